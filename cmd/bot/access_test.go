@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// groupMessage returns an update carrying a group chat message
+func groupMessage(chatID, userID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID, FirstName: "User"},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "group"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}}
+}
+
+func TestUpdatesFromDisallowedChatsIgnored(t *testing.T) {
+	ctx := context.Background()
+	telegram := newFakeTelegram(t)
+	cfg := newTestInstanceConfig(t)
+	cfg.Bot.Access.AllowedChats = []int64{-100}
+	main, _ := newTestInstances(t, cfg, telegram)
+
+	main.handleUpdate(ctx, groupMessage(-200, 7, "@main_bot hello"))
+	main.handleUpdate(ctx, groupMessage(-100, 7, "@main_bot hello"))
+	main.handleUpdate(ctx, privateMessage(7, "hello"))
+	main.messageHandler.Shutdown(5 * time.Second)
+
+	answered := make(map[string]bool)
+	for _, msg := range telegram.sentBy("main") {
+		if strings.Contains(msg.text, "prompt:") {
+			answered[msg.chatID] = true
+		}
+	}
+	if answered["-200"] {
+		t.Error("bot answered in a chat outside the allowlist")
+	}
+	if !answered["-100"] || !answered["7"] {
+		t.Errorf("bot answered in chats %v, want the allowed group and the private chat", answered)
+	}
+	if chatCtx, _ := main.storage.GetContext(ctx, -200); chatCtx != nil {
+		t.Errorf("bot kept a context for the refused chat: %+v", chatCtx)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+func TestAllowedUpdates(t *testing.T) {
+	base := []string{"message", "callback_query", "poll", "poll_answer"}
+	tests := []struct {
+		name   string
+		config func(cfg *config.Config)
+		want   []string
+	}{
+		{
+			name:   "defaults",
+			config: func(cfg *config.Config) {},
+			want:   base,
+		},
+		{
+			name:   "announcing joins",
+			config: func(cfg *config.Config) { cfg.Bot.Membership.AnnounceOnJoin = true },
+			want:   append(base[:4:4], "my_chat_member"),
+		},
+		{
+			name:   "pruning on leave",
+			config: func(cfg *config.Config) { cfg.Bot.Membership.PruneOnLeave = true },
+			want:   append(base[:4:4], "my_chat_member"),
+		},
+		{
+			name:   "answering channel posts",
+			config: func(cfg *config.Config) { cfg.Bot.ChannelPosts = true },
+			want:   append(base[:4:4], "channel_post"),
+		},
+		{
+			name: "extra types",
+			config: func(cfg *config.Config) {
+				cfg.Bot.Webhook.AllowedUpdates = []string{" edited_message ", "", "message", "inline_query", "edited_message"}
+			},
+			want: append(base[:4:4], "edited_message", "inline_query"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			tt.config(cfg)
+			if got := allowedUpdates(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allowedUpdates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
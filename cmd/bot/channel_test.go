@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// channelPost returns an update carrying a channel post, which has no sender
+func channelPost(chatID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{ChannelPost: &tgbotapi.Message{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "channel", Title: "News"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}}
+}
+
+func TestChannelPosts(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(map[bool]string{true: "enabled", false: "disabled"}[enabled], func(t *testing.T) {
+			ctx := context.Background()
+			telegram := newFakeTelegram(t)
+			cfg := newTestInstanceConfig(t)
+			cfg.Bot.ChannelPosts = enabled
+			main, _ := newTestInstances(t, cfg, telegram)
+
+			main.handleUpdate(ctx, channelPost(-300, "@main_bot hello"))
+			// Channels follow the group rules, so posts not addressing the bot are skipped
+			main.handleUpdate(ctx, channelPost(-301, "hello"))
+			main.messageHandler.Shutdown(5 * time.Second)
+
+			answered := make(map[string]bool)
+			for _, msg := range telegram.sentBy("main") {
+				if strings.Contains(msg.text, "prompt:") {
+					answered[msg.chatID] = true
+				}
+			}
+			if answered["-300"] != enabled {
+				t.Errorf("answered the mentioning post = %v, want %v", answered["-300"], enabled)
+			}
+			if answered["-301"] {
+				t.Error("answered a post that doesn't address the bot")
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/handlers"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/middleware"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	"github.com/cf-ai-tgbot-go/internal/services/cache"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+	"github.com/cf-ai-tgbot-go/internal/services/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// shutdownDrainTimeout bounds how long shutdown waits for messages being processed
+const shutdownDrainTimeout = 30 * time.Second
+
+// telegramAPIEndpoint is the Bot API URL template the bots talk to
+var telegramAPIEndpoint = tgbotapi.APIEndpoint
+
+// sharedServices are used by every bot the process runs
+type sharedServices struct {
+	metrics   *middleware.Metrics
+	knowledge knowledge.Service
+	localizer *i18n.Localizer
+}
+
+// botInstance is one Telegram bot with its own token, storage, services and
+// handlers. Several instances can run side by side in one process.
+type botInstance struct {
+	name    string
+	cfg     *config.Config
+	bot     *tgbotapi.BotAPI
+	storage *storage.Manager
+	metrics *middleware.Metrics
+	log     *logrus.Logger
+
+	configHandler  *handlers.ConfigHandler
+	membership     *handlers.MembershipHandler
+	commandHandler *handlers.CommandHandler
+	messageHandler *handlers.MessageHandler
+}
+
+// newBotInstance authorizes the bot and builds its services and handlers
+func newBotInstance(ctx context.Context, name string, cfg *config.Config, shared *sharedServices, log *logrus.Logger) (*botInstance, error) {
+	// Debug: Log token length (not the actual token for security)
+	log.WithFields(logrus.Fields{
+		"bot":          name,
+		"token_length": len(cfg.Bot.Token),
+	}).Info("Bot token loaded")
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.Bot.Token, telegramAPIEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+	bot.Debug = cfg.Logging.Level == "debug"
+	log.WithFields(logrus.Fields{
+		"bot":      name,
+		"username": bot.Self.UserName,
+	}).Info("Bot authorized")
+
+	// Initialize storage
+	storageManager, err := storage.NewManager(cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	// Initialize dynamic config service; the memory backend has no Redis client
+	dynamicConfigService := dynamicconfig.NewDynamicConfigService(storageManager.GetRedisClient(), cfg, log)
+
+	// Initialize AI service with dynamic config
+	aiService := ai.NewDynamicAI(dynamicConfigService, log)
+	if storageManager.UserKeysEnabled() {
+		aiService.SetUserKeys(storageManager)
+	}
+
+	// Initialize cache
+	cacheService := cache.NewCache(cfg, log)
+
+	// Initialize rate limiter
+	rateLimiter := middleware.NewRateLimiter(cfg, log)
+	if overrides, err := storageManager.GetRateLimitOverrides(ctx); err != nil {
+		log.WithError(err).Warn("Failed to load rate limit overrides")
+	} else {
+		for userID, rpm := range overrides {
+			rateLimiter.SetUserLimit(userID, rpm)
+		}
+	}
+
+	// Shared by both handlers: messages record their failures, /errors shows them
+	errorLog := handlers.NewErrorLog(cfg.Bot.ErrorLogSize)
+	chatLocks := handlers.NewChatLocker()
+
+	return &botInstance{
+		name:    name,
+		cfg:     cfg,
+		bot:     bot,
+		storage: storageManager,
+		metrics: shared.metrics,
+		log:     log,
+		configHandler: handlers.NewConfigHandler(
+			bot,
+			dynamicConfigService,
+			storageManager,
+			shared.localizer,
+			log,
+		),
+		membership: handlers.NewMembershipHandler(
+			bot,
+			cfg,
+			storageManager,
+			shared.localizer,
+			log,
+		),
+		commandHandler: handlers.NewCommandHandler(
+			bot,
+			cfg,
+			aiService,
+			shared.knowledge,
+			storageManager,
+			cacheService,
+			rateLimiter,
+			shared.localizer,
+			errorLog,
+			chatLocks,
+			log,
+		),
+		messageHandler: handlers.NewMessageHandler(
+			cfg,
+			bot,
+			aiService,
+			shared.knowledge,
+			storageManager,
+			cacheService,
+			rateLimiter,
+			shared.localizer,
+			errorLog,
+			chatLocks,
+			log,
+		),
+	}, nil
+}
+
+// openUpdates sets up the webhook or long polling and returns the update channel
+func (b *botInstance) openUpdates(ctx context.Context) (tgbotapi.UpdatesChannel, error) {
+	if !b.cfg.Bot.Webhook.Enabled {
+		// Use long polling, reopening the update channel if it ever closes
+		updates := superviseUpdates(ctx, func(offset int) tgbotapi.UpdatesChannel {
+			u := tgbotapi.NewUpdate(offset)
+			u.Timeout = b.cfg.Bot.UpdateTimeout
+			return b.bot.GetUpdatesChan(u)
+		}, b.cfg.Bot.Reconnect, b.log)
+		b.log.WithField("bot", b.name).Info("Using long polling")
+		return updates, nil
+	}
+
+	// Setup webhook
+	webhookURL := fmt.Sprintf("%s/%s", b.cfg.Bot.Webhook.URL, b.bot.Token)
+	webhook, err := tgbotapi.NewWebhook(webhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	webhook.AllowedUpdates = allowedUpdates(b.cfg)
+
+	if _, err := b.bot.Request(webhook); err != nil {
+		return nil, fmt.Errorf("failed to set webhook: %w", err)
+	}
+
+	updates := b.bot.ListenForWebhook("/" + b.bot.Token)
+	b.log.WithFields(logrus.Fields{
+		"bot":            b.name,
+		"url":            webhookURL,
+		"allowedUpdates": webhook.AllowedUpdates,
+	}).Info("Webhook set")
+	return updates, nil
+}
+
+// run handles updates until the channel closes
+func (b *botInstance) run(ctx context.Context, updates tgbotapi.UpdatesChannel) {
+	for update := range updates {
+		b.handleUpdate(ctx, update)
+	}
+}
+
+// refreshUsername periodically re-reads the bot's username, so mentions keep
+// being recognized after the bot is renamed
+func (b *botInstance) refreshUsername(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.messageHandler.RefreshUsername(); err != nil {
+				b.log.WithError(err).WithField("bot", b.name).Warn("Failed to refresh bot username")
+			}
+		}
+	}
+}
+
+// handleUpdate dispatches an update to the matching handler
+func (b *botInstance) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	// Refuse chats outside the configured allowlist
+	if chat := update.FromChat(); chat != nil && !b.membership.IsChatAllowed(chat) {
+		b.membership.HandleDisallowedChat(ctx, chat)
+		return
+	}
+	
+	// Handle callback queries
+	if update.CallbackQuery != nil {
+		// Check if it's a config-related callback
+		if strings.HasPrefix(update.CallbackQuery.Data, "config:") {
+			if err := b.configHandler.HandleConfigCallback(ctx, update.CallbackQuery); err != nil {
+				b.log.WithError(err).Error("Failed to handle config callback")
+			}
+		} else if strings.HasPrefix(update.CallbackQuery.Data, "followup:") {
+			if err := b.messageHandler.HandleFollowUpCallback(ctx, update.CallbackQuery); err != nil {
+				b.log.WithError(err).Error("Failed to handle follow-up callback")
+			}
+		} else if strings.HasPrefix(update.CallbackQuery.Data, "example:") {
+			if err := b.messageHandler.HandleExampleCallback(ctx, update.CallbackQuery); err != nil {
+				b.log.WithError(err).Error("Failed to handle example callback")
+			}
+		} else {
+			if err := b.commandHandler.HandleCallbackQuery(ctx, update.CallbackQuery); err != nil {
+				b.log.WithError(err).Error("Failed to handle callback query")
+			}
+		}
+		return
+	}
+	
+	// Handle the bot being added to or removed from chats
+	if update.MyChatMember != nil {
+		if err := b.membership.HandleMyChatMember(ctx, update.MyChatMember); err != nil {
+			b.log.WithError(err).Error("Failed to handle membership change")
+		}
+		return
+	}
+	
+	// Handle votes and results of model selection polls
+	if update.PollAnswer != nil {
+		if err := b.commandHandler.HandlePollAnswer(ctx, update.PollAnswer); err != nil {
+			b.log.WithError(err).Error("Failed to handle poll answer")
+		}
+		return
+	}
+	if update.Poll != nil {
+		if err := b.commandHandler.HandlePoll(ctx, update.Poll); err != nil {
+			b.log.WithError(err).Error("Failed to handle poll update")
+		}
+		return
+	}
+	
+	// Answer channel posts when enabled
+	if update.ChannelPost != nil {
+		if !b.cfg.Bot.ChannelPosts {
+			return
+		}
+		b.metrics.RecordMessageReceived("channel")
+		if err := b.messageHandler.HandleChannelPost(ctx, &update); err != nil {
+			b.log.WithError(err).Error("Failed to handle channel post")
+			b.metrics.RecordMessageProcessed("error")
+		} else {
+			b.metrics.RecordMessageProcessed("success")
+		}
+		return
+	}
+	
+	// Skip if no message
+	if update.Message == nil {
+		return
+	}
+
+	// Record metrics
+	chatType := "private"
+	if update.Message.Chat.IsGroup() || update.Message.Chat.IsSuperGroup() {
+		chatType = "group"
+	}
+	b.metrics.RecordMessageReceived(chatType)
+
+	// Handle commands
+	if update.Message.IsCommand() {
+		b.metrics.RecordCommandExecuted(update.Message.Command())
+		
+		if err := b.commandHandler.HandleCommand(ctx, update.Message); err != nil {
+			b.log.WithError(err).Error("Failed to handle command")
+			b.metrics.RecordMessageProcessed("error")
+		} else {
+			b.metrics.RecordMessageProcessed("success")
+		}
+		return
+	}
+
+	// Handle regular messages
+	// First check if user is in config mode
+	if handled, err := b.configHandler.HandleConfigInput(ctx, update.Message); handled {
+		if err != nil {
+			b.log.WithError(err).Error("Failed to handle config input")
+		}
+		return
+	}
+	
+	// Then handle as regular message
+	if err := b.messageHandler.HandleMessage(ctx, &update); err != nil {
+		b.log.WithError(err).Error("Failed to handle message")
+		b.metrics.RecordMessageProcessed("error")
+	} else {
+		b.metrics.RecordMessageProcessed("success")
+	}
+}
+
+// shutdown removes the webhook so Telegram stops delivering to this process,
+// lets the messages being processed finish and saves the in-memory contexts
+// when a snapshot file is configured
+func (b *botInstance) shutdown() {
+	if b.cfg.Bot.Webhook.Enabled {
+		if _, err := b.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			b.log.WithError(err).WithField("bot", b.name).Error("Failed to delete webhook")
+		}
+	}
+	b.messageHandler.Shutdown(shutdownDrainTimeout)
+	if err := b.storage.SaveSnapshot(); err != nil {
+		b.log.WithError(err).WithField("bot", b.name).Error("Failed to save context snapshot")
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/middleware"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// TestMain runs the tests from the repository root, where the language files
+// are loaded from
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// sentMessage is a sendMessage request received by fakeTelegram
+type sentMessage struct {
+	token  string
+	chatID string
+	text   string
+}
+
+// fakeTelegram answers Bot API requests of any token and records the
+// messages sent
+type fakeTelegram struct {
+	server *httptest.Server
+
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	f := &fakeTelegram{}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	// Paths are /bot<token>/<method>
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	token, method := parts[0], parts[len(parts)-1]
+
+	var result interface{} = true
+	switch method {
+	case "getMe":
+		result = map[string]interface{}{"id": len(token), "is_bot": true, "first_name": token, "username": token + "_bot"}
+	case "sendMessage", "editMessageText":
+		f.mu.Lock()
+		f.sent = append(f.sent, sentMessage{token: token, chatID: r.PostForm.Get("chat_id"), text: r.PostForm.Get("text")})
+		f.mu.Unlock()
+		chatID, _ := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+		result = map[string]interface{}{
+			"message_id": 1000,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]interface{}{"id": chatID, "type": "private"},
+			"text":       r.PostForm.Get("text"),
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+// sentBy returns the messages sent with token
+func (f *fakeTelegram) sentBy(token string) []sentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sent []sentMessage
+	for _, msg := range f.sent {
+		if msg.token == token {
+			sent = append(sent, msg)
+		}
+	}
+	return sent
+}
+
+// echoEndpoint is a chat completion endpoint answering with the system
+// prompt it was sent
+type echoEndpoint struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (e *echoEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Messages []models.Message `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+	e.mu.Lock()
+	e.requests++
+	e.mu.Unlock()
+
+	answer := "no system prompt"
+	if len(request.Messages) > 0 && request.Messages[0].Role == "system" {
+		answer = "prompt: " + request.Messages[0].Content
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": answer}}},
+	})
+}
+
+func newTestLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// newTestInstances builds the main bot and one additional bot of cfg, both
+// talking to telegram
+func newTestInstances(t *testing.T, cfg *config.Config, telegram *fakeTelegram) (*botInstance, *botInstance) {
+	previous := telegramAPIEndpoint
+	telegramAPIEndpoint = telegram.server.URL + "/bot%s/%s"
+	t.Cleanup(func() { telegramAPIEndpoint = previous })
+
+	log := newTestLogger()
+	localizer, err := i18n.NewLocalizer(&cfg.I18n, log)
+	if err != nil {
+		t.Fatalf("failed to create localizer: %v", err)
+	}
+	shared := &sharedServices{metrics: middleware.NewMetrics(), localizer: localizer}
+
+	ctx := context.Background()
+	main, err := newBotInstance(ctx, "main", cfg, shared, log)
+	if err != nil {
+		t.Fatalf("newBotInstance(main): %v", err)
+	}
+	second, err := newBotInstance(ctx, cfg.Bot.Instances[0].Name, cfg.ForInstance(cfg.Bot.Instances[0]), shared, log)
+	if err != nil {
+		t.Fatalf("newBotInstance(second): %v", err)
+	}
+	return main, second
+}
+
+// newTestInstanceConfig returns a configuration with one additional bot whose
+// system prompt differs from the main bot's
+func newTestInstanceConfig(t *testing.T) *config.Config {
+	endpoint := &echoEndpoint{}
+	server := httptest.NewServer(http.HandlerFunc(endpoint.serve))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Bot.Token = "main"
+	cfg.Bot.Workers = 1
+	cfg.Bot.QueueSize = 16
+	cfg.Bot.Instances = []config.BotInstanceConfig{{Name: "second", Token: "second", DefaultSystemPrompt: "You are the second bot."}}
+	cfg.Models.Default = "test-model"
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: "test-model"}},
+	}}
+	cfg.Storage.Type = "memory"
+	cfg.Storage.Memory.DefaultExpiration = time.Hour
+	cfg.Storage.Memory.CleanupInterval = time.Hour
+	cfg.Context.MaxMessages = 20
+	cfg.Context.DefaultSystemPrompt = "You are the main bot."
+	cfg.I18n.DefaultLanguage = "zh-CN"
+	cfg.I18n.Languages = []string{"zh-CN"}
+	return cfg
+}
+
+// privateMessage returns an update carrying a private chat message
+func privateMessage(chatID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: chatID, FirstName: "User"},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}}
+}
+
+func TestBotInstancesOperateIndependently(t *testing.T) {
+	ctx := context.Background()
+	telegram := newFakeTelegram(t)
+	main, second := newTestInstances(t, newTestInstanceConfig(t), telegram)
+
+	if main.bot.Self.UserName != "main_bot" || second.bot.Self.UserName != "second_bot" {
+		t.Fatalf("bots authorized as %q and %q, want main_bot and second_bot", main.bot.Self.UserName, second.bot.Self.UserName)
+	}
+
+	// The same chat talks to both bots
+	const chatID = 42
+	main.handleUpdate(ctx, privateMessage(chatID, "hello main"))
+	second.handleUpdate(ctx, privateMessage(chatID, "hello second"))
+	main.messageHandler.Shutdown(5 * time.Second)
+	second.messageHandler.Shutdown(5 * time.Second)
+
+	tests := []struct {
+		bot        *botInstance
+		wantText   string
+		otherText  string
+		wantPrompt string
+	}{
+		{bot: main, wantText: "hello main", otherText: "hello second", wantPrompt: "prompt: You are the main bot."},
+		{bot: second, wantText: "hello second", otherText: "hello main", wantPrompt: "prompt: You are the second bot."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bot.name, func(t *testing.T) {
+			// Each bot answers with its own token and system prompt
+			sent := telegram.sentBy(tt.bot.cfg.Bot.Token)
+			if len(sent) == 0 {
+				t.Fatal("bot sent nothing")
+			}
+			last := sent[len(sent)-1]
+			if last.chatID != fmt.Sprint(chatID) || !strings.Contains(last.text, tt.wantPrompt) {
+				t.Errorf("bot answered %q in chat %s, want %q", last.text, last.chatID, tt.wantPrompt)
+			}
+
+			// and keeps the conversation in its own storage
+			chatCtx, err := tt.bot.storage.GetContext(ctx, chatID)
+			if err != nil || chatCtx == nil {
+				t.Fatalf("GetContext = %v, %v", chatCtx, err)
+			}
+			var texts []string
+			for _, msg := range chatCtx.Messages {
+				texts = append(texts, msg.Content)
+			}
+			joined := strings.Join(texts, "\n")
+			if !strings.Contains(joined, tt.wantText) {
+				t.Errorf("context %q misses %q", joined, tt.wantText)
+			}
+			if strings.Contains(joined, tt.otherText) {
+				t.Errorf("context %q holds the other bot's message %q", joined, tt.otherText)
+			}
+		})
+	}
+}
+
+func TestRecordActiveChatsPerBot(t *testing.T) {
+	ctx := context.Background()
+	log := newTestLogger()
+	metrics := middleware.NewMetrics()
+	cfg := newTestInstanceConfig(t)
+
+	active := map[string]int{"main": 3, "second": 5}
+	managers := make(map[string]*storage.Manager)
+	for name, count := range active {
+		manager, err := storage.NewManager(cfg, log)
+		if err != nil {
+			t.Fatalf("NewManager: %v", err)
+		}
+		for i := 0; i < count; i++ {
+			// Chats are listed by their settings
+			chatID := int64(i + 1)
+			if err := manager.SaveSettings(ctx, chatID, &models.ChatSettings{}); err != nil {
+				t.Fatalf("SaveSettings: %v", err)
+			}
+			if err := manager.SaveContext(ctx, &models.ChatContext{ChatID: chatID, LastActivity: time.Now()}); err != nil {
+				t.Fatalf("SaveContext: %v", err)
+			}
+		}
+		managers[name] = manager
+	}
+
+	for name, manager := range managers {
+		recordActiveChats(ctx, name, manager, time.Hour, metrics, log)
+	}
+	// Recording one bot again leaves the other's value alone
+	recordActiveChats(ctx, "main", managers["main"], time.Hour, metrics, log)
+
+	snapshot, err := middleware.GatherSnapshot(prometheus.DefaultGatherer)
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	got := make(map[string]float64)
+	for _, family := range snapshot.Metrics {
+		if family.Name != "telegram_bot_active_chats" {
+			continue
+		}
+		for _, sample := range family.Samples {
+			got[sample.Labels["bot"]] = sample.Value
+		}
+	}
+	for name, count := range active {
+		if got[name] != float64(count) {
+			t.Errorf("active chats of %s = %v, want %d", name, got[name], count)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/middleware"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scheduledKnowledge is a knowledge base whose refreshes return results in
+// turn. It holds one more document after every refresh.
+type scheduledKnowledge struct {
+	knowledge.Service
+	results []error
+
+	mu        sync.Mutex
+	refreshes int
+	done      chan struct{}
+}
+
+func (k *scheduledKnowledge) RefreshKnowledgeBase(ctx context.Context) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.refreshes == len(k.results) {
+		return knowledge.ErrRefreshInProgress
+	}
+	err := k.results[k.refreshes]
+	k.refreshes++
+	if k.refreshes == len(k.results) {
+		close(k.done)
+	}
+	time.Sleep(5 * time.Millisecond)
+	return err
+}
+
+func (k *scheduledKnowledge) GetAllDocuments() []knowledge.Document {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return make([]knowledge.Document, k.refreshes+2)
+}
+
+// gaugeValue returns the current value of an unlabelled gauge
+func gaugeValue(t *testing.T, name string) float64 {
+	snapshot, err := middleware.GatherSnapshot(prometheus.DefaultGatherer)
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range snapshot.Metrics {
+		if family.Name == name && len(family.Samples) > 0 {
+			return family.Samples[0].Value
+		}
+	}
+	return 0
+}
+
+func TestStartKnowledgeRefresh(t *testing.T) {
+	// The first refresh succeeds, the others fail or overlap a running one
+	service := &scheduledKnowledge{
+		results: []error{nil, errors.New("disk unavailable"), knowledge.ErrRefreshInProgress},
+		done:    make(chan struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		startKnowledgeRefresh(ctx, service, 10*time.Millisecond, middleware.NewMetrics(), newTestLogger())
+		close(stopped)
+	}()
+
+	select {
+	case <-service.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("knowledge base not refreshed 3 times within 5s")
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh still scheduled 5s after the context ended")
+	}
+
+	// Only the successful refresh, after which the base held 3 documents, is recorded
+	if got := gaugeValue(t, "telegram_bot_knowledge_documents"); got != 3 {
+		t.Errorf("knowledge documents = %v, want 3", got)
+	}
+	if got := gaugeValue(t, "telegram_bot_knowledge_reindex_duration_seconds"); got < 0.005 {
+		t.Errorf("reindex duration = %vs, want at least the 5ms the refresh took", got)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -11,17 +13,14 @@ import (
 	"time"
 
 	"github.com/cf-ai-tgbot-go/internal/config"
-	"github.com/cf-ai-tgbot-go/internal/handlers"
 	"github.com/cf-ai-tgbot-go/internal/i18n"
 	"github.com/cf-ai-tgbot-go/internal/middleware"
-	"github.com/cf-ai-tgbot-go/internal/services/ai"
-	"github.com/cf-ai-tgbot-go/internal/services/cache"
-	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
 	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
 	"github.com/cf-ai-tgbot-go/internal/services/storage"
 	"github.com/cf-ai-tgbot-go/pkg/logger"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -52,68 +51,40 @@ func main() {
 	}
 
 	log.Info("Starting Telegram Bot...")
-	
-	// Debug: Log token length (not the actual token for security)
-	log.WithField("token_length", len(cfg.Bot.Token)).Info("Bot token loaded")
-
-	// Initialize bot
-	bot, err := tgbotapi.NewBotAPI(cfg.Bot.Token)
-	if err != nil {
-		log.WithError(err).Fatal("Failed to create bot")
-	}
-
-	bot.Debug = cfg.Logging.Level == "debug"
-	log.WithField("username", bot.Self.UserName).Info("Bot authorized")
 
 	// Initialize services
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Initialize storage
-	storageManager, err := storage.NewManager(cfg, log)
-	if err != nil {
-		log.WithError(err).Fatal("Failed to initialize storage")
-	}
-
-	// Initialize dynamic config service
-	redisClient := storageManager.GetRedisClient()
-	if redisClient == nil {
-		log.Fatal("Dynamic configuration requires Redis storage")
-	}
-	
-	dynamicConfigService := dynamicconfig.NewDynamicConfigService(redisClient, cfg, log)
-
-	// Initialize AI service with dynamic config
-	aiService := ai.NewDynamicAI(dynamicConfigService, log)
+	// Initialize metrics
+	metrics := middleware.NewMetrics()
 
 	// Initialize knowledge service
 	var knowledgeService knowledge.Service
 	if cfg.Knowledge.Enabled {
-		knowledgeService = knowledge.NewVectorKnowledgeService(log)
-		if err := knowledgeService.LoadKnowledgeBase(ctx, cfg.Knowledge.Directory); err != nil {
+		vectorService := knowledge.NewVectorKnowledgeService(log)
+		vectorService.SetSearchCacheTTL(cfg.Knowledge.SearchCacheTTL)
+		vectorService.SetSearchConcurrency(cfg.Knowledge.MaxConcurrentSearches)
+		vectorService.SetSearchObserver(metrics.RecordKnowledgeSearch)
+		knowledgeService = vectorService
+		if err := knowledgeService.LoadKnowledgeBase(ctx, cfg.Knowledge.Directories...); err != nil {
 			log.WithError(err).Error("Failed to load knowledge base")
 			// Continue without knowledge base
 			knowledgeService = nil
 		} else {
 			docs := knowledgeService.GetAllDocuments()
 			log.WithField("documents", len(docs)).Info("Knowledge base loaded")
+			metrics.RecordKnowledgeReindex(0, len(docs))
 		}
 	}
 
-	// Initialize cache
-	cacheService := cache.NewCache(cfg, log)
-
-	// Initialize rate limiter
-	rateLimiter := middleware.NewRateLimiter(cfg, log)
-
 	// Initialize i18n
-	localizer, err := i18n.NewLocalizer(&cfg.I18n)
+	localizer, err := i18n.NewLocalizer(&cfg.I18n, log)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to initialize i18n")
 	}
-
-	// Initialize metrics
-	metrics := middleware.NewMetrics()
+	// Only offer languages that can actually be shown
+	cfg.I18n.Languages = localizer.Languages()
 
 	// Start metrics server if enabled
 	if cfg.Monitoring.Metrics.Enabled {
@@ -129,140 +100,60 @@ func main() {
 		}()
 	}
 
-	// Initialize config handler
-	configHandler := handlers.NewConfigHandler(
-		bot,
-		dynamicConfigService,
-		storageManager,
-		log,
-	)
-
-	// Initialize handlers
-	commandHandler := handlers.NewCommandHandler(
-		bot,
-		cfg,
-		aiService,
-		knowledgeService,
-		storageManager,
-		cacheService,
-		rateLimiter,
-		localizer,
-		log,
-	)
-	
-	messageHandler := handlers.NewMessageHandler(
-		cfg,
-		bot,
-		aiService,
-		knowledgeService,
-		storageManager,
-		cacheService,
-		rateLimiter,
-		localizer,
-		log,
-	)
-
-	// Setup update channel
-	var updates tgbotapi.UpdatesChannel
-
-	if cfg.Bot.Webhook.Enabled {
-		// Setup webhook
-		webhookURL := fmt.Sprintf("%s/%s", cfg.Bot.Webhook.URL, bot.Token)
-		webhook, err := tgbotapi.NewWebhook(webhookURL)
+	// Build the main bot and any additional ones; they share the knowledge
+	// base, translations and metrics but nothing else
+	shared := &sharedServices{
+		metrics:   metrics,
+		knowledge: knowledgeService,
+		localizer: localizer,
+	}
+	type botSetup struct {
+		name string
+		cfg  *config.Config
+	}
+	setups := []botSetup{{name: "main", cfg: cfg}}
+	for _, instance := range cfg.Bot.Instances {
+		setups = append(setups, botSetup{name: instance.Name, cfg: cfg.ForInstance(instance)})
+	}
+
+	bots := make([]*botInstance, 0, len(setups))
+	for _, setup := range setups {
+		b, err := newBotInstance(ctx, setup.name, setup.cfg, shared, log)
 		if err != nil {
-			log.WithError(err).Fatal("Failed to create webhook")
+			log.WithError(err).WithField("bot", setup.name).Fatal("Failed to start bot")
 		}
-
-		if _, err := bot.Request(webhook); err != nil {
-			log.WithError(err).Fatal("Failed to set webhook")
+		updates, err := b.openUpdates(ctx)
+		if err != nil {
+			log.WithError(err).WithField("bot", setup.name).Fatal("Failed to receive updates")
 		}
-
-		updates = bot.ListenForWebhook("/" + bot.Token)
-		log.WithField("url", webhookURL).Info("Webhook set")
-	} else {
-		// Use long polling
-		u := tgbotapi.NewUpdate(0)
-		u.Timeout = cfg.Bot.UpdateTimeout
-
-		updates = bot.GetUpdatesChan(u)
-		log.Info("Using long polling")
+		go b.run(ctx, updates)
+		if setup.cfg.Bot.UsernameRefresh > 0 {
+			go b.refreshUsername(ctx, setup.cfg.Bot.UsernameRefresh)
+		}
+		// Each bot counts its own chats, instances may use separate databases
+		go startPeriodicTasks(ctx, b.name, b.storage, setup.cfg.Bot.ActiveWindow, metrics, log)
+		bots = append(bots, b)
 	}
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Main bot loop
-	go func() {
-		for update := range updates {
-			// Handle callback queries
-			if update.CallbackQuery != nil {
-				// Check if it's a config-related callback
-				if strings.HasPrefix(update.CallbackQuery.Data, "config:") {
-					if err := configHandler.HandleConfigCallback(ctx, update.CallbackQuery); err != nil {
-						log.WithError(err).Error("Failed to handle config callback")
-					}
-				} else {
-					if err := commandHandler.HandleCallbackQuery(ctx, update.CallbackQuery); err != nil {
-						log.WithError(err).Error("Failed to handle callback query")
-					}
-				}
-				continue
-			}
-			
-			// Skip if no message
-			if update.Message == nil {
-				continue
-			}
-
-			// Record metrics
-			chatType := "private"
-			if update.Message.Chat.IsGroup() || update.Message.Chat.IsSuperGroup() {
-				chatType = "group"
-			}
-			metrics.RecordMessageReceived(chatType)
-
-			// Handle commands
-			if update.Message.IsCommand() {
-				metrics.RecordCommandExecuted(update.Message.Command())
-				
-				if err := commandHandler.HandleCommand(ctx, update.Message); err != nil {
-					log.WithError(err).Error("Failed to handle command")
-					metrics.RecordMessageProcessed("error")
-				} else {
-					metrics.RecordMessageProcessed("success")
-				}
-				continue
-			}
-
-			// Handle regular messages
-			// First check if user is in config mode
-			if err := configHandler.HandleConfigInput(ctx, update.Message); err != nil {
-				log.WithError(err).Error("Failed to handle config input")
-			}
-			
-			// Then handle as regular message
-			if err := messageHandler.HandleMessage(ctx, &update); err != nil {
-				log.WithError(err).Error("Failed to handle message")
-				metrics.RecordMessageProcessed("error")
-			} else {
-				metrics.RecordMessageProcessed("success")
-			}
-		}
-	}()
-
 	// Start periodic tasks
-	go startPeriodicTasks(ctx, storageManager, metrics, log)
+	if knowledgeService != nil && cfg.Knowledge.RefreshInterval > 0 {
+		go startKnowledgeRefresh(ctx, knowledgeService, cfg.Knowledge.RefreshInterval, metrics, log)
+	}
+	if cfg.Monitoring.Snapshot.Interval > 0 {
+		go startMetricsSnapshots(ctx, cfg.Monitoring.Snapshot, log)
+	}
 
 	// Wait for shutdown signal
 	<-sigChan
 	log.Info("Shutdown signal received")
 
 	// Cleanup
-	if cfg.Bot.Webhook.Enabled {
-		if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
-			log.WithError(err).Error("Failed to delete webhook")
-		}
+	for _, b := range bots {
+		b.shutdown()
 	}
 
 	// Cancel context to stop all goroutines
@@ -274,8 +165,41 @@ func main() {
 	log.Info("Bot stopped")
 }
 
-// startPeriodicTasks starts periodic background tasks
-func startPeriodicTasks(ctx context.Context, storage *storage.Manager, metrics *middleware.Metrics, log *logrus.Logger) {
+// allowedUpdates lists the update types the bot handles with the current
+// configuration, plus any extra types configured for the webhook
+func allowedUpdates(cfg *config.Config) []string {
+	types := []string{
+		tgbotapi.UpdateTypeMessage,
+		tgbotapi.UpdateTypeCallbackQuery,
+		tgbotapi.UpdateTypePoll,
+		tgbotapi.UpdateTypePollAnswer,
+	}
+	if cfg.Bot.Membership.AnnounceOnJoin || cfg.Bot.Membership.PruneOnLeave || cfg.Bot.Access.LeaveUnallowed {
+		types = append(types, tgbotapi.UpdateTypeMyChatMember)
+	}
+	if cfg.Bot.ChannelPosts {
+		types = append(types, tgbotapi.UpdateTypeChannelPost)
+	}
+
+	seen := make(map[string]bool, len(types))
+	for _, t := range types {
+		seen[t] = true
+	}
+	for _, t := range cfg.Bot.Webhook.AllowedUpdates {
+		if t = strings.TrimSpace(t); t != "" && !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// startPeriodicTasks starts periodic background tasks for the named bot
+func startPeriodicTasks(ctx context.Context, name string, storage *storage.Manager, activeWindow time.Duration, metrics *middleware.Metrics, log *logrus.Logger) {
+	if activeWindow <= 0 {
+		activeWindow = config.DefaultActiveWindow
+	}
+
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
@@ -284,11 +208,99 @@ func startPeriodicTasks(ctx context.Context, storage *storage.Manager, metrics *
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			// Update active users/chats metrics
-			// This is a placeholder - in production you'd query storage
-			// to get actual counts
-			metrics.SetActiveUsers(0)
-			metrics.SetActiveChats(0)
+			recordActiveChats(ctx, name, storage, activeWindow, metrics, log)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// recordActiveChats updates the active users/chats metrics of the named bot
+func recordActiveChats(ctx context.Context, name string, storage *storage.Manager, activeWindow time.Duration, metrics *middleware.Metrics, log *logrus.Logger) {
+	// Active users aren't tracked yet, this is a placeholder
+	metrics.SetActiveUsers(0)
+	active, err := storage.ActiveContexts(ctx, activeWindow)
+	if err != nil {
+		log.WithError(err).WithField("bot", name).Warn("Failed to count active chats")
+		return
+	}
+	metrics.SetActiveChats(name, float64(len(active)))
+}
+
+// startKnowledgeRefresh periodically rebuilds the knowledge base, for directories
+// where file change notifications aren't reliable
+func startKnowledgeRefresh(ctx context.Context, knowledgeService knowledge.Service, interval time.Duration, metrics *middleware.Metrics, log *logrus.Logger) {
+	log.WithField("interval", interval).Info("Starting scheduled knowledge base refresh")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			err := knowledgeService.RefreshKnowledgeBase(ctx)
+			if errors.Is(err, knowledge.ErrRefreshInProgress) {
+				log.Debug("Skipping scheduled knowledge refresh: another refresh is running")
+				continue
+			}
+			if err != nil {
+				log.WithError(err).Error("Scheduled knowledge refresh failed")
+				continue
+			}
+
+			duration := time.Since(start)
+			docs := len(knowledgeService.GetAllDocuments())
+			metrics.RecordKnowledgeReindex(duration, docs)
+			log.WithFields(logrus.Fields{
+				"documents": docs,
+				"duration":  duration,
+			}).Info("Knowledge base reindexed")
+		}
+	}
+}
+
+// startMetricsSnapshots periodically exports the metric values to a file or
+// the log, writing a last snapshot on shutdown
+func startMetricsSnapshots(ctx context.Context, snapshotCfg config.MetricsSnapshotConfig, log *logrus.Logger) {
+	log.WithFields(logrus.Fields{
+		"interval": snapshotCfg.Interval,
+		"path":     snapshotCfg.Path,
+	}).Info("Starting metrics snapshots")
+
+	ticker := time.NewTicker(snapshotCfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			exportMetricsSnapshot(snapshotCfg.Path, log)
+			return
+		case <-ticker.C:
+			exportMetricsSnapshot(snapshotCfg.Path, log)
+		}
+	}
+}
+
+// exportMetricsSnapshot writes the registered metrics to path, or logs them
+// when path is empty
+func exportMetricsSnapshot(path string, log *logrus.Logger) {
+	if path != "" {
+		if err := middleware.WriteMetricsSnapshot(prometheus.DefaultGatherer, path); err != nil {
+			log.WithError(err).Warn("Failed to write metrics snapshot")
+		}
+		return
+	}
+
+	snapshot, err := middleware.GatherSnapshot(prometheus.DefaultGatherer)
+	if err != nil {
+		log.WithError(err).Warn("Failed to gather metrics snapshot")
+		return
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		log.WithError(err).Warn("Failed to encode metrics snapshot")
+		return
+	}
+	log.WithField("metrics", string(data)).Info("Metrics snapshot")
+}
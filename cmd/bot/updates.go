@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultReconnectInitialBackoff is the first wait before reopening the update channel
+	defaultReconnectInitialBackoff = time.Second
+	// defaultReconnectMaxBackoff caps the wait between reconnection attempts
+	defaultReconnectMaxBackoff = time.Minute
+)
+
+// updatesOpener starts receiving updates from offset, the ID of the first
+// update not yet handled
+type updatesOpener func(offset int) tgbotapi.UpdatesChannel
+
+// superviseUpdates forwards updates from the channels open returns. When a
+// channel closes while ctx is still live it is reopened after an exponential
+// backoff, continuing after the last forwarded update, so the bot doesn't go
+// silent. The returned channel closes once ctx is done.
+func superviseUpdates(ctx context.Context, open updatesOpener, cfg config.ReconnectConfig, log *logrus.Logger) tgbotapi.UpdatesChannel {
+	initial := cfg.InitialBackoff
+	if initial <= 0 {
+		initial = defaultReconnectInitialBackoff
+	}
+	maxBackoff := cfg.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultReconnectMaxBackoff
+	}
+	if maxBackoff < initial {
+		maxBackoff = initial
+	}
+
+	out := make(chan tgbotapi.Update, 100)
+	go func() {
+		defer close(out)
+
+		offset := 0
+		backoff := initial
+		for attempt := 1; ; attempt++ {
+			source := open(offset)
+			received := false
+		forward:
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case update, ok := <-source:
+					if !ok {
+						break forward
+					}
+					received = true
+					offset = update.UpdateID + 1
+					select {
+					case out <- update:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			// Updates flowed again, so the connection recovered
+			if received {
+				attempt = 1
+				backoff = initial
+			}
+			log.WithFields(logrus.Fields{
+				"attempt": attempt,
+				"backoff": backoff,
+				"offset":  offset,
+			}).Warn("Update channel closed unexpectedly, reconnecting")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// flakyUpdates is an updates factory whose channels close right away. The
+// first channel delivers updates before closing.
+type flakyUpdates struct {
+	first []int
+
+	mu      sync.Mutex
+	offsets []int
+	opened  []time.Time
+}
+
+func (f *flakyUpdates) open(offset int) tgbotapi.UpdatesChannel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan tgbotapi.Update, len(f.first))
+	if len(f.opened) == 0 {
+		for _, id := range f.first {
+			ch <- tgbotapi.Update{UpdateID: id}
+		}
+	}
+	f.offsets = append(f.offsets, offset)
+	f.opened = append(f.opened, time.Now())
+	close(ch)
+	return ch
+}
+
+func (f *flakyUpdates) calls() ([]int, []time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.offsets...), append([]time.Time(nil), f.opened...)
+}
+
+func TestSuperviseUpdatesReconnects(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	factory := &flakyUpdates{first: []int{5, 6}}
+	cfg := config.ReconnectConfig{InitialBackoff: 20 * time.Millisecond, MaxBackoff: 80 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := superviseUpdates(ctx, factory.open, cfg, log)
+
+	for _, want := range factory.first {
+		select {
+		case update := <-out:
+			if update.UpdateID != want {
+				t.Fatalf("forwarded update %d, want %d", update.UpdateID, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("update %d not forwarded", want)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if offsets, _ := factory.calls(); len(offsets) >= 5 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("channel not reopened 4 times")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	offsets, opened := factory.calls()
+	// Every reconnection continues after the last forwarded update
+	for i, offset := range offsets[:5] {
+		want := 7
+		if i == 0 {
+			want = 0
+		}
+		if offset != want {
+			t.Errorf("open %d used offset %d, want %d", i+1, offset, want)
+		}
+	}
+	// The wait doubles up to the maximum
+	for i, want := range []time.Duration{20, 40, 80, 80} {
+		want *= time.Millisecond
+		if gap := opened[i+1].Sub(opened[i]); gap < want {
+			t.Errorf("reopened %d after %v, want at least %v", i+1, gap, want)
+		}
+	}
+
+	// Cancelling stops the loop and closes the channel
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Error("update forwarded after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel still open after cancellation")
+	}
+	stopped, _ := factory.calls()
+	time.Sleep(200 * time.Millisecond)
+	if after, _ := factory.calls(); len(after) != len(stopped) {
+		t.Errorf("reopened %d more times after cancellation", len(after)-len(stopped))
+	}
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/spf13/viper"
@@ -20,24 +22,180 @@ type Config struct {
 	Monitoring MonitoringConfig `mapstructure:"monitoring"`
 	I18n       I18nConfig       `mapstructure:"i18n"`
 	Knowledge  KnowledgeConfig  `mapstructure:"knowledge"`
+	Markdown   MarkdownConfig   `mapstructure:"markdown"`
+	// PostProcessors are regex replacements applied to every answer, in order
+	PostProcessors []PostProcessorConfig `mapstructure:"post_processors"`
+	// Tools controls how tool results are shown to users, keyed by tool name
+	Tools map[string]ToolConfig `mapstructure:"tools"`
+}
+
+// ToolConfig is the user-facing rendering of a tool's results
+type ToolConfig struct {
+	// Summary is a Go template over the fields of the tool's JSON result,
+	// e.g. "查询了天气：{{.condition}}，{{.temperature}}"
+	Summary string `mapstructure:"summary"`
+}
+
+// MarkdownConfig controls how markdown Telegram can't show as such is converted
+type MarkdownConfig struct {
+	Quotes string `mapstructure:"quotes"` // native（Telegram 引用块，默认）或 prefix（"> " 前缀行）
+	Images string `mapstructure:"images"` // link（渲染为图片链接，默认）或 drop（丢弃）
+}
+
+// PostProcessorConfig is a named regex replace rule for answers
+type PostProcessorConfig struct {
+	Name        string `mapstructure:"name"`
+	Pattern     string `mapstructure:"pattern"`
+	Replacement string `mapstructure:"replacement"` // 支持 $1 等分组引用
 }
 
 type BotConfig struct {
 	Token   string        `mapstructure:"token"`
 	Webhook WebhookConfig `mapstructure:"webhook"`
 	UpdateTimeout int    `mapstructure:"update_timeout"`
+	// Reconnect controls reopening the long-polling update channel when it closes
+	Reconnect ReconnectConfig `mapstructure:"reconnect"`
+	// Workers caps concurrent message processing (0 uses DefaultWorkers)
+	Workers int `mapstructure:"workers"`
+	// QueueSize is how many messages may wait for a worker before new ones are refused
+	QueueSize int `mapstructure:"queue_size"`
+	AdminIDs   []int64          `mapstructure:"admin_ids"` // users allowed to run admin commands
+	Membership MembershipConfig `mapstructure:"membership"`
+	Access     AccessConfig     `mapstructure:"access"`
+	Broadcast  BroadcastConfig  `mapstructure:"broadcast"`
+	ModelPoll  ModelPollConfig  `mapstructure:"model_poll"`
+	// Onboarding walks users without saved settings through picking a
+	// language and model when they first send /start in a private chat
+	Onboarding bool `mapstructure:"onboarding"`
+	// ChannelPosts answers posts in channels where the bot is an admin,
+	// following the same mention and keyword rules as groups
+	ChannelPosts bool `mapstructure:"channel_posts"`
+	// UsernameRefresh is how often the bot's username is re-read so mentions
+	// keep working after a rename (0 disables)
+	UsernameRefresh time.Duration `mapstructure:"username_refresh"`
+	// ErrorLogSize is how many recent errors are kept for /errors
+	ErrorLogSize int `mapstructure:"error_log_size"`
+	// ActiveWindow is how recently a chat must have been used to count as
+	// active, for /active and the active chats metric (0 uses the default)
+	ActiveWindow time.Duration `mapstructure:"active_window"`
+	// Instances are additional bots run by the same process, each with its
+	// own token, storage and update loop
+	Instances []BotInstanceConfig `mapstructure:"instances"`
+}
+
+// BotInstanceConfig is an additional bot sharing this configuration. Empty
+// fields fall back to the main bot's values.
+type BotInstanceConfig struct {
+	Name                string  `mapstructure:"name"`
+	Token               string  `mapstructure:"token"`
+	TokenEnv            string  `mapstructure:"token_env"` // 从该环境变量读取 token，避免写入配置文件
+	AdminIDs            []int64 `mapstructure:"admin_ids"`
+	DefaultSystemPrompt string  `mapstructure:"default_system_prompt"`
+	BotPersonality      string  `mapstructure:"bot_personality"`
+	RedisDB             int     `mapstructure:"redis_db"` // 独立的 Redis 数据库，使聊天数据与其他机器人互不影响
+}
+
+// ReconnectConfig is the exponential backoff between attempts to reopen the
+// update channel
+type ReconnectConfig struct {
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"` // 首次重连前的等待时间，0 使用默认值 1s
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`     // 重连等待时间上限，0 使用默认值 1m
+}
+
+// ModelPollConfig controls the poll-based group model selector
+type ModelPollConfig struct {
+	Threshold       int `mapstructure:"threshold"`        // votes needed to pick a model early
+	DurationSeconds int `mapstructure:"duration_seconds"` // how long the poll stays open
+}
+
+// BroadcastConfig paces /broadcast to stay under Telegram's global send limit
+type BroadcastConfig struct {
+	RatePerSecond int `mapstructure:"rate_per_second"` // messages sent per second across all chats
+	Concurrency   int `mapstructure:"concurrency"`     // chats sent to in parallel
+}
+
+// AccessConfig restricts which chats the bot serves; the defaults allow every chat
+type AccessConfig struct {
+	AllowedChats   []int64 `mapstructure:"allowed_chats"`   // group chats the bot serves, empty allows all
+	PrivateOnly    bool    `mapstructure:"private_only"`    // refuse all group chats
+	LeaveUnallowed bool    `mapstructure:"leave_unallowed"` // leave refused chats with a notice instead of ignoring them
+}
+
+// MembershipConfig controls how the bot reacts to being added to or removed from chats
+type MembershipConfig struct {
+	AnnounceOnJoin bool `mapstructure:"announce_on_join"`
+	PruneOnLeave   bool `mapstructure:"prune_on_leave"`
 }
 
 type WebhookConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
 	URL     string `mapstructure:"url"`
 	Port    int    `mapstructure:"port"`
+	// Extra update types to receive besides the ones the bot handles
+	AllowedUpdates []string `mapstructure:"allowed_updates"`
 }
 
 
 type ModelsConfig struct {
 	Default   string           `mapstructure:"default"`
 	Endpoints []ModelEndpoint  `mapstructure:"endpoints"`
+	// Limits for endpoints and models added at runtime
+	MaxDynamicEndpoints  int `mapstructure:"max_dynamic_endpoints"`
+	MaxModelsPerEndpoint int `mapstructure:"max_models_per_endpoint"`
+	// EndpointVisibility decides who sees endpoints added at runtime, one of the
+	// EndpointVisibility constants; empty shares them with everyone
+	EndpointVisibility string `mapstructure:"endpoint_visibility"`
+	HTTP                 HTTPClientConfig `mapstructure:"http"`
+	// InteractiveRetries is how often chat replies are retried (0 uses the default, negative disables)
+	InteractiveRetries int `mapstructure:"interactive_retries"`
+	// GroupBy groups the model keyboard by "endpoint" (default) or model "category"
+	GroupBy string `mapstructure:"group_by"`
+	// LogMessageContent keeps message text in the debug log of outgoing request
+	// bodies; by default it is replaced with its length
+	LogMessageContent bool `mapstructure:"log_message_content"`
+	// RateLimitWait is how long a request may queue for an endpoint's rate
+	// limit before failing (0 fails right away)
+	RateLimitWait time.Duration `mapstructure:"rate_limit_wait"`
+	// SafeMode locks the endpoints and models to the file/env configuration:
+	// runtime changes from Telegram are refused and stored ones are ignored
+	SafeMode bool `mapstructure:"safe_mode"`
+	// UserKeySecret encrypts the API keys users register with /mykey; per-user
+	// keys are disabled while it is empty
+	UserKeySecret string `mapstructure:"user_key_secret"`
+}
+
+// Grouping of the model selection keyboard
+const (
+	// ModelGroupByEndpoint lists models under the endpoint serving them
+	ModelGroupByEndpoint = "endpoint"
+	// ModelGroupByCategory lists models under their category
+	ModelGroupByCategory = "category"
+)
+
+// MinUserKeySecretLength is the shortest accepted models.user_key_secret
+const MinUserKeySecretLength = 16
+
+// DefaultWorkers is used when bot.workers is unset
+const DefaultWorkers = 16
+
+// DefaultActiveWindow is used when bot.active_window is unset
+const DefaultActiveWindow = 24 * time.Hour
+
+// Visibility of endpoints added at runtime
+const (
+	// EndpointVisibilityGlobal shares every added endpoint with all users
+	EndpointVisibilityGlobal = "global"
+	// EndpointVisibilityOwner keeps endpoints added by non-admins private to
+	// their owner; endpoints added by admins are still shared
+	EndpointVisibilityOwner = "owner"
+)
+
+// HTTPClientConfig tunes the connection pool used for AI requests
+type HTTPClientConfig struct {
+	MaxIdleConns        int           `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
+	DisableKeepAlives   bool          `mapstructure:"disable_keep_alives"`
 }
 
 type ModelEndpoint struct {
@@ -46,18 +204,39 @@ type ModelEndpoint struct {
 	BaseURL     string       `mapstructure:"base_url"`
 	APIKey      string       `mapstructure:"api_key"`
 	Models      []ModelInfo  `mapstructure:"models"`
+	// SupportsPrefill marks endpoints that continue a trailing assistant message
+	SupportsPrefill bool `mapstructure:"supports_prefill"`
+	// SystemPrompt is prepended to the chat's system prompt for this endpoint's models
+	SystemPrompt string `mapstructure:"system_prompt"`
+	// Priority orders endpoints in the model keyboard; lower comes first, ties keep the configured order
+	Priority int `mapstructure:"priority"`
+	// RequestsPerMinute caps requests sent to this endpoint by all users together (0 is unlimited)
+	RequestsPerMinute int `mapstructure:"requests_per_minute"`
+	// Burst is how many requests may be sent at once within that rate (0 means 1)
+	Burst int `mapstructure:"burst"`
+	// Organization and Project scope billing on OpenAI, sent as the
+	// OpenAI-Organization and OpenAI-Project headers when set
+	Organization string `mapstructure:"organization"`
+	Project      string `mapstructure:"project"`
 }
 
 type ModelInfo struct {
-	ID        string `mapstructure:"id"`
-	Name      string `mapstructure:"name"`
-	MaxTokens int    `mapstructure:"max_tokens"`
+	ID               string  `mapstructure:"id"`
+	Name             string  `mapstructure:"name"`
+	MaxTokens        int     `mapstructure:"max_tokens"`
+	InputPricePer1K  float64 `mapstructure:"input_price_per_1k"`  // 每千输入 token 价格（可选）
+	OutputPricePer1K float64 `mapstructure:"output_price_per_1k"` // 每千输出 token 价格（可选）
+	RequestModelID   string  `mapstructure:"request_model_id"`   // 请求中使用的模型 ID（可选，网关使用不同 ID 时设置）
+	UseKnowledge     *bool   `mapstructure:"use_knowledge"`      // 是否注入知识库内容（可选，未设置时跟随 knowledge.enabled）
+	Category         string  `mapstructure:"category"`           // 模型分类，models.group_by 为 category 时按此分组（可选）
 }
 
 type StorageConfig struct {
 	Type   string       `mapstructure:"type"`
 	Redis  RedisConfig  `mapstructure:"redis"`
 	Memory MemoryConfig `mapstructure:"memory"`
+	// Retention prunes contexts idle for longer than this and the chat states left without a context (0 disables)
+	Retention time.Duration `mapstructure:"retention"`
 }
 
 type RedisConfig struct {
@@ -69,25 +248,121 @@ type RedisConfig struct {
 type MemoryConfig struct {
 	DefaultExpiration time.Duration `mapstructure:"default_expiration"`
 	CleanupInterval   time.Duration `mapstructure:"cleanup_interval"`
+	// SnapshotPath is where contexts are saved on shutdown and reloaded from on
+	// startup, so a restart keeps conversations (empty disables)
+	SnapshotPath string `mapstructure:"snapshot_path"`
+	// SnapshotMaxAge drops reloaded contexts idle for longer than this (0 keeps
+	// every context that hasn't expired)
+	SnapshotMaxAge time.Duration `mapstructure:"snapshot_max_age"`
 }
 
 type CacheConfig struct {
 	Enabled bool          `mapstructure:"enabled"`
 	TTL     time.Duration `mapstructure:"ttl"`
 	MaxSize int           `mapstructure:"max_size"`
+	// ExcludePatterns are regular expressions; questions matching any of them,
+	// such as time-sensitive ones, are never answered from or stored in the cache
+	ExcludePatterns []string `mapstructure:"exclude_patterns"`
+	// ExcludeKeywords exclude questions containing any of them, ignoring case
+	ExcludeKeywords []string `mapstructure:"exclude_keywords"`
 }
 
 type RateLimitConfig struct {
 	Enabled            bool `mapstructure:"enabled"`
 	RequestsPerMinute  int  `mapstructure:"requests_per_minute"`
 	Burst              int  `mapstructure:"burst"`
+	// Users rejected LockoutThreshold times within LockoutWindow are ignored
+	// for LockoutDuration (threshold 0 disables the lockout)
+	LockoutThreshold int           `mapstructure:"lockout_threshold"`
+	LockoutWindow    time.Duration `mapstructure:"lockout_window"`
+	LockoutDuration  time.Duration `mapstructure:"lockout_duration"`
 }
 
 type ContextConfig struct {
 	MaxMessages         int      `mapstructure:"max_messages"`
+	// SummarizeTokens summarizes the oldest half of the conversation once its
+	// estimated token count exceeds this, after the reply was sent (0 disables)
+	SummarizeTokens int `mapstructure:"summarize_tokens"`
+	// SummaryMaxTokens bounds the summary replacing the summarized messages (0 uses the default)
+	SummaryMaxTokens int `mapstructure:"summary_max_tokens"`
 	DefaultSystemPrompt string   `mapstructure:"default_system_prompt"`
 	DefaultMentionWords []string `mapstructure:"default_mention_words"`
 	BotPersonality      string   `mapstructure:"bot_personality"`
+	// ShowThink is the default for showing the model's thinking in new chats
+	ShowThink bool `mapstructure:"show_think"`
+	// SystemReminderInterval re-injects the system prompt every N user turns (0 disables)
+	SystemReminderInterval int `mapstructure:"system_reminder_interval"`
+	// InactivityMinutes clears the context after this many idle minutes (0 disables)
+	InactivityMinutes int `mapstructure:"inactivity_minutes"`
+	// GreetingCooldown suppresses mention greetings in a chat for this long after one (0 always greets)
+	GreetingCooldown time.Duration `mapstructure:"greeting_cooldown"`
+	// FileResponseChars sends longer responses as a document instead of a message (0 disables)
+	FileResponseChars int `mapstructure:"file_response_chars"`
+	// ResponseTimeBudget streams answers and cuts them off after this long,
+	// sending the partial answer with a note (0 disables)
+	ResponseTimeBudget time.Duration `mapstructure:"response_time_budget"`
+	// MinGroupMessageChars ignores shorter group messages that only match a mention word or keyword (0 disables)
+	MinGroupMessageChars int `mapstructure:"min_group_message_chars"`
+	// GroupSizeTiers make the bot more reserved in bigger groups; the tier
+	// with the largest MinMembers not above a group's member count applies
+	GroupSizeTiers []GroupSizeTierConfig `mapstructure:"group_size_tiers"`
+	// GroupSizeCacheTTL is how long a group's member count is reused before
+	// it is fetched again (0 uses the default)
+	GroupSizeCacheTTL time.Duration `mapstructure:"group_size_cache_ttl"`
+	// ResponseFooter is markdown appended to every response, e.g. branding (empty disables)
+	ResponseFooter string `mapstructure:"response_footer"`
+	// FollowUps are suggested follow-up buttons attached to answers, keyed by
+	// language (lower-case, e.g. zh-cn); an empty list disables them
+	FollowUps map[string][]FollowUpConfig `mapstructure:"follow_ups"`
+	// Examples are prompts offered by /examples, keyed by language like FollowUps
+	Examples map[string][]string `mapstructure:"examples"`
+	// ExamplesPerPage is how many examples are shown at once (0 uses the default)
+	ExamplesPerPage int `mapstructure:"examples_per_page"`
+	// Profiles are selectable use-case presets; built-in ones are used when empty
+	Profiles []ProfileConfig `mapstructure:"profiles"`
+	// PromptLibrary lists preset system prompts chats can switch to from the settings menu
+	PromptLibrary []PromptPresetConfig `mapstructure:"prompt_library"`
+}
+
+// FollowUpConfig is a suggested follow-up: a button label and the instruction
+// sent to the model when it is pressed
+type FollowUpConfig struct {
+	Label  string `mapstructure:"label"`
+	Prompt string `mapstructure:"prompt"`
+}
+
+// GroupSizeTierConfig is the behavior in groups of at least MinMembers members
+type GroupSizeTierConfig struct {
+	MinMembers int `mapstructure:"min_members"`
+	// SkipRate is the fraction of mention word and keyword triggers left
+	// unanswered; @mentions and replies to the bot are always answered
+	SkipRate float64 `mapstructure:"skip_rate"`
+	// GreetingCooldown replaces context.greeting_cooldown when it is longer
+	GreetingCooldown time.Duration `mapstructure:"greeting_cooldown"`
+}
+
+// ProfileConfig bundles generation parameters for a use case
+type ProfileConfig struct {
+	Name           string  `mapstructure:"name"`
+	DisplayName    string  `mapstructure:"display_name"`
+	Temperature    float64 `mapstructure:"temperature"`
+	TopP           float64 `mapstructure:"top_p"`           // 0 表示不设置
+	PromptAddendum string  `mapstructure:"prompt_addendum"` // 附加到系统提示词的内容
+}
+
+// PromptPresetConfig is a named system prompt of the prompt library. Name and
+// Prompt may be translated per language (lower-case keys, e.g. en-us).
+type PromptPresetConfig struct {
+	ID        string                      `mapstructure:"id"`
+	Name      string                      `mapstructure:"name"`
+	Prompt    string                      `mapstructure:"prompt"`
+	Localized map[string]PromptPresetText `mapstructure:"localized"`
+}
+
+// PromptPresetText is the translated name and prompt of a preset
+type PromptPresetText struct {
+	Name   string `mapstructure:"name"`
+	Prompt string `mapstructure:"prompt"`
 }
 
 type LoggingConfig struct {
@@ -106,6 +381,15 @@ type FileConfig struct {
 
 type MonitoringConfig struct {
 	Metrics MetricsConfig `mapstructure:"metrics"`
+	// Snapshot periodically exports the metric values without a scraper
+	Snapshot MetricsSnapshotConfig `mapstructure:"snapshot"`
+}
+
+// MetricsSnapshotConfig writes the metric values as JSON every Interval, to
+// Path or, when it is empty, to the log (an Interval of 0 disables it)
+type MetricsSnapshotConfig struct {
+	Interval time.Duration `mapstructure:"interval"`
+	Path     string        `mapstructure:"path"`
 }
 
 type MetricsConfig struct {
@@ -117,11 +401,32 @@ type MetricsConfig struct {
 type I18nConfig struct {
 	DefaultLanguage string   `mapstructure:"default_language"`
 	Languages       []string `mapstructure:"languages"`
+	// AutoSwitch changes a private chat's UI language to the language the user writes in
+	AutoSwitch bool `mapstructure:"auto_switch"`
+	// AutoSwitchMessages is how many messages in a row must be in the new language before switching
+	AutoSwitchMessages int `mapstructure:"auto_switch_messages"`
 }
 
 type KnowledgeConfig struct {
-	Enabled   bool   `mapstructure:"enabled"`
-	Directory string `mapstructure:"directory"`
+	Enabled     bool     `mapstructure:"enabled"`
+	Directories []string `mapstructure:"directory"`     // 单个路径或路径列表
+	MaxDocChars int      `mapstructure:"max_doc_chars"` // 每篇文档注入的最大字符数，超出部分截断
+	// MaxContextTokens caps the estimated tokens of all injected documents (0 = unlimited)
+	MaxContextTokens int `mapstructure:"max_context_tokens"`
+	// FullDocuments injects whole documents instead of cutting them at MaxDocChars
+	FullDocuments bool `mapstructure:"full_documents"`
+	// SearchCacheTTL reuses the results of a repeated search query for this long (0 disables)
+	SearchCacheTTL time.Duration `mapstructure:"search_cache_ttl"`
+	// MaxConcurrentSearches caps searches running at once, others wait (0 is unlimited)
+	MaxConcurrentSearches int `mapstructure:"max_concurrent_searches"`
+	// RefreshInterval periodically rebuilds the knowledge base (0 disables)
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	// Position is where retrieved knowledge is injected: after_system (default),
+	// before_last_user or user_prefix
+	Position string `mapstructure:"position"`
+	// Dedup keeps injected documents in the conversation and doesn't inject
+	// them again while they are still there
+	Dedup bool `mapstructure:"dedup"`
 }
 
 // LoadConfig loads configuration from file and environment variables
@@ -143,6 +448,7 @@ func LoadConfig(configPath string) (*Config, error) {
 	viper.BindEnv("storage.redis.addr", "REDIS_HOST", "REDIS_PORT")
 	viper.BindEnv("storage.redis.password", "REDIS_PASSWORD")
 	viper.BindEnv("storage.redis.db", "REDIS_DB")
+	viper.BindEnv("models.user_key_secret", "USER_KEY_SECRET")
 	
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
@@ -217,6 +523,14 @@ func LoadConfig(configPath string) (*Config, error) {
 		}
 	}
 	
+	// Read tokens of additional bots kept in the environment
+	for i := range config.Bot.Instances {
+		instance := &config.Bot.Instances[i]
+		if instance.Token == "" && instance.TokenEnv != "" {
+			instance.Token = os.Getenv(instance.TokenEnv)
+		}
+	}
+	
 	// Validate required fields
 	if err := validateConfig(&config); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
@@ -225,12 +539,311 @@ func LoadConfig(configPath string) (*Config, error) {
 	return &config, nil
 }
 
+// validateConfig checks the whole configuration and reports every problem at once,
+// each prefixed with the path of the offending field
 func validateConfig(cfg *Config) error {
-	if cfg.Bot.Token == "" {
-		return fmt.Errorf("bot token is required")
+	var v validator
+
+	v.require(cfg.Bot.Token != "", "bot.token", "is required")
+	if cfg.Bot.Webhook.Enabled {
+		v.require(cfg.Bot.Webhook.URL != "", "bot.webhook.url", "is required when the webhook is enabled")
+		v.require(validPort(cfg.Bot.Webhook.Port), "bot.webhook.port", "must be between 1 and 65535, got %d", cfg.Bot.Webhook.Port)
 	}
-	if len(cfg.Models.Endpoints) == 0 {
-		return fmt.Errorf("at least one model endpoint is required")
+	v.require(cfg.Bot.Workers >= 0, "bot.workers", "must not be negative")
+	validateInstances(&v, cfg)
+	v.require(cfg.Bot.Reconnect.InitialBackoff >= 0, "bot.reconnect.initial_backoff", "must not be negative")
+	v.require(cfg.Bot.Reconnect.MaxBackoff >= 0, "bot.reconnect.max_backoff", "must not be negative")
+	v.require(cfg.Bot.UsernameRefresh >= 0, "bot.username_refresh", "must not be negative")
+	v.require(cfg.Bot.ErrorLogSize >= 0, "bot.error_log_size", "must not be negative")
+	v.require(cfg.Bot.ActiveWindow >= 0, "bot.active_window", "must not be negative")
+	v.require(cfg.Bot.QueueSize >= 0, "bot.queue_size", "must not be negative")
+	v.require(cfg.Bot.Broadcast.RatePerSecond >= 0, "bot.broadcast.rate_per_second", "must not be negative")
+	v.require(cfg.Bot.Broadcast.Concurrency >= 0, "bot.broadcast.concurrency", "must not be negative")
+	v.require(cfg.Bot.ModelPoll.Threshold >= 0, "bot.model_poll.threshold", "must not be negative")
+	v.require(cfg.Bot.ModelPoll.DurationSeconds >= 0, "bot.model_poll.duration_seconds", "must not be negative")
+
+	validateModels(&v, &cfg.Models)
+
+	switch cfg.Storage.Type {
+	case "memory":
+	case "redis":
+		v.require(cfg.Storage.Redis.Addr != "", "storage.redis.addr", "is required for redis storage")
+	default:
+		v.add("storage.type", "must be memory or redis, got %q", cfg.Storage.Type)
 	}
-	return nil
-}
\ No newline at end of file
+
+	v.require(cfg.Storage.Retention >= 0, "storage.retention", "must not be negative")
+	v.require(cfg.Storage.Memory.SnapshotMaxAge >= 0, "storage.memory.snapshot_max_age", "must not be negative")
+
+	v.require(cfg.Cache.TTL >= 0, "cache.ttl", "must not be negative")
+	v.require(cfg.Cache.MaxSize >= 0, "cache.max_size", "must not be negative")
+	for i, pattern := range cfg.Cache.ExcludePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			v.add(fmt.Sprintf("cache.exclude_patterns[%d]", i), "invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	if cfg.RateLimit.Enabled {
+		v.require(cfg.RateLimit.RequestsPerMinute > 0, "rate_limit.requests_per_minute", "must be positive when rate limiting is enabled")
+	}
+	v.require(cfg.RateLimit.Burst >= 0, "rate_limit.burst", "must not be negative")
+	v.require(cfg.RateLimit.LockoutThreshold >= 0, "rate_limit.lockout_threshold", "must not be negative")
+	v.require(cfg.RateLimit.LockoutWindow >= 0, "rate_limit.lockout_window", "must not be negative")
+	v.require(cfg.RateLimit.LockoutDuration >= 0, "rate_limit.lockout_duration", "must not be negative")
+
+	v.require(cfg.Context.MaxMessages > 0, "context.max_messages", "must be positive")
+	v.require(cfg.Context.SummarizeTokens >= 0, "context.summarize_tokens", "must not be negative")
+	v.require(cfg.Context.SummaryMaxTokens >= 0, "context.summary_max_tokens", "must not be negative")
+	if cfg.Context.SummarizeTokens > 0 && cfg.Context.SummaryMaxTokens > 0 {
+		v.require(cfg.Context.SummaryMaxTokens < cfg.Context.SummarizeTokens/2, "context.summary_max_tokens", "must be less than half of context.summarize_tokens")
+	}
+	v.require(cfg.Context.SystemReminderInterval >= 0, "context.system_reminder_interval", "must not be negative")
+	v.require(cfg.Context.InactivityMinutes >= 0, "context.inactivity_minutes", "must not be negative")
+	v.require(cfg.Context.GreetingCooldown >= 0, "context.greeting_cooldown", "must not be negative")
+	v.require(cfg.Context.FileResponseChars >= 0, "context.file_response_chars", "must not be negative")
+	v.require(cfg.Context.ResponseTimeBudget >= 0, "context.response_time_budget", "must not be negative")
+	v.require(cfg.Context.ResponseTimeBudget < 2*time.Minute, "context.response_time_budget", "must be shorter than the 2m request timeout")
+	v.require(cfg.Context.MinGroupMessageChars >= 0, "context.min_group_message_chars", "must not be negative")
+	v.require(cfg.Context.GroupSizeCacheTTL >= 0, "context.group_size_cache_ttl", "must not be negative")
+	tierSizes := make(map[int]bool)
+	for i, tier := range cfg.Context.GroupSizeTiers {
+		path := fmt.Sprintf("context.group_size_tiers[%d]", i)
+		v.require(tier.MinMembers > 0, path+".min_members", "must be positive")
+		v.require(!tierSizes[tier.MinMembers], path+".min_members", "duplicates tier %d", tier.MinMembers)
+		tierSizes[tier.MinMembers] = true
+		v.require(tier.SkipRate >= 0 && tier.SkipRate <= 1, path+".skip_rate", "must be between 0 and 1, got %g", tier.SkipRate)
+		v.require(tier.GreetingCooldown >= 0, path+".greeting_cooldown", "must not be negative")
+	}
+	v.require(cfg.Context.ExamplesPerPage >= 0, "context.examples_per_page", "must not be negative")
+	for lang, examples := range cfg.Context.Examples {
+		for i, example := range examples {
+			v.require(strings.TrimSpace(example) != "", fmt.Sprintf("context.examples.%s[%d]", lang, i), "must not be empty")
+		}
+	}
+	for lang, followUps := range cfg.Context.FollowUps {
+		for i, followUp := range followUps {
+			path := fmt.Sprintf("context.follow_ups.%s[%d]", lang, i)
+			v.require(followUp.Label != "", path+".label", "is required")
+			v.require(followUp.Prompt != "", path+".prompt", "is required")
+		}
+	}
+	profiles := make(map[string]bool)
+	for i, profile := range cfg.Context.Profiles {
+		path := fmt.Sprintf("context.profiles[%d].name", i)
+		v.require(profile.Name != "", path, "is required")
+		v.require(!profiles[profile.Name], path, "duplicates profile %q", profile.Name)
+		profiles[profile.Name] = true
+	}
+	presets := make(map[string]bool)
+	for i, preset := range cfg.Context.PromptLibrary {
+		path := fmt.Sprintf("context.prompt_library[%d]", i)
+		v.require(preset.ID != "" && len(preset.ID) <= 32 && !strings.Contains(preset.ID, ":"), path+".id", "must be 1-32 characters without ':'")
+		v.require(!presets[preset.ID], path+".id", "duplicates preset %q", preset.ID)
+		presets[preset.ID] = true
+		v.require(preset.Name != "", path+".name", "is required")
+		v.require(preset.Prompt != "", path+".prompt", "is required")
+	}
+
+	switch strings.ToLower(cfg.Logging.Level) {
+	case "", "panic", "fatal", "error", "warn", "warning", "info", "debug", "trace":
+	default:
+		v.add("logging.level", "unknown level %q", cfg.Logging.Level)
+	}
+
+	if cfg.Monitoring.Metrics.Enabled {
+		v.require(validPort(cfg.Monitoring.Metrics.Port), "monitoring.metrics.port", "must be between 1 and 65535, got %d", cfg.Monitoring.Metrics.Port)
+	}
+	v.require(cfg.Monitoring.Snapshot.Interval >= 0, "monitoring.snapshot.interval", "must not be negative")
+
+	if len(cfg.I18n.Languages) == 0 {
+		v.add("i18n.languages", "must list at least one language")
+	} else {
+		v.require(containsString(cfg.I18n.Languages, cfg.I18n.DefaultLanguage), "i18n.default_language",
+			"%q is not one of i18n.languages %v", cfg.I18n.DefaultLanguage, cfg.I18n.Languages)
+	}
+	v.require(cfg.I18n.AutoSwitchMessages >= 0, "i18n.auto_switch_messages", "must not be negative")
+
+	v.require(cfg.Knowledge.MaxDocChars >= 0, "knowledge.max_doc_chars", "must not be negative")
+	v.require(cfg.Knowledge.MaxContextTokens >= 0, "knowledge.max_context_tokens", "must not be negative")
+	v.require(cfg.Knowledge.RefreshInterval >= 0, "knowledge.refresh_interval", "must not be negative")
+	v.require(cfg.Knowledge.SearchCacheTTL >= 0, "knowledge.search_cache_ttl", "must not be negative")
+	v.require(cfg.Knowledge.MaxConcurrentSearches >= 0, "knowledge.max_concurrent_searches", "must not be negative")
+	switch cfg.Knowledge.Position {
+	case "", "after_system", "before_last_user", "user_prefix":
+	default:
+		v.add("knowledge.position", "must be after_system, before_last_user or user_prefix, got %q", cfg.Knowledge.Position)
+	}
+
+	switch cfg.Markdown.Quotes {
+	case "", "native", "prefix":
+	default:
+		v.add("markdown.quotes", "must be native or prefix, got %q", cfg.Markdown.Quotes)
+	}
+	switch cfg.Markdown.Images {
+	case "", "link", "drop":
+	default:
+		v.add("markdown.images", "must be link or drop, got %q", cfg.Markdown.Images)
+	}
+
+	for i, rule := range cfg.PostProcessors {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			v.add(fmt.Sprintf("post_processors[%d].pattern", i), "invalid pattern in %q: %v", rule.Name, err)
+		}
+	}
+
+	for name, tool := range cfg.Tools {
+		if _, err := template.New(name).Parse(tool.Summary); err != nil {
+			v.add(fmt.Sprintf("tools.%s.summary", name), "invalid template: %v", err)
+		}
+	}
+
+	return v.err()
+}
+
+// validateInstances checks that additional bots have a token, distinct names
+// and, with Redis storage, a database of their own
+func validateInstances(v *validator, cfg *Config) {
+	names := make(map[string]bool, len(cfg.Bot.Instances))
+	databases := map[int]bool{cfg.Storage.Redis.DB: true}
+	for i, instance := range cfg.Bot.Instances {
+		path := fmt.Sprintf("bot.instances[%d]", i)
+		v.require(instance.Name != "", path+".name", "is required")
+		if instance.Name != "" {
+			v.require(!names[instance.Name], path+".name", "duplicates %q", instance.Name)
+			names[instance.Name] = true
+		}
+		v.require(instance.Token != "" && instance.Token != cfg.Bot.Token, path+".token", "is required and must differ from bot.token")
+		if cfg.Storage.Type == "redis" {
+			v.require(!databases[instance.RedisDB], path+".redis_db", "must differ from the other bots' databases, got %d", instance.RedisDB)
+			databases[instance.RedisDB] = true
+		}
+	}
+}
+
+// ForInstance returns the configuration of an additional bot: a copy of c
+// with the instance's overrides applied
+func (c *Config) ForInstance(instance BotInstanceConfig) *Config {
+	cfg := *c
+	cfg.Bot.Token = instance.Token
+	cfg.Bot.Instances = nil
+	cfg.Storage.Redis.DB = instance.RedisDB
+	if cfg.Storage.Memory.SnapshotPath != "" {
+		cfg.Storage.Memory.SnapshotPath += "." + instance.Name
+	}
+	if len(instance.AdminIDs) > 0 {
+		cfg.Bot.AdminIDs = instance.AdminIDs
+	}
+	if instance.DefaultSystemPrompt != "" {
+		cfg.Context.DefaultSystemPrompt = instance.DefaultSystemPrompt
+	}
+	if instance.BotPersonality != "" {
+		cfg.Context.BotPersonality = instance.BotPersonality
+	}
+	return &cfg
+}
+
+// validateModels checks the endpoints and that the default model exists
+func validateModels(v *validator, models *ModelsConfig) {
+	if len(models.Endpoints) == 0 {
+		v.add("models.endpoints", "at least one model endpoint is required")
+	}
+	v.require(models.MaxDynamicEndpoints >= 0, "models.max_dynamic_endpoints", "must not be negative")
+	v.require(models.MaxModelsPerEndpoint >= 0, "models.max_models_per_endpoint", "must not be negative")
+	v.require(models.RateLimitWait >= 0, "models.rate_limit_wait", "must not be negative")
+	if models.UserKeySecret != "" {
+		v.require(len(models.UserKeySecret) >= MinUserKeySecretLength, "models.user_key_secret", "must be at least %d characters", MinUserKeySecretLength)
+	}
+	switch models.EndpointVisibility {
+	case "", EndpointVisibilityGlobal, EndpointVisibilityOwner:
+	default:
+		v.add("models.endpoint_visibility", "must be global or owner, got %q", models.EndpointVisibility)
+	}
+	switch models.GroupBy {
+	case "", ModelGroupByEndpoint, ModelGroupByCategory:
+	default:
+		v.add("models.group_by", "must be endpoint or category, got %q", models.GroupBy)
+	}
+
+	endpoints := make(map[string]bool)
+	modelIDs := make(map[string]bool)
+	for i, endpoint := range models.Endpoints {
+		path := fmt.Sprintf("models.endpoints[%d]", i)
+		v.require(endpoint.Name != "", path+".name", "is required")
+		v.require(!endpoints[endpoint.Name], path+".name", "duplicates endpoint %q", endpoint.Name)
+		endpoints[endpoint.Name] = true
+		v.require(endpoint.BaseURL != "", path+".base_url", "is required")
+		v.require(endpoint.RequestsPerMinute >= 0, path+".requests_per_minute", "must not be negative")
+		v.require(endpoint.Burst >= 0, path+".burst", "must not be negative")
+		if endpoint.Organization != "" {
+			v.require(validOpenAIID(endpoint.Organization, "org-"), path+".organization", "must be an OpenAI organization ID like org-..., got %q", endpoint.Organization)
+		}
+		if endpoint.Project != "" {
+			v.require(validOpenAIID(endpoint.Project, "proj_"), path+".project", "must be an OpenAI project ID like proj_..., got %q", endpoint.Project)
+		}
+
+		for j, model := range endpoint.Models {
+			modelPath := fmt.Sprintf("%s.models[%d]", path, j)
+			v.require(model.ID != "", modelPath+".id", "is required")
+			v.require(model.MaxTokens >= 0, modelPath+".max_tokens", "must not be negative")
+			v.require(model.InputPricePer1K >= 0 && model.OutputPricePer1K >= 0, modelPath, "prices must not be negative")
+			modelIDs[model.ID] = true
+		}
+	}
+
+	if models.Default != "" && len(models.Endpoints) > 0 {
+		v.require(modelIDs[models.Default], "models.default", "%q is not a model of any endpoint", models.Default)
+	}
+}
+
+// validOpenAIID reports whether id is an OpenAI organization or project ID
+// with the given prefix. They end up in request headers, so nothing but
+// letters, digits, '-' and '_' is accepted.
+func validOpenAIID(id string, prefix string) bool {
+	if !strings.HasPrefix(id, prefix) || len(id) == len(prefix) {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// validator collects configuration problems
+type validator struct {
+	problems []string
+}
+
+// add records a problem with the field at path
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+// require records a problem unless ok holds
+func (v *validator) require(ok bool, path, format string, args ...interface{}) {
+	if !ok {
+		v.add(path, format, args...)
+	}
+}
+
+// err returns all recorded problems as a single error, or nil
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d problem(s):\n  - %s", len(v.problems), strings.Join(v.problems, "\n  - "))
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const instancesYAML = `
+bot:
+  token: main-token
+  admin_ids: [1]
+  instances:
+    - name: staging
+      token: staging-token
+      admin_ids: [2, 3]
+      redis_db: 1
+    - name: brand
+      token_env: BRAND_BOT_TOKEN
+      default_system_prompt: You are Brand's assistant.
+      bot_personality: cheerful
+      redis_db: 2
+models:
+  default: gpt-4o
+  endpoints:
+    - name: openai
+      base_url: https://api.openai.com/v1
+      models:
+        - id: gpt-4o
+storage:
+  type: memory
+  memory:
+    snapshot_path: data/contexts.json
+context:
+  max_messages: 20
+  default_system_prompt: You are a helpful assistant.
+  bot_personality: calm
+i18n:
+  default_language: zh
+  languages: [zh]
+`
+
+func TestLoadConfigInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(instancesYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BRAND_BOT_TOKEN", "brand-token")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.Bot.Instances) != 2 {
+		t.Fatalf("got %d instances, want 2", len(cfg.Bot.Instances))
+	}
+	tokens := []string{cfg.Bot.Token, cfg.Bot.Instances[0].Token, cfg.Bot.Instances[1].Token}
+	if want := []string{"main-token", "staging-token", "brand-token"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("tokens %v, want %v", tokens, want)
+	}
+}
+
+func TestLoadConfigInstanceTokenEnvUnset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(instancesYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BRAND_BOT_TOKEN", "")
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("LoadConfig passed without the brand bot's token")
+	}
+	if want := "bot.instances[1].token: is required"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q, want %q", err, want)
+	}
+}
+
+func TestForInstance(t *testing.T) {
+	base := validConfig()
+	base.Bot.AdminIDs = []int64{1}
+	base.Bot.Instances = []BotInstanceConfig{{Name: "staging", Token: "staging-token"}}
+	base.Storage.Redis.DB = 0
+	base.Storage.Memory.SnapshotPath = "data/contexts.json"
+	base.Context.DefaultSystemPrompt = "You are a helpful assistant."
+	base.Context.BotPersonality = "calm"
+
+	tests := []struct {
+		name     string
+		instance BotInstanceConfig
+		want     func(cfg *Config)
+	}{
+		{
+			name:     "inherits unset fields",
+			instance: BotInstanceConfig{Name: "staging", Token: "staging-token", RedisDB: 1},
+			want: func(cfg *Config) {
+				cfg.Bot.Token = "staging-token"
+				cfg.Storage.Redis.DB = 1
+				cfg.Storage.Memory.SnapshotPath = "data/contexts.json.staging"
+			},
+		},
+		{
+			name: "overrides",
+			instance: BotInstanceConfig{
+				Name: "brand", Token: "brand-token", AdminIDs: []int64{2, 3}, RedisDB: 2,
+				DefaultSystemPrompt: "You are Brand's assistant.", BotPersonality: "cheerful",
+			},
+			want: func(cfg *Config) {
+				cfg.Bot.Token = "brand-token"
+				cfg.Bot.AdminIDs = []int64{2, 3}
+				cfg.Storage.Redis.DB = 2
+				cfg.Storage.Memory.SnapshotPath = "data/contexts.json.brand"
+				cfg.Context.DefaultSystemPrompt = "You are Brand's assistant."
+				cfg.Context.BotPersonality = "cheerful"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := base.ForInstance(tt.instance)
+
+			want := *base
+			want.Bot.Instances = nil
+			tt.want(&want)
+			if !reflect.DeepEqual(got, &want) {
+				t.Errorf("ForInstance =\n%+v\nwant\n%+v", got, &want)
+			}
+		})
+	}
+
+	// The main bot's configuration is left alone
+	if base.Bot.Token != "main-token" || base.Storage.Memory.SnapshotPath != "data/contexts.json" ||
+		base.Context.DefaultSystemPrompt != "You are a helpful assistant." || len(base.Bot.Instances) != 1 {
+		t.Errorf("ForInstance modified the main configuration: %+v", base)
+	}
+}
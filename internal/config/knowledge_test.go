@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const knowledgeBaseYAML = `
+bot:
+  token: main-token
+models:
+  default: gpt-4o
+  endpoints:
+    - name: openai
+      base_url: https://api.openai.com/v1
+      models:
+        - id: gpt-4o
+storage:
+  type: memory
+context:
+  max_messages: 20
+i18n:
+  default_language: zh
+  languages: [zh]
+`
+
+func TestLoadConfigKnowledgeDirectories(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{name: "single directory", yaml: "knowledge:\n  directory: data/knowledge\n", want: []string{"data/knowledge"}},
+		{name: "list", yaml: "knowledge:\n  directory: [shared/docs, data/knowledge]\n", want: []string{"shared/docs", "data/knowledge"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(knowledgeBaseYAML+tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if !reflect.DeepEqual(cfg.Knowledge.Directories, tt.want) {
+				t.Errorf("knowledge directories = %q, want %q", cfg.Knowledge.Directories, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns the smallest configuration that passes validation
+func validConfig() *Config {
+	return &Config{
+		Bot: BotConfig{Token: "main-token"},
+		Models: ModelsConfig{
+			Default: "gpt-4o",
+			Endpoints: []ModelEndpoint{{
+				Name:    "openai",
+				BaseURL: "https://api.openai.com/v1",
+				Models:  []ModelInfo{{ID: "gpt-4o"}},
+			}},
+		},
+		Storage: StorageConfig{Type: "memory"},
+		Context: ContextConfig{MaxMessages: 20},
+		I18n:    I18nConfig{DefaultLanguage: "zh", Languages: []string{"zh", "en"}},
+	}
+}
+
+func TestValidateConfigValid(t *testing.T) {
+	if err := validateConfig(validConfig()); err != nil {
+		t.Fatalf("validateConfig: %v", err)
+	}
+}
+
+func TestValidateConfigProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   string // the single problem reported, path first
+	}{
+		{
+			name:   "missing token",
+			modify: func(cfg *Config) { cfg.Bot.Token = "" },
+			want:   "bot.token: is required",
+		},
+		{
+			name: "webhook without url",
+			modify: func(cfg *Config) {
+				cfg.Bot.Webhook = WebhookConfig{Enabled: true, Port: 8443}
+			},
+			want: "bot.webhook.url: is required when the webhook is enabled",
+		},
+		{
+			name: "webhook port out of range",
+			modify: func(cfg *Config) {
+				cfg.Bot.Webhook = WebhookConfig{Enabled: true, URL: "https://example.com", Port: 70000}
+			},
+			want: "bot.webhook.port: must be between 1 and 65535, got 70000",
+		},
+		{
+			name:   "negative workers",
+			modify: func(cfg *Config) { cfg.Bot.Workers = -1 },
+			want:   "bot.workers: must not be negative",
+		},
+		{
+			name:   "negative reconnect backoff",
+			modify: func(cfg *Config) { cfg.Bot.Reconnect.MaxBackoff = -time.Second },
+			want:   "bot.reconnect.max_backoff: must not be negative",
+		},
+		{
+			name: "instance without name",
+			modify: func(cfg *Config) {
+				cfg.Bot.Instances = []BotInstanceConfig{{Token: "second-token"}}
+			},
+			want: "bot.instances[0].name: is required",
+		},
+		{
+			name: "instance reusing the main token",
+			modify: func(cfg *Config) {
+				cfg.Bot.Instances = []BotInstanceConfig{{Name: "second", Token: "main-token"}}
+			},
+			want: "bot.instances[0].token: is required and must differ from bot.token",
+		},
+		{
+			name: "duplicate instance names",
+			modify: func(cfg *Config) {
+				cfg.Bot.Instances = []BotInstanceConfig{{Name: "second", Token: "a"}, {Name: "second", Token: "b"}}
+			},
+			want: `bot.instances[1].name: duplicates "second"`,
+		},
+		{
+			name: "instance sharing the redis database",
+			modify: func(cfg *Config) {
+				cfg.Storage = StorageConfig{Type: "redis", Redis: RedisConfig{Addr: "localhost:6379", DB: 2}}
+				cfg.Bot.Instances = []BotInstanceConfig{{Name: "second", Token: "a", RedisDB: 2}}
+			},
+			want: "bot.instances[0].redis_db: must differ from the other bots' databases, got 2",
+		},
+		{
+			name:   "no endpoints",
+			modify: func(cfg *Config) { cfg.Models.Endpoints = nil },
+			want:   "models.endpoints: at least one model endpoint is required",
+		},
+		{
+			name:   "endpoint without base url",
+			modify: func(cfg *Config) { cfg.Models.Endpoints[0].BaseURL = "" },
+			want:   "models.endpoints[0].base_url: is required",
+		},
+		{
+			name: "duplicate endpoint names",
+			modify: func(cfg *Config) {
+				cfg.Models.Endpoints = append(cfg.Models.Endpoints, ModelEndpoint{Name: "openai", BaseURL: "https://example.com"})
+			},
+			want: `models.endpoints[1].name: duplicates endpoint "openai"`,
+		},
+		{
+			name:   "bad organization",
+			modify: func(cfg *Config) { cfg.Models.Endpoints[0].Organization = "org-a\r\nX-Injected: 1" },
+			want:   `models.endpoints[0].organization: must be an OpenAI organization ID like org-..., got "org-a\r\nX-Injected: 1"`,
+		},
+		{
+			name:   "bad project",
+			modify: func(cfg *Config) { cfg.Models.Endpoints[0].Project = "project" },
+			want:   `models.endpoints[0].project: must be an OpenAI project ID like proj_..., got "project"`,
+		},
+		{
+			name: "model without id",
+			modify: func(cfg *Config) {
+				cfg.Models.Endpoints[0].Models = append(cfg.Models.Endpoints[0].Models, ModelInfo{Name: "unnamed"})
+			},
+			want: "models.endpoints[0].models[1].id: is required",
+		},
+		{
+			name:   "negative price",
+			modify: func(cfg *Config) { cfg.Models.Endpoints[0].Models[0].OutputPricePer1K = -0.01 },
+			want:   "models.endpoints[0].models[0]: prices must not be negative",
+		},
+		{
+			name:   "unknown default model",
+			modify: func(cfg *Config) { cfg.Models.Default = "gpt-5" },
+			want:   `models.default: "gpt-5" is not a model of any endpoint`,
+		},
+		{
+			name:   "short user key secret",
+			modify: func(cfg *Config) { cfg.Models.UserKeySecret = "short" },
+			want:   "models.user_key_secret: must be at least 16 characters",
+		},
+		{
+			name:   "unknown endpoint visibility",
+			modify: func(cfg *Config) { cfg.Models.EndpointVisibility = "public" },
+			want:   `models.endpoint_visibility: must be global or owner, got "public"`,
+		},
+		{
+			name:   "unknown storage type",
+			modify: func(cfg *Config) { cfg.Storage.Type = "sqlite" },
+			want:   `storage.type: must be memory or redis, got "sqlite"`,
+		},
+		{
+			name:   "redis without address",
+			modify: func(cfg *Config) { cfg.Storage.Type = "redis" },
+			want:   "storage.redis.addr: is required for redis storage",
+		},
+		{
+			name:   "invalid cache exclude pattern",
+			modify: func(cfg *Config) { cfg.Cache.ExcludePatterns = []string{"ok", "(unclosed"} },
+			want:   `cache.exclude_patterns[1]: invalid pattern "(unclosed"`,
+		},
+		{
+			name:   "rate limit without a rate",
+			modify: func(cfg *Config) { cfg.RateLimit.Enabled = true },
+			want:   "rate_limit.requests_per_minute: must be positive when rate limiting is enabled",
+		},
+		{
+			name:   "no context messages",
+			modify: func(cfg *Config) { cfg.Context.MaxMessages = 0 },
+			want:   "context.max_messages: must be positive",
+		},
+		{
+			name: "summary too long for the threshold",
+			modify: func(cfg *Config) {
+				cfg.Context.SummarizeTokens = 1000
+				cfg.Context.SummaryMaxTokens = 600
+			},
+			want: "context.summary_max_tokens: must be less than half of context.summarize_tokens",
+		},
+		{
+			name:   "time budget past the request timeout",
+			modify: func(cfg *Config) { cfg.Context.ResponseTimeBudget = 3 * time.Minute },
+			want:   "context.response_time_budget: must be shorter than the 2m request timeout",
+		},
+		{
+			name:   "skip rate above one",
+			modify: func(cfg *Config) { cfg.Context.GroupSizeTiers = []GroupSizeTierConfig{{MinMembers: 50, SkipRate: 1.5}} },
+			want:   "context.group_size_tiers[0].skip_rate: must be between 0 and 1, got 1.5",
+		},
+		{
+			name:   "empty example",
+			modify: func(cfg *Config) { cfg.Context.Examples = map[string][]string{"en": {"What is Go?", " "}} },
+			want:   "context.examples.en[1]: must not be empty",
+		},
+		{
+			name:   "follow-up without prompt",
+			modify: func(cfg *Config) { cfg.Context.FollowUps = map[string][]FollowUpConfig{"zh": {{Label: "继续"}}} },
+			want:   "context.follow_ups.zh[0].prompt: is required",
+		},
+		{
+			name:   "duplicate profiles",
+			modify: func(cfg *Config) { cfg.Context.Profiles = []ProfileConfig{{Name: "coder"}, {Name: "coder"}} },
+			want:   `context.profiles[1].name: duplicates profile "coder"`,
+		},
+		{
+			name: "preset id with a colon",
+			modify: func(cfg *Config) {
+				cfg.Context.PromptLibrary = []PromptPresetConfig{{ID: "a:b", Name: "Preset", Prompt: "Be brief"}}
+			},
+			want: "context.prompt_library[0].id: must be 1-32 characters without ':'",
+		},
+		{
+			name:   "unknown log level",
+			modify: func(cfg *Config) { cfg.Logging.Level = "verbose" },
+			want:   `logging.level: unknown level "verbose"`,
+		},
+		{
+			name: "metrics port out of range",
+			modify: func(cfg *Config) {
+				cfg.Monitoring.Metrics.Enabled = true
+			},
+			want: "monitoring.metrics.port: must be between 1 and 65535, got 0",
+		},
+		{
+			name:   "no languages",
+			modify: func(cfg *Config) { cfg.I18n.Languages = nil },
+			want:   "i18n.languages: must list at least one language",
+		},
+		{
+			name:   "default language not offered",
+			modify: func(cfg *Config) { cfg.I18n.DefaultLanguage = "fr" },
+			want:   `i18n.default_language: "fr" is not one of i18n.languages [zh en]`,
+		},
+		{
+			name:   "unknown knowledge position",
+			modify: func(cfg *Config) { cfg.Knowledge.Position = "end" },
+			want:   `knowledge.position: must be after_system, before_last_user or user_prefix, got "end"`,
+		},
+		{
+			name:   "unknown quote style",
+			modify: func(cfg *Config) { cfg.Markdown.Quotes = "italic" },
+			want:   `markdown.quotes: must be native or prefix, got "italic"`,
+		},
+		{
+			name: "invalid post-processor pattern",
+			modify: func(cfg *Config) {
+				cfg.PostProcessors = []PostProcessorConfig{{Name: "strip", Pattern: "[a-"}}
+			},
+			want: `post_processors[0].pattern: invalid pattern in "strip"`,
+		},
+		{
+			name: "duplicate prompt preset",
+			modify: func(cfg *Config) {
+				cfg.Context.PromptLibrary = []PromptPresetConfig{
+					{ID: "tutor", Name: "家教", Prompt: "p"},
+					{ID: "tutor", Name: "家教", Prompt: "p"},
+				}
+			},
+			want: `context.prompt_library[1].id: duplicates preset "tutor"`,
+		},
+		{
+			name: "invalid tool summary",
+			modify: func(cfg *Config) {
+				cfg.Tools = map[string]ToolConfig{"weather": {Summary: "查询了天气：{{.condition"}}
+			},
+			want: `tools.weather.summary: invalid template`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := validateConfig(cfg)
+			if err == nil {
+				t.Fatalf("validateConfig passed, want %q", tt.want)
+			}
+			if !strings.HasPrefix(err.Error(), "1 problem(s):") {
+				t.Errorf("error %q, want exactly one problem", err)
+			}
+			if !strings.Contains(err.Error(), "\n  - "+tt.want) {
+				t.Errorf("error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigReportsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Bot.Token = ""
+	cfg.Storage.Type = "sqlite"
+	cfg.Logging.Level = "verbose"
+
+	err := validateConfig(cfg)
+	if err == nil {
+		t.Fatal("validateConfig passed, want 3 problems")
+	}
+	want := "3 problem(s):\n" +
+		"  - bot.token: is required\n" +
+		`  - storage.type: must be memory or redis, got "sqlite"` + "\n" +
+		`  - logging.level: unknown level "verbose"`
+	if err.Error() != want {
+		t.Errorf("error:\n%s\nwant:\n%s", err, want)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestIsChatAllowed(t *testing.T) {
+	private := &tgbotapi.Chat{ID: 7, Type: "private"}
+	allowed := &tgbotapi.Chat{ID: -100, Type: "supergroup"}
+	other := &tgbotapi.Chat{ID: -200, Type: "group"}
+	tests := []struct {
+		name   string
+		access config.AccessConfig
+		chat   *tgbotapi.Chat
+		want   bool
+	}{
+		{name: "no restriction", chat: other, want: true},
+		{name: "allowed group", access: config.AccessConfig{AllowedChats: []int64{-100}}, chat: allowed, want: true},
+		{name: "group not on the list", access: config.AccessConfig{AllowedChats: []int64{-100}}, chat: other, want: false},
+		{name: "private chat with a list", access: config.AccessConfig{AllowedChats: []int64{-100}}, chat: private, want: true},
+		{name: "private only", access: config.AccessConfig{PrivateOnly: true, AllowedChats: []int64{-100}}, chat: allowed, want: false},
+		{name: "private chat in private only", access: config.AccessConfig{PrivateOnly: true}, chat: private, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Bot.Access = tt.access
+			h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+			membership := NewMembershipHandler(h.bot, cfg, h.storage, h.localizer, h.logger)
+			if got := membership.IsChatAllowed(tt.chat); got != tt.want {
+				t.Errorf("IsChatAllowed(%d) = %v, want %v", tt.chat.ID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleDisallowedChat(t *testing.T) {
+	const chatID = -200
+	tests := []struct {
+		name      string
+		leave     bool
+		wantLeave bool
+	}{
+		{name: "ignored"},
+		{name: "left with a notice", leave: true, wantLeave: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Bot.Access = config.AccessConfig{AllowedChats: []int64{-100}, LeaveUnallowed: tt.leave}
+			h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+			membership := NewMembershipHandler(h.bot, cfg, h.storage, h.localizer, h.logger)
+			
+			membership.HandleDisallowedChat(context.Background(), &tgbotapi.Chat{ID: chatID, Type: "group"})
+			
+			sent := telegram.texts("sendMessage", chatID)
+			left := len(telegram.requests("leaveChat")) == 1
+			if left != tt.wantLeave {
+				t.Errorf("left the chat = %v, want %v", left, tt.wantLeave)
+			}
+			if gotNotice := len(sent) == 1 && strings.Contains(sent[0], "未被授权"); gotNotice != tt.wantLeave {
+				t.Errorf("sent %q, want a notice %v", sent, tt.wantLeave)
+			}
+		})
+	}
+}
+
+func TestJoiningDisallowedChat(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig()
+	cfg.Bot.Membership.AnnounceOnJoin = true
+	cfg.Bot.Access = config.AccessConfig{AllowedChats: []int64{-100}, LeaveUnallowed: true}
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	membership := NewMembershipHandler(h.bot, cfg, h.storage, h.localizer, h.logger)
+	
+	// Added to a chat outside the list, the bot leaves without an intro
+	if err := membership.HandleMyChatMember(ctx, membershipChange(-200, "left", "member")); err != nil {
+		t.Fatalf("joining: %v", err)
+	}
+	if sent := telegram.texts("sendMessage", -200); len(sent) != 1 || strings.Contains(sent[0], "我是 AI 助手") {
+		t.Errorf("sent %q, want only the notice", sent)
+	}
+	if leaves := telegram.requests("leaveChat"); len(leaves) != 1 || leaves[0].Get("chat_id") != "-200" {
+		t.Errorf("leaveChat requests %v, want one for chat -200", leaves)
+	}
+	
+	// Added to an allowed chat, it stays and introduces itself
+	if err := membership.HandleMyChatMember(ctx, membershipChange(-100, "left", "member")); err != nil {
+		t.Fatalf("joining: %v", err)
+	}
+	if sent := telegram.texts("sendMessage", -100); len(sent) != 1 || !strings.Contains(sent[0], "我是 AI 助手") {
+		t.Errorf("sent %q, want the intro", sent)
+	}
+	if leaves := telegram.requests("leaveChat"); len(leaves) != 1 {
+		t.Errorf("got %d leaveChat requests, want only the first", len(leaves))
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// activeChatsPerPage is how many chats /active lists at once
+const activeChatsPerPage = 10
+
+// activeWindow returns how recently a chat must have been used to be active
+func activeWindow(cfg *config.Config) time.Duration {
+	if cfg.Bot.ActiveWindow > 0 {
+		return cfg.Bot.ActiveWindow
+	}
+	return config.DefaultActiveWindow
+}
+
+// pageBounds clamps page to the pages of total entries and returns it with
+// the slice bounds of its entries and the number of pages (at least 1)
+func pageBounds(total int, page int, perPage int) (int, int, int, int) {
+	pages := max((total+perPage-1)/perPage, 1)
+	page = min(max(page, 0), pages-1)
+	start := page * perPage
+	end := min(start+perPage, total)
+	return page, start, end, pages
+}
+
+// handleActive handles /active command, listing recently active chats (admin only)
+func (h *CommandHandler) handleActive(ctx context.Context, chatID int64, userID int64) error {
+	if !h.isAdmin(userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 该命令仅限管理员使用"))
+		return err
+	}
+	
+	text, keyboard, err := h.describeActive(ctx, 0)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list active chats")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 获取活跃聊天失败，请稍后重试"))
+		return err
+	}
+	
+	msg := tgbotapi.NewMessage(chatID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// handleActiveCallback handles "active:<page>", showing another page of /active
+func (h *CommandHandler) handleActiveCallback(ctx context.Context, chatID int64, messageID int, userID int64, pageStr string, callbackID string) error {
+	if !h.isAdmin(userID) {
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "❌ 仅限管理员"))
+		return nil
+	}
+	
+	page, _ := strconv.Atoi(pageStr)
+	text, keyboard, err := h.describeActive(ctx, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list active chats")
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "❌ 获取失败"))
+		return nil
+	}
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ReplyMarkup = keyboard
+	_, err = h.bot.Send(edit)
+	return err
+}
+
+// describeActive renders one page of the active chats, with page buttons when
+// there is more than one page
+func (h *CommandHandler) describeActive(ctx context.Context, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	window := activeWindow(h.config)
+	active, err := h.storage.ActiveContexts(ctx, window)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(active) == 0 {
+		return fmt.Sprintf("💤 最近 %s 内没有活跃的聊天", window), nil, nil
+	}
+	
+	page, start, end, pages := pageBounds(len(active), page, activeChatsPerPage)
+	
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📊 最近 %s 内活跃的聊天：%d 个（第 %d/%d 页）\n", window, len(active), page+1, pages))
+	for _, chatCtx := range active[start:end] {
+		name := strconv.FormatInt(chatCtx.ChatID, 10)
+		if title := h.chatTitle(chatCtx.ChatID); title != "" {
+			name = fmt.Sprintf("%s (%d)", title, chatCtx.ChatID)
+		}
+		model := chatCtx.Settings.AIParams.Model
+		if model == "" {
+			model = h.config.Models.Default
+		}
+		b.WriteString(fmt.Sprintf("\n%s\n   最后活跃：%s  消息：%d  模型：%s\n",
+			name, chatCtx.LastActivity.Format("01-02 15:04"), max(len(chatCtx.Messages)-1, 0), model))
+	}
+	
+	if pages == 1 {
+		return b.String(), nil, nil
+	}
+	
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("⬅️ 上一页", fmt.Sprintf("active:%d", page-1)))
+	}
+	if page < pages-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("下一页 ➡️", fmt.Sprintf("active:%d", page+1)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return b.String(), &keyboard, nil
+}
+
+// chatTitle returns the title of a group or the name of a user, empty when
+// the chat can't be looked up
+func (h *CommandHandler) chatTitle(chatID int64) string {
+	chat, err := h.bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+	if err != nil {
+		return ""
+	}
+	if chat.Title != "" {
+		return chat.Title
+	}
+	return strings.TrimSpace(chat.FirstName + " " + chat.LastName)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		name                                    string
+		total, page                             int
+		wantPage, wantStart, wantEnd, wantPages int
+	}{
+		{name: "empty", total: 0, page: 0, wantPage: 0, wantStart: 0, wantEnd: 0, wantPages: 1},
+		{name: "single page", total: 7, page: 0, wantPage: 0, wantStart: 0, wantEnd: 7, wantPages: 1},
+		{name: "full first page", total: 23, page: 0, wantPage: 0, wantStart: 0, wantEnd: 10, wantPages: 3},
+		{name: "partial last page", total: 23, page: 2, wantPage: 2, wantStart: 20, wantEnd: 23, wantPages: 3},
+		{name: "exact pages", total: 20, page: 1, wantPage: 1, wantStart: 10, wantEnd: 20, wantPages: 2},
+		{name: "past the end", total: 23, page: 9, wantPage: 2, wantStart: 20, wantEnd: 23, wantPages: 3},
+		{name: "negative", total: 23, page: -1, wantPage: 0, wantStart: 0, wantEnd: 10, wantPages: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, start, end, pages := pageBounds(tt.total, tt.page, 10)
+			if page != tt.wantPage || start != tt.wantStart || end != tt.wantEnd || pages != tt.wantPages {
+				t.Errorf("pageBounds = %d, %d, %d, %d, want %d, %d, %d, %d",
+					page, start, end, pages, tt.wantPage, tt.wantStart, tt.wantEnd, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestDescribeActive(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig()
+	cfg.Bot.ActiveWindow = time.Hour
+	h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+	commands := newTestCommandHandler(h)
+	
+	// 23 chats active a minute apart, and one idle for longer than the window
+	for i := 1; i <= 24; i++ {
+		idleFor := time.Duration(i) * time.Minute
+		if i == 24 {
+			idleFor = 2 * time.Hour
+		}
+		chatID := int64(-100 - i)
+		saveChatSettings(t, h, chatID, func(s *models.ChatSettings) {})
+		chatCtx := &models.ChatContext{ChatID: chatID, Messages: []models.Message{{Role: "system"}}, LastActivity: time.Now().Add(-idleFor)}
+		if err := h.storage.SaveContext(ctx, chatCtx); err != nil {
+			t.Fatalf("SaveContext: %v", err)
+		}
+	}
+	
+	tests := []struct {
+		name       string
+		page       int
+		wantHeader string
+		wantChats  []int64 // first and last chat listed
+		wantCount  int
+		buttons    []string
+	}{
+		{name: "first page", page: 0, wantHeader: "23 个（第 1/3 页）", wantChats: []int64{-101, -110}, wantCount: 10, buttons: []string{"active:1"}},
+		{name: "middle page", page: 1, wantHeader: "第 2/3 页", wantChats: []int64{-111, -120}, wantCount: 10, buttons: []string{"active:0", "active:2"}},
+		{name: "last page", page: 2, wantHeader: "第 3/3 页", wantChats: []int64{-121, -123}, wantCount: 3, buttons: []string{"active:1"}},
+		{name: "past the end", page: 7, wantHeader: "第 3/3 页", wantChats: []int64{-121, -123}, wantCount: 3, buttons: []string{"active:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, keyboard, err := commands.describeActive(ctx, tt.page)
+			if err != nil {
+				t.Fatalf("describeActive: %v", err)
+			}
+			if !strings.Contains(text, tt.wantHeader) {
+				t.Errorf("header of %q, want %q", text, tt.wantHeader)
+			}
+			if count := strings.Count(text, "最后活跃"); count != tt.wantCount {
+				t.Errorf("listed %d chats, want %d", count, tt.wantCount)
+			}
+			first, last := fmt.Sprint(tt.wantChats[0]), fmt.Sprint(tt.wantChats[1])
+			if !strings.Contains(text, "\n"+first+"\n") || !strings.Contains(text, "\n"+last+"\n") ||
+				strings.Index(text, first) > strings.Index(text, last) {
+				t.Errorf("page %q, want chats %s to %s", text, first, last)
+			}
+			if strings.Contains(text, "-124") {
+				t.Error("idle chat listed")
+			}
+			
+			var buttons []string
+			if keyboard != nil {
+				for _, button := range keyboard.InlineKeyboard[0] {
+					buttons = append(buttons, *button.CallbackData)
+				}
+			}
+			if strings.Join(buttons, ",") != strings.Join(tt.buttons, ",") {
+				t.Errorf("buttons %v, want %v", buttons, tt.buttons)
+			}
+		})
+	}
+}
+
+func TestDescribeActiveNoChats(t *testing.T) {
+	cfg := newTestConfig()
+	h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+	commands := newTestCommandHandler(h)
+	
+	text, keyboard, err := commands.describeActive(context.Background(), 0)
+	if err != nil || keyboard != nil || !strings.Contains(text, "没有活跃的聊天") {
+		t.Errorf("describeActive = %q, %v, %v, want no chats and no buttons", text, keyboard, err)
+	}
+}
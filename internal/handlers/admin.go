@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// testModelPrompt is the fixed prompt sent by /testmodel
+	testModelPrompt = "Reply with OK"
+	// testModelMaxReply caps how much of the raw reply is echoed back
+	testModelMaxReply = 500
+	// kbTestCandidates is how many documents /kbtest shows
+	kbTestCandidates = 5
+	// kbTestSnippetChars caps the matched section shown per document
+	kbTestSnippetChars = 150
+)
+
+// isAdmin reports whether the user is listed in bot.admin_ids
+func (h *CommandHandler) isAdmin(userID int64) bool {
+	return isAdminID(h.config.Bot.AdminIDs, userID)
+}
+
+// isAdminID reports whether userID is one of adminIDs
+func isAdminID(adminIDs []int64, userID int64) bool {
+	for _, id := range adminIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// noModelsMessage returns the message key shown when no models are configured,
+// pointing admins to the endpoint setup and everyone else to an admin
+func noModelsMessage(adminIDs []int64, userID int64) string {
+	if isAdminID(adminIDs, userID) {
+		return i18n.MsgNoModelsAdmin
+	}
+	return i18n.MsgNoModels
+}
+
+// handleTestModel handles /testmodel command, sending a fixed prompt to a model
+// through the normal AI path and reporting the latency and raw reply
+func (h *CommandHandler) handleTestModel(ctx context.Context, chatID int64, userID int64, args string) error {
+	if !h.isAdmin(userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 该命令仅限管理员使用"))
+		return err
+	}
+	
+	modelID := strings.TrimSpace(args)
+	if modelID == "" {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "用法：/testmodel <模型ID>"))
+		return err
+	}
+	
+	model, err := h.aiService.GetModelByID(modelID)
+	if err != nil {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 未找到模型：%s\n使用 /models 查看可用模型", modelID)))
+		return err
+	}
+	
+	statusMsg, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🧪 正在测试模型 %s ...", model.Name)))
+	if err != nil {
+		return err
+	}
+	
+	messages := []models.Message{{Role: "user", Content: testModelPrompt}}
+	start := time.Now()
+	reply, err := h.aiService.GetResponse(ctx, messages, model.ID)
+	latency := time.Since(start).Round(time.Millisecond)
+	
+	var text string
+	if err != nil {
+		h.logger.WithError(err).WithField("modelID", model.ID).Warn("Model test failed")
+		text = fmt.Sprintf("❌ 模型测试失败\n\n模型：%s (%s)\n端点：%s\n耗时：%s\n错误：%s",
+			model.Name, model.ID, model.EndpointName, latency, err.Error())
+	} else {
+		if runes := []rune(reply); len(runes) > testModelMaxReply {
+			reply = string(runes[:testModelMaxReply]) + "..."
+		}
+		text = fmt.Sprintf("✅ 模型测试成功\n\n模型：%s (%s)\n端点：%s\n耗时：%s\n回复：%s",
+			model.Name, model.ID, model.EndpointName, latency, reply)
+	}
+	
+	_, err = h.bot.Send(tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID, text))
+	return err
+}
+
+// handleCache handles /cache command, showing cache statistics with a flush button
+func (h *CommandHandler) handleCache(ctx context.Context, chatID int64, userID int64) error {
+	if !h.isAdmin(userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 该命令仅限管理员使用"))
+		return err
+	}
+	
+	msg := tgbotapi.NewMessage(chatID, h.describeCache(ctx))
+	msg.ReplyMarkup = cacheKeyboard()
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// handleCacheCallback handles the cache management buttons
+func (h *CommandHandler) handleCacheCallback(ctx context.Context, chatID int64, messageID int, userID int64, action string, callbackID string) error {
+	if !h.isAdmin(userID) {
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "❌ 仅限管理员"))
+		return nil
+	}
+	
+	switch action {
+	case "flush":
+		if err := h.cache.Clear(ctx); err != nil {
+			h.logger.WithError(err).Error("Failed to clear cache")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "❌ 清空失败"))
+			return err
+		}
+		h.logger.WithField("userID", userID).Info("Cache flushed by admin")
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "✅ 缓存已清空"))
+	case "refresh":
+		h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	default:
+		return nil
+	}
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, h.describeCache(ctx))
+	keyboard := cacheKeyboard()
+	edit.ReplyMarkup = &keyboard
+	_, err := h.bot.Send(edit)
+	return err
+}
+
+// describeCache formats the cache statistics
+func (h *CommandHandler) describeCache(ctx context.Context) string {
+	stats := h.cache.Stats(ctx)
+	if !stats.Enabled {
+		return "🗄 回复缓存：未启用"
+	}
+	return fmt.Sprintf("🗄 回复缓存\n\n条目数：%d / %d", stats.Items, stats.MaxSize)
+}
+
+// cacheKeyboard returns the cache management buttons
+func cacheKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 清空缓存", "cache:flush"),
+			tgbotapi.NewInlineKeyboardButtonData("🔄 刷新", "cache:refresh"),
+		),
+	)
+}
+
+// handleKBTest handles /kbtest command, showing vector search scores for a query
+// and whether each candidate passes the relevance threshold
+func (h *CommandHandler) handleKBTest(ctx context.Context, chatID int64, userID int64, query string) error {
+	if !h.isAdmin(userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 该命令仅限管理员使用"))
+		return err
+	}
+	
+	query = strings.TrimSpace(query)
+	if query == "" {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "用法：/kbtest <查询内容>"))
+		return err
+	}
+	
+	vectorService, ok := h.knowledgeService.(*knowledge.VectorKnowledgeService)
+	if !ok {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 向量知识库未启用"))
+		return err
+	}
+	
+	results, err := vectorService.ScoreDocuments(ctx, query, kbTestCandidates)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to score documents")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 检索失败："+err.Error()))
+		return err
+	}
+	
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, formatKBTestResults(vectorService, query, results)))
+	return err
+}
+
+// formatKBTestResults renders the scored candidates of /kbtest
+func formatKBTestResults(vectorService *knowledge.VectorKnowledgeService, query string, results []knowledge.DocumentWithScore) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("🧪 知识库检索测试\n查询：%s\n阈值：%.2f\n", query, knowledge.RelevanceThreshold))
+	
+	if len(results) == 0 {
+		text.WriteString("\n没有任何文档与查询相似")
+		return text.String()
+	}
+	
+	for i, result := range results {
+		verdict := "✅ 通过"
+		if result.Score <= knowledge.RelevanceThreshold {
+			verdict = "❌ 低于阈值"
+		}
+		text.WriteString(fmt.Sprintf("\n%d. %s\n   相似度：%.3f %s\n", i+1, result.Document.Title, result.Score, verdict))
+		
+		section, sectionScore := vectorService.BestSection(result.Document, query)
+		snippet := []rune(strings.Join(strings.Fields(section.Content), " "))
+		if len(snippet) > kbTestSnippetChars {
+			snippet = append(snippet[:kbTestSnippetChars], []rune("...")...)
+		}
+		text.WriteString(fmt.Sprintf("   匹配片段「%s」(%.3f)：%s\n", section.Title, sectionScore, string(snippet)))
+	}
+	
+	return text.String()
+}
+
+// handleLimit handles /limit command, overriding the rate limit of a user.
+// Usage: /limit <user_id> <rpm> or /limit <user_id> reset
+func (h *CommandHandler) handleLimit(ctx context.Context, chatID int64, userID int64, args string) error {
+	if !h.isAdmin(userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 该命令仅限管理员使用"))
+		return err
+	}
+	
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "用法：/limit <用户ID> <每分钟请求数> | /limit <用户ID> reset"))
+		return err
+	}
+	
+	targetID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 无效的用户 ID："+fields[0]))
+		return err
+	}
+	
+	rpm := 0
+	if !strings.EqualFold(fields[1], "reset") {
+		rpm, err = strconv.Atoi(fields[1])
+		if err != nil || rpm <= 0 {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请输入正整数的每分钟请求数，或 reset"))
+			return err
+		}
+	}
+	
+	if err := h.storage.SaveRateLimitOverride(ctx, targetID, rpm); err != nil {
+		h.logger.WithError(err).Error("Failed to save rate limit override")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	h.rateLimiter.SetUserLimit(targetID, rpm)
+	
+	text := fmt.Sprintf("✅ 用户 %d 已恢复默认限流（每分钟 %d 次）", targetID, h.config.RateLimit.RequestsPerMinute)
+	if rpm > 0 {
+		text = fmt.Sprintf("✅ 用户 %d 的限流已设为每分钟 %d 次", targetID, rpm)
+	}
+	if !h.config.RateLimit.Enabled {
+		text += "\n⚠️ 当前未启用限流，设置将在启用后生效"
+	}
+	
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
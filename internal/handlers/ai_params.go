@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxStopSequences is the most stop sequences OpenAI-compatible APIs accept
+const maxStopSequences = 4
+
+// paramsUsage explains /params
+const paramsUsage = "用法：/params <参数> <值> | /params <参数> default | /params reset\n" +
+	"参数：temperature (0-2)、top_p (0-1)、max_tokens、stop（多个用 | 分隔）"
+
+// setAIParam parses value into the named parameter; "default" unsets it
+func setAIParam(params *models.AIParams, name, value string) error {
+	unset := strings.EqualFold(value, "default")
+	
+	switch strings.ToLower(name) {
+	case "temperature":
+		if unset {
+			params.Temperature = nil
+			return nil
+		}
+		temperature, err := strconv.ParseFloat(value, 64)
+		if err != nil || temperature < 0 || temperature > 2 {
+			return fmt.Errorf("temperature 应为 0 到 2 之间的数字")
+		}
+		params.Temperature = &temperature
+	case "top_p":
+		if unset {
+			params.TopP = nil
+			return nil
+		}
+		topP, err := strconv.ParseFloat(value, 64)
+		if err != nil || topP <= 0 || topP > 1 {
+			return fmt.Errorf("top_p 应为大于 0 且不超过 1 的数字")
+		}
+		params.TopP = &topP
+	case "max_tokens":
+		if unset {
+			params.MaxTokens = 0
+			return nil
+		}
+		maxTokens, err := strconv.Atoi(value)
+		if err != nil || maxTokens <= 0 {
+			return fmt.Errorf("max_tokens 应为正整数")
+		}
+		params.MaxTokens = maxTokens
+	case "stop":
+		if unset {
+			params.Stop = nil
+			return nil
+		}
+		var stop []string
+		for _, sequence := range strings.Split(value, "|") {
+			if sequence = strings.TrimSpace(sequence); sequence != "" {
+				stop = append(stop, sequence)
+			}
+		}
+		if len(stop) == 0 || len(stop) > maxStopSequences {
+			return fmt.Errorf("stop 需要 1-%d 个停止序列", maxStopSequences)
+		}
+		params.Stop = stop
+	default:
+		return fmt.Errorf("未知参数：%s", name)
+	}
+	return nil
+}
+
+// handleParams handles /params command, showing or changing the chat's AI parameters
+func (h *CommandHandler) handleParams(ctx context.Context, chatID int64, args string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	fields := strings.Fields(args)
+	
+	switch {
+	case len(fields) == 0:
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.describeAIParams(settings)+"\n\n"+paramsUsage))
+		return err
+	case len(fields) == 1 && strings.EqualFold(fields[0], "reset"):
+		settings.AIParams.Temperature = nil
+		settings.AIParams.TopP = nil
+		settings.AIParams.MaxTokens = 0
+		settings.AIParams.Stop = nil
+	case len(fields) >= 2:
+		value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(args), fields[0]))
+		if err := setAIParam(&settings.AIParams, fields[0], value); err != nil {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+			return err
+		}
+	default:
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, paramsUsage))
+		return err
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ "+h.describeAIParams(settings)))
+	return err
+}
+
+// handleParamsCallback shows the AI parameters from the settings menu
+func (h *CommandHandler) handleParamsCallback(ctx context.Context, chatID int64, messageID int, callbackID string) error {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, h.describeAIParams(h.getChatSettings(ctx, chatID))+"\n\n"+paramsUsage)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:settings"),
+		),
+	)
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	return err
+}
+
+// describeAIParams lists the whole AI parameter bundle of a chat
+func (h *CommandHandler) describeAIParams(settings *models.ChatSettings) string {
+	params := settings.AIParams
+	unset := "默认"
+	
+	model := params.Model
+	if model == "" {
+		model = h.config.Models.Default
+	}
+	systemPrompt := unset
+	if params.SystemPrompt != "" {
+		systemPrompt = truncateRunes(params.SystemPrompt, 50)
+	}
+	temperature := unset
+	if params.Temperature != nil {
+		temperature = strconv.FormatFloat(*params.Temperature, 'f', -1, 64)
+	}
+	topP := unset
+	if params.TopP != nil {
+		topP = strconv.FormatFloat(*params.TopP, 'f', -1, 64)
+	}
+	maxTokens := unset
+	if params.MaxTokens > 0 {
+		maxTokens = strconv.Itoa(params.MaxTokens)
+	}
+	stop := unset
+	if len(params.Stop) > 0 {
+		stop = strings.Join(params.Stop, " | ")
+	}
+	
+	return fmt.Sprintf("🎛 AI 参数\n\n• 模型：%s\n• 系统提示词：%s\n• temperature：%s\n• top_p：%s\n• max_tokens：%s\n• stop：%s",
+		model, systemPrompt, temperature, topP, maxTokens, stop)
+}
+
+// truncateRunes shortens s to at most n characters, marking the cut
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
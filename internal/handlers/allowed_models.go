@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// isModelAllowed reports whether modelID may be used under the allowlist.
+// An empty allowlist allows every model.
+func isModelAllowed(allowed []string, modelID string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, id := range allowed {
+		if id == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAllowedModels returns the models permitted by the allowlist
+func filterAllowedModels(options []ai.ModelOption, allowed []string) []ai.ModelOption {
+	if len(allowed) == 0 {
+		return options
+	}
+	filtered := make([]ai.ModelOption, 0, len(options))
+	for _, option := range options {
+		if isModelAllowed(allowed, option.ID) {
+			filtered = append(filtered, option)
+		}
+	}
+	return filtered
+}
+
+// resolveModel picks the model for a request: a group-locked model wins, then
+// the user's choice, then the chat default. A locked model outside the chat's
+// allowlist is ignored and any other choice outside it falls back to the
+// first allowed model.
+func resolveModel(settings *models.ChatSettings, userModel string) string {
+	if settings.LockedModel != "" && isModelAllowed(settings.AllowedModels, settings.LockedModel) {
+		return settings.LockedModel
+	}
+	
+	model := settings.AIParams.Model
+	if userModel != "" {
+		model = userModel
+	}
+	if !isModelAllowed(settings.AllowedModels, model) {
+		return settings.AllowedModels[0]
+	}
+	return model
+}
+
+// isChatAdmin reports whether the user may change restricted chat settings.
+// Bot admins always may; in private chats the user owns the chat.
+func (h *CommandHandler) isChatAdmin(chatID int64, userID int64) bool {
+	if h.isAdmin(userID) || chatID == userID {
+		return true
+	}
+	
+	member, err := h.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to get chat member")
+		return false
+	}
+	return member.IsCreator() || member.IsAdministrator()
+}
+
+// handleAllowedModelsCallback handles the allowed models menu
+func (h *CommandHandler) handleAllowedModelsCallback(ctx context.Context, chatID int64, messageID int, userID int64, action string, callbackID string) error {
+	if action != "menu" {
+		if !h.isChatAdmin(chatID, userID) {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "❌ 仅群管理员可以修改"))
+			return nil
+		}
+		
+		settings := h.getChatSettings(ctx, chatID)
+		if action == "all" {
+			settings.AllowedModels = nil
+		} else if modelID := strings.TrimPrefix(action, "t:"); modelID != action {
+			settings.AllowedModels = toggleAllowedModel(settings.AllowedModels, modelID)
+		} else {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+			return nil
+		}
+		
+		if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+			h.logger.WithError(err).Error("Failed to save settings")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "保存失败"))
+			return nil
+		}
+	}
+	
+	settings := h.getChatSettings(ctx, chatID)
+	
+	text := "🧩 **可用模型**\n\n当前：所有模型均可选择\n\n点击模型加入或移出允许列表，仅群管理员可以修改。"
+	if len(settings.AllowedModels) > 0 {
+		text = fmt.Sprintf("🧩 **可用模型**\n\n当前：仅允许 %d 个模型\n\n点击模型加入或移出允许列表，仅群管理员可以修改。", len(settings.AllowedModels))
+	}
+	
+	keyboard := h.createAllowedModelsKeyboard(settings.AllowedModels)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	return err
+}
+
+// toggleAllowedModel adds modelID to the allowlist or removes it
+func toggleAllowedModel(allowed []string, modelID string) []string {
+	for i, id := range allowed {
+		if id == modelID {
+			return append(allowed[:i:i], allowed[i+1:]...)
+		}
+	}
+	return append(allowed, modelID)
+}
+
+// createAllowedModelsKeyboard creates the allowed models toggle keyboard
+func (h *CommandHandler) createAllowedModelsKeyboard(allowed []string) tgbotapi.InlineKeyboardMarkup {
+	options := h.aiService.GetAvailableModels()
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Name < options[j].Name
+	})
+	
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, option := range options {
+		mark := "⬜ "
+		if len(allowed) > 0 && isModelAllowed(allowed, option.ID) {
+			mark = "✅ "
+		}
+		rows = append(rows, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(mark+option.Name, "allowed:t:"+option.ID),
+		})
+	}
+	
+	allMark := ""
+	if len(allowed) == 0 {
+		allMark = "✅ "
+	}
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(allMark+"🔓 允许所有模型", "allowed:all"),
+	})
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:settings"),
+	})
+	
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
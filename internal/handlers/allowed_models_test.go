@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// modelButtons returns the models offered by a model selection keyboard
+func modelButtons(c *CommandHandler, allowed []string) []string {
+	var offered []string
+	for _, row := range c.createModelSelectionKeyboard(7, "", allowed).InlineKeyboard {
+		for _, button := range row {
+			if data := *button.CallbackData; strings.HasPrefix(data, "model:") {
+				offered = append(offered, strings.TrimPrefix(data, "model:"))
+			}
+		}
+	}
+	return offered
+}
+
+func TestResolveModel(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  models.ChatSettings
+		userModel string
+		want      string
+	}{
+		{name: "chat default", settings: models.ChatSettings{AIParams: models.AIParams{Model: "model-a"}}, want: "model-a"},
+		{name: "user choice", settings: models.ChatSettings{AIParams: models.AIParams{Model: "model-a"}}, userModel: "model-b", want: "model-b"},
+		{name: "locked", settings: models.ChatSettings{LockedModel: "model-c"}, userModel: "model-b", want: "model-c"},
+		{name: "allowed choice", settings: models.ChatSettings{AllowedModels: []string{"model-a", "model-b"}}, userModel: "model-b", want: "model-b"},
+		{name: "choice not allowed", settings: models.ChatSettings{AllowedModels: []string{"model-a", "model-b"}}, userModel: "model-c", want: "model-a"},
+		{
+			name:      "locked model not allowed",
+			settings:  models.ChatSettings{LockedModel: "model-c", AllowedModels: []string{"model-a", "model-b"}},
+			userModel: "model-b",
+			want:      "model-b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveModel(&tt.settings, tt.userModel); got != tt.want {
+				t.Errorf("resolveModel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelKeyboardHonorsAllowlist(t *testing.T) {
+	h, _ := newTestMessageHandler(t, newTestConfig(), &fakeAI{models: pollModels})
+	c := newTestCommandHandler(h)
+	
+	if got, want := modelButtons(c, nil), []string{"model-a", "model-b", "model-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without an allowlist offered %v, want %v", got, want)
+	}
+	if got, want := modelButtons(c, []string{"model-c", "model-a"}), []string{"model-a", "model-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with an allowlist offered %v, want %v", got, want)
+	}
+}
+
+func TestModelSelectionHonorsAllowlist(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig()
+	cfg.Models.Default = "model-a"
+	service := &fakeAI{models: pollModels, reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer", nil
+	}}
+	h, _ := newTestMessageHandler(t, cfg, service)
+	c := newTestCommandHandler(h)
+	settings := c.getChatSettings(ctx, 42)
+	settings.AllowedModels = []string{"model-a", "model-b"}
+	c.storage.SaveSettings(ctx, 42, settings)
+	
+	userModel := func() string {
+		settings, _ := h.storage.GetUserSettings(ctx, 7)
+		if settings == nil {
+			return ""
+		}
+		return settings.Model
+	}
+	pressButton(t, c, 42, 7, "model:model-b")
+	if got := userModel(); got != "model-b" {
+		t.Fatalf("picked model-b, user model is %q", got)
+	}
+	pressButton(t, c, 42, 7, "model:model-c")
+	if got := userModel(); got != "model-b" {
+		t.Errorf("picking the disallowed model-c changed the user model to %q", got)
+	}
+	
+	// A choice made before the allowlist changed falls back to an allowed model
+	settings = c.getChatSettings(ctx, 42)
+	settings.AllowedModels = []string{"model-a"}
+	c.storage.SaveSettings(ctx, 42, settings)
+	handleAndWait(t, h, privateMessage(42, 7, 1, "question"))
+	if service.requestCount() != 1 || service.modelIDs[0] != "model-a" {
+		t.Errorf("requests went to %v, want model-a", service.modelIDs)
+	}
+}
+
+func TestAllowedModelsMenu(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{models: pollModels})
+	c := newTestCommandHandler(h)
+	allowed := func(chatID int64) []string {
+		return c.getChatSettings(ctx, chatID).AllowedModels
+	}
+	
+	// In a private chat the user manages the list
+	steps := []struct {
+		button string
+		want   []string
+	}{
+		{button: "allowed:t:model-b", want: []string{"model-b"}},
+		{button: "allowed:t:model-a", want: []string{"model-b", "model-a"}},
+		{button: "allowed:t:model-b", want: []string{"model-a"}},
+		{button: "allowed:all"},
+	}
+	for _, step := range steps {
+		pressButton(t, c, 7, 7, step.button)
+		if got := allowed(7); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("after %s allowed %v, want %v", step.button, got, step.want)
+		}
+	}
+	if text := lastText(telegram.texts("editMessageText", 7)); !strings.Contains(text, "所有模型均可选择") {
+		t.Errorf("menu shows %q, want every model allowed", text)
+	}
+	
+	// Group members who aren't admins can't
+	pressButton(t, c, -100, 8, "allowed:t:model-a")
+	if got := allowed(-100); len(got) != 0 {
+		t.Errorf("a member changed the group's allowlist to %v", got)
+	}
+}
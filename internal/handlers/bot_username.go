@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// botUsername caches the bot's username. It is refreshed from getMe, so a
+// renamed bot keeps recognizing and stripping its mentions without a restart.
+type botUsername struct {
+	mu    sync.RWMutex
+	value string
+}
+
+// get returns the cached username
+func (u *botUsername) get() string {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.value
+}
+
+// set stores a username and reports whether it changed
+func (u *botUsername) set(value string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	changed := u.value != value
+	u.value = value
+	return changed
+}
+
+// botMention returns "@" followed by the bot's current username
+func (h *MessageHandler) botMention() string {
+	return "@" + h.username.get()
+}
+
+// RefreshUsername asks Telegram for the bot's current username and uses it for
+// mention detection from now on
+func (h *MessageHandler) RefreshUsername() error {
+	self, err := h.bot.GetMe()
+	if err != nil {
+		return err
+	}
+	
+	previous := h.username.get()
+	if h.username.set(self.UserName) {
+		h.logger.WithFields(logrus.Fields{
+			"previous": previous,
+			"username": self.UserName,
+		}).Info("Bot username changed")
+	}
+	return nil
+}
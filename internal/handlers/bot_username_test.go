@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMentionsFollowRename(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	mentioned := func(text string) bool {
+		t.Helper()
+		respond, err := h.shouldRespond(ctx, groupMessage(-100, 7, text))
+		if err != nil {
+			t.Fatalf("shouldRespond(%q): %v", text, err)
+		}
+		return respond
+	}
+	
+	if !mentioned("@test_bot what time is it") {
+		t.Error("mention of the current username ignored")
+	}
+	if got := h.cleanMessage("@test_bot what time is it"); got != "what time is it" {
+		t.Errorf("cleanMessage = %q", got)
+	}
+	
+	// The rename is picked up on the next refresh
+	telegram.rename("renamed_bot")
+	if err := h.RefreshUsername(); err != nil {
+		t.Fatalf("RefreshUsername: %v", err)
+	}
+	if !mentioned("@renamed_bot what time is it") {
+		t.Error("mention of the new username ignored after the refresh")
+	}
+	if mentioned("@test_bot what time is it") {
+		t.Error("old username still counts as a mention")
+	}
+	if got := h.cleanMessage("@renamed_bot what time is it"); got != "what time is it" {
+		t.Errorf("cleanMessage after the rename = %q", got)
+	}
+	
+	// A failed refresh keeps the last known username
+	telegram.fail("getMe", "Forbidden: bot was blocked")
+	if err := h.RefreshUsername(); err == nil {
+		t.Error("RefreshUsername passed while getMe fails")
+	}
+	if !mentioned("@renamed_bot what time is it") {
+		t.Error("failed refresh dropped the username")
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Defaults used when bot.broadcast is not configured
+	defaultBroadcastRate        = 25
+	defaultBroadcastConcurrency = 4
+	// broadcastMaxRetries is how often a chat is retried after a 429
+	broadcastMaxRetries = 3
+	// broadcastProgressInterval is how often the progress message is updated
+	broadcastProgressInterval = 3 * time.Second
+	// broadcastFailuresShown caps the failed chat IDs listed in the summary
+	broadcastFailuresShown = 10
+)
+
+// broadcastResult tallies a broadcast
+type broadcastResult struct {
+	Total  int
+	Sent   int
+	Failed []int64
+}
+
+// done returns how many chats have been handled so far
+func (r *broadcastResult) done() int {
+	return r.Sent + len(r.Failed)
+}
+
+// handleBroadcast handles /broadcast command, sending a message to every known chat
+func (h *CommandHandler) handleBroadcast(ctx context.Context, chatID int64, userID int64, text string) error {
+	if !h.isAdmin(userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 该命令仅限管理员使用"))
+		return err
+	}
+	
+	text = strings.TrimSpace(text)
+	if text == "" {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "用法：/broadcast <消息内容>"))
+		return err
+	}
+	
+	chatIDs, err := h.storage.ListChatIDs(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list chats")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 获取聊天列表失败，请稍后重试"))
+		return err
+	}
+	if len(chatIDs) == 0 {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "📭 没有可广播的聊天"))
+		return err
+	}
+	
+	statusMsg, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📣 正在广播：0/%d", len(chatIDs))))
+	if err != nil {
+		return err
+	}
+	
+	// Broadcasting can take minutes, report progress from the background
+	go func() {
+		send := func(target int64) error {
+			_, err := h.bot.Send(tgbotapi.NewMessage(target, text))
+			return err
+		}
+		progress := func(result broadcastResult) {
+			edit := tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID,
+				fmt.Sprintf("📣 正在广播：%d/%d（失败 %d）", result.done(), result.Total, len(result.Failed)))
+			h.bot.Send(edit)
+		}
+		
+		result := runBroadcast(ctx, chatIDs, h.config.Bot.Broadcast.RatePerSecond, h.config.Bot.Broadcast.Concurrency, send, progress)
+		h.logger.WithFields(logrus.Fields{
+			"total":  result.Total,
+			"sent":   result.Sent,
+			"failed": len(result.Failed),
+		}).Info("Broadcast finished")
+		
+		edit := tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID, formatBroadcastSummary(result))
+		if _, err := h.bot.Send(edit); err != nil {
+			h.logger.WithError(err).Warn("Failed to send broadcast summary")
+		}
+	}()
+	
+	return nil
+}
+
+// broadcastPacer spaces out the sends of all broadcast workers. A 429 seen by
+// one worker pauses all of them, since the flood limit is per bot, not per worker.
+type broadcastPacer struct {
+	limiter  *rate.Limiter
+	mu       sync.Mutex
+	resumeAt time.Time
+}
+
+// newBroadcastPacer creates a pacer allowing ratePerSecond sends per second
+func newBroadcastPacer(ratePerSecond int) *broadcastPacer {
+	return &broadcastPacer{limiter: rate.NewLimiter(rate.Limit(ratePerSecond), 1)}
+}
+
+// wait blocks until a send is allowed: any pause has passed and the rate
+// limit has a free slot
+func (p *broadcastPacer) wait(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		delay := time.Until(p.resumeAt)
+		p.mu.Unlock()
+		if delay <= 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return p.limiter.Wait(ctx)
+}
+
+// pause holds back all workers for d, extending an earlier pause if needed
+func (p *broadcastPacer) pause(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if resumeAt := time.Now().Add(d); resumeAt.After(p.resumeAt) {
+		p.resumeAt = resumeAt
+	}
+}
+
+// runBroadcast sends to every chat at no more than ratePerSecond messages per
+// second using concurrency workers, calling progress periodically with the tally
+func runBroadcast(ctx context.Context, chatIDs []int64, ratePerSecond, concurrency int, send func(chatID int64) error, progress func(broadcastResult)) broadcastResult {
+	if ratePerSecond <= 0 {
+		ratePerSecond = defaultBroadcastRate
+	}
+	if concurrency <= 0 {
+		concurrency = defaultBroadcastConcurrency
+	}
+	
+	pacer := newBroadcastPacer(ratePerSecond)
+	result := broadcastResult{Total: len(chatIDs)}
+	var mu sync.Mutex
+	
+	jobs := make(chan int64)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				err := sendWithRetryAfter(ctx, pacer, target, send)
+				mu.Lock()
+				if err != nil {
+					result.Failed = append(result.Failed, target)
+				} else {
+					result.Sent++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	
+	// Report progress until all workers are done
+	stop := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		ticker := time.NewTicker(broadcastProgressInterval)
+		defer ticker.Stop()
+		last := -1
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				snapshot := result
+				mu.Unlock()
+				if snapshot.done() != last {
+					last = snapshot.done()
+					progress(snapshot)
+				}
+			}
+		}
+	}()
+	
+	for _, target := range chatIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- target
+	}
+	close(jobs)
+	wg.Wait()
+	close(stop)
+	<-reported
+	
+	// Chats never attempted because of cancellation count as failed
+	for _, target := range chatIDs[result.done():] {
+		result.Failed = append(result.Failed, target)
+	}
+	return result
+}
+
+// sendWithRetryAfter sends to a chat within the rate limit. When Telegram
+// reports flooding, every worker waits out its retry_after before the retry.
+func sendWithRetryAfter(ctx context.Context, pacer *broadcastPacer, chatID int64, send func(chatID int64) error) error {
+	var err error
+	for attempt := 0; attempt <= broadcastMaxRetries; attempt++ {
+		if err := pacer.wait(ctx); err != nil {
+			return err
+		}
+		
+		err = send(chatID)
+		var apiErr *tgbotapi.Error
+		if err == nil || !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 {
+			return err
+		}
+		pacer.pause(time.Duration(apiErr.RetryAfter) * time.Second)
+	}
+	return err
+}
+
+// formatBroadcastSummary renders the final report of a broadcast
+func formatBroadcastSummary(result broadcastResult) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("✅ 广播完成\n\n• 总计：%d\n• 成功：%d\n• 失败：%d", result.Total, result.Sent, len(result.Failed)))
+	
+	if len(result.Failed) > 0 {
+		failed := result.Failed
+		if len(failed) > broadcastFailuresShown {
+			failed = failed[:broadcastFailuresShown]
+		}
+		ids := make([]string, len(failed))
+		for i, id := range failed {
+			ids[i] = fmt.Sprintf("%d", id)
+		}
+		text.WriteString("\n\n失败的聊天：" + strings.Join(ids, ", "))
+		if len(result.Failed) > len(failed) {
+			text.WriteString(fmt.Sprintf(" 等 %d 个", len(result.Failed)))
+		}
+	}
+	
+	return text.String()
+}
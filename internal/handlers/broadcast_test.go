@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRunBroadcastAccounting(t *testing.T) {
+	tests := []struct {
+		name    string
+		chatIDs []int64
+		failing map[int64]bool
+		sent    int
+		failed  int
+	}{
+		{name: "all sent", chatIDs: []int64{1, 2, 3}, sent: 3},
+		{name: "some failed", chatIDs: []int64{1, 2, 3, 4}, failing: map[int64]bool{2: true, 4: true}, sent: 2, failed: 2},
+		{name: "all failed", chatIDs: []int64{1}, failing: map[int64]bool{1: true}, failed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send := func(chatID int64) error {
+				if tt.failing[chatID] {
+					return &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}
+				}
+				return nil
+			}
+			result := runBroadcast(context.Background(), tt.chatIDs, 1000, 2, send, func(broadcastResult) {})
+			if result.Total != len(tt.chatIDs) || result.Sent != tt.sent || len(result.Failed) != tt.failed {
+				t.Errorf("got total %d sent %d failed %d, want %d/%d/%d",
+					result.Total, result.Sent, len(result.Failed), len(tt.chatIDs), tt.sent, tt.failed)
+			}
+		})
+	}
+}
+
+func TestRunBroadcastSharesRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	flooded := false
+	var floodedAt time.Time
+	sentAt := make(map[int64]time.Time)
+	send := func(chatID int64) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if chatID == 1 && !flooded {
+			flooded = true
+			floodedAt = time.Now()
+			return &tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+		}
+		sentAt[chatID] = time.Now()
+		return nil
+	}
+
+	// Chat 1 is sent first; the others must wait out its retry_after too
+	result := runBroadcast(context.Background(), []int64{1, 2, 3, 4}, 1000, 4, send, func(broadcastResult) {})
+	if result.Sent != 4 {
+		t.Fatalf("sent %d of 4, failed %v", result.Sent, result.Failed)
+	}
+	for chatID, at := range sentAt {
+		if at.Before(floodedAt) {
+			continue // sent before the 429 was seen
+		}
+		if at.Sub(floodedAt) < 900*time.Millisecond {
+			t.Errorf("chat %d sent %v after the 429, want the shared pause", chatID, at.Sub(floodedAt))
+		}
+	}
+}
+
+func TestFormatBroadcastSummary(t *testing.T) {
+	failed := make([]int64, broadcastFailuresShown+2)
+	for i := range failed {
+		failed[i] = int64(i + 1)
+	}
+	summary := formatBroadcastSummary(broadcastResult{Total: 20, Sent: 8, Failed: failed})
+	for _, want := range []string{"总计：20", "成功：8", "失败：12", "等 12 个"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q is missing %q", summary, want)
+		}
+	}
+}
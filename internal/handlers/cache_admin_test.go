@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheCommand(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig()
+	cfg.Bot.AdminIDs = []int64{7}
+	cfg.Cache.Enabled = true
+	cfg.Cache.TTL = time.Hour
+	cfg.Cache.MaxSize = 100
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	c := newTestCommandHandler(h)
+	for _, question := range []string{"one", "two"} {
+		h.cache.Set(ctx, question, testModel, "scope", "answer")
+	}
+	
+	runCommand(t, c, 42, 7, "/cache")
+	if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "条目数：2 / 100") {
+		t.Errorf("/cache answered %q, want 2 of 100 entries", text)
+	}
+	
+	// Only admins may flush the cache
+	pressButton(t, c, 42, 8, "cache:flush")
+	if got := h.cache.Stats(ctx).Items; got != 2 {
+		t.Fatalf("%d entries after a user pressed flush, want 2", got)
+	}
+	
+	pressButton(t, c, 42, 7, "cache:flush")
+	if got := h.cache.Stats(ctx).Items; got != 0 {
+		t.Errorf("%d entries after flushing, want 0", got)
+	}
+	if text := lastText(telegram.texts("editMessageText", 42)); !strings.Contains(text, "条目数：0 / 100") {
+		t.Errorf("stats after flushing show %q, want 0 entries", text)
+	}
+}
+
+func TestCacheCommandRefused(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Bot.AdminIDs = []int64{7}
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	runCommand(t, c, 42, 8, "/cache")
+	if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "仅限管理员") {
+		t.Errorf("/cache answered a user with %q, want it refused", text)
+	}
+	
+	// Without a cache the admin is told so
+	runCommand(t, c, 42, 7, "/cache")
+	if text := lastText(telegram.texts("sendMessage", 42)); text != "🗄 回复缓存：未启用" {
+		t.Errorf("/cache answered %q, want the cache disabled", text)
+	}
+}
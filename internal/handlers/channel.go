@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleChannelPost answers a post in a channel where the bot is an admin by
+// running it through the regular message pipeline, so mention words, keywords
+// and rate limits apply as in groups. Channel posts have no sender, the
+// channel itself stands in for the user.
+func (h *MessageHandler) HandleChannelPost(ctx context.Context, update *tgbotapi.Update) error {
+	if update.ChannelPost == nil {
+		return nil
+	}
+	
+	post := *update.ChannelPost
+	if post.From == nil {
+		post.From = &tgbotapi.User{ID: post.Chat.ID, FirstName: post.Chat.Title}
+	}
+	return h.HandleMessage(ctx, &tgbotapi.Update{UpdateID: update.UpdateID, Message: &post})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestHandleChannelPost(t *testing.T) {
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer", nil
+	}}
+	h, telegram := newTestMessageHandler(t, newTestConfig(), service)
+	post := func(text string) *tgbotapi.Update {
+		return &tgbotapi.Update{ChannelPost: &tgbotapi.Message{
+			MessageID: 1,
+			Chat:      &tgbotapi.Chat{ID: -300, Type: "channel", Title: "News"},
+			Date:      int(time.Now().Unix()),
+			Text:      text,
+		}}
+	}
+	
+	for _, text := range []string{"hello", "@test_bot hello"} {
+		if err := h.HandleChannelPost(context.Background(), post(text)); err != nil {
+			t.Fatalf("HandleChannelPost(%q): %v", text, err)
+		}
+	}
+	waitForWorkers(t, h)
+	
+	// Only the post mentioning the bot is answered
+	if service.requestCount() != 1 {
+		t.Fatalf("AI got %d requests, want 1", service.requestCount())
+	}
+	if got := lastReply(t, telegram, -300); got != "answer" {
+		t.Errorf("channel got %q, want the answer", got)
+	}
+	
+	// Updates without a channel post are ignored
+	if err := h.HandleChannelPost(context.Background(), &tgbotapi.Update{}); err != nil {
+		t.Errorf("HandleChannelPost without a post: %v", err)
+	}
+}
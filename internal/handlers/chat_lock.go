@@ -0,0 +1,50 @@
+package handlers
+
+import "sync"
+
+// ChatLocker serializes changes to chat contexts, so that messages arriving
+// together in a chat don't overwrite each other's turns and a context being
+// cleared isn't saved back. It is shared by every handler changing contexts.
+type ChatLocker struct {
+	mu    sync.Mutex
+	locks map[int64]*chatLock
+}
+
+// chatLock is a per-chat mutex, dropped once nobody holds or waits for it
+type chatLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewChatLocker creates a locker for the handlers of one bot
+func NewChatLocker() *ChatLocker {
+	return &ChatLocker{locks: make(map[int64]*chatLock)}
+}
+
+// lock blocks until the chat is free and returns the function releasing it,
+// which may be called more than once
+func (l *ChatLocker) lock(chatID int64) func() {
+	l.mu.Lock()
+	lock, ok := l.locks[chatID]
+	if !ok {
+		lock = &chatLock{}
+		l.locks[chatID] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			lock.Unlock()
+
+			l.mu.Lock()
+			lock.refs--
+			if lock.refs == 0 {
+				delete(l.locks, chatID)
+			}
+			l.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// newBlockingAI returns an AI service that reports every request containing
+// "slow" on started and answers it only once release is closed
+func newBlockingAI(started chan<- string, release <-chan struct{}) *fakeAI {
+	return &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		question := messages[len(messages)-1].Content
+		if strings.Contains(question, "slow") {
+			started <- question
+			<-release
+		}
+		return "answer to " + question, nil
+	}}
+}
+
+// waitStarted waits for n requests to reach the AI service at the same time
+func waitStarted(t *testing.T, started <-chan string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d requests reached the AI service, the chat stayed locked", i, n)
+		}
+	}
+}
+
+// savedContents returns the contents of the saved context of chatID after its
+// system message
+func savedContents(t *testing.T, h *MessageHandler, chatID int64) []string {
+	t.Helper()
+	chatCtx, err := h.storage.GetContext(context.Background(), chatID)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("no saved context: %v", err)
+	}
+	var contents []string
+	for _, msg := range chatCtx.Messages[1:] {
+		contents = append(contents, msg.Content)
+	}
+	return contents
+}
+
+func TestConcurrentMessagesKeepAllTurns(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Bot.Workers = 2
+	started, release := make(chan string), make(chan struct{})
+	h, _ := newTestMessageHandler(t, cfg, newBlockingAI(started, release))
+	
+	for i, text := range []string{"slow one", "slow two"} {
+		if err := h.HandleMessage(context.Background(), privateMessage(42, 7, i+1, text)); err != nil {
+			t.Fatalf("HandleMessage: %v", err)
+		}
+	}
+	// Both answers are generated at once, the chat is only held to read and save
+	waitStarted(t, started, 2)
+	close(release)
+	h.Shutdown(5 * time.Second)
+	
+	contents := strings.Join(savedContents(t, h, 42), "\n")
+	for _, want := range []string{"slow one", "answer to slow one", "slow two", "answer to slow two"} {
+		if !strings.Contains(contents, want) {
+			t.Errorf("context %q lost %q", contents, want)
+		}
+	}
+}
+
+func TestClearDuringAnswer(t *testing.T) {
+	tests := []struct {
+		name  string
+		clear func(ctx context.Context, h *CommandHandler) error
+		want  []string
+	}{
+		{
+			name:  "clear all",
+			clear: func(ctx context.Context, h *CommandHandler) error { return h.deleteContext(ctx, 42) },
+			want:  []string{"slow question", "answer to slow question"},
+		},
+		{
+			name:  "keep last",
+			clear: func(ctx context.Context, h *CommandHandler) error { return h.keepLastContext(ctx, 42, 1) },
+			want:  []string{"answer to second", "slow question", "answer to slow question"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started, release := make(chan string), make(chan struct{})
+			h, _ := newTestMessageHandler(t, newTestConfig(), newBlockingAI(started, release))
+			commands := &CommandHandler{storage: h.storage, chatLocks: h.chatLocks}
+			handleAndWait(t, h, privateMessage(42, 7, 1, "first"))
+			handleAndWait(t, h, privateMessage(42, 7, 2, "second"))
+			
+			if err := h.HandleMessage(context.Background(), privateMessage(42, 7, 3, "slow question")); err != nil {
+				t.Fatalf("HandleMessage: %v", err)
+			}
+			waitStarted(t, started, 1)
+			cleared := make(chan error, 1)
+			go func() { cleared <- tt.clear(context.Background(), commands) }()
+			select {
+			case err := <-cleared:
+				if err != nil {
+					t.Fatalf("clear: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("clearing waited for the answer")
+			}
+			close(release)
+			h.Shutdown(5 * time.Second)
+			
+			// The answer lands in the cleared context rather than restoring the old one
+			if got := savedContents(t, h, 42); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("context = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
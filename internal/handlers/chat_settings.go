@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// jsonPrefill is the assistant prefill used by /json to force JSON output
+const jsonPrefill = "{"
+
+// defaultChatSettings returns the default settings for a new chat
+func defaultChatSettings(cfg *config.Config) *models.ChatSettings {
+	// Use default mention words from config if available
+	defaultMentionWords := cfg.Context.DefaultMentionWords
+	if len(defaultMentionWords) == 0 {
+		defaultMentionWords = []string{"小菲", "小菲ai", "小菲AI", "ai", "AI"}
+	}
+	
+	return &models.ChatSettings{
+		ShowThink: cfg.Context.ShowThink,
+		AIParams: models.AIParams{
+			Model:        cfg.Models.Default,
+			SystemPrompt: cfg.Context.DefaultSystemPrompt,
+		},
+		Keywords:     []string{},
+		MentionWords: defaultMentionWords,
+		Language:     cfg.I18n.DefaultLanguage,
+	}
+}
+
+// getChatSettings returns the stored chat settings or the defaults
+func (h *CommandHandler) getChatSettings(ctx context.Context, chatID int64) *models.ChatSettings {
+	settings, err := h.storage.GetSettings(ctx, chatID)
+	if err != nil || settings == nil {
+		return defaultChatSettings(h.config)
+	}
+	return settings
+}
+
+// handlePrefill handles /prefill command, setting or clearing the assistant prefill
+func (h *CommandHandler) handlePrefill(ctx context.Context, chatID int64, prefill string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	settings.Prefill = strings.TrimSpace(prefill)
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	text := "✅ 已清除回复预填充"
+	if settings.Prefill != "" {
+		text = "✅ 回复将以以下内容开头：\n" + settings.Prefill
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// handleJSON handles /json command, toggling JSON output via prefill
+func (h *CommandHandler) handleJSON(ctx context.Context, chatID int64) error {
+	settings := h.getChatSettings(ctx, chatID)
+	if settings.Prefill == jsonPrefill {
+		return h.handlePrefill(ctx, chatID, "")
+	}
+	return h.handlePrefill(ctx, chatID, jsonPrefill)
+}
+
+// handleAutoClear handles /autoclear command, setting the chat's inactivity window.
+// Accepts a number of minutes, "off" to disable or "default" to follow the config.
+func (h *CommandHandler) handleAutoClear(ctx context.Context, chatID int64, args string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	arg := strings.ToLower(strings.TrimSpace(args))
+	
+	switch arg {
+	case "":
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.describeAutoClear(settings)+
+			"\n\n用法：/autoclear <分钟数> | off | default"))
+		return err
+	case "off":
+		settings.InactivityMinutes = -1
+	case "default":
+		settings.InactivityMinutes = 0
+	default:
+		minutes, err := strconv.Atoi(arg)
+		if err != nil || minutes <= 0 {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请输入正整数分钟数，或 off / default"))
+			return err
+		}
+		settings.InactivityMinutes = minutes
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ "+h.describeAutoClear(settings)))
+	return err
+}
+
+// describeAutoClear describes the effective inactivity window of a chat
+func (h *CommandHandler) describeAutoClear(settings *models.ChatSettings) string {
+	minutes := settings.InactivityMinutes
+	source := "本聊天设置"
+	if minutes == 0 {
+		minutes = h.config.Context.InactivityMinutes
+		source = "全局默认"
+	}
+	if minutes <= 0 {
+		return fmt.Sprintf("无活动自动清空：已关闭（%s）", source)
+	}
+	return fmt.Sprintf("无活动自动清空：%d 分钟（%s）", minutes, source)
+}
+
+// handleThink handles /think command, toggling whether the thinking process is shown
+func (h *CommandHandler) handleThink(ctx context.Context, chatID int64, lang string) error {
+	text, err := h.toggleShowThink(ctx, chatID, lang)
+	if err != nil {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// handleThinkCallback handles the thinking toggle button of the settings menu
+func (h *CommandHandler) handleThinkCallback(ctx context.Context, chatID int64, lang string, callbackID string) error {
+	text, err := h.toggleShowThink(ctx, chatID, lang)
+	if err != nil {
+		text = "❌ 保存失败，请稍后重试"
+	}
+	
+	_, err = h.bot.Request(tgbotapi.NewCallback(callbackID, text))
+	return err
+}
+
+// toggleShowThink flips and persists the chat's ShowThink setting,
+// returning the localized confirmation
+func (h *CommandHandler) toggleShowThink(ctx context.Context, chatID int64, lang string) (string, error) {
+	settings := h.getChatSettings(ctx, chatID)
+	settings.ShowThink = !settings.ShowThink
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		return "", err
+	}
+	
+	if settings.ShowThink {
+		return h.localizer.Get(lang, i18n.MsgThinkingEnabled, nil), nil
+	}
+	return h.localizer.Get(lang, i18n.MsgThinkingDisabled, nil), nil
+}
+
+// handleThinkStats handles /thinkstats command, toggling the reasoning token note
+func (h *CommandHandler) handleThinkStats(ctx context.Context, chatID int64) error {
+	settings := h.getChatSettings(ctx, chatID)
+	settings.ShowThinkStats = !settings.ShowThinkStats
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	text := "✅ 已关闭思考 token 统计"
+	if settings.ShowThinkStats {
+		text = "✅ 已开启思考 token 统计，隐藏思考内容时会显示模型的思考 token 数"
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
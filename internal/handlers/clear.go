@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// clearKeepLast is how many recent messages "keep last" preserves
+const clearKeepLast = 3
+
+// clearKeyboard returns the /clear scope buttons
+func clearKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 全部清空", "clear:all"),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✂️ 保留最近 %d 条", clearKeepLast), fmt.Sprintf("clear:keep%d", clearKeepLast)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "clear:cancel"),
+		),
+	)
+}
+
+// handleNew handles /new command: it drops the whole context and any flow
+// waiting for input, then greets the user so the fresh start is obvious
+func (h *CommandHandler) handleNew(ctx context.Context, chatID int64, userID int64, lang string) error {
+	if err := h.deleteContext(ctx, chatID); err != nil {
+		h.logger.WithError(err).Error("Failed to clear context")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 清空失败，请稍后重试"))
+		return err
+	}
+	
+	for _, key := range pendingInputStates {
+		if err := h.storage.DeleteUserState(ctx, userID, key); err != nil {
+			h.logger.WithError(err).WithField("state", key).Warn("Failed to clear user state")
+		}
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.localizer.Get(lang, i18n.MsgNewConversation, nil)))
+	return err
+}
+
+// handleClearCallback handles the /clear scope buttons
+func (h *CommandHandler) handleClearCallback(ctx context.Context, chatID int64, messageID int, action string, lang string, callbackID string) error {
+	var text string
+	
+	switch action {
+	case "all":
+		if err := h.deleteContext(ctx, chatID); err != nil {
+			h.logger.WithError(err).Error("Failed to clear context")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "❌ 清空失败"))
+			return err
+		}
+		text = h.localizer.Get(lang, i18n.MsgContextCleared, nil)
+	case fmt.Sprintf("keep%d", clearKeepLast):
+		if err := h.keepLastContext(ctx, chatID, clearKeepLast); err != nil {
+			h.logger.WithError(err).Error("Failed to trim context")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "❌ 清空失败"))
+			return err
+		}
+		text = fmt.Sprintf("✅ 已清空较早的对话，保留最近 %d 条消息", clearKeepLast)
+	case "cancel":
+		text = "已取消"
+	default:
+		h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+		return nil
+	}
+	
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	_, err := h.bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, text))
+	return err
+}
+
+// deleteContext drops the chat's context once no message is being saved into it
+func (h *CommandHandler) deleteContext(ctx context.Context, chatID int64) error {
+	unlock := h.chatLocks.lock(chatID)
+	defer unlock()
+	return h.storage.DeleteContext(ctx, chatID)
+}
+
+// keepLastContext trims the chat's context to its last n messages once no
+// message is being saved into it
+func (h *CommandHandler) keepLastContext(ctx context.Context, chatID int64, n int) error {
+	unlock := h.chatLocks.lock(chatID)
+	defer unlock()
+	
+	chatCtx, err := h.storage.GetContext(ctx, chatID)
+	if err != nil || chatCtx == nil || !keepLastMessages(chatCtx, n) {
+		return err
+	}
+	return h.storage.SaveContext(ctx, chatCtx)
+}
+
+// keepLastMessages trims the context to its system message plus the last n
+// messages. It reports whether anything was removed.
+func keepLastMessages(chatCtx *models.ChatContext, n int) bool {
+	start := 0
+	if len(chatCtx.Messages) > 0 && chatCtx.Messages[0].Role == "system" {
+		start = 1
+	}
+	
+	removed := len(chatCtx.Messages) - start - n
+	if removed <= 0 {
+		return false
+	}
+	
+	kept := append([]models.Message{}, chatCtx.Messages[:start]...)
+	chatCtx.Messages = append(kept, chatCtx.Messages[start+removed:]...)
+	shiftReplyIndex(chatCtx, removed)
+	shiftKnowledgeIndex(chatCtx, removed, start)
+	return true
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// numberedMessages returns a context of a system message and n alternating
+// user and assistant messages "1" to "n"
+func numberedMessages(chatID int64, n int) *models.ChatContext {
+	chatCtx := &models.ChatContext{ChatID: chatID, LastActivity: time.Now()}
+	chatCtx.Messages = append(chatCtx.Messages, models.Message{Role: "system", Content: "system"})
+	for i := 1; i <= n; i++ {
+		role := "user"
+		if i%2 == 0 {
+			role = "assistant"
+		}
+		chatCtx.Messages = append(chatCtx.Messages, models.Message{Role: role, Content: string(rune('0' + i))})
+	}
+	return chatCtx
+}
+
+func TestKeepLastMessages(t *testing.T) {
+	tests := []struct {
+		name        string
+		chatCtx     *models.ChatContext
+		n           int
+		want        []string
+		wantRemoved bool
+	}{
+		{name: "system kept", chatCtx: numberedMessages(42, 6), n: 3, want: []string{"system", "4", "5", "6"}, wantRemoved: true},
+		{name: "nothing to remove", chatCtx: numberedMessages(42, 3), n: 3, want: []string{"system", "1", "2", "3"}},
+		{name: "fewer than kept", chatCtx: numberedMessages(42, 1), n: 3, want: []string{"system", "1"}},
+		{
+			name: "no system message",
+			chatCtx: &models.ChatContext{Messages: []models.Message{
+				{Role: "user", Content: "1"}, {Role: "assistant", Content: "2"}, {Role: "user", Content: "3"}, {Role: "assistant", Content: "4"},
+			}},
+			n:           3,
+			want:        []string{"2", "3", "4"},
+			wantRemoved: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if removed := keepLastMessages(tt.chatCtx, tt.n); removed != tt.wantRemoved {
+				t.Errorf("keepLastMessages reported removing %v, want %v", removed, tt.wantRemoved)
+			}
+			var got []string
+			for _, msg := range tt.chatCtx.Messages {
+				got = append(got, msg.Content)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeepLastMessagesShiftsIndexes(t *testing.T) {
+	chatCtx := numberedMessages(42, 6)
+	// Replies after messages 2, 4 and 6, a document injected with message 5
+	chatCtx.ReplyIndex = map[int]int{100: 3, 101: 5, 102: 7}
+	chatCtx.KnowledgeIndex = map[string]int{"old": 1, "new": 5}
+	
+	keepLastMessages(chatCtx, 3)
+	
+	if want := map[int]int{101: 2, 102: 4}; !reflect.DeepEqual(chatCtx.ReplyIndex, want) {
+		t.Errorf("reply index %v, want %v", chatCtx.ReplyIndex, want)
+	}
+	if want := map[string]int{"new": 2}; !reflect.DeepEqual(chatCtx.KnowledgeIndex, want) {
+		t.Errorf("knowledge index %v, want %v", chatCtx.KnowledgeIndex, want)
+	}
+}
+
+func TestClearScopes(t *testing.T) {
+	tests := []struct {
+		button   string
+		want     []string
+		wantText string
+	}{
+		{button: "clear:keep3", want: []string{"system", "4", "5", "6"}, wantText: "保留最近 3 条消息"},
+		{button: "clear:cancel", want: []string{"system", "1", "2", "3", "4", "5", "6"}, wantText: "已取消"},
+		{button: "clear:all", wantText: "清空"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.button, func(t *testing.T) {
+			ctx := context.Background()
+			h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+			c := newTestCommandHandler(h)
+			if err := h.storage.SaveContext(ctx, numberedMessages(42, 6)); err != nil {
+				t.Fatalf("SaveContext: %v", err)
+			}
+			
+			// /clear only offers the choice
+			runCommand(t, c, 42, 7, "/clear")
+			offers := telegram.requests("sendMessage")
+			if markup := offers[len(offers)-1].Get("reply_markup"); !strings.Contains(markup, "clear:keep3") || !strings.Contains(markup, "clear:all") {
+				t.Errorf("/clear offered %s, want the scope buttons", markup)
+			}
+			if chatCtx, _ := h.storage.GetContext(ctx, 42); chatCtx == nil || len(chatCtx.Messages) != 7 {
+				t.Fatal("/clear changed the context before a scope was chosen")
+			}
+			
+			pressButton(t, c, 42, 7, tt.button)
+			
+			chatCtx, err := h.storage.GetContext(ctx, 42)
+			if err != nil {
+				t.Fatalf("GetContext: %v", err)
+			}
+			var got []string
+			if chatCtx != nil {
+				for _, msg := range chatCtx.Messages {
+					got = append(got, msg.Content)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("context %q, want %q", got, tt.want)
+			}
+			if text := lastText(telegram.texts("editMessageText", 42)); !strings.Contains(text, tt.wantText) {
+				t.Errorf("choice answered %q, want %q", text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestNewConversation(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestCommandHandler(h)
+	if err := h.storage.SaveContext(ctx, numberedMessages(42, 6)); err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+	// The user was halfway through adding a keyword
+	if err := h.storage.SetUserState(ctx, 7, "adding_keyword", "42"); err != nil {
+		t.Fatalf("SetUserState: %v", err)
+	}
+	
+	runCommand(t, c, 42, 7, "/new")
+	
+	if chatCtx, err := h.storage.GetContext(ctx, 42); err != nil || (chatCtx != nil && len(chatCtx.Messages) > 0) {
+		t.Errorf("context after /new is %+v, %v, want it cleared", chatCtx, err)
+	}
+	if state, _ := h.storage.GetUserState(ctx, 7, "adding_keyword"); state != "" {
+		t.Errorf("pending input %q survived /new", state)
+	}
+	sent := telegram.requests("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("/new sent %d messages, want the greeting alone", len(sent))
+	}
+	if text := sent[0].Get("text"); !strings.Contains(text, "已开始新的对话") || !strings.Contains(text, "有什么可以帮你的") {
+		t.Errorf("/new answered %q, want the new conversation greeting", text)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/cf-ai-tgbot-go/internal/config"
@@ -28,6 +29,9 @@ type CommandHandler struct {
 	rateLimiter      middleware.RateLimiter
 	localizer        *i18n.Localizer
 	logger           *logrus.Logger
+	modelPolls       *modelPollTracker
+	errorLog         *ErrorLog
+	chatLocks        *ChatLocker
 }
 
 // NewCommandHandler creates a new command handler
@@ -40,6 +44,8 @@ func NewCommandHandler(
 	cache cache.Service,
 	rateLimiter middleware.RateLimiter,
 	localizer *i18n.Localizer,
+	errorLog *ErrorLog,
+	chatLocks *ChatLocker,
 	logger *logrus.Logger,
 ) *CommandHandler {
 	return &CommandHandler{
@@ -52,6 +58,9 @@ func NewCommandHandler(
 		rateLimiter:      rateLimiter,
 		localizer:        localizer,
 		logger:           logger,
+		modelPolls:       newModelPollTracker(),
+		errorLog:         errorLog,
+		chatLocks:        chatLocks,
 	}
 }
 
@@ -68,9 +77,14 @@ func (h *CommandHandler) HandleCommand(ctx context.Context, message *tgbotapi.Me
 		lang = settings.Language
 	}
 	
+	// A paused chat only listens for an admin's /resume
+	if h.chatPaused(ctx, chatID) && (command != "resume" || !h.isChatAdmin(chatID, userID)) {
+		return nil
+	}
+	
 	switch command {
 	case "start":
-		return h.handleStart(ctx, chatID, lang)
+		return h.handleStart(ctx, chatID, userID, message.Chat.IsPrivate(), lang)
 	case "help":
 		return h.handleHelp(ctx, chatID, lang)
 	case "models":
@@ -79,10 +93,74 @@ func (h *CommandHandler) HandleCommand(ctx context.Context, message *tgbotapi.Me
 		return h.handleSettings(ctx, chatID, userID, lang)
 	case "clear":
 		return h.handleClear(ctx, chatID, userID, lang)
+	case "new":
+		return h.handleNew(ctx, chatID, userID, lang)
+	case "examples":
+		return h.handleExamples(ctx, chatID, lang)
 	case "stats":
 		return h.handleStats(ctx, chatID, userID, lang)
 	case "knowledge":
 		return h.handleKnowledge(ctx, chatID, userID, lang)
+	case "prefill":
+		return h.handlePrefill(ctx, chatID, message.CommandArguments())
+	case "personality":
+		return h.handlePersonality(ctx, chatID, message.CommandArguments())
+	case "remember":
+		return h.handleRemember(ctx, chatID, userID, message.CommandArguments())
+	case "forget":
+		return h.handleForget(ctx, chatID, userID, message.CommandArguments())
+	case "memories":
+		return h.handleMemories(ctx, chatID, userID)
+	case "cancel":
+		return h.handleCancel(ctx, chatID, userID)
+	case "think":
+		return h.handleThink(ctx, chatID, lang)
+	case "thinkstats":
+		return h.handleThinkStats(ctx, chatID)
+	case "autoclear":
+		return h.handleAutoClear(ctx, chatID, message.CommandArguments())
+	case "modelpoll":
+		return h.handleModelPoll(ctx, message)
+	case "json":
+		return h.handleJSON(ctx, chatID)
+	case "maxlength":
+		return h.handleMaxLength(ctx, chatID, message.CommandArguments())
+	case "minlength":
+		return h.handleMinLength(ctx, chatID, message.CommandArguments())
+	case "asfile":
+		return h.handleAsFile(ctx, chatID, message.CommandArguments())
+	case "pause":
+		return h.handlePause(ctx, chatID, userID, true)
+	case "resume":
+		return h.handlePause(ctx, chatID, userID, false)
+	case "timebudget":
+		return h.handleTimeBudget(ctx, chatID, message.CommandArguments())
+	case "kbfull":
+		return h.handleKBFull(ctx, chatID, message.CommandArguments())
+	case "keywords":
+		return h.handleKeywords(ctx, chatID, message.CommandArguments(), lang)
+	case "params":
+		return h.handleParams(ctx, chatID, message.CommandArguments())
+	case "footer":
+		return h.handleFooter(ctx, chatID, message.CommandArguments())
+	case "cache":
+		return h.handleCache(ctx, chatID, userID)
+	case "lastrequest":
+		return h.handleLastRequest(ctx, chatID, userID)
+	case "kbtest":
+		return h.handleKBTest(ctx, chatID, userID, message.CommandArguments())
+	case "broadcast":
+		return h.handleBroadcast(ctx, chatID, userID, message.CommandArguments())
+	case "limit":
+		return h.handleLimit(ctx, chatID, userID, message.CommandArguments())
+	case "testmodel":
+		return h.handleTestModel(ctx, chatID, userID, message.CommandArguments())
+	case "errors":
+		return h.handleErrors(ctx, chatID, userID, message.CommandArguments())
+	case "active":
+		return h.handleActive(ctx, chatID, userID)
+	case "mykey":
+		return h.handleMyKey(ctx, message)
 	default:
 		return h.handleUnknown(ctx, chatID, lang)
 	}
@@ -131,15 +209,63 @@ func (h *CommandHandler) HandleCallbackQuery(ctx context.Context, callback *tgbo
 		}
 	case "knowledge":
 		if len(parts) >= 2 {
-			return h.handleKnowledgeCallback(ctx, chatID, messageID, userID, parts[1], lang, callback.ID)
+			return h.handleKnowledgeCallback(ctx, chatID, messageID, userID, strings.Join(parts[1:], ":"), lang, callback.ID)
 		}
 	case "mention":
 		if len(parts) >= 2 {
 			return h.handleMentionCallback(ctx, chatID, messageID, userID, parts[1], lang, callback.ID)
 		}
+	case "keyword":
+		if len(parts) >= 2 {
+			return h.handleKeywordCallback(ctx, chatID, messageID, userID, strings.Join(parts[1:], ":"), lang, callback.ID)
+		}
+	case "params":
+		return h.handleParamsCallback(ctx, chatID, messageID, callback.ID)
 	case "mention_del":
 		if len(parts) >= 2 {
-			return h.handleMentionCallback(ctx, chatID, messageID, userID, "del:"+parts[1], lang, callback.ID)
+			return h.handleMentionCallback(ctx, chatID, messageID, userID, "del:"+strings.Join(parts[1:], ":"), lang, callback.ID)
+		}
+	case "personality":
+		if len(parts) >= 2 {
+			return h.handlePersonalityCallback(ctx, chatID, messageID, parts[1], callback.ID)
+		}
+	case "profile":
+		if len(parts) >= 2 {
+			return h.handleProfileCallback(ctx, chatID, messageID, parts[1], callback.ID)
+		}
+	case "resp_lang":
+		if len(parts) >= 2 {
+			return h.handleResponseLanguageCallback(ctx, chatID, messageID, parts[1], callback.ID)
+		}
+	case "prompt_lib":
+		if len(parts) >= 2 {
+			return h.handlePromptLibraryCallback(ctx, chatID, messageID, parts[1], lang, callback.ID)
+		}
+	case "resp_style":
+		if len(parts) >= 2 {
+			return h.handleResponseStyleCallback(ctx, chatID, messageID, parts[1], callback.ID)
+		}
+	case "allowed":
+		if len(parts) >= 2 {
+			return h.handleAllowedModelsCallback(ctx, chatID, messageID, userID, strings.Join(parts[1:], ":"), callback.ID)
+		}
+	case "think":
+		return h.handleThinkCallback(ctx, chatID, lang, callback.ID)
+	case "clear":
+		if len(parts) >= 2 {
+			return h.handleClearCallback(ctx, chatID, messageID, parts[1], lang, callback.ID)
+		}
+	case "cache":
+		if len(parts) >= 2 {
+			return h.handleCacheCallback(ctx, chatID, messageID, userID, parts[1], callback.ID)
+		}
+	case "active":
+		if len(parts) >= 2 {
+			return h.handleActiveCallback(ctx, chatID, messageID, userID, parts[1], callback.ID)
+		}
+	case "onboard":
+		if len(parts) >= 2 {
+			return h.handleOnboardingCallback(ctx, chatID, messageID, userID, strings.Join(parts[1:], ":"), lang, callback.ID)
 		}
 	case "noop":
 		// Answer callback to remove loading state
@@ -150,7 +276,11 @@ func (h *CommandHandler) HandleCallbackQuery(ctx context.Context, callback *tgbo
 }
 
 // handleStart handles /start command
-func (h *CommandHandler) handleStart(ctx context.Context, chatID int64, lang string) error {
+func (h *CommandHandler) handleStart(ctx context.Context, chatID int64, userID int64, isPrivate bool, lang string) error {
+	if h.needsOnboarding(ctx, userID, isPrivate) {
+		return h.startOnboarding(chatID, lang)
+	}
+	
 	text := h.localizer.Get(lang, i18n.MsgWelcome, nil)
 	
 	msg := tgbotapi.NewMessage(chatID, text)
@@ -192,10 +322,13 @@ func (h *CommandHandler) handleModels(ctx context.Context, chatID int64, userID
 	text := h.localizer.Get(lang, i18n.MsgCurrentModel, map[string]interface{}{
 		"Model": currentModelName,
 	})
+	if len(h.aiService.GetModelsForUser(userID)) == 0 {
+		text = h.localizer.Get(lang, noModelsMessage(h.config.Bot.AdminIDs, userID), nil)
+	}
 	
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
-	msg.ReplyMarkup = h.createModelSelectionKeyboard(settings.Model)
+	msg.ReplyMarkup = h.createModelSelectionKeyboard(userID, settings.Model, h.getChatSettings(ctx, chatID).AllowedModels)
 	
 	_, err = h.bot.Send(msg)
 	return err
@@ -215,14 +348,10 @@ func (h *CommandHandler) handleSettings(ctx context.Context, chatID int64, userI
 	return err
 }
 
-// handleClear handles /clear command
+// handleClear handles /clear command, asking how much of the context to clear
 func (h *CommandHandler) handleClear(ctx context.Context, chatID int64, userID int64, lang string) error {
-	if err := h.storage.ClearContext(ctx, userID); err != nil {
-		h.logger.WithError(err).Error("Failed to clear context")
-	}
-	
-	text := h.localizer.Get(lang, i18n.MsgContextCleared, nil)
-	msg := tgbotapi.NewMessage(chatID, text)
+	msg := tgbotapi.NewMessage(chatID, "🧹 要如何清空对话？")
+	msg.ReplyMarkup = clearKeyboard()
 	
 	_, err := h.bot.Send(msg)
 	return err
@@ -238,15 +367,85 @@ func (h *CommandHandler) handleStats(ctx context.Context, chatID int64, userID i
 		}
 	}
 	
+	text := h.formatStats(lang, stats)
+	
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// formatStats renders user statistics, including the estimated cost when available
+func (h *CommandHandler) formatStats(lang string, stats *models.UserStats) string {
 	text := h.localizer.Get(lang, i18n.MsgStats, map[string]interface{}{
 		"Messages": stats.TotalMessages,
 		"Sessions": stats.TotalSessions,
 	})
 	
-	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ParseMode = "Markdown"
+	if stats.TotalCost > 0 {
+		text += "\n" + h.localizer.Get(lang, i18n.MsgStatsCost, map[string]interface{}{
+			"Tokens": stats.PromptTokens + stats.CompletionTokens,
+			"Cost":   fmt.Sprintf("%.4f", stats.TotalCost),
+		})
+	}
 	
-	_, err = h.bot.Send(msg)
+	if top := topModelUsage(stats.ModelUsage, statsTopModels); len(top) > 0 {
+		text += "\n" + h.localizer.Get(lang, i18n.MsgStatsModels, nil)
+		for _, usage := range top {
+			name := usage.modelID
+			if model, err := h.aiService.GetModelByID(usage.modelID); err == nil {
+				name = model.Name
+			}
+			text += fmt.Sprintf("\n• %s: %d", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, name), usage.count)
+		}
+	}
+	
+	return text
+}
+
+// statsTopModels is how many models /stats lists
+const statsTopModels = 3
+
+// modelUsageCount is a single model's response count
+type modelUsageCount struct {
+	modelID string
+	count   int
+}
+
+// topModelUsage returns up to limit models ordered by usage, ties broken by ID
+func topModelUsage(usage map[string]int, limit int) []modelUsageCount {
+	counts := make([]modelUsageCount, 0, len(usage))
+	for modelID, count := range usage {
+		counts = append(counts, modelUsageCount{modelID: modelID, count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].modelID < counts[j].modelID
+	})
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts
+}
+
+// pendingInputStates lists the user states of flows that wait for text input
+var pendingInputStates = []string{
+	"config_action", "config_endpoint", "temp_endpoint",
+	"configuring_endpoint", "adding_model", "adding_mention", "adding_keyword", "knowledge_search",
+}
+
+// handleCancel handles /cancel command, aborting any flow waiting for input
+func (h *CommandHandler) handleCancel(ctx context.Context, chatID int64, userID int64) error {
+	for _, key := range pendingInputStates {
+		if err := h.storage.DeleteUserState(ctx, userID, key); err != nil {
+			h.logger.WithError(err).WithField("state", key).Warn("Failed to clear user state")
+		}
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ 已取消当前操作"))
 	return err
 }
 
@@ -284,7 +483,10 @@ func (h *CommandHandler) handleMenuCallback(ctx context.Context, chatID int64, m
 		text = h.localizer.Get(lang, i18n.MsgCurrentModel, map[string]interface{}{
 			"Model": currentModelName,
 		})
-		keyboard = h.createModelSelectionKeyboard(settings.Model)
+		if len(h.aiService.GetModelsForUser(userID)) == 0 {
+			text = h.localizer.Get(lang, noModelsMessage(h.config.Bot.AdminIDs, userID), nil)
+		}
+		keyboard = h.createModelSelectionKeyboard(userID, settings.Model, h.getChatSettings(ctx, chatID).AllowedModels)
 	case "settings":
 		text = h.localizer.Get(lang, i18n.MsgSettings, map[string]interface{}{
 			"Language": lang,
@@ -295,10 +497,7 @@ func (h *CommandHandler) handleMenuCallback(ctx context.Context, chatID int64, m
 		if stats == nil {
 			stats = &models.UserStats{}
 		}
-		text = h.localizer.Get(lang, i18n.MsgStats, map[string]interface{}{
-			"Messages": stats.TotalMessages,
-			"Sessions": stats.TotalSessions,
-		})
+		text = h.formatStats(lang, stats)
 		keyboard = h.createBackButtonKeyboard(lang)
 	default:
 		return nil
@@ -321,10 +520,18 @@ func (h *CommandHandler) handleModelCallback(ctx context.Context, chatID int64,
 		}
 	}
 	
-	// Validate model exists
+	// Validate model exists and isn't another user's private model. A missing
+	// model usually means the keyboard predates its removal, so refresh it.
+	chatSettings := h.getChatSettings(ctx, chatID)
 	model, err := h.aiService.GetModelByID(modelID)
-	if err != nil {
-		h.bot.Request(tgbotapi.NewCallback(callbackID, h.localizer.Get(lang, "error.model_not_found", nil)))
+	if err != nil || !model.VisibleTo(userID) {
+		keyboard := h.createModelSelectionKeyboard(userID, settings.Model, chatSettings.AllowedModels)
+		return h.answerStaleMenu(chatID, messageID, lang, callbackID, &keyboard)
+	}
+	
+	// Respect the chat's model allowlist
+	if !isModelAllowed(chatSettings.AllowedModels, modelID) {
+		h.bot.Request(tgbotapi.NewCallbackWithAlert(callbackID, "❌ 该模型未被本聊天允许使用"))
 		return nil
 	}
 	
@@ -336,7 +543,7 @@ func (h *CommandHandler) handleModelCallback(ctx context.Context, chatID int64,
 	}
 	
 	// Clear context when model changes
-	h.storage.ClearContext(ctx, userID)
+	h.deleteContext(ctx, userID)
 	
 	// Update message
 	text := h.localizer.Get(lang, i18n.MsgModelChanged, map[string]interface{}{
@@ -345,7 +552,7 @@ func (h *CommandHandler) handleModelCallback(ctx context.Context, chatID int64,
 	
 	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
 	edit.ParseMode = "Markdown"
-	keyboard := h.createModelSelectionKeyboard(modelID)
+	keyboard := h.createModelSelectionKeyboard(userID, modelID, chatSettings.AllowedModels)
 	edit.ReplyMarkup = &keyboard
 	
 	_, err = h.bot.Send(edit)
@@ -358,15 +565,7 @@ func (h *CommandHandler) handleModelCallback(ctx context.Context, chatID int64,
 
 func (h *CommandHandler) handleLanguageCallback(ctx context.Context, chatID int64, messageID int, userID int64, newLang string, callbackID string) error {
 	// Validate language
-	validLang := false
-	for _, l := range h.config.I18n.Languages {
-		if l == newLang {
-			validLang = true
-			break
-		}
-	}
-	
-	if !validLang {
+	if !h.isConfiguredLanguage(newLang) {
 		h.bot.Request(tgbotapi.NewCallback(callbackID, "Invalid language"))
 		return nil
 	}
@@ -407,7 +606,7 @@ func (h *CommandHandler) handleLanguageCallback(ctx context.Context, chatID int6
 func (h *CommandHandler) handleActionCallback(ctx context.Context, chatID int64, messageID int, userID int64, action string, lang string, callbackID string) error {
 	switch action {
 	case "clear":
-		if err := h.storage.ClearContext(ctx, userID); err != nil {
+		if err := h.deleteContext(ctx, userID); err != nil {
 			h.logger.WithError(err).Error("Failed to clear context")
 			h.bot.Request(tgbotapi.NewCallback(callbackID, h.localizer.Get(lang, "error.clear_failed", nil)))
 			return nil
@@ -506,49 +705,41 @@ func (h *CommandHandler) createMainMenuKeyboard(lang string) tgbotapi.InlineKeyb
 	)
 }
 
-func (h *CommandHandler) createModelSelectionKeyboard(currentModelID string) tgbotapi.InlineKeyboardMarkup {
-	models := h.aiService.GetAvailableModels()
+func (h *CommandHandler) createModelSelectionKeyboard(userID int64, currentModelID string, allowed []string) tgbotapi.InlineKeyboardMarkup {
+	models := filterAllowedModels(h.aiService.GetModelsForUser(userID), allowed)
 	rows := make([][]tgbotapi.InlineKeyboardButton, 0)
 	
-	// Group models by endpoint
-	endpointModels := make(map[string][]ai.ModelOption)
-	for _, model := range models {
-		endpointModels[model.EndpointName] = append(endpointModels[model.EndpointName], model)
-	}
-	
-	// Create buttons for each endpoint group
-	for endpointName, models := range endpointModels {
-		endpoint := h.getEndpointByName(endpointName)
-		if endpoint != nil {
-			// Add endpoint header
+	for _, group := range h.groupModels(models) {
+		// Add group header
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("📍 %s", group.title),
+				"noop",
+			),
+		))
+		
+		// Add model buttons
+		for _, model := range group.models {
+			checkmark := ""
+			if model.ID == currentModelID {
+				checkmark = "✅ "
+			}
+			
 			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData(
-					fmt.Sprintf("📍 %s", endpoint.DisplayName),
-					"noop",
+					fmt.Sprintf("%s%s", checkmark, model.Name),
+					fmt.Sprintf("model:%s", model.ID),
 				),
 			))
-			
-			// Add model buttons
-			for _, model := range models {
-				checkmark := ""
-				if model.ID == currentModelID {
-					checkmark = "✅ "
-				}
-				
-				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData(
-						fmt.Sprintf("%s%s", checkmark, model.Name),
-						fmt.Sprintf("model:%s", model.ID),
-					),
-				))
-			}
 		}
 	}
 	
-	// Add custom model configuration button
-	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("⚙️ 配置自定义模型", "config:add_endpoint"),
-	))
+	// Add custom model configuration button, unless safe mode locks the configuration
+	if !h.config.Models.SafeMode {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚙️ 配置自定义模型", "config:add_endpoint"),
+		))
+	}
 	
 	// Add back button
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
@@ -566,24 +757,58 @@ func (h *CommandHandler) createSettingsKeyboard(currentLang string) tgbotapi.Inl
 	}
 	
 	// Add language options
-	for _, lang := range h.config.I18n.Languages {
-		checkmark := ""
-		if lang == currentLang {
-			checkmark = "✅ "
-		}
-		
-		langName := h.localizer.Get(lang, "language.name", nil)
+	rows = append(rows, h.languageRows(currentLang, "lang")...)
+	
+	// Add mention words button
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("💬 提及词管理", "action:mention_words"),
+	})
+	
+	// Add keywords button
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🔑 关键词管理", "keyword:menu"),
+	})
+	
+	// Add AI parameters button
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🎛 AI 参数", "params:view"),
+	})
+	
+	// Add personality button
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🎭 机器人性格", "personality:menu"),
+	})
+	
+	// Add prompt library button when presets are configured
+	if len(h.config.Context.PromptLibrary) > 0 {
 		rows = append(rows, []tgbotapi.InlineKeyboardButton{
-			tgbotapi.NewInlineKeyboardButtonData(
-				fmt.Sprintf("%s%s", checkmark, langName),
-				fmt.Sprintf("lang:%s", lang),
-			),
+			tgbotapi.NewInlineKeyboardButtonData("📜 提示词库", "prompt_lib:menu"),
 		})
 	}
 	
-	// Add mention words button
+	// Add response language button
 	rows = append(rows, []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("💬 提及词管理", "action:mention_words"),
+		tgbotapi.NewInlineKeyboardButtonData("🗣 回答语言", "resp_lang:menu"),
+	})
+	
+	// Add response style button
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("📝 回答风格", "resp_style:menu"),
+	})
+	
+	// Add use-case profile button
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🎛 使用场景", "profile:menu"),
+	})
+	
+	// Add thinking toggle button
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🧠 显示/隐藏思考过程", "think:toggle"),
+	})
+	
+	// Add allowed models button
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🧩 可用模型", "allowed:menu"),
 	})
 	
 	// Add back button
@@ -594,6 +819,27 @@ func (h *CommandHandler) createSettingsKeyboard(currentLang string) tgbotapi.Inl
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
+// languageRows lists the configured languages, one button per row, with
+// callbacks of the form "<prefix>:<lang>"
+func (h *CommandHandler) languageRows(currentLang string, prefix string) [][]tgbotapi.InlineKeyboardButton {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(h.config.I18n.Languages))
+	for _, lang := range h.config.I18n.Languages {
+		checkmark := ""
+		if lang == currentLang {
+			checkmark = "✅ "
+		}
+		
+		langName := h.localizer.Get(lang, "language.name", nil)
+		rows = append(rows, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s%s", checkmark, langName),
+				fmt.Sprintf("%s:%s", prefix, lang),
+			),
+		})
+	}
+	return rows
+}
+
 func (h *CommandHandler) createBackButtonKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -604,6 +850,11 @@ func (h *CommandHandler) createBackButtonKeyboard(lang string) tgbotapi.InlineKe
 
 // handleCustomModelCallback handles custom model configuration callbacks
 func (h *CommandHandler) handleCustomModelCallback(ctx context.Context, chatID int64, messageID int, userID int64, action string, lang string, callbackID string) error {
+	if h.config.Models.SafeMode {
+		h.bot.Request(tgbotapi.NewCallbackWithAlert(callbackID, h.localizer.Get(lang, "error.config_locked", nil)))
+		return nil
+	}
+	
 	switch action {
 	case "config":
 		// Show custom model configuration menu
@@ -678,9 +929,9 @@ func (h *CommandHandler) handleCustomModelCallback(ctx context.Context, chatID i
 				}
 			}
 			
+			// The endpoint was removed after the menu was sent
 			if endpoint == nil {
-				h.bot.Request(tgbotapi.NewCallback(callbackID, "端点未找到"))
-				return nil
+				return h.answerStaleMenu(chatID, messageID, lang, callbackID, nil)
 			}
 			
 			// Show endpoint configuration options
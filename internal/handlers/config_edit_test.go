@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newTestConfigHandler returns a config handler sharing h's bot and storage,
+// managing the base endpoint "test"
+func newTestConfigHandler(h *MessageHandler) *ConfigHandler {
+	h.config.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", DisplayName: "Test", BaseURL: "https://old.example.com/v1", APIKey: "sk-old",
+		Models: []config.ModelInfo{{ID: testModel}},
+	}}
+	configService := dynamicconfig.NewDynamicConfigService(nil, h.config, h.logger)
+	return NewConfigHandler(h.bot, configService, h.storage, h.localizer, h.logger)
+}
+
+// configCallback returns a callback query of userID pressing a button with data
+// in a private chat
+func configCallback(chatID, userID int64, data string) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:      "callback",
+		From:    &tgbotapi.User{ID: userID},
+		Message: &tgbotapi.Message{MessageID: 500, Chat: &tgbotapi.Chat{ID: chatID, Type: "private"}},
+		Data:    data,
+	}
+}
+
+// configInput hands text of userID to the config flows, failing unless consumed
+func configInput(t *testing.T, c *ConfigHandler, chatID, userID int64, text string) {
+	t.Helper()
+	message := privateMessage(chatID, userID, 2, text).Message
+	consumed, err := c.HandleConfigInput(context.Background(), message)
+	if err != nil {
+		t.Fatalf("HandleConfigInput(%q): %v", text, err)
+	}
+	if !consumed {
+		t.Fatalf("HandleConfigInput(%q) left the message alone", text)
+	}
+}
+
+// currentTestEndpoint returns the configured endpoint "test"
+func currentTestEndpoint(t *testing.T, c *ConfigHandler) config.ModelEndpoint {
+	t.Helper()
+	current, err := c.configService.GetCurrentConfig(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentConfig: %v", err)
+	}
+	for _, endpoint := range current.Models.Endpoints {
+		if endpoint.Name == "test" {
+			return endpoint
+		}
+	}
+	t.Fatal("endpoint test is gone")
+	return config.ModelEndpoint{}
+}
+
+func editState(t *testing.T, c *ConfigHandler, userID int64) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+	action, _ := c.storage.GetUserState(ctx, userID, "config_action")
+	endpoint, _ := c.storage.GetUserState(ctx, userID, "config_endpoint")
+	return action, endpoint
+}
+
+func lastText(sent []string) string {
+	if len(sent) == 0 {
+		return ""
+	}
+	return sent[len(sent)-1]
+}
+
+func TestEditEndpointMenu(t *testing.T) {
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestConfigHandler(h)
+	
+	if err := c.HandleConfigCallback(context.Background(), configCallback(42, 7, "config:edit_endpoint:test")); err != nil {
+		t.Fatalf("HandleConfigCallback: %v", err)
+	}
+	
+	edits := telegram.texts("editMessageText", 42)
+	if text := lastText(edits); !strings.Contains(text, "编辑端点: test") {
+		t.Errorf("menu %q, want the endpoint's edit menu", text)
+	}
+	// Only opening a form starts waiting for input
+	if action, _ := editState(t, c, 7); action != "" {
+		t.Errorf("config_action = %q after opening the menu, want none", action)
+	}
+}
+
+func TestEditEndpointURL(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestConfigHandler(h)
+	
+	if err := c.HandleConfigCallback(ctx, configCallback(42, 7, "config:edit_url:test")); err != nil {
+		t.Fatalf("HandleConfigCallback: %v", err)
+	}
+	if action, endpoint := editState(t, c, 7); action != "editing_url" || endpoint != "test" {
+		t.Fatalf("state = %q, %q, want editing_url of test", action, endpoint)
+	}
+	
+	// An invalid address is refused and the form stays open for another try
+	for _, text := range []string{"not a url", "ftp://old.example.com", "https://"} {
+		configInput(t, c, 42, 7, text)
+		if sent := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(sent, "无效的API地址") {
+			t.Errorf("%q answered with %q, want it refused", text, sent)
+		}
+		if action, _ := editState(t, c, 7); action != "editing_url" {
+			t.Errorf("config_action = %q after refusing %q, want editing_url", action, text)
+		}
+	}
+	if got := currentTestEndpoint(t, c).BaseURL; got != "https://old.example.com/v1" {
+		t.Errorf("base URL = %q after invalid input, want it unchanged", got)
+	}
+	
+	configInput(t, c, 42, 7, " https://new.example.com/v1/ ")
+	if got := currentTestEndpoint(t, c).BaseURL; got != "https://new.example.com/v1" {
+		t.Errorf("base URL = %q, want https://new.example.com/v1", got)
+	}
+	if sent := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(sent, "API地址已更新") {
+		t.Errorf("answered with %q, want the update confirmed", sent)
+	}
+	if action, endpoint := editState(t, c, 7); action != "" || endpoint != "" {
+		t.Errorf("state = %q, %q after the update, want it cleared", action, endpoint)
+	}
+	
+	// Later messages are ordinary messages again
+	if consumed, _ := c.HandleConfigInput(ctx, privateMessage(42, 7, 3, "hello").Message); consumed {
+		t.Error("message after the update consumed by the config flow")
+	}
+}
+
+func TestEditEndpointKey(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestConfigHandler(h)
+	
+	if err := c.HandleConfigCallback(ctx, configCallback(42, 7, "config:edit_key:test")); err != nil {
+		t.Fatalf("HandleConfigCallback: %v", err)
+	}
+	if action, endpoint := editState(t, c, 7); action != "editing_key" || endpoint != "test" {
+		t.Fatalf("state = %q, %q, want editing_key of test", action, endpoint)
+	}
+	
+	configInput(t, c, 42, 7, "  ")
+	if sent := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(sent, "API密钥不能为空") {
+		t.Errorf("empty key answered with %q, want it refused", sent)
+	}
+	if action, _ := editState(t, c, 7); action != "editing_key" {
+		t.Errorf("config_action = %q after an empty key, want editing_key", action)
+	}
+	
+	configInput(t, c, 42, 7, " sk-new-secret ")
+	if got := currentTestEndpoint(t, c).APIKey; got != "sk-new-secret" {
+		t.Errorf("API key = %q, want sk-new-secret", got)
+	}
+	sent := lastText(telegram.texts("sendMessage", 42))
+	if !strings.Contains(sent, "sk-n****") || strings.Contains(sent, "sk-new-secret") {
+		t.Errorf("answered with %q, want the key confirmed masked", sent)
+	}
+	if action, endpoint := editState(t, c, 7); action != "" || endpoint != "" {
+		t.Errorf("state = %q, %q after the update, want it cleared", action, endpoint)
+	}
+	
+	// The messages carrying keys are deleted from the chat
+	telegram.mu.Lock()
+	deleted := 0
+	for _, call := range telegram.calls {
+		if call.method == "deleteMessage" {
+			deleted++
+		}
+	}
+	telegram.mu.Unlock()
+	if deleted != 2 {
+		t.Errorf("deleted %d messages, want both key messages", deleted)
+	}
+}
+
+func TestEditEndpointCancel(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestConfigHandler(h)
+	commands := newTestCommandHandler(h)
+	
+	if err := c.HandleConfigCallback(ctx, configCallback(42, 7, "config:edit_url:test")); err != nil {
+		t.Fatalf("HandleConfigCallback: %v", err)
+	}
+	if err := commands.HandleCommand(ctx, command(42, 7, "/cancel")); err != nil {
+		t.Fatalf("/cancel: %v", err)
+	}
+	
+	if action, endpoint := editState(t, c, 7); action != "" || endpoint != "" {
+		t.Errorf("state = %q, %q after /cancel, want it cleared", action, endpoint)
+	}
+	if consumed, _ := c.HandleConfigInput(ctx, privateMessage(42, 7, 3, "https://new.example.com").Message); consumed {
+		t.Error("address after /cancel consumed by the config flow")
+	}
+	if got := currentTestEndpoint(t, c).BaseURL; got != "https://old.example.com/v1" {
+		t.Errorf("base URL = %q after /cancel, want it unchanged", got)
+	}
+}
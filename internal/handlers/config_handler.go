@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
 	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
 	"github.com/cf-ai-tgbot-go/internal/services/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -21,6 +21,7 @@ type ConfigHandler struct {
 	bot           *tgbotapi.BotAPI
 	configService *dynamicconfig.DynamicConfigService
 	storage       *storage.Manager
+	localizer     *i18n.Localizer
 	logger        *logrus.Logger
 }
 
@@ -29,12 +30,14 @@ func NewConfigHandler(
 	bot *tgbotapi.BotAPI,
 	configService *dynamicconfig.DynamicConfigService,
 	storage *storage.Manager,
+	localizer *i18n.Localizer,
 	logger *logrus.Logger,
 ) *ConfigHandler {
 	return &ConfigHandler{
 		bot:           bot,
 		configService: configService,
 		storage:       storage,
+		localizer:     localizer,
 		logger:        logger,
 	}
 }
@@ -52,6 +55,12 @@ func (h *ConfigHandler) HandleConfigCallback(ctx context.Context, callback *tgbo
 	
 	action := parts[1]
 	
+	// Only testing an endpoint leaves the configuration untouched
+	if h.configService.SafeMode() && action != "test_endpoint" {
+		h.bot.Request(tgbotapi.NewCallbackWithAlert(callback.ID, h.configLockedText(ctx, userID)))
+		return nil
+	}
+	
 	switch action {
 	case "add_endpoint":
 		return h.showAddEndpointForm(ctx, chatID, messageID, userID, callback.ID)
@@ -68,7 +77,12 @@ func (h *ConfigHandler) HandleConfigCallback(ctx context.Context, callback *tgbo
 		
 	case "confirm_delete":
 		if len(parts) >= 3 {
-			return h.deleteEndpoint(ctx, chatID, messageID, parts[2], callback.ID)
+			return h.deleteEndpoint(ctx, chatID, messageID, userID, parts[2], false, callback.ID)
+		}
+		
+	case "force_delete":
+		if len(parts) >= 3 {
+			return h.deleteEndpoint(ctx, chatID, messageID, userID, parts[2], true, callback.ID)
 		}
 		
 	case "add_model":
@@ -80,6 +94,31 @@ func (h *ConfigHandler) HandleConfigCallback(ctx context.Context, callback *tgbo
 		if len(parts) >= 3 {
 			return h.showEditEndpointMenu(ctx, chatID, messageID, parts[2], callback.ID)
 		}
+		
+	case "force_add":
+		if len(parts) >= 3 {
+			return h.forceAddEndpoint(ctx, chatID, messageID, userID, parts[2], callback.ID)
+		}
+		
+	case "common_models":
+		if len(parts) >= 3 {
+			return h.showCommonModels(ctx, chatID, messageID, parts[2], callback.ID)
+		}
+		
+	case "preset":
+		if len(parts) >= 4 {
+			return h.applyModelPreset(ctx, chatID, messageID, userID, parts[2], parts[3], callback.ID)
+		}
+		
+	case "edit_url":
+		if len(parts) >= 3 {
+			return h.showEditURLForm(ctx, chatID, messageID, userID, parts[2], callback.ID)
+		}
+		
+	case "edit_key":
+		if len(parts) >= 3 {
+			return h.showEditKeyForm(ctx, chatID, messageID, userID, parts[2], callback.ID)
+		}
 	}
 	
 	return nil
@@ -99,22 +138,24 @@ func (h *ConfigHandler) showAddEndpointForm(ctx context.Context, chatID int64, m
 	
 	text := `🆕 **添加新的AI端点**
 
-请依次发送以下信息（每行一个）：
+请按「字段: 值」的格式发送以下信息（每行一个）：
 
-1️⃣ **端点名称**（英文，如: my-api）
-2️⃣ **显示名称**（如: 我的API）
+1️⃣ **名称**（英文，如: my-api）
+2️⃣ **显示名称**（可选，如: 我的API）
 3️⃣ **API地址**（如: https://api.example.com/v1）
 4️⃣ **API密钥**（如: sk-xxxxx）
 
 📝 **示例消息：**
 ` + "```" + `
-my-custom-api
-我的自定义API
-https://api.myservice.com/v1
-sk-1234567890abcdef
+名称: my-custom-api
+显示名称: 我的自定义API
+API地址: https://api.myservice.com/v1
+API密钥: sk-1234567890abcdef
 ` + "```" + `
 
 💡 **提示：**
+- 字段名也可以用英文（name / display_name / base_url / api_key）
+- 也可以不写字段名，按上面的顺序每行一个值
 - API地址必须兼容OpenAI格式
 - 所有信息请一次性发送
 - 发送后将自动测试连接`
@@ -131,34 +172,51 @@ sk-1234567890abcdef
 	return err
 }
 
-// handleConfigInput processes user input for configuration
-func (h *ConfigHandler) HandleConfigInput(ctx context.Context, message *tgbotapi.Message) error {
+// configLockedText returns the safe mode refusal in the user's language
+func (h *ConfigHandler) configLockedText(ctx context.Context, userID int64) string {
+	lang := ""
+	if settings, err := h.storage.GetUserSettings(ctx, userID); err == nil && settings != nil {
+		lang = settings.Language
+	}
+	return h.localizer.Get(lang, "error.config_locked", nil)
+}
+
+// HandleConfigInput processes user input for configuration.
+// It reports whether the message was consumed by a configuration flow.
+func (h *ConfigHandler) HandleConfigInput(ctx context.Context, message *tgbotapi.Message) (bool, error) {
 	userID := message.From.ID
 	
 	// Get current config action
 	action, err := h.storage.GetUserState(ctx, userID, "config_action")
 	if err != nil || action == "" {
-		return nil
+		return false, nil
+	}
+	
+	// A flow started before safe mode was turned on can't be finished
+	if h.configService.SafeMode() {
+		h.clearEditState(ctx, userID)
+		_, err := h.bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.configLockedText(ctx, userID)))
+		return true, err
 	}
 	
 	switch action {
 	case "adding_endpoint":
-		return h.handleAddEndpointInput(ctx, message)
+		return true, h.handleAddEndpointInput(ctx, message)
 		
 	case "adding_model":
 		endpointName, _ := h.storage.GetUserState(ctx, userID, "config_endpoint")
-		return h.handleAddModelInput(ctx, message, endpointName)
+		return true, h.handleAddModelInput(ctx, message, endpointName)
 		
 	case "editing_url":
 		endpointName, _ := h.storage.GetUserState(ctx, userID, "config_endpoint")
-		return h.handleEditURLInput(ctx, message, endpointName)
+		return true, h.handleEditURLInput(ctx, message, endpointName)
 		
 	case "editing_key":
 		endpointName, _ := h.storage.GetUserState(ctx, userID, "config_endpoint")
-		return h.handleEditKeyInput(ctx, message, endpointName)
+		return true, h.handleEditKeyInput(ctx, message, endpointName)
 	}
 	
-	return nil
+	return false, nil
 }
 
 // handleAddEndpointInput processes endpoint addition input
@@ -166,25 +224,14 @@ func (h *ConfigHandler) handleAddEndpointInput(ctx context.Context, message *tgb
 	userID := message.From.ID
 	chatID := message.Chat.ID
 	
-	// Parse input
-	lines := strings.Split(strings.TrimSpace(message.Text), "\n")
-	if len(lines) < 4 {
-		msg := tgbotapi.NewMessage(chatID, "❌ 请提供完整的端点信息（4行）")
-		h.bot.Send(msg)
-		return nil
-	}
-	
-	name := strings.TrimSpace(lines[0])
-	displayName := strings.TrimSpace(lines[1])
-	baseURL := strings.TrimSpace(lines[2])
-	apiKey := strings.TrimSpace(lines[3])
-	
-	// Validate input
-	if err := h.validateEndpointInput(name, displayName, baseURL, apiKey); err != nil {
-		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 输入验证失败：%s", err.Error()))
+	// Parse and validate input
+	input, problems := parseEndpointInput(message.Text, false)
+	if len(problems) > 0 {
+		msg := tgbotapi.NewMessage(chatID, formatInputProblems(problems))
 		h.bot.Send(msg)
 		return nil
 	}
+	name, displayName, baseURL, apiKey := input.Name, input.DisplayName, input.BaseURL, input.APIKey
 	
 	// Create endpoint
 	endpoint := &config.ModelEndpoint{
@@ -219,7 +266,7 @@ func (h *ConfigHandler) handleAddEndpointInput(ctx context.Context, message *tgb
 	}
 	
 	// Add endpoint
-	if err := h.configService.AddEndpoint(ctx, endpoint); err != nil {
+	if err := h.configService.AddEndpoint(ctx, userID, endpoint); err != nil {
 		editMsg := tgbotapi.NewEditMessageText(chatID, sentMsg.MessageID, 
 			fmt.Sprintf("❌ 添加失败：%s", err.Error()))
 		h.bot.Send(editMsg)
@@ -257,6 +304,75 @@ func (h *ConfigHandler) handleAddEndpointInput(ctx context.Context, message *tgb
 	return nil
 }
 
+// parseTempEndpoint rebuilds an endpoint from the pipe-delimited temp_endpoint state
+func parseTempEndpoint(state string) (*config.ModelEndpoint, error) {
+	// The API key comes last so it may itself contain "|"
+	fields := strings.SplitN(state, "|", 4)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid temporary endpoint state")
+	}
+	
+	return &config.ModelEndpoint{
+		Name:        fields[0],
+		DisplayName: fields[1],
+		BaseURL:     fields[2],
+		APIKey:      fields[3],
+		Models:      []config.ModelInfo{},
+	}, nil
+}
+
+// forceAddEndpoint adds the endpoint whose connection test failed
+func (h *ConfigHandler) forceAddEndpoint(ctx context.Context, chatID int64, messageID int, userID int64, endpointName string, callbackID string) error {
+	state, err := h.storage.GetUserState(ctx, userID, "temp_endpoint")
+	if err != nil || state == "" {
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "端点信息已过期，请重新添加"))
+		return nil
+	}
+	
+	endpoint, err := parseTempEndpoint(state)
+	if err != nil || endpoint.Name != endpointName {
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "端点信息不匹配，请重新添加"))
+		return nil
+	}
+	
+	// Clear user state
+	h.storage.DeleteUserState(ctx, userID, "temp_endpoint")
+	h.storage.DeleteUserState(ctx, userID, "config_action")
+	
+	if err := h.configService.AddEndpoint(ctx, userID, endpoint); err != nil {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ 添加失败：%s", err.Error()))
+		h.bot.Send(edit)
+		h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+		return nil
+	}
+	
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ 添加模型", fmt.Sprintf("config:add_model:%s", endpoint.Name)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📋 查看所有端点", "menu:models"),
+			tgbotapi.NewInlineKeyboardButtonData("🏠 主菜单", "menu:main"),
+		),
+	)
+	
+	text := fmt.Sprintf(`⚠️ **端点已添加（未通过连接测试）**
+
+📍 **名称：** %s
+🏷 **显示名称：** %s
+🌐 **API地址：** %s
+
+该端点未通过连接测试，使用前请确认地址和密钥是否正确。`, endpoint.Name, endpoint.DisplayName, endpoint.BaseURL)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err = h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, "已添加"))
+	return err
+}
+
 // showAddModelForm shows form for adding model to endpoint
 func (h *ConfigHandler) showAddModelForm(ctx context.Context, chatID int64, messageID int, userID int64, endpointName string, callbackID string) error {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -333,7 +449,7 @@ func (h *ConfigHandler) handleAddModelInput(ctx context.Context, message *tgbota
 	}
 	
 	// Add model to endpoint
-	if err := h.configService.AddModelToEndpoint(ctx, endpointName, model); err != nil {
+	if err := h.configService.AddModelToEndpoint(ctx, userID, endpointName, model); err != nil {
 		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 添加模型失败：%s", err.Error()))
 		h.bot.Send(msg)
 		return nil
@@ -367,31 +483,6 @@ func (h *ConfigHandler) handleAddModelInput(ctx context.Context, message *tgbota
 	return nil
 }
 
-// validateEndpointInput validates endpoint input
-func (h *ConfigHandler) validateEndpointInput(name, displayName, baseURL, apiKey string) error {
-	// Validate name
-	if !regexp.MustCompile(`^[a-zA-Z0-9-_]+$`).MatchString(name) {
-		return fmt.Errorf("端点名称只能包含字母、数字、横线和下划线")
-	}
-	
-	// Validate URL
-	u, err := url.Parse(baseURL)
-	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
-		return fmt.Errorf("无效的API地址")
-	}
-	
-	// Validate other fields
-	if displayName == "" {
-		return fmt.Errorf("显示名称不能为空")
-	}
-	
-	if apiKey == "" {
-		return fmt.Errorf("API密钥不能为空")
-	}
-	
-	return nil
-}
-
 // showCommonModels shows common model presets
 func (h *ConfigHandler) showCommonModels(ctx context.Context, chatID int64, messageID int, endpointName string, callbackID string) error {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -408,7 +499,7 @@ func (h *ConfigHandler) showCommonModels(ctx context.Context, chatID int64, mess
 			tgbotapi.NewInlineKeyboardButtonData("🟠 Llama系列", fmt.Sprintf("config:preset:llama:%s", endpointName)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅��� 返回", fmt.Sprintf("config:add_model:%s", endpointName)),
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", fmt.Sprintf("config:add_model:%s", endpointName)),
 		),
 	)
 	
@@ -447,13 +538,19 @@ func (h *ConfigHandler) testEndpoint(ctx context.Context, chatID int64, messageI
 	return err
 }
 
-// confirmDeleteEndpoint shows confirmation for endpoint deletion
+// confirmDeleteEndpoint shows confirmation for endpoint deletion, with an
+// extra warning when the endpoint is the last one left
 func (h *ConfigHandler) confirmDeleteEndpoint(ctx context.Context, chatID int64, messageID int, endpointName string, callbackID string) error {
 	text := fmt.Sprintf("⚠️ **确认删除端点**\n\n您确定要删除端点 `%s` 吗？\n\n此操作将删除该端点及其所有模型配置。", endpointName)
+	confirmData := fmt.Sprintf("config:confirm_delete:%s", endpointName)
+	if h.isLastEndpoint(ctx, endpointName) {
+		text += "\n\n❗ 这是最后一个端点，删除后所有用户将无法使用任何模型，直到重新添加端点。"
+		confirmData = fmt.Sprintf("config:force_delete:%s", endpointName)
+	}
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✅ 确认删除", fmt.Sprintf("config:confirm_delete:%s", endpointName)),
+			tgbotapi.NewInlineKeyboardButtonData("✅ 确认删除", confirmData),
 			tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "menu:models"),
 		),
 	)
@@ -467,10 +564,20 @@ func (h *ConfigHandler) confirmDeleteEndpoint(ctx context.Context, chatID int64,
 	return err
 }
 
-// deleteEndpoint deletes an endpoint
-func (h *ConfigHandler) deleteEndpoint(ctx context.Context, chatID int64, messageID int, endpointName string, callbackID string) error {
-	// TODO: Implement endpoint deletion
+// deleteEndpoint deletes an endpoint. The last remaining endpoint is only
+// deleted when force is set, i.e. after the last-endpoint warning was confirmed.
+func (h *ConfigHandler) deleteEndpoint(ctx context.Context, chatID int64, messageID int, userID int64, endpointName string, force bool, callbackID string) error {
+	if !force && h.isLastEndpoint(ctx, endpointName) {
+		return h.confirmDeleteEndpoint(ctx, chatID, messageID, endpointName, callbackID)
+	}
+	
 	text := fmt.Sprintf("✅ 端点 `%s` 已删除", endpointName)
+	callbackText := "删除成功"
+	if err := h.configService.RemoveEndpoint(ctx, userID, endpointName); err != nil {
+		h.logger.WithError(err).WithField("endpoint", endpointName).Warn("Failed to remove endpoint")
+		text = fmt.Sprintf("❌ 删除端点 `%s` 失败：%s", endpointName, err.Error())
+		callbackText = "删除失败"
+	}
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -483,10 +590,20 @@ func (h *ConfigHandler) deleteEndpoint(ctx context.Context, chatID int64, messag
 	edit.ReplyMarkup = &keyboard
 	
 	_, err := h.bot.Send(edit)
-	h.bot.Request(tgbotapi.NewCallback(callbackID, "删除成功"))
+	h.bot.Request(tgbotapi.NewCallback(callbackID, callbackText))
 	return err
 }
 
+// isLastEndpoint reports whether the endpoint is the only one configured
+func (h *ConfigHandler) isLastEndpoint(ctx context.Context, endpointName string) bool {
+	cfg, err := h.configService.GetCurrentConfig(ctx)
+	if err != nil {
+		return false
+	}
+	endpoints := cfg.Models.Endpoints
+	return len(endpoints) == 1 && endpoints[0].Name == endpointName
+}
+
 // showEditEndpointMenu shows endpoint edit menu
 func (h *ConfigHandler) showEditEndpointMenu(ctx context.Context, chatID int64, messageID int, endpointName string, callbackID string) error {
 	text := fmt.Sprintf("⚙️ **编辑端点: %s**\n\n请选择要修改的内容：", endpointName)
@@ -498,6 +615,9 @@ func (h *ConfigHandler) showEditEndpointMenu(ctx context.Context, chatID int64,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔑 修改API密钥", fmt.Sprintf("config:edit_key:%s", endpointName)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 删除端点", fmt.Sprintf("config:delete_endpoint:%s", endpointName)),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:models"),
 		),
@@ -512,25 +632,57 @@ func (h *ConfigHandler) showEditEndpointMenu(ctx context.Context, chatID int64,
 	return err
 }
 
+// showEditURLForm asks for the new API address of an endpoint
+func (h *ConfigHandler) showEditURLForm(ctx context.Context, chatID int64, messageID int, userID int64, endpointName string, callbackID string) error {
+	text := fmt.Sprintf("📝 **修改API地址: %s**\n\n请发送新的API地址（HTTP/HTTPS），例如：\n`https://api.openai.com/v1`\n\n_发送 /cancel 取消操作_", endpointName)
+	
+	// Set user state
+	h.storage.SetUserState(ctx, userID, "config_action", "editing_url")
+	h.storage.SetUserState(ctx, userID, "config_endpoint", endpointName)
+	
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", fmt.Sprintf("config:edit_endpoint:%s", endpointName)),
+		),
+	)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, "请输入新的API地址"))
+	return err
+}
+
+// clearEditState clears the state of an endpoint edit flow
+func (h *ConfigHandler) clearEditState(ctx context.Context, userID int64) {
+	h.storage.DeleteUserState(ctx, userID, "config_action")
+	h.storage.DeleteUserState(ctx, userID, "config_endpoint")
+}
+
 // handleEditURLInput handles URL edit input
 func (h *ConfigHandler) handleEditURLInput(ctx context.Context, message *tgbotapi.Message, endpointName string) error {
 	chatID := message.Chat.ID
 	newURL := strings.TrimSpace(message.Text)
 	
-	// Validate URL
+	// Validate URL (keep the state so the user can try again)
 	u, err := url.Parse(newURL)
-	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
 		msg := tgbotapi.NewMessage(chatID, "❌ 无效的API地址，请输入有效的HTTP/HTTPS URL")
 		h.bot.Send(msg)
 		return nil
 	}
 	
+	// Clear user state
+	h.clearEditState(ctx, message.From.ID)
+	
 	// Update endpoint
 	updates := map[string]interface{}{
-		"base_url": newURL,
+		"base_url": strings.TrimSuffix(newURL, "/"),
 	}
 	
-	if err := h.configService.UpdateEndpoint(ctx, endpointName, updates); err != nil {
+	if err := h.configService.UpdateEndpoint(ctx, message.From.ID, endpointName, updates); err != nil {
 		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 更新失败：%s", err.Error()))
 		h.bot.Send(msg)
 		return nil
@@ -539,13 +691,31 @@ func (h *ConfigHandler) handleEditURLInput(ctx context.Context, message *tgbotap
 	// Success message
 	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ 端点 `%s` 的API地址已更新为：\n%s", endpointName, newURL))
 	msg.ParseMode = "Markdown"
-	h.bot.Send(msg)
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// showEditKeyForm asks for the new API key of an endpoint
+func (h *ConfigHandler) showEditKeyForm(ctx context.Context, chatID int64, messageID int, userID int64, endpointName string, callbackID string) error {
+	text := fmt.Sprintf("🔑 **修改API密钥: %s**\n\n请发送新的API密钥。\n\n正在进行的请求会使用旧密钥完成，之后的请求将使用新密钥。\n\n_发送 /cancel 取消操作_", endpointName)
 	
-	// Clear user state
-	h.storage.DeleteUserState(ctx, message.From.ID, "config_action")
-	h.storage.DeleteUserState(ctx, message.From.ID, "config_endpoint")
+	// Set user state
+	h.storage.SetUserState(ctx, userID, "config_action", "editing_key")
+	h.storage.SetUserState(ctx, userID, "config_endpoint", endpointName)
 	
-	return nil
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", fmt.Sprintf("config:edit_endpoint:%s", endpointName)),
+		),
+	)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, "请输入新的API密钥"))
+	return err
 }
 
 // handleEditKeyInput handles API key edit input
@@ -553,18 +723,22 @@ func (h *ConfigHandler) handleEditKeyInput(ctx context.Context, message *tgbotap
 	chatID := message.Chat.ID
 	newKey := strings.TrimSpace(message.Text)
 	
+	// Don't leave the key in the chat history
+	if _, err := h.bot.Request(tgbotapi.NewDeleteMessage(chatID, message.MessageID)); err != nil {
+		h.logger.WithError(err).Debug("Failed to delete API key message")
+	}
+	
 	if newKey == "" {
 		msg := tgbotapi.NewMessage(chatID, "❌ API密钥不能为空")
 		h.bot.Send(msg)
 		return nil
 	}
 	
-	// Update endpoint
-	updates := map[string]interface{}{
-		"api_key": newKey,
-	}
+	// Clear user state
+	h.clearEditState(ctx, message.From.ID)
 	
-	if err := h.configService.UpdateEndpoint(ctx, endpointName, updates); err != nil {
+	// Rotate the key without interrupting in-flight requests
+	if err := h.configService.RotateKey(ctx, message.From.ID, endpointName, newKey); err != nil {
 		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 更新失败：%s", err.Error()))
 		h.bot.Send(msg)
 		return nil
@@ -574,13 +748,8 @@ func (h *ConfigHandler) handleEditKeyInput(ctx context.Context, message *tgbotap
 	maskedKey := newKey[:min(4, len(newKey))] + "****"
 	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ 端点 `%s` 的API密钥已更新为：%s", endpointName, maskedKey))
 	msg.ParseMode = "Markdown"
-	h.bot.Send(msg)
-	
-	// Clear user state
-	h.storage.DeleteUserState(ctx, message.From.ID, "config_action")
-	h.storage.DeleteUserState(ctx, message.From.ID, "config_endpoint")
-	
-	return nil
+	_, err := h.bot.Send(msg)
+	return err
 }
 
 // min returns the minimum of two integers
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+)
+
+func TestContentFilteredMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "content filtered", err: fmt.Errorf("request failed: %w", ai.ErrContentFiltered), want: "内容被安全过滤拦截"},
+		{name: "other error", err: errors.New("no response from AI"), want: "发生错误"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+				return "", tt.err
+			}}
+			h, telegram := newTestMessageHandler(t, newTestConfig(), service)
+			
+			handleAndWait(t, h, privateMessage(42, 7, 1, "question"))
+			
+			edits := telegram.texts("editMessageText", 42)
+			if text := lastText(edits); !strings.Contains(text, tt.want) {
+				t.Errorf("thinking message became %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
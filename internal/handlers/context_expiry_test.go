@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestContextExpiryUsesCurrentSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		global      int
+		stored      int // the window when the context was saved
+		current     int // the window set since with /autoclear
+		wantExpired bool
+	}{
+		{name: "window set since", stored: 0, current: 30, wantExpired: true},
+		{name: "window turned off since", global: 30, stored: 30, current: -1, wantExpired: false},
+		{name: "window widened since", stored: 30, current: 180, wantExpired: false},
+		{name: "global window", global: 30, wantExpired: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := newTestConfig()
+			cfg.Context.InactivityMinutes = tt.global
+			h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+			
+			stale := h.getDefaultSettings()
+			stale.InactivityMinutes = tt.stored
+			chatCtx := &models.ChatContext{
+				SchemaVersion: models.ContextSchemaVersion,
+				ChatID:        42,
+				Messages:      []models.Message{{Role: "system"}, {Role: "user", Content: "hello"}},
+				LastActivity:  time.Now().Add(-time.Hour),
+				Settings:      *stale,
+			}
+			if err := h.storage.SaveContext(ctx, chatCtx); err != nil {
+				t.Fatalf("SaveContext: %v", err)
+			}
+			saveChatSettings(t, h, 42, func(s *models.ChatSettings) { s.InactivityMinutes = tt.current })
+			
+			got, expired, err := h.getOrCreateContext(ctx, 42, 7)
+			if err != nil {
+				t.Fatalf("getOrCreateContext: %v", err)
+			}
+			if expired != tt.wantExpired {
+				t.Errorf("expired = %v, want %v", expired, tt.wantExpired)
+			}
+			if kept := len(got.Messages) == 2; kept == tt.wantExpired {
+				t.Errorf("context kept %d messages after expired = %v", len(got.Messages), expired)
+			}
+			if got.Settings.InactivityMinutes != tt.current {
+				t.Errorf("settings window = %d, want the current %d", got.Settings.InactivityMinutes, tt.current)
+			}
+		})
+	}
+}
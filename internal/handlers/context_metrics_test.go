@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestContextMetrics(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.MaxMessages = 2
+	service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) { return "answer", nil }}
+	h, _ := newTestMessageHandler(t, cfg, service)
+	
+	trims := map[string]string{"reason": "count"}
+	trimsBefore := metricSample(t, "telegram_bot_context_trims_total", trims).Value
+	sizeBefore := metricSample(t, "telegram_bot_context_messages", nil)
+	
+	for i := 1; i <= 3; i++ {
+		handleAndWait(t, h, privateMessage(42, 7, i, fmt.Sprintf("question %d", i)))
+	}
+	
+	// The first turn fits, the next two each trim once when saved
+	if got := metricSample(t, "telegram_bot_context_trims_total", trims).Value - trimsBefore; got != 2 {
+		t.Errorf("recorded %v trims, want 2", got)
+	}
+	
+	// Every request observes the size of the context it sent
+	var sent int
+	for _, messages := range service.requests {
+		sent += len(messages)
+	}
+	size := metricSample(t, "telegram_bot_context_messages", nil)
+	if count := size.Count - sizeBefore.Count; count != 3 {
+		t.Errorf("observed %d context sizes, want 3", count)
+	}
+	if sum := size.Value - sizeBefore.Value; sum != float64(sent) {
+		t.Errorf("observed %v messages in total, want the %d sent", sum, sent)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+// newPricedAI returns a real AI service for testModel, priced per 1k tokens,
+// whose endpoint reports 1500 prompt and 500 completion tokens per request
+func newPricedAI(t *testing.T, cfg *config.Config, inputPrice, outputPrice float64) ai.Service {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"answer"}}],`+
+			`"usage":{"prompt_tokens":1500,"completion_tokens":500,"total_tokens":2000}}`)
+	}))
+	t.Cleanup(server.Close)
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", DisplayName: "Test", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: testModel, InputPricePer1K: inputPrice, OutputPricePer1K: outputPrice}},
+	}}
+	return ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger())
+}
+
+func TestCostEstimate(t *testing.T) {
+	tests := []struct {
+		name        string
+		inputPrice  float64
+		outputPrice float64
+		wantCost    float64
+		wantShown   string // cost line of /stats, empty when omitted
+	}{
+		{name: "priced model", inputPrice: 0.01, outputPrice: 0.03, wantCost: 0.06, wantShown: "预估费用: $0.0600"},
+		{name: "unpriced model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := newTestConfig()
+			h, telegram := newTestMessageHandler(t, cfg, newPricedAI(t, cfg, tt.inputPrice, tt.outputPrice))
+			labels := map[string]string{"model": testModel}
+			before := metricSample(t, "telegram_bot_ai_cost_total", labels).Value
+			
+			// Two requests of 1500 prompt and 500 completion tokens
+			handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+			handleAndWait(t, h, privateMessage(42, 7, 2, "and again"))
+			
+			stats, err := h.storage.GetUserStats(ctx, 7)
+			if err != nil {
+				t.Fatalf("GetUserStats: %v", err)
+			}
+			if stats.PromptTokens != 3000 || stats.CompletionTokens != 1000 {
+				t.Errorf("tokens = %d prompt, %d completion, want 3000, 1000", stats.PromptTokens, stats.CompletionTokens)
+			}
+			if math.Abs(stats.TotalCost-tt.wantCost) > 1e-9 {
+				t.Errorf("TotalCost = %g, want %g", stats.TotalCost, tt.wantCost)
+			}
+			if recorded := metricSample(t, "telegram_bot_ai_cost_total", labels).Value - before; math.Abs(recorded-tt.wantCost) > 1e-9 {
+				t.Errorf("cost metric grew by %g, want %g", recorded, tt.wantCost)
+			}
+			
+			commands := newTestCommandHandler(h)
+			if err := commands.HandleCommand(ctx, command(42, 7, "/stats")); err != nil {
+				t.Fatalf("/stats: %v", err)
+			}
+			sent := telegram.texts("sendMessage", 42)
+			text := sent[len(sent)-1]
+			if tt.wantShown != "" && !strings.Contains(text, tt.wantShown) {
+				t.Errorf("/stats shows %q, want %q", text, tt.wantShown)
+			}
+			if tt.wantShown == "" && strings.Contains(text, "预估费用") {
+				t.Errorf("/stats shows %q, want no cost for an unpriced model", text)
+			}
+		})
+	}
+}
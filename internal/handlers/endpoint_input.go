@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Canonical field names of the endpoint input form
+const (
+	fieldName        = "name"
+	fieldDisplayName = "display_name"
+	fieldBaseURL     = "base_url"
+	fieldAPIKey      = "api_key"
+	fieldModels      = "models"
+	
+	// maxEndpointNameLength matches the limit enforced by the dynamic config service
+	maxEndpointNameLength = 64
+)
+
+// endpointFieldAliases maps the accepted (lowercased, space-free) keys to canonical field names
+var endpointFieldAliases = map[string]string{
+	"name": fieldName, "endpoint": fieldName, "名称": fieldName, "端点名称": fieldName, "端点": fieldName,
+	"display_name": fieldDisplayName, "displayname": fieldDisplayName, "显示名称": fieldDisplayName, "显示名": fieldDisplayName,
+	"base_url": fieldBaseURL, "baseurl": fieldBaseURL, "url": fieldBaseURL, "api_url": fieldBaseURL, "api地址": fieldBaseURL, "地址": fieldBaseURL,
+	"api_key": fieldAPIKey, "apikey": fieldAPIKey, "key": fieldAPIKey, "api密钥": fieldAPIKey, "密钥": fieldAPIKey,
+	"models": fieldModels, "model": fieldModels, "模型": fieldModels, "模型列表": fieldModels,
+}
+
+// endpointPositionalFields is the field order of the legacy one-value-per-line form
+var endpointPositionalFields = []string{fieldName, fieldDisplayName, fieldBaseURL, fieldAPIKey, fieldModels}
+
+// endpointNamePattern restricts endpoint names to identifier-like strings
+var endpointNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// endpointInput holds the parsed endpoint form
+type endpointInput struct {
+	Name        string
+	DisplayName string
+	BaseURL     string
+	APIKey      string
+	Models      string
+}
+
+// parseEndpointInput parses the endpoint form. It accepts "key: value" lines
+// with English or Chinese keys, as well as the legacy one-value-per-line form.
+// All problems are returned together so the user can fix them in one go.
+func parseEndpointInput(text string, requireModels bool) (*endpointInput, []string) {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		// Skip blank lines and code fences copied from the example
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	
+	values, unknown := parseKeyValueLines(lines)
+	if len(values) == 0 {
+		// Nothing looked like a key, fall back to positional lines
+		unknown = nil
+		for i, line := range lines {
+			if i < len(endpointPositionalFields) {
+				values[endpointPositionalFields[i]] = cleanInputValue(line)
+			}
+		}
+	}
+	
+	input := &endpointInput{
+		Name:        values[fieldName],
+		DisplayName: values[fieldDisplayName],
+		BaseURL:     values[fieldBaseURL],
+		APIKey:      values[fieldAPIKey],
+		Models:      values[fieldModels],
+	}
+	if input.DisplayName == "" {
+		input.DisplayName = input.Name
+	}
+	
+	var problems []string
+	for _, key := range unknown {
+		problems = append(problems, fmt.Sprintf("无法识别的字段「%s」", key))
+	}
+	
+	switch {
+	case input.Name == "":
+		problems = append(problems, "缺少端点名称（名称: my-api）")
+	case !endpointNamePattern.MatchString(input.Name):
+		problems = append(problems, fmt.Sprintf("端点名称「%s」只能包含字母、数字、横线和下划线", input.Name))
+	case len(input.Name) > maxEndpointNameLength:
+		problems = append(problems, fmt.Sprintf("端点名称不能超过 %d 个字符", maxEndpointNameLength))
+	}
+	
+	if input.BaseURL == "" {
+		problems = append(problems, "缺少API地址（API地址: https://api.example.com/v1）")
+	} else if u, err := url.Parse(input.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("API地址「%s」无效，需以 http:// 或 https:// 开头", input.BaseURL))
+	}
+	
+	if input.APIKey == "" {
+		problems = append(problems, "缺少API密钥（API密钥: sk-xxxxx）")
+	}
+	
+	if requireModels && input.Models == "" {
+		problems = append(problems, "缺少模型列表（模型列表: gpt-4o,gpt-4o-mini）")
+	}
+	
+	return input, problems
+}
+
+// parseKeyValueLines reads "key: value" lines, accepting ASCII and full-width
+// colons. Lines whose key isn't a known field are reported as unknown.
+func parseKeyValueLines(lines []string) (map[string]string, []string) {
+	values := make(map[string]string)
+	var unknown []string
+	
+	for _, line := range lines {
+		sep := strings.IndexAny(line, ":：")
+		if sep <= 0 {
+			continue
+		}
+		
+		key := strings.TrimSpace(line[:sep])
+		normalized := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(key, " ", ""), "-", "_"))
+		field, ok := endpointFieldAliases[normalized]
+		if !ok {
+			// "https://..." without a key is a value, not an unknown field
+			if !strings.HasPrefix(line[sep:], "://") {
+				unknown = append(unknown, key)
+			}
+			continue
+		}
+		
+		_, size := utf8.DecodeRuneInString(line[sep:])
+		values[field] = cleanInputValue(line[sep+size:])
+	}
+	
+	if len(values) == 0 {
+		return values, nil
+	}
+	return values, unknown
+}
+
+// inputQuotes pairs the opening and closing quotes stripped from values
+var inputQuotes = map[rune]rune{'"': '"', '\'': '\'', '`': '`', '“': '”', '‘': '’', '「': '」'}
+
+// cleanInputValue trims whitespace and surrounding quotes or backticks
+func cleanInputValue(value string) string {
+	value = strings.TrimSpace(value)
+	for {
+		first, firstSize := utf8.DecodeRuneInString(value)
+		last, lastSize := utf8.DecodeLastRuneInString(value)
+		closing, ok := inputQuotes[first]
+		if !ok || closing != last || len(value) < firstSize+lastSize {
+			return value
+		}
+		value = strings.TrimSpace(value[firstSize : len(value)-lastSize])
+	}
+}
+
+// formatInputProblems renders parse problems as a bullet list
+func formatInputProblems(problems []string) string {
+	var text strings.Builder
+	text.WriteString("❌ 输入有误，请修改后重新发送：\n")
+	for _, problem := range problems {
+		text.WriteString("\n• ")
+		text.WriteString(problem)
+	}
+	return text.String()
+}
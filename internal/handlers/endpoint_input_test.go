@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEndpointInput(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		requireModels bool
+		want          endpointInput
+		wantProblems  []string // substrings, one per expected problem
+	}{
+		{
+			name: "english keys",
+			text: "name: my-api\nbase_url: https://api.example.com/v1\napi_key: sk-123\nmodels: gpt-4o,gpt-4o-mini",
+			want: endpointInput{Name: "my-api", DisplayName: "my-api", BaseURL: "https://api.example.com/v1", APIKey: "sk-123", Models: "gpt-4o,gpt-4o-mini"},
+		},
+		{
+			name: "chinese keys, full-width colons and quotes",
+			text: "```\n名称：my-api\n显示名称： “我的接口”\nAPI地址：'https://api.example.com/v1'\nAPI密钥：`sk-123`\n```",
+			want: endpointInput{Name: "my-api", DisplayName: "我的接口", BaseURL: "https://api.example.com/v1", APIKey: "sk-123"},
+		},
+		{
+			name: "legacy positional lines",
+			text: "my-api\nMy API\nhttps://api.example.com/v1\nsk-123\ngpt-4o",
+			want: endpointInput{Name: "my-api", DisplayName: "My API", BaseURL: "https://api.example.com/v1", APIKey: "sk-123", Models: "gpt-4o"},
+		},
+		{
+			name:         "empty",
+			text:         "\n  \n",
+			wantProblems: []string{"缺少端点名称", "缺少API地址", "缺少API密钥"},
+		},
+		{
+			name:         "unknown field",
+			text:         "name: my-api\ncolour: blue\nurl: https://api.example.com/v1\nkey: sk-123",
+			want:         endpointInput{Name: "my-api", DisplayName: "my-api", BaseURL: "https://api.example.com/v1", APIKey: "sk-123"},
+			wantProblems: []string{"无法识别的字段「colour」"},
+		},
+		{
+			name:         "invalid name",
+			text:         "name: my api!\nurl: https://api.example.com/v1\nkey: sk-123",
+			want:         endpointInput{Name: "my api!", DisplayName: "my api!", BaseURL: "https://api.example.com/v1", APIKey: "sk-123"},
+			wantProblems: []string{"只能包含字母、数字、横线和下划线"},
+		},
+		{
+			name:         "name too long",
+			text:         "name: " + strings.Repeat("a", maxEndpointNameLength+1) + "\nurl: https://api.example.com/v1\nkey: sk-123",
+			want:         endpointInput{Name: strings.Repeat("a", maxEndpointNameLength+1), DisplayName: strings.Repeat("a", maxEndpointNameLength+1), BaseURL: "https://api.example.com/v1", APIKey: "sk-123"},
+			wantProblems: []string{"端点名称不能超过"},
+		},
+		{
+			name:         "url without scheme",
+			text:         "name: my-api\nurl: api.example.com/v1\nkey: sk-123",
+			want:         endpointInput{Name: "my-api", DisplayName: "my-api", BaseURL: "api.example.com/v1", APIKey: "sk-123"},
+			wantProblems: []string{"API地址「api.example.com/v1」无效"},
+		},
+		{
+			name:         "url with another scheme",
+			text:         "name: my-api\nurl: ftp://api.example.com\nkey: sk-123",
+			want:         endpointInput{Name: "my-api", DisplayName: "my-api", BaseURL: "ftp://api.example.com", APIKey: "sk-123"},
+			wantProblems: []string{"API地址「ftp://api.example.com」无效"},
+		},
+		{
+			name:          "models required",
+			text:          "name: my-api\nurl: https://api.example.com/v1\nkey: sk-123",
+			requireModels: true,
+			want:          endpointInput{Name: "my-api", DisplayName: "my-api", BaseURL: "https://api.example.com/v1", APIKey: "sk-123"},
+			wantProblems:  []string{"缺少模型列表"},
+		},
+		{
+			name: "lone quote kept",
+			text: "name: my-api\nurl: https://api.example.com/v1\nkey: \"",
+			want: endpointInput{Name: "my-api", DisplayName: "my-api", BaseURL: "https://api.example.com/v1", APIKey: "\""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := parseEndpointInput(tt.text, tt.requireModels)
+			if *got != tt.want {
+				t.Errorf("parsed %+v, want %+v", *got, tt.want)
+			}
+			if len(problems) != len(tt.wantProblems) {
+				t.Fatalf("problems = %q, want %d matching %q", problems, len(tt.wantProblems), tt.wantProblems)
+			}
+			for i, want := range tt.wantProblems {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %q, want it to mention %q", problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatInputProblems(t *testing.T) {
+	text := formatInputProblems([]string{"first", "second"})
+	if !strings.HasSuffix(text, "\n• first\n• second") {
+		t.Errorf("formatted %q, want one bullet per problem", text)
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+)
+
+func TestEndpointRateLimitedAnswer(t *testing.T) {
+	service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) {
+		return "", fmt.Errorf("%w: test", ai.ErrEndpointRateLimited)
+	}}
+	h, telegram := newTestMessageHandler(t, newTestConfig(), service)
+	labels := map[string]string{"endpoint": "test"}
+	before := metricSample(t, "telegram_bot_endpoint_rate_limited_total", labels).Value
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+	
+	edits := telegram.texts("editMessageText", 42)
+	if len(edits) == 0 || !strings.Contains(edits[len(edits)-1], "系统繁忙") {
+		t.Errorf("answers %q, want the busy message", edits)
+	}
+	if got := metricSample(t, "telegram_bot_endpoint_rate_limited_total", labels).Value - before; got != 1 {
+		t.Errorf("recorded %v rate limited requests of the endpoint, want 1", got)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// defaultErrorLogSize is used when bot.error_log_size is unset
+	defaultErrorLogSize = 50
+	// defaultErrorsShown is how many errors /errors shows without an argument
+	defaultErrorsShown = 10
+	// errorMessageChars caps the error text kept per entry
+	errorMessageChars = 300
+)
+
+// secretPatterns match credentials that may end up in error texts, such as
+// keys echoed back in provider responses or embedded in request URLs
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)bearer\s+[^\s"',]+`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|key|token|secret)["']?\s*[=:]\s*["']?)[^\s"'&,}]+`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{8,}`),
+	regexp.MustCompile(`\d{6,}:[A-Za-z0-9_-]{30,}`), // Telegram bot tokens, also inside /bot<token>/ URLs
+}
+
+// errorEntry is one failure recorded for /errors
+type errorEntry struct {
+	Time    time.Time
+	ChatID  int64
+	UserID  int64
+	Model   string
+	Kind    string
+	Message string
+}
+
+// ErrorLog keeps the most recent errors of the handler and AI paths in a ring
+// buffer, so admins can triage "the bot isn't answering" from Telegram
+type ErrorLog struct {
+	mu      sync.Mutex
+	entries []errorEntry
+	next    int
+	full    bool
+}
+
+// NewErrorLog creates an error log holding the last size errors
+func NewErrorLog(size int) *ErrorLog {
+	if size <= 0 {
+		size = defaultErrorLogSize
+	}
+	return &ErrorLog{entries: make([]errorEntry, size)}
+}
+
+// Record adds an entry, overwriting the oldest one once the log is full
+func (l *ErrorLog) Record(entry errorEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Recent returns up to n entries, newest first
+func (l *ErrorLog) Recent(n int) []errorEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	
+	count := l.next
+	if l.full {
+		count = len(l.entries)
+	}
+	n = min(n, count)
+	
+	recent := make([]errorEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return recent
+}
+
+// errorKind classifies an error for the log
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ai.ErrContentFiltered):
+		return "content_filtered"
+	case errors.Is(err, ai.ErrEndpointRateLimited):
+		return "endpoint_rate_limited"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	if isTelegramError(err) {
+		return "telegram"
+	}
+	return "error"
+}
+
+// isTelegramError reports whether err came back from the Telegram API
+func isTelegramError(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr)
+}
+
+// redactSecrets removes credentials from an error text: the given secrets
+// verbatim and anything that looks like a key or token
+func redactSecrets(text string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, "[REDACTED]")
+		}
+	}
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if prefix := pattern.FindStringSubmatch(match); len(prefix) > 1 {
+				return prefix[1] + "[REDACTED]"
+			}
+			return "[REDACTED]"
+		})
+	}
+	return text
+}
+
+// recordError adds a failure of the message pipeline to the error log
+func (h *MessageHandler) recordError(chatID int64, userID int64, model string, err error) {
+	if h.errorLog == nil || err == nil {
+		return
+	}
+	h.errorLog.Record(errorEntry{
+		Time:    time.Now(),
+		ChatID:  chatID,
+		UserID:  userID,
+		Model:   model,
+		Kind:    errorKind(err),
+		Message: truncateRunes(redactSecrets(err.Error(), h.config.Bot.Token), errorMessageChars),
+	})
+}
+
+// handleErrors handles /errors command, showing the most recent errors (admin only).
+// Usage: /errors [count]
+func (h *CommandHandler) handleErrors(ctx context.Context, chatID int64, userID int64, args string) error {
+	if !h.isAdmin(userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 该命令仅限管理员使用"))
+		return err
+	}
+	
+	count := defaultErrorsShown
+	if args = strings.TrimSpace(args); args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "用法：/errors [条数]"))
+			return err
+		}
+		count = n
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, formatErrors(h.errorLog.Recent(count), h.config.Bot.Token)))
+	return err
+}
+
+// formatErrors renders the logged errors as plain text. Entries are redacted
+// when recorded; doing it again here keeps secrets out of the chat even if
+// something was recorded unredacted.
+func formatErrors(entries []errorEntry, secrets ...string) string {
+	if len(entries) == 0 {
+		return "✅ 最近没有错误记录"
+	}
+	
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("🚨 最近 %d 条错误\n", len(entries)))
+	for _, entry := range entries {
+		b.WriteString(fmt.Sprintf("\n%s [%s]\n", entry.Time.Format("01-02 15:04:05"), entry.Kind))
+		b.WriteString(fmt.Sprintf("聊天：%d 用户：%d", entry.ChatID, entry.UserID))
+		if entry.Model != "" {
+			b.WriteString(" 模型：" + entry.Model)
+		}
+		b.WriteString("\n" + redactSecrets(entry.Message, secrets...) + "\n")
+	}
+	return truncateRunes(b.String(), maxLastRequestChars)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loggedMessages returns the messages of entries, in order
+func loggedMessages(entries []errorEntry) []string {
+	messages := make([]string, len(entries))
+	for i, entry := range entries {
+		messages[i] = entry.Message
+	}
+	return messages
+}
+
+func TestErrorLogRotation(t *testing.T) {
+	log := NewErrorLog(3)
+	if got := log.Recent(10); len(got) != 0 {
+		t.Fatalf("empty log returned %v", got)
+	}
+	
+	for i := 1; i <= 2; i++ {
+		log.Record(errorEntry{Message: fmt.Sprintf("error %d", i)})
+	}
+	if got := strings.Join(loggedMessages(log.Recent(10)), ","); got != "error 2,error 1" {
+		t.Errorf("before rotating Recent = %s, want newest first", got)
+	}
+	
+	// Older entries are overwritten once the log is full
+	for i := 3; i <= 7; i++ {
+		log.Record(errorEntry{Message: fmt.Sprintf("error %d", i)})
+	}
+	if got := strings.Join(loggedMessages(log.Recent(10)), ","); got != "error 7,error 6,error 5" {
+		t.Errorf("after rotating Recent = %s, want the last 3", got)
+	}
+	if got := strings.Join(loggedMessages(log.Recent(2)), ","); got != "error 7,error 6" {
+		t.Errorf("Recent(2) = %s", got)
+	}
+	
+	if got := len(NewErrorLog(0).entries); got != defaultErrorLogSize {
+		t.Errorf("unset size keeps %d entries, want %d", got, defaultErrorLogSize)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	const botToken = "123456789:AAAbbbCCCdddEEEfffGGGhhhIIIjjjKKKlll"
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		secrets []string
+	}{
+		{
+			name: "bearer header",
+			text: "request failed: Authorization: Bearer abc.def",
+			want: "request failed: Authorization: [REDACTED]",
+		},
+		{
+			name: "key parameter",
+			text: `GET https://api.example.com/v1?api_key=secret123&x=1`,
+			want: `GET https://api.example.com/v1?api_key=[REDACTED]&x=1`,
+		},
+		{
+			name: "key in JSON",
+			text: `{"error":"bad key","token": "tok-999"}`,
+			want: `{"error":"bad key","token": "[REDACTED]"}`,
+		},
+		{
+			name: "provider key",
+			text: "401: Incorrect API key provided: sk-proj-abcdef123456",
+			want: "401: Incorrect API key provided: [REDACTED]",
+		},
+		{
+			name: "bot token",
+			text: "Post https://api.telegram.org/bot" + botToken + "/sendMessage: timeout",
+			want: "Post https://api.telegram.org/bot[REDACTED]/sendMessage: timeout",
+		},
+		{
+			name:    "given secret",
+			text:    "failed with hunter2",
+			want:    "failed with [REDACTED]",
+			secrets: []string{"hunter2"},
+		},
+		{
+			name: "nothing secret",
+			text: "context deadline exceeded",
+			want: "context deadline exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactSecrets(tt.text, tt.secrets...); got != tt.want {
+				t.Errorf("redactSecrets = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatErrorsRedacts(t *testing.T) {
+	entries := []errorEntry{{
+		Time:    time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		ChatID:  42,
+		UserID:  7,
+		Model:   "gpt-4o",
+		Kind:    "error",
+		Message: "401: key sk-abcdefgh12345 rejected, bot token s3cret",
+	}}
+	
+	got := formatErrors(entries, "s3cret")
+	for _, want := range []string{"05-06 07:08:09 [error]", "聊天：42 用户：7 模型：gpt-4o", "401: key [REDACTED] rejected"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatErrors = %q, missing %q", got, want)
+		}
+	}
+	for _, secret := range []string{"sk-abcdefgh12345", "s3cret"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("formatErrors = %q shows %q", got, secret)
+		}
+	}
+	
+	if got := formatErrors(nil); got != "✅ 最近没有错误记录" {
+		t.Errorf("formatErrors without entries = %q", got)
+	}
+}
+
+func TestErrorsCommand(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Bot.AdminIDs = []int64{7}
+	cfg.Bot.Token = "bot-secret-token"
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	h.recordError(-100, 8, "gpt-4o", errors.New("upstream echoed bot-secret-token and Bearer xyz"))
+	h.recordError(-100, 9, "", errors.New("second failure"))
+	
+	runCommand(t, c, 42, 7, "/errors")
+	text := lastText(telegram.texts("sendMessage", 42))
+	if !strings.Contains(text, "最近 2 条错误") || !strings.Contains(text, "用户：8 模型：gpt-4o") {
+		t.Errorf("/errors answered %q", text)
+	}
+	if strings.Contains(text, "bot-secret-token") || strings.Contains(text, "xyz") {
+		t.Errorf("/errors shows a secret: %q", text)
+	}
+	// The newest error comes first
+	if strings.Index(text, "second failure") > strings.Index(text, "用户：8") {
+		t.Errorf("/errors lists %q oldest first", text)
+	}
+	
+	runCommand(t, c, 42, 7, "/errors 1")
+	if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "最近 1 条错误") {
+		t.Errorf("/errors 1 answered %q", text)
+	}
+	runCommand(t, c, 42, 7, "/errors -1")
+	if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "用法：/errors") {
+		t.Errorf("/errors -1 answered %q", text)
+	}
+	
+	// Only admins may look
+	runCommand(t, c, 43, 8, "/errors")
+	if text := lastText(telegram.texts("sendMessage", 43)); !strings.Contains(text, "仅限管理员") {
+		t.Errorf("non-admin got %q", text)
+	}
+}
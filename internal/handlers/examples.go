@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultExamplesPerPage is used when context.examples_per_page is unset
+const defaultExamplesPerPage = 4
+
+// examplesFor returns the example prompts for a language, falling back to the
+// default language like followUpsFor
+func examplesFor(cfg *config.Config, lang string) []string {
+	if examples, ok := cfg.Context.Examples[strings.ToLower(lang)]; ok {
+		return examples
+	}
+	return cfg.Context.Examples[strings.ToLower(cfg.I18n.DefaultLanguage)]
+}
+
+// examplesPerPage returns how many examples are shown at once
+func examplesPerPage(cfg *config.Config) int {
+	if cfg.Context.ExamplesPerPage > 0 {
+		return cfg.Context.ExamplesPerPage
+	}
+	return defaultExamplesPerPage
+}
+
+// exampleKeyboard shows one page of examples, one per row. The callbacks carry
+// the language the examples were listed in, so a tap resolves against the same
+// list whoever presses it; "more" cycles through the pages.
+func exampleKeyboard(examples []string, lang string, page int, perPage int, moreLabel string) tgbotapi.InlineKeyboardMarkup {
+	pages := (len(examples) + perPage - 1) / perPage
+	if pages == 0 {
+		return tgbotapi.NewInlineKeyboardMarkup()
+	}
+	page = ((page % pages) + pages) % pages
+	
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, perPage+1)
+	end := min((page+1)*perPage, len(examples))
+	for i := page * perPage; i < end; i++ {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(examples[i], indexedCallbackData("example:"+lang, i, examples)),
+		))
+	}
+	
+	if pages > 1 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 "+moreLabel, fmt.Sprintf("example:%s:page:%d", lang, page+1)),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleExamples handles /examples, showing the first page of example prompts
+func (h *CommandHandler) handleExamples(ctx context.Context, chatID int64, lang string) error {
+	examples := examplesFor(h.config, lang)
+	if len(examples) == 0 {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.localizer.Get(lang, i18n.MsgExamplesEmpty, nil)))
+		return err
+	}
+	
+	msg := tgbotapi.NewMessage(chatID, h.localizer.Get(lang, i18n.MsgExamples, nil))
+	msg.ReplyMarkup = exampleKeyboard(examples, lang, 0, examplesPerPage(h.config), h.localizer.Get(lang, "button.more_examples", nil))
+	
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// HandleExampleCallback handles "example:<lang>:page:<n>", showing another
+// page of examples, and "example:<lang>:<index>:<version>", which sends the
+// example through the normal pipeline as if the user had typed it
+func (h *MessageHandler) HandleExampleCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) error {
+	if callback.Message == nil {
+		return nil
+	}
+	chatID := callback.Message.Chat.ID
+	userID := callback.From.ID
+	
+	exampleLang, arg, _ := strings.Cut(strings.TrimPrefix(callback.Data, "example:"), ":")
+	examples := examplesFor(h.config, exampleLang)
+	
+	if pageStr, ok := strings.CutPrefix(arg, "page:"); ok {
+		page, _ := strconv.Atoi(pageStr)
+		keyboard := exampleKeyboard(examples, exampleLang, page, examplesPerPage(h.config), h.localizer.Get(exampleLang, "button.more_examples", nil))
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		_, err := h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, keyboard))
+		return err
+	}
+	
+	index, ok := parseIndexedCallback(arg, examples)
+	if !ok {
+		// The examples changed since the menu was sent, show the current ones
+		h.bot.Request(tgbotapi.NewCallbackWithAlert(callback.ID, h.localizer.Get(exampleLang, "error.menu_expired", nil)))
+		keyboard := exampleKeyboard(examples, exampleLang, 0, examplesPerPage(h.config), h.localizer.Get(exampleLang, "button.more_examples", nil))
+		_, err := h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, keyboard))
+		return err
+	}
+	example := examples[index]
+	
+	lang := h.getUserLanguage(ctx, chatID)
+	if settings, err := h.storage.GetSettings(ctx, chatID); err == nil && settings != nil && settings.Paused {
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, "⏸ 机器人已在本聊天暂停"))
+		return nil
+	}
+	
+	if h.rateLimiter.IsLockedOut(userID) || !h.rateLimiter.Allow(userID) {
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, h.localizer.Get(lang, i18n.MsgRateLimitExceeded, nil)))
+		return nil
+	}
+	h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+	
+	// Show what was asked, the answer replies to it like to a typed message
+	asked, err := h.bot.Send(tgbotapi.NewMessage(chatID, "💬 "+example))
+	if err != nil {
+		if h.handleSendFailure(ctx, chatID, err) {
+			return nil
+		}
+		return err
+	}
+	
+	thinkingMsg := tgbotapi.NewMessage(chatID, h.localizer.Get(lang, i18n.MsgProcessing, nil))
+	thinkingMsg.ReplyToMessageID = asked.MessageID
+	sentMsg, err := h.bot.Send(thinkingMsg)
+	if err != nil {
+		if h.handleSendFailure(ctx, chatID, err) {
+			return nil
+		}
+		return err
+	}
+	
+	update := &tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			MessageID: asked.MessageID,
+			From:      callback.From,
+			Chat:      callback.Message.Chat,
+			Text:      example,
+		},
+	}
+	if !h.workers.trySubmit(func() { h.processMessage(ctx, update, sentMsg.MessageID, lang) }) {
+		h.logger.WithField("chatID", chatID).Warn("Processing queue full, refusing example")
+		h.metrics.RecordRequestShed()
+		h.sendErrorMessage(chatID, sentMsg.MessageID, lang, i18n.MsgBusy)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+var testExamples = map[string][]string{
+	"zh-cn": {"介绍一下上海", "写一首诗", "解释量子计算"},
+	"en-us": {"Tell me about Shanghai", "Write a poem"},
+}
+
+// keyboardButtons returns the "text=data" of every button of a reply markup
+func keyboardButtons(t *testing.T, markup string) []string {
+	t.Helper()
+	var keyboard struct {
+		InlineKeyboard [][]struct {
+			Text         string `json:"text"`
+			CallbackData string `json:"callback_data"`
+		} `json:"inline_keyboard"`
+	}
+	if err := json.Unmarshal([]byte(markup), &keyboard); err != nil {
+		t.Fatalf("reply markup %q: %v", markup, err)
+	}
+	var buttons []string
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			buttons = append(buttons, button.Text+"="+button.CallbackData)
+		}
+	}
+	return buttons
+}
+
+func TestExamplesFor(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.Examples = testExamples
+	
+	if got := examplesFor(cfg, "en-US"); !reflect.DeepEqual(got, testExamples["en-us"]) {
+		t.Errorf("English examples %q", got)
+	}
+	// Languages without examples get the default language's
+	if got := examplesFor(cfg, "ja-JP"); !reflect.DeepEqual(got, testExamples["zh-cn"]) {
+		t.Errorf("Japanese examples %q, want the Chinese ones", got)
+	}
+}
+
+func TestExampleKeyboardPages(t *testing.T) {
+	examples := testExamples["zh-cn"]
+	zh := listVersion(examples)
+	
+	tests := []struct {
+		page int
+		want []string
+	}{
+		{page: 0, want: []string{"介绍一下上海=example:zh-CN:0:" + zh, "写一首诗=example:zh-CN:1:" + zh, "🔄 换一批=example:zh-CN:page:1"}},
+		{page: 1, want: []string{"解释量子计算=example:zh-CN:2:" + zh, "🔄 换一批=example:zh-CN:page:2"}},
+		// Paging wraps around to the first page
+		{page: 2, want: []string{"介绍一下上海=example:zh-CN:0:" + zh, "写一首诗=example:zh-CN:1:" + zh, "🔄 换一批=example:zh-CN:page:1"}},
+	}
+	for _, tt := range tests {
+		keyboard := exampleKeyboard(examples, "zh-CN", tt.page, 2, "换一批")
+		markup, _ := json.Marshal(keyboard)
+		if got := keyboardButtons(t, string(markup)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("page %d = %q, want %q", tt.page, got, tt.want)
+		}
+	}
+	
+	// A single page has no "more" button
+	keyboard := exampleKeyboard(examples, "zh-CN", 0, 4, "换一批")
+	if len(keyboard.InlineKeyboard) != 3 {
+		t.Errorf("single page has %d rows, want the 3 examples", len(keyboard.InlineKeyboard))
+	}
+}
+
+func TestExamplesCommand(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.I18n.Languages = []string{"zh-CN", "en-US"}
+	cfg.Context.Examples = testExamples
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	c := newTestCommandHandler(h)
+	if err := h.storage.SaveUserSettings(context.Background(), 43, &models.UserSettings{UserID: 43, Language: "en-US"}); err != nil {
+		t.Fatalf("SaveUserSettings: %v", err)
+	}
+	
+	runCommand(t, c, 42, 42, "/examples")
+	runCommand(t, c, 43, 43, "/examples")
+	
+	tests := []struct {
+		chatID   int64
+		wantText string
+		want     []string
+	}{
+		{chatID: 42, wantText: "试试下面的示例", want: testExamples["zh-cn"]},
+		{chatID: 43, wantText: "Try one of these examples", want: testExamples["en-us"]},
+	}
+	for _, tt := range tests {
+		var sent []string
+		for _, params := range telegram.requests("sendMessage") {
+			if params.Get("chat_id") == strconv.FormatInt(tt.chatID, 10) {
+				sent = append(sent, params.Get("text"))
+				var examples []string
+				for _, button := range keyboardButtons(t, params.Get("reply_markup")) {
+					examples = append(examples, strings.SplitN(button, "=", 2)[0])
+				}
+				if !reflect.DeepEqual(examples, tt.want) {
+					t.Errorf("chat %d offered %q, want %q", tt.chatID, examples, tt.want)
+				}
+			}
+		}
+		if len(sent) != 1 || !strings.Contains(sent[0], tt.wantText) {
+			t.Errorf("chat %d got %q, want %q", tt.chatID, sent, tt.wantText)
+		}
+	}
+	
+	// Without examples the command says so
+	cfg.Context.Examples = nil
+	runCommand(t, c, 42, 42, "/examples")
+	if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "暂时没有可用的示例") {
+		t.Errorf("/examples without examples answered %q", text)
+	}
+}
+
+func TestExampleDispatch(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.Examples = testExamples
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer to " + messages[len(messages)-1].Content, nil
+	}}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	
+	data := indexedCallbackData("example:zh-CN", 1, testExamples["zh-cn"])
+	if err := h.HandleExampleCallback(context.Background(), configCallback(42, 7, data)); err != nil {
+		t.Fatalf("HandleExampleCallback: %v", err)
+	}
+	waitForWorkers(t, h)
+	
+	// The example goes to the model as the user's message...
+	if service.requestCount() != 1 {
+		t.Fatalf("AI got %d requests, want 1", service.requestCount())
+	}
+	request := service.requests[0]
+	if last := request[len(request)-1]; last.Role != "user" || last.Content != "写一首诗" {
+		t.Errorf("model asked %+v, want the example from the user", last)
+	}
+	// ...shown in the chat and answered like a typed message
+	if sent := telegram.texts("sendMessage", 42); len(sent) == 0 || sent[0] != "💬 写一首诗" {
+		t.Errorf("chat shows %q, want the example first", sent)
+	}
+	if text := lastReply(t, telegram, 42); !strings.Contains(text, "answer to 写一首诗") {
+		t.Errorf("reply %q, want the answer to the example", text)
+	}
+	chatCtx, _ := h.storage.GetContext(context.Background(), 42)
+	if chatCtx == nil || len(chatCtx.Messages) < 2 || chatCtx.Messages[len(chatCtx.Messages)-2].Content != "写一首诗" {
+		t.Errorf("stored context %+v, want the example and its answer", chatCtx)
+	}
+	
+	// A tap on examples that changed since is refused
+	stale := indexedCallbackData("example:zh-CN", 1, []string{"old", "examples"})
+	if err := h.HandleExampleCallback(context.Background(), configCallback(42, 7, stale)); err != nil {
+		t.Fatalf("HandleExampleCallback: %v", err)
+	}
+	waitForWorkers(t, h)
+	if service.requestCount() != 1 {
+		t.Errorf("stale example reached the AI")
+	}
+	if !answeredExpired(telegram) {
+		t.Error("stale example not answered with the expired menu notice")
+	}
+}
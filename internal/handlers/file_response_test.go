@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestFileResponseThreshold(t *testing.T) {
+	tests := []struct {
+		name   string
+		global int
+		chat   int
+		want   int
+	}{
+		{name: "off", want: 0},
+		{name: "global", global: 3000, want: 3000},
+		{name: "chat overrides global", global: 3000, chat: 500, want: 500},
+		{name: "chat turned off", global: 3000, chat: -1, want: 0},
+		{name: "chat without global", chat: 500, want: 500},
+		{name: "negative global", global: -5, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Context.FileResponseChars = tt.global
+			settings := &models.ChatSettings{FileResponseChars: tt.chat}
+			if got := fileResponseThreshold(cfg, settings); got != tt.want {
+				t.Errorf("fileResponseThreshold = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldSendAsFile(t *testing.T) {
+	tests := []struct {
+		response  string
+		threshold int
+		want      bool
+	}{
+		{response: strings.Repeat("a", 100), threshold: 0, want: false},
+		{response: strings.Repeat("a", 100), threshold: 100, want: false},
+		{response: strings.Repeat("a", 101), threshold: 100, want: true},
+		// Characters are counted, not bytes
+		{response: strings.Repeat("字", 100), threshold: 100, want: false},
+	}
+	for _, tt := range tests {
+		if got := shouldSendAsFile(tt.response, tt.threshold); got != tt.want {
+			t.Errorf("shouldSendAsFile(%d chars, %d) = %v, want %v", len([]rune(tt.response)), tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestNewResponseDocument(t *testing.T) {
+	doc := newResponseDocument(42, "# Title\n\ncontent")
+	
+	if doc.ChatID != 42 {
+		t.Errorf("document goes to chat %d, want 42", doc.ChatID)
+	}
+	file, ok := doc.File.(tgbotapi.FileBytes)
+	if !ok {
+		t.Fatalf("document file is %T, want FileBytes", doc.File)
+	}
+	if !regexp.MustCompile(`^response-\d{8}-\d{6}\.md$`).MatchString(file.Name) {
+		t.Errorf("file name %q, want response-<time>.md", file.Name)
+	}
+	if string(file.Bytes) != "# Title\n\ncontent" {
+		t.Errorf("file content %q, want the response", file.Bytes)
+	}
+}
+
+func TestLongResponseSentAsFile(t *testing.T) {
+	long := strings.Repeat("很长的回答。", 50)
+	tests := []struct {
+		name     string
+		response string
+		wantFile bool
+	}{
+		{name: "long", response: long, wantFile: true},
+		{name: "short", response: "短回答。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Context.FileResponseChars = 100
+			service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+				return tt.response, nil
+			}}
+			h, telegram := newTestMessageHandler(t, cfg, service)
+			
+			handleAndWait(t, h, privateMessage(42, 7, 1, "question"))
+			
+			documents := telegram.requests("sendDocument")
+			answer := lastText(telegram.texts("editMessageText", 42))
+			if !tt.wantFile {
+				if len(documents) != 0 || answer != tt.response {
+					t.Errorf("sent %d documents and answered %q, want the answer as a message", len(documents), answer)
+				}
+				return
+			}
+			if len(documents) != 1 {
+				t.Fatalf("sent %d documents, want 1", len(documents))
+			}
+			if got := documents[0].Get("document"); got != tt.response {
+				t.Errorf("document holds %q, want the whole answer", got)
+			}
+			// The chat gets a short preview instead
+			preview := string([]rune(tt.response)[:filePreviewChars]) + "…"
+			if !strings.Contains(answer, "回复较长（300 字）") || !strings.HasSuffix(answer, preview) {
+				t.Errorf("summary %q, want the length and a %d character preview", answer, filePreviewChars)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// followUpsFor returns the suggested follow-ups for a language, falling back to
+// the default language. Viper lower-cases map keys, so lookups are lower-case too.
+func followUpsFor(cfg *config.Config, lang string) []config.FollowUpConfig {
+	if followUps, ok := cfg.Context.FollowUps[strings.ToLower(lang)]; ok {
+		return followUps
+	}
+	return cfg.Context.FollowUps[strings.ToLower(cfg.I18n.DefaultLanguage)]
+}
+
+// followUpKeyboard returns one button per suggested follow-up, two per row
+func followUpKeyboard(followUps []config.FollowUpConfig) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, (len(followUps)+1)/2)
+	for i, followUp := range followUps {
+		button := tgbotapi.NewInlineKeyboardButtonData(followUp.Label, fmt.Sprintf("followup:%d", i))
+		if i%2 == 0 {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+		} else {
+			rows[len(rows)-1] = append(rows[len(rows)-1], button)
+		}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// attachFollowUps adds the suggested follow-up buttons below an answer
+func (h *MessageHandler) attachFollowUps(chatID int64, messageID int, lang string) {
+	followUps := followUpsFor(h.config, lang)
+	if len(followUps) == 0 {
+		return
+	}
+	
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, followUpKeyboard(followUps))
+	if _, err := h.bot.Send(edit); err != nil {
+		h.logger.WithError(err).Debug("Failed to attach follow-up buttons")
+	}
+}
+
+// resolveFollowUp maps the callback data of a follow-up button to its instruction
+func resolveFollowUp(followUps []config.FollowUpConfig, data string) (config.FollowUpConfig, bool) {
+	index, err := strconv.Atoi(strings.TrimPrefix(data, "followup:"))
+	if err != nil || index < 0 || index >= len(followUps) {
+		return config.FollowUpConfig{}, false
+	}
+	return followUps[index], true
+}
+
+// HandleFollowUpCallback re-asks the model with the instruction of the pressed
+// follow-up button, as if the user had sent it as a message
+func (h *MessageHandler) HandleFollowUpCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) error {
+	if callback.Message == nil {
+		return nil
+	}
+	chatID := callback.Message.Chat.ID
+	userID := callback.From.ID
+	lang := h.getUserLanguage(ctx, chatID)
+	
+	if settings, err := h.storage.GetSettings(ctx, chatID); err == nil && settings != nil && settings.Paused {
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, "⏸ 机器人已在本聊天暂停"))
+		return nil
+	}
+	
+	followUp, ok := resolveFollowUp(followUpsFor(h.config, lang), callback.Data)
+	if !ok {
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, "❌ 该选项已失效"))
+		return nil
+	}
+	
+	if h.rateLimiter.IsLockedOut(userID) || !h.rateLimiter.Allow(userID) {
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, h.localizer.Get(lang, i18n.MsgRateLimitExceeded, nil)))
+		return nil
+	}
+	h.bot.Request(tgbotapi.NewCallback(callback.ID, followUp.Label))
+	
+	// Each answer can be followed up once; the new answer brings its own buttons
+	h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	}))
+	
+	thinkingMsg := tgbotapi.NewMessage(chatID, h.localizer.Get(lang, i18n.MsgProcessing, nil))
+	thinkingMsg.ReplyToMessageID = callback.Message.MessageID
+	sentMsg, err := h.bot.Send(thinkingMsg)
+	if err != nil {
+		if h.handleSendFailure(ctx, chatID, err) {
+			return nil
+		}
+		return err
+	}
+	
+	// Run the instruction through the normal pipeline; the callback stays on the
+	// update so processMessage knows it is a follow-up
+	update := &tgbotapi.Update{
+		CallbackQuery: callback,
+		Message: &tgbotapi.Message{
+			MessageID: callback.Message.MessageID,
+			From:      callback.From,
+			Chat:      callback.Message.Chat,
+			Text:      followUp.Prompt,
+		},
+	}
+	if !h.workers.trySubmit(func() { h.processMessage(ctx, update, sentMsg.MessageID, lang) }) {
+		h.logger.WithField("chatID", chatID).Warn("Processing queue full, refusing follow-up")
+		h.metrics.RecordRequestShed()
+		h.sendErrorMessage(chatID, sentMsg.MessageID, lang, i18n.MsgBusy)
+	}
+	return nil
+}
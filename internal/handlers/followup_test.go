@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// testFollowUps are the Chinese follow-up suggestions of the tests
+var testFollowUps = []config.FollowUpConfig{
+	{Label: "解释更详细", Prompt: "请更详细地解释上面的回答"},
+	{Label: "给个例子", Prompt: "请举一个例子"},
+	{Label: "翻译成英文", Prompt: "请把上面的回答翻译成英文"},
+}
+
+func TestResolveFollowUp(t *testing.T) {
+	tests := []struct {
+		data   string
+		want   string
+		wantOK bool
+	}{
+		{data: "followup:0", want: "请更详细地解释上面的回答", wantOK: true},
+		{data: "followup:2", want: "请把上面的回答翻译成英文", wantOK: true},
+		{data: "followup:3"},
+		{data: "followup:-1"},
+		{data: "followup:more"},
+	}
+	for _, tt := range tests {
+		followUp, ok := resolveFollowUp(testFollowUps, tt.data)
+		if ok != tt.wantOK || followUp.Prompt != tt.want {
+			t.Errorf("resolveFollowUp(%s) = %q, %v, want %q, %v", tt.data, followUp.Prompt, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestFollowUpsFor(t *testing.T) {
+	cfg := newTestConfig()
+	english := []config.FollowUpConfig{{Label: "More detail", Prompt: "Explain in more detail"}}
+	cfg.Context.FollowUps = map[string][]config.FollowUpConfig{"zh-cn": testFollowUps, "en-us": english}
+	
+	if got := followUpsFor(cfg, "en-US"); len(got) != 1 || got[0].Label != "More detail" {
+		t.Errorf("English follow-ups = %+v", got)
+	}
+	// Languages without their own fall back to the default language's
+	if got := followUpsFor(cfg, "ja"); len(got) != len(testFollowUps) || got[0].Label != "解释更详细" {
+		t.Errorf("follow-ups without a translation = %+v, want the default language's", got)
+	}
+}
+
+func TestFollowUpButton(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.FollowUps = map[string][]config.FollowUpConfig{"zh-cn": testFollowUps}
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer to " + messages[len(messages)-1].Content, nil
+	}}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "什么是 Go"))
+	
+	// The answer gets one button per follow-up
+	markups := telegram.requests("editMessageReplyMarkup")
+	if len(markups) != 1 {
+		t.Fatalf("got %d keyboards, want the follow-ups below the answer", len(markups))
+	}
+	var keyboard struct {
+		InlineKeyboard [][]struct {
+			Text         string `json:"text"`
+			CallbackData string `json:"callback_data"`
+		} `json:"inline_keyboard"`
+	}
+	json.Unmarshal([]byte(markups[0].Get("reply_markup")), &keyboard)
+	var buttons []string
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			buttons = append(buttons, button.Text+"="+button.CallbackData)
+		}
+	}
+	if want := "解释更详细=followup:0|给个例子=followup:1|翻译成英文=followup:2"; strings.Join(buttons, "|") != want {
+		t.Errorf("buttons %q, want %q", buttons, want)
+	}
+	answerID, _ := strconv.Atoi(markups[0].Get("message_id"))
+	
+	// Pressing one asks the model the instruction in the same conversation
+	callback := configCallback(42, 7, "followup:1")
+	callback.Message.MessageID = answerID
+	if err := h.HandleFollowUpCallback(context.Background(), callback); err != nil {
+		t.Fatalf("HandleFollowUpCallback: %v", err)
+	}
+	waitForWorkers(t, h)
+	
+	if service.requestCount() != 2 {
+		t.Fatalf("AI got %d requests, want 2", service.requestCount())
+	}
+	// The pressed answer loses its buttons, the new answer brings its own
+	markups = telegram.requests("editMessageReplyMarkup")
+	if len(markups) != 3 || markups[1].Get("message_id") != strconv.Itoa(answerID) || strings.Contains(markups[1].Get("reply_markup"), "followup:") {
+		t.Errorf("keyboard edits %v, want the answer's buttons removed and new ones attached", markups)
+	}
+	request := service.requests[1]
+	var texts []string
+	for _, msg := range request {
+		texts = append(texts, msg.Role+":"+msg.Content)
+	}
+	if want := "user:什么是 Go|assistant:answer to 什么是 Go|user:请举一个例子"; !strings.HasSuffix(strings.Join(texts, "|"), want) {
+		t.Errorf("follow-up request %q, want it to end with %q", texts, want)
+	}
+	if reply := lastText(telegram.texts("editMessageText", 42)); !strings.Contains(reply, "answer to 请举一个例子") {
+		t.Errorf("follow-up answered %q", reply)
+	}
+	// A button of an older configuration no longer maps to an instruction
+	if err := h.HandleFollowUpCallback(context.Background(), configCallback(42, 7, "followup:5")); err != nil {
+		t.Fatalf("HandleFollowUpCallback: %v", err)
+	}
+	answers := telegram.requests("answerCallbackQuery")
+	if text := answers[len(answers)-1].Get("text"); !strings.Contains(text, "已失效") {
+		t.Errorf("stale button answered %q", text)
+	}
+	if service.requestCount() != 2 {
+		t.Errorf("stale button reached the AI")
+	}
+}
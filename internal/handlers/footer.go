@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// appendFooter adds the footer below the response, separated by a blank line.
+// Responses are cached without the footer, so it is appended exactly once
+// whether the response is fresh or served from the cache.
+func appendFooter(response, footer string) string {
+	footer = strings.TrimSpace(footer)
+	if footer == "" {
+		return response
+	}
+	return response + "\n\n" + footer
+}
+
+// responseFooter returns the footer for responses in a chat, empty when the
+// chat turned it off
+func (h *MessageHandler) responseFooter(settings *models.ChatSettings) string {
+	if settings.HideFooter {
+		return ""
+	}
+	return h.config.Context.ResponseFooter
+}
+
+// handleFooter handles /footer command, turning the response footer on or off for the chat
+func (h *CommandHandler) handleFooter(ctx context.Context, chatID int64, args string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.describeFooter(settings)+
+			"\n\n用法：/footer on | off"))
+		return err
+	case "on":
+		settings.HideFooter = false
+	case "off":
+		settings.HideFooter = true
+	default:
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请输入 on 或 off"))
+		return err
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ "+h.describeFooter(settings)))
+	return err
+}
+
+// describeFooter describes whether responses in a chat carry the footer
+func (h *CommandHandler) describeFooter(settings *models.ChatSettings) string {
+	if strings.TrimSpace(h.config.Context.ResponseFooter) == "" {
+		return "回复页脚：未配置"
+	}
+	if settings.HideFooter {
+		return "回复页脚：本聊天已关闭"
+	}
+	return "回复页脚：已开启"
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestFooterOnFreshAndCachedResponses(t *testing.T) {
+	const question = "What is the answer to everything?"
+	const footer = "Powered by ExampleCorp"
+	h, telegram, service := newCachingHandler(t, "The answer is 42.")
+	h.config.Context.ResponseFooter = footer
+	saveChatSettings(t, h, 44, func(s *models.ChatSettings) { s.HideFooter = true })
+	
+	tests := []struct {
+		name       string
+		chatID     int64
+		wantFooter int
+	}{
+		{name: "fresh", chatID: 42, wantFooter: 1},
+		{name: "cached", chatID: 43, wantFooter: 1},
+		{name: "cached again", chatID: 42, wantFooter: 1},
+		{name: "turned off", chatID: 44, wantFooter: 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handleAndWait(t, h, privateMessage(tt.chatID, tt.chatID, i+1, question))
+			
+			reply := lastReply(t, telegram, tt.chatID)
+			if !strings.Contains(reply, "The answer is 42.") {
+				t.Errorf("reply %q misses the answer", reply)
+			}
+			if got := strings.Count(reply, footer); got != tt.wantFooter {
+				t.Errorf("reply %q has the footer %d times, want %d", reply, got, tt.wantFooter)
+			}
+		})
+	}
+	
+	if n := service.requestCount(); n != 1 {
+		t.Errorf("AI service got %d requests, want 1 with the others served from the cache", n)
+	}
+	// Neither the cache nor the context keeps the footer
+	chatCtx, err := h.storage.GetContext(context.Background(), 42)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("no saved context: %v", err)
+	}
+	if cached, _ := h.cache.Get(context.Background(), question, testModel, cacheScope(&chatCtx.Settings, false)); strings.Contains(cached, footer) {
+		t.Errorf("cached %q, want it without the footer", cached)
+	}
+	for _, msg := range chatCtx.Messages {
+		if strings.Contains(msg.Content, footer) {
+			t.Errorf("context message %+v carries the footer", msg)
+		}
+	}
+}
+
+func TestFooterCommand(t *testing.T) {
+	cfg := newTestConfig()
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	runCommand(t, c, 42, 7, "/footer")
+	if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "未配置") {
+		t.Errorf("/footer without a footer answered %q", text)
+	}
+	
+	cfg.Context.ResponseFooter = "Powered by ExampleCorp"
+	steps := []struct {
+		command  string
+		wantText string
+		wantHide bool
+	}{
+		{command: "/footer", wantText: "已开启"},
+		{command: "/footer off", wantText: "本聊天已关闭", wantHide: true},
+		{command: "/footer maybe", wantText: "请输入 on 或 off", wantHide: true},
+		{command: "/footer ON", wantText: "已开启"},
+	}
+	for _, step := range steps {
+		runCommand(t, c, 42, 7, step.command)
+		if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, step.wantText) {
+			t.Errorf("%s answered %q, want %q", step.command, text, step.wantText)
+		}
+		if settings, _ := h.storage.GetSettings(context.Background(), 42); settings != nil && settings.HideFooter != step.wantHide {
+			t.Errorf("after %s HideFooter = %v, want %v", step.command, settings.HideFooter, step.wantHide)
+		}
+	}
+}
+
+func TestAppendFooter(t *testing.T) {
+	if got := appendFooter("answer", "  "); got != "answer" {
+		t.Errorf("blank footer gave %q", got)
+	}
+	if got := appendFooter("answer", " [Example](https://example.com)\n"); got != "answer\n\n[Example](https://example.com)" {
+		t.Errorf("footer appended as %q", got)
+	}
+}
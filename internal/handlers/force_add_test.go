@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+func TestParseTempEndpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   string
+		want    *config.ModelEndpoint
+		wantErr bool
+	}{
+		{
+			name:  "all fields",
+			state: "my-api|My API|https://api.example.com/v1|sk-123",
+			want: &config.ModelEndpoint{
+				Name: "my-api", DisplayName: "My API", BaseURL: "https://api.example.com/v1", APIKey: "sk-123",
+				Models: []config.ModelInfo{},
+			},
+		},
+		{
+			name:  "key containing the separator",
+			state: "my-api|My API|https://api.example.com/v1|sk-a|b",
+			want: &config.ModelEndpoint{
+				Name: "my-api", DisplayName: "My API", BaseURL: "https://api.example.com/v1", APIKey: "sk-a|b",
+				Models: []config.ModelInfo{},
+			},
+		},
+		{name: "missing fields", state: "my-api|My API|https://api.example.com/v1", wantErr: true},
+		{name: "empty", state: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTempEndpoint(tt.state)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTempEndpoint error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTempEndpoint = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForceAddEndpoint(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestConfigHandler(h)
+	// Left behind by an add whose connection test failed
+	c.storage.SetUserState(ctx, 7, "config_action", "adding_endpoint")
+	c.storage.SetUserState(ctx, 7, "temp_endpoint", "untested|Untested|https://untested.example.com/v1|sk-untested")
+	
+	if err := c.HandleConfigCallback(ctx, configCallback(42, 7, "config:force_add:untested")); err != nil {
+		t.Fatalf("HandleConfigCallback: %v", err)
+	}
+	
+	current, err := c.configService.GetCurrentConfig(ctx)
+	if err != nil {
+		t.Fatalf("GetCurrentConfig: %v", err)
+	}
+	var added *config.ModelEndpoint
+	for i := range current.Models.Endpoints {
+		if current.Models.Endpoints[i].Name == "untested" {
+			added = &current.Models.Endpoints[i]
+		}
+	}
+	if added == nil || added.BaseURL != "https://untested.example.com/v1" || added.APIKey != "sk-untested" {
+		t.Fatalf("added endpoint = %+v, want the stored one", added)
+	}
+	if text := lastText(telegram.texts("editMessageText", 42)); !strings.Contains(text, "未通过连接测试") {
+		t.Errorf("confirmation %q, want a warning that the endpoint is untested", text)
+	}
+	for _, key := range []string{"temp_endpoint", "config_action"} {
+		if value, _ := c.storage.GetUserState(ctx, 7, key); value != "" {
+			t.Errorf("%s = %q after the add, want it cleared", key, value)
+		}
+	}
+}
+
+func TestForceAddEndpointRefused(t *testing.T) {
+	tests := []struct {
+		name  string
+		state string // temp_endpoint, empty when expired
+	}{
+		{name: "expired"},
+		{name: "another endpoint", state: "other|Other|https://other.example.com/v1|sk-other"},
+		{name: "corrupt", state: "untested|Untested"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+			c := newTestConfigHandler(h)
+			if tt.state != "" {
+				c.storage.SetUserState(ctx, 7, "temp_endpoint", tt.state)
+			}
+			
+			if err := c.HandleConfigCallback(ctx, configCallback(42, 7, "config:force_add:untested")); err != nil {
+				t.Fatalf("HandleConfigCallback: %v", err)
+			}
+			
+			current, _ := c.configService.GetCurrentConfig(ctx)
+			if len(current.Models.Endpoints) != 1 {
+				t.Errorf("got %d endpoints, want nothing added", len(current.Models.Endpoints))
+			}
+			if edits := telegram.texts("editMessageText", 42); len(edits) != 0 {
+				t.Errorf("edited the message to %q, want only the callback answered", edits)
+			}
+		})
+	}
+}
@@ -5,21 +5,104 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 	
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// lastGreetingKey stores when a chat was last greeted, as a unix timestamp
+const lastGreetingKey = "last_greeting"
+
+// greetingOnCooldown reports whether the chat was greeted within the cooldown,
+// which grows with the group's size tier. When it isn't, now is recorded as the
+// chat's last greeting.
+func (h *MessageHandler) greetingOnCooldown(ctx context.Context, chatID int64) bool {
+	cooldown := h.greetingCooldown(chatID)
+	if cooldown <= 0 {
+		return false
+	}
+	
+	now := time.Now()
+	if value, err := h.storage.GetChatState(ctx, chatID, lastGreetingKey); err == nil && value != "" {
+		if last, err := strconv.ParseInt(value, 10, 64); err == nil && now.Sub(time.Unix(last, 0)) < cooldown {
+			return true
+		}
+	}
+	
+	// Kept for the whole cooldown, which may be longer than a user state lives
+	if err := h.storage.SetChatState(ctx, chatID, lastGreetingKey, strconv.FormatInt(now.Unix(), 10), cooldown); err != nil {
+		h.logger.WithError(err).Warn("Failed to record last greeting")
+	}
+	return false
+}
+
 // addMentionGreeting adds a friendly greeting when triggered by mention word
-func (h *MessageHandler) addMentionGreeting(message, mentionWord string, update *tgbotapi.Update) string {
-	// 获取机器人性格设置
-	personality := h.config.Context.BotPersonality
-	if personality == "" {
-		personality = "cute" // 默认可爱性格
+func (h *MessageHandler) addMentionGreeting(message, mentionWord, chatPersonality string, update *tgbotapi.Update) string {
+	// 获取机器人性格设置，群组设置优先于配置默认值
+	personality := resolvePersonality(h.config, chatPersonality)
+	allGreetings := mentionGreetings(personality, time.Now().Hour())
+	
+	// 获取最近使用的问候语历史
+	var recentGreetings []int
+	recentGreetingsKey := fmt.Sprintf("recent_greetings_%d", update.Message.Chat.ID)
+	recentGreetingsStr, err := h.storage.GetUserState(context.Background(), update.Message.From.ID, recentGreetingsKey)
+	if err == nil && recentGreetingsStr != "" {
+		json.Unmarshal([]byte(recentGreetingsStr), &recentGreetings)
+	}
+	
+	// 创建候选索引列表（排除最近使用的）
+	candidateIndices := []int{}
+	for i := 0; i < len(allGreetings); i++ {
+		isRecent := false
+		for _, recentIdx := range recentGreetings {
+			if i == recentIdx {
+				isRecent = true
+				break
+			}
+		}
+		if !isRecent {
+			candidateIndices = append(candidateIndices, i)
+		}
+	}
+	
+	// 如果所有问候语都最近使用过，清空历史
+	if len(candidateIndices) == 0 {
+		recentGreetings = []int{}
+		for i := 0; i < len(allGreetings); i++ {
+			candidateIndices = append(candidateIndices, i)
+		}
+	}
+	
+	// 随机选择一个问候语
+	rand.Seed(time.Now().UnixNano())
+	selectedIdx := candidateIndices[rand.Intn(len(candidateIndices))]
+	greeting := allGreetings[selectedIdx]
+	
+	// 更新最近使用的问候语历史（保留最近5个）
+	recentGreetings = append(recentGreetings, selectedIdx)
+	if len(recentGreetings) > 5 {
+		recentGreetings = recentGreetings[len(recentGreetings)-5:]
+	}
+	
+	// 保存更新后的历史
+	updatedRecentGreetingsStr, _ := json.Marshal(recentGreetings)
+	h.storage.SetUserState(context.Background(), update.Message.From.ID, recentGreetingsKey, string(updatedRecentGreetingsStr))
+	
+	// If the message only contains the mention word, just return the greeting
+	trimmed := strings.TrimSpace(message)
+	if strings.EqualFold(trimmed, mentionWord) || trimmed == "" {
+		return greeting
 	}
+	
+	// Otherwise, acknowledge the mention and process the message
+	return fmt.Sprintf("%s\n\n关于你的问题：%s", greeting, message)
+}
+
+// mentionGreetings lists the greetings of a personality at the given hour
+func mentionGreetings(personality string, hour int) []string {
 	// 根据时间段和性格选择不同风格的问候
-	hour := time.Now().Hour()
 	var greetings []string
 	
 	if hour >= 5 && hour < 9 {
@@ -247,60 +330,5 @@ func (h *MessageHandler) addMentionGreeting(message, mentionWord string, update
 	}
 	
 	// 合并问候语
-	allGreetings := append(greetings, generalGreetings...)
-	
-	// 获取最近使用的问候语历史
-	var recentGreetings []int
-	recentGreetingsKey := fmt.Sprintf("recent_greetings_%d", update.Message.Chat.ID)
-	recentGreetingsStr, err := h.storage.GetUserState(context.Background(), update.Message.From.ID, recentGreetingsKey)
-	if err == nil && recentGreetingsStr != "" {
-		json.Unmarshal([]byte(recentGreetingsStr), &recentGreetings)
-	}
-	
-	// 创建候选索引列表（排除最近使用的）
-	candidateIndices := []int{}
-	for i := 0; i < len(allGreetings); i++ {
-		isRecent := false
-		for _, recentIdx := range recentGreetings {
-			if i == recentIdx {
-				isRecent = true
-				break
-			}
-		}
-		if !isRecent {
-			candidateIndices = append(candidateIndices, i)
-		}
-	}
-	
-	// 如果所有问候语都最近使用过，清空历史
-	if len(candidateIndices) == 0 {
-		recentGreetings = []int{}
-		for i := 0; i < len(allGreetings); i++ {
-			candidateIndices = append(candidateIndices, i)
-		}
-	}
-	
-	// 随机选择一个问候语
-	rand.Seed(time.Now().UnixNano())
-	selectedIdx := candidateIndices[rand.Intn(len(candidateIndices))]
-	greeting := allGreetings[selectedIdx]
-	
-	// 更新最近使用的问候语历史（保留最近5个）
-	recentGreetings = append(recentGreetings, selectedIdx)
-	if len(recentGreetings) > 5 {
-		recentGreetings = recentGreetings[len(recentGreetings)-5:]
-	}
-	
-	// 保存更新后的历史
-	updatedRecentGreetingsStr, _ := json.Marshal(recentGreetings)
-	h.storage.SetUserState(context.Background(), update.Message.From.ID, recentGreetingsKey, string(updatedRecentGreetingsStr))
-	
-	// If the message only contains the mention word, just return the greeting
-	trimmed := strings.TrimSpace(message)
-	if strings.EqualFold(trimmed, mentionWord) || trimmed == "" {
-		return greeting
-	}
-	
-	// Otherwise, acknowledge the mention and process the message
-	return fmt.Sprintf("%s\n\n关于你的问题：%s", greeting, message)
-}
\ No newline at end of file
+	return append(greetings, generalGreetings...)
+}
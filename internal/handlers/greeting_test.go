@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGreetingOnCooldown(t *testing.T) {
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		lastAgo  time.Duration // 0 when the chat was never greeted
+		want     bool
+	}{
+		{name: "never greeted", cooldown: 3 * time.Hour, want: false},
+		{name: "greeted two hours ago with a longer cooldown", cooldown: 3 * time.Hour, lastAgo: 2 * time.Hour, want: true},
+		{name: "cooldown over", cooldown: 3 * time.Hour, lastAgo: 4 * time.Hour, want: false},
+		{name: "no cooldown", cooldown: 0, lastAgo: time.Minute, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := newTestConfig()
+			cfg.Context.GreetingCooldown = tt.cooldown
+			h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+			if tt.lastAgo > 0 {
+				last := strconv.FormatInt(time.Now().Add(-tt.lastAgo).Unix(), 10)
+				h.storage.SetChatState(ctx, -100, lastGreetingKey, last, 0)
+			}
+			
+			if got := h.greetingOnCooldown(ctx, -100); got != tt.want {
+				t.Errorf("greetingOnCooldown = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGreetingRecordedForCooldown(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig()
+	cfg.Context.GreetingCooldown = 3 * time.Hour
+	h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+	
+	if h.greetingOnCooldown(ctx, -100) {
+		t.Fatal("first greeting is on cooldown")
+	}
+	if !h.greetingOnCooldown(ctx, -100) {
+		t.Error("second greeting isn't on cooldown")
+	}
+	// A user with the chat's ID keeps their own states
+	if value, _ := h.storage.GetUserState(ctx, -100, lastGreetingKey); value != "" {
+		t.Errorf("greeting recorded as a user state %q, want a chat state", value)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultGroupSizeCacheTTL is used when context.group_size_cache_ttl is unset
+const defaultGroupSizeCacheTTL = time.Hour
+
+// groupSizeTier returns the tier for a group of count members: the one with
+// the largest MinMembers not above count. The bool is false when none applies.
+func groupSizeTier(tiers []config.GroupSizeTierConfig, count int) (config.GroupSizeTierConfig, bool) {
+	var best config.GroupSizeTierConfig
+	found := false
+	for _, tier := range tiers {
+		if tier.MinMembers <= count && (!found || tier.MinMembers > best.MinMembers) {
+			best = tier
+			found = true
+		}
+	}
+	return best, found
+}
+
+// memberCount is a cached member count of a group
+type memberCount struct {
+	count   int
+	fetched time.Time
+}
+
+// memberCounter caches the member counts of groups, so size tiers don't cost
+// a Telegram request per message
+type memberCounter struct {
+	mu     sync.Mutex
+	counts map[int64]memberCount
+	fetch  func(chatID int64) (int, error)
+}
+
+// newMemberCounter creates a cache around fetch
+func newMemberCounter(fetch func(chatID int64) (int, error)) *memberCounter {
+	return &memberCounter{
+		counts: make(map[int64]memberCount),
+		fetch:  fetch,
+	}
+}
+
+// get returns the chat's member count, fetching it when the cached one is
+// older than ttl. A failed fetch falls back to the stale count if there is one.
+func (c *memberCounter) get(chatID int64, ttl time.Duration) (int, error) {
+	c.mu.Lock()
+	cached, ok := c.counts[chatID]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetched) < ttl {
+		return cached.count, nil
+	}
+
+	count, err := c.fetch(chatID)
+	if err != nil {
+		if ok {
+			return cached.count, nil
+		}
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.counts[chatID] = memberCount{count: count, fetched: time.Now()}
+	c.mu.Unlock()
+	return count, nil
+}
+
+// telegramMemberCount returns a fetch function asking Telegram for the number
+// of members of a chat
+func telegramMemberCount(bot *tgbotapi.BotAPI) func(chatID int64) (int, error) {
+	return func(chatID int64) (int, error) {
+		return bot.GetChatMembersCount(tgbotapi.ChatMemberCountConfig{
+			ChatConfig: tgbotapi.ChatConfig{ChatID: chatID},
+		})
+	}
+}
+
+// groupTier returns the size tier of a group chat. Without tiers, or when the
+// member count can't be fetched, no tier applies and the bot behaves as usual.
+func (h *MessageHandler) groupTier(chatID int64) (config.GroupSizeTierConfig, bool) {
+	if len(h.config.Context.GroupSizeTiers) == 0 {
+		return config.GroupSizeTierConfig{}, false
+	}
+
+	ttl := h.config.Context.GroupSizeCacheTTL
+	if ttl <= 0 {
+		ttl = defaultGroupSizeCacheTTL
+	}
+	count, err := h.memberCounts.get(chatID, ttl)
+	if err != nil {
+		h.logger.WithError(err).WithField("chatID", chatID).Warn("Failed to get group member count")
+		return config.GroupSizeTierConfig{}, false
+	}
+	return groupSizeTier(h.config.Context.GroupSizeTiers, count)
+}
+
+// skipForGroupSize reports whether a mention word or keyword trigger should go
+// unanswered because of the group's size tier
+func (h *MessageHandler) skipForGroupSize(chatID int64) bool {
+	tier, ok := h.groupTier(chatID)
+	if !ok || tier.SkipRate <= 0 {
+		return false
+	}
+
+	skip := rand.Float64() < tier.SkipRate
+	if skip {
+		h.logger.WithFields(logrus.Fields{
+			"chatID":     chatID,
+			"minMembers": tier.MinMembers,
+			"skipRate":   tier.SkipRate,
+		}).Debug("Not responding: skipped in large group")
+	}
+	return skip
+}
+
+// greetingCooldown returns how long mention greetings pause in a chat: the
+// configured cooldown, or the group size tier's when that is longer
+func (h *MessageHandler) greetingCooldown(chatID int64) time.Duration {
+	cooldown := h.config.Context.GreetingCooldown
+	if tier, ok := h.groupTier(chatID); ok && tier.GreetingCooldown > cooldown {
+		cooldown = tier.GreetingCooldown
+	}
+	return cooldown
+}
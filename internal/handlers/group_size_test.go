@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestGroupSizeTier(t *testing.T) {
+	tiers := []config.GroupSizeTierConfig{
+		{MinMembers: 500, SkipRate: 0.9},
+		{MinMembers: 20, SkipRate: 0.5},
+		{MinMembers: 100, SkipRate: 0.7},
+	}
+	tests := []struct {
+		count    int
+		want     int
+		wantTier bool
+	}{
+		{count: 5},
+		{count: 19},
+		{count: 20, want: 20, wantTier: true},
+		{count: 99, want: 20, wantTier: true},
+		{count: 100, want: 100, wantTier: true},
+		{count: 499, want: 100, wantTier: true},
+		{count: 5000, want: 500, wantTier: true},
+	}
+	for _, tt := range tests {
+		tier, ok := groupSizeTier(tiers, tt.count)
+		if ok != tt.wantTier || tier.MinMembers != tt.want {
+			t.Errorf("groupSizeTier(%d) = %d, %v, want %d, %v", tt.count, tier.MinMembers, ok, tt.want, tt.wantTier)
+		}
+	}
+	if _, ok := groupSizeTier(nil, 1000); ok {
+		t.Error("a tier applies without tiers configured")
+	}
+}
+
+func TestMemberCounterCaches(t *testing.T) {
+	fetches := 0
+	var fail bool
+	counter := newMemberCounter(func(chatID int64) (int, error) {
+		fetches++
+		if fail {
+			return 0, errors.New("telegram down")
+		}
+		return 42, nil
+	})
+	
+	for i := 0; i < 3; i++ {
+		if count, err := counter.get(-100, time.Hour); err != nil || count != 42 {
+			t.Fatalf("get = %d, %v, want 42", count, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("fetched %d times within the TTL, want 1", fetches)
+	}
+	
+	// Once expired, a failed fetch falls back to the stale count
+	fail = true
+	if count, err := counter.get(-100, 0); err != nil || count != 42 {
+		t.Errorf("get with a failed refresh = %d, %v, want the stale 42", count, err)
+	}
+	if fetches != 2 {
+		t.Errorf("fetched %d times after expiry, want 2", fetches)
+	}
+	// Without a count to fall back on the error is returned
+	if _, err := counter.get(-200, time.Hour); err == nil {
+		t.Error("get of an unknown chat with a failed fetch succeeded")
+	}
+}
+
+func TestGroupSizeBehavior(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.GreetingCooldown = time.Minute
+	cfg.Context.GroupSizeTiers = []config.GroupSizeTierConfig{
+		{MinMembers: 1},
+		{MinMembers: 200, SkipRate: 1, GreetingCooldown: time.Hour},
+	}
+	h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+	// Chat -100 is small, -200 large; -300's count can't be fetched
+	counts := map[int64]int{-100: 10, -200: 500}
+	h.memberCounts = newMemberCounter(func(chatID int64) (int, error) {
+		if count, ok := counts[chatID]; ok {
+			return count, nil
+		}
+		return 0, errors.New("forbidden")
+	})
+	for chatID := range map[int64]bool{-100: true, -200: true, -300: true} {
+		saveChatSettings(t, h, chatID, func(s *models.ChatSettings) {
+			s.Keywords = []string{"weather"}
+			s.MentionWords = []string{"bot"}
+		})
+	}
+	
+	tests := []struct {
+		name   string
+		chatID int64
+		text   string
+		want   bool
+	}{
+		{name: "keyword in a small group", chatID: -100, text: "how is the weather", want: true},
+		{name: "mention word in a small group", chatID: -100, text: "bot, hi", want: true},
+		{name: "keyword in a large group", chatID: -200, text: "how is the weather", want: false},
+		{name: "mention word in a large group", chatID: -200, text: "bot, hi", want: false},
+		{name: "@mention in a large group", chatID: -200, text: "@test_bot how is the weather", want: true},
+		{name: "unknown size", chatID: -300, text: "how is the weather", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.shouldRespond(context.Background(), groupMessage(tt.chatID, 7, tt.text))
+			if err != nil {
+				t.Fatalf("shouldRespond: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("shouldRespond(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+	
+	// Large groups are greeted less often
+	for chatID, want := range map[int64]time.Duration{-100: time.Minute, -200: time.Hour, -300: time.Minute} {
+		if got := h.greetingCooldown(chatID); got != want {
+			t.Errorf("greetingCooldown(%d) = %v, want %v", chatID, got, want)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/middleware"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	"github.com/cf-ai-tgbot-go/internal/services/cache"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+	"github.com/cf-ai-tgbot-go/internal/services/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// testModel is the only model the fake AI service offers
+const testModel = "test-model"
+
+// TestMain runs the tests from the repository root, where the language files
+// are loaded from
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// telegramCall is one Bot API request received by fakeTelegram
+type telegramCall struct {
+	method string
+	params url.Values
+}
+
+// fakeTelegram answers Bot API requests and records them. Methods listed in
+// failures are refused as forbidden with their description, and texts in the
+// parse modes listed in badParseModes as unparsable. getMe reports username,
+// test_bot unless renamed.
+type fakeTelegram struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	calls         []telegramCall
+	nextMessageID int
+	failures      map[string]string
+	badParseModes map[string]bool
+	username      string
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	f := &fakeTelegram{nextMessageID: 1000, username: "test_bot"}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := path.Base(r.URL.Path)
+	// Uploads are recorded with the file's name and content as parameters
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for field, headers := range r.MultipartForm.File {
+			file, err := headers[0].Open()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			file.Close()
+			r.PostForm.Set(field, string(content))
+			r.PostForm.Set(field+"_name", headers[0].Filename)
+		}
+	}
+	
+	f.mu.Lock()
+	f.calls = append(f.calls, telegramCall{method: method, params: r.PostForm})
+	if description, ok := f.failures[method]; ok {
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": 403, "description": description})
+		return
+	}
+	if mode := r.PostForm.Get("parse_mode"); mode != "" && f.badParseModes[mode] {
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": 400, "description": "Bad Request: can't parse entities"})
+		return
+	}
+	var result interface{} = true
+	switch method {
+	case "getMe":
+		result = map[string]interface{}{"id": 1, "is_bot": true, "first_name": "Test", "username": f.username}
+	case "sendMessage", "editMessageText", "editMessageReplyMarkup":
+		chatID, _ := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+		messageID, err := strconv.Atoi(r.PostForm.Get("message_id"))
+		if err != nil {
+			f.nextMessageID++
+			messageID = f.nextMessageID
+		}
+		result = map[string]interface{}{
+			"message_id": messageID,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]interface{}{"id": chatID, "type": "private"},
+			"text":       r.PostForm.Get("text"),
+		}
+	case "sendDocument":
+		f.nextMessageID++
+		chatID, _ := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+		result = map[string]interface{}{
+			"message_id": f.nextMessageID,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]interface{}{"id": chatID, "type": "private"},
+			"document":   map[string]interface{}{"file_id": "file", "file_unique_id": "file", "file_name": r.PostForm.Get("document_name")},
+		}
+	case "sendPoll":
+		f.nextMessageID++
+		chatID, _ := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+		var options []map[string]interface{}
+		var texts []string
+		json.Unmarshal([]byte(r.PostForm.Get("options")), &texts)
+		for _, text := range texts {
+			options = append(options, map[string]interface{}{"text": text, "voter_count": 0})
+		}
+		result = map[string]interface{}{
+			"message_id": f.nextMessageID,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]interface{}{"id": chatID, "type": "group"},
+			"poll": map[string]interface{}{
+				"id":       "poll-" + strconv.Itoa(f.nextMessageID),
+				"question": r.PostForm.Get("question"),
+				"options":  options,
+			},
+		}
+	case "getChatMemberCount":
+		result = 3
+	}
+	f.mu.Unlock()
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+// texts returns the text of every request of the method sent to chatID
+func (f *fakeTelegram) texts(method string, chatID int64) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, call := range f.calls {
+		if call.method == method && call.params.Get("chat_id") == strconv.FormatInt(chatID, 10) {
+			texts = append(texts, call.params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// fail has requests of the method refused with description from now on
+func (f *fakeTelegram) fail(method, description string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures == nil {
+		f.failures = make(map[string]string)
+	}
+	f.failures[method] = description
+}
+
+// rejectParseModes has texts in the parse modes refused as unparsable from now on
+func (f *fakeTelegram) rejectParseModes(modes ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.badParseModes == nil {
+		f.badParseModes = make(map[string]bool)
+	}
+	for _, mode := range modes {
+		f.badParseModes[mode] = true
+	}
+}
+
+// rename changes the username getMe reports from now on
+func (f *fakeTelegram) rename(username string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.username = username
+}
+
+// requests returns the parameters of every request of the method
+func (f *fakeTelegram) requests(method string) []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var params []url.Values
+	for _, call := range f.calls {
+		if call.method == method {
+			params = append(params, call.params)
+		}
+	}
+	return params
+}
+
+// fakeAI answers every request with reply and records the messages sent and
+// whether the knowledge base was searched. It offers models, or only testModel
+// when none are set.
+type fakeAI struct {
+	reply  func(ctx context.Context, messages []models.Message) (string, error)
+	models []ai.ModelOption
+
+	mu       sync.Mutex
+	requests [][]models.Message
+	modelIDs []string
+	options  [][]ai.RequestOption
+	// withKnowledge holds whether each request went through GetResponseWithKnowledge
+	withKnowledge []bool
+}
+
+func (f *fakeAI) GetResponse(ctx context.Context, messages []models.Message, modelID string, opts ...ai.RequestOption) (string, error) {
+	return f.respond(ctx, messages, modelID, false, opts)
+}
+
+func (f *fakeAI) GetResponseWithKnowledge(ctx context.Context, messages []models.Message, modelID string, knowledgeService knowledge.Service, opts ...ai.RequestOption) (string, error) {
+	return f.respond(ctx, messages, modelID, true, opts)
+}
+
+func (f *fakeAI) respond(ctx context.Context, messages []models.Message, modelID string, withKnowledge bool, opts []ai.RequestOption) (string, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, append([]models.Message(nil), messages...))
+	f.modelIDs = append(f.modelIDs, modelID)
+	f.options = append(f.options, opts)
+	f.withKnowledge = append(f.withKnowledge, withKnowledge)
+	f.mu.Unlock()
+	return f.reply(ctx, messages)
+}
+
+func (f *fakeAI) GetAvailableModels() []ai.ModelOption {
+	if f.models != nil {
+		return f.models
+	}
+	return []ai.ModelOption{{ID: testModel, Name: "Test Model", EndpointName: "test"}}
+}
+
+func (f *fakeAI) GetModelsForUser(userID int64) []ai.ModelOption {
+	return f.GetAvailableModels()
+}
+
+func (f *fakeAI) GetModelByID(modelID string) (*ai.ModelOption, error) {
+	for _, model := range f.GetAvailableModels() {
+		if model.ID == modelID {
+			return &model, nil
+		}
+	}
+	return nil, fmt.Errorf("model not found: %s", modelID)
+}
+
+func (f *fakeAI) SetUserKeys(store ai.UserKeyStore) {}
+
+// requestCount returns how many requests the service received
+func (f *fakeAI) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+// newTestConfig returns the smallest configuration the handlers run with,
+// storing everything in memory. A single worker processes the messages in
+// order, see handleAndWait.
+func newTestConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Bot.Workers = 1
+	cfg.Bot.QueueSize = 16
+	cfg.Models.Default = testModel
+	cfg.Storage.Type = "memory"
+	cfg.Storage.Memory.DefaultExpiration = time.Hour
+	cfg.Storage.Memory.CleanupInterval = time.Hour
+	cfg.Context.MaxMessages = 20
+	cfg.I18n.DefaultLanguage = "zh-CN"
+	cfg.I18n.Languages = []string{"zh-CN"}
+	return cfg
+}
+
+// newTestStorage returns memory storage for cfg
+func newTestStorage(t *testing.T, cfg *config.Config) *storage.Manager {
+	manager, err := storage.NewManager(cfg, newTestLogger())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	return manager
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// newTestMessageHandler returns a message handler talking to a fake Telegram
+// and aiService, waiting for its messages to finish when the test ends
+func newTestMessageHandler(t *testing.T, cfg *config.Config, aiService ai.Service) (*MessageHandler, *fakeTelegram) {
+	telegram := newFakeTelegram(t)
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", telegram.server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("failed to create bot: %v", err)
+	}
+	logger := newTestLogger()
+	localizer, err := i18n.NewLocalizer(&cfg.I18n, logger)
+	if err != nil {
+		t.Fatalf("failed to create localizer: %v", err)
+	}
+	
+	h := NewMessageHandler(
+		cfg,
+		bot,
+		aiService,
+		nil,
+		newTestStorage(t, cfg),
+		cache.NewCache(cfg, logger),
+		middleware.NewRateLimiter(cfg, logger),
+		localizer,
+		NewErrorLog(0),
+		NewChatLocker(),
+		logger,
+	)
+	t.Cleanup(func() { h.Shutdown(5 * time.Second) })
+	return h, telegram
+}
+
+// newTestCommandHandler returns a command handler sharing h's bot, services
+// and storage
+func newTestCommandHandler(h *MessageHandler) *CommandHandler {
+	return NewCommandHandler(
+		h.bot,
+		h.config,
+		h.aiService,
+		h.knowledgeService,
+		h.storage,
+		h.cache,
+		h.rateLimiter,
+		h.localizer,
+		h.errorLog,
+		h.chatLocks,
+		h.logger,
+	)
+}
+
+// command returns a message carrying a command from userID in chatID
+func command(chatID, userID int64, text string) *tgbotapi.Message {
+	name := strings.SplitN(text, " ", 2)[0]
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID, FirstName: "User"},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(name)}},
+	}
+}
+
+// handleAndWait handles update and waits until its processing finished. With
+// a single worker, a job queued after the message runs once it is done.
+func handleAndWait(t *testing.T, h *MessageHandler, update *tgbotapi.Update) {
+	t.Helper()
+	if err := h.HandleMessage(context.Background(), update); err != nil {
+		t.Fatalf("HandleMessage: %v", err)
+	}
+	waitForWorkers(t, h)
+}
+
+// waitForWorkers waits until the jobs queued so far have run
+func waitForWorkers(t *testing.T, h *MessageHandler) {
+	t.Helper()
+	done := make(chan struct{})
+	if !h.workers.trySubmit(func() { close(done) }) {
+		t.Fatal("worker pool refused the wait job")
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("message still processing after 10s")
+	}
+}
+
+// privateMessage returns an update carrying a private chat message
+func privateMessage(chatID, userID int64, messageID int, text string) *tgbotapi.Update {
+	return &tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: messageID,
+		From:      &tgbotapi.User{ID: userID, FirstName: "User"},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}}
+}
+
+// counterValue returns the current value of an unlabelled counter
+func counterValue(t *testing.T, name string) float64 {
+	return metricSample(t, name, nil).Value
+}
+
+// metricSample returns the current sample of the metric with labels, zero
+// when it hasn't been recorded yet
+func metricSample(t *testing.T, name string, labels map[string]string) middleware.MetricSample {
+	snapshot, err := middleware.GatherSnapshot(prometheus.DefaultGatherer)
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range snapshot.Metrics {
+		if family.Name != name {
+			continue
+		}
+		for _, sample := range family.Samples {
+			if labelsMatch(sample.Labels, labels) {
+				return sample
+			}
+		}
+	}
+	return middleware.MetricSample{}
+}
+
+func labelsMatch(have, want map[string]string) bool {
+	for name, value := range want {
+		if have[name] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+func TestInteractiveRetriesDisabled(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	
+	cfg := newTestConfig()
+	cfg.Models.InteractiveRetries = -1
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: testModel}},
+	}}
+	service := ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger())
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	
+	start := time.Now()
+	handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+	
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("endpoint got %d requests, want 1", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("reply took %v, want the error without waiting for retries", elapsed)
+	}
+	if edits := telegram.texts("editMessageText", 42); len(edits) == 0 {
+		t.Error("the user got no error message")
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+)
+
+// newTestVectorKnowledge returns a vector knowledge base of a library and a
+// canteen document
+func newTestVectorKnowledge(t *testing.T) *knowledge.VectorKnowledgeService {
+	dir := t.TempDir()
+	docs := map[string]string{
+		"library.md": "# Campus library\n## Hours\nThe library opens at eight and closes at ten.\n## Cards\nLibrary cards are free for students.",
+		"canteen.md": "# Canteen\nThe canteen serves vegetarian lunch from eleven.",
+	}
+	for name, content := range docs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v := knowledge.NewVectorKnowledgeService(newTestLogger())
+	if err := v.LoadKnowledgeBase(context.Background(), dir); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+	return v
+}
+
+func TestFormatKBTestResults(t *testing.T) {
+	v := newTestVectorKnowledge(t)
+	library, _ := v.GetDocument("library")
+	canteen, _ := v.GetDocument("canteen")
+	results := []knowledge.DocumentWithScore{
+		{Document: *library, Score: 0.62},
+		{Document: *canteen, Score: 0.04},
+	}
+	
+	text := formatKBTestResults(v, "library opens at eight", results)
+	for _, want := range []string{
+		"查询：library opens at eight\n阈值：0.10",
+		"1. Campus library\n   相似度：0.620 ✅ 通过",
+		"匹配片段「Hours」",
+		"The library opens at eight and closes at ten.",
+		"2. Canteen\n   相似度：0.040 ❌ 低于阈值",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("results %q miss %q", text, want)
+		}
+	}
+	
+	// A score right at the threshold doesn't pass
+	atThreshold := []knowledge.DocumentWithScore{{Document: *canteen, Score: knowledge.RelevanceThreshold}}
+	if text := formatKBTestResults(v, "lunch", atThreshold); !strings.Contains(text, "❌ 低于阈值") {
+		t.Errorf("score at the threshold rendered as %q, want it failing", text)
+	}
+	if text := formatKBTestResults(v, "lunch", nil); !strings.Contains(text, "没有任何文档与查询相似") {
+		t.Errorf("no results rendered as %q", text)
+	}
+}
+
+func TestKBTestCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int64
+		command string
+		vector  bool
+		want    string
+	}{
+		{name: "scores", userID: 7, command: "/kbtest library cards", vector: true, want: "1. Campus library"},
+		{name: "not an admin", userID: 8, command: "/kbtest library", vector: true, want: "仅限管理员"},
+		{name: "no query", userID: 7, command: "/kbtest", vector: true, want: "用法：/kbtest"},
+		{name: "no vector search", userID: 7, command: "/kbtest library", want: "向量知识库未启用"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Bot.AdminIDs = []int64{7}
+			h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+			if tt.vector {
+				h.knowledgeService = newTestVectorKnowledge(t)
+			}
+			c := newTestCommandHandler(h)
+			
+			runCommand(t, c, 42, tt.userID, tt.command)
+			if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, tt.want) {
+				t.Errorf("answered %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxKeywordChars caps the length of a single keyword
+const maxKeywordChars = 20
+
+// containsWord reports whether text contains word, ignoring case. Latin letters
+// and digits next to the match mean it is part of a longer word ("ai" in
+// "said"), while CJK text has no word separators and always matches.
+func containsWord(text, word string) bool {
+	text = strings.ToLower(text)
+	word = strings.ToLower(word)
+	if word == "" {
+		return false
+	}
+	
+	for offset := 0; offset < len(text); {
+		index := strings.Index(text[offset:], word)
+		if index < 0 {
+			return false
+		}
+		start := offset + index
+		end := start + len(word)
+		
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !(isLatinWordRune(before) && isLatinWordRune(firstRune(word))) &&
+			!(isLatinWordRune(after) && isLatinWordRune(lastRune(word))) {
+			return true
+		}
+		offset = start + 1
+	}
+	return false
+}
+
+// isLatinWordRune reports whether r belongs to a space-separated word
+func isLatinWordRune(r rune) bool {
+	return r < unicode.MaxLatin1 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// addKeyword adds a keyword unless it is already configured, reporting whether it was added
+func addKeyword(settings *models.ChatSettings, keyword string) bool {
+	for _, existing := range settings.Keywords {
+		if strings.EqualFold(existing, keyword) {
+			return false
+		}
+	}
+	settings.Keywords = append(settings.Keywords, keyword)
+	return true
+}
+
+// removeKeyword removes a keyword, reporting whether it was configured
+func removeKeyword(settings *models.ChatSettings, keyword string) bool {
+	for i, existing := range settings.Keywords {
+		if strings.EqualFold(existing, keyword) {
+			settings.Keywords = append(settings.Keywords[:i], settings.Keywords[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// validKeyword reports whether keyword has an acceptable length
+func validKeyword(keyword string) bool {
+	chars := utf8.RuneCountInString(keyword)
+	return chars > 0 && chars <= maxKeywordChars
+}
+
+// handleKeywords handles /keywords command.
+// Usage: /keywords, /keywords add <词>, /keywords del <词>, /keywords clear
+func (h *CommandHandler) handleKeywords(ctx context.Context, chatID int64, args string, lang string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	
+	action, keyword, _ := strings.Cut(strings.TrimSpace(args), " ")
+	keyword = strings.TrimSpace(keyword)
+	
+	var text string
+	switch strings.ToLower(action) {
+	case "":
+		msg := tgbotapi.NewMessage(chatID, h.describeKeywords(settings, lang))
+		msg.ReplyMarkup = keywordsKeyboard(settings)
+		_, err := h.bot.Send(msg)
+		return err
+	case "add":
+		if !validKeyword(keyword) {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 关键词长度应在 1-%d 个字符之间", maxKeywordChars)))
+			return err
+		}
+		if !addKeyword(settings, keyword) {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 关键词「%s」已存在", keyword)))
+			return err
+		}
+		text = h.localizer.Get(lang, i18n.MsgKeywordsSet, map[string]interface{}{
+			"Keywords": strings.Join(settings.Keywords, ", "),
+		})
+	case "del", "delete":
+		if !removeKeyword(settings, keyword) {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 未找到关键词「%s」", keyword)))
+			return err
+		}
+		text = fmt.Sprintf("✅ 已删除关键词「%s」", keyword)
+	case "clear":
+		settings.Keywords = []string{}
+		text = h.localizer.Get(lang, i18n.MsgKeywordsDisabled, nil)
+	default:
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "用法：/keywords | /keywords add <关键词> | /keywords del <关键词> | /keywords clear"))
+		return err
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// handleKeywordCallback handles the keyword management buttons
+func (h *CommandHandler) handleKeywordCallback(ctx context.Context, chatID int64, messageID int, userID int64, action string, lang string, callbackID string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	
+	switch {
+	case action == "menu":
+		h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	case action == "add":
+		h.storage.SetUserState(ctx, userID, "adding_keyword", "true")
+		
+		edit := tgbotapi.NewEditMessageText(chatID, messageID,
+			"➕ 添加关键词\n\n请发送要添加的关键词，群组消息包含该词时机器人将自动回复。\n\n发送 /cancel 取消操作")
+		_, err := h.bot.Send(edit)
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "请输入关键词"))
+		return err
+	case action == "delete":
+		if len(settings.Keywords) == 0 {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "没有可删除的关键词"))
+			return nil
+		}
+		
+		rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(settings.Keywords)+1)
+		for i, keyword := range settings.Keywords {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗑 "+keyword, indexedCallbackData("keyword:del", i, settings.Keywords)),
+			))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "keyword:menu"),
+		))
+		
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, "➖ 删除关键词\n\n请选择要删除的关键词：")
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		edit.ReplyMarkup = &keyboard
+		_, err := h.bot.Send(edit)
+		h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+		return err
+	case action == "clear":
+		settings.Keywords = []string{}
+		if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+			h.logger.WithError(err).Error("Failed to save settings")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "清空失败"))
+			return err
+		}
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "已清空关键词"))
+	case strings.HasPrefix(action, "del:"):
+		index, ok := parseIndexedCallback(strings.TrimPrefix(action, "del:"), settings.Keywords)
+		if !ok {
+			// The keywords changed since the menu was opened; show the current list
+			h.answerStaleMenu(chatID, messageID, lang, callbackID, nil)
+			break
+		}
+		
+		deleted := settings.Keywords[index]
+		settings.Keywords = append(settings.Keywords[:index], settings.Keywords[index+1:]...)
+		if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+			h.logger.WithError(err).Error("Failed to save settings")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "删除失败"))
+			return err
+		}
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "已删除: "+deleted))
+	default:
+		return nil
+	}
+	
+	// Show the updated keyword list
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, h.describeKeywords(settings, lang))
+	keyboard := keywordsKeyboard(settings)
+	edit.ReplyMarkup = &keyboard
+	_, err := h.bot.Send(edit)
+	return err
+}
+
+// describeKeywords lists the keywords of a chat
+func (h *CommandHandler) describeKeywords(settings *models.ChatSettings, lang string) string {
+	if len(settings.Keywords) == 0 {
+		return "🔑 关键词管理\n\n" + h.localizer.Get(lang, i18n.MsgKeywordsDisabled, nil)
+	}
+	return "🔑 关键词管理\n\n" + h.localizer.Get(lang, i18n.MsgCurrentKeywords, map[string]interface{}{
+		"Keywords": strings.Join(settings.Keywords, ", "),
+	})
+}
+
+// keywordsKeyboard returns the keyword management buttons
+func keywordsKeyboard(settings *models.ChatSettings) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ 添加关键词", "keyword:add")),
+	}
+	if len(settings.Keywords) > 0 {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➖ 删除关键词", "keyword:delete")),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🧹 清空关键词", "keyword:clear")),
+		)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:settings")))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleAddKeyword handles the keyword sent after pressing "add keyword"
+func (h *MessageHandler) handleAddKeyword(ctx context.Context, update *tgbotapi.Update) error {
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+	keyword := strings.TrimSpace(update.Message.Text)
+	lang := h.getUserLanguage(ctx, chatID)
+	
+	h.storage.DeleteUserState(ctx, userID, "adding_keyword")
+	
+	if keyword == "/cancel" || keyword == "取消" {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "已取消添加关键词"))
+		return err
+	}
+	
+	if !validKeyword(keyword) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 关键词长度应在 1-%d 个字符之间", maxKeywordChars)))
+		return err
+	}
+	
+	settings, err := h.storage.GetSettings(ctx, chatID)
+	if err != nil || settings == nil {
+		settings = h.getDefaultSettings()
+	}
+	
+	if !addKeyword(settings, keyword) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 关键词「%s」已存在", keyword)))
+		return err
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	msg := tgbotapi.NewMessage(chatID, h.localizer.Get(lang, i18n.MsgKeywordsSet, map[string]interface{}{
+		"Keywords": strings.Join(settings.Keywords, ", "),
+	}))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔑 查看所有关键词", "keyword:menu"),
+		),
+	)
+	_, err = h.bot.Send(msg)
+	return err
+}
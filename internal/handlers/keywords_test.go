@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestContainsWord(t *testing.T) {
+	tests := []struct {
+		text string
+		word string
+		want bool
+	}{
+		{text: "ask the AI please", word: "ai", want: true},
+		{text: "AI, help", word: "ai", want: true},
+		{text: "she said so", word: "ai", want: false},
+		{text: "said ai", word: "ai", want: true},
+		{text: "gpt4o is out", word: "gpt4", want: false},
+		{text: "问问小菲吧", word: "小菲", want: true},
+		{text: "小菲ai在吗", word: "小菲", want: true},
+		{text: "问一下ai吧", word: "ai", want: true},
+		{text: "anything", word: "", want: false},
+		{text: "", word: "ai", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text+"/"+tt.word, func(t *testing.T) {
+			if got := containsWord(tt.text, tt.word); got != tt.want {
+				t.Errorf("containsWord(%q, %q) = %v, want %v", tt.text, tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeywordAddAndRemove(t *testing.T) {
+	settings := &models.ChatSettings{}
+	steps := []struct {
+		name   string
+		change func() bool
+		want   bool
+		after  []string
+	}{
+		{name: "add", change: func() bool { return addKeyword(settings, "Go") }, want: true, after: []string{"Go"}},
+		{name: "add another", change: func() bool { return addKeyword(settings, "天气") }, want: true, after: []string{"Go", "天气"}},
+		{name: "add again in other case", change: func() bool { return addKeyword(settings, "go") }, want: false, after: []string{"Go", "天气"}},
+		{name: "del in other case", change: func() bool { return removeKeyword(settings, "GO") }, want: true, after: []string{"天气"}},
+		{name: "del unknown", change: func() bool { return removeKeyword(settings, "rust") }, want: false, after: []string{"天气"}},
+	}
+	for _, step := range steps {
+		if got := step.change(); got != step.want {
+			t.Errorf("%s reported %v, want %v", step.name, got, step.want)
+		}
+		if !reflect.DeepEqual(settings.Keywords, step.after) {
+			t.Errorf("after %s keywords = %q, want %q", step.name, settings.Keywords, step.after)
+		}
+	}
+}
+
+func TestValidKeyword(t *testing.T) {
+	tests := []struct {
+		keyword string
+		want    bool
+	}{
+		{keyword: "", want: false},
+		{keyword: "go", want: true},
+		{keyword: "一二三四五六七八九十一二三四五六七八九十", want: true},
+		{keyword: "一二三四五六七八九十一二三四五六七八九十一", want: false},
+	}
+	for _, tt := range tests {
+		if got := validKeyword(tt.keyword); got != tt.want {
+			t.Errorf("validKeyword(%q) = %v, want %v", tt.keyword, got, tt.want)
+		}
+	}
+}
+
+// groupMessage returns an update carrying a group chat message
+func groupMessage(chatID, userID int64, text string) *tgbotapi.Update {
+	return &tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID, FirstName: "User"},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "group"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}}
+}
+
+func TestShouldRespondToKeywords(t *testing.T) {
+	h, _ := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	saveChatSettings(t, h, -100, func(s *models.ChatSettings) {
+		s.Keywords = []string{"weather", "天气"}
+		s.MentionWords = []string{"bot"}
+	})
+	saveChatSettings(t, h, -200, func(s *models.ChatSettings) {
+		s.Keywords = []string{"weather"}
+		s.MentionWords = []string{"bot"}
+		s.Paused = true
+	})
+	
+	tests := []struct {
+		name   string
+		update *tgbotapi.Update
+		want   bool
+	}{
+		{name: "keyword", update: groupMessage(-100, 7, "How is the weather tomorrow?"), want: true},
+		{name: "keyword in other case", update: groupMessage(-100, 7, "WEATHER report please"), want: true},
+		{name: "keyword inside a longer word", update: groupMessage(-100, 7, "The weathered rocks are old"), want: false},
+		{name: "cjk keyword", update: groupMessage(-100, 7, "明天天气怎么样"), want: true},
+		{name: "mention word", update: groupMessage(-100, 7, "bot, what time is it"), want: true},
+		{name: "no match", update: groupMessage(-100, 7, "See you all tomorrow"), want: false},
+		{name: "paused chat", update: groupMessage(-200, 7, "How is the weather tomorrow?"), want: false},
+		{name: "private chat", update: privateMessage(7, 7, 1, "See you all tomorrow"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.shouldRespond(context.Background(), tt.update)
+			if err != nil {
+				t.Fatalf("shouldRespond: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("shouldRespond(%q) = %v, want %v", tt.update.Message.Text, got, tt.want)
+			}
+		})
+	}
+}
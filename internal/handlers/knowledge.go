@@ -2,12 +2,22 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+const (
+	// maxReloadButtons caps the per-document reload buttons of the document list
+	maxReloadButtons = 20
+	// maxCallbackDataLen is Telegram's limit for callback data in bytes
+	maxCallbackDataLen = 64
+)
+
 // handleKnowledge handles knowledge base management
 func (h *CommandHandler) handleKnowledge(ctx context.Context, chatID int64, userID int64, lang string) error {
 	// Check if knowledge service is available
@@ -50,6 +60,10 @@ func (h *CommandHandler) handleKnowledge(ctx context.Context, chatID int64, user
 
 // handleKnowledgeCallback handles knowledge base callbacks
 func (h *CommandHandler) handleKnowledgeCallback(ctx context.Context, chatID int64, messageID int, userID int64, action string, lang string, callbackID string) error {
+	if docID, ok := strings.CutPrefix(action, "reload:"); ok {
+		return h.handleReloadDocument(ctx, chatID, messageID, docID, callbackID)
+	}
+	
 	switch action {
 	case "list":
 		// List all documents
@@ -63,21 +77,30 @@ func (h *CommandHandler) handleKnowledgeCallback(ctx context.Context, chatID int
 			return err
 		}
 		
+		sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+		
 		var text strings.Builder
 		text.WriteString("📚 知识库文档列表：\n\n")
 		
+		// Each document gets a button reloading just that document
+		var rows [][]tgbotapi.InlineKeyboardButton
 		for i, doc := range docs {
 			text.WriteString(fmt.Sprintf("%d. 📄 %s\n", i+1, doc.Title))
 			text.WriteString(fmt.Sprintf("   ID: %s\n", doc.ID))
 			text.WriteString(fmt.Sprintf("   大小: %d 字符\n\n", len(doc.Content)))
+			
+			data := "knowledge:reload:" + doc.ID
+			if len(rows) < maxReloadButtons && len(data) <= maxCallbackDataLen {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🔄 %d. %s", i+1, doc.Title), data),
+				))
+			}
 		}
 		
 		// Add back button
-		rows := [][]tgbotapi.InlineKeyboardButton{
-			{
-				tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "knowledge:menu"),
-			},
-		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "knowledge:menu"),
+		))
 		keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 		
 		edit := tgbotapi.NewEditMessageText(chatID, messageID, text.String())
@@ -92,6 +115,10 @@ func (h *CommandHandler) handleKnowledgeCallback(ctx context.Context, chatID int
 		h.bot.Request(tgbotapi.NewCallback(callbackID, "正在刷新知识库..."))
 		
 		err := h.knowledgeService.RefreshKnowledgeBase(ctx)
+		if errors.Is(err, knowledge.ErrRefreshInProgress) {
+			_, err := h.bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, "⏳ 知识库正在刷新中，请稍后再试"))
+			return err
+		}
 		if err != nil {
 			h.logger.WithError(err).Error("Failed to refresh knowledge base")
 			edit := tgbotapi.NewEditMessageText(chatID, messageID, "❌ 刷新失败："+err.Error())
@@ -185,4 +212,25 @@ func (h *CommandHandler) handleKnowledgeCallback(ctx context.Context, chatID int
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+// handleReloadDocument reloads a single knowledge document from its file
+func (h *CommandHandler) handleReloadDocument(ctx context.Context, chatID int64, messageID int, docID string, callbackID string) error {
+	if h.knowledgeService == nil {
+		_, err := h.bot.Request(tgbotapi.NewCallback(callbackID, "❌ 知识库服务未启用"))
+		return err
+	}
+	
+	doc, err := h.knowledgeService.ReloadDocument(ctx, docID)
+	if err != nil {
+		h.logger.WithError(err).WithField("id", docID).Error("Failed to reload document")
+		_, err := h.bot.Request(tgbotapi.NewCallbackWithAlert(callbackID, "❌ 重新加载失败："+err.Error()))
+		return err
+	}
+	
+	text := fmt.Sprintf("🗑 文件已不存在，已移除文档 %s", docID)
+	if doc != nil {
+		text = fmt.Sprintf("✅ 已重新加载「%s」（%d 字符）", doc.Title, len(doc.Content))
+	}
+	_, err = h.bot.Request(tgbotapi.NewCallbackWithAlert(callbackID, text))
+	return err
+}
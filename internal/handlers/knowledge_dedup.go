@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+)
+
+// injectedKnowledge returns the keys of the knowledge documents still held by the context
+func injectedKnowledge(chatCtx *models.ChatContext) map[string]bool {
+	seen := make(map[string]bool, len(chatCtx.KnowledgeIndex))
+	for key := range chatCtx.KnowledgeIndex {
+		seen[key] = true
+	}
+	return seen
+}
+
+// recordKnowledge keeps the knowledge injected by a request in the context, just
+// before the latest user turn, so later turns can refer to it instead of
+// injecting it again
+func recordKnowledge(chatCtx *models.ChatContext, dedup *ai.KnowledgeDedup) {
+	if dedup == nil || dedup.Context == "" || len(chatCtx.Messages) == 0 {
+		return
+	}
+
+	position := len(chatCtx.Messages) - 1
+	knowledgeMsg := models.Message{Role: "system", Content: dedup.Context}
+	chatCtx.Messages = append(chatCtx.Messages[:position], append([]models.Message{knowledgeMsg}, chatCtx.Messages[position:]...)...)
+
+	if chatCtx.KnowledgeIndex == nil {
+		chatCtx.KnowledgeIndex = make(map[string]int)
+	}
+	for _, key := range dedup.Injected {
+		chatCtx.KnowledgeIndex[key] = position
+	}
+}
+
+// shiftKnowledgeIndex adjusts recorded positions after removed messages were
+// trimmed from the start of the history, after the keep leading messages
+// (the system message and any summary)
+func shiftKnowledgeIndex(chatCtx *models.ChatContext, removed, keep int) {
+	for key, position := range chatCtx.KnowledgeIndex {
+		// Documents trimmed away may be injected again
+		if position-removed < keep {
+			delete(chatCtx.KnowledgeIndex, key)
+			continue
+		}
+		chatCtx.KnowledgeIndex[key] = position - removed
+	}
+}
+
+// dropKnowledgeAfter forgets documents injected at or after length, once the
+// context was cut back to that many messages
+func dropKnowledgeAfter(chatCtx *models.ChatContext, length int) {
+	for key, position := range chatCtx.KnowledgeIndex {
+		if position >= length {
+			delete(chatCtx.KnowledgeIndex, key)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+)
+
+// fakeKnowledge is a knowledge base whose search always finds docs
+type fakeKnowledge struct {
+	docs []knowledge.Document
+}
+
+func (k fakeKnowledge) LoadKnowledgeBase(ctx context.Context, dirs ...string) error { return nil }
+
+func (k fakeKnowledge) SearchDocuments(ctx context.Context, query string, limit int) ([]knowledge.Document, error) {
+	return k.docs, nil
+}
+
+func (k fakeKnowledge) GetAllDocuments() []knowledge.Document { return k.docs }
+
+func (k fakeKnowledge) GetDocument(id string) (*knowledge.Document, error) {
+	for _, doc := range k.docs {
+		if doc.ID == id {
+			return &doc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (k fakeKnowledge) RefreshKnowledgeBase(ctx context.Context) error { return nil }
+
+func (k fakeKnowledge) ReloadDocument(ctx context.Context, id string) (*knowledge.Document, error) {
+	return k.GetDocument(id)
+}
+
+// recordingEndpoint answers every chat completion and keeps the messages of
+// each request
+type recordingEndpoint struct {
+	mu       sync.Mutex
+	requests [][]models.Message
+}
+
+func (e *recordingEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Messages []models.Message `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+	e.mu.Lock()
+	e.requests = append(e.requests, request.Messages)
+	e.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": "answer"}}},
+	})
+}
+
+func (e *recordingEndpoint) sent() [][]models.Message {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([][]models.Message(nil), e.requests...)
+}
+
+// newEndpointAI returns a real AI service sending testModel requests to endpoint
+func newEndpointAI(t *testing.T, cfg *config.Config, endpoint http.HandlerFunc) ai.Service {
+	server := httptest.NewServer(endpoint)
+	t.Cleanup(server.Close)
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", DisplayName: "Test", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: testModel}},
+	}}
+	return ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger())
+}
+
+// countIn returns how many messages hold text
+func countIn(messages []models.Message, text string) int {
+	count := 0
+	for _, msg := range messages {
+		count += strings.Count(msg.Content, text)
+	}
+	return count
+}
+
+func TestKnowledgeInjectedOnce(t *testing.T) {
+	const docText = "The library opens at eight."
+	tests := []struct {
+		name  string
+		dedup bool
+	}{
+		{name: "dedup", dedup: true},
+		{name: "without dedup", dedup: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Knowledge.Enabled = true
+			cfg.Knowledge.Dedup = tt.dedup
+			endpoint := &recordingEndpoint{}
+			h, _ := newTestMessageHandler(t, cfg, newEndpointAI(t, cfg, endpoint.serve))
+			h.knowledgeService = fakeKnowledge{docs: []knowledge.Document{{ID: "library", Title: "Library", Content: docText}}}
+			
+			handleAndWait(t, h, privateMessage(42, 7, 1, "When does the library open?"))
+			handleAndWait(t, h, privateMessage(42, 7, 2, "And on weekends?"))
+			
+			requests := endpoint.sent()
+			if len(requests) != 2 {
+				t.Fatalf("sent %d requests, want 2", len(requests))
+			}
+			if got := countIn(requests[0], docText); got != 1 {
+				t.Errorf("first turn carried %d copies of the document, want 1", got)
+			}
+			// Either kept from the first turn or injected again, never both
+			second := requests[1]
+			if got := countIn(second, docText); got != 1 {
+				t.Errorf("second turn carried %d copies of the document, want 1", got)
+			}
+			// Only the deduplicated turn points back to the earlier document
+			if got := countIn(second, "参见之前提供的文档") == 1; got != tt.dedup {
+				t.Errorf("second turn refers to the earlier document = %v, want %v", got, tt.dedup)
+			}
+			
+			// With dedup the document stays in the saved context, once
+			chatCtx, err := h.storage.GetContext(context.Background(), 42)
+			if err != nil || chatCtx == nil {
+				t.Fatalf("no saved context: %v", err)
+			}
+			if got, want := countIn(chatCtx.Messages, docText), map[bool]int{true: 1}[tt.dedup]; got != want {
+				t.Errorf("saved context holds %d copies of the document, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestShiftKnowledgeIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		position int
+		removed  int
+		keep     int
+		want     int // -1 when the document is forgotten
+	}{
+		{name: "after the trimmed messages", position: 5, removed: 2, keep: 1, want: 3},
+		{name: "trimmed away", position: 2, removed: 2, keep: 1, want: -1},
+		{name: "first message kept", position: 3, removed: 2, keep: 1, want: 1},
+		{name: "after the summary", position: 5, removed: 2, keep: 2, want: 3},
+		{name: "would land on the summary", position: 3, removed: 2, keep: 2, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatCtx := &models.ChatContext{KnowledgeIndex: map[string]int{"doc": tt.position}}
+			shiftKnowledgeIndex(chatCtx, tt.removed, tt.keep)
+			got, ok := chatCtx.KnowledgeIndex["doc"]
+			if !ok {
+				got = -1
+			}
+			if got != tt.want {
+				t.Errorf("position = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimForgetsKnowledgeBehindSummary(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.MaxMessages = 3
+	h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+	chatCtx := &models.ChatContext{
+		Messages: []models.Message{
+			{Role: "system", Content: "prompt"},
+			{Role: "system", Content: summaryPrefix + "earlier"},
+			{Role: "system", Content: "knowledge"},
+			{Role: "user", Content: "question"},
+			{Role: "assistant", Content: "answer"},
+			{Role: "user", Content: "next question"},
+		},
+		KnowledgeIndex: map[string]int{"doc": 2},
+	}
+	
+	if !h.trimContext(chatCtx) {
+		t.Fatal("context not trimmed")
+	}
+	if !isSummaryMessage(chatCtx.Messages[1]) || len(chatCtx.Messages) != 5 {
+		t.Fatalf("trimmed to %+v, want the summary and the last 3 messages kept", chatCtx.Messages)
+	}
+	// The knowledge message was trimmed, so the document may be injected again
+	if position, ok := chatCtx.KnowledgeIndex["doc"]; ok {
+		t.Errorf("trimmed document still recorded at %d (%q)", position, chatCtx.Messages[position].Content)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// knowledgeFullDocs reports whether knowledge documents are injected whole in
+// this chat instead of being cut at knowledge.max_doc_chars
+func knowledgeFullDocs(cfg *config.Config, settings *models.ChatSettings) bool {
+	if settings.KnowledgeFullDocs != 0 {
+		return settings.KnowledgeFullDocs > 0
+	}
+	return cfg.Knowledge.FullDocuments
+}
+
+// handleKBFull handles /kbfull command, choosing whether the chat gets whole
+// knowledge documents. Accepts "on", "off" or "default" to follow the config.
+func (h *CommandHandler) handleKBFull(ctx context.Context, chatID int64, args string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	arg := strings.ToLower(strings.TrimSpace(args))
+	
+	switch arg {
+	case "":
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.describeKBFull(settings)+
+			"\n\n用法：/kbfull on | off | default"))
+		return err
+	case "on":
+		settings.KnowledgeFullDocs = 1
+	case "off":
+		settings.KnowledgeFullDocs = -1
+	case "default":
+		settings.KnowledgeFullDocs = 0
+	default:
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请输入 on / off / default"))
+		return err
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ "+h.describeKBFull(settings)))
+	return err
+}
+
+// describeKBFull describes how knowledge documents are injected in a chat
+func (h *CommandHandler) describeKBFull(settings *models.ChatSettings) string {
+	source := "本聊天设置"
+	if settings.KnowledgeFullDocs == 0 {
+		source = "全局默认"
+	}
+	if !knowledgeFullDocs(h.config, settings) {
+		return fmt.Sprintf("知识库文档：超长文档按字符数截断后注入（%s）", source)
+	}
+	if h.config.Knowledge.MaxContextTokens > 0 {
+		return fmt.Sprintf("知识库文档：注入完整文档，总量不超过约 %d token（%s）", h.config.Knowledge.MaxContextTokens, source)
+	}
+	return fmt.Sprintf("知识库文档：注入完整文档（%s）", source)
+}
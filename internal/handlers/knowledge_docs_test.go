@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestKnowledgeFullDocsSetting(t *testing.T) {
+	tests := []struct {
+		global  bool
+		setting int
+		want    bool
+	}{
+		{global: false, setting: 0, want: false},
+		{global: true, setting: 0, want: true},
+		{global: false, setting: 1, want: true},
+		{global: true, setting: -1, want: false},
+	}
+	for _, tt := range tests {
+		cfg := newTestConfig()
+		cfg.Knowledge.FullDocuments = tt.global
+		if got := knowledgeFullDocs(cfg, &models.ChatSettings{KnowledgeFullDocs: tt.setting}); got != tt.want {
+			t.Errorf("knowledgeFullDocs(global %v, chat %d) = %v, want %v", tt.global, tt.setting, got, tt.want)
+		}
+	}
+}
+
+func TestKBFullCommand(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig()
+	cfg.Knowledge.MaxContextTokens = 1500
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	steps := []struct {
+		command  string
+		wantText string
+		want     int
+	}{
+		{command: "/kbfull", wantText: "按字符数截断后注入（全局默认）"},
+		{command: "/kbfull ON", wantText: "注入完整文档，总量不超过约 1500 token（本聊天设置）", want: 1},
+		{command: "/kbfull maybe", wantText: "请输入 on / off / default", want: 1},
+		{command: "/kbfull off", wantText: "按字符数截断后注入（本聊天设置）", want: -1},
+		{command: "/kbfull default", wantText: "（全局默认）"},
+	}
+	for _, step := range steps {
+		runCommand(t, c, 42, 42, step.command)
+		
+		if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, step.wantText) {
+			t.Errorf("%s answered %q, want %q", step.command, text, step.wantText)
+		}
+		if got := c.getChatSettings(ctx, 42).KnowledgeFullDocs; got != step.want {
+			t.Errorf("after %s the chat setting is %d, want %d", step.command, got, step.want)
+		}
+	}
+}
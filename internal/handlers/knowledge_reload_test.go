@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+)
+
+func TestReloadDocumentButton(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"shared/guide.md": "# Guide",
+		"local/guide.md":  "# Local guide",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := knowledge.NewKnowledgeService(newTestLogger())
+	if err := service.LoadKnowledgeBase(context.Background(), filepath.Join(root, "shared"), filepath.Join(root, "local")); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	h.knowledgeService = service
+	c := newTestCommandHandler(h)
+	
+	// The list has a reload button for each document
+	pressButton(t, c, 42, 7, "knowledge:list")
+	edits := telegram.requests("editMessageText")
+	if len(edits) == 0 {
+		t.Fatal("the document list wasn't shown")
+	}
+	var markup struct {
+		InlineKeyboard [][]struct {
+			CallbackData string `json:"callback_data"`
+		} `json:"inline_keyboard"`
+	}
+	json.Unmarshal([]byte(edits[len(edits)-1].Get("reply_markup")), &markup)
+	var buttons []string
+	for _, row := range markup.InlineKeyboard {
+		buttons = append(buttons, row[0].CallbackData)
+	}
+	if want := "knowledge:reload:local:guide|knowledge:reload:shared:guide|knowledge:menu"; strings.Join(buttons, "|") != want {
+		t.Errorf("buttons %q, want %q", buttons, want)
+	}
+	
+	// Reloading one document leaves the other alone
+	os.WriteFile(filepath.Join(root, "local/guide.md"), []byte("# New local guide"), 0o644)
+	os.WriteFile(filepath.Join(root, "shared/guide.md"), []byte("# New guide"), 0o644)
+	pressButton(t, c, 42, 7, "knowledge:reload:local:guide")
+	answers := telegram.requests("answerCallbackQuery")
+	if text := answers[len(answers)-1].Get("text"); !strings.Contains(text, "已重新加载「New local guide」") {
+		t.Errorf("answered %q, want the reloaded title", text)
+	}
+	if doc, _ := service.GetDocument("shared:guide"); doc == nil || doc.Title != "Guide" {
+		t.Errorf("other document became %+v, want it untouched", doc)
+	}
+	
+	// A document whose file is gone is removed
+	os.Remove(filepath.Join(root, "local/guide.md"))
+	pressButton(t, c, 42, 7, "knowledge:reload:local:guide")
+	answers = telegram.requests("answerCallbackQuery")
+	if text := answers[len(answers)-1].Get("text"); !strings.Contains(text, "已移除文档 local:guide") {
+		t.Errorf("answered %q, want the document removed", text)
+	}
+	if doc, _ := service.GetDocument("local:guide"); doc != nil {
+		t.Errorf("removed document still loaded: %+v", doc)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/sirupsen/logrus"
+)
+
+// languageStreakKey stores "<lang>:<count>", the language the latest messages
+// of a chat were written in and how many in a row used it, as a chat state
+const languageStreakKey = "language_streak"
+
+// languageStreakTTL ends a streak the user didn't continue within a day
+const languageStreakTTL = 24 * time.Hour
+
+// defaultAutoSwitchMessages is used when i18n.auto_switch_messages is unset
+const defaultAutoSwitchMessages = 3
+
+// autoSwitchLanguage switches the chat's UI language once the user has written
+// enough messages in a row in another configured language. Only private chats
+// switch; group members may write in different languages.
+func (h *MessageHandler) autoSwitchLanguage(ctx context.Context, chatID int64, isPrivate bool, text string) {
+	if !h.config.I18n.AutoSwitch || !isPrivate {
+		return
+	}
+	
+	detected := i18n.DetectLanguage(text, h.config.I18n.Languages)
+	if detected == "" {
+		return
+	}
+	
+	settings, err := h.storage.GetSettings(ctx, chatID)
+	if err != nil || settings == nil {
+		settings = h.getDefaultSettings()
+	}
+	if detected == settings.Language {
+		h.storage.DeleteChatState(ctx, chatID, languageStreakKey)
+		return
+	}
+	
+	// Count consecutive messages in the detected language
+	count := 1
+	if value, err := h.storage.GetChatState(ctx, chatID, languageStreakKey); err == nil {
+		if lang, n, ok := strings.Cut(value, ":"); ok && lang == detected {
+			if previous, err := strconv.Atoi(n); err == nil {
+				count = previous + 1
+			}
+		}
+	}
+	
+	required := h.config.I18n.AutoSwitchMessages
+	if required <= 0 {
+		required = defaultAutoSwitchMessages
+	}
+	if count < required {
+		h.storage.SetChatState(ctx, chatID, languageStreakKey, detected+":"+strconv.Itoa(count), languageStreakTTL)
+		return
+	}
+	
+	settings.Language = detected
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Warn("Failed to switch chat language")
+		return
+	}
+	h.storage.DeleteChatState(ctx, chatID, languageStreakKey)
+	h.logger.WithFields(logrus.Fields{
+		"chatID":   chatID,
+		"language": detected,
+	}).Info("Switched chat language to match the user")
+}
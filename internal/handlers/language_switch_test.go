@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAutoSwitchLanguage(t *testing.T) {
+	const english = "Could you explain how this works please"
+	const chinese = "请问这个功能是怎么工作的呢"
+	tests := []struct {
+		name     string
+		messages []string
+		want     string
+	}{
+		{name: "switches after a streak", messages: []string{english, english}, want: "en-US"},
+		{name: "streak too short", messages: []string{english}, want: "zh-CN"},
+		{name: "streak broken", messages: []string{english, chinese, english}, want: "zh-CN"},
+		{name: "too short to tell", messages: []string{"ok", "ok", "ok"}, want: "zh-CN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := newTestConfig()
+			cfg.I18n.Languages = []string{"zh-CN", "en-US"}
+			cfg.I18n.AutoSwitch = true
+			cfg.I18n.AutoSwitchMessages = 2
+			h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+			
+			for _, text := range tt.messages {
+				h.autoSwitchLanguage(ctx, 42, true, text)
+			}
+			
+			if got := h.getUserLanguage(ctx, 42); got != tt.want {
+				t.Errorf("language = %q, want %q", got, tt.want)
+			}
+			// Private chats share their ID with the user, whose states must not collide
+			if value, _ := h.storage.GetUserState(ctx, 42, languageStreakKey); value != "" {
+				t.Errorf("streak stored as a user state %q", value)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// lastRequestKey stores the last AI request of a chat as a chat state
+const lastRequestKey = "last_request"
+
+// lastRequestTTL is how long the last request is kept for /lastrequest
+const lastRequestTTL = time.Hour
+
+// lastRequestContentChars is how much of each message is kept in the stash
+const lastRequestContentChars = 300
+
+// maxLastRequestChars keeps the rendered request within a Telegram message
+const maxLastRequestChars = 4000
+
+// lastRequest describes an assembled AI request for debugging. It only holds
+// what the bot sent on the chat's behalf; endpoint credentials are never stored.
+type lastRequest struct {
+	Time       time.Time
+	Model      string
+	Endpoint   string
+	Params     models.AIParams
+	Profile    string
+	Prefill    string
+	Knowledge  bool
+	TimeBudget time.Duration
+	Messages   []models.Message
+}
+
+// stashLastRequest records the request about to be sent for /lastrequest,
+// truncating message contents to keep the stash small
+func (h *MessageHandler) stashLastRequest(ctx context.Context, chatID int64, request lastRequest) {
+	messages := make([]models.Message, len(request.Messages))
+	for i, msg := range request.Messages {
+		messages[i] = models.Message{Role: msg.Role, Content: truncateRunes(msg.Content, lastRequestContentChars)}
+	}
+	request.Messages = messages
+	if model, err := h.aiService.GetModelByID(request.Model); err == nil {
+		request.Endpoint = model.EndpointName
+	}
+	
+	data, err := json.Marshal(request)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to encode last request")
+		return
+	}
+	if err := h.storage.SetChatState(ctx, chatID, lastRequestKey, string(data), lastRequestTTL); err != nil {
+		h.logger.WithError(err).Warn("Failed to stash last request")
+	}
+}
+
+// handleLastRequest handles /lastrequest command, showing the chat's last AI request (admin only)
+func (h *CommandHandler) handleLastRequest(ctx context.Context, chatID int64, userID int64) error {
+	if !h.isAdmin(userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 该命令仅限管理员使用"))
+		return err
+	}
+	
+	value, err := h.storage.GetChatState(ctx, chatID, lastRequestKey)
+	if err != nil || value == "" {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "暂无最近的请求记录（记录保留一小时）"))
+		return err
+	}
+	
+	var request lastRequest
+	if err := json.Unmarshal([]byte(value), &request); err != nil {
+		h.logger.WithError(err).Warn("Failed to decode last request")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请求记录已损坏"))
+		return err
+	}
+	
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, formatLastRequest(request)))
+	return err
+}
+
+// formatLastRequest renders a stashed request as plain text
+func formatLastRequest(request lastRequest) string {
+	var b strings.Builder
+	b.WriteString("🔍 最近一次 AI 请求\n\n")
+	b.WriteString(fmt.Sprintf("时间：%s\n", request.Time.Format("2006-01-02 15:04:05")))
+	b.WriteString(fmt.Sprintf("模型：%s", request.Model))
+	if request.Endpoint != "" {
+		b.WriteString(fmt.Sprintf("（端点 %s）", request.Endpoint))
+	}
+	b.WriteString("\n")
+	
+	var params []string
+	if request.Profile != "" {
+		params = append(params, "profile="+request.Profile)
+	}
+	if request.Params.Temperature != nil {
+		params = append(params, "temperature="+strconv.FormatFloat(*request.Params.Temperature, 'f', -1, 64))
+	}
+	if request.Params.TopP != nil {
+		params = append(params, "top_p="+strconv.FormatFloat(*request.Params.TopP, 'f', -1, 64))
+	}
+	if request.Params.MaxTokens > 0 {
+		params = append(params, "max_tokens="+strconv.Itoa(request.Params.MaxTokens))
+	}
+	if len(request.Params.Stop) > 0 {
+		params = append(params, "stop="+strings.Join(request.Params.Stop, "|"))
+	}
+	if request.TimeBudget > 0 {
+		params = append(params, "time_budget="+request.TimeBudget.String())
+	}
+	if len(params) == 0 {
+		params = append(params, "默认")
+	}
+	b.WriteString("参数：" + strings.Join(params, ", ") + "\n")
+	
+	knowledge := "否"
+	if request.Knowledge {
+		knowledge = "是"
+	}
+	b.WriteString("知识库检索：" + knowledge + "\n")
+	if request.Prefill != "" {
+		b.WriteString("预填充：" + truncateRunes(request.Prefill, 100) + "\n")
+	}
+	
+	b.WriteString(fmt.Sprintf("\n消息（%d 条）：\n", len(request.Messages)))
+	for i, msg := range request.Messages {
+		b.WriteString(fmt.Sprintf("\n[%d] %s:\n%s\n", i+1, msg.Role, msg.Content))
+	}
+	return truncateRunes(b.String(), maxLastRequestChars)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestStashLastRequest(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	h.stashLastRequest(ctx, 42, lastRequest{
+		Time:     time.Now(),
+		Model:    testModel,
+		Messages: []models.Message{{Role: "user", Content: strings.Repeat("长", lastRequestContentChars+10)}},
+	})
+	
+	value, err := h.storage.GetChatState(ctx, 42, lastRequestKey)
+	if err != nil || value == "" {
+		t.Fatalf("last request not stored as a chat state: %q, %v", value, err)
+	}
+	// Private chats share their ID with the user, whose states must not collide
+	if value, _ := h.storage.GetUserState(ctx, 42, lastRequestKey); value != "" {
+		t.Errorf("last request stored as a user state")
+	}
+	
+	var request lastRequest
+	if err := json.Unmarshal([]byte(value), &request); err != nil {
+		t.Fatalf("failed to decode the stored request: %v", err)
+	}
+	if request.Endpoint != "test" {
+		t.Errorf("endpoint = %q, want test", request.Endpoint)
+	}
+	if n := len([]rune(request.Messages[0].Content)); n > lastRequestContentChars+1 {
+		t.Errorf("message kept %d characters, want at most %d", n, lastRequestContentChars)
+	}
+}
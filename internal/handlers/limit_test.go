@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitCommand(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig()
+	cfg.Bot.AdminIDs = []int64{7}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RequestsPerMinute = 1
+	cfg.RateLimit.Burst = 1
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	steps := []struct {
+		userID   int64
+		command  string
+		wantText string
+		want     map[int64]int
+	}{
+		{userID: 8, command: "/limit 9 100", wantText: "仅限管理员", want: map[int64]int{}},
+		{userID: 7, command: "/limit 9", wantText: "用法：/limit", want: map[int64]int{}},
+		{userID: 7, command: "/limit nine 100", wantText: "无效的用户 ID", want: map[int64]int{}},
+		{userID: 7, command: "/limit 9 0", wantText: "请输入正整数", want: map[int64]int{}},
+		{userID: 7, command: "/limit 9 60000", wantText: "用户 9 的限流已设为每分钟 60000 次", want: map[int64]int{9: 60000}},
+		{userID: 7, command: "/limit 10 60000", wantText: "用户 10 的限流已设为每分钟 60000 次", want: map[int64]int{9: 60000, 10: 60000}},
+		{userID: 7, command: "/limit 10 RESET", wantText: "用户 10 已恢复默认限流（每分钟 1 次）", want: map[int64]int{9: 60000}},
+	}
+	for _, step := range steps {
+		runCommand(t, c, 42, step.userID, step.command)
+		if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, step.wantText) {
+			t.Errorf("%s answered %q, want %q", step.command, text, step.wantText)
+		}
+		overrides, err := h.storage.GetRateLimitOverrides(ctx)
+		if err != nil {
+			t.Fatalf("GetRateLimitOverrides: %v", err)
+		}
+		if len(overrides) != len(step.want) {
+			t.Errorf("after %s overrides = %v, want %v", step.command, overrides, step.want)
+		}
+		for userID, rpm := range step.want {
+			if overrides[userID] != rpm {
+				t.Errorf("after %s overrides = %v, want %v", step.command, overrides, step.want)
+			}
+		}
+	}
+	
+	// The raised user gets another request a moment later, the reset one doesn't
+	for _, userID := range []int64{9, 10} {
+		h.rateLimiter.Allow(userID)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if !h.rateLimiter.Allow(9) {
+		t.Error("user 9 rejected despite the raised limit")
+	}
+	if h.rateLimiter.Allow(10) {
+		t.Error("user 10 allowed after the reset to 1 request a minute")
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestLockedOutUserIgnored(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RequestsPerMinute = 1
+	cfg.RateLimit.Burst = 1
+	cfg.RateLimit.LockoutThreshold = 2
+	cfg.RateLimit.LockoutWindow = time.Minute
+	cfg.RateLimit.LockoutDuration = time.Hour
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer", nil
+	}}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	
+	// The first message is answered, the next two are told about the limit
+	for i := 1; i <= 3; i++ {
+		handleAndWait(t, h, privateMessage(42, 7, i, "hello"))
+	}
+	if service.requestCount() != 1 {
+		t.Fatalf("AI got %d requests, want 1", service.requestCount())
+	}
+	sent := len(telegram.texts("sendMessage", 42))
+	
+	// Once locked out, the user gets no reply at all
+	for i := 4; i <= 5; i++ {
+		handleAndWait(t, h, privateMessage(42, 7, i, "hello"))
+	}
+	if got := len(telegram.texts("sendMessage", 42)); got != sent {
+		t.Errorf("locked out user got %d more messages, want none", got-sent)
+	}
+	if service.requestCount() != 1 {
+		t.Errorf("AI got %d requests, want 1", service.requestCount())
+	}
+}
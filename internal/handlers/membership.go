@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/services/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// MembershipHandler handles the bot being added to or removed from chats
+type MembershipHandler struct {
+	bot       *tgbotapi.BotAPI
+	config    *config.Config
+	storage   *storage.Manager
+	localizer *i18n.Localizer
+	logger    *logrus.Logger
+}
+
+// NewMembershipHandler creates a new membership handler
+func NewMembershipHandler(
+	bot *tgbotapi.BotAPI,
+	cfg *config.Config,
+	storage *storage.Manager,
+	localizer *i18n.Localizer,
+	logger *logrus.Logger,
+) *MembershipHandler {
+	return &MembershipHandler{
+		bot:       bot,
+		config:    cfg,
+		storage:   storage,
+		localizer: localizer,
+		logger:    logger,
+	}
+}
+
+// HandleMyChatMember processes changes of the bot's own membership status
+func (h *MembershipHandler) HandleMyChatMember(ctx context.Context, update *tgbotapi.ChatMemberUpdated) error {
+	chatID := update.Chat.ID
+	joined, left := membershipTransition(update.OldChatMember, update.NewChatMember)
+
+	h.logger.WithFields(logrus.Fields{
+		"chatID":    chatID,
+		"oldStatus": update.OldChatMember.Status,
+		"newStatus": update.NewChatMember.Status,
+	}).Info("Bot membership changed")
+
+	switch {
+	case joined && !h.IsChatAllowed(&update.Chat):
+		h.HandleDisallowedChat(ctx, &update.Chat)
+		return nil
+	case joined:
+		return h.handleJoined(ctx, chatID)
+	case left:
+		return h.handleLeft(ctx, chatID)
+	}
+
+	return nil
+}
+
+// IsChatAllowed reports whether the bot may serve the chat according to
+// bot.access. Private chats are always allowed.
+func (h *MembershipHandler) IsChatAllowed(chat *tgbotapi.Chat) bool {
+	if chat.IsPrivate() {
+		return true
+	}
+
+	access := h.config.Bot.Access
+	if access.PrivateOnly {
+		return false
+	}
+	if len(access.AllowedChats) == 0 {
+		return true
+	}
+	for _, id := range access.AllowedChats {
+		if id == chat.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleDisallowedChat ignores a chat outside the allowlist, or posts a notice
+// and leaves it when bot.access.leave_unallowed is set
+func (h *MembershipHandler) HandleDisallowedChat(ctx context.Context, chat *tgbotapi.Chat) {
+	logger := h.logger.WithFields(logrus.Fields{
+		"chatID": chat.ID,
+		"title":  chat.Title,
+	})
+	if !h.config.Bot.Access.LeaveUnallowed {
+		logger.Debug("Ignoring update from chat that is not allowed")
+		return
+	}
+
+	notice := tgbotapi.NewMessage(chat.ID, h.localizer.Get(h.config.I18n.DefaultLanguage, i18n.MsgChatNotAllowed, nil))
+	if _, err := h.bot.Send(notice); err != nil {
+		logger.WithError(err).Warn("Failed to send not allowed notice")
+	}
+	if _, err := h.bot.Request(tgbotapi.LeaveChatConfig{ChatID: chat.ID}); err != nil {
+		logger.WithError(err).Error("Failed to leave chat that is not allowed")
+		return
+	}
+	logger.Info("Left chat that is not allowed")
+}
+
+// handleJoined initializes default settings and posts an intro
+func (h *MembershipHandler) handleJoined(ctx context.Context, chatID int64) error {
+	if !h.config.Bot.Membership.AnnounceOnJoin {
+		return nil
+	}
+
+	settings, err := h.storage.GetSettings(ctx, chatID)
+	if err != nil || settings == nil {
+		settings = defaultChatSettings(h.config)
+		if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+			h.logger.WithError(err).Warn("Failed to save default settings")
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, h.localizer.Get(settings.Language, i18n.MsgGroupIntro, nil))
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// handleLeft prunes the stored data of a chat the bot was removed from
+func (h *MembershipHandler) handleLeft(ctx context.Context, chatID int64) error {
+	if !h.config.Bot.Membership.PruneOnLeave {
+		return nil
+	}
+
+	if err := pruneChatData(ctx, h.storage, h.logger, chatID); err != nil {
+		return err
+	}
+
+	h.logger.WithField("chatID", chatID).Info("Pruned data of chat the bot left")
+	return nil
+}
+
+// pruneChatData deletes the stored context and settings of a chat
+func pruneChatData(ctx context.Context, store *storage.Manager, logger *logrus.Logger, chatID int64) error {
+	if err := store.DeleteContext(ctx, chatID); err != nil {
+		logger.WithError(err).Warn("Failed to delete context")
+	}
+	if err := store.DeleteSettings(ctx, chatID); err != nil {
+		logger.WithError(err).Warn("Failed to delete settings")
+		return err
+	}
+	return nil
+}
+
+// membershipTransition detects whether the bot joined or left a chat
+func membershipTransition(oldMember, newMember tgbotapi.ChatMember) (joined bool, left bool) {
+	wasMember := isActiveMember(oldMember)
+	isMember := isActiveMember(newMember)
+	return !wasMember && isMember, wasMember && !isMember
+}
+
+// isActiveMember reports whether a member status means the bot is present in the chat
+func isActiveMember(member tgbotapi.ChatMember) bool {
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true
+	case "restricted":
+		return member.IsMember
+	default: // left, kicked
+		return false
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMembershipTransition(t *testing.T) {
+	tests := []struct {
+		name       string
+		old, new   tgbotapi.ChatMember
+		wantJoined bool
+		wantLeft   bool
+	}{
+		{name: "added", old: tgbotapi.ChatMember{Status: "left"}, new: tgbotapi.ChatMember{Status: "member"}, wantJoined: true},
+		{name: "added as admin", old: tgbotapi.ChatMember{Status: "left"}, new: tgbotapi.ChatMember{Status: "administrator"}, wantJoined: true},
+		{name: "unbanned and added", old: tgbotapi.ChatMember{Status: "kicked"}, new: tgbotapi.ChatMember{Status: "member"}, wantJoined: true},
+		{name: "removed", old: tgbotapi.ChatMember{Status: "member"}, new: tgbotapi.ChatMember{Status: "left"}, wantLeft: true},
+		{name: "banned", old: tgbotapi.ChatMember{Status: "administrator"}, new: tgbotapi.ChatMember{Status: "kicked"}, wantLeft: true},
+		{name: "promoted", old: tgbotapi.ChatMember{Status: "member"}, new: tgbotapi.ChatMember{Status: "administrator"}},
+		{name: "restricted member", old: tgbotapi.ChatMember{Status: "member"}, new: tgbotapi.ChatMember{Status: "restricted", IsMember: true}},
+		{name: "restricted and removed", old: tgbotapi.ChatMember{Status: "restricted", IsMember: true}, new: tgbotapi.ChatMember{Status: "restricted"}, wantLeft: true},
+		{name: "still gone", old: tgbotapi.ChatMember{Status: "left"}, new: tgbotapi.ChatMember{Status: "kicked"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			joined, left := membershipTransition(tt.old, tt.new)
+			if joined != tt.wantJoined || left != tt.wantLeft {
+				t.Errorf("membershipTransition = joined %v, left %v, want %v, %v", joined, left, tt.wantJoined, tt.wantLeft)
+			}
+		})
+	}
+}
+
+// membershipChange returns the bot's status in chatID changing from oldStatus to newStatus
+func membershipChange(chatID int64, oldStatus, newStatus string) *tgbotapi.ChatMemberUpdated {
+	return &tgbotapi.ChatMemberUpdated{
+		Chat:          tgbotapi.Chat{ID: chatID, Type: "supergroup", Title: "Group"},
+		OldChatMember: tgbotapi.ChatMember{Status: oldStatus},
+		NewChatMember: tgbotapi.ChatMember{Status: newStatus},
+	}
+}
+
+func TestHandleMyChatMember(t *testing.T) {
+	ctx := context.Background()
+	const chatID = -100
+	tests := []struct {
+		name         string
+		announce     bool
+		prune        bool
+		wantIntro    bool
+		wantSettings bool // after joining and before leaving
+		wantKept     bool // context and settings after leaving
+	}{
+		{name: "announce and prune", announce: true, prune: true, wantIntro: true, wantSettings: true},
+		{name: "both off", wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Bot.Membership.AnnounceOnJoin = tt.announce
+			cfg.Bot.Membership.PruneOnLeave = tt.prune
+			h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+			membership := NewMembershipHandler(h.bot, cfg, h.storage, h.localizer, h.logger)
+			
+			if err := membership.HandleMyChatMember(ctx, membershipChange(chatID, "left", "member")); err != nil {
+				t.Fatalf("joining: %v", err)
+			}
+			intro := telegram.texts("sendMessage", chatID)
+			if gotIntro := len(intro) == 1 && strings.Contains(intro[0], "我是 AI 助手"); gotIntro != tt.wantIntro {
+				t.Errorf("sent %q on joining, want intro %v", intro, tt.wantIntro)
+			}
+			if settings, _ := h.storage.GetSettings(ctx, chatID); (settings != nil) != tt.wantSettings {
+				t.Errorf("settings after joining = %+v, want saved %v", settings, tt.wantSettings)
+			}
+			
+			// The chat is used, then the bot is removed
+			saveChatSettings(t, h, chatID, func(*models.ChatSettings) {})
+			chatCtx := &models.ChatContext{ChatID: chatID, Messages: []models.Message{{Role: "system"}}, LastActivity: time.Now()}
+			if err := h.storage.SaveContext(ctx, chatCtx); err != nil {
+				t.Fatalf("SaveContext: %v", err)
+			}
+			if err := membership.HandleMyChatMember(ctx, membershipChange(chatID, "member", "kicked")); err != nil {
+				t.Fatalf("leaving: %v", err)
+			}
+			settings, _ := h.storage.GetSettings(ctx, chatID)
+			stored, _ := h.storage.GetContext(ctx, chatID)
+			if kept := settings != nil && stored != nil; kept != tt.wantKept {
+				t.Errorf("after leaving settings = %v, context = %v, want kept %v", settings, stored, tt.wantKept)
+			}
+			if sent := telegram.texts("sendMessage", chatID); len(sent) != len(intro) {
+				t.Errorf("sent %q on leaving, want nothing", sent[len(intro):])
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxMemories caps how many facts a user can pin
+	maxMemories = 20
+	// maxMemoryRunes caps the length of a single fact
+	maxMemoryRunes = 200
+	// maxMemoriesTotalRunes caps the combined length injected into the prompt
+	maxMemoriesTotalRunes = 2000
+)
+
+// injectMemories appends the user's pinned facts to the system prompt
+func injectMemories(messages []models.Message, memories []string) []models.Message {
+	if len(memories) == 0 || len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	
+	var facts strings.Builder
+	facts.WriteString("\n\n关于用户的已知信息（请在回答时参考）：")
+	for _, memory := range memories {
+		facts.WriteString("\n- ")
+		facts.WriteString(memory)
+	}
+	
+	messages[0].Content += facts.String()
+	return messages
+}
+
+// hasMemories reports whether the user pinned any facts. A failed lookup
+// counts as having some, so a personal answer is never shared by mistake.
+func (h *MessageHandler) hasMemories(ctx context.Context, userID int64) bool {
+	memories, err := h.storage.GetMemories(ctx, userID)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to get memories")
+		return true
+	}
+	return len(memories) > 0
+}
+
+// handleRemember handles /remember command, pinning a fact about the user
+func (h *CommandHandler) handleRemember(ctx context.Context, chatID int64, userID int64, fact string) error {
+	fact = strings.TrimSpace(fact)
+	if fact == "" {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "用法：/remember <要记住的信息>\n例如：/remember 我叫小明，是一名前端工程师"))
+		return err
+	}
+	if len([]rune(fact)) > maxMemoryRunes {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 单条信息不能超过 %d 个字符", maxMemoryRunes)))
+		return err
+	}
+	
+	memories, err := h.storage.GetMemories(ctx, userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get memories")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 读取记忆失败，请稍后重试"))
+		return err
+	}
+	
+	if len(memories) >= maxMemories {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 最多只能记住 %d 条信息，请先使用 /forget 删除一些", maxMemories)))
+		return err
+	}
+	
+	total := len([]rune(fact))
+	for _, memory := range memories {
+		total += len([]rune(memory))
+	}
+	if total > maxMemoriesTotalRunes {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 记忆总长度不能超过 %d 个字符，请先使用 /forget 删除一些", maxMemoriesTotalRunes)))
+		return err
+	}
+	
+	memories = append(memories, fact)
+	if err := h.storage.SaveMemories(ctx, userID, memories); err != nil {
+		h.logger.WithError(err).Error("Failed to save memories")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ 已记住（第 %d 条）：%s", len(memories), fact)))
+	return err
+}
+
+// handleForget handles /forget command, removing one fact by number or all facts
+func (h *CommandHandler) handleForget(ctx context.Context, chatID int64, userID int64, args string) error {
+	args = strings.ToLower(strings.TrimSpace(args))
+	if args == "" {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "用法：/forget <编号> 或 /forget all\n使用 /memories 查看编号"))
+		return err
+	}
+	
+	memories, err := h.storage.GetMemories(ctx, userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get memories")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 读取记忆失败，请稍后重试"))
+		return err
+	}
+	
+	var text string
+	if args == "all" {
+		memories = nil
+		text = "✅ 已忘记所有信息"
+	} else {
+		index, err := strconv.Atoi(args)
+		if err != nil || index < 1 || index > len(memories) {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 无效的编号，使用 /memories 查看编号"))
+			return err
+		}
+		text = "✅ 已忘记：" + memories[index-1]
+		memories = append(memories[:index-1], memories[index:]...)
+	}
+	
+	if err := h.storage.SaveMemories(ctx, userID, memories); err != nil {
+		h.logger.WithError(err).Error("Failed to save memories")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// handleMemories handles /memories command, listing the user's pinned facts
+func (h *CommandHandler) handleMemories(ctx context.Context, chatID int64, userID int64) error {
+	memories, err := h.storage.GetMemories(ctx, userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get memories")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 读取记忆失败，请稍后重试"))
+		return err
+	}
+	
+	if len(memories) == 0 {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "🧠 还没有记住任何信息\n使用 /remember <信息> 让我记住"))
+		return err
+	}
+	
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("🧠 我记住的信息（%d/%d）：\n\n", len(memories), maxMemories))
+	for i, memory := range memories {
+		text.WriteString(fmt.Sprintf("%d. %s\n", i+1, memory))
+	}
+	text.WriteString("\n使用 /forget <编号> 删除")
+	
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, text.String()))
+	return err
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// runCommand hands a command from userID in chatID to c
+func runCommand(t *testing.T, c *CommandHandler, chatID, userID int64, text string) {
+	t.Helper()
+	if err := c.HandleCommand(context.Background(), command(chatID, userID, text)); err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+}
+
+func TestMemoryCommands(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	steps := []struct {
+		command  string
+		wantText string
+		want     []string
+	}{
+		{command: "/remember", wantText: "用法：/remember"},
+		{command: "/remember  我叫小明 ", wantText: "已记住（第 1 条）：我叫小明", want: []string{"我叫小明"}},
+		{command: "/remember 我喜欢 Go", wantText: "已记住（第 2 条）：我喜欢 Go", want: []string{"我叫小明", "我喜欢 Go"}},
+		{command: "/remember 我住在上海", want: []string{"我叫小明", "我喜欢 Go", "我住在上海"}},
+		{command: "/memories", wantText: "1. 我叫小明\n2. 我喜欢 Go\n3. 我住在上海", want: []string{"我叫小明", "我喜欢 Go", "我住在上海"}},
+		{command: "/forget 2", wantText: "已忘记：我喜欢 Go", want: []string{"我叫小明", "我住在上海"}},
+		{command: "/forget 3", wantText: "无效的编号", want: []string{"我叫小明", "我住在上海"}},
+		{command: "/forget one", wantText: "无效的编号", want: []string{"我叫小明", "我住在上海"}},
+		{command: "/memories", wantText: "1. 我叫小明\n2. 我住在上海", want: []string{"我叫小明", "我住在上海"}},
+		{command: "/forget ALL", wantText: "已忘记所有信息"},
+		{command: "/memories", wantText: "还没有记住任何信息"},
+	}
+	for _, step := range steps {
+		runCommand(t, c, 42, 7, step.command)
+		
+		sent := telegram.texts("sendMessage", 42)
+		if text := lastText(sent); !strings.Contains(text, step.wantText) {
+			t.Errorf("%s answered %q, want %q", step.command, text, step.wantText)
+		}
+		memories, err := h.storage.GetMemories(ctx, 7)
+		if err != nil {
+			t.Fatalf("GetMemories: %v", err)
+		}
+		if strings.Join(memories, "|") != strings.Join(step.want, "|") {
+			t.Errorf("after %s memories are %q, want %q", step.command, memories, step.want)
+		}
+	}
+	
+	// Memories belong to the user, not the chat
+	if memories, _ := h.storage.GetMemories(ctx, 42); len(memories) != 0 {
+		t.Errorf("chat 42 has memories %q, want them kept by user", memories)
+	}
+}
+
+func TestRememberLimits(t *testing.T) {
+	ctx := context.Background()
+	
+	t.Run("fact too long", func(t *testing.T) {
+		h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+		c := newTestCommandHandler(h)
+		
+		runCommand(t, c, 42, 7, "/remember "+strings.Repeat("长", maxMemoryRunes+1))
+		if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "单条信息不能超过") {
+			t.Errorf("answered %q, want the fact refused", text)
+		}
+		if memories, _ := h.storage.GetMemories(ctx, 7); len(memories) != 0 {
+			t.Errorf("memories %q, want none", memories)
+		}
+	})
+	
+	t.Run("too many facts", func(t *testing.T) {
+		h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+		c := newTestCommandHandler(h)
+		
+		for i := 0; i < maxMemories; i++ {
+			runCommand(t, c, 42, 7, "/remember fact")
+		}
+		runCommand(t, c, 42, 7, "/remember one more")
+		if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "最多只能记住") {
+			t.Errorf("answered %q, want the fact refused", text)
+		}
+		if memories, _ := h.storage.GetMemories(ctx, 7); len(memories) != maxMemories {
+			t.Errorf("got %d memories, want %d", len(memories), maxMemories)
+		}
+	})
+	
+	t.Run("too long in total", func(t *testing.T) {
+		h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+		c := newTestCommandHandler(h)
+		
+		fact := strings.Repeat("长", maxMemoryRunes)
+		full := maxMemoriesTotalRunes / maxMemoryRunes
+		for i := 0; i < full; i++ {
+			runCommand(t, c, 42, 7, "/remember "+fact)
+		}
+		runCommand(t, c, 42, 7, "/remember x")
+		if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "记忆总长度不能超过") {
+			t.Errorf("answered %q, want the fact refused", text)
+		}
+		if memories, _ := h.storage.GetMemories(ctx, 7); len(memories) != full {
+			t.Errorf("got %d memories, want %d", len(memories), full)
+		}
+	})
+}
+
+func TestMemoriesSurviveClear(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.DefaultSystemPrompt = "You are a helpful assistant."
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer", nil
+	}}
+	h, _ := newTestMessageHandler(t, cfg, service)
+	c := newTestCommandHandler(h)
+	
+	runCommand(t, c, 42, 7, "/remember 我叫小明")
+	handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+	pressButton(t, c, 42, 7, "clear:all")
+	handleAndWait(t, h, privateMessage(42, 7, 2, "what is my name"))
+	
+	if service.requestCount() != 2 {
+		t.Fatalf("AI got %d requests, want 2", service.requestCount())
+	}
+	request := service.requests[1]
+	if len(request) != 2 || request[0].Role != "system" {
+		t.Fatalf("request after the clear is %+v, want the system prompt and the question", request)
+	}
+	if !strings.Contains(request[0].Content, "- 我叫小明") {
+		t.Errorf("system prompt %q misses the pinned fact", request[0].Content)
+	}
+	
+	// The fact is injected per request, not stored in the context
+	chatCtx, err := h.storage.GetContext(context.Background(), 42)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("GetContext = %v, %v", chatCtx, err)
+	}
+	for _, msg := range chatCtx.Messages {
+		if strings.Contains(msg.Content, "我叫小明") {
+			t.Errorf("stored context holds the fact in %+v", msg)
+		}
+	}
+}
+
+func TestInjectMemories(t *testing.T) {
+	system := models.Message{Role: "system", Content: "You are a helpful assistant."}
+	user := models.Message{Role: "user", Content: "hi"}
+	
+	got := injectMemories([]models.Message{system, user}, []string{"a", "b"})
+	if want := system.Content + "\n\n关于用户的已知信息（请在回答时参考）：\n- a\n- b"; got[0].Content != want {
+		t.Errorf("system prompt %q, want %q", got[0].Content, want)
+	}
+	
+	// Without a system prompt or memories the messages are left alone
+	if got := injectMemories([]models.Message{user}, []string{"a"}); len(got) != 1 || got[0] != user {
+		t.Errorf("messages without a system prompt became %+v", got)
+	}
+	if got := injectMemories([]models.Message{system, user}, nil); got[0] != system {
+		t.Errorf("system prompt without memories became %+v", got[0])
+	}
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"context"
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/cf-ai-tgbot-go/internal/models"
@@ -48,7 +47,7 @@ func (h *CommandHandler) handleMentionCallback(ctx context.Context, chatID int64
 			rows = append(rows, []tgbotapi.InlineKeyboardButton{
 				tgbotapi.NewInlineKeyboardButtonData(
 					fmt.Sprintf("🗑 %s", word),
-					fmt.Sprintf("mention_del:%d", i),
+					indexedCallbackData("mention_del", i, settings.MentionWords),
 				),
 			})
 		}
@@ -96,18 +95,17 @@ func (h *CommandHandler) handleMentionCallback(ctx context.Context, chatID int64
 	default:
 		// Handle delete specific word
 		if strings.HasPrefix(action, "del:") {
-			indexStr := strings.TrimPrefix(action, "del:")
-			index, err := strconv.Atoi(indexStr)
-			if err != nil {
-				h.bot.Request(tgbotapi.NewCallback(callbackID, "无效的索引"))
-				return nil
-			}
-			
 			// Get current settings
 			settings, err := h.storage.GetSettings(ctx, chatID)
-			if err != nil || settings == nil || index < 0 || index >= len(settings.MentionWords) {
-				h.bot.Request(tgbotapi.NewCallback(callbackID, "无效的索引"))
-				return nil
+			if err != nil || settings == nil {
+				settings = &models.ChatSettings{}
+			}
+			
+			// The mention words changed since the menu was opened; show the current list
+			index, ok := parseIndexedCallback(strings.TrimPrefix(action, "del:"), settings.MentionWords)
+			if !ok {
+				h.answerStaleMenu(chatID, messageID, lang, callbackID, nil)
+				return h.handleActionCallback(ctx, chatID, messageID, userID, "mention_words", lang, "")
 			}
 			
 			// Remove the word
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"os"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/cf-ai-tgbot-go/internal/config"
@@ -29,8 +31,16 @@ type MessageHandler struct {
 	cache            cache.Service
 	rateLimiter      middleware.RateLimiter
 	security         *middleware.SecurityMiddleware
+	metrics          *middleware.Metrics
 	localizer        *i18n.Localizer
 	logger           *logrus.Logger
+	postProcessors   []postProcessor
+	toolSummaries    map[string]*template.Template
+	chatLocks        *ChatLocker
+	workers          *workerPool
+	username         *botUsername
+	errorLog         *ErrorLog
+	memberCounts     *memberCounter
 }
 
 // NewMessageHandler creates a new message handler
@@ -43,8 +53,20 @@ func NewMessageHandler(
 	cache cache.Service,
 	rateLimiter middleware.RateLimiter,
 	localizer *i18n.Localizer,
+	errorLog *ErrorLog,
+	chatLocks *ChatLocker,
 	logger *logrus.Logger,
 ) *MessageHandler {
+	// Rules are validated when the config loads, so this only guards direct construction
+	postProcessors, err := compilePostProcessors(cfg.PostProcessors)
+	if err != nil {
+		logger.WithError(err).Error("Invalid post processor, answers won't be post-processed")
+	}
+	toolSummaries, err := compileToolSummaries(cfg.Tools)
+	if err != nil {
+		logger.WithError(err).Error("Invalid tool summary, tool results will only show the tool name")
+	}
+	
 	return &MessageHandler{
 		config:           cfg,
 		bot:              bot,
@@ -54,8 +76,16 @@ func NewMessageHandler(
 		cache:            cache,
 		rateLimiter:      rateLimiter,
 		security:         middleware.NewSecurityMiddleware(logger),
+		metrics:          middleware.NewMetrics(),
 		localizer:        localizer,
 		logger:           logger,
+		postProcessors:   postProcessors,
+		toolSummaries:    toolSummaries,
+		chatLocks:        chatLocks,
+		workers:          newWorkerPool(cfg.Bot.Workers, cfg.Bot.QueueSize),
+		username:         &botUsername{value: bot.Self.UserName},
+		errorLog:         errorLog,
+		memberCounts:     newMemberCounter(telegramMemberCount(bot)),
 	}
 }
 
@@ -74,7 +104,18 @@ func (h *MessageHandler) HandleMessage(ctx context.Context, update *tgbotapi.Upd
 	userID := update.Message.From.ID
 	messageText := update.Message.Text
 
+	// Silently ignore users locked out for spamming
+	if h.rateLimiter.IsLockedOut(userID) {
+		h.logger.WithField("userID", userID).Debug("Ignoring message from locked out user")
+		return nil
+	}
+
 	// Check if user is in configuration state
+	if h.config.Models.SafeMode {
+		if handled, err := h.refuseLockedConfiguration(ctx, update); handled {
+			return err
+		}
+	}
 	configuringEndpoint, err := h.storage.GetUserState(ctx, userID, "configuring_endpoint")
 	if err == nil && configuringEndpoint != "" {
 		// Handle endpoint configuration
@@ -94,6 +135,12 @@ func (h *MessageHandler) HandleMessage(ctx context.Context, update *tgbotapi.Upd
 		return h.handleAddMentionWord(ctx, update)
 	}
 	
+	// Check if adding keyword
+	addingKeyword, err := h.storage.GetUserState(ctx, userID, "adding_keyword")
+	if err == nil && addingKeyword == "true" {
+		return h.handleAddKeyword(ctx, update)
+	}
+	
 	// Check if searching in knowledge base
 	searchingKnowledge, err := h.storage.GetUserState(ctx, userID, "knowledge_search")
 	if err == nil && searchingKnowledge == "true" {
@@ -137,18 +184,29 @@ func (h *MessageHandler) HandleMessage(ctx context.Context, update *tgbotapi.Upd
 		return nil
 	}
 
+	// Follow the language the user writes in, when enabled
+	h.autoSwitchLanguage(ctx, chatID, update.Message.Chat.IsPrivate(), messageText)
+
 	// Send thinking message
 	lang := h.getUserLanguage(ctx, chatID)
 	thinkingMsg := tgbotapi.NewMessage(chatID, h.localizer.Get(lang, i18n.MsgProcessing, nil))
 	thinkingMsg.ReplyToMessageID = update.Message.MessageID
 	sentMsg, err := h.bot.Send(thinkingMsg)
 	if err != nil {
+		// Don't spend an AI request on a chat we can't answer in
+		if h.handleSendFailure(ctx, chatID, err) {
+			return nil
+		}
 		h.logger.WithError(err).Error("Failed to send thinking message")
 		return err
 	}
 
-	// Process message in background
-	go h.processMessage(ctx, update, sentMsg.MessageID, lang)
+	// Process message in background, refusing it right away when overloaded
+	if !h.workers.trySubmit(func() { h.processMessage(ctx, update, sentMsg.MessageID, lang) }) {
+		h.logger.WithField("chatID", chatID).Warn("Processing queue full, refusing message")
+		h.metrics.RecordRequestShed()
+		h.sendErrorMessage(chatID, sentMsg.MessageID, lang, i18n.MsgBusy)
+	}
 
 	return nil
 }
@@ -166,44 +224,80 @@ func (h *MessageHandler) processMessage(ctx context.Context, update *tgbotapi.Up
 	if !update.Message.Chat.IsPrivate() {
 		settings, _ := h.storage.GetSettings(ctx, chatID)
 		if settings != nil && len(settings.MentionWords) > 0 {
-			messageLower := strings.ToLower(messageText)
 			for _, mention := range settings.MentionWords {
-				if strings.Contains(messageLower, strings.ToLower(mention)) {
+				if containsWord(messageText, mention) {
 					triggeredByMention = true
-					// Add a friendly greeting when triggered by mention
-					cleanedMessage = h.addMentionGreeting(cleanedMessage, mention, update)
+					// Add a friendly greeting when triggered by mention, unless the
+					// chat was greeted recently
+					if !h.greetingOnCooldown(ctx, chatID) {
+						cleanedMessage = h.addMentionGreeting(cleanedMessage, mention, settings.Personality, update)
+					}
 					break
 				}
 			}
 		}
 	}
 
+	// Without any model every request would fail with "model not found"
+	if len(h.aiService.GetModelsForUser(userID)) == 0 {
+		h.logger.WithField("chatID", chatID).Warn("No models configured")
+		h.sendErrorMessage(chatID, thinkingMsgID, lang, noModelsMessage(h.config.Bot.AdminIDs, userID))
+		return
+	}
+
+	// Hold the chat while its context is read; the AI call runs unlocked and
+	// saveExchange adds the turns to the context as it is by then
+	unlock := h.chatLocks.lock(chatID)
+	defer unlock()
+
 	// Get or create context
-	chatCtx, err := h.getOrCreateContext(ctx, chatID)
+	chatCtx, expired, err := h.getOrCreateContext(ctx, chatID, userID)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get chat context")
+		h.recordError(chatID, userID, "", err)
 		h.sendError(chatID, thinkingMsgID, lang)
 		return
 	}
 	
-	// Get user settings and update model if different
+	// Continue from the replied-to branch of the conversation
+	h.branchFromReply(chatCtx, update.Message)
+	
+	// Pick the model from the user's choice, the group lock and the allowlist
+	userModel := ""
 	userSettings, err := h.storage.GetUserSettings(ctx, userID)
-	if err == nil && userSettings != nil && userSettings.Model != "" {
-		chatCtx.Settings.Model = userSettings.Model
-		h.logger.WithFields(logrus.Fields{
-			"userID": userID,
-			"model": userSettings.Model,
-		}).Debug("Updated chat context with user's model")
+	if err == nil && userSettings != nil {
+		userModel = userSettings.Model
+	}
+	chatCtx.Settings.AIParams.Model = resolveModel(&chatCtx.Settings, userModel)
+	// A model removed from the configuration would fail every request, and
+	// another user's private model can't be used on their key
+	if model, err := h.aiService.GetModelByID(chatCtx.Settings.AIParams.Model); err != nil || !model.VisibleTo(userID) {
+		if err != nil {
+			h.logger.WithError(err).WithField("chatID", chatID).Warn("Chat model unavailable, using the default")
+		}
+		chatCtx.Settings.AIParams.Model = h.config.Models.Default
 	}
+	h.logger.WithFields(logrus.Fields{
+		"userID": userID,
+		"model":  chatCtx.Settings.AIParams.Model,
+	}).Debug("Resolved model for request")
 
 	// Get settings
 	settings := &chatCtx.Settings
 
-	// Check cache
-	cachedResponse, found := h.cache.Get(ctx, cleanedMessage, settings.Model)
-	if found {
-		h.sendResponse(chatID, thinkingMsgID, cachedResponse, lang)
-		return
+	// Check cache (prefilled requests are steered per chat and never cached,
+	// a reset context must be saved so the notice is shown only once,
+	// follow-up instructions only make sense for the answer they follow, and
+	// answers shaped by the user's pinned memories are personal)
+	useCache := settings.Prefill == "" && !expired && update.CallbackQuery == nil && !h.hasMemories(ctx, userID)
+	scope := cacheScope(settings, triggeredByMention)
+	if useCache {
+		if cachedResponse, found := h.cache.Get(ctx, cleanedMessage, settings.AIParams.Model, scope); found {
+			response := h.renderResponse(cachedResponse, settings)
+			h.sendResponse(chatID, thinkingMsgID, appendFooter(response, h.responseFooter(settings)), lang)
+			h.attachFollowUps(chatID, thinkingMsgID, lang)
+			return
+		}
 	}
 
 	// Add user message to context
@@ -224,54 +318,175 @@ func (h *MessageHandler) processMessage(ctx context.Context, update *tgbotapi.Up
 		}()
 	}
 
-	// Trim context if needed
+	// Trim context if needed (recorded when the trimmed context is saved)
 	h.trimContext(chatCtx)
 
 	// Get AI response with knowledge base
 	aiCtx, cancel := context.WithTimeout(ctx, 2*time.Minute) // Add timeout for AI request
 	defer cancel()
 	
+	requestMessages := h.buildRequestMessages(ctx, chatCtx, userID)
+	h.metrics.RecordContextSize(len(requestMessages))
+	unlock()
+	
 	var aiResponse string
-	if h.knowledgeService != nil && h.config.Knowledge.Enabled {
-		aiResponse, err = h.aiService.GetResponseWithKnowledge(aiCtx, chatCtx.Messages, settings.Model, h.knowledgeService)
+	var usage ai.Usage
+	requestOpts := []ai.RequestOption{
+		ai.WithUsage(&usage),
+		ai.WithUser(userID),
+		ai.WithPrefill(settings.Prefill),
+		ai.WithKnowledgeMaxChars(h.config.Knowledge.MaxDocChars),
+		ai.WithKnowledgeMaxTokens(h.config.Knowledge.MaxContextTokens),
+		ai.WithKnowledgeFullDocs(knowledgeFullDocs(h.config, settings)),
+		ai.WithKnowledgePosition(h.config.Knowledge.Position),
+	}
+	if retries := h.config.Models.InteractiveRetries; retries != 0 {
+		requestOpts = append(requestOpts, ai.WithRetries(retries))
+	}
+	requestOpts = append(requestOpts, profileRequestOptions(h.config, settings.Profile)...)
+	// Explicit chat parameters override the profile
+	requestOpts = append(requestOpts, ai.WithParams(settings.AIParams))
+	var budgetTruncated bool
+	if budget := responseTimeBudget(h.config, settings); budget > 0 {
+		requestOpts = append(requestOpts, ai.WithTimeBudget(budget, &budgetTruncated))
+	}
+	var knowledgeDedup *ai.KnowledgeDedup
+	if h.config.Knowledge.Dedup {
+		knowledgeDedup = &ai.KnowledgeDedup{Seen: injectedKnowledge(chatCtx)}
+		requestOpts = append(requestOpts, ai.WithKnowledgeDedup(knowledgeDedup))
+	}
+	useKnowledge := h.usesKnowledge(settings.AIParams.Model)
+	h.stashLastRequest(ctx, chatID, lastRequest{
+		Time:       time.Now(),
+		Model:      settings.AIParams.Model,
+		Params:     settings.AIParams,
+		Profile:    settings.Profile,
+		Prefill:    settings.Prefill,
+		Knowledge:  useKnowledge,
+		TimeBudget: responseTimeBudget(h.config, settings),
+		Messages:   requestMessages,
+	})
+	if useKnowledge {
+		aiResponse, err = h.aiService.GetResponseWithKnowledge(aiCtx, requestMessages, settings.AIParams.Model, h.knowledgeService, requestOpts...)
 	} else {
-		aiResponse, err = h.aiService.GetResponse(aiCtx, chatCtx.Messages, settings.Model)
+		aiResponse, err = h.aiService.GetResponse(aiCtx, requestMessages, settings.AIParams.Model, requestOpts...)
 	}
 	
 	if err != nil {
 		h.logger.WithError(err).WithFields(logrus.Fields{
 			"chatID": chatID,
 			"userID": userID,
-			"model":  settings.Model,
+			"model":  settings.AIParams.Model,
 		}).Error("Failed to get AI response")
+		h.recordError(chatID, userID, settings.AIParams.Model, err)
+		if errors.Is(err, ai.ErrContentFiltered) {
+			h.sendErrorMessage(chatID, thinkingMsgID, lang, i18n.MsgContentFiltered)
+			return
+		}
+		if errors.Is(err, ai.ErrEndpointRateLimited) {
+			if model, err := h.aiService.GetModelByID(settings.AIParams.Model); err == nil {
+				h.metrics.RecordEndpointRateLimited(model.EndpointName)
+			}
+			h.sendErrorMessage(chatID, thinkingMsgID, lang, i18n.MsgBusy)
+			return
+		}
 		h.sendError(chatID, thinkingMsgID, lang)
 		return
 	}
 
-	// Process thinking tags
-	processedResponse := h.processThinkingTags(aiResponse, settings.ShowThink)
+	// Record usage and estimated cost
+	h.recordUsage(ctx, userID, settings.AIParams.Model, usage)
 
-	// Add AI response to context
-	chatCtx.Messages = append(chatCtx.Messages, models.Message{
-		Role:    "assistant",
-		Content: aiResponse,
-	})
-
-	// Update last activity
-	chatCtx.LastActivity = time.Now()
+	processedResponse := h.renderResponse(aiResponse, settings)
+	
+	// Mention how much the model thought when its reasoning is hidden
+	if settings.ShowThinkStats && !settings.ShowThink && usage.ReasoningTokens > 0 {
+		processedResponse += "\n\n" + h.localizer.Get(lang, i18n.MsgThinkStats, map[string]interface{}{
+			"Tokens": usage.ReasoningTokens,
+		})
+	}
+	
+	// Tell the user the answer was cut short; the context keeps the model's words only
+	if budgetTruncated {
+		processedResponse += "\n\n" + h.localizer.Get(lang, i18n.MsgTimeBudgetCut, nil)
+	}
 
-	// Save context
-	if err := h.storage.SaveContext(ctx, chatCtx); err != nil {
+	// Add the exchange to the context
+	if err := h.saveExchange(ctx, update.Message, cleanedMessage, aiResponse, knowledgeDedup, thinkingMsgID); err != nil {
 		h.logger.WithError(err).Error("Failed to save context")
+		h.recordError(chatID, userID, settings.AIParams.Model, err)
+	}
+
+	// Cache the model's own words, rendered again for every chat that hits
+	// them (answers cut off by the time budget are incomplete)
+	if useCache && !budgetTruncated {
+		if err := h.cache.Set(ctx, cleanedMessage, settings.AIParams.Model, scope, aiResponse); err != nil {
+			h.logger.WithError(err).Warn("Failed to cache response")
+		}
+	}
+
+	// The footer is added after caching so cached responses don't carry it twice
+	processedResponse = appendFooter(processedResponse, h.responseFooter(settings))
+
+	// Let the user know the earlier conversation was dropped
+	if expired {
+		processedResponse = h.localizer.Get(lang, i18n.MsgContextExpired, nil) + "\n\n" + processedResponse
 	}
 
-	// Cache response
-	if err := h.cache.Set(ctx, cleanedMessage, settings.Model, processedResponse); err != nil {
-		h.logger.WithError(err).Warn("Failed to cache response")
+	// Send long responses as a document instead of a wall of messages
+	if shouldSendAsFile(processedResponse, fileResponseThreshold(h.config, settings)) {
+		h.sendResponseAsFile(chatID, thinkingMsgID, processedResponse, lang)
+	} else {
+		h.sendResponse(chatID, thinkingMsgID, processedResponse, lang)
+	}
+
+	// Offer the configured follow-ups below the answer
+	h.attachFollowUps(chatID, thinkingMsgID, lang)
+
+	// Summarize a long conversation once the user has the answer
+	h.summarizeChat(ctx, chatID, userID, settings.AIParams.Model)
+}
+
+// renderResponse turns the model's answer into the text shown in the chat:
+// thinking tags are handled per the chat's setting, the configured
+// transformations applied and long answers truncated
+func (h *MessageHandler) renderResponse(aiResponse string, settings *models.ChatSettings) string {
+	response := h.processThinkingTags(aiResponse, settings.ShowThink)
+	response = applyPostProcessors(response, h.postProcessors)
+	return truncateResponse(response, settings.MaxResponseChars)
+}
+
+// usesKnowledge reports whether requests to the model should search the
+// knowledge base; models can opt out of (or back into) the global setting
+func (h *MessageHandler) usesKnowledge(modelID string) bool {
+	if h.knowledgeService == nil {
+		return false
+	}
+	model, err := h.aiService.GetModelByID(modelID)
+	if err != nil {
+		return h.config.Knowledge.Enabled
+	}
+	return model.UsesKnowledge(h.config.Knowledge.Enabled)
+}
+
+// recordUsage updates the user's stats with the request usage and its estimated cost
+func (h *MessageHandler) recordUsage(ctx context.Context, userID int64, modelID string, usage ai.Usage) {
+	if err := h.storage.IncrementUserStats(ctx, userID); err != nil {
+		h.logger.WithError(err).Warn("Failed to increment user stats")
+	}
+
+	// Models without pricing only contribute token counts
+	var cost float64
+	if model, err := h.aiService.GetModelByID(modelID); err == nil {
+		if c, ok := model.EstimateCost(usage); ok {
+			cost = c
+			h.metrics.RecordAICost(modelID, cost)
+		}
 	}
 
-	// Send response
-	h.sendResponse(chatID, thinkingMsgID, processedResponse, lang)
+	if err := h.storage.RecordUsage(ctx, userID, modelID, usage.PromptTokens, usage.CompletionTokens, cost); err != nil {
+		h.logger.WithError(err).Warn("Failed to record usage")
+	}
 }
 
 func (h *MessageHandler) shouldRespond(ctx context.Context, update *tgbotapi.Update) (bool, error) {
@@ -285,6 +500,12 @@ func (h *MessageHandler) shouldRespond(ctx context.Context, update *tgbotapi.Upd
 		"messageText": messageText,
 	}).Debug("shouldRespond check started")
 
+	// Stay silent while an admin has paused the bot in this chat
+	if settings, err := h.storage.GetSettings(ctx, chatID); err == nil && settings != nil && settings.Paused {
+		h.logger.Debug("Not responding: chat paused")
+		return false, nil
+	}
+
 	// Always respond in private chat
 	if message.Chat.IsPrivate() {
 		h.logger.Debug("Responding: private chat")
@@ -292,14 +513,14 @@ func (h *MessageHandler) shouldRespond(ctx context.Context, update *tgbotapi.Upd
 	}
 
 	// Check if bot is mentioned
-	botUsername := "@" + h.bot.Self.UserName
+	botUsername := h.botMention()
 	if strings.Contains(messageText, strings.ToLower(botUsername)) {
 		h.logger.Debug("Responding: bot mentioned")
 		return true, nil
 	}
 
 	// Check if replying to bot
-	if message.ReplyToMessage != nil && message.ReplyToMessage.From.ID == h.bot.Self.ID {
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil && message.ReplyToMessage.From.ID == h.bot.Self.ID {
 		h.logger.Debug("Responding: reply to bot")
 		return true, nil
 	}
@@ -348,10 +569,19 @@ func (h *MessageHandler) shouldRespond(ctx context.Context, update *tgbotapi.Upd
 	}).Debug("Checking keywords and mention words")
 
 	if settings != nil {
+		// Ignore trivial messages that merely contain a mention word
+		if !meetsMinMessageLength(messageText, settings.MentionWords, minMessageChars(h.config, settings)) {
+			h.logger.Debug("Not responding: message too short")
+			return false, nil
+		}
+		
 		// Check keywords
 		if len(settings.Keywords) > 0 {
 			for _, keyword := range settings.Keywords {
-				if strings.Contains(messageText, keyword) {
+				if containsWord(messageText, keyword) {
+					if h.skipForGroupSize(chatID) {
+						return false, nil
+					}
 					h.logger.WithField("keyword", keyword).Debug("Responding: keyword match")
 					return true, nil
 				}
@@ -361,7 +591,10 @@ func (h *MessageHandler) shouldRespond(ctx context.Context, update *tgbotapi.Upd
 		// Check mention words
 		if len(settings.MentionWords) > 0 {
 			for _, mention := range settings.MentionWords {
-				if strings.Contains(messageText, strings.ToLower(mention)) {
+				if containsWord(messageText, mention) {
+					if h.skipForGroupSize(chatID) {
+						return false, nil
+					}
 					h.logger.WithField("mention", mention).Debug("Responding: mention word match")
 					return true, nil
 				}
@@ -373,53 +606,146 @@ func (h *MessageHandler) shouldRespond(ctx context.Context, update *tgbotapi.Upd
 	return false, nil
 }
 
-func (h *MessageHandler) getOrCreateContext(ctx context.Context, chatID int64) (*models.ChatContext, error) {
-	chatCtx, err := h.storage.GetContext(ctx, chatID)
+// getOrCreateContext loads the chat context, starting a fresh one when none exists
+// or the stored one has been idle too long. The bool reports an inactivity reset.
+// Starting a fresh context counts as a new session for userID.
+func (h *MessageHandler) getOrCreateContext(ctx context.Context, chatID int64, userID int64) (*models.ChatContext, bool, error) {
+	chatCtx, created, expired, err := h.loadContext(ctx, chatID)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if created {
+		if err := h.storage.IncrementUserSessions(ctx, userID); err != nil {
+			h.logger.WithError(err).Warn("Failed to increment user sessions")
+		}
+	}
+	return chatCtx, expired, nil
+}
+
+// loadContext loads the chat context with the chat's current settings,
+// starting a fresh one when none exists or the stored one has been idle too
+// long. It reports whether the context was started and whether that was an
+// inactivity reset.
+func (h *MessageHandler) loadContext(ctx context.Context, chatID int64) (chatCtx *models.ChatContext, created, expired bool, err error) {
+	chatCtx, err = h.storage.GetContext(ctx, chatID)
+	if err != nil {
+		return nil, false, false, err
+	}
+	settings, err := h.storage.GetSettings(ctx, chatID)
+	if err != nil {
+		return nil, false, false, err
+	}
+	if chatCtx != nil && settings != nil {
+		// Pick up settings changed since the context was created, so the
+		// inactivity window below is the chat's current one
+		chatCtx.Settings = *settings
+	}
+
+	if chatCtx != nil && h.isContextExpired(chatCtx) {
+		h.logger.WithFields(logrus.Fields{
+			"chatID":       chatID,
+			"lastActivity": chatCtx.LastActivity,
+		}).Info("Context expired after inactivity")
+		chatCtx = nil
+		expired = true
 	}
 
 	if chatCtx == nil {
 		// Create new context
-		settings, err := h.storage.GetSettings(ctx, chatID)
-		if err != nil {
-			return nil, err
-		}
-
 		if settings == nil {
 			settings = h.getDefaultSettings()
 			if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
-				return nil, err
+				return nil, false, false, err
 			}
 		}
 
 		chatCtx = &models.ChatContext{
-			ChatID:       chatID,
-			Messages:     []models.Message{{Role: "system", Content: settings.SystemPrompt}},
-			LastActivity: time.Now(),
-			Settings:     *settings,
+			SchemaVersion: models.ContextSchemaVersion,
+			ChatID:        chatID,
+			Messages:      []models.Message{{Role: "system", Content: settings.AIParams.SystemPrompt}},
+			LastActivity:  time.Now(),
+			Settings:      *settings,
 		}
+		created = true
 	}
 
 	// Ensure system prompt is up to date
 	if len(chatCtx.Messages) > 0 && chatCtx.Messages[0].Role == "system" {
-		chatCtx.Messages[0].Content = chatCtx.Settings.SystemPrompt
+		chatCtx.Messages[0].Content = chatCtx.Settings.AIParams.SystemPrompt
 	}
 
-	return chatCtx, nil
+	return chatCtx, created, expired, nil
+}
+
+// saveExchange adds the question and the answer to the chat's context as it
+// is now, so turns saved by other messages while the answer was generated
+// are kept, and a context cleared meanwhile starts over with this exchange
+func (h *MessageHandler) saveExchange(ctx context.Context, message *tgbotapi.Message, question, answer string, dedup *ai.KnowledgeDedup, replyID int) error {
+	unlock := h.chatLocks.lock(message.Chat.ID)
+	defer unlock()
+
+	chatCtx, _, _, err := h.loadContext(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+	h.branchFromReply(chatCtx, message)
+	chatCtx.Messages = append(chatCtx.Messages, models.Message{Role: "user", Content: question})
+	if h.trimContext(chatCtx) {
+		h.metrics.RecordContextTrim("count")
+	}
+
+	// Keep newly injected knowledge in the context so it isn't sent again
+	recordKnowledge(chatCtx, dedup)
+
+	chatCtx.Messages = append(chatCtx.Messages, models.Message{Role: "assistant", Content: answer})
+
+	// Remember where this reply sits in the context for later branching
+	recordReplyIndex(chatCtx, replyID)
+	chatCtx.LastActivity = time.Now()
+	return h.storage.SaveContext(ctx, chatCtx)
+}
+
+// isContextExpired reports whether the context has been idle longer than the
+// chat's inactivity window, falling back to the global window
+func (h *MessageHandler) isContextExpired(chatCtx *models.ChatContext) bool {
+	minutes := h.config.Context.InactivityMinutes
+	if chatCtx.Settings.InactivityMinutes != 0 {
+		minutes = chatCtx.Settings.InactivityMinutes
+	}
+	if minutes <= 0 || chatCtx.LastActivity.IsZero() {
+		return false
+	}
+	return time.Since(chatCtx.LastActivity) > time.Duration(minutes)*time.Minute
 }
 
-func (h *MessageHandler) trimContext(chatCtx *models.ChatContext) {
+// trimContext drops the oldest messages beyond the context limit, reporting
+// whether any were dropped
+func (h *MessageHandler) trimContext(chatCtx *models.ChatContext) bool {
 	maxMessages := h.config.Context.MaxMessages + 1 // +1 for system message
+	keep := 1
+	if len(chatCtx.Messages) > 1 && isSummaryMessage(chatCtx.Messages[1]) {
+		// The summary of earlier turns stays along with the system message
+		maxMessages++
+		keep++
+	}
 	if len(chatCtx.Messages) > maxMessages {
+		removed := len(chatCtx.Messages) - maxMessages
+		h.logger.WithFields(logrus.Fields{
+			"chatID":  chatCtx.ChatID,
+			"removed": removed,
+		}).Debug("Trimming oldest context messages")
 		// Keep system message and remove oldest messages
-		chatCtx.Messages = append(chatCtx.Messages[:1], chatCtx.Messages[len(chatCtx.Messages)-maxMessages+1:]...)
+		chatCtx.Messages = append(chatCtx.Messages[:keep], chatCtx.Messages[len(chatCtx.Messages)-maxMessages+keep:]...)
+		shiftReplyIndex(chatCtx, removed)
+		shiftKnowledgeIndex(chatCtx, removed, keep)
+		return true
 	}
+	return false
 }
 
 func (h *MessageHandler) cleanMessage(text string) string {
 	// Remove bot mention
-	botUsername := "@" + h.bot.Self.UserName
+	botUsername := h.botMention()
 	cleaned := strings.ReplaceAll(text, botUsername, "")
 	return strings.TrimSpace(cleaned)
 }
@@ -433,6 +759,7 @@ func (h *MessageHandler) processThinkingTags(response string, showThink bool) st
 	const thinkEndTag = "</think>"
 	lastIndex := strings.LastIndex(response, thinkEndTag)
 	if lastIndex != -1 {
+		h.logger.WithField("thinkLength", lastIndex).Debug("Hiding thinking content from response")
 		return strings.TrimSpace(response[lastIndex+len(thinkEndTag):])
 	}
 
@@ -440,28 +767,55 @@ func (h *MessageHandler) processThinkingTags(response string, showThink bool) st
 }
 
 func (h *MessageHandler) sendResponse(chatID int64, messageID int, response, lang string) {
-	// Convert markdown to HTML
-	htmlResponse := markdown.ToTelegramHTML(response)
-
-	// Sanitize output
-	htmlResponse = h.security.SanitizeOutput(htmlResponse)
+	// Try the richest formatting first, degrading to plain text when Telegram
+	// rejects the entities
+	attempts := []struct {
+		parseMode string
+		text      func() string
+	}{
+		{"HTML", func() string { return h.security.SanitizeOutput(markdown.ToTelegramHTML(response, h.markdownOptions())) }},
+		{"MarkdownV2", func() string { return markdown.ToMarkdownV2(response, h.markdownOptions()) }},
+		{"", func() string { return markdown.ToPlainText(response, h.markdownOptions()) }},
+	}
 
-	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, htmlResponse)
-	editMsg.ParseMode = "HTML"
+	var err error
+	for _, attempt := range attempts {
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, attempt.text())
+		editMsg.ParseMode = attempt.parseMode
+		if _, err = h.bot.Send(editMsg); err == nil {
+			h.logger.WithFields(logrus.Fields{
+				"chatID":    chatID,
+				"parseMode": attempt.parseMode,
+			}).Debug("Sent response")
+			return
+		}
 
-	if _, err := h.bot.Send(editMsg); err != nil {
-		// If HTML parsing fails, try plain text
-		h.logger.WithError(err).Warn("Failed to send HTML response, trying plain text")
-		editMsg.ParseMode = ""
-		editMsg.Text = response
-		if _, err := h.bot.Send(editMsg); err != nil {
-			h.logger.WithError(err).Error("Failed to send response")
+		// Other formats won't help if the chat itself is unreachable
+		if h.handleSendFailure(context.Background(), chatID, err) {
+			return
 		}
+		h.logger.WithError(err).WithField("parseMode", attempt.parseMode).Warn("Failed to send response, trying next format")
+	}
+
+	h.logger.WithError(err).Error("Failed to send response")
+	h.recordError(chatID, 0, "", err)
+}
+
+// markdownOptions returns the configured conversion of elements Telegram can't show
+func (h *MessageHandler) markdownOptions() markdown.Options {
+	return markdown.Options{
+		Quotes: h.config.Markdown.Quotes,
+		Images: h.config.Markdown.Images,
 	}
 }
 
 func (h *MessageHandler) sendError(chatID int64, messageID int, lang string) {
-	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, h.localizer.Get(lang, i18n.MsgError, nil))
+	h.sendErrorMessage(chatID, messageID, lang, i18n.MsgError)
+}
+
+// sendErrorMessage replaces the thinking message with the localized error msgKey
+func (h *MessageHandler) sendErrorMessage(chatID int64, messageID int, lang string, msgKey string) {
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, h.localizer.Get(lang, msgKey, nil))
 	if _, err := h.bot.Send(editMsg); err != nil {
 		h.logger.WithError(err).Error("Failed to send error message")
 	}
@@ -476,20 +830,27 @@ func (h *MessageHandler) getUserLanguage(ctx context.Context, chatID int64) stri
 }
 
 func (h *MessageHandler) getDefaultSettings() *models.ChatSettings {
-	// Use default mention words from config if available
-	defaultMentionWords := h.config.Context.DefaultMentionWords
-	if len(defaultMentionWords) == 0 {
-		defaultMentionWords = []string{"小菲", "小菲ai", "小菲AI", "ai", "AI"}
+	return defaultChatSettings(h.config)
+}
+
+// refuseLockedConfiguration ends a custom model flow started before safe mode
+// was turned on, reporting whether the message belonged to one
+func (h *MessageHandler) refuseLockedConfiguration(ctx context.Context, update *tgbotapi.Update) (bool, error) {
+	userID := update.Message.From.ID
+	handled := false
+	for _, key := range []string{"configuring_endpoint", "adding_model"} {
+		if value, err := h.storage.GetUserState(ctx, userID, key); err == nil && value != "" {
+			h.storage.DeleteUserState(ctx, userID, key)
+			handled = true
+		}
 	}
-	
-	return &models.ChatSettings{
-		ShowThink:    false,
-		Model:        h.config.Models.Default,
-		SystemPrompt: h.config.Context.DefaultSystemPrompt,
-		Keywords:     []string{},
-		MentionWords: defaultMentionWords,
-		Language:     h.config.I18n.DefaultLanguage,
+	if !handled {
+		return false, nil
 	}
+	
+	lang := h.getUserLanguage(ctx, update.Message.Chat.ID)
+	_, err := h.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, h.localizer.Get(lang, "error.config_locked", nil)))
+	return true, err
 }
 
 func (h *MessageHandler) handleEndpointConfiguration(ctx context.Context, update *tgbotapi.Update, configuringType string) error {
@@ -497,27 +858,19 @@ func (h *MessageHandler) handleEndpointConfiguration(ctx context.Context, update
 	chatID := update.Message.Chat.ID
 	messageText := update.Message.Text
 	
-	// Parse the configuration text
-	lines := strings.Split(messageText, "\n")
-	configData := make(map[string]string)
-	
-	for _, line := range lines {
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			configData[key] = value
-		}
+	// Parse and validate the configuration text
+	input, problems := parseEndpointInput(messageText, true)
+	if len(problems) > 0 {
+		msg := tgbotapi.NewMessage(chatID, formatInputProblems(problems))
+		h.bot.Send(msg)
+		return nil
 	}
-	
-	// Validate required fields
-	requiredFields := []string{"名称", "显示名称", "API地址", "API密钥", "模型列表"}
-	for _, field := range requiredFields {
-		if _, ok := configData[field]; !ok {
-			msg := tgbotapi.NewMessage(chatID, "❌ 缺少必要字段: "+field+"\n\n请按照示例格式重新发送。")
-			h.bot.Send(msg)
-			return nil
-		}
+	configData := map[string]string{
+		"名称":   input.Name,
+		"显示名称": input.DisplayName,
+		"API地址": input.BaseURL,
+		"API密钥": input.APIKey,
+		"模型列表": input.Models,
 	}
 	
 	// Update the .env file
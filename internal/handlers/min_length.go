@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// minMessageChars returns the minimum length of group messages triggered by a
+// mention word or keyword for this chat; 0 means no minimum
+func minMessageChars(cfg *config.Config, settings *models.ChatSettings) int {
+	minChars := cfg.Context.MinGroupMessageChars
+	if settings.MinMessageChars != 0 {
+		minChars = settings.MinMessageChars
+	}
+	if minChars < 0 {
+		return 0
+	}
+	return minChars
+}
+
+// meetsMinMessageLength reports whether text still has at least minChars
+// characters once mention words, spaces and punctuation are removed
+func meetsMinMessageLength(text string, mentionWords []string, minChars int) bool {
+	if minChars <= 0 {
+		return true
+	}
+	
+	text = strings.ToLower(text)
+	for _, mention := range mentionWords {
+		if mention != "" {
+			text = strings.ReplaceAll(text, strings.ToLower(mention), " ")
+		}
+	}
+	
+	count := 0
+	for _, r := range text {
+		if !unicode.IsSpace(r) && !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			count++
+		}
+	}
+	return count >= minChars
+}
+
+// handleMinLength handles /minlength command, setting the minimum length of group
+// messages answered because of a mention word or keyword.
+// Accepts a number of characters, "off" to disable or "default" to follow the config.
+func (h *CommandHandler) handleMinLength(ctx context.Context, chatID int64, args string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	arg := strings.ToLower(strings.TrimSpace(args))
+	
+	switch arg {
+	case "":
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.describeMinLength(settings)+
+			"\n\n用法：/minlength <字符数> | off | default"))
+		return err
+	case "off":
+		settings.MinMessageChars = -1
+	case "default":
+		settings.MinMessageChars = 0
+	default:
+		chars, err := strconv.Atoi(arg)
+		if err != nil || chars <= 0 {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请输入正整数字符数，或 off / default"))
+			return err
+		}
+		settings.MinMessageChars = chars
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ "+h.describeMinLength(settings)))
+	return err
+}
+
+// describeMinLength describes the effective minimum message length of a chat
+func (h *CommandHandler) describeMinLength(settings *models.ChatSettings) string {
+	source := "本聊天设置"
+	if settings.MinMessageChars == 0 {
+		source = "全局默认"
+	}
+	minChars := minMessageChars(h.config, settings)
+	if minChars == 0 {
+		return fmt.Sprintf("提及词触发的最短消息：不限制（%s）", source)
+	}
+	return fmt.Sprintf("提及词触发的最短消息：%d 字符（%s）", minChars, source)
+}
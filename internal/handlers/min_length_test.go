@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMeetsMinMessageLength(t *testing.T) {
+	mentions := []string{"ai", "小菲"}
+	tests := []struct {
+		text     string
+		minChars int
+		want     bool
+	}{
+		{text: "ai", minChars: 3, want: false},
+		{text: "AI?!", minChars: 3, want: false},
+		{text: "ai lol", minChars: 3, want: true},
+		{text: "ai ok", minChars: 3, want: false},
+		{text: "小菲，在吗", minChars: 2, want: true},
+		{text: "小菲！", minChars: 1, want: false},
+		{text: "ai", minChars: 0, want: true},
+	}
+	for _, tt := range tests {
+		if got := meetsMinMessageLength(tt.text, mentions, tt.minChars); got != tt.want {
+			t.Errorf("meetsMinMessageLength(%q, %d) = %v, want %v", tt.text, tt.minChars, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRespondMinLength(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.MinGroupMessageChars = 5
+	h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+	if err := h.RefreshUsername(); err != nil {
+		t.Fatalf("RefreshUsername: %v", err)
+	}
+	mentionWords := func(s *models.ChatSettings) {
+		s.MentionWords = []string{"ai"}
+		s.Keywords = []string{"weather"}
+	}
+	saveChatSettings(t, h, -100, mentionWords)
+	saveChatSettings(t, h, -200, func(s *models.ChatSettings) {
+		mentionWords(s)
+		s.MinMessageChars = -1
+	})
+	saveChatSettings(t, h, -300, func(s *models.ChatSettings) {
+		mentionWords(s)
+		s.MinMessageChars = 20
+	})
+	
+	replyToBot := groupMessage(-100, 7, "ok")
+	replyToBot.Message.ReplyToMessage = &tgbotapi.Message{MessageID: 1, From: &tgbotapi.User{ID: h.bot.Self.ID}}
+	
+	tests := []struct {
+		name   string
+		update *tgbotapi.Update
+		want   bool
+	}{
+		{name: "only the mention word", update: groupMessage(-100, 7, "ai"), want: false},
+		{name: "short with mention word", update: groupMessage(-100, 7, "ai lol"), want: false},
+		{name: "long enough", update: groupMessage(-100, 7, "ai, what time is it"), want: true},
+		{name: "short with keyword", update: groupMessage(-100, 7, "weather?"), want: true},
+		{name: "explicit mention", update: groupMessage(-100, 7, "@test_bot hi"), want: true},
+		{name: "reply to the bot", update: replyToBot, want: true},
+		{name: "disabled for the chat", update: groupMessage(-200, 7, "ai"), want: true},
+		{name: "raised for the chat", update: groupMessage(-300, 7, "ai, what time is it"), want: false},
+		{name: "private chat", update: privateMessage(7, 7, 1, "ai"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.shouldRespond(context.Background(), tt.update)
+			if err != nil {
+				t.Fatalf("shouldRespond: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("shouldRespond(%q) = %v, want %v", tt.update.Message.Text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinLengthCommand(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.MinGroupMessageChars = 5
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	steps := []struct {
+		command  string
+		wantText string
+		want     int
+	}{
+		{command: "/minlength", wantText: "5 字符（全局默认）"},
+		{command: "/minlength 8", wantText: "8 字符（本聊天设置）", want: 8},
+		{command: "/minlength -3", wantText: "请输入正整数字符数", want: 8},
+		{command: "/minlength off", wantText: "不限制（本聊天设置）", want: -1},
+		{command: "/minlength default", wantText: "5 字符（全局默认）", want: 0},
+	}
+	for _, step := range steps {
+		runCommand(t, c, -100, 7, step.command)
+		if text := lastText(telegram.texts("sendMessage", -100)); !strings.Contains(text, step.wantText) {
+			t.Errorf("%s answered %q, want %q", step.command, text, step.wantText)
+		}
+		settings, err := h.storage.GetSettings(context.Background(), -100)
+		if err != nil {
+			t.Fatalf("GetSettings: %v", err)
+		}
+		if settings != nil && settings.MinMessageChars != step.want {
+			t.Errorf("after %s MinMessageChars = %d, want %d", step.command, settings.MinMessageChars, step.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+)
+
+// uncategorizedModels titles the group of models without a category
+const uncategorizedModels = "其他"
+
+// modelGroup is a titled group of models in the selection keyboard
+type modelGroup struct {
+	title  string
+	models []ai.ModelOption
+}
+
+// groupModels groups models, already sorted by the AI service, by endpoint or
+// by category as configured. Groups appear in the order of their first
+// model, so the keyboard layout is the same on every call; models without
+// a category come last.
+func (h *CommandHandler) groupModels(models []ai.ModelOption) []modelGroup {
+	byCategory := h.config.Models.GroupBy == config.ModelGroupByCategory
+	
+	var groups []modelGroup
+	index := make(map[string]int)
+	var uncategorized []ai.ModelOption
+	for _, model := range models {
+		key := model.EndpointName
+		if byCategory {
+			if model.Category == "" {
+				uncategorized = append(uncategorized, model)
+				continue
+			}
+			key = model.Category
+		}
+		
+		i, exists := index[key]
+		if !exists {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, modelGroup{title: h.modelGroupTitle(model, byCategory)})
+		}
+		groups[i].models = append(groups[i].models, model)
+	}
+	
+	if len(uncategorized) > 0 {
+		groups = append(groups, modelGroup{title: uncategorizedModels, models: uncategorized})
+	}
+	return groups
+}
+
+// modelGroupTitle returns the header of the group model starts
+func (h *CommandHandler) modelGroupTitle(model ai.ModelOption, byCategory bool) string {
+	if byCategory {
+		return model.Category
+	}
+	
+	// Endpoints added at runtime aren't in the config file; show their name instead
+	title := model.EndpointName
+	if endpoint := h.getEndpointByName(model.EndpointName); endpoint != nil {
+		title = endpoint.DisplayName
+	}
+	if model.Owner != 0 {
+		title += "（私有）"
+	}
+	return title
+}
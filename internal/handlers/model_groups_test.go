@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+// keyboardLayout returns the group headers and model buttons of a model
+// selection keyboard, in order
+func keyboardLayout(c *CommandHandler) []string {
+	var layout []string
+	for _, row := range c.createModelSelectionKeyboard(7, "", nil).InlineKeyboard {
+		for _, button := range row {
+			if data := *button.CallbackData; data == "noop" || strings.HasPrefix(data, "model:") {
+				layout = append(layout, strings.TrimPrefix(data, "model:")+" "+button.Text)
+			}
+		}
+	}
+	return layout
+}
+
+func TestModelKeyboardOrder(t *testing.T) {
+	tests := []struct {
+		groupBy string
+		want    []string
+	}{
+		{
+			groupBy: "",
+			want: []string{
+				"noop 📍 Fast", "fast-2 Fast 2", "fast-1 Fast 1",
+				"noop 📍 Main", "main-1 Main 1", "main-2 Main 2",
+				"noop 📍 Extra", "extra-1 Extra 1",
+			},
+		},
+		{
+			groupBy: config.ModelGroupByCategory,
+			want: []string{
+				"noop 📍 聊天", "fast-2 Fast 2", "main-1 Main 1", "extra-1 Extra 1",
+				"noop 📍 推理", "main-2 Main 2",
+				"noop 📍 其他", "fast-1 Fast 1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("group by "+tt.groupBy, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Models.GroupBy = tt.groupBy
+			cfg.Models.Endpoints = []config.ModelEndpoint{
+				{Name: "main", DisplayName: "Main", BaseURL: "http://main", Models: []config.ModelInfo{
+					{ID: "main-1", Name: "Main 1", Category: "聊天"},
+					{ID: "main-2", Name: "Main 2", Category: "推理"},
+				}},
+				{Name: "extra", DisplayName: "Extra", BaseURL: "http://extra", Models: []config.ModelInfo{
+					{ID: "extra-1", Name: "Extra 1", Category: "聊天"},
+				}},
+				// A lower priority comes first, ahead of the configured order
+				{Name: "fast", DisplayName: "Fast", BaseURL: "http://fast", Priority: -1, Models: []config.ModelInfo{
+					{ID: "fast-2", Name: "Fast 2", Category: "聊天"},
+					{ID: "fast-1", Name: "Fast 1"},
+				}},
+			}
+			services := map[string]ai.Service{
+				"dynamic": ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger()),
+				"custom":  ai.NewCustomAI(&cfg.Models, newTestLogger()),
+			}
+			for name, service := range services {
+				h, _ := newTestMessageHandler(t, cfg, service)
+				c := newTestCommandHandler(h)
+				
+				// The layout is the same on every call
+				for i := 0; i < 10; i++ {
+					if got := keyboardLayout(c); !reflect.DeepEqual(got, tt.want) {
+						t.Fatalf("%s keyboard %d =\n%q\nwant\n%q", name, i, got, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
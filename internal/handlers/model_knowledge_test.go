@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+)
+
+func TestModelKnowledgeChoosesPath(t *testing.T) {
+	on, off := true, false
+	modelOptions := []ai.ModelOption{
+		{ID: "default-model", Name: "Default", EndpointName: "test"},
+		{ID: "research", Name: "Research", EndpointName: "test", UseKnowledge: &on},
+		{ID: "fast-chat", Name: "Fast chat", EndpointName: "test", UseKnowledge: &off},
+	}
+	
+	tests := []struct {
+		name          string
+		enabled       bool
+		withService   bool
+		model         string
+		wantKnowledge bool
+	}{
+		{name: "model inherits enabled knowledge", enabled: true, withService: true, model: "default-model", wantKnowledge: true},
+		{name: "model inherits disabled knowledge", withService: true, model: "default-model"},
+		{name: "research model searches", enabled: true, withService: true, model: "research", wantKnowledge: true},
+		{name: "fast chat model skips retrieval", enabled: true, withService: true, model: "fast-chat"},
+		{name: "research model without knowledge enabled", withService: true, model: "research"},
+		{name: "no knowledge base", enabled: true, model: "research"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Knowledge.Enabled = tt.enabled
+			service := &fakeAI{models: modelOptions, reply: func(ctx context.Context, messages []models.Message) (string, error) {
+				return "answer", nil
+			}}
+			h, _ := newTestMessageHandler(t, cfg, service)
+			if tt.withService {
+				h.knowledgeService = fakeKnowledge{docs: []knowledge.Document{{ID: "doc", Title: "Doc", Content: "facts"}}}
+			}
+			saveChatSettings(t, h, 42, func(s *models.ChatSettings) { s.AIParams.Model = tt.model })
+			
+			handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+			
+			if service.requestCount() != 1 {
+				t.Fatalf("AI got %d requests, want 1", service.requestCount())
+			}
+			if service.modelIDs[0] != tt.model {
+				t.Errorf("request went to %s, want %s", service.modelIDs[0], tt.model)
+			}
+			if got := service.withKnowledge[0]; got != tt.wantKnowledge {
+				t.Errorf("request searched the knowledge base = %v, want %v", got, tt.wantKnowledge)
+			}
+		})
+	}
+}
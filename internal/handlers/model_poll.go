@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Telegram allows 2-10 poll options of up to 100 characters
+	maxPollOptions      = 10
+	maxPollOptionLength = 100
+	
+	defaultModelPollThreshold = 3
+	defaultModelPollDuration  = 300
+	maxModelPollDuration      = 600 // Telegram's limit for open_period
+)
+
+// modelPoll tracks an open model selection poll
+type modelPoll struct {
+	chatID    int64
+	messageID int
+	modelIDs  []string        // option index -> model ID
+	votes     map[int64][]int // user ID -> chosen options
+}
+
+// tally counts the votes for each option
+func (p *modelPoll) tally() []int {
+	counts := make([]int, len(p.modelIDs))
+	for _, options := range p.votes {
+		for _, option := range options {
+			if option >= 0 && option < len(counts) {
+				counts[option]++
+			}
+		}
+	}
+	return counts
+}
+
+// modelPollTracker holds the open model polls by poll ID
+type modelPollTracker struct {
+	mu    sync.Mutex
+	polls map[string]*modelPoll
+}
+
+func newModelPollTracker() *modelPollTracker {
+	return &modelPollTracker{
+		polls: make(map[string]*modelPoll),
+	}
+}
+
+// winningOption returns the option with the most votes, or -1 without votes.
+// Ties go to the earlier option.
+func winningOption(counts []int) int {
+	winner := -1
+	best := 0
+	for option, count := range counts {
+		if count > best {
+			winner = option
+			best = count
+		}
+	}
+	return winner
+}
+
+// handleModelPoll handles /modelpoll command, starting a poll to choose the group model.
+// "/modelpoll unlock" removes the locked model again.
+func (h *CommandHandler) handleModelPoll(ctx context.Context, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	if message.Chat.IsPrivate() {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 投票选择模型仅适用于群组"))
+		return err
+	}
+	
+	if strings.TrimSpace(message.CommandArguments()) == "unlock" {
+		settings := h.getChatSettings(ctx, chatID)
+		settings.LockedModel = ""
+		if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+			h.logger.WithError(err).Error("Failed to save settings")
+			return err
+		}
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "🔓 已解除群组模型锁定，成员可自行选择模型"))
+		return err
+	}
+	
+	// Only models members may use can win the poll
+	available := filterAllowedModels(h.aiService.GetAvailableModels(), h.getChatSettings(ctx, chatID).AllowedModels)
+	if len(available) < 2 {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 可用模型不足两个，无法发起投票"))
+		return err
+	}
+	if len(available) > maxPollOptions {
+		available = available[:maxPollOptions]
+	}
+	
+	options := make([]string, len(available))
+	modelIDs := make([]string, len(available))
+	for i, model := range available {
+		name := []rune(model.Name)
+		if len(name) > maxPollOptionLength {
+			name = name[:maxPollOptionLength]
+		}
+		options[i] = string(name)
+		modelIDs[i] = model.ID
+	}
+	
+	threshold := h.modelPollThreshold()
+	poll := tgbotapi.NewPoll(chatID, fmt.Sprintf("🗳 选择本群使用的模型（先达到 %d 票或投票结束时得票最多者获胜）", threshold), options...)
+	poll.IsAnonymous = false // votes of anonymous polls are not delivered to bots
+	poll.OpenPeriod = h.modelPollDuration()
+	
+	sent, err := h.bot.Send(poll)
+	if err != nil {
+		return err
+	}
+	if sent.Poll == nil {
+		return fmt.Errorf("poll message has no poll")
+	}
+	
+	h.modelPolls.mu.Lock()
+	h.modelPolls.polls[sent.Poll.ID] = &modelPoll{
+		chatID:    chatID,
+		messageID: sent.MessageID,
+		modelIDs:  modelIDs,
+		votes:     make(map[int64][]int),
+	}
+	h.modelPolls.mu.Unlock()
+	
+	return nil
+}
+
+// HandlePollAnswer records a vote and picks the winner once it reaches the threshold
+func (h *CommandHandler) HandlePollAnswer(ctx context.Context, answer *tgbotapi.PollAnswer) error {
+	h.modelPolls.mu.Lock()
+	poll, ok := h.modelPolls.polls[answer.PollID]
+	if !ok {
+		h.modelPolls.mu.Unlock()
+		return nil
+	}
+	
+	// An empty answer means the vote was retracted
+	if len(answer.OptionIDs) == 0 {
+		delete(poll.votes, answer.User.ID)
+	} else {
+		poll.votes[answer.User.ID] = answer.OptionIDs
+	}
+	
+	counts := poll.tally()
+	winner := winningOption(counts)
+	if winner < 0 || counts[winner] < h.modelPollThreshold() {
+		h.modelPolls.mu.Unlock()
+		return nil
+	}
+	
+	delete(h.modelPolls.polls, answer.PollID)
+	h.modelPolls.mu.Unlock()
+	
+	if _, err := h.bot.Request(tgbotapi.NewStopPoll(poll.chatID, poll.messageID)); err != nil {
+		h.logger.WithError(err).Warn("Failed to stop model poll")
+	}
+	
+	return h.applyPollWinner(ctx, poll, winner)
+}
+
+// HandlePoll picks the winner of a model poll when it closes
+func (h *CommandHandler) HandlePoll(ctx context.Context, update *tgbotapi.Poll) error {
+	if !update.IsClosed {
+		return nil
+	}
+	
+	h.modelPolls.mu.Lock()
+	poll, ok := h.modelPolls.polls[update.ID]
+	if ok {
+		delete(h.modelPolls.polls, update.ID)
+	}
+	h.modelPolls.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	
+	// Telegram's final counts also include votes cast before a restart
+	counts := make([]int, len(poll.modelIDs))
+	for i, option := range update.Options {
+		if i < len(counts) {
+			counts[i] = option.VoterCount
+		}
+	}
+	
+	winner := winningOption(counts)
+	if winner < 0 {
+		_, err := h.bot.Send(tgbotapi.NewMessage(poll.chatID, "🗳 投票已结束，但没有人投票，模型保持不变"))
+		return err
+	}
+	
+	return h.applyPollWinner(ctx, poll, winner)
+}
+
+// applyPollWinner locks the group to the winning model
+func (h *CommandHandler) applyPollWinner(ctx context.Context, poll *modelPoll, winner int) error {
+	modelID := poll.modelIDs[winner]
+	
+	// The model may have been removed or disallowed while the poll ran
+	model, err := h.aiService.GetModelByID(modelID)
+	settings := h.getChatSettings(ctx, poll.chatID)
+	if err != nil || !isModelAllowed(settings.AllowedModels, modelID) {
+		h.logger.WithField("model", modelID).Warn("Poll winner is no longer available")
+		_, err := h.bot.Send(tgbotapi.NewMessage(poll.chatID, "🗳 投票结束，但获胜的模型已不可用，模型保持不变"))
+		return err
+	}
+	settings.LockedModel = modelID
+	if err := h.storage.SaveSettings(ctx, poll.chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		return err
+	}
+	
+	h.logger.WithFields(logrus.Fields{
+		"chatID": poll.chatID,
+		"model":  modelID,
+	}).Info("Group model chosen by poll")
+	
+	text := fmt.Sprintf("🎉 投票结束！本群将使用模型：%s\n使用 /modelpoll unlock 可解除锁定", model.Name)
+	_, err = h.bot.Send(tgbotapi.NewMessage(poll.chatID, text))
+	return err
+}
+
+func (h *CommandHandler) modelPollThreshold() int {
+	if h.config.Bot.ModelPoll.Threshold > 0 {
+		return h.config.Bot.ModelPoll.Threshold
+	}
+	return defaultModelPollThreshold
+}
+
+func (h *CommandHandler) modelPollDuration() int {
+	duration := h.config.Bot.ModelPoll.DurationSeconds
+	if duration <= 0 {
+		return defaultModelPollDuration
+	}
+	if duration > maxModelPollDuration {
+		return maxModelPollDuration
+	}
+	return duration
+}
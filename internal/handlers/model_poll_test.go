@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pollModels are the models a model poll chooses from
+var pollModels = []ai.ModelOption{
+	{ID: "model-a", Name: "Model A", EndpointName: "test"},
+	{ID: "model-b", Name: "Model B", EndpointName: "test"},
+	{ID: "model-c", Name: "Model C", EndpointName: "test"},
+}
+
+func TestModelPollTally(t *testing.T) {
+	poll := &modelPoll{
+		modelIDs: []string{"model-a", "model-b", "model-c"},
+		votes: map[int64][]int{
+			1: {1},
+			2: {1, 2},
+			3: {0},
+			4: {7}, // not an option
+		},
+	}
+	
+	counts := poll.tally()
+	if want := []int{1, 2, 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("tally = %v, want %v", counts, want)
+	}
+	if got := winningOption(counts); got != 1 {
+		t.Errorf("winningOption(%v) = %d, want 1", counts, got)
+	}
+}
+
+func TestWinningOption(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int
+		want   int
+	}{
+		{name: "most votes", counts: []int{1, 3, 2}, want: 1},
+		{name: "tie goes to the earlier option", counts: []int{0, 2, 2}, want: 1},
+		{name: "no votes", counts: []int{0, 0, 0}, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := winningOption(tt.counts); got != tt.want {
+				t.Errorf("winningOption(%v) = %d, want %d", tt.counts, got, tt.want)
+			}
+		})
+	}
+}
+
+// startModelPoll starts a model poll in group chatID and returns its ID
+func startModelPoll(t *testing.T, c *CommandHandler, chatID int64) string {
+	t.Helper()
+	message := command(chatID, 7, "/modelpoll")
+	message.Chat.Type = "group"
+	if err := c.HandleCommand(context.Background(), message); err != nil {
+		t.Fatalf("HandleCommand: %v", err)
+	}
+	
+	c.modelPolls.mu.Lock()
+	defer c.modelPolls.mu.Unlock()
+	for id, poll := range c.modelPolls.polls {
+		if poll.chatID == chatID {
+			return id
+		}
+	}
+	t.Fatal("no model poll started")
+	return ""
+}
+
+// vote casts userID's vote for options of poll pollID
+func vote(t *testing.T, c *CommandHandler, pollID string, userID int64, options ...int) {
+	t.Helper()
+	answer := &tgbotapi.PollAnswer{PollID: pollID, User: tgbotapi.User{ID: userID}, OptionIDs: options}
+	if err := c.HandlePollAnswer(context.Background(), answer); err != nil {
+		t.Fatalf("HandlePollAnswer: %v", err)
+	}
+}
+
+func lockedModel(t *testing.T, c *CommandHandler, chatID int64) string {
+	t.Helper()
+	return c.getChatSettings(context.Background(), chatID).LockedModel
+}
+
+func TestModelPollThreshold(t *testing.T) {
+	const chatID = -100
+	cfg := newTestConfig()
+	cfg.Bot.ModelPoll.Threshold = 2
+	h, telegram := newTestMessageHandler(t, cfg, &fakeAI{models: pollModels})
+	c := newTestCommandHandler(h)
+	
+	pollID := startModelPoll(t, c, chatID)
+	
+	vote(t, c, pollID, 1, 2)
+	vote(t, c, pollID, 2, 0)
+	// A changed vote replaces the earlier one and a retracted one counts no more
+	vote(t, c, pollID, 1, 1)
+	vote(t, c, pollID, 2)
+	vote(t, c, pollID, 3, 0)
+	if got := lockedModel(t, c, chatID); got != "" {
+		t.Fatalf("model locked to %q below the threshold", got)
+	}
+	
+	vote(t, c, pollID, 4, 0)
+	if got := lockedModel(t, c, chatID); got != "model-a" {
+		t.Errorf("locked model = %q, want the winner model-a", got)
+	}
+	sent := telegram.texts("sendMessage", chatID)
+	if len(sent) == 0 || !strings.Contains(sent[len(sent)-1], "本群将使用模型：Model A") {
+		t.Errorf("sent %q, want the winner announced", sent)
+	}
+	if len(telegram.texts("stopPoll", chatID)) != 1 {
+		t.Error("poll wasn't stopped after reaching the threshold")
+	}
+	
+	// Votes after the poll ended change nothing
+	vote(t, c, pollID, 5, 1)
+	vote(t, c, pollID, 6, 1)
+	if got := lockedModel(t, c, chatID); got != "model-a" {
+		t.Errorf("locked model = %q after the poll ended, want model-a", got)
+	}
+}
+
+func TestModelPollClosed(t *testing.T) {
+	tests := []struct {
+		name        string
+		voterCounts []int
+		allowed     []string
+		wantLocked  string
+		wantText    string
+	}{
+		{name: "most voted wins", voterCounts: []int{1, 0, 2}, wantLocked: "model-c", wantText: "本群将使用模型：Model C"},
+		{name: "no votes", voterCounts: []int{0, 0, 0}, wantText: "没有人投票"},
+		{name: "winner disallowed meanwhile", voterCounts: []int{1, 0, 2}, allowed: []string{"model-a"}, wantText: "获胜的模型已不可用"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const chatID = -100
+			ctx := context.Background()
+			h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{models: pollModels})
+			c := newTestCommandHandler(h)
+			pollID := startModelPoll(t, c, chatID)
+			
+			if tt.allowed != nil {
+				settings := c.getChatSettings(ctx, chatID)
+				settings.AllowedModels = tt.allowed
+				c.storage.SaveSettings(ctx, chatID, settings)
+			}
+			
+			closed := &tgbotapi.Poll{ID: pollID, IsClosed: true}
+			for _, count := range tt.voterCounts {
+				closed.Options = append(closed.Options, tgbotapi.PollOption{VoterCount: count})
+			}
+			if err := c.HandlePoll(ctx, closed); err != nil {
+				t.Fatalf("HandlePoll: %v", err)
+			}
+			
+			if got := lockedModel(t, c, chatID); got != tt.wantLocked {
+				t.Errorf("locked model = %q, want %q", got, tt.wantLocked)
+			}
+			sent := telegram.texts("sendMessage", chatID)
+			if len(sent) == 0 || !strings.Contains(sent[len(sent)-1], tt.wantText) {
+				t.Errorf("sent %q, want %q", sent, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestModelPollOffersAllowedModels(t *testing.T) {
+	const chatID = -100
+	ctx := context.Background()
+	h, _ := newTestMessageHandler(t, newTestConfig(), &fakeAI{models: pollModels})
+	c := newTestCommandHandler(h)
+	settings := c.getChatSettings(ctx, chatID)
+	settings.AllowedModels = []string{"model-b", "model-c"}
+	c.storage.SaveSettings(ctx, chatID, settings)
+	
+	pollID := startModelPoll(t, c, chatID)
+	
+	c.modelPolls.mu.Lock()
+	modelIDs := c.modelPolls.polls[pollID].modelIDs
+	c.modelPolls.mu.Unlock()
+	if strings.Join(modelIDs, ",") != "model-b,model-c" {
+		t.Errorf("poll offers %v, want the allowed models", modelIDs)
+	}
+}
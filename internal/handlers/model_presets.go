@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	
+	"github.com/cf-ai-tgbot-go/internal/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// modelPresets holds curated model lists for common providers
+var modelPresets = map[string][]config.ModelInfo{
+	"openai": {
+		{ID: "gpt-4o", Name: "GPT-4o", MaxTokens: 16384},
+		{ID: "gpt-4o-mini", Name: "GPT-4o Mini", MaxTokens: 16384},
+		{ID: "gpt-4-turbo", Name: "GPT-4 Turbo", MaxTokens: 4096},
+		{ID: "gpt-3.5-turbo", Name: "GPT-3.5 Turbo", MaxTokens: 4096},
+	},
+	"claude": {
+		{ID: "claude-3-5-sonnet-latest", Name: "Claude 3.5 Sonnet", MaxTokens: 8192},
+		{ID: "claude-3-5-haiku-latest", Name: "Claude 3.5 Haiku", MaxTokens: 8192},
+		{ID: "claude-3-opus-latest", Name: "Claude 3 Opus", MaxTokens: 4096},
+	},
+	"gemini": {
+		{ID: "gemini-2.5-pro", Name: "Gemini 2.5 Pro", MaxTokens: 65536},
+		{ID: "gemini-2.5-flash", Name: "Gemini 2.5 Flash", MaxTokens: 65536},
+		{ID: "gemini-2.0-flash", Name: "Gemini 2.0 Flash", MaxTokens: 8192},
+	},
+	"llama": {
+		{ID: "llama-3.3-70b-instruct", Name: "Llama 3.3 70B", MaxTokens: 8192},
+		{ID: "llama-3.1-405b-instruct", Name: "Llama 3.1 405B", MaxTokens: 8192},
+		{ID: "llama-3.1-8b-instruct", Name: "Llama 3.1 8B", MaxTokens: 8192},
+	},
+}
+
+// applyModelPreset adds the models of a preset to an endpoint, skipping existing ones
+func (h *ConfigHandler) applyModelPreset(ctx context.Context, chatID int64, messageID int, userID int64, preset string, endpointName string, callbackID string) error {
+	presetModels, ok := modelPresets[preset]
+	if !ok {
+		h.bot.Request(tgbotapi.NewCallback(callbackID, "未知的模型系列"))
+		return nil
+	}
+	
+	added, err := h.configService.AddModelsToEndpoint(ctx, userID, endpointName, presetModels)
+	
+	var text string
+	switch {
+	case err != nil:
+		text = fmt.Sprintf("❌ 添加模型失败：%s", err.Error())
+	case added == 0:
+		text = "ℹ️ 该系列的模型均已存在，未添加新模型"
+	default:
+		text = fmt.Sprintf("✅ 已为端点 `%s` 添加 %d 个模型（共 %d 个，已存在的已跳过）", endpointName, added, len(presetModels))
+	}
+	
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ 继续添加", fmt.Sprintf("config:common_models:%s", endpointName)),
+			tgbotapi.NewInlineKeyboardButtonData("✅ 完成", "menu:models"),
+		),
+	)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, sendErr := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	return sendErr
+}
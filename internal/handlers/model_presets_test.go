@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+func TestApplyModelPreset(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestConfigHandler(h)
+	// One of the preset's models is already there
+	if err := c.configService.AddModelToEndpoint(ctx, 7, "test", config.ModelInfo{ID: "gpt-4o", Name: "Mine"}); err != nil {
+		t.Fatalf("AddModelToEndpoint: %v", err)
+	}
+	
+	if err := c.HandleConfigCallback(ctx, configCallback(42, 7, "config:preset:openai:test")); err != nil {
+		t.Fatalf("HandleConfigCallback: %v", err)
+	}
+	
+	want := []config.ModelInfo{
+		{ID: testModel},
+		{ID: "gpt-4o", Name: "Mine"},
+		{ID: "gpt-4o-mini", Name: "GPT-4o Mini", MaxTokens: 16384},
+		{ID: "gpt-4-turbo", Name: "GPT-4 Turbo", MaxTokens: 4096},
+		{ID: "gpt-3.5-turbo", Name: "GPT-3.5 Turbo", MaxTokens: 4096},
+	}
+	if got := currentTestEndpoint(t, c).Models; !reflect.DeepEqual(got, want) {
+		t.Errorf("models = %+v, want %+v", got, want)
+	}
+	if text := lastText(telegram.texts("editMessageText", 42)); !strings.Contains(text, "添加 3 个模型（共 4 个") {
+		t.Errorf("confirmation %q, want 3 of 4 models added", text)
+	}
+	
+	// Applying it again adds nothing
+	if err := c.HandleConfigCallback(ctx, configCallback(42, 7, "config:preset:openai:test")); err != nil {
+		t.Fatalf("HandleConfigCallback: %v", err)
+	}
+	if got := currentTestEndpoint(t, c).Models; len(got) != len(want) {
+		t.Errorf("got %d models after applying the preset twice, want %d", len(got), len(want))
+	}
+	if text := lastText(telegram.texts("editMessageText", 42)); !strings.Contains(text, "均已存在") {
+		t.Errorf("confirmation %q, want nothing added", text)
+	}
+}
+
+func TestApplyUnknownModelPreset(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestConfigHandler(h)
+	
+	if err := c.HandleConfigCallback(ctx, configCallback(42, 7, "config:preset:mistral:test")); err != nil {
+		t.Fatalf("HandleConfigCallback: %v", err)
+	}
+	if got := currentTestEndpoint(t, c).Models; len(got) != 1 {
+		t.Errorf("models = %+v, want only the original one", got)
+	}
+	if edits := telegram.texts("editMessageText", 42); len(edits) != 0 {
+		t.Errorf("edited the menu to %q for an unknown preset", edits)
+	}
+}
+
+func TestModelPresetsDistinct(t *testing.T) {
+	for name, models := range modelPresets {
+		seen := make(map[string]bool)
+		for _, model := range models {
+			if model.ID == "" || model.Name == "" || model.MaxTokens <= 0 {
+				t.Errorf("%s preset has incomplete model %+v", name, model)
+			}
+			if seen[model.ID] {
+				t.Errorf("%s preset lists %s twice", name, model.ID)
+			}
+			seen[model.ID] = true
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+)
+
+func TestModelUsageCounted(t *testing.T) {
+	ctx := context.Background()
+	fail := false
+	service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) {
+		if fail {
+			return "", errors.New("endpoint down")
+		}
+		return "answer", nil
+	}}
+	h, _ := newTestMessageHandler(t, newTestConfig(), service)
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+	handleAndWait(t, h, privateMessage(42, 7, 2, "and again"))
+	// A failed response isn't counted
+	fail = true
+	handleAndWait(t, h, privateMessage(42, 7, 3, "once more"))
+	
+	stats, err := h.storage.GetUserStats(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetUserStats: %v", err)
+	}
+	if len(stats.ModelUsage) != 1 || stats.ModelUsage[testModel] != 2 {
+		t.Errorf("ModelUsage = %v, want 2 responses of %s", stats.ModelUsage, testModel)
+	}
+}
+
+func TestStatsTopModels(t *testing.T) {
+	ctx := context.Background()
+	service := &fakeAI{models: []ai.ModelOption{
+		{ID: "model-a", Name: "Model A"},
+		{ID: "model-b", Name: "Model B"},
+		{ID: "model-c", Name: "Model C"},
+		{ID: "model-d", Name: "Model D"},
+	}}
+	h, telegram := newTestMessageHandler(t, newTestConfig(), service)
+	c := newTestCommandHandler(h)
+	
+	runCommand(t, c, 42, 7, "/stats")
+	if text := lastText(telegram.texts("sendMessage", 42)); strings.Contains(text, "常用模型") {
+		t.Errorf("/stats without usage shows %q, want no models", text)
+	}
+	
+	// The retired model is no longer offered and shows by its ID
+	usage := map[string]int{"model-a": 1, "model-b": 5, "retired": 3, "model-d": 3}
+	for modelID, count := range usage {
+		for i := 0; i < count; i++ {
+			if err := h.storage.RecordUsage(ctx, 7, modelID, 1, 1, 0); err != nil {
+				t.Fatalf("RecordUsage: %v", err)
+			}
+		}
+	}
+	runCommand(t, c, 42, 7, "/stats")
+	text := lastText(telegram.texts("sendMessage", 42))
+	if want := "常用模型**\n• Model B: 5\n• Model D: 3\n• retired: 3"; !strings.Contains(text, want) {
+		t.Errorf("/stats shows %q, want %q", text, want)
+	}
+	if strings.Contains(text, "Model A") {
+		t.Errorf("/stats shows %q, want only the top %d models", text, statsTopModels)
+	}
+}
+
+func TestTopModelUsage(t *testing.T) {
+	usage := map[string]int{"b": 2, "a": 2, "c": 7, "d": 1}
+	got := topModelUsage(usage, 3)
+	want := []modelUsageCount{{"c", 7}, {"a", 2}, {"b", 2}}
+	if len(got) != len(want) {
+		t.Fatalf("topModelUsage = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("topModelUsage = %v, want %v", got, want)
+			break
+		}
+	}
+	if got := topModelUsage(nil, 3); len(got) != 0 {
+		t.Errorf("topModelUsage(nil) = %v, want none", got)
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// myKeyUsage explains the /mykey command
+const myKeyUsage = "用法：\n/mykey - 查看已登记自己 API Key 的端点\n/mykey <端点> <API Key> - 为端点登记自己的 Key（仅限私聊）\n/mykey remove <端点> - 删除登记的 Key\n\n登记后你在该端点上的请求将使用你自己的 Key 计费。"
+
+// handleMyKey handles /mykey command, managing the user's own API keys for
+// shared endpoints
+func (h *CommandHandler) handleMyKey(ctx context.Context, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	userID := message.From.ID
+	if !h.storage.UserKeysEnabled() {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 未启用自带 API Key 功能"))
+		return err
+	}
+	
+	args := strings.Fields(message.CommandArguments())
+	switch {
+	case len(args) == 0:
+		return h.listMyKeys(ctx, chatID, userID)
+	case len(args) == 2 && (args[0] == "remove" || args[0] == "delete"):
+		return h.removeMyKey(ctx, chatID, userID, args[1])
+	case len(args) == 2:
+		return h.setMyKey(ctx, message, args[0], args[1])
+	default:
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, myKeyUsage))
+		return err
+	}
+}
+
+// listMyKeys shows the endpoints the user registered a key for
+func (h *CommandHandler) listMyKeys(ctx context.Context, chatID int64, userID int64) error {
+	endpoints, err := h.storage.UserAPIKeyEndpoints(ctx, userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list user API keys")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 读取失败，请稍后重试"))
+		return err
+	}
+	
+	text := "🔑 你还没有登记自己的 API Key\n\n" + myKeyUsage
+	if len(endpoints) > 0 {
+		text = fmt.Sprintf("🔑 已登记自己 API Key 的端点：%s\n\n%s", strings.Join(endpoints, "、"), myKeyUsage)
+	}
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// setMyKey registers the user's own key for a shared endpoint
+func (h *CommandHandler) setMyKey(ctx context.Context, message *tgbotapi.Message, endpoint string, apiKey string) error {
+	chatID := message.Chat.ID
+	
+	// Don't leave the key in the chat history
+	if _, err := h.bot.Request(tgbotapi.NewDeleteMessage(chatID, message.MessageID)); err != nil {
+		h.logger.WithError(err).Debug("Failed to delete API key message")
+	}
+	
+	if !message.Chat.IsPrivate() {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请在与机器人的私聊中登记 API Key，并尽快更换已在群里发出的 Key"))
+		return err
+	}
+	if !h.sharedEndpoint(endpoint) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 未找到端点：%s\n可用端点：%s", endpoint, strings.Join(h.sharedEndpoints(), "、"))))
+		return err
+	}
+	
+	if err := h.storage.SetUserAPIKey(ctx, message.From.ID, endpoint, apiKey); err != nil {
+		h.logger.WithError(err).Error("Failed to save user API key")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ 已为端点 %s 登记你的 API Key（%s），之后你在该端点上的请求将使用此 Key", endpoint, maskAPIKey(apiKey))))
+	return err
+}
+
+// removeMyKey deletes the user's key for an endpoint
+func (h *CommandHandler) removeMyKey(ctx context.Context, chatID int64, userID int64, endpoint string) error {
+	removed, err := h.storage.DeleteUserAPIKey(ctx, userID, endpoint)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to delete user API key")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 删除失败，请稍后重试"))
+		return err
+	}
+	
+	text := fmt.Sprintf("✅ 已删除端点 %s 的 API Key，之后将使用共享 Key", endpoint)
+	if !removed {
+		text = fmt.Sprintf("❌ 你没有为端点 %s 登记 API Key", endpoint)
+	}
+	_, err = h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// sharedEndpoints returns the names of the endpoints serving shared models,
+// in model order
+func (h *CommandHandler) sharedEndpoints() []string {
+	var endpoints []string
+	seen := make(map[string]bool)
+	for _, model := range h.aiService.GetAvailableModels() {
+		if !seen[model.EndpointName] {
+			seen[model.EndpointName] = true
+			endpoints = append(endpoints, model.EndpointName)
+		}
+	}
+	return endpoints
+}
+
+// sharedEndpoint reports whether name is an endpoint serving shared models
+func (h *CommandHandler) sharedEndpoint(name string) bool {
+	for _, endpoint := range h.sharedEndpoints() {
+		if endpoint == name {
+			return true
+		}
+	}
+	return false
+}
+
+// maskAPIKey shows only the last characters of a key
+func maskAPIKey(apiKey string) string {
+	runes := []rune(apiKey)
+	if len(runes) <= 8 {
+		return "****"
+	}
+	return "****" + string(runes[len(runes)-4:])
+}
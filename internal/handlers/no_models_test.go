@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+func TestNoModelsConfigured(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID int64
+		want   string
+	}{
+		{name: "user", userID: 8, want: "请联系管理员添加"},
+		{name: "admin", userID: 7, want: "配置自定义模型"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Bot.AdminIDs = []int64{7}
+			service := &fakeAI{
+				models: []ai.ModelOption{},
+				reply:  func(context.Context, []models.Message) (string, error) { return "answer", nil },
+			}
+			h, telegram := newTestMessageHandler(t, cfg, service)
+			c := newTestCommandHandler(h)
+			
+			handleAndWait(t, h, privateMessage(tt.userID, tt.userID, 1, "hello"))
+			if service.requestCount() != 0 {
+				t.Errorf("AI got %d requests without any model", service.requestCount())
+			}
+			if reply := lastText(telegram.texts("editMessageText", tt.userID)); !strings.Contains(reply, tt.want) {
+				t.Errorf("replied %q, want %q", reply, tt.want)
+			}
+			
+			runCommand(t, c, tt.userID, tt.userID, "/models")
+			if text := lastText(telegram.texts("sendMessage", tt.userID)); !strings.Contains(text, tt.want) {
+				t.Errorf("/models answered %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteLastEndpoint(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	// Only a runtime endpoint is configured, the config file has none
+	configService := dynamicconfig.NewDynamicConfigService(nil, h.config, h.logger)
+	c := NewConfigHandler(h.bot, configService, h.storage, h.localizer, h.logger)
+	added := config.ModelEndpoint{Name: "added", DisplayName: "Added", BaseURL: "https://added.example.com/v1", APIKey: "sk-added",
+		Models: []config.ModelInfo{{ID: "added-model"}}}
+	if err := configService.AddEndpoint(ctx, 7, &added); err != nil {
+		t.Fatalf("AddEndpoint: %v", err)
+	}
+	endpoints := func() int {
+		current, err := configService.GetCurrentConfig(ctx)
+		if err != nil {
+			t.Fatalf("GetCurrentConfig: %v", err)
+		}
+		return len(current.Models.Endpoints)
+	}
+	press := func(data string) string {
+		t.Helper()
+		if err := c.HandleConfigCallback(ctx, configCallback(42, 7, data)); err != nil {
+			t.Fatalf("HandleConfigCallback(%s): %v", data, err)
+		}
+		edits := telegram.requests("editMessageText")
+		return edits[len(edits)-1].Get("reply_markup") + "\n" + edits[len(edits)-1].Get("text")
+	}
+	
+	// The confirmation warns and asks for the forced deletion
+	if shown := press("config:delete_endpoint:added"); !strings.Contains(shown, "最后一个端点") || !strings.Contains(shown, "config:force_delete:added") {
+		t.Errorf("confirmation %q, want the last-endpoint warning", shown)
+	}
+	// A plain confirmation, e.g. from an older message, asks again
+	if shown := press("config:confirm_delete:added"); !strings.Contains(shown, "最后一个端点") || endpoints() != 1 {
+		t.Errorf("plain confirmation showed %q with %d endpoints, want the warning again", shown, endpoints())
+	}
+	if shown := press("config:force_delete:added"); !strings.Contains(shown, "已删除") || endpoints() != 0 {
+		t.Errorf("forced deletion showed %q with %d endpoints, want none left", shown, endpoints())
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxCallbackDataLength is Telegram's limit on inline button callback data
+const maxCallbackDataLength = 64
+
+// needsOnboarding reports whether /start should walk the user through the
+// initial setup: the flow is enabled and the user has never saved settings.
+// Storage errors skip onboarding rather than showing it again to known users.
+func (h *CommandHandler) needsOnboarding(ctx context.Context, userID int64, isPrivate bool) bool {
+	if !h.config.Bot.Onboarding || !isPrivate {
+		return false
+	}
+	
+	settings, err := h.storage.GetUserSettings(ctx, userID)
+	return err == nil && settings == nil
+}
+
+// startOnboarding sends the first onboarding step, choosing a language
+func (h *CommandHandler) startOnboarding(chatID int64, lang string) error {
+	msg := tgbotapi.NewMessage(chatID, h.localizer.Get(lang, i18n.MsgOnboardingLanguage, nil))
+	msg.ReplyMarkup = h.createOnboardingLanguageKeyboard(lang)
+	
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// handleOnboardingCallback handles "onboard:lang:<lang>", "onboard:model:<id>"
+// and "onboard:skip". Every step saves what was picked so far, so leaving the
+// flow halfway never shows it again.
+func (h *CommandHandler) handleOnboardingCallback(ctx context.Context, chatID int64, messageID int, userID int64, action string, lang string, callbackID string) error {
+	settings, err := h.storage.GetUserSettings(ctx, userID)
+	if err != nil || settings == nil {
+		settings = &models.UserSettings{
+			UserID:   userID,
+			Language: lang,
+			Model:    h.config.Models.Default,
+		}
+	}
+	
+	step, value, _ := strings.Cut(action, ":")
+	switch step {
+	case "lang":
+		if !h.isConfiguredLanguage(value) {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "Invalid language"))
+			return nil
+		}
+		settings.Language = value
+		if !h.saveOnboardingSettings(ctx, userID, settings, callbackID) {
+			return nil
+		}
+		
+		// Without any model to pick the flow is already complete
+		if len(h.aiService.GetModelsForUser(userID)) == 0 {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, h.localizer.Get(value, "success.language_changed", nil)))
+			return h.finishOnboarding(chatID, messageID, value)
+		}
+		
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, h.localizer.Get(value, i18n.MsgOnboardingModel, nil))
+		keyboard := h.createOnboardingModelKeyboard(ctx, chatID, userID, settings.Model, value)
+		edit.ReplyMarkup = &keyboard
+		
+		_, err := h.bot.Send(edit)
+		h.bot.Request(tgbotapi.NewCallback(callbackID, h.localizer.Get(value, "success.language_changed", nil)))
+		return err
+	case "model":
+		chatSettings := h.getChatSettings(ctx, chatID)
+		model, err := h.aiService.GetModelByID(value)
+		if err != nil || !model.VisibleTo(userID) || !isModelAllowed(chatSettings.AllowedModels, value) {
+			keyboard := h.createOnboardingModelKeyboard(ctx, chatID, userID, settings.Model, settings.Language)
+			return h.answerStaleMenu(chatID, messageID, settings.Language, callbackID, &keyboard)
+		}
+		settings.Model = value
+		if !h.saveOnboardingSettings(ctx, userID, settings, callbackID) {
+			return nil
+		}
+		
+		h.bot.Request(tgbotapi.NewCallback(callbackID, h.localizer.Get(settings.Language, "success.model_changed", nil)))
+		return h.finishOnboarding(chatID, messageID, settings.Language)
+	case "skip":
+		if !h.saveOnboardingSettings(ctx, userID, settings, callbackID) {
+			return nil
+		}
+		
+		h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+		return h.finishOnboarding(chatID, messageID, settings.Language)
+	}
+	
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	return nil
+}
+
+// saveOnboardingSettings persists the user's settings, answering the callback
+// when saving fails
+func (h *CommandHandler) saveOnboardingSettings(ctx context.Context, userID int64, settings *models.UserSettings, callbackID string) bool {
+	if err := h.storage.SaveUserSettings(ctx, userID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save user settings")
+		h.bot.Request(tgbotapi.NewCallback(callbackID, h.localizer.Get(settings.Language, "error.save_failed", nil)))
+		return false
+	}
+	return true
+}
+
+// finishOnboarding replaces the onboarding message with the regular welcome
+func (h *CommandHandler) finishOnboarding(chatID int64, messageID int, lang string) error {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, h.localizer.Get(lang, i18n.MsgWelcome, nil))
+	edit.ParseMode = "Markdown"
+	keyboard := h.createMainMenuKeyboard(lang)
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	return err
+}
+
+// isConfiguredLanguage reports whether lang is one of the configured UI languages
+func (h *CommandHandler) isConfiguredLanguage(lang string) bool {
+	for _, l := range h.config.I18n.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// createOnboardingLanguageKeyboard lists the languages of the settings menu
+// with a skip button
+func (h *CommandHandler) createOnboardingLanguageKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
+	rows := h.languageRows(lang, "onboard:lang")
+	rows = append(rows, h.onboardingSkipRow(lang))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// createOnboardingModelKeyboard reuses the model selection keyboard, routing
+// its model buttons through onboarding and replacing the custom endpoint and
+// back buttons with a skip button
+func (h *CommandHandler) createOnboardingModelKeyboard(ctx context.Context, chatID int64, userID int64, currentModelID string, lang string) tgbotapi.InlineKeyboardMarkup {
+	selection := h.createModelSelectionKeyboard(userID, currentModelID, h.getChatSettings(ctx, chatID).AllowedModels)
+	
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(selection.InlineKeyboard))
+	for _, row := range selection.InlineKeyboard {
+		keep := true
+		for i, button := range row {
+			if button.CallbackData == nil {
+				continue
+			}
+			data := *button.CallbackData
+			switch {
+			case data == "config:add_endpoint" || data == "menu:main":
+				keep = false
+			case strings.HasPrefix(data, "model:"):
+				// Keep the plain model button when the longer data wouldn't fit
+				if onboarding := "onboard:" + data; len(onboarding) <= maxCallbackDataLength {
+					row[i].CallbackData = &onboarding
+				}
+			}
+		}
+		if keep {
+			rows = append(rows, row)
+		}
+	}
+	
+	rows = append(rows, h.onboardingSkipRow(lang))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// onboardingSkipRow is the button row that ends onboarding with defaults
+func (h *CommandHandler) onboardingSkipRow(lang string) []tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⏭ "+h.localizer.Get(lang, "button.skip", nil), "onboard:skip"),
+	)
+}
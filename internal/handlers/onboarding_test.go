@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+)
+
+// newOnboardingHandler returns a command handler with onboarding enabled,
+// Chinese and English as languages and service's models
+func newOnboardingHandler(t *testing.T, service *fakeAI) (*CommandHandler, *fakeTelegram) {
+	t.Helper()
+	cfg := newTestConfig()
+	cfg.Bot.Onboarding = true
+	cfg.I18n.Languages = []string{"zh-CN", "en-US"}
+	cfg.Models.Default = "model-a"
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	return newTestCommandHandler(h), telegram
+}
+
+func TestNeedsOnboarding(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newOnboardingHandler(t, &fakeAI{models: pollModels})
+	if err := c.storage.SaveUserSettings(ctx, 8, &models.UserSettings{UserID: 8, Language: "zh-CN"}); err != nil {
+		t.Fatalf("SaveUserSettings: %v", err)
+	}
+	
+	if !c.needsOnboarding(ctx, 7, true) {
+		t.Error("first interaction in a private chat skips onboarding")
+	}
+	if c.needsOnboarding(ctx, 7, false) {
+		t.Error("onboarding offered in a group")
+	}
+	if c.needsOnboarding(ctx, 8, true) {
+		t.Error("onboarding offered to a user with saved settings")
+	}
+	
+	c.config.Bot.Onboarding = false
+	if c.needsOnboarding(ctx, 7, true) {
+		t.Error("onboarding offered while disabled")
+	}
+}
+
+func TestOnboardingFlow(t *testing.T) {
+	ctx := context.Background()
+	c, telegram := newOnboardingHandler(t, &fakeAI{models: pollModels})
+	
+	runCommand(t, c, 7, 7, "/start")
+	sent := telegram.requests("sendMessage")
+	if len(sent) != 1 || !strings.Contains(sent[0].Get("text"), "开始之前先做两步简单设置") {
+		t.Fatalf("/start sent %v, want the language step", sent)
+	}
+	if markup := sent[0].Get("reply_markup"); !strings.Contains(markup, "onboard:lang:en-US") || !strings.Contains(markup, "onboard:skip") {
+		t.Errorf("language step offers %s, want the languages and skip", markup)
+	}
+	
+	pressButton(t, c, 7, 7, "onboard:lang:en-US")
+	if settings, _ := c.storage.GetUserSettings(ctx, 7); settings == nil || settings.Language != "en-US" {
+		t.Fatalf("user settings %+v, want en-US saved", settings)
+	}
+	edits := telegram.requests("editMessageText")
+	step := edits[len(edits)-1]
+	if !strings.Contains(step.Get("text"), "Please choose the AI model") {
+		t.Errorf("model step says %q, want it in English", step.Get("text"))
+	}
+	markup := step.Get("reply_markup")
+	if !strings.Contains(markup, "onboard:model:model-b") || !strings.Contains(markup, "onboard:skip") {
+		t.Errorf("model step offers %s, want onboarding model buttons and skip", markup)
+	}
+	if strings.Contains(markup, "config:add_endpoint") || strings.Contains(markup, "menu:main") {
+		t.Errorf("model step offers %s, want the menu buttons left out", markup)
+	}
+	
+	pressButton(t, c, 7, 7, "onboard:model:model-b")
+	settings, _ := c.storage.GetUserSettings(ctx, 7)
+	if settings == nil || settings.Language != "en-US" || settings.Model != "model-b" {
+		t.Fatalf("user settings %+v, want en-US and model-b", settings)
+	}
+	edits = telegram.requests("editMessageText")
+	if text := edits[len(edits)-1].Get("text"); !strings.Contains(text, "I'm your AI assistant") {
+		t.Errorf("onboarding ended with %q, want the English welcome", text)
+	}
+	
+	// Once done, /start only welcomes
+	runCommand(t, c, 7, 7, "/start")
+	if text := lastText(telegram.texts("sendMessage", 7)); strings.Contains(text, "Two quick steps") || strings.Contains(text, "两步简单设置") {
+		t.Errorf("second /start answered %q, want the welcome", text)
+	}
+}
+
+func TestOnboardingSkip(t *testing.T) {
+	ctx := context.Background()
+	c, telegram := newOnboardingHandler(t, &fakeAI{models: pollModels})
+	
+	runCommand(t, c, 7, 7, "/start")
+	pressButton(t, c, 7, 7, "onboard:skip")
+	
+	settings, _ := c.storage.GetUserSettings(ctx, 7)
+	if settings == nil || settings.Language != "zh-CN" || settings.Model != "model-a" {
+		t.Fatalf("user settings %+v, want the defaults saved", settings)
+	}
+	if text := lastText(telegram.texts("editMessageText", 7)); !strings.Contains(text, "我是您的 AI 助手") {
+		t.Errorf("skipping ended with %q, want the welcome", text)
+	}
+	if c.needsOnboarding(ctx, 7, true) {
+		t.Error("skipped onboarding is offered again")
+	}
+}
+
+func TestOnboardingWithoutModels(t *testing.T) {
+	ctx := context.Background()
+	c, telegram := newOnboardingHandler(t, &fakeAI{models: []ai.ModelOption{}})
+	
+	runCommand(t, c, 7, 7, "/start")
+	pressButton(t, c, 7, 7, "onboard:lang:zh-CN")
+	
+	if settings, _ := c.storage.GetUserSettings(ctx, 7); settings == nil || settings.Language != "zh-CN" {
+		t.Fatalf("user settings %+v, want zh-CN saved", settings)
+	}
+	// There is no model to pick, so the language ends the flow
+	if text := lastText(telegram.texts("editMessageText", 7)); !strings.Contains(text, "我是您的 AI 助手") {
+		t.Errorf("language step ended with %q, want the welcome", text)
+	}
+}
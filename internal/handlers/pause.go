@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatPaused reports whether an admin paused the bot in the chat
+func (h *CommandHandler) chatPaused(ctx context.Context, chatID int64) bool {
+	settings, err := h.storage.GetSettings(ctx, chatID)
+	return err == nil && settings != nil && settings.Paused
+}
+
+// handlePause handles /pause and /resume commands. While paused the bot ignores
+// every message and command in the chat except /resume.
+func (h *CommandHandler) handlePause(ctx context.Context, chatID int64, userID int64, paused bool) error {
+	if !h.isChatAdmin(chatID, userID) {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 仅群管理员可以暂停或恢复机器人"))
+		return err
+	}
+	
+	settings := h.getChatSettings(ctx, chatID)
+	if settings.Paused == paused {
+		text := "▶️ 机器人未暂停"
+		if paused {
+			text = "⏸ 机器人已处于暂停状态，发送 /resume 恢复"
+		}
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, text))
+		return err
+	}
+	
+	settings.Paused = paused
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	text := "▶️ 机器人已恢复，将正常回复消息"
+	if paused {
+		text = "⏸ 机器人已暂停，将不再回复本聊天的消息。管理员发送 /resume 恢复"
+	}
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestPauseAndResume(t *testing.T) {
+	ctx := context.Background()
+	const (
+		chatID = -100
+		admin  = 1
+		member = 8
+	)
+	cfg := newTestConfig()
+	cfg.Bot.AdminIDs = []int64{admin}
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer", nil
+	}}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	c := newTestCommandHandler(h)
+	saveChatSettings(t, h, chatID, func(s *models.ChatSettings) { s.Keywords = []string{"weather"} })
+	sentCount := func() int { return len(telegram.texts("sendMessage", chatID)) }
+	
+	// Members can't pause the bot
+	runCommand(t, c, chatID, member, "/pause")
+	if text := lastText(telegram.texts("sendMessage", chatID)); !strings.Contains(text, "仅群管理员") {
+		t.Errorf("member's /pause answered %q, want it refused", text)
+	}
+	if c.chatPaused(ctx, chatID) {
+		t.Fatal("member paused the chat")
+	}
+	
+	runCommand(t, c, chatID, admin, "/pause")
+	if text := lastText(telegram.texts("sendMessage", chatID)); !strings.Contains(text, "机器人已暂停") {
+		t.Errorf("/pause answered %q, want the pause notice", text)
+	}
+	settings, err := h.storage.GetSettings(ctx, chatID)
+	if err != nil || settings == nil || !settings.Paused {
+		t.Fatalf("stored settings %+v, %v, want the chat paused", settings, err)
+	}
+	if len(settings.Keywords) != 1 {
+		t.Errorf("pausing dropped the keywords: %+v", settings)
+	}
+	
+	// Nothing gets an answer while paused
+	before := sentCount()
+	if respond, _ := h.shouldRespond(ctx, groupMessage(chatID, member, "what's the weather")); respond {
+		t.Error("paused chat responds to a keyword")
+	}
+	handleAndWait(t, h, groupMessage(chatID, member, "what's the weather"))
+	runCommand(t, c, chatID, member, "/help")
+	runCommand(t, c, chatID, admin, "/help")
+	runCommand(t, c, chatID, member, "/resume")
+	if service.requestCount() != 0 || sentCount() != before {
+		t.Errorf("paused chat got %d AI requests and %d messages, want none", service.requestCount(), sentCount()-before)
+	}
+	if err := h.HandleFollowUpCallback(ctx, configCallback(chatID, member, "followup:0")); err != nil {
+		t.Fatalf("HandleFollowUpCallback: %v", err)
+	}
+	answers := telegram.requests("answerCallbackQuery")
+	if len(answers) == 0 || !strings.Contains(answers[len(answers)-1].Get("text"), "暂停") {
+		t.Errorf("follow-up button answered %v, want the pause notice", answers)
+	}
+	if service.requestCount() != 0 {
+		t.Errorf("follow-up button reached the AI while paused")
+	}
+	
+	// Only an admin's /resume gets through, and everything answers again
+	runCommand(t, c, chatID, admin, "/resume")
+	if text := lastText(telegram.texts("sendMessage", chatID)); !strings.Contains(text, "机器人已恢复") {
+		t.Errorf("/resume answered %q, want the resume notice", text)
+	}
+	if c.chatPaused(ctx, chatID) {
+		t.Fatal("chat still paused after /resume")
+	}
+	handleAndWait(t, h, groupMessage(chatID, member, "what's the weather"))
+	if service.requestCount() != 1 {
+		t.Errorf("AI got %d requests after /resume, want 1", service.requestCount())
+	}
+}
+
+func TestPauseCommandTwice(t *testing.T) {
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	runCommand(t, c, 42, 42, "/resume")
+	if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, "机器人未暂停") {
+		t.Errorf("/resume of a running bot answered %q", text)
+	}
+	runCommand(t, c, 42, 42, "/pause")
+	sent := len(telegram.texts("sendMessage", 42))
+	
+	// A paused chat ignores even the admin's /pause
+	runCommand(t, c, 42, 42, "/pause")
+	if got := len(telegram.texts("sendMessage", 42)); got != sent {
+		t.Errorf("second /pause answered %q, want it ignored", lastText(telegram.texts("sendMessage", 42)))
+	}
+}
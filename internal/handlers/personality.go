@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultPersonality is used when neither the chat nor the config sets one
+const defaultPersonality = "cute"
+
+// personalityOrder lists the known personalities in display order
+var personalityOrder = []string{"cute", "professional", "humorous", "warm"}
+
+// personalityNames maps personalities to their display names
+var personalityNames = map[string]string{
+	"cute":         "🥰 可爱",
+	"professional": "💼 专业",
+	"humorous":     "😄 幽默",
+	"warm":         "☀️ 温暖",
+}
+
+// personalityPrompts holds the tone instruction folded into the system prompt
+var personalityPrompts = map[string]string{
+	"cute":         "请用可爱、活泼的语气回答，可以适当使用表情符号。",
+	"professional": "请用专业、严谨、简洁的语气回答。",
+	"humorous":     "请用幽默风趣的语气回答，适当加入轻松的玩笑。",
+	"warm":         "请用温暖、体贴、富有同理心的语气回答。",
+}
+
+// isValidPersonality checks whether the personality is known
+func isValidPersonality(personality string) bool {
+	_, ok := personalityNames[personality]
+	return ok
+}
+
+// resolvePersonality returns the chat personality, falling back to the config default
+func resolvePersonality(cfg *config.Config, chatPersonality string) string {
+	if isValidPersonality(chatPersonality) {
+		return chatPersonality
+	}
+	if isValidPersonality(cfg.Context.BotPersonality) {
+		return cfg.Context.BotPersonality
+	}
+	return defaultPersonality
+}
+
+// injectPersonality appends the tone instruction of a per-chat personality
+// to the system prompt so the whole conversation follows it
+func injectPersonality(messages []models.Message, personality string) []models.Message {
+	if !isValidPersonality(personality) {
+		return messages
+	}
+	if len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	
+	messages[0].Content = strings.TrimSpace(messages[0].Content + "\n\n" + personalityPrompts[personality])
+	return messages
+}
+
+// handlePersonality handles /personality command
+func (h *CommandHandler) handlePersonality(ctx context.Context, chatID int64, args string) error {
+	personality := strings.ToLower(strings.TrimSpace(args))
+	if personality == "" {
+		settings := h.getChatSettings(ctx, chatID)
+		msg := tgbotapi.NewMessage(chatID, h.personalityMenuText(settings))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = h.createPersonalityKeyboard(settings)
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	
+	if !isValidPersonality(personality) {
+		text := fmt.Sprintf("❌ 未知的性格：%s\n可选：%s", personality, strings.Join(personalityOrder, ", "))
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, text))
+		return err
+	}
+	
+	if err := h.setPersonality(ctx, chatID, personality); err != nil {
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ 性格已切换为："+personalityNames[personality]))
+	return err
+}
+
+// handlePersonalityCallback handles personality selection callbacks
+func (h *CommandHandler) handlePersonalityCallback(ctx context.Context, chatID int64, messageID int, action string, callbackID string) error {
+	if action != "menu" {
+		// "default" clears the per-chat override
+		personality := action
+		if personality == "default" {
+			personality = ""
+		} else if !isValidPersonality(personality) {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "未知的性格"))
+			return nil
+		}
+		
+		if err := h.setPersonality(ctx, chatID, personality); err != nil {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "保存失败"))
+			return nil
+		}
+	}
+	
+	settings := h.getChatSettings(ctx, chatID)
+	keyboard := h.createPersonalityKeyboard(settings)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, h.personalityMenuText(settings))
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	return err
+}
+
+// setPersonality stores the per-chat personality
+func (h *CommandHandler) setPersonality(ctx context.Context, chatID int64, personality string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	settings.Personality = personality
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		return err
+	}
+	return nil
+}
+
+// personalityMenuText describes the current personality
+func (h *CommandHandler) personalityMenuText(settings *models.ChatSettings) string {
+	current := resolvePersonality(h.config, settings.Personality)
+	
+	var text strings.Builder
+	text.WriteString("🎭 **机器人性格**\n\n")
+	text.WriteString(fmt.Sprintf("当前性格：%s", personalityNames[current]))
+	if settings.Personality == "" {
+		text.WriteString("（默认）")
+	}
+	text.WriteString("\n\n性格会影响问候语以及整个对话的语气，请选择：")
+	return text.String()
+}
+
+// createPersonalityKeyboard creates the personality selection keyboard
+func (h *CommandHandler) createPersonalityKeyboard(settings *models.ChatSettings) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	
+	for _, personality := range personalityOrder {
+		checkmark := ""
+		if personality == settings.Personality {
+			checkmark = "✅ "
+		}
+		rows = append(rows, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(checkmark+personalityNames[personality], "personality:"+personality),
+		})
+	}
+	
+	defaultMark := ""
+	if settings.Personality == "" {
+		defaultMark = "✅ "
+	}
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(defaultMark+"🔄 使用默认", "personality:default"),
+	})
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:settings"),
+	})
+	
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
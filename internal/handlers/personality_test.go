@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestResolvePersonality(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		chat   string
+		want   string
+	}{
+		{name: "chat overrides config", config: "professional", chat: "humorous", want: "humorous"},
+		{name: "config without chat", config: "professional", want: "professional"},
+		{name: "unknown chat personality", config: "warm", chat: "grumpy", want: "warm"},
+		{name: "neither set", want: defaultPersonality},
+		{name: "unknown config personality", config: "grumpy", want: defaultPersonality},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Context.BotPersonality = tt.config
+			if got := resolvePersonality(cfg, tt.chat); got != tt.want {
+				t.Errorf("resolvePersonality(%q, %q) = %q, want %q", tt.config, tt.chat, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionGreetingPersonality(t *testing.T) {
+	tests := []struct {
+		name string
+		chat string
+		want string
+	}{
+		{name: "chat personality", chat: "humorous", want: "humorous"},
+		{name: "config personality", chat: "", want: "professional"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Context.BotPersonality = "professional"
+			h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+			update := privateMessage(100, 7, 1, "小助手")
+			
+			before := time.Now().Hour()
+			var got []string
+			// More greetings than the recent ones skipped, so the history wraps
+			for i := 0; i < 10; i++ {
+				got = append(got, h.addMentionGreeting("小助手", "小助手", tt.chat, update))
+			}
+			after := time.Now().Hour()
+			
+			want := make(map[string]bool)
+			for _, greeting := range append(mentionGreetings(tt.want, before), mentionGreetings(tt.want, after)...) {
+				want[greeting] = true
+			}
+			for _, greeting := range got {
+				if !want[greeting] {
+					t.Errorf("greeting %q isn't one of the %s greetings", greeting, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMentionGreetingsDifferByPersonality(t *testing.T) {
+	for hour := 0; hour < 24; hour++ {
+		seen := make(map[string]string)
+		for _, personality := range personalityOrder {
+			for _, greeting := range mentionGreetings(personality, hour) {
+				if other, ok := seen[greeting]; ok && other != personality {
+					t.Errorf("hour %d: %q is both a %s and a %s greeting", hour, greeting, other, personality)
+				}
+				seen[greeting] = personality
+			}
+		}
+	}
+}
+
+func TestInjectPersonality(t *testing.T) {
+	tests := []struct {
+		name        string
+		messages    []models.Message
+		personality string
+		want        string
+	}{
+		{
+			name:        "appended to the system prompt",
+			messages:    []models.Message{{Role: "system", Content: "你是助手。"}, {Role: "user", Content: "你好"}},
+			personality: "warm",
+			want:        "你是助手。\n\n" + personalityPrompts["warm"],
+		},
+		{
+			name:        "no chat personality",
+			messages:    []models.Message{{Role: "system", Content: "你是助手。"}, {Role: "user", Content: "你好"}},
+			personality: "",
+			want:        "你是助手。",
+		},
+		{
+			name:        "no system prompt",
+			messages:    []models.Message{{Role: "user", Content: "你好"}},
+			personality: "warm",
+			want:        "你好",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := injectPersonality(tt.messages, tt.personality)
+			if got[0].Content != tt.want {
+				t.Errorf("first message %q, want %q", got[0].Content, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+// postProcessor is a compiled answer transformation rule
+type postProcessor struct {
+	name        string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// compilePostProcessors compiles the configured rules, keeping their order
+func compilePostProcessors(rules []config.PostProcessorConfig) ([]postProcessor, error) {
+	processors := make([]postProcessor, 0, len(rules))
+	for i, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("post processor %d (%s): %w", i+1, rule.Name, err)
+		}
+		processors = append(processors, postProcessor{
+			name:        rule.Name,
+			pattern:     pattern,
+			replacement: rule.Replacement,
+		})
+	}
+	return processors, nil
+}
+
+// applyPostProcessors runs the answer through each rule in order
+func applyPostProcessors(response string, processors []postProcessor) string {
+	for _, processor := range processors {
+		response = processor.pattern.ReplaceAllString(response, processor.replacement)
+	}
+	return response
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// testPostProcessorRules redact internal links, then name what was redacted.
+// Swapping them would leave the second rule nothing to match.
+var testPostProcessorRules = []config.PostProcessorConfig{
+	{Name: "redact-internal-urls", Pattern: `https?://[a-z0-9.-]+\.internal\S*`, Replacement: "[内部链接]"},
+	{Name: "name-redactions", Pattern: `\[内部链接\]`, Replacement: "[内部链接已隐藏]"},
+	{Name: "placeholders", Pattern: `\{\{(\w+)\}\}`, Replacement: "<$1>"},
+	{Name: "disclaimer", Pattern: `\z`, Replacement: "\n\n——以上内容由 AI 生成"},
+}
+
+func TestApplyPostProcessors(t *testing.T) {
+	processors, err := compilePostProcessors(testPostProcessorRules)
+	if err != nil {
+		t.Fatalf("compilePostProcessors: %v", err)
+	}
+	
+	got := applyPostProcessors("See https://wiki.corp.internal/page and {{name}}.", processors)
+	if want := "See [内部链接已隐藏] and <name>.\n\n——以上内容由 AI 生成"; got != want {
+		t.Errorf("applyPostProcessors = %q, want %q", got, want)
+	}
+	if got := applyPostProcessors("unchanged", nil); got != "unchanged" {
+		t.Errorf("without rules the answer became %q", got)
+	}
+}
+
+func TestCompilePostProcessorsBadPattern(t *testing.T) {
+	rules := []config.PostProcessorConfig{
+		{Name: "fine", Pattern: `a+`},
+		{Name: "broken", Pattern: `[unclosed`},
+	}
+	_, err := compilePostProcessors(rules)
+	if err == nil {
+		t.Fatal("compilePostProcessors accepted an invalid pattern")
+	}
+	if !strings.Contains(err.Error(), "post processor 2 (broken)") {
+		t.Errorf("error %q, want the broken rule named", err)
+	}
+}
+
+func TestPostProcessorsApplied(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.PostProcessors = testPostProcessorRules
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "<think>check https://wiki.corp.internal/x</think>Read https://docs.corp.internal/guide", nil
+	}}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "question"))
+	
+	// The rules run on the answer left after the reasoning is stripped
+	answer := lastText(telegram.texts("editMessageText", 42))
+	if want := "Read [内部链接已隐藏]\n\n——以上内容由 AI 生成"; answer != want {
+		t.Errorf("answer %q, want %q", answer, want)
+	}
+}
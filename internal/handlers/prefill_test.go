@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+func TestPrefillCommands(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	commands := newTestCommandHandler(h)
+	
+	steps := []struct {
+		command string
+		want    string
+	}{
+		{command: "/json", want: "{"},
+		{command: "/json", want: ""},
+		{command: "/prefill  Sure, here is", want: "Sure, here is"},
+		{command: "/json", want: "{"},
+		{command: "/prefill", want: ""},
+	}
+	for _, step := range steps {
+		if err := commands.HandleCommand(ctx, command(42, 7, step.command)); err != nil {
+			t.Fatalf("%s: %v", step.command, err)
+		}
+		settings, err := h.storage.GetSettings(ctx, 42)
+		if err != nil || settings == nil {
+			t.Fatalf("GetSettings = %v, %v", settings, err)
+		}
+		if settings.Prefill != step.want {
+			t.Errorf("after %s prefill is %q, want %q", step.command, settings.Prefill, step.want)
+		}
+	}
+}
+
+func TestPrefilledAnswer(t *testing.T) {
+	ctx := context.Background()
+	var sent []models.Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []models.Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = body.Messages
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": `ok: true}`}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	cfg := newTestConfig()
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", BaseURL: server.URL, APIKey: "sk-test", SupportsPrefill: true,
+		Models: []config.ModelInfo{{ID: testModel}},
+	}}
+	service := ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger())
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	saveChatSettings(t, h, 42, func(s *models.ChatSettings) { s.Prefill = jsonPrefill })
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "Status as JSON"))
+	
+	if last := sent[len(sent)-1]; last.Role != "assistant" || last.Content != "{" {
+		t.Errorf("request ends with %+v, want the prefill", last)
+	}
+	edits := telegram.texts("editMessageText", 42)
+	if len(edits) == 0 || edits[len(edits)-1] != "{ok: true}" {
+		t.Errorf("answered %q, want the merged JSON", edits)
+	}
+	// The conversation keeps the whole answer, not the prefill as a turn of its own
+	chatCtx, err := h.storage.GetContext(ctx, 42)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("GetContext = %v, %v", chatCtx, err)
+	}
+	last := chatCtx.Messages[len(chatCtx.Messages)-1]
+	if last.Role != "assistant" || last.Content != "{ok: true}" {
+		t.Errorf("stored answer %+v, want the merged JSON", last)
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultProfiles are offered when the config defines no profiles
+var defaultProfiles = []config.ProfileConfig{
+	{
+		Name:           "coding",
+		DisplayName:    "💻 编程",
+		Temperature:    0.1,
+		TopP:           0.9,
+		PromptAddendum: "回答编程问题时请给出准确、可运行的代码，并简要说明关键步骤。",
+	},
+	{
+		Name:        "chat",
+		DisplayName: "💬 聊天",
+		Temperature: 0.7,
+	},
+	{
+		Name:           "brainstorm",
+		DisplayName:    "💡 头脑风暴",
+		Temperature:    1.1,
+		TopP:           0.95,
+		PromptAddendum: "请大胆发散思维，尽量提供多样、新颖的想法。",
+	},
+}
+
+// chatProfiles returns the configured use-case profiles or the defaults
+func chatProfiles(cfg *config.Config) []config.ProfileConfig {
+	if len(cfg.Context.Profiles) > 0 {
+		return cfg.Context.Profiles
+	}
+	return defaultProfiles
+}
+
+// findProfile looks up a profile by name
+func findProfile(cfg *config.Config, name string) (config.ProfileConfig, bool) {
+	if name == "" {
+		return config.ProfileConfig{}, false
+	}
+	for _, profile := range chatProfiles(cfg) {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return config.ProfileConfig{}, false
+}
+
+// profileDisplayName returns the name shown for a profile
+func profileDisplayName(profile config.ProfileConfig) string {
+	if profile.DisplayName != "" {
+		return profile.DisplayName
+	}
+	return profile.Name
+}
+
+// profileRequestOptions returns the sampling parameters of the chat's profile
+func profileRequestOptions(cfg *config.Config, name string) []ai.RequestOption {
+	profile, ok := findProfile(cfg, name)
+	if !ok {
+		return nil
+	}
+	
+	opts := []ai.RequestOption{ai.WithTemperature(profile.Temperature)}
+	if profile.TopP > 0 {
+		opts = append(opts, ai.WithTopP(profile.TopP))
+	}
+	return opts
+}
+
+// injectProfile appends the profile's prompt addendum to the system prompt
+func (h *MessageHandler) injectProfile(messages []models.Message, name string) []models.Message {
+	profile, ok := findProfile(h.config, name)
+	if !ok || profile.PromptAddendum == "" {
+		return messages
+	}
+	if len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	
+	messages[0].Content += "\n\n" + profile.PromptAddendum
+	return messages
+}
+
+// handleProfileCallback handles use-case profile selection callbacks
+func (h *CommandHandler) handleProfileCallback(ctx context.Context, chatID int64, messageID int, action string, callbackID string) error {
+	if action != "menu" {
+		// "none" clears the profile
+		name := action
+		if name == "none" {
+			name = ""
+		} else if _, ok := findProfile(h.config, name); !ok {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "未知的场景"))
+			return nil
+		}
+		
+		settings := h.getChatSettings(ctx, chatID)
+		settings.Profile = name
+		if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+			h.logger.WithError(err).Error("Failed to save settings")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "保存失败"))
+			return nil
+		}
+	}
+	
+	settings := h.getChatSettings(ctx, chatID)
+	
+	var text strings.Builder
+	text.WriteString("🎛 **使用场景**\n\n")
+	if profile, ok := findProfile(h.config, settings.Profile); ok {
+		text.WriteString(fmt.Sprintf("当前场景：%s\n温度：%.2f", profileDisplayName(profile), profile.Temperature))
+		if profile.TopP > 0 {
+			text.WriteString(fmt.Sprintf("，Top P：%.2f", profile.TopP))
+		}
+		text.WriteString("\n")
+	} else {
+		text.WriteString("当前场景：默认\n")
+	}
+	text.WriteString("\n场景会一次性设置温度等生成参数以及附加的提示词。")
+	
+	keyboard := h.createProfileKeyboard(settings.Profile)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text.String())
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	return err
+}
+
+// createProfileKeyboard creates the use-case profile selection keyboard
+func (h *CommandHandler) createProfileKeyboard(current string) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	
+	for _, profile := range chatProfiles(h.config) {
+		checkmark := ""
+		if profile.Name == current {
+			checkmark = "✅ "
+		}
+		rows = append(rows, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(checkmark+profileDisplayName(profile), "profile:"+profile.Name),
+		})
+	}
+	
+	noneMark := ""
+	if current == "" {
+		noneMark = "✅ "
+	}
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(noneMark+"🔄 默认", "profile:none"),
+	})
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:settings"),
+	})
+	
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+// sentRequest is the part of a chat completion request the tests look at
+type sentRequest struct {
+	Messages    []models.Message `json:"messages"`
+	Temperature *float64         `json:"temperature"`
+	TopP        *float64         `json:"top_p"`
+}
+
+// newRecordingAI returns a real AI service for testModel and a function
+// returning the latest request its endpoint received
+func newRecordingAI(t *testing.T, cfg *config.Config) (ai.Service, func() sentRequest) {
+	var mu sync.Mutex
+	var last sentRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request sentRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		mu.Lock()
+		last = request
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"answer"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", DisplayName: "Test", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: testModel}},
+	}}
+	service := ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger())
+	return service, func() sentRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+// pressButton hands a callback query of userID pressing a button with data to c
+func pressButton(t *testing.T, c *CommandHandler, chatID, userID int64, data string) {
+	t.Helper()
+	if err := c.HandleCallbackQuery(context.Background(), configCallback(chatID, userID, data)); err != nil {
+		t.Fatalf("HandleCallbackQuery(%s): %v", data, err)
+	}
+}
+
+func floatOrNil(f *float64) interface{} {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+func TestProfileAppliedToRequest(t *testing.T) {
+	tests := []struct {
+		name            string
+		profiles        []config.ProfileConfig
+		button          string
+		wantTemperature interface{}
+		wantTopP        interface{}
+		wantAddendum    string
+	}{
+		{name: "coding", button: "profile:coding", wantTemperature: 0.1, wantTopP: 0.9, wantAddendum: "准确、可运行的代码"},
+		{name: "chat", button: "profile:chat", wantTemperature: 0.7},
+		{name: "brainstorm", button: "profile:brainstorm", wantTemperature: 1.1, wantTopP: 0.95, wantAddendum: "大胆发散思维"},
+		{name: "profile cleared", button: "profile:none"},
+		{
+			name:            "configured profile",
+			profiles:        []config.ProfileConfig{{Name: "poet", Temperature: 1.3, TopP: 0.8, PromptAddendum: "Answer in rhyme."}},
+			button:          "profile:poet",
+			wantTemperature: 1.3,
+			wantTopP:        0.8,
+			wantAddendum:    "Answer in rhyme.",
+		},
+		// The built-in profiles are replaced by the configured ones
+		{name: "built-in profile not configured", profiles: []config.ProfileConfig{{Name: "poet", Temperature: 1.3}}, button: "profile:coding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Context.DefaultSystemPrompt = "You are a helpful assistant."
+			cfg.Context.Profiles = tt.profiles
+			service, lastRequest := newRecordingAI(t, cfg)
+			h, _ := newTestMessageHandler(t, cfg, service)
+			c := newTestCommandHandler(h)
+			
+			pressButton(t, c, 42, 7, tt.button)
+			handleAndWait(t, h, privateMessage(42, 7, 1, "question"))
+			
+			request := lastRequest()
+			if got := floatOrNil(request.Temperature); got != tt.wantTemperature {
+				t.Errorf("temperature = %v, want %v", got, tt.wantTemperature)
+			}
+			if got := floatOrNil(request.TopP); got != tt.wantTopP {
+				t.Errorf("top_p = %v, want %v", got, tt.wantTopP)
+			}
+			if len(request.Messages) == 0 || request.Messages[0].Role != "system" {
+				t.Fatalf("request %+v has no system prompt", request.Messages)
+			}
+			system := request.Messages[0].Content
+			if tt.wantAddendum != "" && !strings.Contains(system, tt.wantAddendum) {
+				t.Errorf("system prompt %q, want the profile's addendum %q", system, tt.wantAddendum)
+			}
+			if tt.wantAddendum == "" && system != cfg.Context.DefaultSystemPrompt {
+				t.Errorf("system prompt %q, want the default one", system)
+			}
+		})
+	}
+}
+
+func TestChatParamsOverrideProfile(t *testing.T) {
+	cfg := newTestConfig()
+	service, lastRequest := newRecordingAI(t, cfg)
+	h, _ := newTestMessageHandler(t, cfg, service)
+	c := newTestCommandHandler(h)
+	
+	pressButton(t, c, 42, 7, "profile:coding")
+	settings := c.getChatSettings(context.Background(), 42)
+	temperature := 0.5
+	settings.AIParams.Temperature = &temperature
+	c.storage.SaveSettings(context.Background(), 42, settings)
+	handleAndWait(t, h, privateMessage(42, 7, 1, "question"))
+	
+	request := lastRequest()
+	if got := floatOrNil(request.Temperature); got != 0.5 {
+		t.Errorf("temperature = %v, want the chat's 0.5", got)
+	}
+	// Parameters the chat leaves unset still come from the profile
+	if got := floatOrNil(request.TopP); got != 0.9 {
+		t.Errorf("top_p = %v, want the profile's 0.9", got)
+	}
+}
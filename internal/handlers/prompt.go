@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// reminderMaxRunes caps the length of the condensed system prompt reminder
+const reminderMaxRunes = 200
+
+// buildRequestMessages assembles the outgoing messages for an AI request.
+// The stored context is never modified, so anything injected here does not accumulate.
+func (h *MessageHandler) buildRequestMessages(ctx context.Context, chatCtx *models.ChatContext, userID int64) []models.Message {
+	messages := make([]models.Message, len(chatCtx.Messages))
+	copy(messages, chatCtx.Messages)
+
+	memories, err := h.storage.GetMemories(ctx, userID)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to get memories")
+	}
+	messages = injectMemories(messages, memories)
+
+	messages = injectPersonality(messages, chatCtx.Settings.Personality)
+	messages = injectResponseLanguage(messages, chatCtx.Settings.ResponseLanguage)
+	messages = injectResponseStyle(messages, chatCtx.Settings.ResponseStyle)
+	messages = h.injectProfile(messages, chatCtx.Settings.Profile)
+	messages = normalizeTurns(messages)
+	messages = h.injectPromptReminder(messages)
+
+	return messages
+}
+
+// normalizeTurns merges consecutive messages of the same role and drops assistant
+// turns preceding the first user turn, so that the conversation strictly alternates
+// after the leading system messages. Some providers reject anything else. Tool
+// results each answer their own call and are never merged.
+func normalizeTurns(messages []models.Message) []models.Message {
+	result := make([]models.Message, 0, len(messages))
+	for _, msg := range messages {
+		last := len(result) - 1
+		switch {
+		case msg.Role == "assistant" && (last < 0 || result[last].Role == "system"):
+			continue
+		case last >= 0 && result[last].Role == msg.Role && msg.Role != "tool":
+			result[last].Content += "\n" + msg.Content
+		default:
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// injectPromptReminder re-injects a condensed copy of the system prompt before the
+// latest user turn every N user turns, keeping long conversations on track
+func (h *MessageHandler) injectPromptReminder(messages []models.Message) []models.Message {
+	interval := h.config.Context.SystemReminderInterval
+	if interval <= 0 || len(messages) < 2 || messages[0].Role != "system" {
+		return messages
+	}
+
+	userTurns := 0
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			userTurns++
+		}
+	}
+
+	last := len(messages) - 1
+	if userTurns == 0 || userTurns%interval != 0 || messages[last].Role != "user" {
+		return messages
+	}
+
+	prompt := []rune(messages[0].Content)
+	if len(prompt) == 0 {
+		return messages
+	}
+	if len(prompt) > reminderMaxRunes {
+		prompt = append(prompt[:reminderMaxRunes], []rune("...")...)
+	}
+
+	reminder := models.Message{
+		Role:    "system",
+		Content: fmt.Sprintf("提醒：请继续遵循最初的设定回答。\n%s", string(prompt)),
+	}
+
+	result := make([]models.Message, 0, len(messages)+1)
+	result = append(result, messages[:last]...)
+	result = append(result, reminder, messages[last])
+	return result
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// findPromptPreset looks up a preset of the prompt library by ID
+func findPromptPreset(cfg *config.Config, id string) (config.PromptPresetConfig, bool) {
+	for _, preset := range cfg.Context.PromptLibrary {
+		if preset.ID == id {
+			return preset, true
+		}
+	}
+	return config.PromptPresetConfig{}, false
+}
+
+// localizedPreset returns the preset's name and prompt in a language, falling
+// back to the untranslated ones. Viper lower-cases map keys, so lookups are
+// lower-case too.
+func localizedPreset(preset config.PromptPresetConfig, lang string) (string, string) {
+	name, prompt := preset.Name, preset.Prompt
+	if text, ok := preset.Localized[strings.ToLower(lang)]; ok {
+		if text.Name != "" {
+			name = text.Name
+		}
+		if text.Prompt != "" {
+			prompt = text.Prompt
+		}
+	}
+	return name, prompt
+}
+
+// activePromptPreset returns the ID of the preset whose prompt, in any of its
+// languages, is the chat's system prompt; empty when it is a custom prompt
+func activePromptPreset(cfg *config.Config, systemPrompt string) string {
+	for _, preset := range cfg.Context.PromptLibrary {
+		if preset.Prompt == systemPrompt {
+			return preset.ID
+		}
+		for _, text := range preset.Localized {
+			if text.Prompt != "" && text.Prompt == systemPrompt {
+				return preset.ID
+			}
+		}
+	}
+	return ""
+}
+
+// handlePromptLibraryCallback handles "prompt_lib:menu", "prompt_lib:<id>"
+// and "prompt_lib:default". Switching the system prompt clears the context so
+// the new persona starts fresh.
+func (h *CommandHandler) handlePromptLibraryCallback(ctx context.Context, chatID int64, messageID int, action string, lang string, callbackID string) error {
+	answer := ""
+	if action != "menu" {
+		prompt := h.config.Context.DefaultSystemPrompt
+		if action != "default" {
+			preset, ok := findPromptPreset(h.config, action)
+			if !ok {
+				h.bot.Request(tgbotapi.NewCallback(callbackID, "未知的预设"))
+				return nil
+			}
+			_, prompt = localizedPreset(preset, lang)
+		}
+		
+		settings := h.getChatSettings(ctx, chatID)
+		if settings.AIParams.SystemPrompt != prompt {
+			settings.AIParams.SystemPrompt = prompt
+			if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+				h.logger.WithError(err).Error("Failed to save settings")
+				h.bot.Request(tgbotapi.NewCallback(callbackID, "保存失败"))
+				return nil
+			}
+			if err := h.deleteContext(ctx, chatID); err != nil {
+				h.logger.WithError(err).Warn("Failed to clear context after switching system prompt")
+			}
+			answer = "✅ 已切换，对话已清空"
+		}
+	}
+	
+	settings := h.getChatSettings(ctx, chatID)
+	current := activePromptPreset(h.config, settings.AIParams.SystemPrompt)
+	
+	text := "📜 **提示词库**\n\n选择一个预设作为本聊天的系统提示词，切换后会清空当前对话。"
+	if current == "" && settings.AIParams.SystemPrompt != h.config.Context.DefaultSystemPrompt {
+		text += "\n\n当前使用的是自定义提示词。"
+	}
+	keyboard := h.createPromptLibraryKeyboard(current, settings.AIParams.SystemPrompt == h.config.Context.DefaultSystemPrompt, lang)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, answer))
+	return err
+}
+
+// createPromptLibraryKeyboard lists the presets with their localized names
+func (h *CommandHandler) createPromptLibraryKeyboard(current string, isDefault bool, lang string) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	
+	for _, preset := range h.config.Context.PromptLibrary {
+		checkmark := ""
+		if preset.ID == current {
+			checkmark = "✅ "
+		}
+		name, _ := localizedPreset(preset, lang)
+		rows = append(rows, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(checkmark+name, "prompt_lib:"+preset.ID),
+		})
+	}
+	
+	defaultMark := ""
+	if isDefault {
+		defaultMark = "✅ "
+	}
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(defaultMark+"🔄 默认提示词", "prompt_lib:default"),
+	})
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:settings"),
+	})
+	
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// newPromptLibraryHandler returns a handler offering a tutor and a translator preset
+func newPromptLibraryHandler(t *testing.T) (*MessageHandler, *CommandHandler, *fakeTelegram) {
+	cfg := newTestConfig()
+	cfg.Context.DefaultSystemPrompt = "You are a helpful assistant."
+	cfg.Context.PromptLibrary = []config.PromptPresetConfig{
+		{
+			ID: "tutor", Name: "家教", Prompt: "你是一位耐心的家教。",
+			Localized: map[string]config.PromptPresetText{"en-us": {Name: "Tutor", Prompt: "You are a patient tutor."}},
+		},
+		{ID: "translator", Name: "翻译", Prompt: "你是一名翻译。"},
+	}
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer", nil
+	}}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	return h, newTestCommandHandler(h), telegram
+}
+
+// systemPrompt returns the chat's saved system prompt
+func systemPrompt(t *testing.T, h *MessageHandler, chatID int64) string {
+	t.Helper()
+	settings, err := h.storage.GetSettings(context.Background(), chatID)
+	if err != nil || settings == nil {
+		t.Fatalf("GetSettings = %v, %v", settings, err)
+	}
+	return settings.AIParams.SystemPrompt
+}
+
+func TestPromptLibrarySelect(t *testing.T) {
+	ctx := context.Background()
+	h, c, telegram := newPromptLibraryHandler(t)
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+	pressButton(t, c, 42, 7, "prompt_lib:tutor")
+	
+	if got := systemPrompt(t, h, 42); got != "你是一位耐心的家教。" {
+		t.Errorf("system prompt %q, want the tutor preset", got)
+	}
+	if chatCtx, _ := h.storage.GetContext(ctx, 42); chatCtx != nil {
+		t.Errorf("context kept %d messages after switching preset, want it cleared", len(chatCtx.Messages))
+	}
+	if answer, _ := lastCallbackAnswer(t, telegram); answer != "✅ 已切换，对话已清空" {
+		t.Errorf("callback answered %q", answer)
+	}
+	if text := lastReply(t, telegram, 42); !strings.Contains(text, "提示词库") {
+		t.Errorf("menu shows %q", text)
+	}
+	
+	// The menu marks the active preset
+	edits := telegram.requests("editMessageText")
+	if markup := edits[len(edits)-1].Get("reply_markup"); !strings.Contains(markup, "✅ 家教") {
+		t.Errorf("keyboard %s doesn't mark the tutor preset", markup)
+	}
+	
+	// Back to the default prompt
+	pressButton(t, c, 42, 7, "prompt_lib:default")
+	if got := systemPrompt(t, h, 42); got != "You are a helpful assistant." {
+		t.Errorf("system prompt %q, want the default", got)
+	}
+}
+
+func TestPromptLibraryLocalized(t *testing.T) {
+	h, c, _ := newPromptLibraryHandler(t)
+	if err := h.storage.SaveUserSettings(context.Background(), 7, &models.UserSettings{UserID: 7, Language: "en-US"}); err != nil {
+		t.Fatalf("SaveUserSettings: %v", err)
+	}
+	
+	pressButton(t, c, 42, 7, "prompt_lib:tutor")
+	if got := systemPrompt(t, h, 42); got != "You are a patient tutor." {
+		t.Errorf("system prompt %q, want the English tutor preset", got)
+	}
+	
+	// Presets without a translation keep their prompt
+	pressButton(t, c, 42, 7, "prompt_lib:translator")
+	if got := systemPrompt(t, h, 42); got != "你是一名翻译。" {
+		t.Errorf("system prompt %q, want the translator preset", got)
+	}
+}
+
+func TestPromptLibraryUnknownPreset(t *testing.T) {
+	ctx := context.Background()
+	h, c, telegram := newPromptLibraryHandler(t)
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+	pressButton(t, c, 42, 7, "prompt_lib:therapist")
+	
+	if answer, _ := lastCallbackAnswer(t, telegram); answer != "未知的预设" {
+		t.Errorf("callback answered %q, want the preset refused", answer)
+	}
+	if got := systemPrompt(t, h, 42); got != "You are a helpful assistant." {
+		t.Errorf("system prompt %q, want it unchanged", got)
+	}
+	if chatCtx, _ := h.storage.GetContext(ctx, 42); chatCtx == nil {
+		t.Error("context cleared by an unknown preset")
+	}
+}
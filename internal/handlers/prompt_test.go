@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// reminders returns the positions of the reminder system messages in a request
+func reminders(messages []models.Message) []int {
+	var positions []int
+	for i, msg := range messages {
+		if msg.Role == "system" && strings.HasPrefix(msg.Content, "提醒：") {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+func TestPromptReminderEveryNTurns(t *testing.T) {
+	const prompt = "You are a pirate. Always answer like one."
+	cfg := newTestConfig()
+	cfg.Context.DefaultSystemPrompt = prompt
+	cfg.Context.SystemReminderInterval = 2
+	service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) { return "Arr", nil }}
+	h, _ := newTestMessageHandler(t, cfg, service)
+	
+	for i := 1; i <= 4; i++ {
+		handleAndWait(t, h, privateMessage(42, 7, i, "question"))
+	}
+	
+	if len(service.requests) != 4 {
+		t.Fatalf("got %d requests, want 4", len(service.requests))
+	}
+	for i, request := range service.requests {
+		turn := i + 1
+		positions := reminders(request)
+		if turn%2 != 0 {
+			if len(positions) != 0 {
+				t.Errorf("turn %d has reminders at %v, want none", turn, positions)
+			}
+			continue
+		}
+		// One reminder, right before the latest user turn, however many came before
+		last := len(request) - 1
+		if len(positions) != 1 || positions[0] != last-1 || request[last].Role != "user" {
+			t.Errorf("turn %d has reminders at %v of %d messages, want one before the last", turn, positions, len(request))
+			continue
+		}
+		if !strings.Contains(request[positions[0]].Content, prompt) {
+			t.Errorf("turn %d reminder %q, want the system prompt", turn, request[positions[0]].Content)
+		}
+	}
+	
+	// The stored conversation never holds a reminder
+	chatCtx, err := h.storage.GetContext(context.Background(), 42)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("GetContext = %v, %v", chatCtx, err)
+	}
+	if positions := reminders(chatCtx.Messages); len(positions) != 0 {
+		t.Errorf("stored context has reminders at %v", positions)
+	}
+}
+
+func TestPromptReminderCondensed(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.SystemReminderInterval = 1
+	h, _ := newTestMessageHandler(t, cfg, &fakeAI{})
+	
+	tests := []struct {
+		name     string
+		messages []models.Message
+		want     string // reminder content after the prefix, empty for none
+	}{
+		{
+			name:     "short prompt",
+			messages: []models.Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "hi"}},
+			want:     "Be brief.",
+		},
+		{
+			name:     "long prompt",
+			messages: []models.Message{{Role: "system", Content: strings.Repeat("长", 250)}, {Role: "user", Content: "hi"}},
+			want:     strings.Repeat("长", reminderMaxRunes) + "...",
+		},
+		{
+			name:     "empty prompt",
+			messages: []models.Message{{Role: "system"}, {Role: "user", Content: "hi"}},
+		},
+		{
+			name:     "no system prompt",
+			messages: []models.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}, {Role: "user", Content: "bye"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.injectPromptReminder(tt.messages)
+			positions := reminders(got)
+			if tt.want == "" {
+				if len(got) != len(tt.messages) || len(positions) != 0 {
+					t.Errorf("got %v, want the messages unchanged", got)
+				}
+				return
+			}
+			if len(positions) != 1 {
+				t.Fatalf("got reminders at %v, want one", positions)
+			}
+			if content := got[positions[0]].Content; content != "提醒：请继续遵循最初的设定回答。\n"+tt.want {
+				t.Errorf("reminder %q, want it to end with %q", content, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdjacentUserTurnsMerged(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.DefaultSystemPrompt = "You are a helpful assistant."
+	service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) { return "answer", nil }}
+	h, _ := newTestMessageHandler(t, cfg, service)
+	
+	// An earlier question went unanswered, e.g. because the user was rate limited
+	chatCtx := &models.ChatContext{ChatID: 42, LastActivity: time.Now(), Messages: []models.Message{
+		{Role: "system", Content: cfg.Context.DefaultSystemPrompt},
+		{Role: "user", Content: "first question"},
+	}}
+	if err := h.storage.SaveContext(context.Background(), chatCtx); err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+	handleAndWait(t, h, privateMessage(42, 7, 1, "second question"))
+	
+	if service.requestCount() != 1 {
+		t.Fatalf("AI got %d requests, want 1", service.requestCount())
+	}
+	request := service.requests[0]
+	if len(request) != 2 || request[0].Role != "system" || request[1].Role != "user" {
+		t.Fatalf("request %+v, want the system prompt and one user turn", request)
+	}
+	if want := "first question\nsecond question"; request[1].Content != want {
+		t.Errorf("user turn %q, want %q", request[1].Content, want)
+	}
+}
+
+func TestNormalizeTurns(t *testing.T) {
+	system := models.Message{Role: "system", Content: "Be brief."}
+	tests := []struct {
+		name     string
+		messages []models.Message
+		want     []models.Message
+	}{
+		{
+			name:     "alternating",
+			messages: []models.Message{system, {Role: "user", Content: "a"}, {Role: "assistant", Content: "b"}, {Role: "user", Content: "c"}},
+			want:     []models.Message{system, {Role: "user", Content: "a"}, {Role: "assistant", Content: "b"}, {Role: "user", Content: "c"}},
+		},
+		{
+			name:     "adjacent user turns",
+			messages: []models.Message{system, {Role: "user", Content: "a"}, {Role: "user", Content: "b"}, {Role: "user", Content: "c"}},
+			want:     []models.Message{system, {Role: "user", Content: "a\nb\nc"}},
+		},
+		{
+			name:     "adjacent assistant turns",
+			messages: []models.Message{{Role: "user", Content: "a"}, {Role: "assistant", Content: "b"}, {Role: "assistant", Content: "c"}, {Role: "user", Content: "d"}},
+			want:     []models.Message{{Role: "user", Content: "a"}, {Role: "assistant", Content: "b\nc"}, {Role: "user", Content: "d"}},
+		},
+		{
+			name:     "assistant before the first user turn",
+			messages: []models.Message{system, {Role: "assistant", Content: "hello"}, {Role: "user", Content: "a"}},
+			want:     []models.Message{system, {Role: "user", Content: "a"}},
+		},
+		{
+			name:     "no system prompt",
+			messages: []models.Message{{Role: "assistant", Content: "hello"}, {Role: "user", Content: "a"}},
+			want:     []models.Message{{Role: "user", Content: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeTurns(tt.messages); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeTurns = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	
+	// The caller's messages are left alone
+	messages := []models.Message{{Role: "user", Content: "a"}, {Role: "user", Content: "b"}}
+	normalizeTurns(messages)
+	if messages[0].Content != "a" {
+		t.Errorf("normalizeTurns modified its input to %+v", messages)
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// cacheScope fingerprints the chat settings that shape the prompt or the
+// generation of an answer, so cached answers are only shared between chats
+// that would have asked the model the same way. Settings applied when the
+// answer is rendered (thinking, length limit, footer) are left out, as every
+// hit is rendered again for its chat.
+func cacheScope(settings *models.ChatSettings, mentioned bool) string {
+	scope, _ := json.Marshal(struct {
+		AIParams          models.AIParams
+		Personality       string
+		ResponseLanguage  string
+		ResponseStyle     string
+		Profile           string
+		KnowledgeFullDocs int
+		Mentioned         bool
+	}{
+		AIParams:          settings.AIParams,
+		Personality:       settings.Personality,
+		ResponseLanguage:  settings.ResponseLanguage,
+		ResponseStyle:     settings.ResponseStyle,
+		Profile:           settings.Profile,
+		KnowledgeFullDocs: settings.KnowledgeFullDocs,
+		Mentioned:         mentioned,
+	})
+	return string(scope)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// newCachingHandler returns a handler with the response cache enabled whose
+// AI service always answers answer
+func newCachingHandler(t *testing.T, answer string) (*MessageHandler, *fakeTelegram, *fakeAI) {
+	cfg := newTestConfig()
+	cfg.Cache = config.CacheConfig{Enabled: true, TTL: time.Hour, MaxSize: 100}
+	service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) { return answer, nil }}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	return h, telegram, service
+}
+
+// saveChatSettings stores the default settings of chatID changed by change
+func saveChatSettings(t *testing.T, h *MessageHandler, chatID int64, change func(*models.ChatSettings)) {
+	t.Helper()
+	settings := h.getDefaultSettings()
+	change(settings)
+	if err := h.storage.SaveSettings(context.Background(), chatID, settings); err != nil {
+		t.Fatalf("SaveSettings: %v", err)
+	}
+}
+
+// lastReply returns the last text the bot put into chatID
+func lastReply(t *testing.T, telegram *fakeTelegram, chatID int64) string {
+	t.Helper()
+	edits := telegram.texts("editMessageText", chatID)
+	if len(edits) == 0 {
+		t.Fatalf("chat %d got no reply", chatID)
+	}
+	return edits[len(edits)-1]
+}
+
+func TestCachedResponseRenderedPerChat(t *testing.T) {
+	const answer = "<think>secret reasoning</think>The answer is 42. More words follow here."
+	const question = "What is the answer to everything?"
+	h, telegram, service := newCachingHandler(t, answer)
+	saveChatSettings(t, h, 43, func(s *models.ChatSettings) { s.ShowThink = true })
+	saveChatSettings(t, h, 44, func(s *models.ChatSettings) { s.MaxResponseChars = 20 })
+	
+	tests := []struct {
+		name    string
+		chatID  int64
+		want    []string
+		notWant []string
+	}{
+		{name: "thinking hidden", chatID: 42, want: []string{"The answer is 42."}, notWant: []string{"secret reasoning"}},
+		{name: "thinking shown", chatID: 43, want: []string{"secret reasoning", "The answer is 42."}},
+		{name: "truncated", chatID: 44, want: []string{"The answer is 42."}, notWant: []string{"secret reasoning", "More words"}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handleAndWait(t, h, privateMessage(tt.chatID, tt.chatID, i+1, question))
+			
+			reply := lastReply(t, telegram, tt.chatID)
+			for _, want := range tt.want {
+				if !strings.Contains(reply, want) {
+					t.Errorf("reply %q is missing %q", reply, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(reply, notWant) {
+					t.Errorf("reply %q contains %q", reply, notWant)
+				}
+			}
+		})
+	}
+	
+	if n := service.requestCount(); n != 1 {
+		t.Errorf("AI service got %d requests, want 1 with the others served from the cache", n)
+	}
+	// The model's own words are cached, not one chat's rendering of them
+	chatCtx, err := h.storage.GetContext(context.Background(), 42)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("no saved context: %v", err)
+	}
+	if cached, _ := h.cache.Get(context.Background(), question, testModel, cacheScope(&chatCtx.Settings, false)); cached != answer {
+		t.Errorf("cached %q, want the raw answer", cached)
+	}
+}
+
+func TestCachedResponseScopedBySettings(t *testing.T) {
+	const question = "What is the answer to everything?"
+	h, _, service := newCachingHandler(t, "The answer is 42.")
+	temperature := 1.2
+	saveChatSettings(t, h, 51, func(s *models.ChatSettings) { s.ResponseLanguage = "en" })
+	saveChatSettings(t, h, 52, func(s *models.ChatSettings) { s.ResponseLanguage = "en" })
+	saveChatSettings(t, h, 53, func(s *models.ChatSettings) { s.Personality = "pirate" })
+	saveChatSettings(t, h, 54, func(s *models.ChatSettings) { s.AIParams.Temperature = &temperature })
+	saveChatSettings(t, h, 55, func(s *models.ChatSettings) { s.AIParams.SystemPrompt = "Answer in rhymes." })
+	
+	tests := []struct {
+		name      string
+		chatID    int64
+		wantAsked bool
+	}{
+		{name: "first asker", chatID: 50, wantAsked: true},
+		{name: "same settings", chatID: 49, wantAsked: false},
+		{name: "response language", chatID: 51, wantAsked: true},
+		{name: "same response language", chatID: 52, wantAsked: false},
+		{name: "personality", chatID: 53, wantAsked: true},
+		{name: "temperature", chatID: 54, wantAsked: true},
+		{name: "system prompt", chatID: 55, wantAsked: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := service.requestCount()
+			handleAndWait(t, h, privateMessage(tt.chatID, tt.chatID, i+1, question))
+			
+			if asked := service.requestCount() > before; asked != tt.wantAsked {
+				t.Errorf("AI service asked = %v, want %v", asked, tt.wantAsked)
+			}
+		})
+	}
+}
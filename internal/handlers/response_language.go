@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// responseLanguageOrder lists the selectable answer languages in display order
+var responseLanguageOrder = []string{"Chinese", "English", "Japanese", "Korean", "French", "German", "Spanish"}
+
+// responseLanguageNames maps answer languages to their display names
+var responseLanguageNames = map[string]string{
+	"Chinese":  "🇨🇳 中文",
+	"English":  "🇬🇧 English",
+	"Japanese": "🇯🇵 日本語",
+	"Korean":   "🇰🇷 한국어",
+	"French":   "🇫🇷 Français",
+	"German":   "🇩🇪 Deutsch",
+	"Spanish":  "🇪🇸 Español",
+}
+
+// injectResponseLanguage appends an answer language instruction to the system prompt.
+// This is independent of the UI language used for bot messages.
+func injectResponseLanguage(messages []models.Message, language string) []models.Message {
+	if language == "" || len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	
+	messages[0].Content += fmt.Sprintf("\n\nAlways respond in %s, regardless of the language used above.", language)
+	return messages
+}
+
+// handleResponseLanguageCallback handles answer language selection callbacks
+func (h *CommandHandler) handleResponseLanguageCallback(ctx context.Context, chatID int64, messageID int, action string, callbackID string) error {
+	if action != "menu" {
+		// "auto" clears the override
+		language := action
+		if language == "auto" {
+			language = ""
+		} else if _, ok := responseLanguageNames[language]; !ok {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "未知的语言"))
+			return nil
+		}
+		
+		settings := h.getChatSettings(ctx, chatID)
+		settings.ResponseLanguage = language
+		if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+			h.logger.WithError(err).Error("Failed to save settings")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "保存失败"))
+			return nil
+		}
+	}
+	
+	settings := h.getChatSettings(ctx, chatID)
+	current := "自动（跟随系统提示词）"
+	if name, ok := responseLanguageNames[settings.ResponseLanguage]; ok {
+		current = name
+	}
+	
+	text := fmt.Sprintf("🗣 **回答语言**\n\n当前：%s\n\n此设置只影响 AI 回答使用的语言，不影响界面语言。", current)
+	keyboard := h.createResponseLanguageKeyboard(settings.ResponseLanguage)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	return err
+}
+
+// createResponseLanguageKeyboard creates the answer language selection keyboard
+func (h *CommandHandler) createResponseLanguageKeyboard(current string) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	
+	autoMark := ""
+	if current == "" {
+		autoMark = "✅ "
+	}
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(autoMark+"🔄 自动", "resp_lang:auto"),
+	})
+	
+	// Two languages per row
+	var row []tgbotapi.InlineKeyboardButton
+	for _, language := range responseLanguageOrder {
+		checkmark := ""
+		if language == current {
+			checkmark = "✅ "
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(checkmark+responseLanguageNames[language], "resp_lang:"+language))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	
+	rows = append(rows, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:settings"),
+	})
+	
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// filePreviewChars is how much of a file response is previewed in the chat
+const filePreviewChars = 200
+
+// responseTruncatedMarker is appended to responses cut by MaxResponseChars
+const responseTruncatedMarker = "…（回复过长已截断）"
+
+// sentenceEnders are the runes a truncated response prefers to end on
+const sentenceEnders = "。！？；.!?;\n"
+
+// truncateResponse shortens response to at most maxChars runes, preferring to
+// cut at the end of a sentence, then at a word boundary. maxChars <= 0 means unlimited.
+func truncateResponse(response string, maxChars int) string {
+	runes := []rune(response)
+	if maxChars <= 0 || len(runes) <= maxChars {
+		return response
+	}
+	
+	cut := runes[:maxChars]
+	
+	// Only back off to a boundary if it keeps most of the allowed text
+	minCut := maxChars / 2
+	end := -1
+	for i := len(cut) - 1; i >= minCut; i-- {
+		if strings.ContainsRune(sentenceEnders, cut[i]) {
+			end = i + 1
+			break
+		}
+	}
+	if end == -1 {
+		for i := len(cut) - 1; i >= minCut; i-- {
+			if unicode.IsSpace(cut[i]) {
+				end = i
+				break
+			}
+		}
+	}
+	if end != -1 {
+		cut = cut[:end]
+	}
+	
+	return strings.TrimRightFunc(string(cut), unicode.IsSpace) + responseTruncatedMarker
+}
+
+// handleMaxLength handles /maxlength command, setting the chat's response length limit
+func (h *CommandHandler) handleMaxLength(ctx context.Context, chatID int64, args string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	arg := strings.ToLower(strings.TrimSpace(args))
+	
+	switch arg {
+	case "":
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, describeMaxLength(settings.MaxResponseChars)+
+			"\n\n用法：/maxlength <字符数> | off"))
+		return err
+	case "off":
+		settings.MaxResponseChars = 0
+	default:
+		chars, err := strconv.Atoi(arg)
+		if err != nil || chars <= 0 {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请输入正整数字符数，或 off"))
+			return err
+		}
+		settings.MaxResponseChars = chars
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ "+describeMaxLength(settings.MaxResponseChars)))
+	return err
+}
+
+// describeMaxLength describes a chat's response length limit
+func describeMaxLength(maxChars int) string {
+	if maxChars <= 0 {
+		return "回复长度限制：不限制"
+	}
+	return fmt.Sprintf("回复长度限制：%d 字符", maxChars)
+}
+
+// fileResponseThreshold returns the length above which responses are sent as a
+// file for this chat; 0 means never
+func fileResponseThreshold(cfg *config.Config, settings *models.ChatSettings) int {
+	threshold := cfg.Context.FileResponseChars
+	if settings.FileResponseChars != 0 {
+		threshold = settings.FileResponseChars
+	}
+	if threshold < 0 {
+		return 0
+	}
+	return threshold
+}
+
+// shouldSendAsFile reports whether response is long enough to be sent as a file
+func shouldSendAsFile(response string, threshold int) bool {
+	return threshold > 0 && utf8.RuneCountInString(response) > threshold
+}
+
+// newResponseDocument builds the document upload for a long response
+func newResponseDocument(chatID int64, response string) tgbotapi.DocumentConfig {
+	file := tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("response-%s.md", time.Now().Format("20060102-150405")),
+		Bytes: []byte(response),
+	}
+	return tgbotapi.NewDocument(chatID, file)
+}
+
+// sendResponseAsFile uploads the response as a markdown document and replaces
+// the thinking message with a short preview
+func (h *MessageHandler) sendResponseAsFile(chatID int64, messageID int, response, lang string) {
+	if _, err := h.bot.Send(newResponseDocument(chatID, response)); err != nil {
+		h.logger.WithError(err).Warn("Failed to send response as file, sending as message")
+		h.sendResponse(chatID, messageID, response, lang)
+		return
+	}
+	
+	preview := []rune(response)
+	if len(preview) > filePreviewChars {
+		preview = append(preview[:filePreviewChars], []rune("…")...)
+	}
+	
+	text := h.localizer.Get(lang, i18n.MsgResponseAsFile, map[string]interface{}{
+		"Chars":   utf8.RuneCountInString(response),
+		"Preview": string(preview),
+	})
+	if _, err := h.bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, text)); err != nil {
+		h.logger.WithError(err).Error("Failed to send file response summary")
+	}
+}
+
+// handleAsFile handles /asfile command, setting the chat's file response threshold.
+// Accepts a number of characters, "off" to disable or "default" to follow the config.
+func (h *CommandHandler) handleAsFile(ctx context.Context, chatID int64, args string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	arg := strings.ToLower(strings.TrimSpace(args))
+	
+	switch arg {
+	case "":
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.describeAsFile(settings)+
+			"\n\n用法：/asfile <字符数> | off | default"))
+		return err
+	case "off":
+		settings.FileResponseChars = -1
+	case "default":
+		settings.FileResponseChars = 0
+	default:
+		chars, err := strconv.Atoi(arg)
+		if err != nil || chars <= 0 {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 请输入正整数字符数，或 off / default"))
+			return err
+		}
+		settings.FileResponseChars = chars
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ "+h.describeAsFile(settings)))
+	return err
+}
+
+// describeAsFile describes the effective file response threshold of a chat
+func (h *CommandHandler) describeAsFile(settings *models.ChatSettings) string {
+	source := "本聊天设置"
+	if settings.FileResponseChars == 0 {
+		source = "全局默认"
+	}
+	threshold := fileResponseThreshold(h.config, settings)
+	if threshold == 0 {
+		return fmt.Sprintf("长回复以文件发送：已关闭（%s）", source)
+	}
+	return fmt.Sprintf("长回复以文件发送：超过 %d 字符（%s）", threshold, source)
+}
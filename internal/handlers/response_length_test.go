@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestTruncateResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		maxChars int
+		want     string
+	}{
+		{name: "unlimited", response: strings.Repeat("很长的回答。", 100), maxChars: 0, want: strings.Repeat("很长的回答。", 100)},
+		{name: "within the limit", response: "短回答。", maxChars: 4, want: "短回答。"},
+		{name: "sentence boundary", response: "第一句话。第二句话！第三句话", maxChars: 12, want: "第一句话。第二句话！" + responseTruncatedMarker},
+		{name: "english sentence", response: "One sentence. Another one follows here.", maxChars: 24, want: "One sentence." + responseTruncatedMarker},
+		{name: "word boundary", response: "one two three four five six", maxChars: 16, want: "one two three" + responseTruncatedMarker},
+		{name: "no boundary", response: strings.Repeat("字", 20), maxChars: 8, want: strings.Repeat("字", 8) + responseTruncatedMarker},
+		// Cutting at the only sentence end would drop most of the allowed text
+		{name: "boundary too early", response: "短。" + strings.Repeat("字", 20), maxChars: 10, want: "短。" + strings.Repeat("字", 8) + responseTruncatedMarker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateResponse(tt.response, tt.maxChars); got != tt.want {
+				t.Errorf("truncateResponse = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxLengthApplied(t *testing.T) {
+	answer := "<think>" + strings.Repeat("想", 50) + "</think>第一句话。第二句话。第三句话。"
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{name: "limited", command: "/maxlength 12", want: "第一句话。第二句话。" + responseTruncatedMarker},
+		{name: "unlimited", command: "/maxlength off", want: "第一句话。第二句话。第三句话。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+				return answer, nil
+			}}
+			h, telegram := newTestMessageHandler(t, newTestConfig(), service)
+			c := newTestCommandHandler(h)
+			
+			runCommand(t, c, 42, 7, tt.command)
+			handleAndWait(t, h, privateMessage(42, 7, 1, "question"))
+			
+			// The hidden reasoning doesn't count toward the limit
+			if text := lastText(telegram.texts("editMessageText", 42)); text != tt.want {
+				t.Errorf("answer %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxLengthCommand(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	steps := []struct {
+		command  string
+		wantText string
+		want     int
+	}{
+		{command: "/maxlength", wantText: "回复长度限制：不限制"},
+		{command: "/maxlength 500", wantText: "回复长度限制：500 字符", want: 500},
+		{command: "/maxlength -3", wantText: "请输入正整数字符数", want: 500},
+		{command: "/maxlength OFF", wantText: "回复长度限制：不限制"},
+	}
+	for _, step := range steps {
+		runCommand(t, c, 42, 7, step.command)
+		if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, step.wantText) {
+			t.Errorf("%s answered %q, want %q", step.command, text, step.wantText)
+		}
+		if got := c.getChatSettings(ctx, 42).MaxResponseChars; got != step.want {
+			t.Errorf("after %s the limit is %d, want %d", step.command, got, step.want)
+		}
+	}
+}
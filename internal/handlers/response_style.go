@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Response styles; the empty string is the normal style
+const (
+	responseStyleConcise  = "concise"
+	responseStyleNormal   = "normal"
+	responseStyleDetailed = "detailed"
+)
+
+// responseStyleOrder lists the selectable response styles in display order
+var responseStyleOrder = []string{responseStyleConcise, responseStyleNormal, responseStyleDetailed}
+
+// responseStyleNames maps response styles to their display names
+var responseStyleNames = map[string]string{
+	responseStyleConcise:  "✂️ 简洁",
+	responseStyleNormal:   "⚖️ 适中",
+	responseStyleDetailed: "📖 详细",
+}
+
+// responseStyleInstructions are appended to the system prompt for each style;
+// the normal style adds nothing
+var responseStyleInstructions = map[string]string{
+	responseStyleConcise:  "回答尽量简洁，直接给出要点，避免不必要的铺垫和重复。",
+	responseStyleDetailed: "提供详细解释，说明原因和步骤，必要时给出示例。",
+}
+
+// injectResponseStyle appends the instruction of the chosen response style to
+// the system prompt, leaving the prompt itself unchanged in settings
+func injectResponseStyle(messages []models.Message, style string) []models.Message {
+	instruction, ok := responseStyleInstructions[style]
+	if !ok || len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	
+	messages[0].Content += "\n\n" + instruction
+	return messages
+}
+
+// handleResponseStyleCallback handles response style selection callbacks
+func (h *CommandHandler) handleResponseStyleCallback(ctx context.Context, chatID int64, messageID int, action string, callbackID string) error {
+	if action != "menu" {
+		if _, ok := responseStyleNames[action]; !ok {
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "未知的回答风格"))
+			return nil
+		}
+		
+		// The normal style is stored as no preference
+		style := action
+		if style == responseStyleNormal {
+			style = ""
+		}
+		
+		settings := h.getChatSettings(ctx, chatID)
+		settings.ResponseStyle = style
+		if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+			h.logger.WithError(err).Error("Failed to save settings")
+			h.bot.Request(tgbotapi.NewCallback(callbackID, "保存失败"))
+			return nil
+		}
+	}
+	
+	settings := h.getChatSettings(ctx, chatID)
+	current := settings.ResponseStyle
+	if current == "" {
+		current = responseStyleNormal
+	}
+	
+	text := fmt.Sprintf("📝 **回答风格**\n\n当前：%s\n\n简洁或详细会在系统提示词后附加相应要求，不修改提示词本身。", responseStyleNames[current])
+	keyboard := h.createResponseStyleKeyboard(current)
+	
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = &keyboard
+	
+	_, err := h.bot.Send(edit)
+	h.bot.Request(tgbotapi.NewCallback(callbackID, ""))
+	return err
+}
+
+// createResponseStyleKeyboard creates the response style selection keyboard
+func (h *CommandHandler) createResponseStyleKeyboard(current string) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, style := range responseStyleOrder {
+		checkmark := ""
+		if style == current {
+			checkmark = "✅ "
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(checkmark+responseStyleNames[style], "resp_style:"+style))
+	}
+	
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		[]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "menu:settings")},
+	)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestInjectResponseStyle(t *testing.T) {
+	const prompt = "You are a helpful assistant."
+	
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{style: "", want: prompt},
+		{style: responseStyleNormal, want: prompt},
+		{style: responseStyleConcise, want: prompt + "\n\n回答尽量简洁，直接给出要点，避免不必要的铺垫和重复。"},
+		{style: responseStyleDetailed, want: prompt + "\n\n提供详细解释，说明原因和步骤，必要时给出示例。"},
+		{style: "verbose", want: prompt},
+	}
+	for _, tt := range tests {
+		messages := []models.Message{{Role: "system", Content: prompt}, {Role: "user", Content: "hi"}}
+		got := injectResponseStyle(messages, tt.style)
+		if got[0].Content != tt.want {
+			t.Errorf("style %q gave system prompt %q, want %q", tt.style, got[0].Content, tt.want)
+		}
+	}
+	
+	// Without a system prompt there is nothing to append to
+	user := models.Message{Role: "user", Content: "hi"}
+	if got := injectResponseStyle([]models.Message{user}, responseStyleConcise); len(got) != 1 || got[0] != user {
+		t.Errorf("messages without a system prompt became %+v", got)
+	}
+}
+
+func TestResponseStyleSetting(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.DefaultSystemPrompt = "You are a helpful assistant."
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "answer", nil
+	}}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	c := newTestCommandHandler(h)
+	
+	steps := []struct {
+		button    string
+		wantShown string
+		want      string
+	}{
+		{button: "resp_style:menu", wantShown: "当前：⚖️ 适中"},
+		{button: "resp_style:concise", wantShown: "当前：✂️ 简洁", want: "回答尽量简洁"},
+		{button: "resp_style:detailed", wantShown: "当前：📖 详细", want: "提供详细解释"},
+		{button: "resp_style:normal", wantShown: "当前：⚖️ 适中"},
+	}
+	for i, step := range steps {
+		pressButton(t, c, 42, 42, step.button)
+		if text := lastText(telegram.texts("editMessageText", 42)); !strings.Contains(text, step.wantShown) {
+			t.Errorf("%s shows %q, want %q", step.button, text, step.wantShown)
+		}
+		
+		handleAndWait(t, h, privateMessage(42, 42, i+1, "hello"))
+		request := service.requests[len(service.requests)-1]
+		system := request[0].Content
+		if !strings.HasPrefix(system, cfg.Context.DefaultSystemPrompt) {
+			t.Errorf("after %s the system prompt is %q, want the base prompt kept", step.button, system)
+		}
+		for _, instruction := range responseStyleInstructions {
+			if got := strings.Contains(system, instruction); got != (step.want != "" && strings.HasPrefix(instruction, step.want)) {
+				t.Errorf("after %s instruction %q injected = %v", step.button, instruction, got)
+			}
+		}
+	}
+	
+	// The style lives in the request, not in the stored settings' prompt
+	settings, _ := h.storage.GetSettings(context.Background(), 42)
+	if settings == nil || settings.ResponseStyle != "" || strings.Contains(settings.AIParams.SystemPrompt, "回答") {
+		t.Errorf("stored settings %+v, want the normal style stored as empty and the prompt untouched", settings)
+	}
+	
+	pressButton(t, c, 42, 42, "resp_style:verbose")
+	answers := telegram.requests("answerCallbackQuery")
+	if text := answers[len(answers)-1].Get("text"); text != "未知的回答风格" {
+		t.Errorf("unknown style answered %q", text)
+	}
+}
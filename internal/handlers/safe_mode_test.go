@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const configLockedText = "🔒 配置已锁定（安全模式），无法通过机器人添加、修改或删除端点和模型"
+
+// hasButton reports whether the model keyboard offers a button with data
+func hasButton(c *CommandHandler, data string) bool {
+	for _, row := range c.createModelSelectionKeyboard(7, "", nil).InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil && *button.CallbackData == data {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestSafeModeHidesConfigButton(t *testing.T) {
+	h, _ := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	if !hasButton(c, "config:add_endpoint") {
+		t.Fatal("model menu lacks the custom model button without safe mode")
+	}
+	h.config.Models.SafeMode = true
+	if hasButton(c, "config:add_endpoint") {
+		t.Error("model menu offers the custom model button in safe mode")
+	}
+}
+
+func TestSafeModeRefusesConfigCallbacks(t *testing.T) {
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	h.config.Bot.AdminIDs = []int64{7}
+	configHandler := newTestConfigHandler(h)
+	c := newTestCommandHandler(h)
+	h.config.Models.SafeMode = true
+	
+	for _, data := range []string{
+		"config:add_endpoint",
+		"config:edit_endpoint:test",
+		"config:edit_key:test",
+		"config:add_model:test",
+		"config:delete_endpoint:test",
+		"config:force_delete:test",
+	} {
+		before := len(telegram.requests("editMessageText"))
+		if err := configHandler.HandleConfigCallback(context.Background(), configCallback(42, 7, data)); err != nil {
+			t.Fatalf("HandleConfigCallback(%s): %v", data, err)
+		}
+		if text, alert := lastCallbackAnswer(t, telegram); text != configLockedText || !alert {
+			t.Errorf("%s answered %q (alert %v), want the locked notice", data, text, alert)
+		}
+		if after := len(telegram.requests("editMessageText")); after != before {
+			t.Errorf("%s opened a menu in safe mode", data)
+		}
+	}
+	if endpoint := currentTestEndpoint(t, configHandler); endpoint.APIKey != "sk-old" {
+		t.Errorf("endpoint changed to %+v", endpoint)
+	}
+	
+	pressButton(t, c, 42, 7, "custom_model:config")
+	if text, alert := lastCallbackAnswer(t, telegram); text != configLockedText || !alert {
+		t.Errorf("custom model menu answered %q (alert %v), want the locked notice", text, alert)
+	}
+}
+
+func TestSafeModeEndsConfigFlow(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	configHandler := newTestConfigHandler(h)
+	
+	// A flow started before safe mode was turned on
+	pressButtonConfig(t, configHandler, 42, 7, "config:add_endpoint")
+	if action, _ := editState(t, configHandler, 7); action != "adding_endpoint" {
+		t.Fatalf("config action %q, want adding_endpoint", action)
+	}
+	h.config.Models.SafeMode = true
+	
+	configInput(t, configHandler, 42, 7, "名称: test2\n地址: https://new.example.com/v1\n密钥: sk-new")
+	if text := lastText(telegram.texts("sendMessage", 42)); text != configLockedText {
+		t.Errorf("input answered %q, want the locked notice", text)
+	}
+	if action, _ := editState(t, configHandler, 7); action != "" {
+		t.Errorf("config action %q left after the refusal", action)
+	}
+	current, _ := configHandler.configService.GetCurrentConfig(ctx)
+	for _, endpoint := range current.Models.Endpoints {
+		if strings.Contains(endpoint.BaseURL, "new.example.com") {
+			t.Errorf("endpoint %s added in safe mode", endpoint.Name)
+		}
+	}
+}
+
+// pressButtonConfig hands a button press of userID to the config handler
+func pressButtonConfig(t *testing.T, c *ConfigHandler, chatID, userID int64, data string) {
+	t.Helper()
+	if err := c.HandleConfigCallback(context.Background(), configCallback(chatID, userID, data)); err != nil {
+		t.Fatalf("HandleConfigCallback(%s): %v", data, err)
+	}
+}
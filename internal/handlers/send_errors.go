@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// sendFailure classifies why Telegram refused to deliver a message
+type sendFailure int
+
+const (
+	// sendFailureOther is any error that says nothing about the chat itself
+	sendFailureOther sendFailure = iota
+	// sendFailureGone means the bot can no longer reach the chat at all
+	sendFailureGone
+	// sendFailureNoRights means the bot is in the chat but may not post
+	sendFailureNoRights
+)
+
+// goneErrorMessages are Telegram error fragments for chats the bot can't reach anymore
+var goneErrorMessages = []string{
+	"bot was blocked by the user",
+	"bot was kicked",
+	"bot is not a member",
+	"user is deactivated",
+	"chat not found",
+	"group chat was deleted",
+}
+
+// noRightsErrorMessages are Telegram error fragments for chats where the bot is muted
+var noRightsErrorMessages = []string{
+	"not enough rights",
+	"have no rights to send",
+	"chat_write_forbidden",
+	"chat_send_plain_forbidden",
+}
+
+// classifySendError inspects an error returned by bot.Send
+func classifySendError(err error) sendFailure {
+	if err == nil {
+		return sendFailureOther
+	}
+	
+	var message string
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		message = apiErr.Message
+	} else {
+		message = err.Error()
+	}
+	message = strings.ToLower(message)
+	
+	for _, fragment := range goneErrorMessages {
+		if strings.Contains(message, fragment) {
+			return sendFailureGone
+		}
+	}
+	for _, fragment := range noRightsErrorMessages {
+		if strings.Contains(message, fragment) {
+			return sendFailureNoRights
+		}
+	}
+	return sendFailureOther
+}
+
+// handleSendFailure reacts to a failed send. Chats the bot can no longer reach
+// are pruned like chats it left, so they stop being processed.
+// It reports whether the chat is unusable and further sends should be skipped.
+func (h *MessageHandler) handleSendFailure(ctx context.Context, chatID int64, err error) bool {
+	switch classifySendError(err) {
+	case sendFailureGone:
+		h.logger.WithError(err).WithField("chatID", chatID).Warn("Chat is no longer reachable")
+		if h.config.Bot.Membership.PruneOnLeave {
+			if err := pruneChatData(ctx, h.storage, h.logger, chatID); err == nil {
+				h.logger.WithField("chatID", chatID).Info("Pruned data of unreachable chat")
+			}
+		}
+		return true
+	case sendFailureNoRights:
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"chatID": chatID,
+		}).Warn("Bot is not allowed to send messages in chat")
+		return true
+	default:
+		return false
+	}
+}
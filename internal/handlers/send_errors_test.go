@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestClassifySendError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want sendFailure
+	}{
+		{name: "no error", want: sendFailureOther},
+		{name: "blocked", err: &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, want: sendFailureGone},
+		{name: "kicked", err: &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was kicked from the group chat"}, want: sendFailureGone},
+		{name: "chat deleted", err: errors.New("Bad Request: chat not found"), want: sendFailureGone},
+		{name: "deactivated", err: &tgbotapi.Error{Code: 403, Message: "Forbidden: user is deactivated"}, want: sendFailureGone},
+		{name: "no rights", err: &tgbotapi.Error{Code: 400, Message: "Bad Request: not enough rights to send text messages to the chat"}, want: sendFailureNoRights},
+		{name: "write forbidden", err: errors.New("Bad Request: CHAT_WRITE_FORBIDDEN"), want: sendFailureNoRights},
+		{name: "not modified", err: &tgbotapi.Error{Code: 400, Message: "Bad Request: message is not modified"}, want: sendFailureOther},
+		{name: "network", err: errors.New("connection reset by peer"), want: sendFailureOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifySendError(tt.err); got != tt.want {
+				t.Errorf("classifySendError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// storeChatData saves settings and a context for chatID
+func storeChatData(t *testing.T, h *MessageHandler, chatID int64) {
+	t.Helper()
+	ctx := context.Background()
+	if err := h.storage.SaveSettings(ctx, chatID, &models.ChatSettings{Language: "zh-CN"}); err != nil {
+		t.Fatalf("SaveSettings: %v", err)
+	}
+	chatCtx := &models.ChatContext{ChatID: chatID, Messages: []models.Message{{Role: "user", Content: "earlier"}}, LastActivity: time.Now()}
+	if err := h.storage.SaveContext(ctx, chatCtx); err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+}
+
+// chatDataKept reports whether chatID still has stored settings and context
+func chatDataKept(h *MessageHandler, chatID int64) (settingsKept, contextKept bool) {
+	ctx := context.Background()
+	settings, err := h.storage.GetSettings(ctx, chatID)
+	settingsKept = err == nil && settings != nil
+	chatCtx, err := h.storage.GetContext(ctx, chatID)
+	contextKept = err == nil && chatCtx != nil
+	return settingsKept, contextKept
+}
+
+func TestUnreachableChatPruned(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		failure   string
+		prune     bool
+		wantKept  bool
+		wantAsked bool
+	}{
+		{name: "blocked", method: "sendMessage", failure: "Forbidden: bot was blocked by the user", prune: true},
+		{name: "kicked while answering", method: "editMessageText", failure: "Forbidden: bot was kicked from the group chat", prune: true, wantAsked: true},
+		{name: "pruning disabled", method: "sendMessage", failure: "Forbidden: bot was blocked by the user", wantKept: true},
+		{name: "muted", method: "sendMessage", failure: "Bad Request: not enough rights to send text messages to the chat", prune: true, wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Bot.Membership.PruneOnLeave = tt.prune
+			service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+				return "answer", nil
+			}}
+			h, telegram := newTestMessageHandler(t, cfg, service)
+			storeChatData(t, h, 42)
+			telegram.fail(tt.method, tt.failure)
+			
+			handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+			
+			// A chat that can't be answered costs no AI request
+			if asked := service.requestCount() > 0; asked != tt.wantAsked {
+				t.Errorf("AI asked = %v, want %v", asked, tt.wantAsked)
+			}
+			settingsKept, contextKept := chatDataKept(h, 42)
+			if settingsKept != tt.wantKept || contextKept != tt.wantKept {
+				t.Errorf("settings kept = %v, context kept = %v, want %v", settingsKept, contextKept, tt.wantKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestResponseFormatFallback(t *testing.T) {
+	const answer = "**Bold** answer with `code`."
+	tests := []struct {
+		name      string
+		rejected  []string
+		wantModes []string
+		wantText  string
+	}{
+		{name: "HTML", wantModes: []string{"HTML"}, wantText: "<b>Bold</b>"},
+		{name: "MarkdownV2", rejected: []string{"HTML"}, wantModes: []string{"HTML", "MarkdownV2"}, wantText: "*Bold* answer with `code`\\."},
+		{name: "plain text", rejected: []string{"HTML", "MarkdownV2"}, wantModes: []string{"HTML", "MarkdownV2", ""}, wantText: "Bold answer with code."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) { return answer, nil }}
+			h, telegram := newTestMessageHandler(t, newTestConfig(), service)
+			telegram.rejectParseModes(tt.rejected...)
+			storeChatData(t, h, 42)
+			
+			handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+			
+			var modes []string
+			var last string
+			for _, params := range telegram.requests("editMessageText") {
+				modes = append(modes, params.Get("parse_mode"))
+				last = params.Get("text")
+			}
+			if !reflect.DeepEqual(modes, tt.wantModes) {
+				t.Fatalf("response sent in parse modes %q, want %q", modes, tt.wantModes)
+			}
+			if !strings.Contains(last, tt.wantText) {
+				t.Errorf("response sent as %q, want %q", last, tt.wantText)
+			}
+			// Unparsable text doesn't make the chat look unreachable
+			if settingsKept, contextKept := chatDataKept(h, 42); !settingsKept || !contextKept {
+				t.Errorf("chat data kept = %v, %v, want both", settingsKept, contextKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// listVersion fingerprints a list shown as buttons. Buttons that act on an
+// item by position carry it, so a tap on a menu built from an older list can
+// be told apart from a tap on the current one.
+func listVersion(items []string) string {
+	hash := fnv.New32a()
+	for _, item := range items {
+		hash.Write([]byte(item))
+		hash.Write([]byte{0})
+	}
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// indexedCallbackData returns callback data acting on item index of items
+func indexedCallbackData(prefix string, index int, items []string) string {
+	return fmt.Sprintf("%s:%d:%s", prefix, index, listVersion(items))
+}
+
+// parseIndexedCallback reads "<index>:<version>" and reports whether it still
+// refers to the same position of items
+func parseIndexedCallback(arg string, items []string) (int, bool) {
+	indexStr, version, _ := strings.Cut(arg, ":")
+	index, err := strconv.Atoi(indexStr)
+	if err != nil || index < 0 || index >= len(items) || version != listVersion(items) {
+		return 0, false
+	}
+	return index, true
+}
+
+// answerStaleMenu tells the user the tapped menu no longer matches the current
+// state and, when keyboard is given, replaces its buttons with up to date ones
+func (h *CommandHandler) answerStaleMenu(chatID int64, messageID int, lang string, callbackID string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	h.bot.Request(tgbotapi.NewCallbackWithAlert(callbackID, h.localizer.Get(lang, "error.menu_expired", nil)))
+	if keyboard == nil {
+		return nil
+	}
+	_, err := h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, *keyboard))
+	return err
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestParseIndexedCallback(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	data := indexedCallbackData("keyword:del", 1, items)
+	arg := strings.TrimPrefix(data, "keyword:del:")
+	
+	if index, ok := parseIndexedCallback(arg, items); !ok || index != 1 {
+		t.Errorf("parseIndexedCallback(%q) = %d, %v, want 1, true", arg, index, ok)
+	}
+	stale := []struct {
+		name  string
+		arg   string
+		items []string
+	}{
+		{name: "item removed before", arg: arg, items: []string{"b", "c"}},
+		{name: "item renamed", arg: arg, items: []string{"a", "x", "c"}},
+		{name: "list emptied", arg: arg},
+		{name: "without a version", arg: "1", items: items},
+		{name: "not a number", arg: "one:" + listVersion(items), items: items},
+		{name: "out of range", arg: "3:" + listVersion(items), items: items},
+	}
+	for _, tt := range stale {
+		if index, ok := parseIndexedCallback(tt.arg, tt.items); ok {
+			t.Errorf("%s: parseIndexedCallback = %d, true, want the tap treated as stale", tt.name, index)
+		}
+	}
+}
+
+// lastCallbackAnswer returns the text of the last callback answer and whether it was an alert
+func lastCallbackAnswer(t *testing.T, telegram *fakeTelegram) (string, bool) {
+	t.Helper()
+	answers := telegram.requests("answerCallbackQuery")
+	if len(answers) == 0 {
+		t.Fatal("no callback answered")
+	}
+	last := answers[len(answers)-1]
+	return last.Get("text"), last.Get("show_alert") == "true"
+}
+
+// answeredExpired reports whether a callback was answered with the expired menu notice
+func answeredExpired(telegram *fakeTelegram) bool {
+	for _, answer := range telegram.requests("answerCallbackQuery") {
+		if answer.Get("text") == "此菜单已过期，请重新打开" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStaleModelCallback(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{models: pollModels})
+	c := newTestCommandHandler(h)
+	
+	// The model was deleted after the keyboard was sent
+	pressButton(t, c, 42, 42, "model:deleted-model")
+	
+	if text, alert := lastCallbackAnswer(t, telegram); text != "此菜单已过期，请重新打开" || !alert {
+		t.Errorf("stale tap answered %q (alert %v), want the expired menu alert", text, alert)
+	}
+	if settings, _ := h.storage.GetUserSettings(ctx, 42); settings != nil && settings.Model != "" {
+		t.Errorf("stale tap selected model %q", settings.Model)
+	}
+	// The keyboard is replaced by the current models
+	refreshed := telegram.requests("editMessageReplyMarkup")
+	if len(refreshed) != 1 {
+		t.Fatalf("sent %d keyboard refreshes, want 1", len(refreshed))
+	}
+	markup := refreshed[0].Get("reply_markup")
+	if !strings.Contains(markup, "model:model-a") || strings.Contains(markup, "deleted-model") {
+		t.Errorf("refreshed keyboard %s, want the current models only", markup)
+	}
+	
+	// A current model is still selected
+	pressButton(t, c, 42, 42, "model:model-b")
+	if settings, _ := h.storage.GetUserSettings(ctx, 42); settings == nil || settings.Model != "model-b" {
+		t.Errorf("user settings %+v, want model-b selected", settings)
+	}
+}
+
+func TestStaleListCallbacks(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		field  func(s *models.ChatSettings) *[]string
+	}{
+		{name: "keyword", prefix: "keyword:del", field: func(s *models.ChatSettings) *[]string { return &s.Keywords }},
+		{name: "mention word", prefix: "mention_del", field: func(s *models.ChatSettings) *[]string { return &s.MentionWords }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+			c := newTestCommandHandler(h)
+			items := func() []string {
+				settings, _ := h.storage.GetSettings(ctx, 42)
+				return *tt.field(settings)
+			}
+			
+			// The delete menu is opened on [a b c]...
+			saveChatSettings(t, h, 42, func(s *models.ChatSettings) { *tt.field(s) = []string{"a", "b", "c"} })
+			staleB := indexedCallbackData(tt.prefix, 1, []string{"a", "b", "c"})
+			// ...and "a" is deleted from another menu before "b" is tapped
+			saveChatSettings(t, h, 42, func(s *models.ChatSettings) { *tt.field(s) = []string{"b", "c"} })
+			
+			pressButton(t, c, 42, 42, staleB)
+			if !answeredExpired(telegram) {
+				t.Error("stale tap not answered with the expired menu notice")
+			}
+			if got := items(); !reflect.DeepEqual(got, []string{"b", "c"}) {
+				t.Errorf("stale tap left %q, want [b c]", got)
+			}
+			// The message now lists the current items
+			edits := telegram.requests("editMessageText")
+			if len(edits) == 0 {
+				t.Fatal("menu not refreshed")
+			}
+			if text := edits[len(edits)-1].Get("text"); !strings.Contains(text, "b") || !strings.Contains(text, "c") || strings.Contains(text, "a") {
+				t.Errorf("refreshed menu %q, want the current items b and c", text)
+			}
+			
+			pressButton(t, c, 42, 42, indexedCallbackData(tt.prefix, 0, []string{"b", "c"}))
+			if got := items(); !reflect.DeepEqual(got, []string{"c"}) {
+				t.Errorf("current tap left %q, want [c]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultSummaryMaxTokens is used when context.summary_max_tokens is unset
+	defaultSummaryMaxTokens = 500
+	// summaryPrefix marks the system message holding the summary of earlier turns
+	summaryPrefix = "[之前对话的摘要]\n"
+	// summaryTimeout bounds the summarization request
+	summaryTimeout = time.Minute
+)
+
+// summaryInstruction is the system prompt of the summarization request
+const summaryInstruction = "请将以下对话压缩为简洁的摘要，保留其中的事实、人名、数字、已做出的决定和尚未解决的问题，以便之后继续对话。只输出摘要本身，不要添加评论。"
+
+// summaryMaxTokens returns the token bound of context summaries
+func summaryMaxTokens(cfg *config.Config) int {
+	if cfg.Context.SummaryMaxTokens > 0 {
+		return cfg.Context.SummaryMaxTokens
+	}
+	return defaultSummaryMaxTokens
+}
+
+// isSummaryMessage reports whether msg is a summary written by summarizeChat
+func isSummaryMessage(msg models.Message) bool {
+	return msg.Role == "system" && strings.HasPrefix(msg.Content, summaryPrefix)
+}
+
+// summaryCut returns the index of the first message kept when summarizing:
+// at least the oldest half of the conversation after the system prompt goes,
+// more when the rest plus a summary of summaryTokens would still exceed
+// highWater. The latest user turn and the reply to it are always kept and the
+// kept part starts at a user turn. 0 means there is nothing to summarize.
+func summaryCut(messages []models.Message, highWater int, summaryTokens int) int {
+	if len(messages) < 3 || messages[0].Role != "system" {
+		return 0
+	}
+	
+	last := len(messages) - 1
+	for last > 0 && messages[last].Role != "user" {
+		last--
+	}
+	cut := 1 + (len(messages)-1)/2
+	if cut > last {
+		cut = last
+	}
+	fixed := ai.EstimateMessagesTokens(messages[:1]) + summaryTokens
+	for cut < last && fixed+ai.EstimateMessagesTokens(messages[cut:]) > highWater {
+		cut++
+	}
+	for cut < last && messages[cut].Role != "user" {
+		cut++
+	}
+	
+	// Summarizing nothing but the previous summary gains nothing
+	if cut <= 1 || (cut == 2 && isSummaryMessage(messages[1])) {
+		return 0
+	}
+	return cut
+}
+
+// summaryTranscript renders the messages to summarize as plain text, folding
+// in an earlier summary so the new one replaces it
+func summaryTranscript(messages []models.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		if isSummaryMessage(msg) {
+			b.WriteString("之前的摘要：" + strings.TrimPrefix(msg.Content, summaryPrefix) + "\n\n")
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%s：%s\n\n", msg.Role, msg.Content))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// summarizeChat replaces the oldest half of the chat's saved conversation
+// with a summary once its estimated token count crosses
+// context.summarize_tokens, keeping long-running chats within budget before
+// the message-count trim kicks in. It runs after the reply was sent; the
+// summary is requested without holding the chat and dropped when the
+// summarized turns changed meanwhile. An earlier summary is part of what gets
+// summarized, so there is only ever one. Failures leave the context as it was.
+func (h *MessageHandler) summarizeChat(ctx context.Context, chatID, userID int64, modelID string) {
+	highWater := h.config.Context.SummarizeTokens
+	if highWater <= 0 {
+		return
+	}
+	
+	unlock := h.chatLocks.lock(chatID)
+	chatCtx, err := h.storage.GetContext(ctx, chatID)
+	unlock()
+	if err != nil || chatCtx == nil {
+		return
+	}
+	tokens := ai.EstimateMessagesTokens(chatCtx.Messages)
+	if tokens <= highWater {
+		return
+	}
+	
+	maxTokens := summaryMaxTokens(h.config)
+	cut := summaryCut(chatCtx.Messages, highWater, maxTokens)
+	if cut == 0 {
+		return
+	}
+	summarized := chatCtx.Messages[1:cut]
+	
+	summaryCtx, cancel := context.WithTimeout(ctx, summaryTimeout)
+	defer cancel()
+	
+	request := []models.Message{
+		{Role: "system", Content: summaryInstruction},
+		{Role: "user", Content: summaryTranscript(summarized)},
+	}
+	summary, err := h.aiService.GetResponse(summaryCtx, request, modelID, ai.WithUser(userID), ai.WithParams(models.AIParams{MaxTokens: maxTokens}))
+	if err != nil {
+		h.logger.WithError(err).WithField("chatID", chatID).Warn("Failed to summarize context")
+		return
+	}
+	summary = ai.TruncateToTokens(strings.TrimSpace(h.processThinkingTags(summary, false)), maxTokens)
+	if summary == "" {
+		return
+	}
+	
+	unlock = h.chatLocks.lock(chatID)
+	defer unlock()
+	chatCtx, err = h.storage.GetContext(ctx, chatID)
+	if err != nil || chatCtx == nil || !startsWith(chatCtx.Messages, summarized) {
+		h.logger.WithField("chatID", chatID).Debug("Context changed while summarizing, dropping the summary")
+		return
+	}
+	applySummary(chatCtx, cut, summary)
+	if err := h.storage.SaveContext(ctx, chatCtx); err != nil {
+		h.logger.WithError(err).WithField("chatID", chatID).Warn("Failed to save summarized context")
+		return
+	}
+	
+	h.metrics.RecordContextTrim("summary")
+	h.logger.WithFields(logrus.Fields{
+		"chatID":     chatID,
+		"summarized": cut - 1,
+		"before":     tokens,
+		"after":      ai.EstimateMessagesTokens(chatCtx.Messages),
+	}).Info("Summarized oldest context messages")
+}
+
+// startsWith reports whether messages continue the system prompt with prefix
+func startsWith(messages, prefix []models.Message) bool {
+	if len(messages) <= len(prefix) {
+		return false
+	}
+	for i, msg := range prefix {
+		if messages[i+1] != msg {
+			return false
+		}
+	}
+	return true
+}
+
+// applySummary replaces messages 1..cut-1 of the context with the summary
+func applySummary(chatCtx *models.ChatContext, cut int, summary string) {
+	messages := make([]models.Message, 0, len(chatCtx.Messages)-cut+2)
+	messages = append(messages, chatCtx.Messages[0], models.Message{Role: "system", Content: summaryPrefix + summary})
+	chatCtx.Messages = append(messages, chatCtx.Messages[cut:]...)
+	
+	// Messages 1..cut-1 became the summary at 1
+	shift := cut - 2
+	shiftReplyIndex(chatCtx, shift)
+	for key, position := range chatCtx.KnowledgeIndex {
+		if position < cut {
+			delete(chatCtx.KnowledgeIndex, key)
+			continue
+		}
+		chatCtx.KnowledgeIndex[key] = position - shift
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+func TestSummaryCut(t *testing.T) {
+	msg := func(role string) models.Message { return models.Message{Role: role, Content: role + " turn"} }
+	summary := models.Message{Role: "system", Content: summaryPrefix + "earlier"}
+	tests := []struct {
+		name      string
+		messages  []models.Message
+		highWater int
+		want      int
+	}{
+		{name: "nothing to summarize", messages: []models.Message{msg("system"), msg("user")}, highWater: 1, want: 0},
+		{name: "latest exchange kept", messages: []models.Message{msg("system"), msg("user"), msg("assistant")}, highWater: 1, want: 0},
+		{name: "only the earlier summary", messages: []models.Message{msg("system"), summary, msg("user"), msg("assistant")}, highWater: 1, want: 0},
+		{
+			name:      "oldest half",
+			messages:  []models.Message{msg("system"), msg("user"), msg("assistant"), msg("user"), msg("assistant"), msg("user"), msg("assistant")},
+			highWater: 100000,
+			want:      5,
+		},
+		{
+			name:      "more while over budget",
+			messages:  []models.Message{msg("system"), msg("user"), msg("assistant"), msg("user"), msg("assistant"), msg("user"), msg("assistant"), msg("user"), msg("assistant")},
+			highWater: 1,
+			want:      7,
+		},
+		{
+			name:      "answered question pending",
+			messages:  []models.Message{msg("system"), msg("user"), msg("assistant"), msg("user"), msg("assistant"), msg("user")},
+			highWater: 1,
+			want:      5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summaryCut(tt.messages, tt.highWater, 10); got != tt.want {
+				t.Errorf("summaryCut = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// userKeys is a UserKeyStore with one key per user
+type userKeys map[int64]string
+
+func (k userKeys) UserAPIKey(ctx context.Context, userID int64, endpoint string) (string, error) {
+	return k[userID], nil
+}
+
+// summaryEndpoint is an endpoint answering summarization requests with a
+// summary and anything else with a long answer, recording the keys of the
+// summarization requests
+type summaryEndpoint struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (e *summaryEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Messages []models.Message `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+	answer := strings.Repeat("A long and detailed answer. ", 20)
+	if len(request.Messages) > 0 && request.Messages[0].Content == summaryInstruction {
+		e.mu.Lock()
+		e.keys = append(e.keys, r.Header.Get("Authorization"))
+		e.mu.Unlock()
+		answer = "The user asked several questions."
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": answer}}},
+	})
+}
+
+func (e *summaryEndpoint) summaryKeys() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.keys...)
+}
+
+func TestSummarizeAfterHighWater(t *testing.T) {
+	tests := []struct {
+		name        string
+		highWater   int
+		wantSummary bool
+	}{
+		{name: "below the high-water mark", highWater: 100000},
+		{name: "crossed", highWater: 300, wantSummary: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := &summaryEndpoint{}
+			server := httptest.NewServer(http.HandlerFunc(endpoint.serve))
+			t.Cleanup(server.Close)
+			
+			cfg := newTestConfig()
+			cfg.Context.SummarizeTokens = tt.highWater
+			cfg.Models.Endpoints = []config.ModelEndpoint{{
+				Name: "test", DisplayName: "Test", BaseURL: server.URL, APIKey: "sk-shared",
+				Models: []config.ModelInfo{{ID: testModel}},
+			}}
+			service := ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger())
+			service.SetUserKeys(userKeys{7: "sk-user-7"})
+			h, telegram := newTestMessageHandler(t, cfg, service)
+			
+			for i := 1; i <= 4; i++ {
+				handleAndWait(t, h, privateMessage(42, 7, i, fmt.Sprintf("Question number %d?", i)))
+				if edits := telegram.texts("editMessageText", 42); len(edits) != i {
+					t.Fatalf("question %d got %d replies", i, len(edits))
+				}
+			}
+			
+			chatCtx, err := h.storage.GetContext(context.Background(), 42)
+			if err != nil || chatCtx == nil {
+				t.Fatalf("no saved context: %v", err)
+			}
+			messages := chatCtx.Messages
+			if summarized := len(messages) > 1 && isSummaryMessage(messages[1]); summarized != tt.wantSummary {
+				t.Fatalf("context summarized = %v, want %v (%d messages)", summarized, tt.wantSummary, len(messages))
+			}
+			keys := endpoint.summaryKeys()
+			if !tt.wantSummary {
+				if len(keys) != 0 {
+					t.Errorf("sent %d summarization requests below the high-water mark", len(keys))
+				}
+				return
+			}
+			
+			// The latest exchange stays as it was
+			if last := messages[len(messages)-2]; last.Role != "user" || last.Content != "Question number 4?" {
+				t.Errorf("latest question became %s %q", last.Role, last.Content)
+			}
+			// Summaries are requested on behalf of the user, with their own key
+			for _, key := range keys {
+				if key != "Bearer sk-user-7" {
+					t.Errorf("summary requested with %q, want the user's key", key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// reportedLatency returns the latency a /testmodel report gives
+func reportedLatency(t *testing.T, report string) time.Duration {
+	t.Helper()
+	match := regexp.MustCompile(`耗时：(\S+)`).FindStringSubmatch(report)
+	if match == nil {
+		t.Fatalf("report %q gives no latency", report)
+	}
+	latency, err := time.ParseDuration(match[1])
+	if err != nil {
+		t.Fatalf("report %q gives latency %q: %v", report, match[1], err)
+	}
+	return latency
+}
+
+func TestTestModel(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		err   error
+		want  []string
+	}{
+		{name: "success", reply: "OK", want: []string{"✅ 模型测试成功", "模型：Test Model (test-model)", "端点：test", "回复：OK"}},
+		{name: "long reply", reply: strings.Repeat("好", testModelMaxReply+10), want: []string{"回复：" + strings.Repeat("好", testModelMaxReply) + "..."}},
+		{name: "failure", err: errors.New("endpoint down"), want: []string{"❌ 模型测试失败", "模型：Test Model (test-model)", "错误：endpoint down"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Bot.AdminIDs = []int64{7}
+			service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+				time.Sleep(20 * time.Millisecond)
+				return tt.reply, tt.err
+			}}
+			h, telegram := newTestMessageHandler(t, cfg, service)
+			c := newTestCommandHandler(h)
+			
+			runCommand(t, c, 42, 7, "/testmodel  test-model ")
+			
+			// The fixed prompt goes to the model alone
+			if service.requestCount() != 1 {
+				t.Fatalf("AI got %d requests, want 1", service.requestCount())
+			}
+			if request := service.requests[0]; len(request) != 1 || request[0].Content != testModelPrompt {
+				t.Errorf("request %+v, want only the test prompt", request)
+			}
+			report := lastText(telegram.texts("editMessageText", 42))
+			for _, want := range tt.want {
+				if !strings.Contains(report, want) {
+					t.Errorf("report %q misses %q", report, want)
+				}
+			}
+			if latency := reportedLatency(t, report); latency < 20*time.Millisecond || latency > time.Second {
+				t.Errorf("reported latency %v, want about 20ms", latency)
+			}
+		})
+	}
+}
+
+func TestTestModelRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		userID  int64
+		want    string
+	}{
+		{name: "not an admin", command: "/testmodel test-model", userID: 8, want: "仅限管理员"},
+		{name: "no model", command: "/testmodel", userID: 7, want: "用法：/testmodel"},
+		{name: "unknown model", command: "/testmodel gpt-99", userID: 7, want: "未找到模型：gpt-99"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Bot.AdminIDs = []int64{7}
+			service := &fakeAI{}
+			h, telegram := newTestMessageHandler(t, cfg, service)
+			c := newTestCommandHandler(h)
+			
+			runCommand(t, c, 42, tt.userID, tt.command)
+			
+			if text := lastText(telegram.texts("sendMessage", 42)); !strings.Contains(text, tt.want) {
+				t.Errorf("answered %q, want %q", text, tt.want)
+			}
+			if service.requestCount() != 0 {
+				t.Errorf("AI got %d requests, want none", service.requestCount())
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+// newReasoningAI returns a real AI service for testModel whose endpoint thinks
+// before answering and reports reasoningTokens of its completion as reasoning
+func newReasoningAI(t *testing.T, cfg *config.Config, reasoningTokens int) ai.Service {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"<think>pondering</think>the answer"}}],`+
+			`"usage":{"prompt_tokens":100,"completion_tokens":300,"total_tokens":400,"completion_tokens_details":{"reasoning_tokens":%d}}}`, reasoningTokens)
+	}))
+	t.Cleanup(server.Close)
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", DisplayName: "Test", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: testModel}},
+	}}
+	return ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger())
+}
+
+func TestThinkStatsNote(t *testing.T) {
+	tests := []struct {
+		name            string
+		settings        models.ChatSettings
+		reasoningTokens int
+		wantNote        bool
+	}{
+		{name: "enabled", settings: models.ChatSettings{ShowThinkStats: true}, reasoningTokens: 120, wantNote: true},
+		{name: "disabled", reasoningTokens: 120},
+		{name: "thinking shown", settings: models.ChatSettings{ShowThinkStats: true, ShowThink: true}, reasoningTokens: 120},
+		{name: "no reasoning reported", settings: models.ChatSettings{ShowThinkStats: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			h, telegram := newTestMessageHandler(t, cfg, newReasoningAI(t, cfg, tt.reasoningTokens))
+			settings := tt.settings
+			if err := h.storage.SaveSettings(context.Background(), 42, &settings); err != nil {
+				t.Fatalf("SaveSettings: %v", err)
+			}
+			
+			handleAndWait(t, h, privateMessage(42, 7, 1, "question"))
+			
+			edits := telegram.texts("editMessageText", 42)
+			if len(edits) == 0 {
+				t.Fatal("answer never shown")
+			}
+			answer := edits[len(edits)-1]
+			if !strings.Contains(answer, "the answer") {
+				t.Fatalf("answer %q, want the model's answer", answer)
+			}
+			if got := strings.Contains(answer, "💭 思考了 120 tokens"); got != tt.wantNote {
+				t.Errorf("answer %q has the note: %v, want %v", answer, got, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestThinkStatsCommand(t *testing.T) {
+	ctx := context.Background()
+	h, telegram := newTestMessageHandler(t, newTestConfig(), &fakeAI{})
+	c := newTestCommandHandler(h)
+	
+	for _, want := range []bool{true, false} {
+		if err := c.HandleCommand(ctx, command(42, 7, "/thinkstats")); err != nil {
+			t.Fatalf("/thinkstats: %v", err)
+		}
+		if got := c.getChatSettings(ctx, 42).ShowThinkStats; got != want {
+			t.Errorf("ShowThinkStats = %v, want %v", got, want)
+		}
+	}
+	if sent := telegram.texts("sendMessage", 42); len(sent) != 2 || !strings.Contains(sent[0], "已开启") || !strings.Contains(sent[1], "已关闭") {
+		t.Errorf("sent %q, want the note turned on, then off", sent)
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// branchFromReply rewinds the context to the point of the bot reply the user is
+// replying to, so the conversation continues from that branch.
+// Only private chats are branched; groups keep their linear context.
+func (h *MessageHandler) branchFromReply(chatCtx *models.ChatContext, message *tgbotapi.Message) {
+	if !message.Chat.IsPrivate() || message.ReplyToMessage == nil || message.ReplyToMessage.From == nil {
+		return
+	}
+	if message.ReplyToMessage.From.ID != h.bot.Self.ID {
+		return
+	}
+
+	index, ok := chatCtx.ReplyIndex[message.ReplyToMessage.MessageID]
+	if !ok || index >= len(chatCtx.Messages) {
+		// Unknown or already the latest turn
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"chatID":  chatCtx.ChatID,
+		"replyTo": message.ReplyToMessage.MessageID,
+		"dropped": len(chatCtx.Messages) - index,
+	}).Debug("Branching context from replied message")
+
+	chatCtx.Messages = chatCtx.Messages[:index]
+	dropKnowledgeAfter(chatCtx, index)
+
+	// Replies past the branch point no longer exist in this context
+	for messageID, i := range chatCtx.ReplyIndex {
+		if i > index {
+			delete(chatCtx.ReplyIndex, messageID)
+		}
+	}
+}
+
+// recordReplyIndex remembers how far the context reached when a bot reply was sent
+func recordReplyIndex(chatCtx *models.ChatContext, messageID int) {
+	if chatCtx.ReplyIndex == nil {
+		chatCtx.ReplyIndex = make(map[int]int)
+	}
+	chatCtx.ReplyIndex[messageID] = len(chatCtx.Messages)
+}
+
+// shiftReplyIndex adjusts recorded positions after removed messages were
+// trimmed from the start of the history (after the system message)
+func shiftReplyIndex(chatCtx *models.ChatContext, removed int) {
+	for messageID, i := range chatCtx.ReplyIndex {
+		// Positions that fell into the trimmed history can't be restored
+		if i-removed <= 1 {
+			delete(chatCtx.ReplyIndex, messageID)
+			continue
+		}
+		chatCtx.ReplyIndex[messageID] = i - removed
+	}
+}
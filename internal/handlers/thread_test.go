@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// conversation returns the contents of the non-system messages
+func conversation(messages []models.Message) []string {
+	var contents []string
+	for _, msg := range messages {
+		if msg.Role != "system" {
+			contents = append(contents, msg.Content)
+		}
+	}
+	return contents
+}
+
+// countingAI answers the nth request with "answer n"
+func countingAI() *fakeAI {
+	service := &fakeAI{}
+	service.reply = func(context.Context, []models.Message) (string, error) {
+		return fmt.Sprintf("answer %d", service.requestCount()), nil
+	}
+	return service
+}
+
+// replyTo returns update as a reply to the bot's message replyID
+func replyTo(update *tgbotapi.Update, h *MessageHandler, replyID int) *tgbotapi.Update {
+	update.Message.ReplyToMessage = &tgbotapi.Message{
+		MessageID: replyID,
+		From:      &tgbotapi.User{ID: h.bot.Self.ID, IsBot: true},
+		Chat:      update.Message.Chat,
+	}
+	return update
+}
+
+// onlyReply returns the message ID of the single bot reply recorded for chatID
+func onlyReply(t *testing.T, h *MessageHandler, chatID int64) int {
+	t.Helper()
+	chatCtx, err := h.storage.GetContext(context.Background(), chatID)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("GetContext = %v, %v", chatCtx, err)
+	}
+	if len(chatCtx.ReplyIndex) != 1 {
+		t.Fatalf("reply index %v, want one reply", chatCtx.ReplyIndex)
+	}
+	for messageID := range chatCtx.ReplyIndex {
+		return messageID
+	}
+	return 0
+}
+
+func TestReplyBranchesConversation(t *testing.T) {
+	service := countingAI()
+	h, _ := newTestMessageHandler(t, newTestConfig(), service)
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "first"))
+	firstReply := onlyReply(t, h, 42)
+	handleAndWait(t, h, privateMessage(42, 7, 2, "second"))
+	
+	// Replying to the first answer continues from there
+	handleAndWait(t, h, replyTo(privateMessage(42, 7, 3, "branch"), h, firstReply))
+	
+	if got := service.requestCount(); got != 3 {
+		t.Fatalf("got %d requests, want 3", got)
+	}
+	want := []string{"first", "answer 1", "branch"}
+	if got := conversation(service.requests[2]); !reflect.DeepEqual(got, want) {
+		t.Errorf("branch request %q, want %q", got, want)
+	}
+	
+	chatCtx, _ := h.storage.GetContext(context.Background(), 42)
+	want = []string{"first", "answer 1", "branch", "answer 3"}
+	if got := conversation(chatCtx.Messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored context %q, want %q", got, want)
+	}
+	// The second answer's branch is gone, the first and the new one remain
+	if len(chatCtx.ReplyIndex) != 2 {
+		t.Errorf("reply index %v, want the first and the latest reply", chatCtx.ReplyIndex)
+	}
+	if _, ok := chatCtx.ReplyIndex[firstReply]; !ok {
+		t.Errorf("reply index %v lost the first reply %d", chatCtx.ReplyIndex, firstReply)
+	}
+}
+
+func TestReplyKeepsLinearConversation(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply func(update *tgbotapi.Update, h *MessageHandler, replyID int) *tgbotapi.Update
+	}{
+		{name: "unknown message", reply: func(update *tgbotapi.Update, h *MessageHandler, replyID int) *tgbotapi.Update {
+			return replyTo(update, h, replyID+1000)
+		}},
+		{name: "user's message", reply: func(update *tgbotapi.Update, h *MessageHandler, replyID int) *tgbotapi.Update {
+			update = replyTo(update, h, replyID)
+			update.Message.ReplyToMessage.From = &tgbotapi.User{ID: 7}
+			return update
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := countingAI()
+			h, _ := newTestMessageHandler(t, newTestConfig(), service)
+			
+			handleAndWait(t, h, privateMessage(42, 7, 1, "first"))
+			firstReply := onlyReply(t, h, 42)
+			handleAndWait(t, h, privateMessage(42, 7, 2, "second"))
+			handleAndWait(t, h, tt.reply(privateMessage(42, 7, 3, "third"), h, firstReply))
+			
+			want := []string{"first", "answer 1", "second", "answer 2", "third"}
+			if got := conversation(service.requests[2]); !reflect.DeepEqual(got, want) {
+				t.Errorf("request %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestShiftReplyIndex(t *testing.T) {
+	chatCtx := &models.ChatContext{ReplyIndex: map[int]int{10: 3, 11: 5, 12: 7}}
+	
+	// Two messages after the system message were trimmed
+	shiftReplyIndex(chatCtx, 2)
+	
+	// The first reply's turn fell into the trimmed history
+	want := map[int]int{11: 3, 12: 5}
+	if !reflect.DeepEqual(chatCtx.ReplyIndex, want) {
+		t.Errorf("reply index %v, want %v", chatCtx.ReplyIndex, want)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxTimeBudgetSeconds caps the per-chat time budget below the request timeout
+const maxTimeBudgetSeconds = 110
+
+// responseTimeBudget returns how long answers in this chat may take before the
+// partial answer is sent; 0 means unlimited
+func responseTimeBudget(cfg *config.Config, settings *models.ChatSettings) time.Duration {
+	budget := cfg.Context.ResponseTimeBudget
+	if settings.TimeBudgetSeconds != 0 {
+		budget = time.Duration(settings.TimeBudgetSeconds) * time.Second
+	}
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// handleTimeBudget handles /timebudget command, setting the chat's response time budget.
+// Accepts a number of seconds, "off" to disable or "default" to follow the config.
+func (h *CommandHandler) handleTimeBudget(ctx context.Context, chatID int64, args string) error {
+	settings := h.getChatSettings(ctx, chatID)
+	arg := strings.ToLower(strings.TrimSpace(args))
+	
+	switch arg {
+	case "":
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.describeTimeBudget(settings)+
+			"\n\n用法：/timebudget <秒数> | off | default"))
+		return err
+	case "off":
+		settings.TimeBudgetSeconds = -1
+	case "default":
+		settings.TimeBudgetSeconds = 0
+	default:
+		seconds, err := strconv.Atoi(strings.TrimSuffix(arg, "s"))
+		if err != nil || seconds <= 0 || seconds > maxTimeBudgetSeconds {
+			_, err := h.bot.Send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("❌ 请输入 1-%d 之间的秒数，或 off / default", maxTimeBudgetSeconds)))
+			return err
+		}
+		settings.TimeBudgetSeconds = seconds
+	}
+	
+	if err := h.storage.SaveSettings(ctx, chatID, settings); err != nil {
+		h.logger.WithError(err).Error("Failed to save settings")
+		_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "❌ 保存失败，请稍后重试"))
+		return err
+	}
+	
+	_, err := h.bot.Send(tgbotapi.NewMessage(chatID, "✅ "+h.describeTimeBudget(settings)))
+	return err
+}
+
+// describeTimeBudget describes the effective response time budget of a chat
+func (h *CommandHandler) describeTimeBudget(settings *models.ChatSettings) string {
+	source := "本聊天设置"
+	if settings.TimeBudgetSeconds == 0 {
+		source = "全局默认"
+	}
+	budget := responseTimeBudget(h.config, settings)
+	if budget == 0 {
+		return fmt.Sprintf("回复时间预算：不限制（%s）", source)
+	}
+	return fmt.Sprintf("回复时间预算：%s，超时发送已生成的部分（%s）", budget, source)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/services/ai"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+)
+
+// newStallingAI returns an AI service whose endpoint streams the start of an
+// answer and then stalls until the request is given up
+func newStallingAI(t *testing.T, cfg *config.Config) ai.Service {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"The beginning\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+	
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "test", DisplayName: "Test", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: testModel}},
+	}}
+	return ai.NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, newTestLogger()), newTestLogger())
+}
+
+func TestTimeBudgetNoteOnlySentToUser(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Context.ResponseTimeBudget = 300 * time.Millisecond
+	h, telegram := newTestMessageHandler(t, cfg, newStallingAI(t, cfg))
+	
+	handleAndWait(t, h, privateMessage(42, 7, 1, "Tell me a long story"))
+	
+	note := h.localizer.Get("zh-CN", i18n.MsgTimeBudgetCut, nil)
+	edits := telegram.texts("editMessageText", 42)
+	if len(edits) == 0 || !strings.Contains(edits[len(edits)-1], "The beginning") || !strings.Contains(edits[len(edits)-1], note) {
+		t.Fatalf("sent %q, want the partial answer with the note %q", edits, note)
+	}
+	
+	chatCtx, err := h.storage.GetContext(context.Background(), 42)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("no saved context: %v", err)
+	}
+	last := chatCtx.Messages[len(chatCtx.Messages)-1]
+	if last.Role != "assistant" || last.Content != "The beginning" {
+		t.Errorf("context kept %s %q, want only the model's words", last.Role, last.Content)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// toolResult is the outcome of one tool call
+type toolResult struct {
+	callID string
+	tool   string
+	output string // the tool's raw JSON result
+}
+
+// compileToolSummaries parses the configured per-tool summary templates.
+// Tool names are matched case-insensitively, as config keys are lowercased.
+func compileToolSummaries(tools map[string]config.ToolConfig) (map[string]*template.Template, error) {
+	summaries := make(map[string]*template.Template, len(tools))
+	for name, tool := range tools {
+		if tool.Summary == "" {
+			continue
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(tool.Summary)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s summary: %w", name, err)
+		}
+		summaries[strings.ToLower(name)] = tmpl
+	}
+	return summaries, nil
+}
+
+// toolMessage renders a tool result for the model as a tool role message
+func toolMessage(result toolResult) models.Message {
+	return models.Message{
+		Role:       "tool",
+		Content:    result.output,
+		ToolCallID: result.callID,
+		Name:       result.tool,
+	}
+}
+
+// toolSummary renders the user-facing summary of a tool result. Tools without
+// a summary, or whose result doesn't fit it, only show the tool's name, so raw
+// JSON never reaches the user.
+func (h *MessageHandler) toolSummary(result toolResult, lang string) string {
+	fallback := h.localizer.Get(lang, i18n.MsgToolUsed, map[string]interface{}{"Tool": result.tool})
+	
+	tmpl, ok := h.toolSummaries[strings.ToLower(result.tool)]
+	if !ok {
+		return fallback
+	}
+	var fields interface{}
+	if err := json.Unmarshal([]byte(result.output), &fields); err != nil {
+		h.logger.WithError(err).WithField("tool", result.tool).Warn("Tool result is not JSON, showing the tool name")
+		return fallback
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, fields); err != nil {
+		h.logger.WithError(err).WithField("tool", result.tool).Warn("Failed to render tool summary, showing the tool name")
+		return fallback
+	}
+	return b.String()
+}
+
+// recordToolResults adds tool results to the chat's context as tool role
+// messages for the model and shows the user a summary of each
+func (h *MessageHandler) recordToolResults(ctx context.Context, chatID int64, results []toolResult, lang string) error {
+	unlock := h.chatLocks.lock(chatID)
+	chatCtx, _, _, err := h.loadContext(ctx, chatID)
+	if err != nil {
+		unlock()
+		return err
+	}
+	for _, result := range results {
+		chatCtx.Messages = append(chatCtx.Messages, toolMessage(result))
+	}
+	chatCtx.LastActivity = time.Now()
+	err = h.storage.SaveContext(ctx, chatCtx)
+	unlock()
+	if err != nil {
+		return err
+	}
+	
+	for _, result := range results {
+		if _, err := h.bot.Send(tgbotapi.NewMessage(chatID, h.toolSummary(result, lang))); err != nil {
+			h.logger.WithError(err).WithField("tool", result.tool).Warn("Failed to send tool summary")
+			h.handleSendFailure(ctx, chatID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+const weatherJSON = `{"condition":"晴","temperature":"25°C"}`
+
+// newToolHandler returns a handler that summarizes weather results
+func newToolHandler(t *testing.T, service *fakeAI) (*MessageHandler, *fakeTelegram) {
+	cfg := newTestConfig()
+	cfg.Tools = map[string]config.ToolConfig{
+		"Weather": {Summary: "查询了天气：{{.condition}}，{{.temperature}}"},
+	}
+	return newTestMessageHandler(t, cfg, service)
+}
+
+func TestToolSummary(t *testing.T) {
+	h, _ := newToolHandler(t, &fakeAI{})
+	
+	tests := []struct {
+		name   string
+		result toolResult
+		want   string
+	}{
+		{
+			name:   "configured tool",
+			result: toolResult{tool: "weather", output: weatherJSON},
+			want:   "查询了天气：晴，25°C",
+		},
+		{
+			name:   "tool names ignore case",
+			result: toolResult{tool: "WEATHER", output: weatherJSON},
+			want:   "查询了天气：晴，25°C",
+		},
+		{
+			name:   "tool without a summary",
+			result: toolResult{tool: "search", output: `{"results":["a","b"]}`},
+			want:   "🔧 调用了工具：search",
+		},
+		{
+			name:   "result missing a field",
+			result: toolResult{tool: "weather", output: `{"condition":"晴"}`},
+			want:   "🔧 调用了工具：weather",
+		},
+		{
+			name:   "result not JSON",
+			result: toolResult{tool: "weather", output: "sunny"},
+			want:   "🔧 调用了工具：weather",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.toolSummary(tt.result, "zh-CN"); got != tt.want {
+				t.Errorf("toolSummary = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolResultsReachModel(t *testing.T) {
+	ctx := context.Background()
+	service := &fakeAI{reply: func(ctx context.Context, messages []models.Message) (string, error) {
+		return "适合", nil
+	}}
+	h, telegram := newToolHandler(t, service)
+	
+	results := []toolResult{
+		{callID: "call_1", tool: "weather", output: weatherJSON},
+		{callID: "call_2", tool: "search", output: `{"results":["a"]}`},
+	}
+	if err := h.recordToolResults(ctx, 42, results, "zh-CN"); err != nil {
+		t.Fatalf("recordToolResults: %v", err)
+	}
+	
+	// The user sees the summaries, never the raw results
+	sent := telegram.texts("sendMessage", 42)
+	if want := []string{"查询了天气：晴，25°C", "🔧 调用了工具：search"}; strings.Join(sent, "|") != strings.Join(want, "|") {
+		t.Errorf("user saw %q, want %q", sent, want)
+	}
+	
+	// The model sees each result as its own tool role message
+	handleAndWait(t, h, privateMessage(42, 7, 1, "适合出门吗"))
+	if service.requestCount() != 1 {
+		t.Fatalf("AI got %d requests, want 1", service.requestCount())
+	}
+	want := []models.Message{
+		{Role: "tool", Content: weatherJSON, ToolCallID: "call_1", Name: "weather"},
+		{Role: "tool", Content: `{"results":["a"]}`, ToolCallID: "call_2", Name: "search"},
+		{Role: "user", Content: "适合出门吗"},
+	}
+	request := service.requests[0]
+	if len(request) < len(want) {
+		t.Fatalf("request %+v misses the tool results", request)
+	}
+	for i, msg := range request[len(request)-len(want):] {
+		if msg != want[i] {
+			t.Errorf("request message %d = %+v, want %+v", i, msg, want[i])
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestMessagesAndSessionsCounted(t *testing.T) {
+	ctx := context.Background()
+	cfg := newTestConfig()
+	cfg.Context.InactivityMinutes = 30
+	service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) { return "answer", nil }}
+	h, _ := newTestMessageHandler(t, cfg, service)
+	
+	// Two messages in a fresh chat are one session
+	handleAndWait(t, h, privateMessage(42, 7, 1, "hello"))
+	handleAndWait(t, h, privateMessage(42, 7, 2, "and again"))
+	stats, err := h.storage.GetUserStats(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetUserStats: %v", err)
+	}
+	if stats.TotalMessages != 2 || stats.TotalSessions != 1 {
+		t.Errorf("stats = %d messages, %d sessions, want 2 messages in 1 session", stats.TotalMessages, stats.TotalSessions)
+	}
+	
+	// Coming back after the inactivity window starts another
+	chatCtx, err := h.storage.GetContext(ctx, 42)
+	if err != nil || chatCtx == nil {
+		t.Fatalf("GetContext = %v, %v", chatCtx, err)
+	}
+	chatCtx.LastActivity = time.Now().Add(-time.Hour)
+	if err := h.storage.SaveContext(ctx, chatCtx); err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+	handleAndWait(t, h, privateMessage(42, 7, 3, "back again"))
+	stats, _ = h.storage.GetUserStats(ctx, 7)
+	if stats.TotalMessages != 3 || stats.TotalSessions != 2 {
+		t.Errorf("stats = %d messages, %d sessions, want 3 messages in 2 sessions", stats.TotalMessages, stats.TotalSessions)
+	}
+}
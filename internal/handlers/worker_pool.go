@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+// workerPool bounds how many messages are processed at once
+type workerPool struct {
+	mu      sync.RWMutex
+	jobs    chan func()
+	stopped bool
+	done    sync.WaitGroup
+}
+
+// newWorkerPool starts workers goroutines fed by a queue of queueSize jobs.
+// A workers count that is not positive uses config.DefaultWorkers.
+func newWorkerPool(workers, queueSize int) *workerPool {
+	if workers <= 0 {
+		workers = config.DefaultWorkers
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	
+	p := &workerPool{jobs: make(chan func(), queueSize)}
+	p.done.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.done.Done()
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// trySubmit queues job without blocking and reports false when the pool is
+// saturated or stopped
+func (p *workerPool) trySubmit(job func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return false
+	}
+	
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop refuses new jobs and waits up to timeout for the running and queued
+// ones to finish, reporting whether they all did
+func (p *workerPool) stop(timeout time.Duration) bool {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+	
+	finished := make(chan struct{})
+	go func() {
+		p.done.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Shutdown stops accepting messages and waits up to timeout for the ones
+// being processed to finish
+func (h *MessageHandler) Shutdown(timeout time.Duration) {
+	if !h.workers.stop(timeout) {
+		h.logger.WithField("timeout", timeout).Warn("Messages still being processed at shutdown")
+	}
+}
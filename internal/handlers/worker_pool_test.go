@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/i18n"
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// occupyPool fills every worker of p with a job blocked until release is
+// closed, then fills its queue
+func occupyPool(t *testing.T, p *workerPool, workers, queueSize int, release chan struct{}) {
+	t.Helper()
+	started := make(chan struct{}, workers)
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; i < workers; i++ {
+		// Wait for a free worker, an unbuffered queue only takes jobs then
+		for !p.trySubmit(func() { started <- struct{}{}; <-release }) {
+			if time.Now().After(deadline) {
+				t.Fatal("worker never became free")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		<-started
+	}
+	for i := 0; i < queueSize; i++ {
+		if !p.trySubmit(func() { <-release }) {
+			t.Fatalf("queued job %d refused, want the queue to hold %d", i+1, queueSize)
+		}
+	}
+}
+
+func TestWorkerPoolSaturation(t *testing.T) {
+	tests := []struct {
+		name      string
+		workers   int
+		queueSize int
+	}{
+		{name: "no queue", workers: 1, queueSize: 0},
+		{name: "queued", workers: 1, queueSize: 3},
+		{name: "several workers", workers: 3, queueSize: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newWorkerPool(tt.workers, tt.queueSize)
+			release := make(chan struct{})
+			occupyPool(t, p, tt.workers, tt.queueSize, release)
+			
+			if p.trySubmit(func() {}) {
+				t.Error("saturated pool accepted a job")
+			}
+			
+			close(release)
+			if !p.stop(5 * time.Second) {
+				t.Error("pool didn't finish its jobs")
+			}
+			if p.trySubmit(func() {}) {
+				t.Error("stopped pool accepted a job")
+			}
+		})
+	}
+}
+
+func TestWorkerPoolStopDrainsQueue(t *testing.T) {
+	p := newWorkerPool(1, 4)
+	ran := make(chan int, 4)
+	for i := 0; i < 4; i++ {
+		i := i
+		if !p.trySubmit(func() { ran <- i }) {
+			t.Fatalf("job %d refused", i)
+		}
+	}
+	if !p.stop(5 * time.Second) {
+		t.Fatal("pool didn't finish its jobs")
+	}
+	if len(ran) != 4 {
+		t.Errorf("%d of 4 queued jobs ran before stop returned", len(ran))
+	}
+}
+
+func TestWorkerPoolStopTimeout(t *testing.T) {
+	p := newWorkerPool(1, 0)
+	release := make(chan struct{})
+	defer close(release)
+	occupyPool(t, p, 1, 0, release)
+	
+	if p.stop(10 * time.Millisecond) {
+		t.Error("stop reported a blocked job as finished")
+	}
+}
+
+func TestWorkerPoolDefaultWorkers(t *testing.T) {
+	p := newWorkerPool(0, 0)
+	release := make(chan struct{})
+	defer close(release)
+	// Unlimited workers would never refuse a job
+	occupyPool(t, p, 16, 0, release)
+	if p.trySubmit(func() {}) {
+		t.Error("pool with the default worker count accepted a 17th concurrent job")
+	}
+}
+
+func TestHandleMessageBusy(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Bot.Workers = 1
+	cfg.Bot.QueueSize = 0
+	service := &fakeAI{reply: func(context.Context, []models.Message) (string, error) { return "answer", nil }}
+	h, telegram := newTestMessageHandler(t, cfg, service)
+	
+	release := make(chan struct{})
+	occupyPool(t, h.workers, 1, 0, release)
+	shedBefore := counterValue(t, "telegram_bot_requests_shed_total")
+	
+	if err := h.HandleMessage(context.Background(), privateMessage(42, 7, 1, "hello")); err != nil {
+		t.Fatalf("HandleMessage: %v", err)
+	}
+	close(release)
+	
+	busy := h.localizer.Get("zh-CN", i18n.MsgBusy, nil)
+	edits := telegram.texts("editMessageText", 42)
+	if len(edits) != 1 || edits[0] != busy {
+		t.Errorf("edited the reply to %q, want the busy message %q", edits, busy)
+	}
+	if shed := counterValue(t, "telegram_bot_requests_shed_total") - shedBefore; shed != 1 {
+		t.Errorf("shed counter grew by %v, want 1", shed)
+	}
+	if n := service.requestCount(); n != 0 {
+		t.Errorf("AI service got %d requests for a refused message, want 0", n)
+	}
+}
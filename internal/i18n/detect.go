@@ -0,0 +1,52 @@
+package i18n
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minDetectLetters is how many letters a message needs before its language is guessed
+const minDetectLetters = 4
+
+// detectDominance is the share of letters one script needs for a confident guess
+const detectDominance = 0.6
+
+// DetectLanguage guesses which of languages text is written in, telling
+// Chinese (Han characters) apart from Latin-script languages. It returns an
+// empty string when the text is too short or too mixed to tell, or when no
+// configured language matches the script.
+func DetectLanguage(text string, languages []string) string {
+	var han, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case r < unicode.MaxLatin1 && unicode.IsLetter(r):
+			latin++
+		}
+	}
+
+	// Chinese packs a word into a character or two, so weigh it up against Latin letters
+	chinese := float64(han) * 3
+	total := chinese + float64(latin)
+	if han+latin < minDetectLetters || total == 0 {
+		return ""
+	}
+
+	var base string
+	switch {
+	case chinese/total >= detectDominance:
+		base = "zh"
+	case float64(latin)/total >= detectDominance:
+		base = "en"
+	default:
+		return ""
+	}
+
+	for _, lang := range languages {
+		if strings.EqualFold(lang, base) || strings.HasPrefix(strings.ToLower(lang), base+"-") {
+			return lang
+		}
+	}
+	return ""
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/cf-ai-tgbot-go/internal/config"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/text/language"
 )
 
@@ -14,32 +15,47 @@ type Localizer struct {
 	bundle          *i18n.Bundle
 	defaultLanguage string
 	localizers      map[string]*i18n.Localizer
+	languages       []string
 }
 
-// NewLocalizer creates a new localizer
-func NewLocalizer(cfg *config.I18nConfig) (*Localizer, error) {
+// NewLocalizer creates a new localizer. A language whose file fails to load is
+// skipped with a warning; only a missing default language is an error.
+func NewLocalizer(cfg *config.I18nConfig, logger *logrus.Logger) (*Localizer, error) {
 	bundle := i18n.NewBundle(language.Chinese)
 	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
 
 	// Load language files
+	localizers := make(map[string]*i18n.Localizer)
+	languages := make([]string, 0, len(cfg.Languages))
 	for _, lang := range cfg.Languages {
 		if _, err := bundle.LoadMessageFile(fmt.Sprintf("configs/i18n/%s.json", lang)); err != nil {
-			return nil, fmt.Errorf("failed to load language file %s: %w", lang, err)
+			if lang == cfg.DefaultLanguage {
+				return nil, fmt.Errorf("failed to load default language file %s: %w", lang, err)
+			}
+			logger.WithError(err).WithField("language", lang).Warn("Skipping language whose file failed to load")
+			continue
 		}
+		localizers[lang] = i18n.NewLocalizer(bundle, lang)
+		languages = append(languages, lang)
 	}
 
-	localizers := make(map[string]*i18n.Localizer)
-	for _, lang := range cfg.Languages {
-		localizers[lang] = i18n.NewLocalizer(bundle, lang)
+	if _, ok := localizers[cfg.DefaultLanguage]; !ok {
+		return nil, fmt.Errorf("default language %s is not one of the configured languages", cfg.DefaultLanguage)
 	}
 
 	return &Localizer{
 		bundle:          bundle,
 		defaultLanguage: cfg.DefaultLanguage,
 		localizers:      localizers,
+		languages:       languages,
 	}, nil
 }
 
+// Languages returns the configured languages whose files loaded, in configured order
+func (l *Localizer) Languages() []string {
+	return l.languages
+}
+
 // Get returns localized message
 func (l *Localizer) Get(lang, messageID string, data map[string]interface{}) string {
 	localizer, exists := l.localizers[lang]
@@ -68,6 +84,8 @@ const (
 	MsgContextCleared    = "context_cleared"
 	MsgSettings          = "settings"
 	MsgStats             = "stats"
+	MsgStatsCost         = "stats_cost"
+	MsgStatsModels       = "stats_models"
 	MsgUnknownCommand    = "unknown_command"
 	MsgRateLimitExceeded = "rate_limit_exceeded"
 	MsgError             = "error"
@@ -82,4 +100,20 @@ const (
 	MsgKeywordsSet       = "keywords_set"
 	MsgKeywordsDisabled  = "keywords_disabled"
 	MsgCurrentKeywords   = "current_keywords"
+	MsgGroupIntro        = "group_intro"
+	MsgContextExpired    = "context_expired"
+	MsgThinkStats        = "think_stats"
+	MsgTimeBudgetCut     = "time_budget_cut"
+	MsgContentFiltered   = "content_filtered"
+	MsgResponseAsFile    = "response_as_file"
+	MsgBusy              = "busy"
+	MsgChatNotAllowed    = "chat_not_allowed"
+	MsgNoModels          = "no_models"
+	MsgNoModelsAdmin     = "no_models_admin"
+	MsgNewConversation   = "new_conversation"
+	MsgOnboardingLanguage = "onboarding_language"
+	MsgOnboardingModel   = "onboarding_model"
+	MsgExamples          = "examples"
+	MsgExamplesEmpty     = "examples_empty"
+	MsgToolUsed          = "tool_used"
 )
\ No newline at end of file
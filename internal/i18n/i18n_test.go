@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// useLanguageFiles switches to a directory whose configs/i18n holds the
+// repo's files for languages, restoring the working directory afterwards
+func useLanguageFiles(t *testing.T, languages ...string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "configs", "i18n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, lang := range languages {
+		data, err := os.ReadFile(filepath.Join(wd, "..", "..", "configs", "i18n", lang+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "configs", "i18n", lang+".json"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestNewLocalizerSkipsMissingLanguage(t *testing.T) {
+	useLanguageFiles(t, "zh-CN", "en-US")
+	logger, hook := logtest.NewNullLogger()
+
+	localizer, err := NewLocalizer(&config.I18nConfig{
+		DefaultLanguage: "zh-CN",
+		Languages:       []string{"zh-CN", "ja-JP", "en-US"},
+	}, logger)
+	if err != nil {
+		t.Fatalf("NewLocalizer: %v", err)
+	}
+
+	if got, want := localizer.Languages(), []string{"zh-CN", "en-US"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Languages = %v, want %v", got, want)
+	}
+	if got := localizer.Get("en-US", MsgProcessing, nil); got == MsgProcessing {
+		t.Errorf("en-US message not loaded")
+	}
+	// The skipped language falls back to the default
+	if got, want := localizer.Get("ja-JP", MsgProcessing, nil), localizer.Get("zh-CN", MsgProcessing, nil); got != want {
+		t.Errorf("ja-JP message %q, want the zh-CN %q", got, want)
+	}
+
+	var warned bool
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel && entry.Data["language"] == "ja-JP" {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("no warning about the skipped language")
+	}
+}
+
+func TestNewLocalizerNeedsDefaultLanguage(t *testing.T) {
+	useLanguageFiles(t, "en-US")
+	logger, _ := logtest.NewNullLogger()
+
+	_, err := NewLocalizer(&config.I18nConfig{
+		DefaultLanguage: "zh-CN",
+		Languages:       []string{"zh-CN", "en-US"},
+	}, logger)
+	if err == nil || !strings.Contains(err.Error(), "default language") {
+		t.Errorf("NewLocalizer error %v, want the default language missing", err)
+	}
+}
@@ -41,6 +41,33 @@ var (
 		Help: "Total number of AI requests",
 	}, []string{"model", "status"})
 
+	endpointRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegram_bot_endpoint_rate_limited_total",
+		Help: "Total number of AI requests refused by an endpoint's rate limit",
+	}, []string{"endpoint"})
+
+	aiCostTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegram_bot_ai_cost_total",
+		Help: "Estimated cost of AI requests",
+	}, []string{"model"})
+
+	// Context metrics
+	contextMessages = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "telegram_bot_context_messages",
+		Help:    "Number of messages in the context sent to the AI",
+		Buckets: []float64{2, 5, 10, 20, 30, 50, 75, 100},
+	})
+
+	contextTrims = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegram_bot_context_trims_total",
+		Help: "Total number of times a context was trimmed",
+	}, []string{"reason"})
+
+	requestsShed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "telegram_bot_requests_shed_total",
+		Help: "Total number of messages refused because the processing queue was full",
+	})
+
 	// Cache metrics
 	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "telegram_bot_cache_hits_total",
@@ -70,17 +97,34 @@ var (
 		Buckets: prometheus.DefBuckets,
 	}, []string{"operation"})
 
+	// Knowledge base metrics
+	knowledgeReindexDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "telegram_bot_knowledge_reindex_duration_seconds",
+		Help: "Duration of the last knowledge base reindex",
+	})
+
+	knowledgeSearchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "telegram_bot_knowledge_search_duration_seconds",
+		Help:    "Duration of knowledge base searches, excluding time spent waiting for a slot",
+		Buckets: []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+	})
+
+	knowledgeDocuments = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "telegram_bot_knowledge_documents",
+		Help: "Number of documents in the knowledge base",
+	})
+
 	// Active users gauge
 	activeUsers = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "telegram_bot_active_users",
 		Help: "Number of active users",
 	})
 
-	// Active chats gauge
-	activeChats = promauto.NewGauge(prometheus.GaugeOpts{
+	// Active chats gauge, per bot instance
+	activeChats = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "telegram_bot_active_chats",
 		Help: "Number of active chats",
-	})
+	}, []string{"bot"})
 )
 
 // Metrics provides methods to record metrics
@@ -112,6 +156,31 @@ func (m *Metrics) RecordAIRequest(model, status string, duration time.Duration)
 	aiRequestsTotal.WithLabelValues(model, status).Inc()
 }
 
+// RecordEndpointRateLimited records an AI request refused by an endpoint's rate limit
+func (m *Metrics) RecordEndpointRateLimited(endpoint string) {
+	endpointRateLimited.WithLabelValues(endpoint).Inc()
+}
+
+// RecordAICost records the estimated cost of an AI request
+func (m *Metrics) RecordAICost(model string, cost float64) {
+	aiCostTotal.WithLabelValues(model).Add(cost)
+}
+
+// RecordContextSize records the number of messages sent with an AI request
+func (m *Metrics) RecordContextSize(messages int) {
+	contextMessages.Observe(float64(messages))
+}
+
+// RecordContextTrim records a context trim; reason is "count" (message limit) or "summary"
+func (m *Metrics) RecordContextTrim(reason string) {
+	contextTrims.WithLabelValues(reason).Inc()
+}
+
+// RecordRequestShed records a message refused because the system was busy
+func (m *Metrics) RecordRequestShed() {
+	requestsShed.Inc()
+}
+
 // RecordCacheHit records a cache hit
 func (m *Metrics) RecordCacheHit() {
 	cacheHits.Inc()
@@ -133,14 +202,25 @@ func (m *Metrics) RecordStorageOperation(operation, status string, duration time
 	storageOperationDuration.WithLabelValues(operation).Observe(duration.Seconds())
 }
 
+// RecordKnowledgeReindex records the duration and resulting document count of a reindex
+func (m *Metrics) RecordKnowledgeReindex(duration time.Duration, documents int) {
+	knowledgeReindexDuration.Set(duration.Seconds())
+	knowledgeDocuments.Set(float64(documents))
+}
+
+// RecordKnowledgeSearch records the duration of a knowledge base search
+func (m *Metrics) RecordKnowledgeSearch(duration time.Duration) {
+	knowledgeSearchDuration.Observe(duration.Seconds())
+}
+
 // SetActiveUsers sets the number of active users
 func (m *Metrics) SetActiveUsers(count float64) {
 	activeUsers.Set(count)
 }
 
-// SetActiveChats sets the number of active chats
-func (m *Metrics) SetActiveChats(count float64) {
-	activeChats.Set(count)
+// SetActiveChats sets the number of active chats of a bot instance
+func (m *Metrics) SetActiveChats(bot string, count float64) {
+	activeChats.WithLabelValues(bot).Set(count)
 }
 
 // StartMetricsServer starts the metrics HTTP server
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsSnapshot is the current value of every registered metric, for
+// deployments without a Prometheus scraper
+type MetricsSnapshot struct {
+	Time    time.Time              `json:"time"`
+	Metrics []MetricFamilySnapshot `json:"metrics"`
+}
+
+// MetricFamilySnapshot is one metric with all its label combinations
+type MetricFamilySnapshot struct {
+	Name    string         `json:"name"`
+	Help    string         `json:"help,omitempty"`
+	Type    string         `json:"type"`
+	Samples []MetricSample `json:"samples"`
+}
+
+// MetricSample is the value of one label combination. Histograms and
+// summaries report their sum as the value along with the observation count.
+type MetricSample struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+	Count  uint64            `json:"count,omitempty"`
+}
+
+// GatherSnapshot collects the current metric values from gatherer
+func GatherSnapshot(gatherer prometheus.Gatherer) (*MetricsSnapshot, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	snapshot := &MetricsSnapshot{
+		Time:    time.Now(),
+		Metrics: make([]MetricFamilySnapshot, 0, len(families)),
+	}
+	for _, family := range families {
+		familySnapshot := MetricFamilySnapshot{
+			Name:    family.GetName(),
+			Help:    family.GetHelp(),
+			Type:    strings.ToLower(family.GetType().String()),
+			Samples: make([]MetricSample, 0, len(family.GetMetric())),
+		}
+		for _, metric := range family.GetMetric() {
+			sample := MetricSample{}
+			if pairs := metric.GetLabel(); len(pairs) > 0 {
+				sample.Labels = make(map[string]string, len(pairs))
+				for _, pair := range pairs {
+					sample.Labels[pair.GetName()] = pair.GetValue()
+				}
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				sample.Value = metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				sample.Value = metric.GetGauge().GetValue()
+			case metric.GetHistogram() != nil:
+				sample.Value = metric.GetHistogram().GetSampleSum()
+				sample.Count = metric.GetHistogram().GetSampleCount()
+			case metric.GetSummary() != nil:
+				sample.Value = metric.GetSummary().GetSampleSum()
+				sample.Count = metric.GetSummary().GetSampleCount()
+			case metric.GetUntyped() != nil:
+				sample.Value = metric.GetUntyped().GetValue()
+			}
+			familySnapshot.Samples = append(familySnapshot.Samples, sample)
+		}
+		snapshot.Metrics = append(snapshot.Metrics, familySnapshot)
+	}
+	return snapshot, nil
+}
+
+// WriteMetricsSnapshot writes the current metric values to path as JSON. The
+// file is replaced atomically so readers never see a partial snapshot.
+func WriteMetricsSnapshot(gatherer prometheus.Gatherer, path string) error {
+	snapshot, err := GatherSnapshot(gatherer)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create metrics snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace metrics snapshot: %w", err)
+	}
+	return nil
+}
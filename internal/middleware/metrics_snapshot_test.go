@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// findMetric returns the named metric of a snapshot
+func findMetric(t *testing.T, snapshot *MetricsSnapshot, name string) MetricFamilySnapshot {
+	t.Helper()
+	for _, family := range snapshot.Metrics {
+		if family.Name == name {
+			return family
+		}
+	}
+	t.Fatalf("snapshot misses %s", name)
+	return MetricFamilySnapshot{}
+}
+
+// findSample returns the sample of family whose label has the given value
+func findSample(t *testing.T, family MetricFamilySnapshot, label, value string) MetricSample {
+	t.Helper()
+	for _, sample := range family.Samples {
+		if sample.Labels[label] == value {
+			return sample
+		}
+	}
+	t.Fatalf("%s has no sample with %s=%s: %+v", family.Name, label, value, family.Samples)
+	return MetricSample{}
+}
+
+func TestGatherSnapshot(t *testing.T) {
+	m := NewMetrics()
+	m.RecordMessageReceived("snapshot-test")
+	m.RecordMessageReceived("snapshot-test")
+	m.RecordKnowledgeSearch(250 * time.Millisecond)
+	m.RecordKnowledgeSearch(750 * time.Millisecond)
+	m.SetActiveUsers(3)
+
+	snapshot, err := GatherSnapshot(prometheus.DefaultGatherer)
+	if err != nil {
+		t.Fatalf("GatherSnapshot: %v", err)
+	}
+
+	received := findMetric(t, snapshot, "telegram_bot_messages_received_total")
+	if received.Type != "counter" || received.Help == "" {
+		t.Errorf("messages received is a %q with help %q, want a counter with help", received.Type, received.Help)
+	}
+	if got := findSample(t, received, "chat_type", "snapshot-test").Value; got != 2 {
+		t.Errorf("messages received = %g, want 2", got)
+	}
+
+	searches := findMetric(t, snapshot, "telegram_bot_knowledge_search_duration_seconds")
+	if searches.Type != "histogram" || len(searches.Samples) != 1 {
+		t.Fatalf("knowledge searches = %+v, want one histogram sample", searches)
+	}
+	if got := searches.Samples[0]; got.Count != 2 || got.Value != 1 {
+		t.Errorf("knowledge searches = %d totalling %gs, want 2 totalling 1s", got.Count, got.Value)
+	}
+
+	users := findMetric(t, snapshot, "telegram_bot_active_users")
+	if users.Type != "gauge" || users.Samples[0].Value != 3 {
+		t.Errorf("active users = %+v, want a gauge of 3", users)
+	}
+}
+
+func TestWriteMetricsSnapshot(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_requests_total", Help: "Requests"}, []string{"status"})
+	registry.MustRegister(requests)
+	requests.WithLabelValues("ok").Add(5)
+	requests.WithLabelValues("error").Inc()
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	// An older snapshot is replaced
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMetricsSnapshot(registry, path); err != nil {
+		t.Fatalf("WriteMetricsSnapshot: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot MetricsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("snapshot is not JSON: %v\n%s", err, data)
+	}
+	if snapshot.Time.IsZero() {
+		t.Error("snapshot has no time")
+	}
+	family := findMetric(t, &snapshot, "test_requests_total")
+	if got := findSample(t, family, "status", "ok").Value; got != 5 {
+		t.Errorf("ok requests = %g, want 5", got)
+	}
+	if got := findSample(t, family, "status", "error").Value; got != 1 {
+		t.Errorf("failed requests = %g, want 1", got)
+	}
+
+	// No temporary files are left behind
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("snapshot directory holds %d files, want only the snapshot", len(entries))
+	}
+}
@@ -14,6 +14,23 @@ import (
 type RateLimiter interface {
 	Allow(userID int64) bool
 	Reset(userID int64)
+	// IsLockedOut reports whether the user is temporarily ignored for spamming
+	IsLockedOut(userID int64) bool
+	// SetUserLimit overrides the requests per minute of a user (0 restores the default)
+	SetUserLimit(userID int64, rpm int)
+}
+
+// Defaults used when the lockout is enabled without a window or duration
+const (
+	defaultLockoutWindow   = time.Minute
+	defaultLockoutDuration = 15 * time.Minute
+)
+
+// lockoutState tracks recent rate limit rejections of a user
+type lockoutState struct {
+	rejections  int
+	windowStart time.Time
+	lockedUntil time.Time
 }
 
 // UserRateLimiter implements per-user rate limiting
@@ -25,6 +42,11 @@ type UserRateLimiter struct {
 	burst     int
 	logger    *logrus.Logger
 	cleanupInterval time.Duration
+	lockouts         map[int64]*lockoutState
+	lockoutThreshold int
+	lockoutWindow    time.Duration
+	lockoutDuration  time.Duration
+	overrides        map[int64]int // per-user requests per minute
 }
 
 // NewRateLimiter creates a new rate limiter
@@ -40,6 +62,17 @@ func NewRateLimiter(cfg *config.Config, logger *logrus.Logger) RateLimiter {
 		burst:     cfg.RateLimit.Burst,
 		logger:    logger,
 		cleanupInterval: 1 * time.Hour,
+		lockouts:         make(map[int64]*lockoutState),
+		lockoutThreshold: cfg.RateLimit.LockoutThreshold,
+		lockoutWindow:    cfg.RateLimit.LockoutWindow,
+		lockoutDuration:  cfg.RateLimit.LockoutDuration,
+		overrides:        make(map[int64]int),
+	}
+	if rl.lockoutWindow <= 0 {
+		rl.lockoutWindow = defaultLockoutWindow
+	}
+	if rl.lockoutDuration <= 0 {
+		rl.lockoutDuration = defaultLockoutDuration
 	}
 
 	// Start cleanup goroutine
@@ -54,6 +87,10 @@ func (r *UserRateLimiter) Allow(userID int64) bool {
 		return true
 	}
 
+	if r.IsLockedOut(userID) {
+		return false
+	}
+
 	limiter := r.getLimiter(userID)
 	allowed := limiter.Allow()
 
@@ -61,11 +98,75 @@ func (r *UserRateLimiter) Allow(userID int64) bool {
 		r.logger.WithFields(logrus.Fields{
 			"user_id": userID,
 		}).Warn("Rate limit exceeded")
+		r.recordRejection(userID)
+	} else {
+		r.clearRejections(userID)
 	}
 
 	return allowed
 }
 
+// IsLockedOut reports whether the user is inside a lockout, clearing it once expired
+func (r *UserRateLimiter) IsLockedOut(userID int64) bool {
+	if !r.enabled || r.lockoutThreshold <= 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, exists := r.lockouts[userID]
+	if !exists || state.lockedUntil.IsZero() {
+		return false
+	}
+	if time.Now().Before(state.lockedUntil) {
+		return true
+	}
+
+	delete(r.lockouts, userID)
+	r.logger.WithField("user_id", userID).Info("Rate limit lockout expired")
+	return false
+}
+
+// clearRejections resets the rejection streak after an allowed request
+func (r *UserRateLimiter) clearRejections(userID int64) {
+	if r.lockoutThreshold <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	delete(r.lockouts, userID)
+	r.mu.Unlock()
+}
+
+// recordRejection counts a rate limit rejection and starts a lockout once the
+// user has been rejected lockoutThreshold times within lockoutWindow
+func (r *UserRateLimiter) recordRejection(userID int64) {
+	if r.lockoutThreshold <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	state, exists := r.lockouts[userID]
+	if !exists || now.Sub(state.windowStart) > r.lockoutWindow {
+		state = &lockoutState{windowStart: now}
+		r.lockouts[userID] = state
+	}
+
+	state.rejections++
+	if state.rejections >= r.lockoutThreshold {
+		state.lockedUntil = now.Add(r.lockoutDuration)
+		r.logger.WithFields(logrus.Fields{
+			"user_id":      userID,
+			"rejections":   state.rejections,
+			"locked_until": state.lockedUntil,
+		}).Warn("User locked out for repeatedly exceeding rate limit")
+	}
+}
+
 // Reset resets the rate limiter for a user
 func (r *UserRateLimiter) Reset(userID int64) {
 	if !r.enabled {
@@ -74,6 +175,24 @@ func (r *UserRateLimiter) Reset(userID int64) {
 
 	r.mu.Lock()
 	delete(r.limiters, userID)
+	delete(r.lockouts, userID)
+	r.mu.Unlock()
+}
+
+// SetUserLimit overrides the requests per minute of a user; rpm <= 0 restores
+// the global limit. The user's current limiter is replaced right away.
+func (r *UserRateLimiter) SetUserLimit(userID int64, rpm int) {
+	if !r.enabled {
+		return
+	}
+
+	r.mu.Lock()
+	if rpm > 0 {
+		r.overrides[userID] = rpm
+	} else {
+		delete(r.overrides, userID)
+	}
+	delete(r.limiters, userID)
 	r.mu.Unlock()
 }
 
@@ -97,7 +216,11 @@ func (r *UserRateLimiter) getLimiter(userID int64) *rate.Limiter {
 	}
 
 	// Rate per second = RPM / 60
-	rps := float64(r.rpm) / 60.0
+	rpm := r.rpm
+	if override, ok := r.overrides[userID]; ok {
+		rpm = override
+	}
+	rps := float64(rpm) / 60.0
 	limiter = rate.NewLimiter(rate.Limit(rps), r.burst)
 	r.limiters[userID] = limiter
 
@@ -117,6 +240,13 @@ func (r *UserRateLimiter) cleanup() {
 			r.logger.Warn("Rate limiter map size exceeded threshold, clearing")
 			r.limiters = make(map[int64]*rate.Limiter)
 		}
+		// Drop lockouts that have expired or whose window has passed
+		now := time.Now()
+		for userID, state := range r.lockouts {
+			if now.After(state.lockedUntil) && now.Sub(state.windowStart) > r.lockoutWindow {
+				delete(r.lockouts, userID)
+			}
+		}
 		r.mu.Unlock()
 	}
 }
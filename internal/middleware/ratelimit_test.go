@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestRateLimiter returns a limiter allowing a burst of one request, then
+// rpm requests a minute, locking users out after threshold rejections
+func newTestRateLimiter(rpm, threshold int, window, duration time.Duration) RateLimiter {
+	cfg := &config.Config{}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RequestsPerMinute = rpm
+	cfg.RateLimit.Burst = 1
+	cfg.RateLimit.LockoutThreshold = threshold
+	cfg.RateLimit.LockoutWindow = window
+	cfg.RateLimit.LockoutDuration = duration
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewRateLimiter(cfg, logger)
+}
+
+// reject has userID rejected n times, failing if a request is allowed
+func reject(t *testing.T, rl RateLimiter, userID int64, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if rl.Allow(userID) {
+			t.Fatalf("request %d of user %d allowed, want it rejected", i+1, userID)
+		}
+	}
+}
+
+func TestRateLimitLockout(t *testing.T) {
+	rl := newTestRateLimiter(1, 3, time.Minute, 50*time.Millisecond)
+
+	if !rl.Allow(7) {
+		t.Fatal("first request rejected")
+	}
+	reject(t, rl, 7, 2)
+	if rl.IsLockedOut(7) {
+		t.Fatal("user locked out after 2 rejections, want 3")
+	}
+	reject(t, rl, 7, 1)
+	if !rl.IsLockedOut(7) {
+		t.Fatal("user not locked out after 3 rejections")
+	}
+	// Other users aren't affected
+	if rl.IsLockedOut(8) || !rl.Allow(8) {
+		t.Error("user 8 affected by user 7's lockout")
+	}
+
+	// The lockout clears once it expires
+	time.Sleep(60 * time.Millisecond)
+	if rl.IsLockedOut(7) {
+		t.Error("user still locked out after the lockout expired")
+	}
+	reject(t, rl, 7, 2)
+	if rl.IsLockedOut(7) {
+		t.Error("rejections before the lockout still count after it expired")
+	}
+}
+
+func TestRateLimitLockoutWindow(t *testing.T) {
+	rl := newTestRateLimiter(1, 3, 30*time.Millisecond, time.Hour)
+
+	rl.Allow(7)
+	reject(t, rl, 7, 2)
+	// Rejections spread over more than the window don't add up
+	time.Sleep(40 * time.Millisecond)
+	reject(t, rl, 7, 2)
+	if rl.IsLockedOut(7) {
+		t.Error("user locked out by rejections outside the window")
+	}
+	reject(t, rl, 7, 1)
+	if !rl.IsLockedOut(7) {
+		t.Error("user not locked out after 3 rejections within the window")
+	}
+}
+
+func TestRateLimitLockoutStreakResets(t *testing.T) {
+	// A request every 100ms
+	rl := newTestRateLimiter(600, 3, time.Minute, time.Hour)
+
+	rl.Allow(7)
+	reject(t, rl, 7, 2)
+	time.Sleep(110 * time.Millisecond)
+	// An allowed request ends the streak
+	if !rl.Allow(7) {
+		t.Fatal("request after the wait rejected")
+	}
+	reject(t, rl, 7, 2)
+	if rl.IsLockedOut(7) {
+		t.Error("user locked out by rejections before an allowed request")
+	}
+}
+
+func TestRateLimitLockoutDisabled(t *testing.T) {
+	rl := newTestRateLimiter(1, 0, time.Minute, time.Hour)
+
+	rl.Allow(7)
+	reject(t, rl, 7, 20)
+	if rl.IsLockedOut(7) {
+		t.Error("user locked out with the lockout disabled")
+	}
+}
+
+func TestRateLimitResetClearsLockout(t *testing.T) {
+	rl := newTestRateLimiter(1, 2, time.Minute, time.Hour)
+
+	rl.Allow(7)
+	reject(t, rl, 7, 2)
+	if !rl.IsLockedOut(7) {
+		t.Fatal("user not locked out after 2 rejections")
+	}
+	rl.Reset(7)
+	if rl.IsLockedOut(7) || !rl.Allow(7) {
+		t.Error("user still limited after Reset")
+	}
+}
+
+func TestSetUserLimit(t *testing.T) {
+	rl := newTestRateLimiter(60, 0, time.Minute, time.Minute).(*UserRateLimiter)
+	// A limiter made before the override is replaced
+	rl.Allow(7)
+
+	rl.SetUserLimit(7, 600)
+	if got := rl.getLimiter(7).Limit(); got != 10 {
+		t.Errorf("overridden user's limit = %v a second, want 10", got)
+	}
+	if got := rl.getLimiter(8).Limit(); got != 1 {
+		t.Errorf("other user's limit = %v a second, want the default 1", got)
+	}
+	if rl.getLimiter(7) == rl.getLimiter(8) {
+		t.Error("overridden user shares the default limiter")
+	}
+
+	rl.SetUserLimit(7, 0)
+	if got := rl.getLimiter(7).Limit(); got != 1 {
+		t.Errorf("limit after the reset = %v a second, want the default 1", got)
+	}
+}
+
+func TestSetUserLimitDisabled(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	rl := NewRateLimiter(&config.Config{}, logger)
+
+	rl.SetUserLimit(7, 1)
+	for i := 0; i < 5; i++ {
+		if !rl.Allow(7) {
+			t.Fatalf("request %d rejected with rate limiting disabled", i+1)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -8,24 +9,83 @@ import (
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCallID and Name identify the call a "tool" role message answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
 }
 
+// ContextSchemaVersion is the current layout of persisted chat contexts.
+// Bump it whenever ChatContext changes incompatibly and add a migration step.
+const ContextSchemaVersion = 1
+
 // ChatContext represents a chat's conversation context
 type ChatContext struct {
-	ChatID       int64
-	Messages     []Message
-	LastActivity time.Time
-	Settings     ChatSettings
+	SchemaVersion  int            // 持久化格式版本，0 表示引入版本号之前写入的记录
+	ChatID         int64
+	Messages       []Message
+	LastActivity   time.Time
+	Settings       ChatSettings
+	ReplyIndex     map[int]int    // bot reply message ID -> context length after that reply
+	KnowledgeIndex map[string]int // knowledge document key -> position of the message holding it
 }
 
 // ChatSettings represents per-chat settings
 type ChatSettings struct {
-	ShowThink     bool
-	Model         string
-	SystemPrompt  string
-	Keywords      []string
-	MentionWords  []string // 提及词列表
-	Language      string
+	ShowThink         bool
+	ShowThinkStats    bool     // 隐藏思考内容时附加思考 token 数
+	AIParams          AIParams // 模型、系统提示词与生成参数
+	Keywords          []string
+	MentionWords      []string // 提及词列表
+	Language          string
+	Prefill           string   // 助手回复预填充内容，用于引导输出格式
+	Personality       string   // 机器人性格，为空时使用配置中的默认值
+	LockedModel       string   // 群组锁定的模型，优先于用户选择的模型
+	InactivityMinutes int      // 无活动自动清空上下文的分钟数，0 使用全局配置，负数表示关闭
+	ResponseLanguage  string   // AI 回答使用的语言，与界面语言无关，为空表示不指定
+	ResponseStyle     string   // 回答风格：concise（简洁）或 detailed（详细），为空表示适中
+	Profile           string   // 使用场景名称，决定温度等生成参数
+	MaxResponseChars  int      // 回复最大字符数，超出部分截断，0 表示不限制
+	FileResponseChars int      // 回复超过该字符数时以文件发送，0 使用全局配置，负数表示关闭
+	TimeBudgetSeconds int      // 回复时间预算秒数，超时发送部分回复，0 使用全局配置，负数表示关闭
+	AllowedModels     []string // 允许成员选择的模型 ID，为空表示不限制
+	MinMessageChars   int      // 群聊中提及词触发所需的最少字符数，0 使用全局配置，负数表示关闭
+	HideFooter        bool     // 不附加配置的回复页脚
+	Paused            bool     // 管理员暂停机器人在本聊天中的回复
+	KnowledgeFullDocs int      // 注入完整知识库文档而不按字符数截断，0 使用全局配置，1 开启，-1 关闭
+}
+
+// AIParams bundles the per-chat parameters of AI requests. Unset fields are
+// omitted from the request so the model's and the profile's defaults apply.
+type AIParams struct {
+	Model        string   `json:",omitempty"`
+	SystemPrompt string   `json:",omitempty"`
+	Temperature  *float64 `json:",omitempty"`
+	TopP         *float64 `json:",omitempty"`
+	MaxTokens    int      `json:",omitempty"`
+	Stop         []string `json:",omitempty"`
+}
+
+// UnmarshalJSON reads chat settings, moving the model and system prompt of
+// records written before AIParams existed into the bundle
+func (s *ChatSettings) UnmarshalJSON(data []byte) error {
+	type plain ChatSettings
+	var legacy struct {
+		plain
+		Model        string
+		SystemPrompt string
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*s = ChatSettings(legacy.plain)
+	if s.AIParams.Model == "" {
+		s.AIParams.Model = legacy.Model
+	}
+	if s.AIParams.SystemPrompt == "" {
+		s.AIParams.SystemPrompt = legacy.SystemPrompt
+	}
+	return nil
 }
 
 // UserSettings represents user-specific settings
@@ -37,9 +97,13 @@ type UserSettings struct {
 
 // UserStats represents user statistics
 type UserStats struct {
-	UserID        int64
-	TotalMessages int
-	TotalSessions int
+	UserID           int64
+	TotalMessages    int
+	TotalSessions    int
+	PromptTokens     int
+	CompletionTokens int
+	TotalCost        float64 // Estimated cost, only accumulated for priced models
+	ModelUsage       map[string]int // Successful responses per model ID
 }
 
 // User represents a user with rate limiting info
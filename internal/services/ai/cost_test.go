@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestEstimateCost(t *testing.T) {
+	usage := Usage{PromptTokens: 1500, CompletionTokens: 500, TotalTokens: 2000}
+	tests := []struct {
+		name       string
+		input      float64
+		output     float64
+		wantCost   float64
+		wantPriced bool
+	}{
+		{name: "priced", input: 0.01, output: 0.03, wantCost: 0.03, wantPriced: true},
+		{name: "input only", input: 0.002, wantCost: 0.003, wantPriced: true},
+		{name: "output only", output: 0.06, wantCost: 0.03, wantPriced: true},
+		{name: "unpriced"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &ModelOption{ID: "m", InputPricePer1K: tt.input, OutputPricePer1K: tt.output}
+			cost, priced := model.EstimateCost(usage)
+			if priced != tt.wantPriced || math.Abs(cost-tt.wantCost) > 1e-9 {
+				t.Errorf("EstimateCost = %g, %v, want %g, %v", cost, priced, tt.wantCost, tt.wantPriced)
+			}
+		})
+	}
+}
+
+func TestUsageCaptured(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}],`+
+			`"usage":{"prompt_tokens":1500,"completion_tokens":500,"total_tokens":2000}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "priced", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: "priced-model", InputPricePer1K: 0.01, OutputPricePer1K: 0.03}},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	service := NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger)
+
+	var usage Usage
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+	if _, err := service.GetResponse(context.Background(), messages, "priced-model", WithUsage(&usage)); err != nil {
+		t.Fatalf("GetResponse: %v", err)
+	}
+	if usage.PromptTokens != 1500 || usage.CompletionTokens != 500 || usage.TotalTokens != 2000 {
+		t.Errorf("usage = %+v, want the endpoint's counts", usage)
+	}
+
+	// The configured prices reach the model the cost is estimated with
+	model, err := service.GetModelByID("priced-model")
+	if err != nil {
+		t.Fatalf("GetModelByID: %v", err)
+	}
+	if cost, _ := model.EstimateCost(usage); math.Abs(cost-0.03) > 1e-9 {
+		t.Errorf("cost = %g, want 0.03", cost)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,10 +20,14 @@ import (
 
 // Service represents the AI service interface
 type Service interface {
-	GetResponse(ctx context.Context, messages []models.Message, modelID string) (string, error)
-	GetResponseWithKnowledge(ctx context.Context, messages []models.Message, modelID string, knowledgeService knowledge.Service) (string, error)
+	GetResponse(ctx context.Context, messages []models.Message, modelID string, opts ...RequestOption) (string, error)
+	GetResponseWithKnowledge(ctx context.Context, messages []models.Message, modelID string, knowledgeService knowledge.Service, opts ...RequestOption) (string, error)
 	GetAvailableModels() []ModelOption
+	// GetModelsForUser returns the shared models plus the user's private ones
+	GetModelsForUser(userID int64) []ModelOption
 	GetModelByID(modelID string) (*ModelOption, error)
+	// SetUserKeys makes requests made WithUser use the user's own API keys from store
+	SetUserKeys(store UserKeyStore)
 }
 
 // ModelOption represents a model option with endpoint info
@@ -30,6 +36,44 @@ type ModelOption struct {
 	Name        string
 	EndpointName string
 	MaxTokens   int
+	InputPricePer1K  float64
+	OutputPricePer1K float64
+	// RequestModelID is sent as "model" instead of ID when set
+	RequestModelID string
+	// UseKnowledge overrides whether knowledge is injected for this model; nil follows the global setting
+	UseKnowledge *bool
+	// Owner is the user whose private endpoint serves the model, 0 for shared models
+	Owner int64
+	// Category groups the model in the selection keyboard when grouping by category
+	Category string
+	// Priority is the priority of the model's endpoint; lower values are listed first
+	Priority int
+	// Order is the position of the model in the configuration, breaking priority ties
+	Order int
+}
+
+// sortModels orders models by endpoint priority, then as configured
+func sortModels(models []ModelOption) {
+	sort.SliceStable(models, func(i, j int) bool {
+		if models[i].Priority != models[j].Priority {
+			return models[i].Priority < models[j].Priority
+		}
+		return models[i].Order < models[j].Order
+	})
+}
+
+// VisibleTo reports whether the user may see and use the model
+func (m *ModelOption) VisibleTo(userID int64) bool {
+	return m.Owner == 0 || m.Owner == userID
+}
+
+// UsesKnowledge reports whether requests to this model get knowledge injected,
+// given the global knowledge setting
+func (m *ModelOption) UsesKnowledge(enabled bool) bool {
+	if m.UseKnowledge != nil {
+		return enabled && *m.UseKnowledge
+	}
+	return enabled
 }
 
 // CustomAI implements AI service using custom endpoints
@@ -38,6 +82,8 @@ type CustomAI struct {
 	endpoints  map[string]*config.ModelEndpoint
 	models     map[string]*ModelOption
 	httpClient *http.Client
+	limiters   *endpointLimiters
+	userKeys   UserKeyStore
 	logger     *logrus.Logger
 }
 
@@ -64,8 +110,15 @@ func NewCustomAI(cfg *config.ModelsConfig, logger *logrus.Logger) Service {
 			models[model.ID] = &ModelOption{
 				ID:           model.ID,
 				Name:         model.Name,
-				EndpointName: endpoint.Name,
-				MaxTokens:    model.MaxTokens,
+				EndpointName:     endpoint.Name,
+				MaxTokens:        model.MaxTokens,
+				InputPricePer1K:  model.InputPricePer1K,
+				OutputPricePer1K: model.OutputPricePer1K,
+				RequestModelID:   model.RequestModelID,
+				UseKnowledge:     model.UseKnowledge,
+				Category:         model.Category,
+				Priority:         endpoint.Priority,
+				Order:            len(models),
 			}
 			
 			logger.WithFields(logrus.Fields{
@@ -82,24 +135,34 @@ func NewCustomAI(cfg *config.ModelsConfig, logger *logrus.Logger) Service {
 		config:    cfg,
 		endpoints: endpoints,
 		models:    models,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		httpClient: newHTTPClient(cfg.HTTP),
+		limiters:   newEndpointLimiters(cfg.RateLimitWait),
 		logger: logger,
 	}
 }
 
 // GetResponse gets AI response from the appropriate endpoint with retry logic
-func (s *CustomAI) GetResponse(ctx context.Context, messages []models.Message, modelID string) (string, error) {
-	const maxRetries = 3
+func (s *CustomAI) GetResponse(ctx context.Context, messages []models.Message, modelID string, opts ...RequestOption) (string, error) {
 	var lastErr error
+	options := applyOptions(opts)
+	maxAttempts := options.maxAttempts()
+	ctx, cancel := options.withTimeBudget(ctx)
+	defer cancel()
 	
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		response, err := s.getResponseWithRetry(ctx, messages, modelID, attempt)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		response, usage, err := s.getResponseWithRetry(ctx, messages, modelID, attempt, options)
 		if err == nil {
+			if options.usage != nil && usage != nil {
+				*options.usage = *usage
+			}
 			return response, nil
 		}
 		
+		// A rate limited endpoint only gets busier when retried
+		if errors.Is(err, ErrContentFiltered) || errors.Is(err, ErrEndpointRateLimited) {
+			return "", err
+		}
+		
 		lastErr = err
 		s.logger.WithFields(logrus.Fields{
 			"attempt": attempt,
@@ -107,7 +170,7 @@ func (s *CustomAI) GetResponse(ctx context.Context, messages []models.Message, m
 			"modelID": modelID,
 		}).Warn("AI request failed, retrying...")
 		
-		if attempt < maxRetries {
+		if attempt < maxAttempts {
 			// Exponential backoff: 2s, 4s, 8s
 			waitTime := time.Duration(2<<uint(attempt-1)) * time.Second
 			select {
@@ -123,7 +186,7 @@ func (s *CustomAI) GetResponse(ctx context.Context, messages []models.Message, m
 }
 
 // getResponseWithRetry performs a single request attempt
-func (s *CustomAI) getResponseWithRetry(ctx context.Context, messages []models.Message, modelID string, attempt int) (string, error) {
+func (s *CustomAI) getResponseWithRetry(ctx context.Context, messages []models.Message, modelID string, attempt int, options *requestOptions) (string, *Usage, error) {
 	s.logger.WithFields(logrus.Fields{
 		"modelID": modelID,
 		"attempt": attempt,
@@ -132,13 +195,13 @@ func (s *CustomAI) getResponseWithRetry(ctx context.Context, messages []models.M
 	modelOption, err := s.GetModelByID(modelID)
 	if err != nil {
 		s.logger.WithError(err).WithField("modelID", modelID).Error("Model not found")
-		return "", err
+		return "", nil, err
 	}
 	
 	endpoint, exists := s.endpoints[modelOption.EndpointName]
 	if !exists {
 		s.logger.WithField("endpointName", modelOption.EndpointName).Error("Endpoint not found")
-		return "", fmt.Errorf("endpoint not found: %s", modelOption.EndpointName)
+		return "", nil, fmt.Errorf("endpoint not found: %s", modelOption.EndpointName)
 	}
 	
 	s.logger.WithFields(logrus.Fields{
@@ -148,40 +211,34 @@ func (s *CustomAI) getResponseWithRetry(ctx context.Context, messages []models.M
 		"attempt": attempt,
 	}).Debug("Using endpoint")
 	
-	// Convert messages to OpenAI format
-	openAIMessages := make([]map[string]string, len(messages))
-	for i, msg := range messages {
-		openAIMessages[i] = map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
+	// The endpoint's rate limit protects the shared key
+	endpoint, ownKey := endpointForUser(ctx, s.userKeys, endpoint, modelOption, options, s.logger)
+	if !ownKey {
+		if err := s.limiters.wait(ctx, endpoint); err != nil {
+			return "", nil, err
 		}
 	}
 	
 	// Build request
-	reqBody := map[string]interface{}{
-		"model":       modelID,
-		"messages":    openAIMessages,
-		"max_tokens":  modelOption.MaxTokens,
-		"temperature": 0.7,
-	}
+	reqBody := buildRequestBody(messages, modelOption, endpoint, options)
 	
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return "", nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	logRequestBody(s.logger, jsonData, endpoint, s.config.LogMessageContent)
 	
 	// Create HTTP request with a timeout context for this specific attempt
-	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	reqCtx, cancel := options.attemptContext(ctx)
 	defer cancel()
 	
 	url := fmt.Sprintf("%s/chat/completions", strings.TrimSuffix(endpoint.BaseURL, "/"))
 	req, err := http.NewRequestWithContext(reqCtx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", endpoint.APIKey))
+	setEndpointHeaders(req, endpoint)
 	
 	// Log request
 	s.logger.WithFields(logrus.Fields{
@@ -194,16 +251,12 @@ func (s *CustomAI) getResponseWithRetry(ctx context.Context, messages []models.M
 	// Send request
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 	
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-	
 	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
 		s.logger.WithFields(logrus.Fields{
 			"status":  resp.StatusCode,
 			"body":    string(body),
@@ -212,37 +265,23 @@ func (s *CustomAI) getResponseWithRetry(ctx context.Context, messages []models.M
 		
 		// Don't retry for client errors (4xx)
 		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return "", fmt.Errorf("AI request failed with client error %d: %s", resp.StatusCode, string(body))
+			return "", nil, fmt.Errorf("AI request failed with client error %d: %s", resp.StatusCode, string(body))
 		}
 		
-		return "", fmt.Errorf("AI request failed with status %d: %s", resp.StatusCode, string(body))
+		return "", nil, fmt.Errorf("AI request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 	
-	// Parse response
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Error struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-	
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
-	
-	if result.Error.Message != "" {
-		return "", fmt.Errorf("AI error: %s", result.Error.Message)
+	// Parse response, streamed when the request has a time budget
+	content, usage, err := readResponse(reqCtx, resp.Body, options)
+	if err != nil {
+		return "", nil, err
 	}
 	
-	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
-		return "", fmt.Errorf("no response from AI")
+	if endpoint.SupportsPrefill {
+		content = mergePrefill(options.prefill, content)
 	}
 	
-	return result.Choices[0].Message.Content, nil
+	return content, usage, nil
 }
 
 // GetAvailableModels returns all available models
@@ -251,9 +290,20 @@ func (s *CustomAI) GetAvailableModels() []ModelOption {
 	for _, model := range s.models {
 		models = append(models, *model)
 	}
+	sortModels(models)
 	return models
 }
 
+// GetModelsForUser returns all available models; configured endpoints are shared
+func (s *CustomAI) GetModelsForUser(userID int64) []ModelOption {
+	return s.GetAvailableModels()
+}
+
+// SetUserKeys sets the store of the users' own API keys
+func (s *CustomAI) SetUserKeys(store UserKeyStore) {
+	s.userKeys = store
+}
+
 // GetModelByID returns a model by its ID
 func (s *CustomAI) GetModelByID(modelID string) (*ModelOption, error) {
 	model, exists := s.models[modelID]
@@ -264,10 +314,10 @@ func (s *CustomAI) GetModelByID(modelID string) (*ModelOption, error) {
 }
 
 // GetResponseWithKnowledge gets AI response with knowledge base context
-func (s *CustomAI) GetResponseWithKnowledge(ctx context.Context, messages []models.Message, modelID string, knowledgeService knowledge.Service) (string, error) {
+func (s *CustomAI) GetResponseWithKnowledge(ctx context.Context, messages []models.Message, modelID string, knowledgeService knowledge.Service, opts ...RequestOption) (string, error) {
 	// Extract user's query from the last message
 	if len(messages) == 0 {
-		return s.GetResponse(ctx, messages, modelID)
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 	
 	userQuery := ""
@@ -279,7 +329,7 @@ func (s *CustomAI) GetResponseWithKnowledge(ctx context.Context, messages []mode
 	}
 	
 	if userQuery == "" || knowledgeService == nil {
-		return s.GetResponse(ctx, messages, modelID)
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 	
 	// Search knowledge base
@@ -287,57 +337,30 @@ func (s *CustomAI) GetResponseWithKnowledge(ctx context.Context, messages []mode
 	relevantDocs, err := knowledgeService.SearchDocuments(ctx, userQuery, 3)
 	if err != nil {
 		s.logger.WithError(err).Warn("Failed to search knowledge base")
-		return s.GetResponse(ctx, messages, modelID)
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 	
 	// If no relevant documents found, proceed without knowledge
 	if len(relevantDocs) == 0 {
 		s.logger.Debug("No relevant documents found in knowledge base")
-		return s.GetResponse(ctx, messages, modelID)
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 	
 	// Build knowledge context
-	var knowledgeContext strings.Builder
-	knowledgeContext.WriteString("根据知识库中的相关信息：\n\n")
-	
-	for i, doc := range relevantDocs {
-		knowledgeContext.WriteString(fmt.Sprintf("【文档 %d: %s】\n", i+1, doc.Title))
-		
-		// Include relevant sections
-		content := doc.Content
-		if len(content) > 1000 {
-			// Truncate long content
-			content = content[:1000] + "..."
-		}
-		knowledgeContext.WriteString(content)
-		knowledgeContext.WriteString("\n\n")
+	options := applyOptions(opts)
+	knowledgeContext := prepareKnowledgeContext(relevantDocs, options, s.logger)
+	if knowledgeContext == "" {
+		// Every document was dropped to fit the token budget
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 	
-	knowledgeContext.WriteString("请基于以上知识库信息和对话历史回答用户的问题。如果知识库中没有相关信息，请根据你的知识回答。\n\n")
-	
-	// Create modified messages with knowledge context
-	modifiedMessages := make([]models.Message, 0, len(messages)+1)
-	
-	// Keep system message if exists
-	if len(messages) > 0 && messages[0].Role == "system" {
-		modifiedMessages = append(modifiedMessages, messages[0])
-		modifiedMessages = append(modifiedMessages, models.Message{
-			Role:    "system",
-			Content: knowledgeContext.String(),
-		})
-		modifiedMessages = append(modifiedMessages, messages[1:]...)
-	} else {
-		modifiedMessages = append(modifiedMessages, models.Message{
-			Role:    "system",
-			Content: knowledgeContext.String(),
-		})
-		modifiedMessages = append(modifiedMessages, messages...)
-	}
+	// Place the knowledge where the configured strategy puts it
+	modifiedMessages := injectKnowledge(messages, knowledgeContext, options.knowledgePosition)
 	
 	s.logger.WithFields(logrus.Fields{
 		"docsFound": len(relevantDocs),
 		"modelID":   modelID,
 	}).Info("Sending request with knowledge context")
 	
-	return s.GetResponse(ctx, modifiedMessages, modelID)
+	return s.GetResponse(ctx, modifiedMessages, modelID, opts...)
 }
\ No newline at end of file
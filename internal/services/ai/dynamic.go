@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,19 +24,30 @@ import (
 type DynamicAI struct {
 	configService    *dynamicconfig.DynamicConfigService
 	httpClient       *http.Client
+	limiters         *endpointLimiters
+	userKeys         UserKeyStore
 	logger           *logrus.Logger
 	mu               sync.RWMutex
 	cachedEndpoints  map[string]*config.ModelEndpoint
 	cachedModels     map[string]*ModelOption
+	logContent       bool
 }
 
 // NewDynamicAI creates a new dynamic AI service
 func NewDynamicAI(configService *dynamicconfig.DynamicConfigService, logger *logrus.Logger) Service {
+	ctx := context.Background()
+	cfg, err := configService.GetCurrentConfig(ctx)
+
+	// The transport is built once; its settings aren't changed at runtime
+	var httpConfig config.HTTPClientConfig
+	if err == nil {
+		httpConfig = cfg.Models.HTTP
+	}
+
 	ai := &DynamicAI{
 		configService:   configService,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		httpClient:      newHTTPClient(httpConfig),
+		limiters:        newEndpointLimiters(0),
 		logger:          logger,
 		cachedEndpoints: make(map[string]*config.ModelEndpoint),
 		cachedModels:    make(map[string]*ModelOption),
@@ -46,8 +59,7 @@ func NewDynamicAI(configService *dynamicconfig.DynamicConfigService, logger *log
 	})
 
 	// Initial cache update
-	ctx := context.Background()
-	if cfg, err := configService.GetCurrentConfig(ctx); err == nil {
+	if err == nil {
 		ai.updateCache(cfg)
 	}
 
@@ -56,26 +68,34 @@ func NewDynamicAI(configService *dynamicconfig.DynamicConfigService, logger *log
 
 // updateCache updates the internal cache when config changes
 func (s *DynamicAI) updateCache(cfg *config.Config) {
+	// Private endpoints are read before taking the lock so Redis never blocks requests
+	userEndpoints, err := s.configService.GetUserEndpoints(context.Background())
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to get user endpoints")
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Clear existing cache
 	s.cachedEndpoints = make(map[string]*config.ModelEndpoint)
 	s.cachedModels = make(map[string]*ModelOption)
+	s.logContent = cfg.Models.LogMessageContent
+	s.limiters.setMaxWait(cfg.Models.RateLimitWait)
 
 	// Rebuild cache
 	for i := range cfg.Models.Endpoints {
-		endpoint := &cfg.Models.Endpoints[i]
-		s.cachedEndpoints[endpoint.Name] = endpoint
-
-		for j := range endpoint.Models {
-			model := &endpoint.Models[j]
-			s.cachedModels[model.ID] = &ModelOption{
-				ID:           model.ID,
-				Name:         model.Name,
-				EndpointName: endpoint.Name,
-				MaxTokens:    model.MaxTokens,
-			}
+		s.cacheEndpoint(&cfg.Models.Endpoints[i], 0)
+	}
+	owners := make([]int64, 0, len(userEndpoints))
+	for owner := range userEndpoints {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return owners[i] < owners[j] })
+	for _, owner := range owners {
+		endpoints := userEndpoints[owner]
+		for i := range endpoints {
+			s.cacheEndpoint(&endpoints[i], owner)
 		}
 	}
 
@@ -85,17 +105,74 @@ func (s *DynamicAI) updateCache(cfg *config.Config) {
 	}).Info("AI service cache updated")
 }
 
+// cacheEndpoint adds an endpoint and its models to the cache. Models of a
+// private endpoint are offered under their UserModelID and never replace
+// shared models. Must be called with s.mu held.
+func (s *DynamicAI) cacheEndpoint(endpoint *config.ModelEndpoint, owner int64) {
+	if _, exists := s.cachedEndpoints[endpoint.Name]; exists && owner != 0 {
+		return
+	}
+	s.cachedEndpoints[endpoint.Name] = endpoint
+
+	for j := range endpoint.Models {
+		model := &endpoint.Models[j]
+		option := &ModelOption{
+			ID:               model.ID,
+			Name:             model.Name,
+			EndpointName:     endpoint.Name,
+			MaxTokens:        model.MaxTokens,
+			InputPricePer1K:  model.InputPricePer1K,
+			OutputPricePer1K: model.OutputPricePer1K,
+			RequestModelID:   model.RequestModelID,
+			UseKnowledge:     model.UseKnowledge,
+			Owner:            owner,
+			Category:         model.Category,
+			Priority:         endpoint.Priority,
+			Order:            len(s.cachedModels),
+		}
+		if owner != 0 {
+			option.ID = dynamicconfig.UserModelID(endpoint.Name, model.ID)
+			if option.RequestModelID == "" {
+				option.RequestModelID = model.ID
+			}
+			if _, exists := s.cachedModels[option.ID]; exists {
+				continue
+			}
+		}
+		s.cachedModels[option.ID] = option
+	}
+}
+
 // GetResponse gets AI response with retry logic
-func (s *DynamicAI) GetResponse(ctx context.Context, messages []models.Message, modelID string) (string, error) {
-	const maxRetries = 3
+func (s *DynamicAI) GetResponse(ctx context.Context, messages []models.Message, modelID string, opts ...RequestOption) (string, error) {
 	var lastErr error
+	options := applyOptions(opts)
+	maxAttempts := options.maxAttempts()
+	ctx, cancel := options.withTimeBudget(ctx)
+	defer cancel()
 
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		response, err := s.getResponseWithRetry(ctx, messages, modelID, attempt)
+	// A private endpoint only serves its owner; requests made without a user
+	// come from admin tools
+	if options.userID != 0 {
+		if model, err := s.GetModelByID(modelID); err == nil && !model.VisibleTo(options.userID) {
+			return "", fmt.Errorf("model not found: %s", modelID)
+		}
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		response, usage, err := s.getResponseWithRetry(ctx, messages, modelID, attempt, options)
 		if err == nil {
+			if options.usage != nil && usage != nil {
+				*options.usage = *usage
+			}
 			return response, nil
 		}
 
+		// A rate limited endpoint only gets busier when retried
+		if errors.Is(err, ErrContentFiltered) || errors.Is(err, ErrEndpointRateLimited) {
+			return "", err
+		}
+
 		lastErr = err
 		s.logger.WithFields(logrus.Fields{
 			"attempt": attempt,
@@ -103,7 +180,7 @@ func (s *DynamicAI) GetResponse(ctx context.Context, messages []models.Message,
 			"modelID": modelID,
 		}).Warn("AI request failed, retrying...")
 
-		if attempt < maxRetries {
+		if attempt < maxAttempts {
 			waitTime := time.Duration(2<<uint(attempt-1)) * time.Second
 			select {
 			case <-ctx.Done():
@@ -118,115 +195,119 @@ func (s *DynamicAI) GetResponse(ctx context.Context, messages []models.Message,
 }
 
 // getResponseWithRetry performs a single request attempt
-func (s *DynamicAI) getResponseWithRetry(ctx context.Context, messages []models.Message, modelID string, attempt int) (string, error) {
+func (s *DynamicAI) getResponseWithRetry(ctx context.Context, messages []models.Message, modelID string, attempt int, options *requestOptions) (string, *Usage, error) {
 	s.mu.RLock()
 	modelOption, exists := s.cachedModels[modelID]
 	if !exists {
 		s.mu.RUnlock()
-		return "", fmt.Errorf("model not found: %s", modelID)
+		return "", nil, fmt.Errorf("model not found: %s", modelID)
 	}
 
 	endpoint, exists := s.cachedEndpoints[modelOption.EndpointName]
 	if !exists {
 		s.mu.RUnlock()
-		return "", fmt.Errorf("endpoint not found: %s", modelOption.EndpointName)
+		return "", nil, fmt.Errorf("endpoint not found: %s", modelOption.EndpointName)
 	}
+	logContent := s.logContent
+	userKeys := s.userKeys
 	s.mu.RUnlock()
 
-	// Convert messages to OpenAI format
-	openAIMessages := make([]map[string]string, len(messages))
-	for i, msg := range messages {
-		openAIMessages[i] = map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
+	// The endpoint's rate limit protects the shared key
+	endpoint, ownKey := endpointForUser(ctx, userKeys, endpoint, modelOption, options, s.logger)
+	if !ownKey {
+		if err := s.limiters.wait(ctx, endpoint); err != nil {
+			return "", nil, err
 		}
 	}
 
 	// Build request
-	reqBody := map[string]interface{}{
-		"model":       modelID,
-		"messages":    openAIMessages,
-		"max_tokens":  modelOption.MaxTokens,
-		"temperature": 0.7,
-	}
+	reqBody := buildRequestBody(messages, modelOption, endpoint, options)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return "", nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	logRequestBody(s.logger, jsonData, endpoint, logContent)
 
 	// Create HTTP request
-	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	reqCtx, cancel := options.attemptContext(ctx)
 	defer cancel()
 
 	url := fmt.Sprintf("%s/chat/completions", strings.TrimSuffix(endpoint.BaseURL, "/"))
 	req, err := http.NewRequestWithContext(reqCtx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", endpoint.APIKey))
+	setEndpointHeaders(req, endpoint)
 
 	// Send request
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
 	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
 		// Don't retry for client errors (4xx)
 		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return "", fmt.Errorf("AI request failed with client error %d: %s", resp.StatusCode, string(body))
+			return "", nil, fmt.Errorf("AI request failed with client error %d: %s", resp.StatusCode, string(body))
 		}
-		return "", fmt.Errorf("AI request failed with status %d: %s", resp.StatusCode, string(body))
+		return "", nil, fmt.Errorf("AI request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	// Parse response
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Error struct {
-			Message string `json:"message"`
-		} `json:"error"`
+	// Parse response, streamed when the request has a time budget
+	content, usage, err := readResponse(reqCtx, resp.Body, options)
+	if err != nil {
+		return "", nil, err
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+	if endpoint.SupportsPrefill {
+		content = mergePrefill(options.prefill, content)
 	}
 
-	if result.Error.Message != "" {
-		return "", fmt.Errorf("AI error: %s", result.Error.Message)
-	}
+	return content, usage, nil
+}
 
-	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
-		return "", fmt.Errorf("no response from AI")
-	}
+// GetAvailableModels returns the models shared with all users
+func (s *DynamicAI) GetAvailableModels() []ModelOption {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	return result.Choices[0].Message.Content, nil
+	models := make([]ModelOption, 0, len(s.cachedModels))
+	for _, model := range s.cachedModels {
+		if model.Owner == 0 {
+			models = append(models, *model)
+		}
+	}
+	sortModels(models)
+	return models
 }
 
-// GetAvailableModels returns all available models
-func (s *DynamicAI) GetAvailableModels() []ModelOption {
+// GetModelsForUser returns the shared models plus the models of the user's
+// private endpoints
+func (s *DynamicAI) GetModelsForUser(userID int64) []ModelOption {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	models := make([]ModelOption, 0, len(s.cachedModels))
 	for _, model := range s.cachedModels {
-		models = append(models, *model)
+		if model.VisibleTo(userID) {
+			models = append(models, *model)
+		}
 	}
+	sortModels(models)
 	return models
 }
 
+// SetUserKeys sets the store of the users' own API keys
+func (s *DynamicAI) SetUserKeys(store UserKeyStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userKeys = store
+}
+
 // GetModelByID returns a model by its ID
 func (s *DynamicAI) GetModelByID(modelID string) (*ModelOption, error) {
 	s.mu.RLock()
@@ -240,10 +321,10 @@ func (s *DynamicAI) GetModelByID(modelID string) (*ModelOption, error) {
 }
 
 // GetResponseWithKnowledge gets AI response with knowledge base context
-func (s *DynamicAI) GetResponseWithKnowledge(ctx context.Context, messages []models.Message, modelID string, knowledgeService knowledge.Service) (string, error) {
+func (s *DynamicAI) GetResponseWithKnowledge(ctx context.Context, messages []models.Message, modelID string, knowledgeService knowledge.Service, opts ...RequestOption) (string, error) {
 	// Extract user's query from the last message
 	if len(messages) == 0 {
-		return s.GetResponse(ctx, messages, modelID)
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 
 	userQuery := ""
@@ -255,7 +336,7 @@ func (s *DynamicAI) GetResponseWithKnowledge(ctx context.Context, messages []mod
 	}
 
 	if userQuery == "" || knowledgeService == nil {
-		return s.GetResponse(ctx, messages, modelID)
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 
 	// Search knowledge base
@@ -263,57 +344,30 @@ func (s *DynamicAI) GetResponseWithKnowledge(ctx context.Context, messages []mod
 	relevantDocs, err := knowledgeService.SearchDocuments(ctx, userQuery, 3)
 	if err != nil {
 		s.logger.WithError(err).Warn("Failed to search knowledge base")
-		return s.GetResponse(ctx, messages, modelID)
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 
 	// If no relevant documents found, proceed without knowledge
 	if len(relevantDocs) == 0 {
 		s.logger.Debug("No relevant documents found in knowledge base")
-		return s.GetResponse(ctx, messages, modelID)
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 
 	// Build knowledge context
-	var knowledgeContext strings.Builder
-	knowledgeContext.WriteString("根据知识库中的相关信息：\n\n")
-
-	for i, doc := range relevantDocs {
-		knowledgeContext.WriteString(fmt.Sprintf("【文档 %d: %s】\n", i+1, doc.Title))
-
-		// Include relevant sections
-		content := doc.Content
-		if len(content) > 1000 {
-			// Truncate long content
-			content = content[:1000] + "..."
-		}
-		knowledgeContext.WriteString(content)
-		knowledgeContext.WriteString("\n\n")
+	options := applyOptions(opts)
+	knowledgeContext := prepareKnowledgeContext(relevantDocs, options, s.logger)
+	if knowledgeContext == "" {
+		// Every document was dropped to fit the token budget
+		return s.GetResponse(ctx, messages, modelID, opts...)
 	}
 
-	knowledgeContext.WriteString("请基于以上知识库信息和对话历史回答用户的问题。如果知识库中没有相关信息，请根据你的知识回答。\n\n")
-
-	// Create modified messages with knowledge context
-	modifiedMessages := make([]models.Message, 0, len(messages)+1)
-
-	// Keep system message if exists
-	if len(messages) > 0 && messages[0].Role == "system" {
-		modifiedMessages = append(modifiedMessages, messages[0])
-		modifiedMessages = append(modifiedMessages, models.Message{
-			Role:    "system",
-			Content: knowledgeContext.String(),
-		})
-		modifiedMessages = append(modifiedMessages, messages[1:]...)
-	} else {
-		modifiedMessages = append(modifiedMessages, models.Message{
-			Role:    "system",
-			Content: knowledgeContext.String(),
-		})
-		modifiedMessages = append(modifiedMessages, messages...)
-	}
+	// Place the knowledge where the configured strategy puts it
+	modifiedMessages := injectKnowledge(messages, knowledgeContext, options.knowledgePosition)
 
 	s.logger.WithFields(logrus.Fields{
 		"docsFound": len(relevantDocs),
 		"modelID":   modelID,
 	}).Info("Sending request with knowledge context")
 
-	return s.GetResponse(ctx, modifiedMessages, modelID)
+	return s.GetResponse(ctx, modifiedMessages, modelID, opts...)
 }
\ No newline at end of file
@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestEndpointServer answers every chat completion with "ok", counting requests
+func newTestEndpointServer(t *testing.T) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// newTestDynamicAI returns a service with the shared endpoint "shared" and
+// the private endpoint "mine" of user 7, both served by baseURL
+func newTestDynamicAI(t *testing.T, baseURL string) Service {
+	cfg := &config.Config{}
+	cfg.Models.EndpointVisibility = config.EndpointVisibilityOwner
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "shared", DisplayName: "Shared", BaseURL: baseURL, APIKey: "sk-shared",
+		Models: []config.ModelInfo{{ID: "shared-model"}},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	configService := dynamicconfig.NewDynamicConfigService(nil, cfg, logger)
+	private := &config.ModelEndpoint{
+		Name: "mine", DisplayName: "Mine", BaseURL: baseURL, APIKey: "sk-user-7",
+		Models: []config.ModelInfo{{ID: "my-model"}},
+	}
+	if err := configService.AddEndpoint(context.Background(), 7, private); err != nil {
+		t.Fatalf("AddEndpoint: %v", err)
+	}
+	return NewDynamicAI(configService, logger)
+}
+
+func modelIDs(options []ModelOption) map[string]bool {
+	ids := make(map[string]bool)
+	for _, option := range options {
+		ids[option.ID] = true
+	}
+	return ids
+}
+
+func TestPrivateEndpointsVisibleToOwnerOnly(t *testing.T) {
+	service := newTestDynamicAI(t, "http://127.0.0.1:0")
+	privateID := dynamicconfig.UserModelID("mine", "my-model")
+
+	tests := []struct {
+		name   string
+		models []ModelOption
+		want   bool
+	}{
+		{name: "owner", models: service.GetModelsForUser(7), want: true},
+		{name: "other user", models: service.GetModelsForUser(8), want: false},
+		{name: "shared list", models: service.GetAvailableModels(), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := modelIDs(tt.models)
+			if ids[privateID] != tt.want {
+				t.Errorf("private model listed = %v, want %v (models %v)", ids[privateID], tt.want, ids)
+			}
+			if !ids["shared-model"] {
+				t.Errorf("shared model missing from %v", ids)
+			}
+		})
+	}
+}
+
+func TestPrivateEndpointRequests(t *testing.T) {
+	server, requests := newTestEndpointServer(t)
+	service := newTestDynamicAI(t, server.URL)
+	privateID := dynamicconfig.UserModelID("mine", "my-model")
+	messages := []models.Message{{Role: "user", Content: "hello"}}
+
+	tests := []struct {
+		name    string
+		userID  int64
+		wantErr bool
+	}{
+		{name: "owner", userID: 7},
+		{name: "other user", userID: 8, wantErr: true},
+		{name: "admin tool without a user", userID: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := atomic.LoadInt32(requests)
+			reply, err := service.GetResponse(context.Background(), messages, privateID, WithUser(tt.userID), WithRetries(0))
+			sent := atomic.LoadInt32(requests) - before
+			if tt.wantErr {
+				if err == nil || sent != 0 {
+					t.Errorf("got %q, %v with %d requests sent, want an error and none sent", reply, err, sent)
+				}
+				return
+			}
+			if err != nil || reply != "ok" {
+				t.Errorf("got %q, %v, want ok", reply, err)
+			}
+		})
+	}
+}
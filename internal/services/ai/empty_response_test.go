@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+// newScriptedAI returns a service whose endpoint answers the nth request with
+// bodies[n], repeating the last body, and the number of requests it received
+func newScriptedAI(t *testing.T, bodies ...string) (Service, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1)) - 1
+		if n >= len(bodies) {
+			n = len(bodies) - 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, bodies[n])
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "scripted", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: "scripted-model"}},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger), &requests
+}
+
+func TestContentFilteredNotRetried(t *testing.T) {
+	service, requests := newScriptedAI(t,
+		`{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"content_filter"}]}`,
+		`{"choices":[{"message":{"role":"assistant","content":"answer"}}]}`,
+	)
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	_, err := service.GetResponse(context.Background(), messages, "scripted-model", WithRetries(2))
+	if !errors.Is(err, ErrContentFiltered) {
+		t.Fatalf("error %v, want ErrContentFiltered", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("endpoint got %d requests, want 1", got)
+	}
+}
+
+func TestEmptyResponseRetried(t *testing.T) {
+	service, requests := newScriptedAI(t,
+		`{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"stop"}]}`,
+		`{"choices":[{"message":{"role":"assistant","content":"answer"}}]}`,
+	)
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	answer, err := service.GetResponse(context.Background(), messages, "scripted-model", WithRetries(2))
+	if err != nil {
+		t.Fatalf("GetResponse: %v", err)
+	}
+	if answer != "answer" {
+		t.Errorf("answer = %q, want the retried answer", answer)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("endpoint got %d requests, want 2", got)
+	}
+}
+
+func TestParseChatResponseEmpty(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantFiltered bool
+	}{
+		{name: "content filter", body: `{"choices":[{"message":{"content":""},"finish_reason":"content_filter"}]}`, wantFiltered: true},
+		{name: "empty content", body: `{"choices":[{"message":{"content":""},"finish_reason":"stop"}]}`},
+		{name: "no choices", body: `{"choices":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseChatResponse([]byte(tt.body))
+			if err == nil {
+				t.Fatal("parseChatResponse passed an empty answer")
+			}
+			if errors.Is(err, ErrContentFiltered) != tt.wantFiltered {
+				t.Errorf("error %v, want content filtered = %v", err, tt.wantFiltered)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"golang.org/x/time/rate"
+)
+
+// ErrEndpointRateLimited is returned when an endpoint's request rate is used
+// up and the request couldn't wait for it
+var ErrEndpointRateLimited = errors.New("endpoint rate limit exceeded")
+
+// endpointLimiter is the token bucket of one endpoint together with the
+// settings it was built from, so a config change replaces it
+type endpointLimiter struct {
+	limiter *rate.Limiter
+	rpm     int
+	burst   int
+}
+
+// endpointLimiters throttles requests per endpoint, shared by every user, so
+// the bot stays within the providers' own quotas
+type endpointLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*endpointLimiter
+	maxWait  time.Duration
+}
+
+// newEndpointLimiters creates the limiters; requests queue for up to maxWait
+func newEndpointLimiters(maxWait time.Duration) *endpointLimiters {
+	return &endpointLimiters{
+		limiters: make(map[string]*endpointLimiter),
+		maxWait:  maxWait,
+	}
+}
+
+// setMaxWait changes how long requests may queue
+func (l *endpointLimiters) setMaxWait(maxWait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxWait = maxWait
+}
+
+// get returns the endpoint's limiter, nil when it isn't rate limited, and how
+// long a request may wait for it
+func (l *endpointLimiters) get(endpoint *config.ModelEndpoint) (*rate.Limiter, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if endpoint.RequestsPerMinute <= 0 {
+		delete(l.limiters, endpoint.Name)
+		return nil, 0
+	}
+
+	burst := endpoint.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+
+	existing, ok := l.limiters[endpoint.Name]
+	if !ok || existing.rpm != endpoint.RequestsPerMinute || existing.burst != burst {
+		existing = &endpointLimiter{
+			limiter: rate.NewLimiter(rate.Limit(float64(endpoint.RequestsPerMinute)/60), burst),
+			rpm:     endpoint.RequestsPerMinute,
+			burst:   burst,
+		}
+		l.limiters[endpoint.Name] = existing
+	}
+	return existing.limiter, l.maxWait
+}
+
+// wait blocks until the endpoint may be sent another request. It fails with
+// ErrEndpointRateLimited right away when the wait would exceed the configured
+// maximum, and with the context's error when the caller gives up first.
+func (l *endpointLimiters) wait(ctx context.Context, endpoint *config.ModelEndpoint) error {
+	limiter, maxWait := l.get(endpoint)
+	if limiter == nil {
+		return nil
+	}
+
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+	if delay > maxWait {
+		reservation.Cancel()
+		return fmt.Errorf("%w: %s", ErrEndpointRateLimited, endpoint.Name)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
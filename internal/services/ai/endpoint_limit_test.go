@@ -0,0 +1,142 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+// newLimitedAI returns a service whose endpoint "limited" allows rpm requests
+// a minute with burst, queuing up to maxWait for them
+func newLimitedAI(t *testing.T, baseURL string, rpm, burst int, maxWait time.Duration) *DynamicAI {
+	cfg := &config.Config{}
+	cfg.Models.RateLimitWait = maxWait
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "limited", BaseURL: baseURL, APIKey: "sk-shared",
+		RequestsPerMinute: rpm, Burst: burst,
+		Models: []config.ModelInfo{{ID: "limited-model"}},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger).(*DynamicAI)
+}
+
+func TestEndpointRateLimitThrottles(t *testing.T) {
+	server, requests := newTestEndpointServer(t)
+	// 10 requests a second after a burst of 2
+	service := newLimitedAI(t, server.URL, 600, 2, time.Second)
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		// The limit holds across users
+		if _, err := service.GetResponse(context.Background(), messages, "limited-model", WithUser(int64(i+1))); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	if got := atomic.LoadInt32(requests); got != 5 {
+		t.Errorf("endpoint got %d requests, want 5", got)
+	}
+	// The burst goes through at once, the other 3 wait 100ms each
+	if elapsed < 250*time.Millisecond || elapsed > time.Second {
+		t.Errorf("5 requests took %v, want about 300ms", elapsed)
+	}
+}
+
+func TestEndpointRateLimitFailsFast(t *testing.T) {
+	server, requests := newTestEndpointServer(t)
+	service := newLimitedAI(t, server.URL, 1, 2, 0)
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.GetResponse(context.Background(), messages, "limited-model"); err != nil {
+			t.Fatalf("request %d within the burst: %v", i, err)
+		}
+	}
+
+	start := time.Now()
+	_, err := service.GetResponse(context.Background(), messages, "limited-model", WithRetries(3))
+	if !errors.Is(err, ErrEndpointRateLimited) {
+		t.Fatalf("error %v, want ErrEndpointRateLimited", err)
+	}
+	// Refused without retrying or reaching the endpoint
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("refusal took %v, want right away", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("endpoint got %d requests, want 2", got)
+	}
+}
+
+func TestEndpointRateLimitSkipsUserKeys(t *testing.T) {
+	server, requests := newTestEndpointServer(t)
+	service := newLimitedAI(t, server.URL, 1, 1, 0)
+	service.SetUserKeys(testKeyStore{keys: map[int64]string{7: "sk-user-7"}})
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	// The shared key's only request is used up...
+	if _, err := service.GetResponse(context.Background(), messages, "limited-model", WithUser(8)); err != nil {
+		t.Fatalf("shared key request: %v", err)
+	}
+	if _, err := service.GetResponse(context.Background(), messages, "limited-model", WithUser(8)); !errors.Is(err, ErrEndpointRateLimited) {
+		t.Fatalf("second shared key request: %v, want ErrEndpointRateLimited", err)
+	}
+	// ...but a user's own key has its own quota
+	for i := 0; i < 3; i++ {
+		if _, err := service.GetResponse(context.Background(), messages, "limited-model", WithUser(7)); err != nil {
+			t.Fatalf("own key request %d: %v", i, err)
+		}
+	}
+	if got := atomic.LoadInt32(requests); got != 4 {
+		t.Errorf("endpoint got %d requests, want 4", got)
+	}
+}
+
+func TestEndpointLimitersWait(t *testing.T) {
+	endpoint := &config.ModelEndpoint{Name: "limited", RequestsPerMinute: 60}
+
+	t.Run("unlimited", func(t *testing.T) {
+		limiters := newEndpointLimiters(0)
+		unlimited := &config.ModelEndpoint{Name: "free"}
+		for i := 0; i < 100; i++ {
+			if err := limiters.wait(context.Background(), unlimited); err != nil {
+				t.Fatalf("wait: %v", err)
+			}
+		}
+	})
+
+	t.Run("caller gives up", func(t *testing.T) {
+		limiters := newEndpointLimiters(time.Minute)
+		limiters.wait(context.Background(), endpoint)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := limiters.wait(ctx, endpoint); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("wait = %v, want the context's error", err)
+		}
+	})
+
+	t.Run("new rate replaces the limiter", func(t *testing.T) {
+		limiters := newEndpointLimiters(0)
+		limiters.wait(context.Background(), endpoint)
+		if err := limiters.wait(context.Background(), endpoint); !errors.Is(err, ErrEndpointRateLimited) {
+			t.Fatalf("wait = %v, want ErrEndpointRateLimited", err)
+		}
+
+		raised := *endpoint
+		raised.Burst = 5
+		if err := limiters.wait(context.Background(), &raised); err != nil {
+			t.Fatalf("wait after raising the burst: %v", err)
+		}
+	})
+}
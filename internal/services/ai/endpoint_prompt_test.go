@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+// sentMessages returns the role and content of the messages in a request body
+func sentMessages(body map[string]interface{}) []models.Message {
+	var messages []models.Message
+	list, _ := body["messages"].([]interface{})
+	for _, item := range list {
+		msg, _ := item.(map[string]interface{})
+		role, _ := msg["role"].(string)
+		content, _ := msg["content"].(string)
+		messages = append(messages, models.Message{Role: role, Content: content})
+	}
+	return messages
+}
+
+func TestEndpointSystemPrompt(t *testing.T) {
+	recorder := &bodyRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(recorder.serve))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Models.Endpoints = []config.ModelEndpoint{
+		{
+			Name: "tailored", BaseURL: server.URL, APIKey: "sk-test", SystemPrompt: "Answer concisely.",
+			Models: []config.ModelInfo{{ID: "tailored-model"}},
+		},
+		{
+			Name: "plain", BaseURL: server.URL, APIKey: "sk-test",
+			Models: []config.ModelInfo{{ID: "plain-model"}},
+		},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	service := NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger)
+
+	messages := []models.Message{{Role: "system", Content: "You are a helpful assistant."}, {Role: "user", Content: "hi"}}
+	tests := []struct {
+		modelID string
+		want    []models.Message
+	}{
+		{
+			modelID: "tailored-model",
+			want: []models.Message{
+				{Role: "system", Content: "Answer concisely.\n\nYou are a helpful assistant."},
+				{Role: "user", Content: "hi"},
+			},
+		},
+		{modelID: "plain-model", want: messages},
+	}
+	for _, tt := range tests {
+		t.Run(tt.modelID, func(t *testing.T) {
+			if _, err := service.GetResponse(context.Background(), messages, tt.modelID, WithRetries(0)); err != nil {
+				t.Fatalf("GetResponse: %v", err)
+			}
+			if got := sentMessages(recorder.last()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// The caller's messages are left alone
+	if messages[0].Content != "You are a helpful assistant." {
+		t.Errorf("caller's system prompt became %q", messages[0].Content)
+	}
+}
+
+func TestApplyEndpointPrompt(t *testing.T) {
+	user := models.Message{Role: "user", Content: "hi"}
+	tests := []struct {
+		name     string
+		messages []models.Message
+		prompt   string
+		want     []models.Message
+	}{
+		{name: "no endpoint prompt", messages: []models.Message{user}, want: []models.Message{user}},
+		{
+			name:     "no system message",
+			messages: []models.Message{user},
+			prompt:   "Answer concisely.",
+			want:     []models.Message{{Role: "system", Content: "Answer concisely."}, user},
+		},
+		{
+			name:     "empty system message",
+			messages: []models.Message{{Role: "system"}, user},
+			prompt:   "Answer concisely.",
+			want:     []models.Message{{Role: "system", Content: "Answer concisely."}, user},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyEndpointPrompt(tt.messages, tt.prompt); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyEndpointPrompt = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+// keyRecordingEndpoint answers chat completions with the API key each request
+// was sent with. Requests asking about "slow" wait until release is closed.
+type keyRecordingEndpoint struct {
+	arrived chan struct{}
+	release chan struct{}
+}
+
+func (e *keyRecordingEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	body, _ := io.ReadAll(r.Body)
+	if strings.Contains(string(body), "slow") {
+		close(e.arrived)
+		<-e.release
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"`+key+`"}}]}`)
+}
+
+func TestRotateKeyAppliesToNewRequests(t *testing.T) {
+	endpoint := &keyRecordingEndpoint{arrived: make(chan struct{}), release: make(chan struct{})}
+	server := httptest.NewServer(http.HandlerFunc(endpoint.serve))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "shared", DisplayName: "Shared", BaseURL: server.URL, APIKey: "sk-old",
+		Models: []config.ModelInfo{{ID: "shared-model"}},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	configService := dynamicconfig.NewDynamicConfigService(nil, cfg, logger)
+	service := NewDynamicAI(configService, logger)
+	ctx := context.Background()
+	ask := func(question string) (string, error) {
+		return service.GetResponse(ctx, []models.Message{{Role: "user", Content: question}}, "shared-model", WithRetries(0))
+	}
+
+	if key, err := ask("before"); err != nil || key != "sk-old" {
+		t.Fatalf("request before the rotation used %q, %v, want sk-old", key, err)
+	}
+
+	// A request is in flight while the key is rotated
+	type result struct {
+		key string
+		err error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		key, err := ask("slow")
+		inFlight <- result{key, err}
+	}()
+	<-endpoint.arrived
+
+	if err := configService.RotateKey(ctx, 1, "shared", " sk-new "); err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+	// Listeners refresh their caches in the background
+	deadline := time.Now().Add(time.Second)
+	for {
+		key, err := ask("after")
+		if err != nil {
+			t.Fatalf("request after the rotation: %v", err)
+		}
+		if key == "sk-new" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("requests still use %q a second after the rotation, want sk-new", key)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The request in flight still finishes, with the key it started with
+	close(endpoint.release)
+	if r := <-inFlight; r.err != nil || r.key != "sk-old" {
+		t.Errorf("request in flight used %q, %v, want sk-old", r.key, r.err)
+	}
+}
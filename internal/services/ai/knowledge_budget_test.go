@@ -0,0 +1,171 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+	"github.com/sirupsen/logrus"
+)
+
+// testKnowledge is a knowledge base whose search returns docs in order
+type testKnowledge struct {
+	docs []knowledge.Document
+}
+
+func (k testKnowledge) LoadKnowledgeBase(ctx context.Context, dirs ...string) error { return nil }
+
+func (k testKnowledge) SearchDocuments(ctx context.Context, query string, limit int) ([]knowledge.Document, error) {
+	if len(k.docs) > limit {
+		return k.docs[:limit], nil
+	}
+	return k.docs, nil
+}
+
+func (k testKnowledge) GetAllDocuments() []knowledge.Document { return k.docs }
+
+func (k testKnowledge) GetDocument(id string) (*knowledge.Document, error) { return nil, nil }
+
+func (k testKnowledge) RefreshKnowledgeBase(ctx context.Context) error { return nil }
+
+func (k testKnowledge) ReloadDocument(ctx context.Context, id string) (*knowledge.Document, error) {
+	return nil, nil
+}
+
+// largeDocs returns n documents of about 250 tokens each, best ranked first
+func largeDocs(n int) []knowledge.Document {
+	docs := make([]knowledge.Document, n)
+	for i := range docs {
+		id := string(rune('a' + i))
+		docs[i] = knowledge.Document{ID: id, Title: "doc " + id, Content: strings.Repeat(id, 1000)}
+	}
+	return docs
+}
+
+func TestFitKnowledgeBudget(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		wantIDs   []string
+		trimmed   bool
+	}{
+		{name: "no budget", maxTokens: 0, wantIDs: []string{"a", "b", "c", "d"}},
+		{name: "room for all", maxTokens: 1000, wantIDs: []string{"a", "b", "c", "d"}},
+		{name: "last kept doc trimmed", maxTokens: 600, wantIDs: []string{"a", "b", "c"}, trimmed: true},
+		{name: "remainder too small to fill", maxTokens: 520, wantIDs: []string{"a", "b"}},
+		{name: "no doc fits", maxTokens: 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fitted := fitKnowledgeBudget(largeDocs(4), defaultKnowledgeMaxChars, tt.maxTokens, logger)
+
+			var ids []string
+			tokens := 0
+			for _, doc := range fitted {
+				ids = append(ids, doc.ID)
+				tokens += EstimateTokens(strings.TrimSuffix(doc.Content, knowledgeTruncatedMarker))
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("kept %v, want the best ranked %v", ids, tt.wantIDs)
+			}
+			if tt.maxTokens > 0 && tokens > tt.maxTokens {
+				t.Errorf("kept %d tokens, over the budget of %d", tokens, tt.maxTokens)
+			}
+			if len(fitted) > 0 {
+				last := fitted[len(fitted)-1].Content
+				if got := strings.HasSuffix(last, knowledgeTruncatedMarker); got != tt.trimmed {
+					t.Errorf("last doc trimmed = %v, want %v", got, tt.trimmed)
+				}
+			}
+		})
+	}
+}
+
+// recordingEndpoint answers chat completions with "ok", keeping the messages
+// of the latest request
+type recordingEndpoint struct {
+	mu       sync.Mutex
+	messages []models.Message
+}
+
+func (e *recordingEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Messages []models.Message `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+	e.mu.Lock()
+	e.messages = request.Messages
+	e.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+}
+
+func (e *recordingEndpoint) lastMessages() []models.Message {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.messages
+}
+
+func TestKnowledgeTokenBudget(t *testing.T) {
+	endpoint := &recordingEndpoint{}
+	server := httptest.NewServer(http.HandlerFunc(endpoint.serve))
+	t.Cleanup(server.Close)
+	service := newTestDynamicAI(t, server.URL)
+	kb := testKnowledge{docs: largeDocs(3)}
+	messages := []models.Message{{Role: "system", Content: "prompt"}, {Role: "user", Content: "question"}}
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		position  string
+		wantDocs  []string
+	}{
+		{name: "under budget", maxTokens: 600, position: KnowledgeAfterSystem, wantDocs: []string{"doc a", "doc b", "doc c"}},
+		{name: "lower ranked dropped", maxTokens: 520, position: KnowledgeAfterSystem, wantDocs: []string{"doc a", "doc b"}},
+		{name: "all dropped", maxTokens: 30, position: KnowledgeAfterSystem},
+		{name: "all dropped before the question", maxTokens: 30, position: KnowledgeBeforeLastUser},
+		{name: "all dropped from the prefix", maxTokens: 30, position: KnowledgeUserPrefix},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.GetResponseWithKnowledge(context.Background(), messages, "shared-model", kb,
+				WithKnowledgeMaxTokens(tt.maxTokens), WithKnowledgePosition(tt.position), WithRetries(0))
+			if err != nil {
+				t.Fatalf("GetResponseWithKnowledge: %v", err)
+			}
+			sent := endpoint.lastMessages()
+			if len(tt.wantDocs) == 0 {
+				// Nothing left to inject, the request goes out unchanged
+				if !reflect.DeepEqual(sent, messages) {
+					t.Errorf("sent %+v, want the messages unchanged", sent)
+				}
+				return
+			}
+
+			if len(sent) != 3 {
+				t.Fatalf("sent %d messages, want the knowledge added", len(sent))
+			}
+			injected := sent[1].Content
+			for _, title := range []string{"doc a", "doc b", "doc c"} {
+				want := false
+				for _, kept := range tt.wantDocs {
+					want = want || kept == title
+				}
+				if got := strings.Contains(injected, title); got != want {
+					t.Errorf("%s injected = %v, want %v", title, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+)
+
+func TestKnowledgeFullDocs(t *testing.T) {
+	endpoint := &recordingEndpoint{}
+	server := httptest.NewServer(http.HandlerFunc(endpoint.serve))
+	t.Cleanup(server.Close)
+	service := newTestDynamicAI(t, server.URL)
+	// About 750 tokens, ending in a marker only whole documents carry
+	content := strings.Repeat("x", 2997) + "END"
+	kb := testKnowledge{docs: []knowledge.Document{{ID: "guide", Title: "Guide", Content: content}}}
+	messages := []models.Message{{Role: "system", Content: "prompt"}, {Role: "user", Content: "question"}}
+
+	tests := []struct {
+		name          string
+		full          bool
+		maxTokens     int
+		wantWhole     bool
+		wantTruncated bool
+	}{
+		{name: "cut at the char limit", wantTruncated: true},
+		{name: "cut at the char limit within budget", maxTokens: 2000, wantTruncated: true},
+		{name: "whole without a budget", full: true, wantWhole: true},
+		{name: "whole within budget", full: true, maxTokens: 2000, wantWhole: true},
+		{name: "budget still applies", full: true, maxTokens: 400, wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.GetResponseWithKnowledge(context.Background(), messages, "shared-model", kb,
+				WithKnowledgeMaxChars(1000), WithKnowledgeMaxTokens(tt.maxTokens), WithKnowledgeFullDocs(tt.full), WithRetries(0))
+			if err != nil {
+				t.Fatalf("GetResponseWithKnowledge: %v", err)
+			}
+			sent := endpoint.lastMessages()
+			if len(sent) != 3 {
+				t.Fatalf("sent %d messages, want the knowledge added", len(sent))
+			}
+			injected := sent[1].Content
+			if got := strings.Contains(injected, content); got != tt.wantWhole {
+				t.Errorf("whole document injected = %v, want %v", got, tt.wantWhole)
+			}
+			if got := strings.Contains(injected, knowledgeTruncatedMarker); got != tt.wantTruncated {
+				t.Errorf("document marked truncated = %v, want %v", got, tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestInjectKnowledge(t *testing.T) {
+	system := models.Message{Role: "system", Content: "prompt"}
+	first := models.Message{Role: "user", Content: "first"}
+	answer := models.Message{Role: "assistant", Content: "answer"}
+	last := models.Message{Role: "user", Content: "last"}
+	kb := models.Message{Role: "system", Content: "KB"}
+
+	tests := []struct {
+		name     string
+		messages []models.Message
+		position string
+		want     []models.Message
+	}{
+		{
+			name:     "after the system prompt",
+			messages: []models.Message{system, first, answer, last},
+			position: KnowledgeAfterSystem,
+			want:     []models.Message{system, kb, first, answer, last},
+		},
+		{
+			name:     "empty position keeps it after the system prompt",
+			messages: []models.Message{system, first, answer, last},
+			want:     []models.Message{system, kb, first, answer, last},
+		},
+		{
+			name:     "first without a system prompt",
+			messages: []models.Message{first, answer, last},
+			position: KnowledgeAfterSystem,
+			want:     []models.Message{kb, first, answer, last},
+		},
+		{
+			name:     "before the last user turn",
+			messages: []models.Message{system, first, answer, last},
+			position: KnowledgeBeforeLastUser,
+			want:     []models.Message{system, first, answer, kb, last},
+		},
+		{
+			name:     "prefix of the last user turn",
+			messages: []models.Message{system, first, answer, last},
+			position: KnowledgeUserPrefix,
+			want:     []models.Message{system, first, answer, {Role: "user", Content: "KB\n\nlast"}},
+		},
+		{
+			name:     "before the last user turn without one",
+			messages: []models.Message{system, answer},
+			position: KnowledgeBeforeLastUser,
+			want:     []models.Message{system, kb, answer},
+		},
+		{
+			name:     "user prefix without a user turn",
+			messages: []models.Message{system, answer},
+			position: KnowledgeUserPrefix,
+			want:     []models.Message{system, kb, answer},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]models.Message(nil), tt.messages...)
+
+			got := injectKnowledge(tt.messages, "KB", tt.position)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("injectKnowledge =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			// The caller's messages are left alone
+			if !reflect.DeepEqual(tt.messages, original) {
+				t.Errorf("messages changed to %+v", tt.messages)
+			}
+		})
+	}
+}
+
+func TestKnowledgePosition(t *testing.T) {
+	endpoint := &recordingEndpoint{}
+	server := httptest.NewServer(http.HandlerFunc(endpoint.serve))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "shared", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: "shared-model"}},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	services := map[string]Service{
+		"dynamic": NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger),
+		"custom":  NewCustomAI(&cfg.Models, logger),
+	}
+	kb := testKnowledge{docs: largeDocs(1)}
+	messages := []models.Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "question"},
+	}
+
+	tests := []struct {
+		position string
+		// wantAt is where the knowledge block lands in the request
+		wantAt   int
+		wantRole string
+		wantLen  int
+	}{
+		{position: KnowledgeAfterSystem, wantAt: 1, wantRole: "system", wantLen: 5},
+		{position: KnowledgeBeforeLastUser, wantAt: 3, wantRole: "system", wantLen: 5},
+		{position: KnowledgeUserPrefix, wantAt: 3, wantRole: "user", wantLen: 4},
+	}
+	for name, service := range services {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.position, func(t *testing.T) {
+				_, err := service.GetResponseWithKnowledge(context.Background(), messages, "shared-model", kb,
+					WithKnowledgePosition(tt.position), WithRetries(0))
+				if err != nil {
+					t.Fatalf("GetResponseWithKnowledge: %v", err)
+				}
+				sent := endpoint.lastMessages()
+				if len(sent) != tt.wantLen {
+					t.Fatalf("sent %d messages, want %d: %+v", len(sent), tt.wantLen, sent)
+				}
+				for i, msg := range sent {
+					if got := strings.Contains(msg.Content, "doc a"); got != (i == tt.wantAt) {
+						t.Errorf("message %d (%s) carries the knowledge = %v, want it only in message %d", i, msg.Role, got, tt.wantAt)
+					}
+				}
+				if got := sent[tt.wantAt]; got.Role != tt.wantRole {
+					t.Errorf("knowledge sent as a %s message, want %s", got.Role, tt.wantRole)
+				}
+				if tt.position == KnowledgeUserPrefix && !strings.HasSuffix(sent[3].Content, "\n\nquestion") {
+					t.Errorf("last user turn %q, want the question after the knowledge", sent[3].Content)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+	"github.com/sirupsen/logrus"
+)
+
+func TestBuildKnowledgeContextTruncates(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name     string
+		content  string
+		maxChars int
+		want     string
+	}{
+		{name: "short document", content: "图书馆开放时间", maxChars: 10, want: "图书馆开放时间\n"},
+		{name: "exactly the limit", content: "0123456789", maxChars: 10, want: "0123456789\n"},
+		{name: "cut at the limit", content: "0123456789abc", maxChars: 10, want: "0123456789" + knowledgeTruncatedMarker + "\n"},
+		{name: "counted in characters", content: "图书馆开放时间是早上八点", maxChars: 5, want: "图书馆开放" + knowledgeTruncatedMarker + "\n"},
+		{name: "whole documents", content: strings.Repeat("x", 2000), maxChars: -1, want: strings.Repeat("x", 2000) + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := []knowledge.Document{{ID: "doc", Title: "文档", Content: tt.content}}
+			got := buildKnowledgeContext(docs, tt.maxChars, logger)
+			if !strings.Contains(got, "【文档 1: 文档】\n"+tt.want) {
+				t.Errorf("knowledge context %q, want the document as %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKnowledgeCharLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []RequestOption
+		want int
+	}{
+		{name: "unset", want: defaultKnowledgeMaxChars},
+		{name: "configured", opts: []RequestOption{WithKnowledgeMaxChars(300)}, want: 300},
+		{name: "full documents", opts: []RequestOption{WithKnowledgeMaxChars(300), WithKnowledgeFullDocs(true)}, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyOptions(tt.opts).knowledgeCharLimit(); got != tt.want {
+				t.Errorf("knowledgeCharLimit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKnowledgeMaxCharsHonored(t *testing.T) {
+	endpoint := &recordingEndpoint{}
+	server := httptest.NewServer(http.HandlerFunc(endpoint.serve))
+	t.Cleanup(server.Close)
+	service := newTestDynamicAI(t, server.URL)
+	kb := testKnowledge{docs: []knowledge.Document{
+		{ID: "long", Title: "long", Content: strings.Repeat("a", 40) + strings.Repeat("b", 40)},
+		{ID: "short", Title: "short", Content: "brief"},
+	}}
+	messages := []models.Message{{Role: "system", Content: "prompt"}, {Role: "user", Content: "question"}}
+
+	_, err := service.GetResponseWithKnowledge(context.Background(), messages, "shared-model", kb,
+		WithKnowledgeMaxChars(40), WithKnowledgePosition(KnowledgeAfterSystem), WithRetries(0))
+	if err != nil {
+		t.Fatalf("GetResponseWithKnowledge: %v", err)
+	}
+
+	sent := endpoint.lastMessages()
+	if len(sent) != 3 {
+		t.Fatalf("sent %d messages, want the knowledge added", len(sent))
+	}
+	injected := sent[1].Content
+	// The model is told the long document was cut after 40 characters
+	if !strings.Contains(injected, strings.Repeat("a", 40)+knowledgeTruncatedMarker) || strings.Contains(injected, "ab") {
+		t.Errorf("injected %q, want the long document cut after 40 characters and marked", injected)
+	}
+	if strings.Count(injected, knowledgeTruncatedMarker) != 1 || !strings.Contains(injected, "brief\n") {
+		t.Errorf("injected %q, want the short document whole", injected)
+	}
+}
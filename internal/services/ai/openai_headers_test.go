@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+// headerRecorder answers chat completions and keeps the last request's headers
+type headerRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+func (h *headerRecorder) serve(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.header = r.Header.Clone()
+	h.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+}
+
+func (h *headerRecorder) last() http.Header {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.header
+}
+
+func TestOpenAIHeaders(t *testing.T) {
+	recorder := &headerRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(recorder.serve))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Models.Endpoints = []config.ModelEndpoint{
+		{
+			Name: "scoped", BaseURL: server.URL, APIKey: "sk-scoped",
+			Organization: "org-abc123", Project: "proj_xyz",
+			Models: []config.ModelInfo{{ID: "scoped-model"}},
+		},
+		{
+			Name: "org-only", BaseURL: server.URL, APIKey: "sk-org",
+			Organization: "org-abc123",
+			Models:       []config.ModelInfo{{ID: "org-model"}},
+		},
+		{
+			Name: "plain", BaseURL: server.URL, APIKey: "sk-plain",
+			Models: []config.ModelInfo{{ID: "plain-model"}},
+		},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	services := map[string]Service{
+		"dynamic": NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger),
+		"custom":  NewCustomAI(&cfg.Models, logger),
+	}
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	tests := []struct {
+		model       string
+		wantKey     string
+		wantOrg     string
+		wantProject string
+	}{
+		{model: "scoped-model", wantKey: "sk-scoped", wantOrg: "org-abc123", wantProject: "proj_xyz"},
+		{model: "org-model", wantKey: "sk-org", wantOrg: "org-abc123"},
+		{model: "plain-model", wantKey: "sk-plain"},
+	}
+	for name, service := range services {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.model, func(t *testing.T) {
+				if _, err := service.GetResponse(context.Background(), messages, tt.model, WithRetries(0)); err != nil {
+					t.Fatalf("GetResponse: %v", err)
+				}
+				header := recorder.last()
+				if got := header.Get("Authorization"); got != "Bearer "+tt.wantKey {
+					t.Errorf("Authorization = %q, want the endpoint's key", got)
+				}
+				// Unset IDs leave the headers out entirely
+				for key, want := range map[string]string{"OpenAI-Organization": tt.wantOrg, "OpenAI-Project": tt.wantProject} {
+					values, sent := header[http.CanonicalHeaderKey(key)]
+					if sent != (want != "") || (sent && values[0] != want) {
+						t.Errorf("%s = %q (sent %v), want %q", key, values, sent, want)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPrefill(t *testing.T) {
+	tests := []struct {
+		name         string
+		supports     bool
+		prefill      string
+		reply        string
+		wantAssist   bool
+		wantResponse string
+	}{
+		{name: "continued", supports: true, prefill: "{", reply: `"answer": 42}`, wantAssist: true, wantResponse: `{"answer": 42}`},
+		{name: "repeated by the model", supports: true, prefill: "{", reply: `{"answer": 42}`, wantAssist: true, wantResponse: `{"answer": 42}`},
+		{name: "unsupported endpoint", prefill: "{", reply: `{"answer": 42}`, wantResponse: `{"answer": 42}`},
+		{name: "no prefill", supports: true, reply: "plain", wantResponse: "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []models.Message
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Messages []models.Message `json:"messages"`
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				sent = body.Messages
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": tt.reply}}},
+				})
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := &config.Config{}
+			cfg.Models.Endpoints = []config.ModelEndpoint{{
+				Name: "test", BaseURL: server.URL, APIKey: "sk-test", SupportsPrefill: tt.supports,
+				Models: []config.ModelInfo{{ID: "test-model"}},
+			}}
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			service := NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger)
+
+			messages := []models.Message{{Role: "user", Content: "Answer in JSON"}}
+			response, err := service.GetResponse(context.Background(), messages, "test-model", WithPrefill(tt.prefill))
+			if err != nil {
+				t.Fatalf("GetResponse: %v", err)
+			}
+			if response != tt.wantResponse {
+				t.Errorf("response %q, want %q", response, tt.wantResponse)
+			}
+
+			last := sent[len(sent)-1]
+			if tt.wantAssist {
+				if last.Role != "assistant" || last.Content != tt.prefill || len(sent) != 2 {
+					t.Errorf("sent %+v, want the user turn and the prefill", sent)
+				}
+			} else if last.Role != "user" || len(sent) != 1 {
+				t.Errorf("sent %+v, want the user turn only", sent)
+			}
+		})
+	}
+}
+
+func TestMergePrefill(t *testing.T) {
+	tests := []struct {
+		prefill, response, want string
+	}{
+		{prefill: "", response: "text", want: "text"},
+		{prefill: "{", response: `"a": 1}`, want: `{"a": 1}`},
+		{prefill: "{", response: `{"a": 1}`, want: `{"a": 1}`},
+		{prefill: "Sure:", response: " here", want: "Sure: here"},
+	}
+	for _, tt := range tests {
+		if got := mergePrefill(tt.prefill, tt.response); got != tt.want {
+			t.Errorf("mergePrefill(%q, %q) = %q, want %q", tt.prefill, tt.response, got, tt.want)
+		}
+	}
+}
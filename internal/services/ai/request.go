@@ -0,0 +1,621 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/cf-ai-tgbot-go/internal/services/knowledge"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultKnowledgeMaxChars is used when no knowledge truncation length is set
+	defaultKnowledgeMaxChars = 1000
+
+	// knowledgeTruncatedMarker tells the model a document was cut short
+	knowledgeTruncatedMarker = "...[文档已截断]"
+)
+
+// ErrContentFiltered is returned when the endpoint withheld the answer because
+// of its content filter. Retrying the same request won't help.
+var ErrContentFiltered = errors.New("response blocked by content filter")
+
+// Usage represents token usage reported by an endpoint
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	// ReasoningTokens is the part of CompletionTokens spent on reasoning, if reported
+	ReasoningTokens int
+}
+
+// RequestOption customizes a single AI request
+type RequestOption func(*requestOptions)
+
+// requestOptions holds the per-request settings collected from RequestOption values
+type requestOptions struct {
+	usage              *Usage
+	prefill            string
+	knowledgeMaxChars  int
+	knowledgeMaxTokens int
+	knowledgeFullDocs  bool
+	knowledgePosition  string
+	knowledgeDedup     *KnowledgeDedup
+	temperature        *float64
+	topP               *float64
+	maxTokens          int
+	stop               []string
+	retries            *int
+	timeBudget         time.Duration
+	budgetTruncated    *bool
+	userID             int64
+}
+
+// defaultRetries is how many times a failed request is retried unless overridden
+const defaultRetries = 2
+
+// WithUsage stores the token usage of the successful attempt into u
+func WithUsage(u *Usage) RequestOption {
+	return func(o *requestOptions) {
+		o.usage = u
+	}
+}
+
+// WithPrefill seeds the start of the assistant reply.
+// It is omitted for endpoints that don't support prefilling.
+func WithPrefill(prefill string) RequestOption {
+	return func(o *requestOptions) {
+		o.prefill = prefill
+	}
+}
+
+// WithKnowledgeMaxChars limits how many characters of each knowledge document
+// are injected; longer documents are truncated with a marker
+func WithKnowledgeMaxChars(n int) RequestOption {
+	return func(o *requestOptions) {
+		o.knowledgeMaxChars = n
+	}
+}
+
+// WithKnowledgeFullDocs injects knowledge documents whole, ignoring the
+// per-document character limit; the token budget still applies
+func WithKnowledgeFullDocs(full bool) RequestOption {
+	return func(o *requestOptions) {
+		o.knowledgeFullDocs = full
+	}
+}
+
+// knowledgeCharLimit returns how many characters of each knowledge document
+// to inject, or -1 to inject whole documents
+func (o *requestOptions) knowledgeCharLimit() int {
+	if o.knowledgeFullDocs {
+		return -1
+	}
+	if o.knowledgeMaxChars <= 0 {
+		return defaultKnowledgeMaxChars
+	}
+	return o.knowledgeMaxChars
+}
+
+// WithKnowledgeMaxTokens caps the estimated tokens of all injected knowledge
+// documents together; lower-ranked documents are trimmed or dropped to fit.
+// 0 leaves the total unlimited.
+func WithKnowledgeMaxTokens(n int) RequestOption {
+	return func(o *requestOptions) {
+		o.knowledgeMaxTokens = n
+	}
+}
+
+// WithKnowledgePosition sets where the knowledge context is injected, one of
+// the KnowledgePosition constants; empty keeps it after the system prompt
+func WithKnowledgePosition(position string) RequestOption {
+	return func(o *requestOptions) {
+		o.knowledgePosition = position
+	}
+}
+
+// KnowledgeDedup skips documents a conversation already holds. Seen is filled
+// by the caller; Injected and Context report the documents added by the request
+// so the caller can keep them in the conversation.
+type KnowledgeDedup struct {
+	Seen     map[string]bool
+	Injected []string
+	Context  string
+}
+
+// WithKnowledgeDedup enables knowledge deduplication for the request
+func WithKnowledgeDedup(dedup *KnowledgeDedup) RequestOption {
+	return func(o *requestOptions) {
+		o.knowledgeDedup = dedup
+	}
+}
+
+// KnowledgeDocKey identifies a version of a document, so edited documents are injected again
+func KnowledgeDocKey(doc knowledge.Document) string {
+	return doc.ID + "@" + doc.ModTime.UTC().Format(time.RFC3339Nano)
+}
+
+// WithTemperature sets the sampling temperature of the request
+func WithTemperature(temperature float64) RequestOption {
+	return func(o *requestOptions) {
+		o.temperature = &temperature
+	}
+}
+
+// WithTopP sets the nucleus sampling probability of the request
+func WithTopP(topP float64) RequestOption {
+	return func(o *requestOptions) {
+		o.topP = &topP
+	}
+}
+
+// WithParams applies the sampling parameters of a chat's AIParams bundle;
+// unset parameters keep their current value. The model and system prompt of
+// the bundle are applied by the caller when choosing the model and building
+// the messages.
+func WithParams(params models.AIParams) RequestOption {
+	return func(o *requestOptions) {
+		if params.Temperature != nil {
+			temperature := *params.Temperature
+			o.temperature = &temperature
+		}
+		if params.TopP != nil {
+			topP := *params.TopP
+			o.topP = &topP
+		}
+		if params.MaxTokens > 0 {
+			o.maxTokens = params.MaxTokens
+		}
+		if len(params.Stop) > 0 {
+			o.stop = append([]string(nil), params.Stop...)
+		}
+	}
+}
+
+// WithRetries sets how many times a failed request is retried; 0 fails on the
+// first error, which suits interactive requests the user is waiting on
+func WithRetries(n int) RequestOption {
+	return func(o *requestOptions) {
+		if n < 0 {
+			n = 0
+		}
+		o.retries = &n
+	}
+}
+
+// maxAttempts returns the total number of attempts for the request
+func (o *requestOptions) maxAttempts() int {
+	if o.retries == nil {
+		return defaultRetries + 1
+	}
+	return *o.retries + 1
+}
+
+// applyOptions collects request options
+func applyOptions(opts []RequestOption) *requestOptions {
+	o := &requestOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// Knowledge injection strategies
+const (
+	// KnowledgeAfterSystem adds the knowledge as a system message after the system prompt
+	KnowledgeAfterSystem = "after_system"
+	// KnowledgeBeforeLastUser adds it as a system message right before the latest user turn
+	KnowledgeBeforeLastUser = "before_last_user"
+	// KnowledgeUserPrefix prepends it to the latest user message
+	KnowledgeUserPrefix = "user_prefix"
+)
+
+// injectKnowledge returns a copy of messages carrying the knowledge context at
+// the given position. Without a user turn the knowledge goes after the system prompt.
+func injectKnowledge(messages []models.Message, knowledgeContext string, position string) []models.Message {
+	lastUser := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			lastUser = i
+			break
+		}
+	}
+
+	knowledgeMsg := models.Message{Role: "system", Content: knowledgeContext}
+	result := make([]models.Message, 0, len(messages)+1)
+
+	switch {
+	case position == KnowledgeBeforeLastUser && lastUser >= 0:
+		result = append(result, messages[:lastUser]...)
+		result = append(result, knowledgeMsg)
+		result = append(result, messages[lastUser:]...)
+	case position == KnowledgeUserPrefix && lastUser >= 0:
+		result = append(result, messages...)
+		result[lastUser].Content = knowledgeContext + "\n\n" + messages[lastUser].Content
+	default:
+		if len(messages) > 0 && messages[0].Role == "system" {
+			result = append(result, messages[0], knowledgeMsg)
+			result = append(result, messages[1:]...)
+		} else {
+			result = append(result, knowledgeMsg)
+			result = append(result, messages...)
+		}
+	}
+	return result
+}
+
+// prepareKnowledgeContext builds the knowledge block of a request. With
+// deduplication, documents the conversation already holds are replaced by a
+// short note and only new documents are included and reported back.
+func prepareKnowledgeContext(docs []knowledge.Document, options *requestOptions, logger *logrus.Logger) string {
+	maxChars := options.knowledgeCharLimit()
+	dedup := options.knowledgeDedup
+	if dedup == nil {
+		docs = fitKnowledgeBudget(docs, maxChars, options.knowledgeMaxTokens, logger)
+		if len(docs) == 0 {
+			return ""
+		}
+		return buildKnowledgeContext(docs, maxChars, logger)
+	}
+
+	var fresh []knowledge.Document
+	var seenTitles []string
+	for _, doc := range docs {
+		if dedup.Seen[KnowledgeDocKey(doc)] {
+			seenTitles = append(seenTitles, doc.Title)
+			continue
+		}
+		fresh = append(fresh, doc)
+	}
+
+	// Documents already in the conversation cost nothing, so only new ones count
+	fresh = fitKnowledgeBudget(fresh, maxChars, options.knowledgeMaxTokens, logger)
+	for _, doc := range fresh {
+		dedup.Injected = append(dedup.Injected, KnowledgeDocKey(doc))
+	}
+
+	var knowledgeContext strings.Builder
+	if len(fresh) > 0 {
+		dedup.Context = buildKnowledgeContext(fresh, maxChars, logger)
+		knowledgeContext.WriteString(dedup.Context)
+	}
+	if len(seenTitles) > 0 {
+		knowledgeContext.WriteString(fmt.Sprintf("（参见之前提供的文档：%s）", strings.Join(seenTitles, "、")))
+	}
+	return knowledgeContext.String()
+}
+
+// minKnowledgeDocTokens is the smallest remainder of the budget worth filling
+// with a trimmed document
+const minKnowledgeDocTokens = 50
+
+// fitKnowledgeBudget keeps the documents, in ranking order, whose content fits
+// in maxTokens once truncated to maxChars (negative keeps documents whole).
+// The first document that doesn't fit is trimmed to the remaining budget;
+// documents after it are dropped. maxTokens <= 0 keeps every document.
+func fitKnowledgeBudget(docs []knowledge.Document, maxChars, maxTokens int, logger *logrus.Logger) []knowledge.Document {
+	if maxTokens <= 0 {
+		return docs
+	}
+
+	fitted := make([]knowledge.Document, 0, len(docs))
+	remaining := maxTokens
+	for i, doc := range docs {
+		content := []rune(doc.Content)
+		if maxChars >= 0 && len(content) > maxChars {
+			content = content[:maxChars]
+		}
+		tokens := EstimateTokens(string(content))
+		if tokens <= remaining {
+			fitted = append(fitted, doc)
+			remaining -= tokens
+			continue
+		}
+
+		if remaining >= minKnowledgeDocTokens {
+			doc.Content = TruncateToTokens(string(content), remaining) + knowledgeTruncatedMarker
+			fitted = append(fitted, doc)
+		}
+
+		dropped := make([]string, 0, len(docs)-len(fitted))
+		for _, skipped := range docs[i:] {
+			if len(fitted) > 0 && skipped.ID == fitted[len(fitted)-1].ID {
+				continue
+			}
+			dropped = append(dropped, skipped.ID)
+		}
+		logger.WithFields(logrus.Fields{
+			"maxTokens": maxTokens,
+			"trimmed":   remaining >= minKnowledgeDocTokens,
+			"dropped":   dropped,
+		}).Info("Knowledge context over token budget")
+		break
+	}
+	return fitted
+}
+
+// buildKnowledgeContext formats relevant documents into a system message,
+// truncating each document to maxChars characters (negative keeps them whole)
+func buildKnowledgeContext(docs []knowledge.Document, maxChars int, logger *logrus.Logger) string {
+	var knowledgeContext strings.Builder
+	knowledgeContext.WriteString("根据知识库中的相关信息：\n\n")
+
+	for i, doc := range docs {
+		knowledgeContext.WriteString(fmt.Sprintf("【文档 %d: %s】\n", i+1, doc.Title))
+
+		// Include relevant sections
+		content := []rune(doc.Content)
+		if maxChars >= 0 && len(content) > maxChars {
+			logger.WithFields(logrus.Fields{
+				"doc":      doc.ID,
+				"length":   len(content),
+				"maxChars": maxChars,
+			}).Debug("Truncating knowledge document")
+			knowledgeContext.WriteString(string(content[:maxChars]))
+			knowledgeContext.WriteString(knowledgeTruncatedMarker)
+		} else {
+			knowledgeContext.WriteString(doc.Content)
+		}
+		knowledgeContext.WriteString("\n\n")
+	}
+
+	knowledgeContext.WriteString("请基于以上知识库信息和对话历史回答用户的问题。如果知识库中没有相关信息，请根据你的知识回答。\n\n")
+	return knowledgeContext.String()
+}
+
+// EstimateCost estimates the cost of a request from its usage.
+// The second return value is false when the model has no pricing configured.
+func (m *ModelOption) EstimateCost(usage Usage) (float64, bool) {
+	if m.InputPricePer1K <= 0 && m.OutputPricePer1K <= 0 {
+		return 0, false
+	}
+	cost := float64(usage.PromptTokens)/1000*m.InputPricePer1K +
+		float64(usage.CompletionTokens)/1000*m.OutputPricePer1K
+	return cost, true
+}
+
+// WireID returns the model ID to send to the endpoint
+func (m *ModelOption) WireID() string {
+	if m.RequestModelID != "" {
+		return m.RequestModelID
+	}
+	return m.ID
+}
+
+// toOpenAIMessages converts messages to OpenAI format
+func toOpenAIMessages(messages []models.Message) []map[string]string {
+	openAIMessages := make([]map[string]string, len(messages))
+	for i, msg := range messages {
+		openAIMessages[i] = map[string]string{
+			"role":    msg.Role,
+			"content": msg.Content,
+		}
+		if msg.ToolCallID != "" {
+			openAIMessages[i]["tool_call_id"] = msg.ToolCallID
+		}
+		if msg.Name != "" {
+			openAIMessages[i]["name"] = msg.Name
+		}
+	}
+	return openAIMessages
+}
+
+// applyEndpointPrompt prefixes the leading system message with the endpoint's
+// system prompt, adding a system message when there is none
+func applyEndpointPrompt(messages []models.Message, prompt string) []models.Message {
+	if prompt == "" {
+		return messages
+	}
+
+	if len(messages) > 0 && messages[0].Role == "system" {
+		result := make([]models.Message, len(messages))
+		copy(result, messages)
+		if result[0].Content != "" {
+			prompt += "\n\n" + result[0].Content
+		}
+		result[0].Content = prompt
+		return result
+	}
+
+	result := make([]models.Message, 0, len(messages)+1)
+	result = append(result, models.Message{Role: "system", Content: prompt})
+	return append(result, messages...)
+}
+
+// buildChatMessages converts messages to OpenAI format, applying the endpoint's
+// system prompt and appending the assistant prefill when the endpoint supports it
+func buildChatMessages(messages []models.Message, endpoint *config.ModelEndpoint, options *requestOptions) []map[string]string {
+	openAIMessages := toOpenAIMessages(applyEndpointPrompt(messages, endpoint.SystemPrompt))
+	if options.prefill != "" && endpoint.SupportsPrefill {
+		openAIMessages = append(openAIMessages, map[string]string{
+			"role":    "assistant",
+			"content": options.prefill,
+		})
+	}
+	return openAIMessages
+}
+
+// buildRequestBody builds the chat completion request body. Sampling
+// parameters the request doesn't set are left out, so the endpoint's
+// defaults apply.
+func buildRequestBody(messages []models.Message, model *ModelOption, endpoint *config.ModelEndpoint, options *requestOptions) map[string]interface{} {
+	// A chat may lower the model's token limit but not raise it
+	maxTokens := model.MaxTokens
+	if options.maxTokens > 0 && (maxTokens <= 0 || options.maxTokens < maxTokens) {
+		maxTokens = options.maxTokens
+	}
+
+	reqBody := map[string]interface{}{
+		"model":    model.WireID(),
+		"messages": buildChatMessages(messages, endpoint, options),
+	}
+	if maxTokens > 0 {
+		reqBody["max_tokens"] = maxTokens
+	}
+	if options.temperature != nil {
+		reqBody["temperature"] = *options.temperature
+	}
+	if options.topP != nil {
+		reqBody["top_p"] = *options.topP
+	}
+	if len(options.stop) > 0 {
+		reqBody["stop"] = options.stop
+	}
+	if options.streaming() {
+		reqBody["stream"] = true
+		reqBody["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	return reqBody
+}
+
+// setEndpointHeaders sets the authentication headers of a request to the
+// endpoint, including the optional OpenAI organization and project
+func setEndpointHeaders(req *http.Request, endpoint *config.ModelEndpoint) {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", endpoint.APIKey))
+	if endpoint.Organization != "" {
+		req.Header.Set("OpenAI-Organization", endpoint.Organization)
+	}
+	if endpoint.Project != "" {
+		req.Header.Set("OpenAI-Project", endpoint.Project)
+	}
+}
+
+// redactedAPIKey replaces the endpoint key wherever it shows up in a logged body
+const redactedAPIKey = "[REDACTED]"
+
+// logRequestBody logs the outgoing request body at debug level. The endpoint's
+// API key is always masked; message text is replaced with its length unless
+// logContent is set
+func logRequestBody(logger *logrus.Logger, body []byte, endpoint *config.ModelEndpoint, logContent bool) {
+	if !logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+
+	logged := string(body)
+	if !logContent {
+		logged = redactMessageContent(body)
+	}
+	if endpoint.APIKey != "" {
+		logged = strings.ReplaceAll(logged, endpoint.APIKey, redactedAPIKey)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"endpoint": endpoint.Name,
+		"body":     logged,
+	}).Debug("Outgoing AI request")
+}
+
+// redactMessageContent replaces the text of every message in a request body
+// with its length, keeping roles and the other request parameters readable
+func redactMessageContent(body []byte) string {
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return fmt.Sprintf("[%d bytes]", len(body))
+	}
+
+	messages, _ := decoded["messages"].([]interface{})
+	for _, raw := range messages {
+		message, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch content := message["content"].(type) {
+		case string:
+			message["content"] = redactedLength(content)
+		case []interface{}:
+			// Multimodal content: mask text parts and image data alike
+			for _, rawPart := range content {
+				part, ok := rawPart.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if text, ok := part["text"].(string); ok {
+					part["text"] = redactedLength(text)
+				}
+				if image, ok := part["image_url"].(map[string]interface{}); ok {
+					if url, ok := image["url"].(string); ok {
+						image["url"] = redactedLength(url)
+					}
+				}
+			}
+		}
+	}
+
+	redacted, err := json.Marshal(decoded)
+	if err != nil {
+		return fmt.Sprintf("[%d bytes]", len(body))
+	}
+	return string(redacted)
+}
+
+// redactedLength describes redacted text by its length
+func redactedLength(text string) string {
+	return fmt.Sprintf("[%d chars]", len([]rune(text)))
+}
+
+// mergePrefill joins the prefill with the continuation returned by the model
+func mergePrefill(prefill, response string) string {
+	if prefill == "" || strings.HasPrefix(response, prefill) {
+		return response
+	}
+	return prefill + response
+}
+
+// chatCompletionResponse mirrors the OpenAI chat completion response
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+		CompletionTokensDetails struct {
+			ReasoningTokens int `json:"reasoning_tokens"`
+		} `json:"completion_tokens_details"`
+	} `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// parseChatResponse extracts the answer and usage from a chat completion response body
+func parseChatResponse(body []byte) (string, *Usage, error) {
+	var result chatCompletionResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if result.Error.Message != "" {
+		return "", nil, fmt.Errorf("AI error: %s", result.Error.Message)
+	}
+
+	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
+		if len(result.Choices) > 0 && result.Choices[0].FinishReason == "content_filter" {
+			return "", nil, ErrContentFiltered
+		}
+		// Other empty answers are usually transient and worth retrying
+		return "", nil, fmt.Errorf("no response from AI")
+	}
+
+	usage := &Usage{
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+		TotalTokens:      result.Usage.TotalTokens,
+		ReasoningTokens:  result.Usage.CompletionTokensDetails.ReasoningTokens,
+	}
+
+	return result.Choices[0].Message.Content, usage, nil
+}
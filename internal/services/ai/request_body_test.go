@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// bodyRecorder answers chat completions with "ok", keeping the decoded body
+// of the latest request
+type bodyRecorder struct {
+	mu   sync.Mutex
+	body map[string]interface{}
+}
+
+func (b *bodyRecorder) serve(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	b.mu.Lock()
+	b.body = body
+	b.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+}
+
+func (b *bodyRecorder) last() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.body
+}
+
+func TestParamsInRequestBody(t *testing.T) {
+	recorder := &bodyRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(recorder.serve))
+	t.Cleanup(server.Close)
+	service := newTestDynamicAI(t, server.URL)
+	messages := []models.Message{{Role: "user", Content: "hello"}}
+	temperature, topP, zero := 0.3, 0.9, 0.0
+
+	tests := []struct {
+		name   string
+		params models.AIParams
+		want   map[string]interface{} // sampling fields expected in the body, nothing else
+	}{
+		{name: "nothing set", want: map[string]interface{}{}},
+		{
+			name:   "every parameter",
+			params: models.AIParams{Temperature: &temperature, TopP: &topP, MaxTokens: 256, Stop: []string{"END", "###"}},
+			want: map[string]interface{}{
+				"temperature": 0.3, "top_p": 0.9, "max_tokens": float64(256), "stop": []interface{}{"END", "###"},
+			},
+		},
+		{name: "zero temperature is set", params: models.AIParams{Temperature: &zero}, want: map[string]interface{}{"temperature": 0.0}},
+		{name: "only max tokens", params: models.AIParams{MaxTokens: 100}, want: map[string]interface{}{"max_tokens": float64(100)}},
+		{name: "model and prompt are not sampling fields", params: models.AIParams{Model: "other", SystemPrompt: "be brief"}, want: map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.GetResponse(context.Background(), messages, "shared-model", WithParams(tt.params), WithRetries(0)); err != nil {
+				t.Fatalf("GetResponse: %v", err)
+			}
+			got := make(map[string]interface{})
+			for key, value := range recorder.last() {
+				if key != "model" && key != "messages" {
+					got[key] = value
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestBodyMaxTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		modelLimit int
+		chatLimit  int
+		want       interface{} // nil when left out
+	}{
+		{name: "no limit"},
+		{name: "model limit", modelLimit: 1000, want: 1000},
+		{name: "chat limit", chatLimit: 500, want: 500},
+		{name: "chat lowers the model limit", modelLimit: 1000, chatLimit: 500, want: 500},
+		{name: "chat can't raise the model limit", modelLimit: 1000, chatLimit: 2000, want: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &ModelOption{ID: "model", MaxTokens: tt.modelLimit}
+			options := applyOptions([]RequestOption{WithParams(models.AIParams{MaxTokens: tt.chatLimit})})
+			body := buildRequestBody(nil, model, &config.ModelEndpoint{}, options)
+			if got := body["max_tokens"]; got != tt.want {
+				t.Errorf("max_tokens = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
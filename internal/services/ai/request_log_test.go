@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// loggedBodies returns the bodies of the outgoing requests logged to hook
+func loggedBodies(hook *logtest.Hook) []string {
+	var bodies []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "Outgoing AI request" {
+			bodies = append(bodies, entry.Data["body"].(string))
+		}
+	}
+	return bodies
+}
+
+func TestLogRequestBody(t *testing.T) {
+	endpoint := &config.ModelEndpoint{Name: "gateway", APIKey: "sk-secret"}
+	body := []byte(`{"model":"gpt-4o","user":"sk-secret","messages":[` +
+		`{"role":"system","content":"be brief"},` +
+		`{"role":"user","content":[{"type":"text","text":"what is this"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}]}`)
+
+	t.Run("content redacted", func(t *testing.T) {
+		logger, hook := logtest.NewNullLogger()
+		logger.SetLevel(logrus.DebugLevel)
+		logRequestBody(logger, body, endpoint, false)
+
+		bodies := loggedBodies(hook)
+		if len(bodies) != 1 {
+			t.Fatalf("logged %d bodies, want 1", len(bodies))
+		}
+		logged := bodies[0]
+		for _, secret := range []string{"sk-secret", "be brief", "what is this", "base64,AAAA"} {
+			if strings.Contains(logged, secret) {
+				t.Errorf("logged body %s shows %q", logged, secret)
+			}
+		}
+		for _, kept := range []string{`"model":"gpt-4o"`, `"role":"system"`, `"content":"[8 chars]"`, `"text":"[12 chars]"`, redactedAPIKey} {
+			if !strings.Contains(logged, kept) {
+				t.Errorf("logged body %s misses %s", logged, kept)
+			}
+		}
+	})
+
+	t.Run("content kept", func(t *testing.T) {
+		logger, hook := logtest.NewNullLogger()
+		logger.SetLevel(logrus.DebugLevel)
+		logRequestBody(logger, body, endpoint, true)
+
+		logged := loggedBodies(hook)[0]
+		if strings.Contains(logged, "sk-secret") {
+			t.Errorf("logged body %s shows the API key", logged)
+		}
+		if !strings.Contains(logged, "be brief") || !strings.Contains(logged, "what is this") {
+			t.Errorf("logged body %s, want the message content kept", logged)
+		}
+	})
+
+	t.Run("not logged above debug", func(t *testing.T) {
+		logger, hook := logtest.NewNullLogger()
+		logger.SetLevel(logrus.InfoLevel)
+		logRequestBody(logger, body, endpoint, true)
+		if bodies := loggedBodies(hook); len(bodies) != 0 {
+			t.Errorf("logged %q at info level", bodies)
+		}
+	})
+}
+
+func TestRequestBodyLogged(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc((&bodyRecorder{}).serve))
+	t.Cleanup(server.Close)
+	messages := []models.Message{{Role: "user", Content: "my private question"}}
+
+	for _, logContent := range []bool{false, true} {
+		cfg := &config.Config{}
+		cfg.Models.LogMessageContent = logContent
+		cfg.Models.Endpoints = []config.ModelEndpoint{{
+			Name: "gateway", BaseURL: server.URL, APIKey: "sk-test",
+			Models: []config.ModelInfo{{ID: "gpt-4o"}},
+		}}
+		logger, hook := logtest.NewNullLogger()
+		logger.SetLevel(logrus.DebugLevel)
+		services := map[string]Service{
+			"dynamic": NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger),
+			"custom":  NewCustomAI(&cfg.Models, logger),
+		}
+
+		for name, service := range services {
+			hook.Reset()
+			if _, err := service.GetResponse(context.Background(), messages, "gpt-4o", WithRetries(0)); err != nil {
+				t.Fatalf("%s GetResponse: %v", name, err)
+			}
+			bodies := loggedBodies(hook)
+			if len(bodies) != 1 {
+				t.Fatalf("%s logged %d bodies, want 1", name, len(bodies))
+			}
+			if got := strings.Contains(bodies[0], "my private question"); got != logContent {
+				t.Errorf("%s with log_message_content %v logged the content = %v: %s", name, logContent, got, bodies[0])
+			}
+		}
+	}
+}
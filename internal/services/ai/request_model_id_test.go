@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestModelID(t *testing.T) {
+	recorder := &bodyRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(recorder.serve))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "gateway", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{
+			{ID: "gpt-4o", Name: "GPT-4o", RequestModelID: "openai/gpt-4o"},
+			{ID: "claude", Name: "Claude"},
+		},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	services := map[string]Service{
+		"dynamic": NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger),
+		"custom":  NewCustomAI(&cfg.Models, logger),
+	}
+	messages := []models.Message{{Role: "user", Content: "hello"}}
+
+	for name, service := range services {
+		t.Run(name, func(t *testing.T) {
+			tests := []struct {
+				modelID string
+				want    string
+			}{
+				{modelID: "gpt-4o", want: "openai/gpt-4o"},
+				{modelID: "claude", want: "claude"},
+			}
+			for _, tt := range tests {
+				if _, err := service.GetResponse(context.Background(), messages, tt.modelID, WithRetries(0)); err != nil {
+					t.Fatalf("GetResponse(%s): %v", tt.modelID, err)
+				}
+				if got := recorder.last()["model"]; got != tt.want {
+					t.Errorf("request for %s named model %v, want %s", tt.modelID, got, tt.want)
+				}
+			}
+
+			// Users still see the canonical model
+			model, err := service.GetModelByID("gpt-4o")
+			if err != nil {
+				t.Fatalf("GetModelByID: %v", err)
+			}
+			if model.ID != "gpt-4o" || model.Name != "GPT-4o" {
+				t.Errorf("model shown as %s (%s), want GPT-4o (gpt-4o)", model.Name, model.ID)
+			}
+		})
+	}
+}
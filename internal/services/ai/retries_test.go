@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// emptyAnswer is a response the services retry
+const emptyAnswer = `{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"stop"}]}`
+
+func TestZeroRetriesFailsImmediately(t *testing.T) {
+	service, requests := newScriptedAI(t, emptyAnswer, `{"choices":[{"message":{"role":"assistant","content":"answer"}}]}`)
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	start := time.Now()
+	if _, err := service.GetResponse(context.Background(), messages, "scripted-model", WithRetries(0)); err == nil {
+		t.Fatal("request passed, want the first failure returned")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("failure took %v, want no backoff", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("endpoint got %d requests, want 1", got)
+	}
+}
+
+func TestBackgroundRequestRetried(t *testing.T) {
+	service, requests := newScriptedAI(t, emptyAnswer, `{"choices":[{"message":{"role":"assistant","content":"answer"}}]}`)
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	// Without the option, e.g. for summaries, failures are retried
+	answer, err := service.GetResponse(context.Background(), messages, "scripted-model")
+	if err != nil || answer != "answer" {
+		t.Fatalf("GetResponse = %q, %v, want the retried answer", answer, err)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("endpoint got %d requests, want 2", got)
+	}
+}
+
+func TestRetryBackoffCanceled(t *testing.T) {
+	service, requests := newScriptedAI(t, emptyAnswer)
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := service.GetResponse(ctx, messages, "scripted-model", WithRetries(5)); err != context.DeadlineExceeded {
+		t.Fatalf("error %v, want the context's error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("canceled request took %v, want it to stop waiting", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("endpoint got %d requests, want 1", got)
+	}
+}
+
+func TestMaxAttempts(t *testing.T) {
+	tests := []struct {
+		opts []RequestOption
+		want int
+	}{
+		{want: defaultRetries + 1},
+		{opts: []RequestOption{WithRetries(0)}, want: 1},
+		{opts: []RequestOption{WithRetries(-1)}, want: 1},
+		{opts: []RequestOption{WithRetries(4)}, want: 5},
+	}
+	for _, tt := range tests {
+		if got := applyOptions(tt.opts).maxAttempts(); got != tt.want {
+			t.Errorf("maxAttempts with %d options = %d, want %d", len(tt.opts), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WithTimeBudget limits the whole request, retries included, to budget. The
+// answer is streamed, and when the budget runs out the content received so far
+// is returned instead of an error; truncated, if not nil, reports whether that
+// happened so the caller can tell the user. A budget <= 0 leaves the request
+// unlimited.
+func WithTimeBudget(budget time.Duration, truncated *bool) RequestOption {
+	return func(o *requestOptions) {
+		o.timeBudget = budget
+		o.budgetTruncated = truncated
+	}
+}
+
+// withTimeBudget returns ctx limited to the request's time budget, if any
+func (o *requestOptions) withTimeBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeBudget <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, o.timeBudget)
+}
+
+// attemptContext limits a single attempt to 30 seconds; streamed answers are
+// bounded by the time budget instead, or defaultRequestTimeout without one
+func (o *requestOptions) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !o.streaming() {
+		return context.WithTimeout(ctx, 30*time.Second)
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, defaultRequestTimeout)
+}
+
+// streaming reports whether the answer is requested as a stream
+func (o *requestOptions) streaming() bool {
+	return o.timeBudget > 0
+}
+
+// chatCompletionChunk mirrors a streamed chat completion chunk
+type chatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+		CompletionTokensDetails struct {
+			ReasoningTokens int `json:"reasoning_tokens"`
+		} `json:"completion_tokens_details"`
+	} `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readChatStream accumulates the answer of a streamed chat completion. On a
+// read error the content received so far is returned along with the error.
+func readChatStream(body io.Reader) (string, *Usage, error) {
+	var content strings.Builder
+	usage := &Usage{}
+	filtered := false
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return content.String(), usage, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Error.Message != "" {
+			return content.String(), usage, fmt.Errorf("AI error: %s", chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			if choice.FinishReason == "content_filter" {
+				filtered = true
+			}
+		}
+		if chunk.Usage != nil {
+			usage = &Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+				ReasoningTokens:  chunk.Usage.CompletionTokensDetails.ReasoningTokens,
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return content.String(), usage, fmt.Errorf("failed to read response stream: %w", err)
+	}
+
+	if content.Len() == 0 {
+		if filtered {
+			return "", nil, ErrContentFiltered
+		}
+		return "", nil, fmt.Errorf("no response from AI")
+	}
+	return content.String(), usage, nil
+}
+
+// readResponse reads the answer from a successful response body, streamed or
+// not. A stream cut off by ctx running out still yields the partial content,
+// reported through the truncated flag of WithTimeBudget.
+func readResponse(ctx context.Context, body io.Reader, options *requestOptions) (string, *Usage, error) {
+	if !options.streaming() {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		return parseChatResponse(data)
+	}
+
+	content, usage, err := readChatStream(body)
+	if err != nil && ctx.Err() != nil && strings.TrimSpace(content) != "" {
+		if options.budgetTruncated != nil {
+			*options.budgetTruncated = true
+		}
+		return content, usage, nil
+	}
+	return content, usage, err
+}
@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReadResponseCutByTimeBudget(t *testing.T) {
+	tests := []struct {
+		name          string
+		chunks        string
+		cut           bool
+		wantContent   string
+		wantTruncated bool
+		wantErr       bool
+	}{
+		{
+			name:        "complete",
+			chunks:      "data: {\"choices\":[{\"delta\":{\"content\":\"whole answer\"}}]}\n\ndata: [DONE]\n\n",
+			wantContent: "whole answer",
+		},
+		{
+			name:          "cut short",
+			chunks:        "data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n\n",
+			cut:           true,
+			wantContent:   "partial",
+			wantTruncated: true,
+		},
+		{
+			name:    "cut before any content",
+			cut:     true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			body, writer := io.Pipe()
+			go func(chunks string, cut bool) {
+				io.WriteString(writer, chunks)
+				if cut {
+					// The budget runs out while the model is still writing
+					cancel()
+					writer.CloseWithError(errors.New("connection closed"))
+					return
+				}
+				writer.Close()
+			}(tt.chunks, tt.cut)
+
+			var truncated bool
+			options := applyOptions([]RequestOption{WithTimeBudget(time.Minute, &truncated)})
+			content, _, err := readResponse(ctx, body, options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			// The note for the user is added by the caller, never to the model's words
+			if content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			if truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
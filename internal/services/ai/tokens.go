@@ -0,0 +1,56 @@
+package ai
+
+import (
+	"unicode"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+// latinCharsPerToken is roughly how many characters of Latin-script text make a token
+const latinCharsPerToken = 4
+
+// messageOverheadTokens approximates the role and formatting tokens each chat
+// message costs on top of its content
+const messageOverheadTokens = 4
+
+// EstimateTokens roughly estimates how many tokens text costs. CJK characters
+// are about a token each, other text about four characters per token.
+func EstimateTokens(text string) int {
+	var cjk, other int
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+latinCharsPerToken-1)/latinCharsPerToken
+}
+
+// EstimateMessagesTokens roughly estimates how many tokens a conversation costs
+func EstimateMessagesTokens(messages []models.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += EstimateTokens(msg.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// TruncateToTokens returns the longest prefix of text estimated to fit in maxTokens
+func TruncateToTokens(text string, maxTokens int) string {
+	if EstimateTokens(text) <= maxTokens {
+		return text
+	}
+
+	runes := []rune(text)
+	low, high := 0, len(runes)
+	for low < high {
+		mid := (low + high + 1) / 2
+		if EstimateTokens(string(runes[:mid])) <= maxTokens {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return string(runes[:low])
+}
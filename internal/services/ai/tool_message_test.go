@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	dynamicconfig "github.com/cf-ai-tgbot-go/internal/services/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestToolMessagesSent(t *testing.T) {
+	endpoint := &recordingEndpoint{}
+	server := httptest.NewServer(http.HandlerFunc(endpoint.serve))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Models.Endpoints = []config.ModelEndpoint{{
+		Name: "shared", BaseURL: server.URL, APIKey: "sk-test",
+		Models: []config.ModelInfo{{ID: "shared-model"}},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	services := map[string]Service{
+		"dynamic": NewDynamicAI(dynamicconfig.NewDynamicConfigService(nil, cfg, logger), logger),
+		"custom":  NewCustomAI(&cfg.Models, logger),
+	}
+	tool := models.Message{Role: "tool", Content: `{"condition":"晴","temperature":"25°C"}`, ToolCallID: "call_1", Name: "weather"}
+	messages := []models.Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "user", Content: "今天天气怎么样"},
+		tool,
+		{Role: "user", Content: "适合出门吗"},
+	}
+
+	for name, service := range services {
+		t.Run(name, func(t *testing.T) {
+			if _, err := service.GetResponse(context.Background(), messages, "shared-model", WithRetries(0)); err != nil {
+				t.Fatalf("GetResponse: %v", err)
+			}
+			sent := endpoint.lastMessages()
+			if len(sent) != len(messages) {
+				t.Fatalf("sent %+v, want %d messages", sent, len(messages))
+			}
+			if sent[2] != tool {
+				t.Errorf("tool result sent as %+v, want %+v", sent[2], tool)
+			}
+			// Other messages carry no tool fields
+			if sent[1].ToolCallID != "" || sent[1].Name != "" {
+				t.Errorf("user message sent as %+v", sent[1])
+			}
+		})
+	}
+}
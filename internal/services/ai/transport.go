@@ -0,0 +1,56 @@
+package ai
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+// Defaults for the shared HTTP transport
+const (
+	// defaultRequestTimeout bounds an attempt whose context has no deadline
+	defaultRequestTimeout      = 120 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// newHTTPClient builds the HTTP client shared by all endpoints of a service.
+// Unset values in cfg fall back to the defaults above. The client has no
+// timeout of its own: each attempt is bounded by its context (see
+// attemptContext), so a time budget longer than a fixed timeout isn't cut.
+func newHTTPClient(cfg config.HTTPClientConfig) *http.Client {
+	maxIdleConns := cfg.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	maxIdleConnsPerHost := cfg.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	idleConnTimeout := cfg.IdleConnTimeout
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = defaultIdleConnTimeout
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}
+}
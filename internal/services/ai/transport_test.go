@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+func TestNewHTTPClientTransport(t *testing.T) {
+	tests := []struct {
+		name                string
+		cfg                 config.HTTPClientConfig
+		maxIdleConns        int
+		maxIdleConnsPerHost int
+		idleConnTimeout     time.Duration
+		disableKeepAlives   bool
+	}{
+		{
+			name:                "defaults",
+			maxIdleConns:        defaultMaxIdleConns,
+			maxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+			idleConnTimeout:     defaultIdleConnTimeout,
+		},
+		{
+			name:                "configured",
+			cfg:                 config.HTTPClientConfig{MaxIdleConns: 50, MaxIdleConnsPerHost: 25, IdleConnTimeout: time.Minute, DisableKeepAlives: true},
+			maxIdleConns:        50,
+			maxIdleConnsPerHost: 25,
+			idleConnTimeout:     time.Minute,
+			disableKeepAlives:   true,
+		},
+		{
+			name:                "negative values fall back",
+			cfg:                 config.HTTPClientConfig{MaxIdleConns: -1, MaxIdleConnsPerHost: -1, IdleConnTimeout: -time.Second},
+			maxIdleConns:        defaultMaxIdleConns,
+			maxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+			idleConnTimeout:     defaultIdleConnTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newHTTPClient(tt.cfg)
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("transport is %T, want *http.Transport", client.Transport)
+			}
+			if transport.MaxIdleConns != tt.maxIdleConns {
+				t.Errorf("MaxIdleConns = %d, want %d", transport.MaxIdleConns, tt.maxIdleConns)
+			}
+			if transport.MaxIdleConnsPerHost != tt.maxIdleConnsPerHost {
+				t.Errorf("MaxIdleConnsPerHost = %d, want %d", transport.MaxIdleConnsPerHost, tt.maxIdleConnsPerHost)
+			}
+			if transport.IdleConnTimeout != tt.idleConnTimeout {
+				t.Errorf("IdleConnTimeout = %v, want %v", transport.IdleConnTimeout, tt.idleConnTimeout)
+			}
+			if transport.DisableKeepAlives != tt.disableKeepAlives {
+				t.Errorf("DisableKeepAlives = %v, want %v", transport.DisableKeepAlives, tt.disableKeepAlives)
+			}
+			// Requests are bounded by their context, not a fixed client timeout
+			if client.Timeout != 0 {
+				t.Errorf("client timeout = %v, want none", client.Timeout)
+			}
+		})
+	}
+}
+
+func TestAttemptContextDeadline(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget time.Duration
+		parent time.Duration // deadline of the caller's context, 0 for none
+		want   time.Duration
+	}{
+		{name: "single answer", want: 30 * time.Second},
+		{name: "single answer under a longer deadline", parent: time.Hour, want: 30 * time.Second},
+		{name: "streamed within a long budget", budget: 5 * time.Minute, parent: 5 * time.Minute, want: 5 * time.Minute},
+		{name: "streamed without a deadline", budget: time.Minute, want: defaultRequestTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.parent > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.parent)
+				defer cancel()
+			}
+			options := applyOptions([]RequestOption{WithTimeBudget(tt.budget, nil)})
+
+			attemptCtx, cancel := options.attemptContext(ctx)
+			defer cancel()
+			deadline, ok := attemptCtx.Deadline()
+			if !ok {
+				t.Fatal("attempt has no deadline")
+			}
+			if left := time.Until(deadline); left > tt.want || left < tt.want-time.Second {
+				t.Errorf("attempt deadline in %v, want %v", left, tt.want)
+			}
+		})
+	}
+}
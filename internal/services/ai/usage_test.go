@@ -0,0 +1,42 @@
+package ai
+
+import "testing"
+
+func TestParseChatResponseUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Usage
+	}{
+		{
+			name: "reasoning tokens",
+			body: `{"choices":[{"message":{"role":"assistant","content":"answer"}}],` +
+				`"usage":{"prompt_tokens":100,"completion_tokens":300,"total_tokens":400,"completion_tokens_details":{"reasoning_tokens":120}}}`,
+			want: Usage{PromptTokens: 100, CompletionTokens: 300, TotalTokens: 400, ReasoningTokens: 120},
+		},
+		{
+			name: "no details",
+			body: `{"choices":[{"message":{"role":"assistant","content":"answer"}}],` +
+				`"usage":{"prompt_tokens":100,"completion_tokens":300,"total_tokens":400}}`,
+			want: Usage{PromptTokens: 100, CompletionTokens: 300, TotalTokens: 400},
+		},
+		{
+			name: "no usage",
+			body: `{"choices":[{"message":{"role":"assistant","content":"answer"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, usage, err := parseChatResponse([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("parseChatResponse: %v", err)
+			}
+			if answer != "answer" {
+				t.Errorf("answer = %q, want answer", answer)
+			}
+			if *usage != tt.want {
+				t.Errorf("usage = %+v, want %+v", *usage, tt.want)
+			}
+		})
+	}
+}
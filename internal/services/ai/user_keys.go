@@ -0,0 +1,49 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// UserKeyStore looks up the API keys users registered for shared endpoints
+type UserKeyStore interface {
+	// UserAPIKey returns the user's key for the endpoint, "" when there is none
+	UserAPIKey(ctx context.Context, userID int64, endpoint string) (string, error)
+}
+
+// WithUser makes the request on behalf of the user, so it is sent with the
+// user's own API key for the endpoint when they registered one
+func WithUser(userID int64) RequestOption {
+	return func(o *requestOptions) {
+		o.userID = userID
+	}
+}
+
+// endpointForUser returns the endpoint to send the request of the model to:
+// a copy carrying the requesting user's own key when they registered one, the
+// shared endpoint otherwise. The bool reports whether the user's key is used.
+// A failed lookup falls back to the shared key.
+func endpointForUser(ctx context.Context, store UserKeyStore, endpoint *config.ModelEndpoint, model *ModelOption, options *requestOptions, logger *logrus.Logger) (*config.ModelEndpoint, bool) {
+	// Private endpoints already carry their owner's key
+	if store == nil || options.userID == 0 || model.Owner != 0 {
+		return endpoint, false
+	}
+
+	apiKey, err := store.UserAPIKey(ctx, options.userID, endpoint.Name)
+	if err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"userID":   options.userID,
+			"endpoint": endpoint.Name,
+		}).Warn("Failed to get user API key, using the shared key")
+		return endpoint, false
+	}
+	if apiKey == "" {
+		return endpoint, false
+	}
+
+	userEndpoint := *endpoint
+	userEndpoint.APIKey = apiKey
+	return &userEndpoint, true
+}
@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// testKeyStore holds one key per user, failing lookups for failUser
+type testKeyStore struct {
+	keys     map[int64]string
+	failUser int64
+}
+
+func (s testKeyStore) UserAPIKey(ctx context.Context, userID int64, endpoint string) (string, error) {
+	if userID == s.failUser {
+		return "", errors.New("storage unavailable")
+	}
+	return s.keys[userID], nil
+}
+
+func TestEndpointForUser(t *testing.T) {
+	store := testKeyStore{keys: map[int64]string{7: "sk-user-7"}, failUser: 9}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name    string
+		store   UserKeyStore
+		userID  int64
+		owner   int64
+		wantKey string
+		wantOwn bool
+	}{
+		{name: "user with a key", store: store, userID: 7, wantKey: "sk-user-7", wantOwn: true},
+		{name: "user without a key", store: store, userID: 8, wantKey: "sk-shared"},
+		{name: "failed lookup", store: store, userID: 9, wantKey: "sk-shared"},
+		{name: "no user", store: store, userID: 0, wantKey: "sk-shared"},
+		{name: "private model", store: store, userID: 7, owner: 7, wantKey: "sk-shared"},
+		{name: "keys disabled", store: nil, userID: 7, wantKey: "sk-shared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := &config.ModelEndpoint{Name: "shared", APIKey: "sk-shared"}
+			model := &ModelOption{ID: "shared-model", EndpointName: "shared", Owner: tt.owner}
+			options := applyOptions([]RequestOption{WithUser(tt.userID)})
+
+			got, own := endpointForUser(context.Background(), tt.store, endpoint, model, options, logger)
+			if got.APIKey != tt.wantKey || own != tt.wantOwn {
+				t.Errorf("got key %q (own %v), want %q (own %v)", got.APIKey, own, tt.wantKey, tt.wantOwn)
+			}
+			// The shared endpoint is never changed for everyone
+			if endpoint.APIKey != "sk-shared" {
+				t.Errorf("shared endpoint key changed to %q", endpoint.APIKey)
+			}
+		})
+	}
+}
+
+func TestRequestsUseUserKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	service := newTestDynamicAI(t, server.URL)
+	service.SetUserKeys(testKeyStore{keys: map[int64]string{7: "sk-user-7"}})
+	messages := []models.Message{{Role: "user", Content: "hello"}}
+
+	tests := []struct {
+		name   string
+		userID int64
+		want   string
+	}{
+		{name: "user with a key", userID: 7, want: "Bearer sk-user-7"},
+		{name: "user without a key", userID: 8, want: "Bearer sk-shared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.GetResponse(context.Background(), messages, "shared-model", WithUser(tt.userID), WithRetries(0)); err != nil {
+				t.Fatalf("GetResponse: %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if got := keys[len(keys)-1]; got != tt.want {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
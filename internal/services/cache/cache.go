@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/cf-ai-tgbot-go/internal/config"
@@ -13,11 +15,20 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// Service defines cache operations
+// Service defines cache operations. The scope fingerprints whatever besides
+// the model shaped the answer; answers are only shared within one scope.
 type Service interface {
-	Get(ctx context.Context, question, model string) (string, bool)
-	Set(ctx context.Context, question, model, answer string) error
+	Get(ctx context.Context, question, model, scope string) (string, bool)
+	Set(ctx context.Context, question, model, scope, answer string) error
 	Clear(ctx context.Context) error
+	Stats(ctx context.Context) Stats
+}
+
+// Stats describes the current cache contents
+type Stats struct {
+	Enabled bool
+	Items   int
+	MaxSize int
 }
 
 // Cache implements caching service
@@ -26,6 +37,10 @@ type Cache struct {
 	cache   *cache.Cache
 	logger  *logrus.Logger
 	maxSize int
+	// Questions matching excludePatterns or containing excludeKeywords
+	// (lower-cased) bypass the cache
+	excludePatterns []*regexp.Regexp
+	excludeKeywords []string
 }
 
 // NewCache creates a new cache service
@@ -34,21 +49,52 @@ func NewCache(cfg *config.Config, logger *logrus.Logger) Service {
 		return &Cache{enabled: false}
 	}
 
-	return &Cache{
+	c := &Cache{
 		enabled: true,
 		cache:   cache.New(cfg.Cache.TTL, cfg.Cache.TTL*2),
 		logger:  logger,
 		maxSize: cfg.Cache.MaxSize,
 	}
+	for _, pattern := range cfg.Cache.ExcludePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.WithError(err).WithField("pattern", pattern).Warn("Ignoring invalid cache exclusion pattern")
+			continue
+		}
+		c.excludePatterns = append(c.excludePatterns, re)
+	}
+	for _, keyword := range cfg.Cache.ExcludeKeywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			c.excludeKeywords = append(c.excludeKeywords, keyword)
+		}
+	}
+	return c
+}
+
+// excluded reports whether the question must not be answered from or stored
+// in the cache
+func (c *Cache) excluded(question string) bool {
+	for _, re := range c.excludePatterns {
+		if re.MatchString(question) {
+			return true
+		}
+	}
+	lower := strings.ToLower(question)
+	for _, keyword := range c.excludeKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
 }
 
 // Get retrieves a cached response
-func (c *Cache) Get(ctx context.Context, question, model string) (string, bool) {
-	if !c.enabled {
+func (c *Cache) Get(ctx context.Context, question, model, scope string) (string, bool) {
+	if !c.enabled || c.excluded(question) {
 		return "", false
 	}
 
-	key := c.generateKey(question, model)
+	key := c.generateKey(question, model, scope)
 	if val, found := c.cache.Get(key); found {
 		entry := val.(*models.CacheEntry)
 		c.logger.WithFields(logrus.Fields{
@@ -63,10 +109,14 @@ func (c *Cache) Get(ctx context.Context, question, model string) (string, bool)
 }
 
 // Set stores a response in cache
-func (c *Cache) Set(ctx context.Context, question, model, answer string) error {
+func (c *Cache) Set(ctx context.Context, question, model, scope, answer string) error {
 	if !c.enabled {
 		return nil
 	}
+	if c.excluded(question) {
+		c.logger.WithField("model", model).Debug("Question excluded from cache")
+		return nil
+	}
 
 	// Check cache size
 	if c.cache.ItemCount() >= c.maxSize {
@@ -74,7 +124,7 @@ func (c *Cache) Set(ctx context.Context, question, model, answer string) error {
 		c.cache.DeleteExpired()
 	}
 
-	key := c.generateKey(question, model)
+	key := c.generateKey(question, model, scope)
 	entry := &models.CacheEntry{
 		Question:  question,
 		Answer:    answer,
@@ -102,9 +152,22 @@ func (c *Cache) Clear(ctx context.Context) error {
 	return nil
 }
 
+// Stats returns the number of cached entries and the configured limit
+func (c *Cache) Stats(ctx context.Context) Stats {
+	if !c.enabled {
+		return Stats{}
+	}
+
+	return Stats{
+		Enabled: true,
+		Items:   c.cache.ItemCount(),
+		MaxSize: c.maxSize,
+	}
+}
+
 // generateKey creates a unique cache key
-func (c *Cache) generateKey(question, model string) string {
-	data := fmt.Sprintf("%s:%s", model, question)
+func (c *Cache) generateKey(question, model, scope string) string {
+	data := fmt.Sprintf("%s:%s:%s", model, scope, question)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
\ No newline at end of file
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestCache(patterns, keywords []string) Service {
+	cfg := &config.Config{}
+	cfg.Cache = config.CacheConfig{
+		Enabled:         true,
+		TTL:             time.Hour,
+		MaxSize:         100,
+		ExcludePatterns: patterns,
+		ExcludeKeywords: keywords,
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewCache(cfg, logger)
+}
+
+func TestCacheExclusions(t *testing.T) {
+	c := newTestCache(
+		[]string{`(?i)\btoday\b`, `^\d{4}年`, `[invalid`},
+		[]string{" Weather ", "现在", ""},
+	)
+	tests := []struct {
+		question string
+		excluded bool
+	}{
+		{question: "What is the capital of France?"},
+		{question: "What happened today?", excluded: true},
+		{question: "TODAY's news", excluded: true},
+		{question: "Is todayish a word?"},
+		{question: "2024年发生了什么", excluded: true},
+		{question: "发生在2024年的事"},
+		{question: "How is the WEATHER in Paris", excluded: true},
+		{question: "现在几点了", excluded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.question, func(t *testing.T) {
+			ctx := context.Background()
+			if err := c.Set(ctx, tt.question, "model", "scope", "answer"); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			_, found := c.Get(ctx, tt.question, "model", "scope")
+			if found == tt.excluded {
+				t.Errorf("cached = %v, want excluded %v", found, tt.excluded)
+			}
+		})
+	}
+
+	// Excluded questions are not even stored, and the invalid pattern is skipped
+	if stats := c.Stats(context.Background()); stats.Items != 3 {
+		t.Errorf("cache holds %d items, want the 3 questions not excluded", stats.Items)
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(nil, nil)
+	if err := c.Set(ctx, "question", "model", "scope", "answer"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	tests := []struct {
+		name     string
+		question string
+		model    string
+		scope    string
+		found    bool
+	}{
+		{name: "same", question: "question", model: "model", scope: "scope", found: true},
+		{name: "other question", question: "other", model: "model", scope: "scope"},
+		{name: "other model", question: "question", model: "other", scope: "scope"},
+		{name: "other scope", question: "question", model: "model", scope: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, found := c.Get(ctx, tt.question, tt.model, tt.scope); found != tt.found {
+				t.Errorf("found = %v, want %v", found, tt.found)
+			}
+		})
+	}
+}
+
+func TestDisabledCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(&config.Config{}, nil)
+	if err := c.Set(ctx, "question", "model", "scope", "answer"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, found := c.Get(ctx, "question", "model", "scope"); found {
+		t.Error("disabled cache answered")
+	}
+	if stats := c.Stats(ctx); stats.Enabled {
+		t.Errorf("stats = %+v, want disabled", stats)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(nil, nil)
+
+	if got := c.Stats(ctx); got != (Stats{Enabled: true, MaxSize: 100}) {
+		t.Errorf("empty cache stats = %+v", got)
+	}
+	for _, question := range []string{"one", "two", "three", "one"} {
+		if err := c.Set(ctx, question, "model", "scope", "answer"); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	// The repeated question replaced its entry
+	if got := c.Stats(ctx); got != (Stats{Enabled: true, Items: 3, MaxSize: 100}) {
+		t.Errorf("stats = %+v, want 3 items", got)
+	}
+
+	if err := c.Clear(ctx); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if got := c.Stats(ctx).Items; got != 0 {
+		t.Errorf("%d items after Clear, want 0", got)
+	}
+	if _, ok := c.Get(ctx, "one", "model", "scope"); ok {
+		t.Error("cleared answer still cached")
+	}
+}
+
+func TestCacheStatsDisabled(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := NewCache(&config.Config{}, logger)
+
+	if got := c.Stats(context.Background()); got != (Stats{}) {
+		t.Errorf("disabled cache stats = %+v, want none", got)
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+func TestGetCurrentConfigWhileAdding(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityGlobal)
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				current, err := s.GetCurrentConfig(ctx)
+				if err != nil {
+					t.Errorf("GetCurrentConfig: %v", err)
+					return
+				}
+				// Callers iterate and even modify their snapshot freely
+				for i := range current.Models.Endpoints {
+					for j := range current.Models.Endpoints[i].Models {
+						current.Models.Endpoints[i].Models[j].Name = "changed by a reader"
+					}
+				}
+				current.Models.Endpoints = append(current.Models.Endpoints, testEndpoint("reader"))
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		endpoint := testEndpoint(fmt.Sprintf("added-%d", i), "model")
+		if err := s.AddEndpoint(ctx, 1, &endpoint); err != nil {
+			t.Errorf("AddEndpoint: %v", err)
+		}
+	}
+	close(done)
+	readers.Wait()
+
+	current, err := s.GetCurrentConfig(ctx)
+	if err != nil {
+		t.Fatalf("GetCurrentConfig: %v", err)
+	}
+	if len(current.Models.Endpoints) != 11 {
+		t.Errorf("got %d endpoints, want base and the 10 added", len(current.Models.Endpoints))
+	}
+	for _, endpoint := range current.Models.Endpoints {
+		if endpoint.Name == "reader" {
+			t.Error("an endpoint appended to a snapshot reached the configuration")
+		}
+		for _, model := range endpoint.Models {
+			if model.Name != "" {
+				t.Errorf("model %s of %s renamed to %q through a snapshot", model.ID, endpoint.Name, model.Name)
+			}
+		}
+	}
+}
+
+func TestListenersCallBackDuringNotification(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityGlobal)
+
+	const adds = 5
+	notified := make(chan struct{}, adds)
+	// The listener reads the config and registers another listener while
+	// being notified, both of which take the service's lock
+	s.RegisterConfigChangeListener(func(cfg *config.Config) {
+		if _, err := s.GetCurrentConfig(ctx); err != nil {
+			t.Errorf("GetCurrentConfig: %v", err)
+		}
+		s.RegisterConfigChangeListener(func(*config.Config) {})
+		notified <- struct{}{}
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		var adders sync.WaitGroup
+		for i := 0; i < adds; i++ {
+			adders.Add(1)
+			go func(i int) {
+				defer adders.Done()
+				endpoint := testEndpoint(fmt.Sprintf("added-%d", i), "model")
+				if err := s.AddEndpoint(ctx, 1, &endpoint); err != nil {
+					t.Errorf("AddEndpoint: %v", err)
+				}
+			}(i)
+		}
+		adders.Wait()
+		for i := 0; i < adds; i++ {
+			<-notified
+		}
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("adding endpoints and notifying listeners deadlocked")
+	}
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/cf-ai-tgbot-go/internal/config"
@@ -11,58 +13,104 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// Fallback limits used when the config leaves them unset
+	defaultMaxDynamicEndpoints  = 20
+	defaultMaxModelsPerEndpoint = 50
+
+	maxNameLength    = 64
+	maxModelIDLength = 128
+)
+
+// ErrConfigLocked is returned by configuration changes while models.safe_mode is on
+var ErrConfigLocked = errors.New("configuration is locked by safe mode")
+
 // DynamicConfigService manages runtime configuration changes
 type DynamicConfigService struct {
 	redis      *redis.Client
+	local      *localEndpoints // used instead of Redis when there is none
 	baseConfig *config.Config
 	logger     *logrus.Logger
 	mu         sync.RWMutex
 	listeners  []func(*config.Config)
 }
 
-// NewDynamicConfigService creates a new dynamic config service
+// NewDynamicConfigService creates a new dynamic config service. Without a
+// Redis client, endpoints added at runtime are kept in memory only.
 func NewDynamicConfigService(redisClient *redis.Client, baseConfig *config.Config, logger *logrus.Logger) *DynamicConfigService {
-	return &DynamicConfigService{
+	s := &DynamicConfigService{
 		redis:      redisClient,
 		baseConfig: baseConfig,
 		logger:     logger,
 		listeners:  make([]func(*config.Config), 0),
 	}
+	if redisClient == nil {
+		s.local = newLocalEndpoints()
+		logger.Warn("No Redis storage, endpoints added at runtime will be lost on restart")
+	}
+	return s
 }
 
-// GetCurrentConfig returns the current configuration with dynamic updates
+// GetCurrentConfig returns the current configuration with dynamic updates.
+// The returned config owns its endpoint slices, so callers may iterate it
+// while endpoints are being added concurrently.
 func (s *DynamicConfigService) GetCurrentConfig(ctx context.Context) (*config.Config, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	if s.SafeMode() {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		currentConfig := *s.baseConfig
+		currentConfig.Models.Endpoints = copyEndpoints(s.baseConfig.Models.Endpoints)
+		return &currentConfig, nil
+	}
 
-	// Get dynamic endpoints from Redis
+	// Read Redis before taking the lock so slow I/O never blocks writers
 	dynamicEndpoints, err := s.getDynamicEndpoints(ctx)
+
+	s.mu.RLock()
+	// Create a copy of base config
+	currentConfig := *s.baseConfig
+	currentConfig.Models.Endpoints = copyEndpoints(s.baseConfig.Models.Endpoints)
+	s.mu.RUnlock()
+
 	if err != nil {
 		s.logger.WithError(err).Warn("Failed to get dynamic endpoints, using base config")
-		return s.baseConfig, nil
+		return &currentConfig, nil
 	}
 
-	// Create a copy of base config
-	currentConfig := *s.baseConfig
-	
-	// Merge dynamic endpoints with base endpoints
-	if len(dynamicEndpoints) > 0 {
-		// Combine base endpoints with dynamic ones
-		currentConfig.Models.Endpoints = append(s.baseConfig.Models.Endpoints, dynamicEndpoints...)
+	// Merge dynamic endpoints with base endpoints; a dynamic endpoint with the
+	// name of a base endpoint overrides it (e.g. after a key rotation)
+	for _, dynamicEndpoint := range dynamicEndpoints {
+		replaced := false
+		for i := range currentConfig.Models.Endpoints {
+			if currentConfig.Models.Endpoints[i].Name == dynamicEndpoint.Name {
+				currentConfig.Models.Endpoints[i] = dynamicEndpoint
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			currentConfig.Models.Endpoints = append(currentConfig.Models.Endpoints, dynamicEndpoint)
+		}
 	}
 
 	return &currentConfig, nil
 }
 
-// AddEndpoint adds a new endpoint dynamically
-func (s *DynamicConfigService) AddEndpoint(ctx context.Context, endpoint *config.ModelEndpoint) error {
+// AddEndpoint adds a new endpoint dynamically on behalf of userID. When
+// endpoints are private to their owner, endpoints added by non-admins are only
+// visible to that user.
+func (s *DynamicConfigService) AddEndpoint(ctx context.Context, userID int64, endpoint *config.ModelEndpoint) error {
+	if s.SafeMode() {
+		return ErrConfigLocked
+	}
 	// Validate endpoint
 	if err := s.validateEndpoint(endpoint); err != nil {
 		return fmt.Errorf("invalid endpoint: %w", err)
 	}
 
 	// Get existing dynamic endpoints
-	endpoints, err := s.getDynamicEndpoints(ctx)
+	owner := s.endpointOwner(userID)
+	endpoints, err := s.getEndpoints(ctx, owner)
 	if err != nil && err != redis.Nil {
 		return err
 	}
@@ -74,24 +122,112 @@ func (s *DynamicConfigService) AddEndpoint(ctx context.Context, endpoint *config
 		}
 	}
 
+	// Model IDs of private endpoints are derived from the endpoint name, so names
+	// must be unique across all users
+	taken, err := s.endpointNameTaken(ctx, endpoint.Name, owner != 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("endpoint with name '%s' already exists", endpoint.Name)
+	}
+
+	// Enforce limits
+	if maxEndpoints := s.maxDynamicEndpoints(); len(endpoints) >= maxEndpoints {
+		return fmt.Errorf("too many endpoints (max %d)", maxEndpoints)
+	}
+	if maxModels := s.maxModelsPerEndpoint(); len(endpoint.Models) > maxModels {
+		return fmt.Errorf("too many models (max %d per endpoint)", maxModels)
+	}
+
 	// Add new endpoint
 	endpoints = append(endpoints, *endpoint)
 
 	// Save to Redis
-	if err := s.saveDynamicEndpoints(ctx, endpoints); err != nil {
+	if err := s.saveEndpoints(ctx, owner, endpoints); err != nil {
+		return err
+	}
+
+	// Notify listeners
+	s.notifyConfigChange()
+
+	s.logger.WithFields(logrus.Fields{
+		"endpoint": endpoint.Name,
+		"owner":    owner,
+	}).Info("Added new endpoint")
+	return nil
+}
+
+// UpdateEndpoint updates an existing endpoint of the endpoints userID manages.
+// Base endpoints are overridden by a dynamic copy carrying the updates.
+func (s *DynamicConfigService) UpdateEndpoint(ctx context.Context, userID int64, endpointName string, updates map[string]interface{}) error {
+	if s.SafeMode() {
+		return ErrConfigLocked
+	}
+	owner := s.endpointOwner(userID)
+	endpoints, err := s.getEndpoints(ctx, owner)
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	index := -1
+	for i := range endpoints {
+		if endpoints[i].Name == endpointName {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		override, ok := s.baseEndpoint(endpointName)
+		if !ok || owner != 0 {
+			return fmt.Errorf("endpoint '%s' not found", endpointName)
+		}
+		endpoints = append(endpoints, override)
+		index = len(endpoints) - 1
+	}
+
+	// Apply updates
+	if displayName, ok := updates["display_name"].(string); ok {
+		endpoints[index].DisplayName = displayName
+	}
+	if baseURL, ok := updates["base_url"].(string); ok {
+		endpoints[index].BaseURL = baseURL
+	}
+	if apiKey, ok := updates["api_key"].(string); ok {
+		endpoints[index].APIKey = apiKey
+	}
+
+	if err := s.validateEndpoint(&endpoints[index]); err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+
+	// Save updated endpoints
+	if err := s.saveEndpoints(ctx, owner, endpoints); err != nil {
 		return err
 	}
 
 	// Notify listeners
 	s.notifyConfigChange()
 
-	s.logger.WithField("endpoint", endpoint.Name).Info("Added new endpoint")
+	s.logger.WithField("endpoint", endpointName).Info("Updated endpoint")
 	return nil
 }
 
-// UpdateEndpoint updates an existing endpoint
-func (s *DynamicConfigService) UpdateEndpoint(ctx context.Context, endpointName string, updates map[string]interface{}) error {
-	endpoints, err := s.getDynamicEndpoints(ctx)
+// RotateKey replaces the API key of an endpoint. Requests already in flight
+// finish with the old key; requests started after listeners have refreshed
+// their caches use the new one. Base endpoints are overridden dynamically.
+func (s *DynamicConfigService) RotateKey(ctx context.Context, userID int64, endpointName, newKey string) error {
+	if s.SafeMode() {
+		return ErrConfigLocked
+	}
+	newKey = strings.TrimSpace(newKey)
+	if newKey == "" {
+		return fmt.Errorf("API key is required")
+	}
+
+	owner := s.endpointOwner(userID)
+	endpoints, err := s.getEndpoints(ctx, owner)
 	if err != nil && err != redis.Nil {
 		return err
 	}
@@ -99,41 +235,46 @@ func (s *DynamicConfigService) UpdateEndpoint(ctx context.Context, endpointName
 	found := false
 	for i := range endpoints {
 		if endpoints[i].Name == endpointName {
+			endpoints[i].APIKey = newKey
 			found = true
-			
-			// Apply updates
-			if displayName, ok := updates["display_name"].(string); ok {
-				endpoints[i].DisplayName = displayName
-			}
-			if baseURL, ok := updates["base_url"].(string); ok {
-				endpoints[i].BaseURL = baseURL
-			}
-			if apiKey, ok := updates["api_key"].(string); ok {
-				endpoints[i].APIKey = apiKey
-			}
-			
 			break
 		}
 	}
 
+	if !found && owner == 0 {
+		if override, ok := s.baseEndpoint(endpointName); ok {
+			override.APIKey = newKey
+			endpoints = append(endpoints, override)
+			found = true
+		}
+	}
+
 	if !found {
 		return fmt.Errorf("endpoint '%s' not found", endpointName)
 	}
 
-	// Save updated endpoints
-	if err := s.saveDynamicEndpoints(ctx, endpoints); err != nil {
+	if err := s.saveEndpoints(ctx, owner, endpoints); err != nil {
 		return err
 	}
 
 	// Notify listeners
 	s.notifyConfigChange()
 
+	s.logger.WithField("endpoint", endpointName).Info("Rotated endpoint API key")
 	return nil
 }
 
-// AddModelToEndpoint adds a model to an endpoint
-func (s *DynamicConfigService) AddModelToEndpoint(ctx context.Context, endpointName string, model config.ModelInfo) error {
-	endpoints, err := s.getDynamicEndpoints(ctx)
+// AddModelToEndpoint adds a model to an endpoint userID manages
+func (s *DynamicConfigService) AddModelToEndpoint(ctx context.Context, userID int64, endpointName string, model config.ModelInfo) error {
+	if s.SafeMode() {
+		return ErrConfigLocked
+	}
+	if err := validateModel(model); err != nil {
+		return fmt.Errorf("invalid model: %w", err)
+	}
+
+	owner := s.endpointOwner(userID)
+	endpoints, err := s.getEndpoints(ctx, owner)
 	if err != nil && err != redis.Nil {
 		return err
 	}
@@ -149,6 +290,10 @@ func (s *DynamicConfigService) AddModelToEndpoint(ctx context.Context, endpointN
 					return fmt.Errorf("model '%s' already exists in endpoint", model.ID)
 				}
 			}
+
+			if maxModels := s.maxModelsPerEndpoint(); len(endpoints[i].Models) >= maxModels {
+				return fmt.Errorf("too many models (max %d per endpoint)", maxModels)
+			}
 			
 			// Add model
 			endpoints[i].Models = append(endpoints[i].Models, model)
@@ -156,12 +301,16 @@ func (s *DynamicConfigService) AddModelToEndpoint(ctx context.Context, endpointN
 		}
 	}
 
-	if !found {
+	if !found && owner == 0 {
 		// Check base config endpoints
 		for i := range s.baseConfig.Models.Endpoints {
 			if s.baseConfig.Models.Endpoints[i].Name == endpointName {
 				// Create a dynamic copy of the base endpoint
-				dynamicEndpoint := s.baseConfig.Models.Endpoints[i]
+				if maxEndpoints := s.maxDynamicEndpoints(); len(endpoints) >= maxEndpoints {
+					return fmt.Errorf("too many endpoints (max %d)", maxEndpoints)
+				}
+
+				dynamicEndpoint := copyEndpoints(s.baseConfig.Models.Endpoints[i : i+1])[0]
 				dynamicEndpoint.Models = append(dynamicEndpoint.Models, model)
 				endpoints = append(endpoints, dynamicEndpoint)
 				found = true
@@ -175,7 +324,7 @@ func (s *DynamicConfigService) AddModelToEndpoint(ctx context.Context, endpointN
 	}
 
 	// Save updated endpoints
-	if err := s.saveDynamicEndpoints(ctx, endpoints); err != nil {
+	if err := s.saveEndpoints(ctx, owner, endpoints); err != nil {
 		return err
 	}
 
@@ -185,6 +334,122 @@ func (s *DynamicConfigService) AddModelToEndpoint(ctx context.Context, endpointN
 	return nil
 }
 
+// AddModelsToEndpoint adds several models to an endpoint in one update,
+// skipping models that already exist. It returns the number of models added.
+func (s *DynamicConfigService) AddModelsToEndpoint(ctx context.Context, userID int64, endpointName string, models []config.ModelInfo) (int, error) {
+	if s.SafeMode() {
+		return 0, ErrConfigLocked
+	}
+	for _, model := range models {
+		if err := validateModel(model); err != nil {
+			return 0, fmt.Errorf("invalid model: %w", err)
+		}
+	}
+
+	owner := s.endpointOwner(userID)
+	endpoints, err := s.getEndpoints(ctx, owner)
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+
+	index := -1
+	for i := range endpoints {
+		if endpoints[i].Name == endpointName {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		base, ok := s.baseEndpoint(endpointName)
+		if !ok || owner != 0 {
+			return 0, fmt.Errorf("endpoint '%s' not found", endpointName)
+		}
+		if maxEndpoints := s.maxDynamicEndpoints(); len(endpoints) >= maxEndpoints {
+			return 0, fmt.Errorf("too many endpoints (max %d)", maxEndpoints)
+		}
+		endpoints = append(endpoints, base)
+		index = len(endpoints) - 1
+	}
+
+	existing := make(map[string]bool)
+	for _, m := range endpoints[index].Models {
+		existing[m.ID] = true
+	}
+
+	added := 0
+	maxModels := s.maxModelsPerEndpoint()
+	for _, model := range models {
+		if existing[model.ID] {
+			continue
+		}
+		if len(endpoints[index].Models) >= maxModels {
+			return 0, fmt.Errorf("too many models (max %d per endpoint)", maxModels)
+		}
+		endpoints[index].Models = append(endpoints[index].Models, model)
+		existing[model.ID] = true
+		added++
+	}
+
+	if added == 0 {
+		return 0, nil
+	}
+
+	// Save updated endpoints
+	if err := s.saveEndpoints(ctx, owner, endpoints); err != nil {
+		return 0, err
+	}
+
+	// Notify listeners
+	s.notifyConfigChange()
+
+	s.logger.WithFields(logrus.Fields{
+		"endpoint": endpointName,
+		"added":    added,
+	}).Info("Added models to endpoint")
+	return added, nil
+}
+
+// RemoveEndpoint deletes a dynamically added endpoint. Removing the dynamic
+// override of a base endpoint restores the endpoint from the config file;
+// endpoints only defined in the config file cannot be removed at runtime.
+func (s *DynamicConfigService) RemoveEndpoint(ctx context.Context, userID int64, endpointName string) error {
+	if s.SafeMode() {
+		return ErrConfigLocked
+	}
+	owner := s.endpointOwner(userID)
+	endpoints, err := s.getEndpoints(ctx, owner)
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	index := -1
+	for i := range endpoints {
+		if endpoints[i].Name == endpointName {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		if _, ok := s.baseEndpoint(endpointName); ok && owner == 0 {
+			return fmt.Errorf("endpoint '%s' is defined in the config file and cannot be removed", endpointName)
+		}
+		return fmt.Errorf("endpoint '%s' not found", endpointName)
+	}
+
+	endpoints = append(endpoints[:index], endpoints[index+1:]...)
+	if err := s.saveEndpoints(ctx, owner, endpoints); err != nil {
+		return err
+	}
+
+	// Notify listeners
+	s.notifyConfigChange()
+
+	s.logger.WithField("endpoint", endpointName).Info("Removed endpoint")
+	return nil
+}
+
 // TestEndpoint tests if an endpoint is working
 func (s *DynamicConfigService) TestEndpoint(ctx context.Context, endpoint *config.ModelEndpoint) error {
 	// TODO: Implement endpoint testing
@@ -199,15 +464,29 @@ func (s *DynamicConfigService) RegisterConfigChangeListener(listener func(*confi
 	s.listeners = append(s.listeners, listener)
 }
 
+// SafeMode reports whether runtime configuration changes are disabled
+func (s *DynamicConfigService) SafeMode() bool {
+	return s.baseConfig.Models.SafeMode
+}
+
 // Private methods
 
 func (s *DynamicConfigService) getDynamicEndpoints(ctx context.Context) ([]config.ModelEndpoint, error) {
-	data, err := s.redis.Get(ctx, "dynamic_endpoints").Result()
-	if err == redis.Nil {
-		return []config.ModelEndpoint{}, nil
-	}
-	if err != nil {
-		return nil, err
+	var data string
+	if s.local != nil {
+		var ok bool
+		if data, ok = s.local.get(0); !ok {
+			return []config.ModelEndpoint{}, nil
+		}
+	} else {
+		var err error
+		data, err = s.redis.Get(ctx, "dynamic_endpoints").Result()
+		if err == redis.Nil {
+			return []config.ModelEndpoint{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var endpoints []config.ModelEndpoint
@@ -224,6 +503,10 @@ func (s *DynamicConfigService) saveDynamicEndpoints(ctx context.Context, endpoin
 		return err
 	}
 
+	if s.local != nil {
+		s.local.set(0, string(data))
+		return nil
+	}
 	return s.redis.Set(ctx, "dynamic_endpoints", data, 0).Err()
 }
 
@@ -231,6 +514,12 @@ func (s *DynamicConfigService) validateEndpoint(endpoint *config.ModelEndpoint)
 	if endpoint.Name == "" {
 		return fmt.Errorf("endpoint name is required")
 	}
+	if len(endpoint.Name) > maxNameLength {
+		return fmt.Errorf("endpoint name is too long (max %d)", maxNameLength)
+	}
+	if len(endpoint.DisplayName) > maxNameLength {
+		return fmt.Errorf("display name is too long (max %d)", maxNameLength)
+	}
 	if endpoint.DisplayName == "" {
 		return fmt.Errorf("display name is required")
 	}
@@ -240,24 +529,90 @@ func (s *DynamicConfigService) validateEndpoint(endpoint *config.ModelEndpoint)
 	if endpoint.APIKey == "" {
 		return fmt.Errorf("API key is required")
 	}
+	for _, model := range endpoint.Models {
+		if err := validateModel(model); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (s *DynamicConfigService) notifyConfigChange() {
+func validateModel(model config.ModelInfo) error {
+	if model.ID == "" {
+		return fmt.Errorf("model ID is required")
+	}
+	if len(model.ID) > maxModelIDLength {
+		return fmt.Errorf("model ID is too long (max %d)", maxModelIDLength)
+	}
+	if len(model.Name) > maxNameLength {
+		return fmt.Errorf("model name is too long (max %d)", maxNameLength)
+	}
+	return nil
+}
+
+func (s *DynamicConfigService) maxDynamicEndpoints() int {
+	if s.baseConfig.Models.MaxDynamicEndpoints > 0 {
+		return s.baseConfig.Models.MaxDynamicEndpoints
+	}
+	return defaultMaxDynamicEndpoints
+}
+
+func (s *DynamicConfigService) maxModelsPerEndpoint() int {
+	if s.baseConfig.Models.MaxModelsPerEndpoint > 0 {
+		return s.baseConfig.Models.MaxModelsPerEndpoint
+	}
+	return defaultMaxModelsPerEndpoint
+}
+
+// baseEndpoint returns a copy of the base endpoint with the given name
+func (s *DynamicConfigService) baseEndpoint(name string) (config.ModelEndpoint, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	
-	// Get current config
+
+	for i := range s.baseConfig.Models.Endpoints {
+		if s.baseConfig.Models.Endpoints[i].Name == name {
+			return copyEndpoints(s.baseConfig.Models.Endpoints[i : i+1])[0], true
+		}
+	}
+	return config.ModelEndpoint{}, false
+}
+
+// copyEndpoints deep-copies endpoints including their model lists
+func copyEndpoints(endpoints []config.ModelEndpoint) []config.ModelEndpoint {
+	if endpoints == nil {
+		return nil
+	}
+
+	copied := make([]config.ModelEndpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		copied[i] = endpoint
+		if endpoint.Models != nil {
+			copied[i].Models = make([]config.ModelInfo, len(endpoint.Models))
+			copy(copied[i].Models, endpoint.Models)
+		}
+	}
+	return copied
+}
+
+// notifyConfigChange snapshots the config once and invokes listeners without
+// holding the lock, so listeners may call back into the service
+func (s *DynamicConfigService) notifyConfigChange() {
+	// Get current config (takes the lock on its own)
 	ctx := context.Background()
-	config, err := s.GetCurrentConfig(ctx)
+	currentConfig, err := s.GetCurrentConfig(ctx)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get config for notification")
 		return
 	}
-	
+
+	s.mu.RLock()
+	listeners := make([]func(*config.Config), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+
 	// Notify all listeners
-	for _, listener := range s.listeners {
-		go listener(config)
+	for _, listener := range listeners {
+		go listener(currentConfig)
 	}
 }
 
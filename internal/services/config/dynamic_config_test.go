@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestService returns a service without Redis on top of base endpoint "base"
+func newTestService(visibility string, adminIDs ...int64) *DynamicConfigService {
+	cfg := &config.Config{}
+	cfg.Models.EndpointVisibility = visibility
+	cfg.Bot.AdminIDs = adminIDs
+	cfg.Models.Endpoints = []config.ModelEndpoint{testEndpoint("base", "base-model")}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewDynamicConfigService(nil, cfg, logger)
+}
+
+func testEndpoint(name string, modelIDs ...string) config.ModelEndpoint {
+	endpoint := config.ModelEndpoint{
+		Name:        name,
+		DisplayName: name,
+		BaseURL:     "https://" + name + ".example.com/v1",
+		APIKey:      "sk-" + name,
+	}
+	for _, id := range modelIDs {
+		endpoint.Models = append(endpoint.Models, config.ModelInfo{ID: id})
+	}
+	return endpoint
+}
+
+func endpointNames(cfg *config.Config) map[string]bool {
+	names := make(map[string]bool)
+	for _, endpoint := range cfg.Models.Endpoints {
+		names[endpoint.Name] = true
+	}
+	return names
+}
+
+func TestDynamicEndpointsWithoutRedis(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityGlobal)
+
+	added := testEndpoint("added", "added-model")
+	if err := s.AddEndpoint(ctx, 1, &added); err != nil {
+		t.Fatalf("AddEndpoint: %v", err)
+	}
+	if err := s.UpdateEndpoint(ctx, 1, "base", map[string]interface{}{"api_key": "sk-rotated"}); err != nil {
+		t.Fatalf("UpdateEndpoint: %v", err)
+	}
+
+	current, err := s.GetCurrentConfig(ctx)
+	if err != nil {
+		t.Fatalf("GetCurrentConfig: %v", err)
+	}
+	if names := endpointNames(current); !names["base"] || !names["added"] || len(current.Models.Endpoints) != 2 {
+		t.Errorf("endpoints = %v, want base and added", names)
+	}
+	for _, endpoint := range current.Models.Endpoints {
+		if endpoint.Name == "base" && endpoint.APIKey != "sk-rotated" {
+			t.Errorf("base endpoint key = %q, want the rotated key", endpoint.APIKey)
+		}
+	}
+
+	if err := s.RemoveEndpoint(ctx, 1, "added"); err != nil {
+		t.Fatalf("RemoveEndpoint: %v", err)
+	}
+	current, _ = s.GetCurrentConfig(ctx)
+	if names := endpointNames(current); names["added"] {
+		t.Errorf("removed endpoint still configured: %v", names)
+	}
+}
+
+func TestPrivateEndpointsWithoutRedis(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityOwner)
+
+	private := testEndpoint("mine", "my-model")
+	if err := s.AddEndpoint(ctx, 7, &private); err != nil {
+		t.Fatalf("AddEndpoint: %v", err)
+	}
+
+	users, err := s.GetUserEndpoints(ctx)
+	if err != nil {
+		t.Fatalf("GetUserEndpoints: %v", err)
+	}
+	if len(users[7]) != 1 || users[7][0].Name != "mine" {
+		t.Errorf("user endpoints = %v, want user 7 owning mine", users)
+	}
+
+	// Empty lists are removed rather than kept around
+	if err := s.RemoveEndpoint(ctx, 7, "mine"); err != nil {
+		t.Fatalf("RemoveEndpoint: %v", err)
+	}
+	if users, _ := s.GetUserEndpoints(ctx); len(users) != 0 {
+		t.Errorf("user endpoints = %v after removing the only one, want none", users)
+	}
+}
+
+func TestPrivateEndpointsIsolated(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityOwner, 1)
+	mine := testEndpoint("mine", "my-model")
+	if err := s.AddEndpoint(ctx, 7, &mine); err != nil {
+		t.Fatalf("AddEndpoint: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{name: "reuse the name", change: func() error {
+			taken := testEndpoint("mine", "other-model")
+			return s.AddEndpoint(ctx, 8, &taken)
+		}},
+		{name: "update", change: func() error {
+			return s.UpdateEndpoint(ctx, 8, "mine", map[string]interface{}{"base_url": "https://evil.example.com"})
+		}},
+		{name: "rotate the key", change: func() error { return s.RotateKey(ctx, 8, "mine", "sk-stolen") }},
+		{name: "add a model", change: func() error {
+			return s.AddModelToEndpoint(ctx, 8, "mine", config.ModelInfo{ID: "injected"})
+		}},
+		{name: "remove", change: func() error { return s.RemoveEndpoint(ctx, 8, "mine") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); err == nil {
+				t.Error("another user changed the private endpoint")
+			}
+		})
+	}
+
+	users, err := s.GetUserEndpoints(ctx)
+	if err != nil {
+		t.Fatalf("GetUserEndpoints: %v", err)
+	}
+	if len(users) != 1 || len(users[7]) != 1 {
+		t.Fatalf("user endpoints = %v, want only user 7's", users)
+	}
+	if got := users[7][0]; got.BaseURL != mine.BaseURL || got.APIKey != mine.APIKey || len(got.Models) != 1 {
+		t.Errorf("private endpoint changed to %+v", got)
+	}
+
+	// Private endpoints never reach the shared configuration
+	current, _ := s.GetCurrentConfig(ctx)
+	if names := endpointNames(current); names["mine"] {
+		t.Errorf("shared endpoints %v include the private one", names)
+	}
+}
+
+func TestRemoveEndpointErrors(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityGlobal)
+
+	if err := s.RemoveEndpoint(ctx, 1, "base"); err == nil || !strings.Contains(err.Error(), "config file") {
+		t.Errorf("removing the base endpoint = %v, want it refused", err)
+	}
+	if err := s.RemoveEndpoint(ctx, 1, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("removing an unknown endpoint = %v, want not found", err)
+	}
+
+	// Removing the runtime override of a base endpoint restores the file's settings
+	if err := s.UpdateEndpoint(ctx, 1, "base", map[string]interface{}{"api_key": "sk-rotated"}); err != nil {
+		t.Fatalf("UpdateEndpoint: %v", err)
+	}
+	if err := s.RemoveEndpoint(ctx, 1, "base"); err != nil {
+		t.Fatalf("RemoveEndpoint of the override: %v", err)
+	}
+	current, _ := s.GetCurrentConfig(ctx)
+	if len(current.Models.Endpoints) != 1 || current.Models.Endpoints[0].APIKey != "sk-base" {
+		t.Errorf("endpoints = %+v, want the base endpoint from the file", current.Models.Endpoints)
+	}
+}
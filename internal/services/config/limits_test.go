@@ -0,0 +1,139 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+func TestDynamicEndpointLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityGlobal)
+	s.baseConfig.Models.MaxDynamicEndpoints = 2
+
+	for i := 0; i < 2; i++ {
+		endpoint := testEndpoint(fmt.Sprintf("added-%d", i), "model")
+		if err := s.AddEndpoint(ctx, 1, &endpoint); err != nil {
+			t.Fatalf("AddEndpoint %d under the cap: %v", i, err)
+		}
+	}
+
+	extra := testEndpoint("extra", "model")
+	err := s.AddEndpoint(ctx, 1, &extra)
+	if err == nil || err.Error() != "too many endpoints (max 2)" {
+		t.Fatalf("AddEndpoint over the cap = %v, want too many endpoints", err)
+	}
+	// Copying a base endpoint to add a model counts as well
+	if err := s.AddModelToEndpoint(ctx, 1, "base", config.ModelInfo{ID: "new-model"}); err == nil || !strings.Contains(err.Error(), "too many endpoints") {
+		t.Errorf("AddModelToEndpoint on a base endpoint = %v, want too many endpoints", err)
+	}
+	current, _ := s.GetCurrentConfig(ctx)
+	if names := endpointNames(current); names["extra"] || len(names) != 3 {
+		t.Errorf("endpoints = %v, want base and the two added", names)
+	}
+
+	// Removing one makes room again
+	if err := s.RemoveEndpoint(ctx, 1, "added-0"); err != nil {
+		t.Fatalf("RemoveEndpoint: %v", err)
+	}
+	if err := s.AddEndpoint(ctx, 1, &extra); err != nil {
+		t.Errorf("AddEndpoint after removing one: %v", err)
+	}
+}
+
+func TestDynamicModelLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityGlobal)
+	s.baseConfig.Models.MaxModelsPerEndpoint = 2
+
+	crowded := testEndpoint("crowded", "a", "b", "c")
+	if err := s.AddEndpoint(ctx, 1, &crowded); err == nil || err.Error() != "too many models (max 2 per endpoint)" {
+		t.Errorf("AddEndpoint with 3 models = %v, want too many models", err)
+	}
+
+	endpoint := testEndpoint("added", "a")
+	if err := s.AddEndpoint(ctx, 1, &endpoint); err != nil {
+		t.Fatalf("AddEndpoint: %v", err)
+	}
+	if err := s.AddModelToEndpoint(ctx, 1, "added", config.ModelInfo{ID: "b"}); err != nil {
+		t.Fatalf("AddModelToEndpoint under the cap: %v", err)
+	}
+	if err := s.AddModelToEndpoint(ctx, 1, "added", config.ModelInfo{ID: "c"}); err == nil || err.Error() != "too many models (max 2 per endpoint)" {
+		t.Errorf("AddModelToEndpoint over the cap = %v, want too many models", err)
+	}
+	// A batch crossing the cap adds none of its models
+	if _, err := s.AddModelsToEndpoint(ctx, 1, "base", []config.ModelInfo{{ID: "x"}, {ID: "y"}}); err == nil {
+		t.Error("AddModelsToEndpoint over the cap passed")
+	}
+
+	current, _ := s.GetCurrentConfig(ctx)
+	for _, endpoint := range current.Models.Endpoints {
+		var ids []string
+		for _, model := range endpoint.Models {
+			ids = append(ids, model.ID)
+		}
+		want := map[string]string{"added": "a,b", "base": "base-model"}[endpoint.Name]
+		if got := strings.Join(ids, ","); got != want {
+			t.Errorf("models of %s = %s, want %s", endpoint.Name, got, want)
+		}
+	}
+}
+
+func TestDynamicLimitDefaults(t *testing.T) {
+	s := newTestService(config.EndpointVisibilityGlobal)
+	if got := s.maxDynamicEndpoints(); got != defaultMaxDynamicEndpoints {
+		t.Errorf("maxDynamicEndpoints = %d, want %d", got, defaultMaxDynamicEndpoints)
+	}
+	if got := s.maxModelsPerEndpoint(); got != defaultMaxModelsPerEndpoint {
+		t.Errorf("maxModelsPerEndpoint = %d, want %d", got, defaultMaxModelsPerEndpoint)
+	}
+}
+
+func TestDynamicNameLengths(t *testing.T) {
+	long := strings.Repeat("n", maxNameLength+1)
+	longID := strings.Repeat("m", maxModelIDLength+1)
+
+	tests := []struct {
+		name    string
+		modify  func(endpoint *config.ModelEndpoint)
+		wantErr string
+	}{
+		{name: "longest names", modify: func(endpoint *config.ModelEndpoint) {
+			endpoint.Name = long[1:]
+			endpoint.DisplayName = long[1:]
+			endpoint.Models = []config.ModelInfo{{ID: longID[1:], Name: long[1:]}}
+		}},
+		{name: "endpoint name", modify: func(endpoint *config.ModelEndpoint) { endpoint.Name = long }, wantErr: "endpoint name is too long (max 64)"},
+		{name: "display name", modify: func(endpoint *config.ModelEndpoint) { endpoint.DisplayName = long }, wantErr: "display name is too long (max 64)"},
+		{name: "model ID", modify: func(endpoint *config.ModelEndpoint) { endpoint.Models[0].ID = longID }, wantErr: "model ID is too long (max 128)"},
+		{name: "model name", modify: func(endpoint *config.ModelEndpoint) { endpoint.Models[0].Name = long }, wantErr: "model name is too long (max 64)"},
+		{name: "model without ID", modify: func(endpoint *config.ModelEndpoint) { endpoint.Models[0].ID = "" }, wantErr: "model ID is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(config.EndpointVisibilityGlobal)
+			endpoint := testEndpoint("added", "model")
+			tt.modify(&endpoint)
+
+			err := s.AddEndpoint(context.Background(), 1, &endpoint)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("AddEndpoint: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != "invalid endpoint: "+tt.wantErr {
+				t.Errorf("AddEndpoint = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Models added to an existing endpoint are checked too
+	s := newTestService(config.EndpointVisibilityGlobal)
+	if err := s.AddModelToEndpoint(context.Background(), 1, "base", config.ModelInfo{ID: longID}); err == nil || err.Error() != "invalid model: model ID is too long (max 128)" {
+		t.Errorf("AddModelToEndpoint = %v, want the model ID refused", err)
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import "sync"
+
+// localEndpoints keeps dynamic endpoints in memory for deployments without
+// Redis. They are lost when the bot restarts.
+type localEndpoints struct {
+	mu   sync.Mutex
+	data map[int64]string // encoded endpoint lists by owner, 0 for the shared list
+}
+
+func newLocalEndpoints() *localEndpoints {
+	return &localEndpoints{data: make(map[int64]string)}
+}
+
+// get returns the encoded endpoints of owner
+func (l *localEndpoints) get(owner int64) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data, ok := l.data[owner]
+	return data, ok
+}
+
+// set stores the encoded endpoints of owner, removing them when data is empty
+func (l *localEndpoints) set(owner int64, data string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if data == "" {
+		delete(l.data, owner)
+		return
+	}
+	l.data[owner] = data
+}
+
+// users returns the encoded private endpoints of every owner
+func (l *localEndpoints) users() map[int64]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	users := make(map[int64]string, len(l.data))
+	for owner, data := range l.data {
+		if owner != 0 {
+			users[owner] = data
+		}
+	}
+	return users
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+// endpointKey returns the API key endpoint name is configured with
+func endpointKey(t *testing.T, s *DynamicConfigService, name string) string {
+	t.Helper()
+	current, err := s.GetCurrentConfig(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentConfig: %v", err)
+	}
+	for _, endpoint := range current.Models.Endpoints {
+		if endpoint.Name == name {
+			return endpoint.APIKey
+		}
+	}
+	t.Fatalf("endpoint %s not configured", name)
+	return ""
+}
+
+func TestRotateKey(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityGlobal)
+	added := testEndpoint("added", "added-model")
+	if err := s.AddEndpoint(ctx, 1, &added); err != nil {
+		t.Fatalf("AddEndpoint: %v", err)
+	}
+	notified := make(chan string, 10)
+	s.RegisterConfigChangeListener(func(cfg *config.Config) {
+		for _, endpoint := range cfg.Models.Endpoints {
+			if endpoint.Name == "base" {
+				notified <- endpoint.APIKey
+			}
+		}
+	})
+
+	// Base endpoints get a dynamic override carrying the new key
+	if err := s.RotateKey(ctx, 1, "base", "  sk-base-new\n"); err != nil {
+		t.Fatalf("RotateKey(base): %v", err)
+	}
+	if got := endpointKey(t, s, "base"); got != "sk-base-new" {
+		t.Errorf("base key = %q, want sk-base-new", got)
+	}
+	select {
+	case key := <-notified:
+		if key != "sk-base-new" {
+			t.Errorf("listeners saw base key %q, want sk-base-new", key)
+		}
+	case <-time.After(time.Second):
+		t.Error("listeners weren't told about the new key")
+	}
+	if err := s.RotateKey(ctx, 1, "added", "sk-added-new"); err != nil {
+		t.Fatalf("RotateKey(added): %v", err)
+	}
+	if got := endpointKey(t, s, "added"); got != "sk-added-new" {
+		t.Errorf("added key = %q, want sk-added-new", got)
+	}
+	// Nothing else about the endpoint changes
+	current, _ := s.GetCurrentConfig(ctx)
+	if len(current.Models.Endpoints) != 2 {
+		t.Errorf("got %d endpoints after rotating keys, want 2", len(current.Models.Endpoints))
+	}
+
+	tests := []struct {
+		name     string
+		endpoint string
+		key      string
+		wantErr  string
+	}{
+		{name: "empty key", endpoint: "base", key: "  ", wantErr: "API key is required"},
+		{name: "unknown endpoint", endpoint: "missing", key: "sk-new", wantErr: "endpoint 'missing' not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.RotateKey(ctx, 1, tt.endpoint, tt.key); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("RotateKey = %v, want %q", err, tt.wantErr)
+			}
+			if got := endpointKey(t, s, "base"); got != "sk-base-new" {
+				t.Errorf("base key = %q after a refused rotation, want sk-base-new", got)
+			}
+		})
+	}
+}
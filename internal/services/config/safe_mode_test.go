@@ -0,0 +1,60 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+)
+
+func TestSafeModeRefusesChanges(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(config.EndpointVisibilityGlobal, 1)
+	// An endpoint stored before safe mode was turned on
+	stored := testEndpoint("stored", "stored-model")
+	if err := s.AddEndpoint(ctx, 1, &stored); err != nil {
+		t.Fatalf("AddEndpoint: %v", err)
+	}
+	s.baseConfig.Models.SafeMode = true
+
+	added := testEndpoint("added", "added-model")
+	changes := map[string]func() error{
+		"AddEndpoint": func() error { return s.AddEndpoint(ctx, 1, &added) },
+		"UpdateEndpoint": func() error {
+			return s.UpdateEndpoint(ctx, 1, "base", map[string]interface{}{"api_key": "sk-changed"})
+		},
+		"RotateKey":          func() error { return s.RotateKey(ctx, 1, "base", "sk-rotated") },
+		"AddModelToEndpoint": func() error { return s.AddModelToEndpoint(ctx, 1, "base", config.ModelInfo{ID: "new-model"}) },
+		"AddModelsToEndpoint": func() error {
+			_, err := s.AddModelsToEndpoint(ctx, 1, "base", []config.ModelInfo{{ID: "new-model"}})
+			return err
+		},
+		"RemoveEndpoint": func() error { return s.RemoveEndpoint(ctx, 1, "stored") },
+	}
+	for name, change := range changes {
+		if err := change(); !errors.Is(err, ErrConfigLocked) {
+			t.Errorf("%s = %v, want ErrConfigLocked", name, err)
+		}
+	}
+
+	// Only the file configuration is used, unchanged
+	current, err := s.GetCurrentConfig(ctx)
+	if err != nil {
+		t.Fatalf("GetCurrentConfig: %v", err)
+	}
+	if len(current.Models.Endpoints) != 1 || current.Models.Endpoints[0].Name != "base" {
+		t.Fatalf("endpoints = %v, want only base", endpointNames(current))
+	}
+	base := current.Models.Endpoints[0]
+	if base.APIKey != "sk-base" || len(base.Models) != 1 {
+		t.Errorf("base endpoint = %+v, want it as configured", base)
+	}
+
+	// Turning safe mode off brings the stored endpoint back
+	s.baseConfig.Models.SafeMode = false
+	current, _ = s.GetCurrentConfig(ctx)
+	if names := endpointNames(current); !names["stored"] {
+		t.Errorf("endpoints = %v after safe mode, want stored back", names)
+	}
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/go-redis/redis/v8"
+)
+
+// userEndpointsKey is the Redis hash holding private endpoints, one field per owner
+const userEndpointsKey = "user_endpoints"
+
+// UserModelID returns the ID under which a model of a private endpoint is
+// offered. Private endpoint names are unique across users, so prefixing the
+// endpoint name keeps models of different users apart.
+func UserModelID(endpointName, modelID string) string {
+	return endpointName + "/" + modelID
+}
+
+// GetUserEndpoints returns the private endpoints of all users, keyed by owner.
+// There are none in safe mode.
+func (s *DynamicConfigService) GetUserEndpoints(ctx context.Context) (map[int64][]config.ModelEndpoint, error) {
+	if s.SafeMode() {
+		return map[int64][]config.ModelEndpoint{}, nil
+	}
+	fields, err := s.userEndpointFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64][]config.ModelEndpoint, len(fields))
+	for field, data := range fields {
+		owner, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		var endpoints []config.ModelEndpoint
+		if err := json.Unmarshal([]byte(data), &endpoints); err != nil {
+			s.logger.WithError(err).WithField("owner", owner).Warn("Skipping unreadable user endpoints")
+			continue
+		}
+		result[owner] = endpoints
+	}
+	return result, nil
+}
+
+// endpointOwner returns whose endpoint list userID manages: their own when
+// endpoints are private to their owner, the shared list (0) for admins and
+// when endpoints are shared
+func (s *DynamicConfigService) endpointOwner(userID int64) int64 {
+	if s.baseConfig.Models.EndpointVisibility != config.EndpointVisibilityOwner {
+		return 0
+	}
+	for _, adminID := range s.baseConfig.Bot.AdminIDs {
+		if adminID == userID {
+			return 0
+		}
+	}
+	return userID
+}
+
+// getEndpoints returns the dynamic endpoints of owner; owner 0 is the shared list
+func (s *DynamicConfigService) getEndpoints(ctx context.Context, owner int64) ([]config.ModelEndpoint, error) {
+	if owner == 0 {
+		return s.getDynamicEndpoints(ctx)
+	}
+
+	var data string
+	if s.local != nil {
+		var ok bool
+		if data, ok = s.local.get(owner); !ok {
+			return []config.ModelEndpoint{}, nil
+		}
+	} else {
+		var err error
+		data, err = s.redis.HGet(ctx, userEndpointsKey, strconv.FormatInt(owner, 10)).Result()
+		if err == redis.Nil {
+			return []config.ModelEndpoint{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var endpoints []config.ModelEndpoint
+	if err := json.Unmarshal([]byte(data), &endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// saveEndpoints stores the dynamic endpoints of owner; owner 0 is the shared list
+func (s *DynamicConfigService) saveEndpoints(ctx context.Context, owner int64, endpoints []config.ModelEndpoint) error {
+	if owner == 0 {
+		return s.saveDynamicEndpoints(ctx, endpoints)
+	}
+
+	field := strconv.FormatInt(owner, 10)
+	if len(endpoints) == 0 {
+		if s.local != nil {
+			s.local.set(owner, "")
+			return nil
+		}
+		return s.redis.HDel(ctx, userEndpointsKey, field).Err()
+	}
+
+	data, err := json.Marshal(endpoints)
+	if err != nil {
+		return err
+	}
+	if s.local != nil {
+		s.local.set(owner, string(data))
+		return nil
+	}
+	return s.redis.HSet(ctx, userEndpointsKey, field, data).Err()
+}
+
+// userEndpointFields returns the encoded private endpoints by owner field
+func (s *DynamicConfigService) userEndpointFields(ctx context.Context) (map[string]string, error) {
+	if s.local == nil {
+		return s.redis.HGetAll(ctx, userEndpointsKey).Result()
+	}
+	fields := make(map[string]string)
+	for owner, data := range s.local.users() {
+		fields[strconv.FormatInt(owner, 10)] = data
+	}
+	return fields, nil
+}
+
+// endpointNameTaken reports whether a private endpoint already uses name, or,
+// with includeShared, any shared endpoint does
+func (s *DynamicConfigService) endpointNameTaken(ctx context.Context, name string, includeShared bool) (bool, error) {
+	if includeShared {
+		currentConfig, err := s.GetCurrentConfig(ctx)
+		if err != nil {
+			return false, err
+		}
+		for _, endpoint := range currentConfig.Models.Endpoints {
+			if endpoint.Name == name {
+				return true, nil
+			}
+		}
+	}
+
+	userEndpoints, err := s.GetUserEndpoints(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user endpoints: %w", err)
+	}
+	for _, endpoints := range userEndpoints {
+		for _, endpoint := range endpoints {
+			if endpoint.Name == name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -6,6 +6,8 @@ import (
 	"math"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -21,6 +23,8 @@ type EmbeddingService interface {
 type SimpleEmbeddingService struct {
 	vocabulary map[string]int
 	idf        map[string]float64
+	dimension  int
+	mu         sync.RWMutex
 }
 
 // NewSimpleEmbeddingService creates a new simple embedding service
@@ -33,6 +37,9 @@ func NewSimpleEmbeddingService() *SimpleEmbeddingService {
 
 // BuildVocabulary builds vocabulary from documents
 func (s *SimpleEmbeddingService) BuildVocabulary(documents []Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
 	// Reset vocabulary
 	s.vocabulary = make(map[string]int)
 	s.idf = make(map[string]float64)
@@ -65,14 +72,28 @@ func (s *SimpleEmbeddingService) BuildVocabulary(documents []Document) {
 	for token, freq := range df {
 		s.idf[token] = math.Log(float64(totalDocs) / float64(freq))
 	}
+	
+	// Fix the dimension so every vector built from this vocabulary aligns
+	s.dimension = vocabIndex
+}
+
+// Dimension returns the length of the vectors produced by GetEmbedding
+func (s *SimpleEmbeddingService) Dimension() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dimension
 }
 
-// GetEmbedding returns TF-IDF vector for text
+// GetEmbedding returns the L2-normalized TF-IDF vector for text.
+// Tokens unseen when the vocabulary was built are ignored.
 func (s *SimpleEmbeddingService) GetEmbedding(text string) ([]float32, error) {
 	tokens := s.tokenize(text)
 	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
 	// Create TF-IDF vector
-	vector := make([]float32, len(s.vocabulary))
+	vector := make([]float32, s.dimension)
 	
 	// Calculate term frequency
 	tf := make(map[string]int)
@@ -89,28 +110,60 @@ func (s *SimpleEmbeddingService) GetEmbedding(text string) ([]float32, error) {
 		}
 	}
 	
+	normalize(vector)
 	return vector, nil
 }
 
-// CosineSimilarity calculates cosine similarity between two vectors
+// normalize scales a vector to unit length in place
+func normalize(vector []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	
+	norm := math.Sqrt(sum)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+}
+
+// CosineSimilarity calculates cosine similarity between two vectors.
+// Vectors of differing lengths are compared over their common prefix, which
+// is where indices of a grown vocabulary still line up.
 func (s *SimpleEmbeddingService) CosineSimilarity(a, b []float32) float32 {
-	if len(a) != len(b) {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if n == 0 {
 		return 0
 	}
 	
-	var dotProduct, normA, normB float32
+	var dotProduct, normA, normB float64
 	
-	for i := range a {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
+	for i := 0; i < n; i++ {
+		dotProduct += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
 	}
 	
 	if normA == 0 || normB == 0 {
 		return 0
 	}
 	
-	return dotProduct / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
+	similarity := dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+	
+	// Guard against floating point drift outside the valid range
+	if similarity > 1 {
+		similarity = 1
+	} else if similarity < -1 {
+		similarity = -1
+	}
+	
+	return float32(similarity)
 }
 
 // tokenize splits text into tokens (simple word-based tokenization)
@@ -163,6 +216,7 @@ type VectorKnowledgeService struct {
 	*KnowledgeService
 	embedding  *SimpleEmbeddingService
 	docVectors map[string][]float32
+	queries    searchCache[[]float32] // query -> embedding
 }
 
 // NewVectorKnowledgeService creates a new vector-enabled knowledge service
@@ -176,9 +230,9 @@ func NewVectorKnowledgeService(logger *logrus.Logger) *VectorKnowledgeService {
 }
 
 // LoadKnowledgeBase loads documents and builds embeddings
-func (v *VectorKnowledgeService) LoadKnowledgeBase(ctx context.Context, dir string) error {
+func (v *VectorKnowledgeService) LoadKnowledgeBase(ctx context.Context, dirs ...string) error {
 	// Load documents
-	if err := v.KnowledgeService.LoadKnowledgeBase(ctx, dir); err != nil {
+	if err := v.KnowledgeService.LoadKnowledgeBase(ctx, dirs...); err != nil {
 		return err
 	}
 	
@@ -197,14 +251,91 @@ func (v *VectorKnowledgeService) LoadKnowledgeBase(ctx context.Context, dir stri
 		v.docVectors[doc.ID] = vector
 	}
 	
+	// Query embeddings depend on the vocabulary just rebuilt
+	v.queries.clear()
+	
 	v.logger.WithField("vectors", len(v.docVectors)).Info("Document vectors created")
 	return nil
 }
 
+// SetSearchCacheTTL keeps search results and query embeddings for ttl; 0
+// disables caching
+func (v *VectorKnowledgeService) SetSearchCacheTTL(ttl time.Duration) {
+	v.KnowledgeService.SetSearchCacheTTL(ttl)
+	v.queries.setTTL(ttl)
+}
+
+// queryEmbedding returns the embedding of a search query, reusing a cached one
+func (v *VectorKnowledgeService) queryEmbedding(query string) ([]float32, error) {
+	vector, generation, cached := v.queries.get(query)
+	if cached {
+		return vector, nil
+	}
+	
+	vector, err := v.embedding.GetEmbedding(query)
+	if err != nil {
+		return nil, err
+	}
+	v.queries.set(query, vector, generation)
+	return vector, nil
+}
+
+// RefreshKnowledgeBase reloads all directories and rebuilds the embeddings
+func (v *VectorKnowledgeService) RefreshKnowledgeBase(ctx context.Context) error {
+	return v.exclusiveRefresh(func() error {
+		return v.LoadKnowledgeBase(ctx, v.knowledgeDirs...)
+	})
+}
+
+// ReloadDocument re-reads a single document and recomputes its vector.
+// The vocabulary isn't rebuilt, so words new to the whole base are only
+// picked up by a full refresh.
+func (v *VectorKnowledgeService) ReloadDocument(ctx context.Context, id string) (*Document, error) {
+	v.documentsRW.Lock()
+	defer v.documentsRW.Unlock()
+	
+	doc, err := v.reloadDocumentLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		delete(v.docVectors, id)
+		return nil, nil
+	}
+	
+	vector, err := v.embedding.GetEmbedding(doc.Content)
+	if err != nil {
+		delete(v.docVectors, id)
+		return doc, fmt.Errorf("failed to create embedding: %w", err)
+	}
+	v.docVectors[id] = vector
+	return doc, nil
+}
+
+// RelevanceThreshold is the minimum similarity for a document to count as relevant
+const RelevanceThreshold float32 = 0.1
+
 // VectorSearch performs semantic search using embeddings
 func (v *VectorKnowledgeService) VectorSearch(ctx context.Context, query string, limit int) ([]DocumentWithScore, error) {
+	return v.scoreDocuments(ctx, query, limit, RelevanceThreshold)
+}
+
+// ScoreDocuments returns the best matching documents with their similarity,
+// including those below RelevanceThreshold. It is meant for tuning.
+func (v *VectorKnowledgeService) ScoreDocuments(ctx context.Context, query string, limit int) ([]DocumentWithScore, error) {
+	return v.scoreDocuments(ctx, query, limit, 0)
+}
+
+// scoreDocuments ranks documents by similarity to the query, keeping those scoring above minScore
+func (v *VectorKnowledgeService) scoreDocuments(ctx context.Context, query string, limit int, minScore float32) ([]DocumentWithScore, error) {
+	release, err := v.gate.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	
 	// Get query embedding
-	queryVector, err := v.embedding.GetEmbedding(query)
+	queryVector, err := v.queryEmbedding(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get query embedding: %w", err)
 	}
@@ -218,7 +349,7 @@ func (v *VectorKnowledgeService) VectorSearch(ctx context.Context, query string,
 	for docID, docVector := range v.docVectors {
 		if doc, exists := v.documents[docID]; exists {
 			score := v.embedding.CosineSimilarity(queryVector, docVector)
-			if score > 0.1 { // Threshold for relevance
+			if score > minScore {
 				results = append(results, DocumentWithScore{
 					Document: *doc,
 					Score:    score,
@@ -238,4 +369,33 @@ func (v *VectorKnowledgeService) VectorSearch(ctx context.Context, query string,
 	}
 	
 	return results, nil
-}
\ No newline at end of file
+}
+
+// BestSection returns the section of doc most similar to the query and its score.
+// Documents without sections are treated as a single section.
+func (v *VectorKnowledgeService) BestSection(doc Document, query string) (Section, float32) {
+	sections := doc.Sections
+	if len(sections) == 0 {
+		sections = []Section{{Title: doc.Title, Content: doc.Content}}
+	}
+	
+	queryVector, err := v.queryEmbedding(query)
+	if err != nil {
+		return sections[0], 0
+	}
+	
+	best, bestScore := sections[0], float32(-1)
+	for _, section := range sections {
+		sectionVector, err := v.embedding.GetEmbedding(section.Title + "\n" + section.Content)
+		if err != nil {
+			continue
+		}
+		if score := v.embedding.CosineSimilarity(queryVector, sectionVector); score > bestScore {
+			best, bestScore = section, score
+		}
+	}
+	if bestScore < 0 {
+		bestScore = 0
+	}
+	return best, bestScore
+}
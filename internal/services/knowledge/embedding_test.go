@@ -0,0 +1,242 @@
+package knowledge
+
+import (
+	"context"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+var embeddingDocs = []Document{
+	{ID: "library", Content: "The library opens at eight and closes at ten. Library cards are free."},
+	{ID: "canteen", Content: "The canteen serves lunch from eleven. Vegetarian lunch is available."},
+	{ID: "sports", Content: "The sports hall opens at seven. Bring your student card."},
+}
+
+func newTestEmbedding() *SimpleEmbeddingService {
+	s := NewSimpleEmbeddingService()
+	s.BuildVocabulary(embeddingDocs)
+	return s
+}
+
+func vectorNorm(vector []float32) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum)
+}
+
+func TestEmbeddingNormalized(t *testing.T) {
+	s := newTestEmbedding()
+	texts := []string{
+		embeddingDocs[0].Content,
+		"When does the library open?",
+		"library library library lunch",
+		"words nobody wrote before", // unseen, nothing to normalize
+	}
+	for _, text := range texts {
+		vector, err := s.GetEmbedding(text)
+		if err != nil {
+			t.Fatalf("GetEmbedding(%q): %v", text, err)
+		}
+		// Every vector has the vocabulary's dimension, whatever the text
+		if len(vector) != s.Dimension() || s.Dimension() != len(s.vocabulary) {
+			t.Errorf("GetEmbedding(%q) has %d dimensions, want %d", text, len(vector), len(s.vocabulary))
+		}
+		norm := vectorNorm(vector)
+		if norm != 0 && math.Abs(norm-1) > 1e-6 {
+			t.Errorf("GetEmbedding(%q) has norm %g, want 1", text, norm)
+		}
+	}
+}
+
+func TestEmbeddingSimilarityRange(t *testing.T) {
+	s := newTestEmbedding()
+	queries := []string{"When does the library open?", "vegetarian lunch", "student card for the hall", "unknown words only"}
+	for _, query := range queries {
+		queryVector, _ := s.GetEmbedding(query)
+		for _, doc := range embeddingDocs {
+			docVector, _ := s.GetEmbedding(doc.Content)
+			score := s.CosineSimilarity(queryVector, docVector)
+			if score < 0 || score > 1 {
+				t.Errorf("similarity of %q and %s = %g, want within [0, 1]", query, doc.ID, score)
+			}
+		}
+	}
+
+	// A document is most similar to itself
+	for _, doc := range embeddingDocs {
+		vector, _ := s.GetEmbedding(doc.Content)
+		if score := s.CosineSimilarity(vector, vector); math.Abs(float64(score)-1) > 1e-6 {
+			t.Errorf("self similarity of %s = %g, want 1", doc.ID, score)
+		}
+	}
+}
+
+func TestEmbeddingSimilarityStable(t *testing.T) {
+	s := newTestEmbedding()
+	docVector, _ := s.GetEmbedding(embeddingDocs[0].Content)
+
+	query, _ := s.GetEmbedding("library opens")
+	again, _ := s.GetEmbedding("library opens")
+	// Words unseen when the vocabulary was built don't shift the score
+	withUnseen, _ := s.GetEmbedding("library opens zebra quantum")
+
+	score := s.CosineSimilarity(query, docVector)
+	if score <= 0 {
+		t.Fatalf("similarity = %g, want the library document to match", score)
+	}
+	if repeated := s.CosineSimilarity(again, docVector); repeated != score {
+		t.Errorf("repeated query scores %g, want %g", repeated, score)
+	}
+	if unseen := s.CosineSimilarity(withUnseen, docVector); math.Abs(float64(unseen-score)) > 1e-6 {
+		t.Errorf("query with unseen words scores %g, want %g", unseen, score)
+	}
+}
+
+func TestCosineSimilarityLengths(t *testing.T) {
+	s := NewSimpleEmbeddingService()
+	tests := []struct {
+		name string
+		a, b []float32
+		want float32
+	}{
+		{name: "empty", a: nil, b: []float32{1}, want: 0},
+		{name: "zero vector", a: []float32{0, 0}, b: []float32{1, 0}, want: 0},
+		{name: "same", a: []float32{0.6, 0.8}, b: []float32{0.6, 0.8}, want: 1},
+		{name: "orthogonal", a: []float32{1, 0}, b: []float32{0, 1}, want: 0},
+		{name: "longer vector compared over the common prefix", a: []float32{1, 0}, b: []float32{1, 0, 5}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.CosineSimilarity(tt.a, tt.b); math.Abs(float64(got-tt.want)) > 1e-6 {
+				t.Errorf("CosineSimilarity = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestVectorService returns a vector knowledge base of the library and
+// canteen documents
+func newTestVectorService(t *testing.T) *VectorKnowledgeService {
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{
+		"library.md": "# Campus library\n## Hours\nThe library opens at eight and closes at ten.\n## Cards\nLibrary cards are free for students.",
+		"canteen.md": "# Canteen\nThe canteen serves vegetarian lunch from eleven.",
+		"sports.md":  "# Sports\nThe sports hall opens at seven.",
+	})
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	v := NewVectorKnowledgeService(logger)
+	if err := v.LoadKnowledgeBase(context.Background(), dir); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+	return v
+}
+
+func TestScoreDocumentsBelowThreshold(t *testing.T) {
+	v := newTestVectorService(t)
+	ctx := context.Background()
+	const query = "library opens at eight"
+
+	scored, err := v.ScoreDocuments(ctx, query, 5)
+	if err != nil {
+		t.Fatalf("ScoreDocuments: %v", err)
+	}
+	searched, err := v.VectorSearch(ctx, query, 5)
+	if err != nil {
+		t.Fatalf("VectorSearch: %v", err)
+	}
+	if len(scored) == 0 || scored[0].Document.ID != "library" {
+		t.Fatalf("ScoreDocuments = %+v, want the library first", scored)
+	}
+
+	// Scoring keeps every similar document, searching only the relevant ones
+	for _, result := range searched {
+		if result.Score <= RelevanceThreshold {
+			t.Errorf("VectorSearch returned %s scoring %g, at most the threshold", result.Document.ID, result.Score)
+		}
+	}
+	var below int
+	for _, result := range scored {
+		if result.Score <= RelevanceThreshold {
+			below++
+		}
+	}
+	if below == 0 || len(scored) != len(searched)+below {
+		t.Errorf("ScoreDocuments found %d documents, %d below the threshold, VectorSearch %d", len(scored), below, len(searched))
+	}
+}
+
+func TestBestSection(t *testing.T) {
+	v := newTestVectorService(t)
+	library, err := v.GetDocument("library")
+	if err != nil {
+		t.Fatalf("GetDocument: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "library opens at eight", want: "Hours"},
+		{query: "library cards free for students", want: "Cards"},
+	}
+	for _, tt := range tests {
+		section, score := v.BestSection(*library, tt.query)
+		if section.Title != tt.want || score <= 0 {
+			t.Errorf("BestSection(%q) = %s (%g), want %s", tt.query, section.Title, score, tt.want)
+		}
+	}
+
+	// A document without sections is a section of its own
+	plain := Document{Title: "Plain", Content: "no headings here"}
+	if section, _ := v.BestSection(plain, "headings"); section.Title != "Plain" || section.Content != plain.Content {
+		t.Errorf("BestSection of a plain document = %+v", section)
+	}
+}
+
+func TestVectorReloadDocument(t *testing.T) {
+	ctx := context.Background()
+	v := newTestVectorService(t)
+	canteenVector := append([]float32(nil), v.docVectors["canteen"]...)
+	libraryVector := append([]float32(nil), v.docVectors["library"]...)
+
+	library, _ := v.GetDocument("library")
+	canteen, _ := v.GetDocument("canteen")
+	dir := filepath.Dir(library.FilePath)
+	writeDocs(t, dir, map[string]string{
+		"library.md": "# Campus library\nThe library now opens at seven and has a canteen.",
+		"canteen.md": "# Canteen\nClosed for renovation.",
+	})
+
+	if _, err := v.ReloadDocument(ctx, "library"); err != nil {
+		t.Fatalf("ReloadDocument: %v", err)
+	}
+	if reflect.DeepEqual(v.docVectors["library"], libraryVector) {
+		t.Error("reloaded document kept its old vector")
+	}
+	if !reflect.DeepEqual(v.docVectors["canteen"], canteenVector) {
+		t.Error("other document's vector changed")
+	}
+	if doc, _ := v.GetDocument("canteen"); doc.Content != canteen.Content {
+		t.Errorf("other document's content became %q", doc.Content)
+	}
+
+	// The vector of a removed document goes with it
+	if err := os.Remove(library.FilePath); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := v.ReloadDocument(ctx, "library"); err != nil || doc != nil {
+		t.Fatalf("ReloadDocument of a removed file = %+v, %v, want nil, nil", doc, err)
+	}
+	if _, ok := v.docVectors["library"]; ok {
+		t.Error("removed document kept its vector")
+	}
+}
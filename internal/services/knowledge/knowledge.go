@@ -2,17 +2,22 @@ package knowledge
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// ErrRefreshInProgress is returned when a refresh is requested while another one is running
+var ErrRefreshInProgress = errors.New("knowledge base refresh already in progress")
+
 // Document represents a knowledge document
 type Document struct {
 	ID       string
@@ -32,18 +37,23 @@ type Section struct {
 
 // Service interface for knowledge base operations
 type Service interface {
-	LoadKnowledgeBase(ctx context.Context, dir string) error
+	LoadKnowledgeBase(ctx context.Context, dirs ...string) error
 	SearchDocuments(ctx context.Context, query string, limit int) ([]Document, error)
 	GetAllDocuments() []Document
 	GetDocument(id string) (*Document, error)
 	RefreshKnowledgeBase(ctx context.Context) error
+	// ReloadDocument re-reads a single document, returning nil when its file is gone
+	ReloadDocument(ctx context.Context, id string) (*Document, error)
 }
 
 // KnowledgeService implements the knowledge base service
 type KnowledgeService struct {
 	documents   map[string]*Document
 	documentsRW sync.RWMutex
-	knowledgeDir string
+	knowledgeDirs []string
+	refreshing  atomic.Bool
+	searches    searchCache[[]string] // query -> IDs of the matching documents
+	gate        searchGate
 	logger      *logrus.Logger
 }
 
@@ -55,14 +65,24 @@ func NewKnowledgeService(logger *logrus.Logger) Service {
 	}
 }
 
-// LoadKnowledgeBase loads all markdown files from the specified directory
-func (s *KnowledgeService) LoadKnowledgeBase(ctx context.Context, dir string) error {
-	s.knowledgeDir = dir
-	s.logger.WithField("dir", dir).Info("Loading knowledge base")
+// SetSearchCacheTTL keeps search results for ttl so repeated queries skip the
+// search; 0 disables caching. Cached results are dropped whenever documents change.
+func (s *KnowledgeService) SetSearchCacheTTL(ttl time.Duration) {
+	s.searches.setTTL(ttl)
+}
+
+// LoadKnowledgeBase loads all markdown files from the specified directories.
+// With several directories, document IDs are prefixed by their source directory
+// so that files with the same relative path don't collide.
+func (s *KnowledgeService) LoadKnowledgeBase(ctx context.Context, dirs ...string) error {
+	s.knowledgeDirs = dirs
+	s.logger.WithField("dirs", dirs).Info("Loading knowledge base")
 	
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create knowledge directory: %w", err)
+	// Create directories if they don't exist
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create knowledge directory: %w", err)
+		}
 	}
 	
 	s.documentsRW.Lock()
@@ -70,7 +90,21 @@ func (s *KnowledgeService) LoadKnowledgeBase(ctx context.Context, dir string) er
 	
 	// Clear existing documents
 	s.documents = make(map[string]*Document)
+	s.searches.clear()
+	
+	prefixes := sourcePrefixes(dirs)
+	for i, dir := range dirs {
+		if err := s.loadDirectory(dir, prefixes[i]); err != nil {
+			return err
+		}
+	}
 	
+	s.logger.WithField("count", len(s.documents)).Info("Knowledge base loaded")
+	return nil
+}
+
+// loadDirectory walks a directory and adds its documents; the caller holds the lock
+func (s *KnowledgeService) loadDirectory(dir, prefix string) error {
 	// Walk through the directory
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -83,7 +117,7 @@ func (s *KnowledgeService) LoadKnowledgeBase(ctx context.Context, dir string) er
 		}
 		
 		// Load the document
-		doc, err := s.loadDocument(path)
+		doc, err := s.loadDocument(dir, prefix, path)
 		if err != nil {
 			s.logger.WithError(err).WithField("path", path).Warn("Failed to load document")
 			return nil // Continue with other files
@@ -100,15 +134,33 @@ func (s *KnowledgeService) LoadKnowledgeBase(ctx context.Context, dir string) er
 	})
 	
 	if err != nil {
-		return fmt.Errorf("failed to walk knowledge directory: %w", err)
+		return fmt.Errorf("failed to walk knowledge directory %s: %w", dir, err)
 	}
-	
-	s.logger.WithField("count", len(s.documents)).Info("Knowledge base loaded")
 	return nil
 }
 
+// sourcePrefixes returns the document ID prefix for each directory.
+// A single directory keeps unprefixed IDs for backward compatibility.
+func sourcePrefixes(dirs []string) []string {
+	prefixes := make([]string, len(dirs))
+	if len(dirs) <= 1 {
+		return prefixes
+	}
+	
+	used := make(map[string]int)
+	for i, dir := range dirs {
+		name := filepath.Base(filepath.Clean(dir))
+		used[name]++
+		if used[name] > 1 {
+			name = fmt.Sprintf("%s%d", name, used[name])
+		}
+		prefixes[i] = name + ":"
+	}
+	return prefixes
+}
+
 // loadDocument loads a single markdown document
-func (s *KnowledgeService) loadDocument(path string) (*Document, error) {
+func (s *KnowledgeService) loadDocument(root, prefix, path string) (*Document, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
@@ -120,10 +172,15 @@ func (s *KnowledgeService) loadDocument(path string) (*Document, error) {
 	}
 	
 	// Generate document ID from file path
-	relPath, _ := filepath.Rel(s.knowledgeDir, path)
+	relPath, _ := filepath.Rel(root, path)
 	id := strings.TrimSuffix(relPath, filepath.Ext(relPath))
-	id = strings.ReplaceAll(id, string(filepath.Separator), "_")
+	id = prefix + strings.ReplaceAll(id, string(filepath.Separator), "_")
 	
+	return s.readDocument(id, path, content, info)
+}
+
+// readDocument parses the content of the file at path into a document with the given ID
+func (s *KnowledgeService) readDocument(id, path string, content []byte, info os.FileInfo) (*Document, error) {
 	// Parse document
 	doc := &Document{
 		ID:       id,
@@ -200,10 +257,28 @@ func (s *KnowledgeService) parseDocument(doc *Document) {
 
 // SearchDocuments searches for documents matching the query
 func (s *KnowledgeService) SearchDocuments(ctx context.Context, query string, limit int) ([]Document, error) {
+	release, err := s.gate.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	
 	s.documentsRW.RLock()
 	defer s.documentsRW.RUnlock()
 	
 	query = strings.ToLower(query)
+	cacheKey := fmt.Sprintf("%d:%s", limit, query)
+	ids, generation, cached := s.searches.get(cacheKey)
+	if cached {
+		results := make([]Document, 0, len(ids))
+		for _, id := range ids {
+			if doc, exists := s.documents[id]; exists {
+				results = append(results, *doc)
+			}
+		}
+		return results, nil
+	}
+	
 	results := make([]Document, 0)
 	
 	// Simple keyword matching for now
@@ -229,12 +304,14 @@ func (s *KnowledgeService) SearchDocuments(ctx context.Context, query string, li
 		
 		if score > 0 {
 			results = append(results, *doc)
+			ids = append(ids, doc.ID)
 			if len(results) >= limit {
 				break
 			}
 		}
 	}
 	
+	s.searches.set(cacheKey, ids, generation)
 	return results, nil
 }
 
@@ -264,7 +341,66 @@ func (s *KnowledgeService) GetDocument(id string) (*Document, error) {
 	return doc, nil
 }
 
-// RefreshKnowledgeBase reloads the knowledge base
+// ReloadDocument re-reads the file of a single document, leaving the others untouched.
+// The document is removed when its file no longer exists, in which case nil is returned.
+func (s *KnowledgeService) ReloadDocument(ctx context.Context, id string) (*Document, error) {
+	s.documentsRW.Lock()
+	defer s.documentsRW.Unlock()
+	
+	return s.reloadDocumentLocked(id)
+}
+
+// reloadDocumentLocked implements ReloadDocument; the caller holds the write lock
+func (s *KnowledgeService) reloadDocumentLocked(id string) (*Document, error) {
+	doc, exists := s.documents[id]
+	if !exists {
+		return nil, fmt.Errorf("document not found: %s", id)
+	}
+	
+	s.searches.clear()
+	content, err := os.ReadFile(doc.FilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		delete(s.documents, id)
+		s.logger.WithField("id", id).Info("Removed document whose file is gone")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	
+	info, err := os.Stat(doc.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	
+	reloaded, err := s.readDocument(id, doc.FilePath, content, info)
+	if err != nil {
+		return nil, err
+	}
+	s.documents[id] = reloaded
+	s.logger.WithFields(logrus.Fields{
+		"id":    id,
+		"title": reloaded.Title,
+	}).Info("Reloaded document")
+	
+	copied := *reloaded
+	return &copied, nil
+}
+
+// RefreshKnowledgeBase reloads the knowledge base from all directories
 func (s *KnowledgeService) RefreshKnowledgeBase(ctx context.Context) error {
-	return s.LoadKnowledgeBase(ctx, s.knowledgeDir)
+	return s.exclusiveRefresh(func() error {
+		return s.LoadKnowledgeBase(ctx, s.knowledgeDirs...)
+	})
+}
+
+// exclusiveRefresh runs reload unless another refresh is already running,
+// in which case ErrRefreshInProgress is returned
+func (s *KnowledgeService) exclusiveRefresh(reload func() error) error {
+	if !s.refreshing.CompareAndSwap(false, true) {
+		return ErrRefreshInProgress
+	}
+	defer s.refreshing.Store(false)
+	
+	return reload()
 }
\ No newline at end of file
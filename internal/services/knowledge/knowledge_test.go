@@ -0,0 +1,202 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestKnowledgeService() *KnowledgeService {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewKnowledgeService(logger).(*KnowledgeService)
+}
+
+// writeDocs writes markdown files, keyed by their path relative to dir
+func writeDocs(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// documentTitles maps the loaded document IDs to their titles
+func documentTitles(s *KnowledgeService) map[string]string {
+	titles := make(map[string]string)
+	for _, doc := range s.GetAllDocuments() {
+		titles[doc.ID] = doc.Title
+	}
+	return titles
+}
+
+func TestLoadKnowledgeBaseDirectories(t *testing.T) {
+	root := t.TempDir()
+	shared := filepath.Join(root, "shared")
+	local := filepath.Join(root, "local")
+	writeDocs(t, shared, map[string]string{
+		"guide.md":         "# Shared guide",
+		"library/hours.md": "# Library hours",
+	})
+	writeDocs(t, local, map[string]string{
+		"guide.md":  "# Local guide",
+		"notes.txt": "not markdown",
+	})
+
+	s := newTestKnowledgeService()
+	if err := s.LoadKnowledgeBase(context.Background(), shared, local); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+
+	// Files with the same relative path are kept apart by their source
+	want := map[string]string{
+		"shared:guide":         "Shared guide",
+		"shared:library_hours": "Library hours",
+		"local:guide":          "Local guide",
+	}
+	if got := documentTitles(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("documents = %v, want %v", got, want)
+	}
+
+	// A refresh reloads every directory
+	writeDocs(t, local, map[string]string{"canteen.md": "# Canteen"})
+	if err := s.RefreshKnowledgeBase(context.Background()); err != nil {
+		t.Fatalf("RefreshKnowledgeBase: %v", err)
+	}
+	want["local:canteen"] = "Canteen"
+	if got := documentTitles(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("documents after refresh = %v, want %v", got, want)
+	}
+}
+
+func TestLoadKnowledgeBaseSingleDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{"guide.md": "# Guide", "library/hours.md": "# Library hours"})
+
+	s := newTestKnowledgeService()
+	if err := s.LoadKnowledgeBase(context.Background(), dir); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+
+	// IDs stay unprefixed, as before several directories were supported
+	var ids []string
+	for id := range documentTitles(s) {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if want := []string{"guide", "library_hours"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("document IDs = %v, want %v", ids, want)
+	}
+}
+
+func TestSourcePrefixes(t *testing.T) {
+	tests := []struct {
+		name string
+		dirs []string
+		want []string
+	}{
+		{name: "single", dirs: []string{"data/knowledge"}, want: []string{""}},
+		{name: "distinct names", dirs: []string{"shared/docs", "deploy/extra/"}, want: []string{"docs:", "extra:"}},
+		{name: "same names", dirs: []string{"shared/docs", "deploy/docs", "more/docs"}, want: []string{"docs:", "docs2:", "docs3:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourcePrefixes(tt.dirs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sourcePrefixes(%v) = %q, want %q", tt.dirs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshNotStartedTwice(t *testing.T) {
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{"guide.md": "# Guide"})
+	s := newTestKnowledgeService()
+	if err := s.LoadKnowledgeBase(context.Background(), dir); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+
+	// A refresh is running...
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.exclusiveRefresh(func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// ...so another one is refused rather than started
+	writeDocs(t, dir, map[string]string{"faq.md": "# FAQ"})
+	if err := s.RefreshKnowledgeBase(context.Background()); !errors.Is(err, ErrRefreshInProgress) {
+		t.Fatalf("RefreshKnowledgeBase during a refresh = %v, want ErrRefreshInProgress", err)
+	}
+	if got := len(s.GetAllDocuments()); got != 1 {
+		t.Errorf("refused refresh loaded %d documents, want the 1 loaded before", got)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("running refresh: %v", err)
+	}
+	// Once it finished, refreshing works again
+	if err := s.RefreshKnowledgeBase(context.Background()); err != nil {
+		t.Fatalf("RefreshKnowledgeBase after the refresh: %v", err)
+	}
+	if got := len(s.GetAllDocuments()); got != 2 {
+		t.Errorf("got %d documents after the refresh, want 2", got)
+	}
+}
+
+func TestReloadDocument(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{"guide.md": "# Guide", "canteen.md": "# Canteen"})
+	s := newTestKnowledgeService()
+	if err := s.LoadKnowledgeBase(ctx, dir); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+
+	// Both files change, only the reloaded document picks it up
+	writeDocs(t, dir, map[string]string{"guide.md": "# New guide\nMore text", "canteen.md": "# New canteen"})
+	doc, err := s.ReloadDocument(ctx, "guide")
+	if err != nil {
+		t.Fatalf("ReloadDocument: %v", err)
+	}
+	if doc == nil || doc.Title != "New guide" {
+		t.Fatalf("ReloadDocument = %+v, want the new guide", doc)
+	}
+	if want := map[string]string{"guide": "New guide", "canteen": "Canteen"}; !reflect.DeepEqual(documentTitles(s), want) {
+		t.Errorf("documents = %v, want %v", documentTitles(s), want)
+	}
+
+	// A document whose file is gone is removed
+	if err := os.Remove(filepath.Join(dir, "guide.md")); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := s.ReloadDocument(ctx, "guide"); err != nil || doc != nil {
+		t.Fatalf("ReloadDocument of a removed file = %+v, %v, want nil, nil", doc, err)
+	}
+	if want := map[string]string{"canteen": "Canteen"}; !reflect.DeepEqual(documentTitles(s), want) {
+		t.Errorf("documents = %v, want %v", documentTitles(s), want)
+	}
+
+	if _, err := s.ReloadDocument(ctx, "unknown"); err == nil {
+		t.Error("ReloadDocument of an unknown document passed")
+	}
+}
@@ -0,0 +1,72 @@
+package knowledge
+
+import (
+	"sync"
+	"time"
+)
+
+// maxSearchCacheEntries bounds a search cache; it is emptied when full
+const maxSearchCacheEntries = 1000
+
+// searchCache remembers values computed for search queries for a short time.
+// A zero TTL disables it. Every clear starts a new generation, and values
+// computed before the clear are not stored, so a reindex racing a search
+// can't leave stale results behind.
+type searchCache[V any] struct {
+	ttl        time.Duration
+	mu         sync.Mutex
+	entries    map[string]searchCacheEntry[V]
+	generation uint64
+}
+
+type searchCacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// get returns the cached value for key and the generation to pass to set
+func (c *searchCache[V]) get(key string) (V, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	if c.ttl <= 0 {
+		return zero, c.generation, false
+	}
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return zero, c.generation, false
+	}
+	return entry.value, c.generation, true
+}
+
+// set stores value for key unless the cache was cleared since generation
+func (c *searchCache[V]) set(key string, value V, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 || generation != c.generation {
+		return
+	}
+	if c.entries == nil || len(c.entries) >= maxSearchCacheEntries {
+		c.entries = make(map[string]searchCacheEntry[V])
+	}
+	c.entries[key] = searchCacheEntry[V]{value: value, expires: time.Now().Add(c.ttl)}
+}
+
+// clear drops every entry, e.g. after the documents changed
+func (c *searchCache[V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = nil
+	c.generation++
+}
+
+// setTTL changes how long entries are kept and drops the current ones
+func (c *searchCache[V]) setTTL(ttl time.Duration) {
+	c.clear()
+	c.mu.Lock()
+	c.ttl = ttl
+	c.mu.Unlock()
+}
@@ -0,0 +1,128 @@
+package knowledge
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+// searchIDs returns the sorted IDs of the documents found for query
+func searchIDs(t *testing.T, s Service, query string) []string {
+	t.Helper()
+	docs, err := s.SearchDocuments(context.Background(), query, 5)
+	if err != nil {
+		t.Fatalf("SearchDocuments(%q): %v", query, err)
+	}
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSearchCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{
+		"library.md": "# Library\nThe library opens at eight.",
+		"canteen.md": "# Canteen\nLunch from eleven.",
+	})
+	s := newTestKnowledgeService()
+	s.SetSearchCacheTTL(time.Hour)
+	if err := s.LoadKnowledgeBase(ctx, dir); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+	if got := searchIDs(t, s, "library"); len(got) != 1 || got[0] != "library" {
+		t.Fatalf("first search found %v, want [library]", got)
+	}
+
+	// A document matching the query appears without a reindex; the repeated
+	// query still gets the cached results
+	s.documentsRW.Lock()
+	s.documents["hours"] = &Document{ID: "hours", Title: "Library hours", Content: "library"}
+	s.documentsRW.Unlock()
+	if got := searchIDs(t, s, "Library"); len(got) != 1 || got[0] != "library" {
+		t.Errorf("repeated search found %v, want the cached [library]", got)
+	}
+
+	// Reindexing drops the cached results
+	writeDocs(t, dir, map[string]string{"hours.md": "# Library hours\nSee the library."})
+	if err := s.LoadKnowledgeBase(ctx, dir); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+	if got := searchIDs(t, s, "library"); len(got) != 2 || got[0] != "hours" || got[1] != "library" {
+		t.Errorf("search after the reindex found %v, want [hours library]", got)
+	}
+
+	// So does reloading a single document
+	writeDocs(t, dir, map[string]string{"hours.md": "# Opening times"})
+	if _, err := s.ReloadDocument(ctx, "hours"); err != nil {
+		t.Fatalf("ReloadDocument: %v", err)
+	}
+	if got := searchIDs(t, s, "library"); len(got) != 1 || got[0] != "library" {
+		t.Errorf("search after the reload found %v, want [library]", got)
+	}
+}
+
+func TestSearchCacheTTL(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var c searchCache[string]
+		c.set("q", "cached", 0)
+		if _, _, ok := c.get("q"); ok {
+			t.Error("cache without a TTL returned a value")
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		var c searchCache[string]
+		c.setTTL(50 * time.Millisecond)
+		_, generation, _ := c.get("q")
+		c.set("q", "cached", generation)
+		if value, _, ok := c.get("q"); !ok || value != "cached" {
+			t.Fatalf("get = %q, %v, want the cached value", value, ok)
+		}
+		time.Sleep(60 * time.Millisecond)
+		if _, _, ok := c.get("q"); ok {
+			t.Error("value still cached after its TTL")
+		}
+	})
+
+	t.Run("computed before a clear", func(t *testing.T) {
+		var c searchCache[string]
+		c.setTTL(time.Hour)
+		_, generation, _ := c.get("q")
+		// A reindex finishes while the search runs
+		c.clear()
+		c.set("q", "stale", generation)
+		if value, _, ok := c.get("q"); ok {
+			t.Errorf("stale value %q cached", value)
+		}
+	})
+}
+
+func TestQueryEmbeddingCache(t *testing.T) {
+	v := newTestVectorService(t)
+	v.SetSearchCacheTTL(time.Hour)
+
+	first, err := v.queryEmbedding("library hours")
+	if err != nil {
+		t.Fatalf("queryEmbedding: %v", err)
+	}
+	if _, _, ok := v.queries.get("library hours"); !ok {
+		t.Fatal("query embedding not cached")
+	}
+	again, _ := v.queryEmbedding("library hours")
+	if &first[0] != &again[0] {
+		t.Error("repeated query computed its embedding again")
+	}
+
+	// The vocabulary is rebuilt on reindex, and the embeddings with it
+	if err := v.LoadKnowledgeBase(context.Background(), v.knowledgeDirs...); err != nil {
+		t.Fatalf("LoadKnowledgeBase: %v", err)
+	}
+	if _, _, ok := v.queries.get("library hours"); ok {
+		t.Error("query embedding still cached after the reindex")
+	}
+}
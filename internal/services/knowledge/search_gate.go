@@ -0,0 +1,51 @@
+package knowledge
+
+import (
+	"context"
+	"time"
+)
+
+// searchGate bounds how many searches run at once, so a burst of queries
+// doesn't pile up on the document lock, and reports how long each took.
+// It is configured once at startup, before any search runs.
+type searchGate struct {
+	slots   chan struct{}       // nil when searches aren't limited
+	observe func(time.Duration) // optional, receives each search's duration
+}
+
+// enter waits for a free slot, giving up when ctx ends. The returned function
+// must be called once the search is done.
+func (g *searchGate) enter(ctx context.Context) (func(), error) {
+	if g.slots != nil {
+		select {
+		case g.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	
+	start := time.Now()
+	return func() {
+		if g.observe != nil {
+			g.observe(time.Since(start))
+		}
+		if g.slots != nil {
+			<-g.slots
+		}
+	}, nil
+}
+
+// SetSearchConcurrency caps concurrent searches at n; further searches wait
+// for a free slot. 0 or less leaves searches unlimited.
+func (s *KnowledgeService) SetSearchConcurrency(n int) {
+	if n <= 0 {
+		s.gate.slots = nil
+		return
+	}
+	s.gate.slots = make(chan struct{}, n)
+}
+
+// SetSearchObserver registers a function receiving the duration of every search
+func (s *KnowledgeService) SetSearchObserver(observe func(time.Duration)) {
+	s.gate.observe = observe
+}
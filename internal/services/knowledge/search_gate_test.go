@@ -0,0 +1,145 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSearchGateLimit(t *testing.T) {
+	var g searchGate
+	g.slots = make(chan struct{}, 2)
+	var observed atomic.Int32
+	g.observe = func(time.Duration) { observed.Add(1) }
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := g.enter(context.Background())
+			if err != nil {
+				t.Errorf("enter: %v", err)
+				return
+			}
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("%d searches ran at once, want 2", got)
+	}
+	if got := observed.Load(); got != 10 {
+		t.Errorf("observed %d search durations, want 10", got)
+	}
+}
+
+func TestSearchGateGivesUp(t *testing.T) {
+	var g searchGate
+	g.slots = make(chan struct{}, 1)
+	release, err := g.enter(context.Background())
+	if err != nil {
+		t.Fatalf("enter: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := g.enter(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("enter while full = %v, want the context's error", err)
+	}
+
+	// The slot is free again once released
+	release()
+	if release, err := g.enter(context.Background()); err != nil {
+		t.Errorf("enter after release: %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestSearchesUnderContention(t *testing.T) {
+	dir := t.TempDir()
+	docs := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		docs[fmt.Sprintf("doc%02d.md", i)] = fmt.Sprintf("# Topic %02d\nAbout subject%02d and the campus.", i, i)
+	}
+	writeDocs(t, dir, docs)
+
+	keyword := newTestKnowledgeService()
+	vector := NewVectorKnowledgeService(keyword.logger)
+	for _, s := range []Service{keyword, vector} {
+		if err := s.LoadKnowledgeBase(context.Background(), dir); err != nil {
+			t.Fatalf("LoadKnowledgeBase: %v", err)
+		}
+	}
+	keyword.SetSearchConcurrency(2)
+	vector.SetSearchConcurrency(2)
+
+	searches := map[string]func(query string) ([]string, error){
+		"keyword": func(query string) ([]string, error) {
+			docs, err := keyword.SearchDocuments(context.Background(), query, 5)
+			ids := make([]string, len(docs))
+			for i, doc := range docs {
+				ids[i] = doc.ID
+			}
+			return ids, err
+		},
+		"vector": func(query string) ([]string, error) {
+			docs, err := vector.VectorSearch(context.Background(), query, 5)
+			ids := make([]string, len(docs))
+			for i, doc := range docs {
+				ids[i] = doc.Document.ID
+			}
+			return ids, err
+		},
+	}
+	for name, search := range searches {
+		t.Run(name, func(t *testing.T) {
+			// Results found one at a time
+			want := make(map[string][]string)
+			for i := 0; i < 20; i++ {
+				query := fmt.Sprintf("subject%02d", i)
+				ids, err := search(query)
+				if err != nil || len(ids) == 0 {
+					t.Fatalf("search(%q) = %v, %v", query, ids, err)
+				}
+				want[query] = ids
+			}
+
+			var wg sync.WaitGroup
+			for round := 0; round < 5; round++ {
+				for query, ids := range want {
+					wg.Add(1)
+					go func(query string, ids []string) {
+						defer wg.Done()
+						got, err := search(query)
+						if err != nil {
+							t.Errorf("search(%q): %v", query, err)
+							return
+						}
+						if !reflect.DeepEqual(got, ids) {
+							t.Errorf("concurrent search for %q found %v, want %v", query, got, ids)
+						}
+					}(query, ids)
+				}
+			}
+			wg.Wait()
+		})
+	}
+}
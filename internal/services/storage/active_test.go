@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// unreadableContexts is memory storage failing to decode the context of chat bad
+type unreadableContexts struct {
+	*MemoryStorage
+	bad int64
+}
+
+func (s unreadableContexts) GetContext(ctx context.Context, chatID int64) (*models.ChatContext, error) {
+	if chatID == s.bad {
+		return nil, errors.New("failed to decode context")
+	}
+	return s.MemoryStorage.GetContext(ctx, chatID)
+}
+
+func TestActiveContexts(t *testing.T) {
+	ctx := context.Background()
+	memory := newTestMemoryStorage()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	m := &Manager{storage: unreadableContexts{MemoryStorage: memory, bad: 5}, logger: logger}
+
+	idle := map[int64]time.Duration{
+		1: 10 * time.Minute,
+		2: time.Minute,
+		3: 3 * time.Hour,
+		4: 30 * time.Minute,
+		5: time.Minute, // unreadable
+	}
+	for chatID, idleFor := range idle {
+		memory.SaveSettings(ctx, chatID, &models.ChatSettings{})
+		memory.SaveContext(ctx, &models.ChatContext{ChatID: chatID, LastActivity: time.Now().Add(-idleFor)})
+	}
+	// A chat with settings but no context is never active
+	memory.SaveSettings(ctx, 6, &models.ChatSettings{})
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   []int64
+	}{
+		{name: "hour", window: time.Hour, want: []int64{2, 1, 4}},
+		{name: "few minutes", window: 5 * time.Minute, want: []int64{2}},
+		{name: "day", window: 24 * time.Hour, want: []int64{2, 1, 4, 3}},
+		{name: "none", window: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, err := m.ActiveContexts(ctx, tt.window)
+			if err != nil {
+				t.Fatalf("ActiveContexts: %v", err)
+			}
+			var got []int64
+			for _, chatCtx := range active {
+				got = append(got, chatCtx.ChatID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("active chats = %v, want %v, most recent first", got, tt.want)
+			}
+		})
+	}
+}
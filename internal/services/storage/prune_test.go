@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestMemoryPruneStale(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemoryStorage()
+	contexts := map[int64]time.Duration{
+		1: time.Minute,    // active
+		2: 48 * time.Hour, // idle past retention
+		3: 0,              // never active, kept
+		4: 23 * time.Hour, // idle, within retention
+	}
+	for chatID, idleFor := range contexts {
+		chatCtx := &models.ChatContext{ChatID: chatID}
+		if idleFor > 0 {
+			chatCtx.LastActivity = time.Now().Add(-idleFor)
+		}
+		m.SaveContext(ctx, chatCtx)
+	}
+	m.SetChatState(ctx, 1, "session", "active chat", 0)
+	m.SetChatState(ctx, 2, "session", "pruned chat", 0)
+	m.SetChatState(ctx, 2, "cooldown", "expires by itself", time.Hour)
+	m.SetChatState(ctx, 5, "session", "no context", 0)
+	m.SetUserState(ctx, 2, "pending", "user state")
+
+	removed, err := m.PruneStale(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("PruneStale: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d entries, want the idle context and 2 orphaned states", removed)
+	}
+
+	tests := []struct {
+		name string
+		get  func() bool
+		want bool
+	}{
+		{name: "active context", get: func() bool { c, _ := m.GetContext(ctx, 1); return c != nil }, want: true},
+		{name: "idle context", get: func() bool { c, _ := m.GetContext(ctx, 2); return c != nil }, want: false},
+		{name: "context without activity", get: func() bool { c, _ := m.GetContext(ctx, 3); return c != nil }, want: true},
+		{name: "context within retention", get: func() bool { c, _ := m.GetContext(ctx, 4); return c != nil }, want: true},
+		{name: "state of an active chat", get: func() bool { v, _ := m.GetChatState(ctx, 1, "session"); return v != "" }, want: true},
+		{name: "state of a pruned chat", get: func() bool { v, _ := m.GetChatState(ctx, 2, "session"); return v != "" }, want: false},
+		{name: "state with a ttl", get: func() bool { v, _ := m.GetChatState(ctx, 2, "cooldown"); return v != "" }, want: true},
+		{name: "state of a chat without context", get: func() bool { v, _ := m.GetChatState(ctx, 5, "session"); return v != "" }, want: false},
+		{name: "user state", get: func() bool { v, _ := m.GetUserState(ctx, 2, "pending"); return v != "" }, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.get(); got != tt.want {
+				t.Errorf("kept = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Nothing is left to prune the second time
+	if removed, _ := m.PruneStale(ctx, 24*time.Hour); removed != 0 {
+		t.Errorf("second run removed %d entries, want 0", removed)
+	}
+}
+
+func TestChatStateID(t *testing.T) {
+	tests := []struct {
+		key    string
+		wantID int64
+		wantOK bool
+	}{
+		{key: "chat_state:42:session", wantID: 42, wantOK: true},
+		{key: "chat_state:-1001:last_greeting", wantID: -1001, wantOK: true},
+		{key: "chat_state:abc:session"},
+		{key: "user_state:42:session"},
+	}
+	for _, tt := range tests {
+		id, ok := chatStateID(tt.key)
+		if id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("chatStateID(%q) = %d, %v, want %d, %v", tt.key, id, ok, tt.wantID, tt.wantOK)
+		}
+	}
+}
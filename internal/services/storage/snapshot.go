@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// contextSnapshot is the file written by SaveSnapshot
+type contextSnapshot struct {
+	SavedAt  time.Time
+	Contexts map[int64]snapshotEntry
+}
+
+// snapshotEntry is one saved context and when it would have expired (zero
+// when it never expires)
+type snapshotEntry struct {
+	Expires time.Time       `json:",omitempty"`
+	Context json.RawMessage
+}
+
+// SaveSnapshot writes the contexts that haven't expired to path, returning how
+// many were saved. The file is replaced atomically so a crash mid-write keeps
+// the previous snapshot.
+func (m *MemoryStorage) SaveSnapshot(path string) (int, error) {
+	snapshot := contextSnapshot{
+		SavedAt:  time.Now(),
+		Contexts: make(map[int64]snapshotEntry),
+	}
+	for key, item := range m.contexts.Items() {
+		chatID, err := strconv.ParseInt(strings.TrimPrefix(key, "context:"), 10, 64)
+		if err != nil {
+			continue
+		}
+		data, err := json.Marshal(item.Object)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode context of chat %d: %w", chatID, err)
+		}
+		entry := snapshotEntry{Context: data}
+		if item.Expiration > 0 {
+			entry.Expires = time.Unix(0, item.Expiration)
+		}
+		snapshot.Contexts[chatID] = entry
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	return len(snapshot.Contexts), nil
+}
+
+// LoadSnapshot restores the contexts saved at path, returning how many were
+// restored. Contexts past their expiration or idle for longer than maxAge
+// (when positive) are skipped; the rest keep their remaining lifetime. A
+// missing file isn't an error.
+func (m *MemoryStorage) LoadSnapshot(path string, maxAge time.Duration) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot contextSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	now := time.Now()
+	restored := 0
+	for chatID, entry := range snapshot.Contexts {
+		ttl := cache.NoExpiration
+		if !entry.Expires.IsZero() {
+			ttl = entry.Expires.Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+
+		chatCtx, err := decodeContext(chatID, entry.Context)
+		if err != nil {
+			m.logger.WithError(err).Warn("Skipping context from snapshot")
+			continue
+		}
+		if maxAge > 0 && now.Sub(chatCtx.LastActivity) > maxAge {
+			continue
+		}
+
+		m.contexts.Set(fmt.Sprintf("context:%d", chatID), chatCtx, ttl)
+		restored++
+	}
+	return restored, nil
+}
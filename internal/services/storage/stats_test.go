@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/cf-ai-tgbot-go/internal/models"
+)
+
+func TestMemoryUserStatsConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemoryStorage()
+	const (
+		userID     = 7
+		goroutines = 20
+		updates    = 50
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				m.IncrementUserStats(ctx, userID)
+				m.IncrementUserSessions(ctx, userID)
+				m.RecordUsage(ctx, userID, "gpt-4o", 3, 2, 0.5)
+			}
+		}()
+		// Readers look at the stats, model usage included, while they change
+		go func() {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				stats, err := m.GetUserStats(ctx, userID)
+				if err != nil {
+					t.Errorf("GetUserStats: %v", err)
+					return
+				}
+				for range stats.ModelUsage {
+				}
+				stats.ModelUsage = nil
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats, err := m.GetUserStats(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserStats: %v", err)
+	}
+	const total = goroutines * updates
+	if stats.TotalMessages != total {
+		t.Errorf("TotalMessages = %d, want %d", stats.TotalMessages, total)
+	}
+	if stats.TotalSessions != total {
+		t.Errorf("TotalSessions = %d, want %d", stats.TotalSessions, total)
+	}
+	if stats.PromptTokens != 3*total || stats.CompletionTokens != 2*total {
+		t.Errorf("tokens = %d prompt, %d completion, want %d, %d", stats.PromptTokens, stats.CompletionTokens, 3*total, 2*total)
+	}
+	if stats.TotalCost != 0.5*total {
+		t.Errorf("TotalCost = %g, want %g", stats.TotalCost, 0.5*total)
+	}
+	if stats.ModelUsage["gpt-4o"] != total {
+		t.Errorf("ModelUsage = %v, want %d responses of gpt-4o", stats.ModelUsage, total)
+	}
+}
+
+func TestMemoryUpdateUserStatsIsAtomic(t *testing.T) {
+	m := newTestMemoryStorage()
+	const goroutines = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.updateUserStats(7, func(stats *models.UserStats) {
+				// Let the other goroutines run between the read and the write
+				runtime.Gosched()
+				stats.TotalMessages++
+			})
+		}()
+	}
+	wg.Wait()
+
+	if stats, _ := m.GetUserStats(context.Background(), 7); stats.TotalMessages != goroutines {
+		t.Errorf("TotalMessages = %d, want %d", stats.TotalMessages, goroutines)
+	}
+}
+
+func TestMemoryUserStatsCopies(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemoryStorage()
+	m.RecordUsage(ctx, 7, "gpt-4o", 1, 1, 0)
+
+	// Changing a returned snapshot doesn't reach the stored stats
+	stats, _ := m.GetUserStats(ctx, 7)
+	stats.TotalMessages = 100
+	stats.ModelUsage["gpt-4o"] = 100
+
+	stored, _ := m.GetUserStats(ctx, 7)
+	if stored.TotalMessages != 0 || stored.ModelUsage["gpt-4o"] != 1 {
+		t.Errorf("stored stats = %+v, want the snapshot's changes left out", stored)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cf-ai-tgbot-go/internal/config"
@@ -24,6 +28,9 @@ type Storage interface {
 	// Settings operations
 	GetSettings(ctx context.Context, chatID int64) (*models.ChatSettings, error)
 	SaveSettings(ctx context.Context, chatID int64, settings *models.ChatSettings) error
+	DeleteSettings(ctx context.Context, chatID int64) error
+	// ListChatIDs returns the chats that have stored settings
+	ListChatIDs(ctx context.Context) ([]int64, error)
 	
 	// User settings operations
 	GetUserSettings(ctx context.Context, userID int64) (*models.UserSettings, error)
@@ -32,14 +39,37 @@ type Storage interface {
 	// User stats operations
 	GetUserStats(ctx context.Context, userID int64) (*models.UserStats, error)
 	IncrementUserStats(ctx context.Context, userID int64) error
+	IncrementUserSessions(ctx context.Context, userID int64) error
+	RecordUsage(ctx context.Context, userID int64, modelID string, promptTokens, completionTokens int, cost float64) error
+	
+	// User memory operations
+	GetMemories(ctx context.Context, userID int64) ([]string, error)
+	SaveMemories(ctx context.Context, userID int64, memories []string) error
+	
+	// User API key operations; keys are stored encrypted, by endpoint name
+	GetAPIKeys(ctx context.Context, userID int64) (map[string]string, error)
+	SaveAPIKeys(ctx context.Context, userID int64, keys map[string]string) error
+	
+	// Rate limit override operations
+	GetRateLimitOverrides(ctx context.Context) (map[int64]int, error)
+	SaveRateLimitOverride(ctx context.Context, userID int64, rpm int) error
 	
 	// User state operations
 	GetUserState(ctx context.Context, userID int64, key string) (string, error)
 	SetUserState(ctx context.Context, userID int64, key string, value string) error
 	DeleteUserState(ctx context.Context, userID int64, key string) error
 	
+	// Chat state operations; states expire after their ttl, or never when it is 0
+	GetChatState(ctx context.Context, chatID int64, key string) (string, error)
+	SetChatState(ctx context.Context, chatID int64, key string, value string, ttl time.Duration) error
+	DeleteChatState(ctx context.Context, chatID int64, key string) error
+	
 	// Cleanup operations
 	CleanupExpiredContexts(ctx context.Context, expiration time.Duration) error
+	// PruneStale removes contexts idle for longer than retention and the chat
+	// states without expiry left behind by chats that no longer have a
+	// context, returning how many entries were removed
+	PruneStale(ctx context.Context, retention time.Duration) (int, error)
 }
 
 // Manager manages different storage backends
@@ -47,6 +77,8 @@ type Manager struct {
 	storage Storage
 	logger  *logrus.Logger
 	redisClient *redis.Client // Store redis client reference
+	snapshotPath string // memory backend only, see SaveSnapshot
+	keyCipher *keyCipher // nil while per-user API keys are disabled
 }
 
 // NewManager creates a new storage manager
@@ -68,20 +100,38 @@ func NewManager(cfg *config.Config, logger *logrus.Logger) (*Manager, error) {
 		// Store redis client reference
 		manager.redisClient = redisStorage.client
 	case "memory":
-		storage = NewMemoryStorage(cfg, logger)
+		memoryStorage := NewMemoryStorage(cfg, logger)
+		if path := cfg.Storage.Memory.SnapshotPath; path != "" {
+			restored, err := memoryStorage.LoadSnapshot(path, cfg.Storage.Memory.SnapshotMaxAge)
+			if err != nil {
+				logger.WithError(err).Warn("Failed to load context snapshot")
+			} else {
+				logger.WithField("contexts", restored).Info("Context snapshot loaded")
+			}
+			manager.snapshotPath = path
+		}
+		storage = memoryStorage
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", cfg.Storage.Type)
 	}
 
 	manager.storage = storage
 
+	if secret := cfg.Models.UserKeySecret; secret != "" {
+		keyCipher, err := newKeyCipher(secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up user key encryption: %w", err)
+		}
+		manager.keyCipher = keyCipher
+	}
+
 	// Start cleanup goroutine
-	go manager.startCleanup(cfg.Storage.Memory.CleanupInterval, cfg.Storage.Memory.DefaultExpiration)
+	go manager.startCleanup(cfg.Storage.Memory.CleanupInterval, cfg.Storage.Memory.DefaultExpiration, cfg.Storage.Retention)
 
 	return manager, nil
 }
 
-func (m *Manager) startCleanup(interval, expiration time.Duration) {
+func (m *Manager) startCleanup(interval, expiration, retention time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -90,6 +140,14 @@ func (m *Manager) startCleanup(interval, expiration time.Duration) {
 		if err := m.storage.CleanupExpiredContexts(ctx, expiration); err != nil {
 			m.logger.WithError(err).Error("Failed to cleanup expired contexts")
 		}
+		if retention > 0 {
+			removed, err := m.storage.PruneStale(ctx, retention)
+			if err != nil {
+				m.logger.WithError(err).Error("Failed to prune stale data")
+			} else if removed > 0 {
+				m.logger.WithField("removed", removed).Info("Pruned stale data")
+			}
+		}
 		cancel()
 	}
 }
@@ -115,6 +173,14 @@ func (m *Manager) SaveSettings(ctx context.Context, chatID int64, settings *mode
 	return m.storage.SaveSettings(ctx, chatID, settings)
 }
 
+func (m *Manager) DeleteSettings(ctx context.Context, chatID int64) error {
+	return m.storage.DeleteSettings(ctx, chatID)
+}
+
+func (m *Manager) ListChatIDs(ctx context.Context) ([]int64, error) {
+	return m.storage.ListChatIDs(ctx)
+}
+
 func (m *Manager) ClearContext(ctx context.Context, userID int64) error {
 	return m.storage.ClearContext(ctx, userID)
 }
@@ -127,6 +193,22 @@ func (m *Manager) SaveUserSettings(ctx context.Context, userID int64, settings *
 	return m.storage.SaveUserSettings(ctx, userID, settings)
 }
 
+func (m *Manager) GetMemories(ctx context.Context, userID int64) ([]string, error) {
+	return m.storage.GetMemories(ctx, userID)
+}
+
+func (m *Manager) SaveMemories(ctx context.Context, userID int64, memories []string) error {
+	return m.storage.SaveMemories(ctx, userID, memories)
+}
+
+func (m *Manager) GetRateLimitOverrides(ctx context.Context) (map[int64]int, error) {
+	return m.storage.GetRateLimitOverrides(ctx)
+}
+
+func (m *Manager) SaveRateLimitOverride(ctx context.Context, userID int64, rpm int) error {
+	return m.storage.SaveRateLimitOverride(ctx, userID, rpm)
+}
+
 func (m *Manager) GetUserStats(ctx context.Context, userID int64) (*models.UserStats, error) {
 	return m.storage.GetUserStats(ctx, userID)
 }
@@ -135,6 +217,14 @@ func (m *Manager) IncrementUserStats(ctx context.Context, userID int64) error {
 	return m.storage.IncrementUserStats(ctx, userID)
 }
 
+func (m *Manager) IncrementUserSessions(ctx context.Context, userID int64) error {
+	return m.storage.IncrementUserSessions(ctx, userID)
+}
+
+func (m *Manager) RecordUsage(ctx context.Context, userID int64, modelID string, promptTokens, completionTokens int, cost float64) error {
+	return m.storage.RecordUsage(ctx, userID, modelID, promptTokens, completionTokens, cost)
+}
+
 func (m *Manager) GetUserState(ctx context.Context, userID int64, key string) (string, error) {
 	return m.storage.GetUserState(ctx, userID, key)
 }
@@ -147,11 +237,114 @@ func (m *Manager) DeleteUserState(ctx context.Context, userID int64, key string)
 	return m.storage.DeleteUserState(ctx, userID, key)
 }
 
+// GetChatState returns a chat state, "" when it isn't set
+func (m *Manager) GetChatState(ctx context.Context, chatID int64, key string) (string, error) {
+	return m.storage.GetChatState(ctx, chatID, key)
+}
+
+// SetChatState stores a chat state for ttl, or until deleted when ttl is 0
+func (m *Manager) SetChatState(ctx context.Context, chatID int64, key string, value string, ttl time.Duration) error {
+	return m.storage.SetChatState(ctx, chatID, key, value, ttl)
+}
+
+func (m *Manager) DeleteChatState(ctx context.Context, chatID int64, key string) error {
+	return m.storage.DeleteChatState(ctx, chatID, key)
+}
+
+// SaveSnapshot writes the in-memory contexts to the configured snapshot file.
+// It does nothing for Redis, which persists contexts itself.
+func (m *Manager) SaveSnapshot() error {
+	memoryStorage, ok := m.storage.(*MemoryStorage)
+	if !ok || m.snapshotPath == "" {
+		return nil
+	}
+	
+	saved, err := memoryStorage.SaveSnapshot(m.snapshotPath)
+	if err != nil {
+		return err
+	}
+	m.logger.WithField("contexts", saved).Info("Context snapshot saved")
+	return nil
+}
+
+// ActiveContexts returns the contexts of chats active within window, most
+// recently active first
+func (m *Manager) ActiveContexts(ctx context.Context, window time.Duration) ([]*models.ChatContext, error) {
+	chatIDs, err := m.storage.ListChatIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	cutoff := time.Now().Add(-window)
+	var active []*models.ChatContext
+	for _, chatID := range chatIDs {
+		chatCtx, err := m.storage.GetContext(ctx, chatID)
+		if err != nil {
+			// One unreadable record shouldn't hide every other chat
+			m.logger.WithError(err).WithField("chatID", chatID).Warn("Skipping unreadable context")
+			continue
+		}
+		if chatCtx != nil && chatCtx.LastActivity.After(cutoff) {
+			active = append(active, chatCtx)
+		}
+	}
+	
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastActivity.After(active[j].LastActivity)
+	})
+	return active, nil
+}
+
 // GetRedisClient returns the Redis client if available
 func (m *Manager) GetRedisClient() *redis.Client {
 	return m.redisClient
 }
 
+// decodeContext parses a persisted chat context and migrates it to the current
+// schema version. Records written by a newer version are rejected rather than
+// returned half-parsed.
+func decodeContext(chatID int64, data []byte) (*models.ChatContext, error) {
+	var chatCtx models.ChatContext
+	if err := json.Unmarshal(data, &chatCtx); err != nil {
+		return nil, fmt.Errorf("failed to decode context of chat %d: %w", chatID, err)
+	}
+
+	if chatCtx.SchemaVersion > models.ContextSchemaVersion {
+		return nil, fmt.Errorf("context of chat %d has schema version %d, newer than supported version %d",
+			chatID, chatCtx.SchemaVersion, models.ContextSchemaVersion)
+	}
+
+	if chatCtx.SchemaVersion < 1 {
+		migrateContextV0(chatID, &chatCtx)
+	}
+	return &chatCtx, nil
+}
+
+// migrateContextV0 upgrades a record written before contexts were versioned
+func migrateContextV0(chatID int64, chatCtx *models.ChatContext) {
+	if chatCtx.ChatID == 0 {
+		chatCtx.ChatID = chatID
+	}
+
+	// Drop turns without a role, the API rejects them
+	messages := make([]models.Message, 0, len(chatCtx.Messages))
+	for _, msg := range chatCtx.Messages {
+		if msg.Role != "" {
+			messages = append(messages, msg)
+		}
+	}
+	chatCtx.Messages = messages
+
+	// Indexes past the end of the context would branch into missing turns
+	for messageID, length := range chatCtx.ReplyIndex {
+		if length > len(messages) {
+			delete(chatCtx.ReplyIndex, messageID)
+		}
+	}
+
+	chatCtx.SchemaVersion = 1
+}
+
 // RedisStorage implements storage using Redis
 type RedisStorage struct {
 	client *redis.Client
@@ -188,16 +381,18 @@ func (r *RedisStorage) GetContext(ctx context.Context, chatID int64) (*models.Ch
 		return nil, err
 	}
 
-	var chatCtx models.ChatContext
-	if err := json.Unmarshal([]byte(data), &chatCtx); err != nil {
+	chatCtx, err := decodeContext(chatID, []byte(data))
+	if err != nil {
+		r.logger.WithError(err).WithField("chatID", chatID).Error("Rejected stored context")
 		return nil, err
 	}
 
-	return &chatCtx, nil
+	return chatCtx, nil
 }
 
 func (r *RedisStorage) SaveContext(ctx context.Context, chatCtx *models.ChatContext) error {
 	key := fmt.Sprintf("context:%d", chatCtx.ChatID)
+	chatCtx.SchemaVersion = models.ContextSchemaVersion
 	data, err := json.Marshal(chatCtx)
 	if err != nil {
 		return err
@@ -239,11 +434,126 @@ func (r *RedisStorage) SaveSettings(ctx context.Context, chatID int64, settings
 	return r.client.Set(ctx, key, data, 0).Err() // No expiration for settings
 }
 
+func (r *RedisStorage) DeleteSettings(ctx context.Context, chatID int64) error {
+	key := fmt.Sprintf("settings:%d", chatID)
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *RedisStorage) ListChatIDs(ctx context.Context) ([]int64, error) {
+	var chatIDs []int64
+	err := r.scanKeys(ctx, "settings:*", func(key string) error {
+		if chatID, ok := parseKeyID(key, "settings:"); ok {
+			chatIDs = append(chatIDs, chatID)
+		}
+		return nil
+	})
+	return chatIDs, err
+}
+
+// parseKeyID extracts the numeric ID from a key like "settings:123"
+func parseKeyID(key, prefix string) (int64, bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(key, prefix), 10, 64)
+	return id, err == nil
+}
+
 func (r *RedisStorage) CleanupExpiredContexts(ctx context.Context, expiration time.Duration) error {
 	// Redis handles expiration automatically
 	return nil
 }
 
+func (r *RedisStorage) PruneStale(ctx context.Context, retention time.Duration) (int, error) {
+	cutoff := time.Now().Add(-retention)
+	removed := 0
+
+	// Contexts normally expire through their TTL, this catches long-lived ones
+	err := r.scanKeys(ctx, "context:*", func(key string) error {
+		data, err := r.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		chatID, _ := parseKeyID(key, "context:")
+		chatCtx, err := decodeContext(chatID, []byte(data))
+		if err != nil {
+			r.logger.WithError(err).WithField("key", key).Warn("Skipping unreadable context")
+			return nil
+		}
+		if chatCtx.LastActivity.IsZero() || chatCtx.LastActivity.After(cutoff) {
+			return nil
+		}
+		if err := r.client.Del(ctx, key).Err(); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, err
+	}
+
+	// Chat states kept until deleted belong to an ongoing chat; once the
+	// chat's context is gone (expired or pruned above) they are orphaned
+	err = r.scanKeys(ctx, "chat_state:*", func(key string) error {
+		chatID, ok := chatStateID(key)
+		if !ok {
+			return nil
+		}
+		ttl, err := r.client.TTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if ttl != -1 {
+			return nil
+		}
+		exists, err := r.client.Exists(ctx, fmt.Sprintf("context:%d", chatID)).Result()
+		if err != nil || exists > 0 {
+			return err
+		}
+		if err := r.client.Del(ctx, key).Err(); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// chatStateID extracts the chat ID from a key like "chat_state:123:name"
+func chatStateID(key string) (int64, bool) {
+	rest, ok := strings.CutPrefix(key, "chat_state:")
+	if !ok {
+		return 0, false
+	}
+	id, _, _ := strings.Cut(rest, ":")
+	chatID, err := strconv.ParseInt(id, 10, 64)
+	return chatID, err == nil
+}
+
+// scanKeys calls fn for every key matching pattern, without blocking Redis like KEYS
+func (r *RedisStorage) scanKeys(ctx context.Context, pattern string, fn func(key string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 func (r *RedisStorage) ClearContext(ctx context.Context, userID int64) error {
 	// Note: This is a simplified implementation
 	// In a real app, you might want to track user->chat associations
@@ -279,6 +589,105 @@ func (r *RedisStorage) SaveUserSettings(ctx context.Context, userID int64, setti
 	return r.client.Set(ctx, key, data, 0).Err()
 }
 
+func (r *RedisStorage) GetMemories(ctx context.Context, userID int64) ([]string, error) {
+	key := fmt.Sprintf("memories:%d", userID)
+	data, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var memories []string
+	if err := json.Unmarshal([]byte(data), &memories); err != nil {
+		return nil, err
+	}
+
+	return memories, nil
+}
+
+func (r *RedisStorage) SaveMemories(ctx context.Context, userID int64, memories []string) error {
+	key := fmt.Sprintf("memories:%d", userID)
+	if len(memories) == 0 {
+		return r.client.Del(ctx, key).Err()
+	}
+
+	data, err := json.Marshal(memories)
+	if err != nil {
+		return err
+	}
+
+	// Memories are permanent and survive context clears
+	return r.client.Set(ctx, key, data, 0).Err()
+}
+
+func (r *RedisStorage) GetAPIKeys(ctx context.Context, userID int64) (map[string]string, error) {
+	key := fmt.Sprintf("api_keys:%d", userID)
+	data, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys map[string]string
+	if err := json.Unmarshal([]byte(data), &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *RedisStorage) SaveAPIKeys(ctx context.Context, userID int64, keys map[string]string) error {
+	key := fmt.Sprintf("api_keys:%d", userID)
+	if len(keys) == 0 {
+		return r.client.Del(ctx, key).Err()
+	}
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	// Keys stay until the user removes them
+	return r.client.Set(ctx, key, data, 0).Err()
+}
+
+// rateLimitOverridesKey is the Redis hash of user ID -> requests per minute
+const rateLimitOverridesKey = "rate_limit_overrides"
+
+func (r *RedisStorage) GetRateLimitOverrides(ctx context.Context) (map[int64]int, error) {
+	values, err := r.client.HGetAll(ctx, rateLimitOverridesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[int64]int, len(values))
+	for field, value := range values {
+		userID, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		rpm, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		overrides[userID] = rpm
+	}
+	return overrides, nil
+}
+
+// SaveRateLimitOverride stores the user's limit; rpm <= 0 removes the override
+func (r *RedisStorage) SaveRateLimitOverride(ctx context.Context, userID int64, rpm int) error {
+	field := strconv.FormatInt(userID, 10)
+	if rpm <= 0 {
+		return r.client.HDel(ctx, rateLimitOverridesKey, field).Err()
+	}
+	return r.client.HSet(ctx, rateLimitOverridesKey, field, rpm).Err()
+}
+
 func (r *RedisStorage) GetUserStats(ctx context.Context, userID int64) (*models.UserStats, error) {
 	key := fmt.Sprintf("user_stats:%d", userID)
 	data, err := r.client.Get(ctx, key).Result()
@@ -298,19 +707,75 @@ func (r *RedisStorage) GetUserStats(ctx context.Context, userID int64) (*models.
 }
 
 func (r *RedisStorage) IncrementUserStats(ctx context.Context, userID int64) error {
-	stats, err := r.GetUserStats(ctx, userID)
-	if err != nil {
-		return err
+	return r.updateUserStats(ctx, userID, func(stats *models.UserStats) {
+		stats.TotalMessages++
+	})
+}
+
+func (r *RedisStorage) IncrementUserSessions(ctx context.Context, userID int64) error {
+	return r.updateUserStats(ctx, userID, func(stats *models.UserStats) {
+		stats.TotalSessions++
+	})
+}
+
+func (r *RedisStorage) RecordUsage(ctx context.Context, userID int64, modelID string, promptTokens, completionTokens int, cost float64) error {
+	return r.updateUserStats(ctx, userID, func(stats *models.UserStats) {
+		addUsage(stats, modelID, promptTokens, completionTokens, cost)
+	})
+}
+
+// addUsage accumulates one successful response into stats
+func addUsage(stats *models.UserStats, modelID string, promptTokens, completionTokens int, cost float64) {
+	stats.PromptTokens += promptTokens
+	stats.CompletionTokens += completionTokens
+	stats.TotalCost += cost
+	if modelID != "" {
+		if stats.ModelUsage == nil {
+			stats.ModelUsage = make(map[string]int)
+		}
+		stats.ModelUsage[modelID]++
 	}
+}
 
-	stats.TotalMessages++
+// updateUserStats applies update to the stored stats inside a WATCH transaction,
+// retrying when a concurrent writer changed the stats in between
+func (r *RedisStorage) updateUserStats(ctx context.Context, userID int64, update func(*models.UserStats)) error {
+	const maxAttempts = 10
 	key := fmt.Sprintf("user_stats:%d", userID)
-	data, err := json.Marshal(stats)
-	if err != nil {
+
+	txf := func(tx *redis.Tx) error {
+		stats := &models.UserStats{UserID: userID}
+		data, err := tx.Get(ctx, key).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil {
+			if err := json.Unmarshal([]byte(data), stats); err != nil {
+				return err
+			}
+		}
+
+		update(stats)
+		newData, err := json.Marshal(stats)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, newData, 0)
+			return nil
+		})
 		return err
 	}
 
-	return r.client.Set(ctx, key, data, 0).Err()
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err := r.client.Watch(ctx, txf, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+
+	return fmt.Errorf("failed to update user stats after %d attempts: concurrent modification", maxAttempts)
 }
 
 func (r *RedisStorage) GetUserState(ctx context.Context, userID int64, key string) (string, error) {
@@ -333,6 +798,28 @@ func (r *RedisStorage) DeleteUserState(ctx context.Context, userID int64, key st
 	return r.client.Del(ctx, stateKey).Err()
 }
 
+func (r *RedisStorage) GetChatState(ctx context.Context, chatID int64, key string) (string, error) {
+	stateKey := fmt.Sprintf("chat_state:%d:%s", chatID, key)
+	value, err := r.client.Get(ctx, stateKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return value, err
+}
+
+func (r *RedisStorage) SetChatState(ctx context.Context, chatID int64, key string, value string, ttl time.Duration) error {
+	stateKey := fmt.Sprintf("chat_state:%d:%s", chatID, key)
+	if ttl < 0 {
+		ttl = 0
+	}
+	return r.client.Set(ctx, stateKey, value, ttl).Err()
+}
+
+func (r *RedisStorage) DeleteChatState(ctx context.Context, chatID int64, key string) error {
+	stateKey := fmt.Sprintf("chat_state:%d:%s", chatID, key)
+	return r.client.Del(ctx, stateKey).Err()
+}
+
 // MemoryStorage implements storage using in-memory cache
 type MemoryStorage struct {
 	contexts     *cache.Cache
@@ -340,6 +827,11 @@ type MemoryStorage struct {
 	userSettings *cache.Cache
 	userStats    *cache.Cache
 	userStates   *cache.Cache
+	chatStates   *cache.Cache
+	memories     *cache.Cache
+	apiKeys      *cache.Cache
+	rateLimits   *cache.Cache
+	statsMu      sync.Mutex // serializes read-modify-write of user stats
 	logger       *logrus.Logger
 }
 
@@ -350,6 +842,10 @@ func NewMemoryStorage(cfg *config.Config, logger *logrus.Logger) *MemoryStorage
 		userSettings: cache.New(cache.NoExpiration, cache.NoExpiration),
 		userStats:    cache.New(cache.NoExpiration, cache.NoExpiration),
 		userStates:   cache.New(time.Hour, 10*time.Minute),
+		chatStates:   cache.New(cache.NoExpiration, 10*time.Minute),
+		memories:     cache.New(cache.NoExpiration, cache.NoExpiration),
+		apiKeys:      cache.New(cache.NoExpiration, cache.NoExpiration),
+		rateLimits:   cache.New(cache.NoExpiration, cache.NoExpiration),
 		logger:       logger,
 	}
 }
@@ -357,17 +853,38 @@ func NewMemoryStorage(cfg *config.Config, logger *logrus.Logger) *MemoryStorage
 func (m *MemoryStorage) GetContext(ctx context.Context, chatID int64) (*models.ChatContext, error) {
 	key := fmt.Sprintf("context:%d", chatID)
 	if val, found := m.contexts.Get(key); found {
-		return val.(*models.ChatContext), nil
+		return copyChatContext(val.(*models.ChatContext)), nil
 	}
 	return nil, nil
 }
 
 func (m *MemoryStorage) SaveContext(ctx context.Context, chatCtx *models.ChatContext) error {
 	key := fmt.Sprintf("context:%d", chatCtx.ChatID)
-	m.contexts.SetDefault(key, chatCtx)
+	chatCtx.SchemaVersion = models.ContextSchemaVersion
+	m.contexts.SetDefault(key, copyChatContext(chatCtx))
 	return nil
 }
 
+// copyChatContext returns a copy of chatCtx sharing no messages or indexes
+// with it, so a context being changed by a handler never changes the stored one
+func copyChatContext(chatCtx *models.ChatContext) *models.ChatContext {
+	copied := *chatCtx
+	copied.Messages = append([]models.Message(nil), chatCtx.Messages...)
+	if chatCtx.ReplyIndex != nil {
+		copied.ReplyIndex = make(map[int]int, len(chatCtx.ReplyIndex))
+		for messageID, index := range chatCtx.ReplyIndex {
+			copied.ReplyIndex[messageID] = index
+		}
+	}
+	if chatCtx.KnowledgeIndex != nil {
+		copied.KnowledgeIndex = make(map[string]int, len(chatCtx.KnowledgeIndex))
+		for key, position := range chatCtx.KnowledgeIndex {
+			copied.KnowledgeIndex[key] = position
+		}
+	}
+	return &copied
+}
+
 func (m *MemoryStorage) DeleteContext(ctx context.Context, chatID int64) error {
 	key := fmt.Sprintf("context:%d", chatID)
 	m.contexts.Delete(key)
@@ -388,11 +905,55 @@ func (m *MemoryStorage) SaveSettings(ctx context.Context, chatID int64, settings
 	return nil
 }
 
+func (m *MemoryStorage) DeleteSettings(ctx context.Context, chatID int64) error {
+	key := fmt.Sprintf("settings:%d", chatID)
+	m.settings.Delete(key)
+	return nil
+}
+
+func (m *MemoryStorage) ListChatIDs(ctx context.Context) ([]int64, error) {
+	var chatIDs []int64
+	for key := range m.settings.Items() {
+		if chatID, ok := parseKeyID(key, "settings:"); ok {
+			chatIDs = append(chatIDs, chatID)
+		}
+	}
+	return chatIDs, nil
+}
+
 func (m *MemoryStorage) CleanupExpiredContexts(ctx context.Context, expiration time.Duration) error {
 	// go-cache handles cleanup automatically
 	return nil
 }
 
+func (m *MemoryStorage) PruneStale(ctx context.Context, retention time.Duration) (int, error) {
+	cutoff := time.Now().Add(-retention)
+	removed := 0
+	
+	for key, item := range m.contexts.Items() {
+		chatCtx, ok := item.Object.(*models.ChatContext)
+		if !ok || chatCtx.LastActivity.IsZero() || chatCtx.LastActivity.After(cutoff) {
+			continue
+		}
+		m.contexts.Delete(key)
+		removed++
+	}
+	
+	// Chat states kept until deleted are orphaned once the chat's context is gone
+	for key, item := range m.chatStates.Items() {
+		chatID, ok := chatStateID(key)
+		if !ok || item.Expiration != 0 {
+			continue
+		}
+		if _, found := m.contexts.Get(fmt.Sprintf("context:%d", chatID)); found {
+			continue
+		}
+		m.chatStates.Delete(key)
+		removed++
+	}
+	return removed, nil
+}
+
 func (m *MemoryStorage) ClearContext(ctx context.Context, userID int64) error {
 	key := fmt.Sprintf("context:%d", userID)
 	m.contexts.Delete(key)
@@ -413,24 +974,134 @@ func (m *MemoryStorage) SaveUserSettings(ctx context.Context, userID int64, sett
 	return nil
 }
 
+func (m *MemoryStorage) GetMemories(ctx context.Context, userID int64) ([]string, error) {
+	key := fmt.Sprintf("memories:%d", userID)
+	if val, found := m.memories.Get(key); found {
+		memories := val.([]string)
+		// Return a copy so callers can't modify the stored list
+		return append([]string(nil), memories...), nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStorage) SaveMemories(ctx context.Context, userID int64, memories []string) error {
+	key := fmt.Sprintf("memories:%d", userID)
+	if len(memories) == 0 {
+		m.memories.Delete(key)
+		return nil
+	}
+	m.memories.Set(key, append([]string(nil), memories...), cache.NoExpiration)
+	return nil
+}
+
+func (m *MemoryStorage) GetAPIKeys(ctx context.Context, userID int64) (map[string]string, error) {
+	key := fmt.Sprintf("api_keys:%d", userID)
+	if val, found := m.apiKeys.Get(key); found {
+		// Return a copy so callers can't modify the stored keys
+		return copyAPIKeys(val.(map[string]string)), nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStorage) SaveAPIKeys(ctx context.Context, userID int64, keys map[string]string) error {
+	key := fmt.Sprintf("api_keys:%d", userID)
+	if len(keys) == 0 {
+		m.apiKeys.Delete(key)
+		return nil
+	}
+	m.apiKeys.Set(key, copyAPIKeys(keys), cache.NoExpiration)
+	return nil
+}
+
+func copyAPIKeys(keys map[string]string) map[string]string {
+	copied := make(map[string]string, len(keys))
+	for endpoint, key := range keys {
+		copied[endpoint] = key
+	}
+	return copied
+}
+
+func (m *MemoryStorage) GetRateLimitOverrides(ctx context.Context) (map[int64]int, error) {
+	items := m.rateLimits.Items()
+	overrides := make(map[int64]int, len(items))
+	for key, item := range items {
+		userID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		overrides[userID] = item.Object.(int)
+	}
+	return overrides, nil
+}
+
+func (m *MemoryStorage) SaveRateLimitOverride(ctx context.Context, userID int64, rpm int) error {
+	key := strconv.FormatInt(userID, 10)
+	if rpm <= 0 {
+		m.rateLimits.Delete(key)
+		return nil
+	}
+	m.rateLimits.Set(key, rpm, cache.NoExpiration)
+	return nil
+}
+
 func (m *MemoryStorage) GetUserStats(ctx context.Context, userID int64) (*models.UserStats, error) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	
 	key := fmt.Sprintf("user_stats:%d", userID)
 	if val, found := m.userStats.Get(key); found {
-		return val.(*models.UserStats), nil
+		// Return a copy so readers never observe a concurrent update
+		return copyUserStats(val.(*models.UserStats)), nil
 	}
 	return &models.UserStats{UserID: userID}, nil
 }
 
 func (m *MemoryStorage) IncrementUserStats(ctx context.Context, userID int64) error {
-	stats, err := m.GetUserStats(ctx, userID)
-	if err != nil {
-		return err
+	m.updateUserStats(userID, func(stats *models.UserStats) {
+		stats.TotalMessages++
+	})
+	return nil
+}
+
+func (m *MemoryStorage) IncrementUserSessions(ctx context.Context, userID int64) error {
+	m.updateUserStats(userID, func(stats *models.UserStats) {
+		stats.TotalSessions++
+	})
+	return nil
+}
+
+func (m *MemoryStorage) RecordUsage(ctx context.Context, userID int64, modelID string, promptTokens, completionTokens int, cost float64) error {
+	m.updateUserStats(userID, func(stats *models.UserStats) {
+		addUsage(stats, modelID, promptTokens, completionTokens, cost)
+	})
+	return nil
+}
+
+// copyUserStats returns a deep copy of stats, including the model usage map
+func copyUserStats(stats *models.UserStats) *models.UserStats {
+	copied := *stats
+	if stats.ModelUsage != nil {
+		copied.ModelUsage = make(map[string]int, len(stats.ModelUsage))
+		for modelID, count := range stats.ModelUsage {
+			copied.ModelUsage[modelID] = count
+		}
 	}
+	return &copied
+}
+
+// updateUserStats applies update to the stored stats under the stats lock
+func (m *MemoryStorage) updateUserStats(userID int64, update func(*models.UserStats)) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
 	
-	stats.TotalMessages++
 	key := fmt.Sprintf("user_stats:%d", userID)
+	stats := &models.UserStats{UserID: userID}
+	if val, found := m.userStats.Get(key); found {
+		stats = copyUserStats(val.(*models.UserStats))
+	}
+	
+	update(stats)
 	m.userStats.Set(key, stats, cache.NoExpiration)
-	return nil
 }
 
 func (m *MemoryStorage) GetUserState(ctx context.Context, userID int64, key string) (string, error) {
@@ -451,4 +1122,27 @@ func (m *MemoryStorage) DeleteUserState(ctx context.Context, userID int64, key s
 	stateKey := fmt.Sprintf("user_state:%d:%s", userID, key)
 	m.userStates.Delete(stateKey)
 	return nil
+}
+
+func (m *MemoryStorage) GetChatState(ctx context.Context, chatID int64, key string) (string, error) {
+	stateKey := fmt.Sprintf("chat_state:%d:%s", chatID, key)
+	if val, found := m.chatStates.Get(stateKey); found {
+		return val.(string), nil
+	}
+	return "", nil
+}
+
+func (m *MemoryStorage) SetChatState(ctx context.Context, chatID int64, key string, value string, ttl time.Duration) error {
+	stateKey := fmt.Sprintf("chat_state:%d:%s", chatID, key)
+	if ttl <= 0 {
+		ttl = cache.NoExpiration
+	}
+	m.chatStates.Set(stateKey, value, ttl)
+	return nil
+}
+
+func (m *MemoryStorage) DeleteChatState(ctx context.Context, chatID int64, key string) error {
+	stateKey := fmt.Sprintf("chat_state:%d:%s", chatID, key)
+	m.chatStates.Delete(stateKey)
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestMemoryStorage() *MemoryStorage {
+	cfg := &config.Config{}
+	cfg.Storage.Memory.DefaultExpiration = time.Hour
+	cfg.Storage.Memory.CleanupInterval = time.Hour
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewMemoryStorage(cfg, logger)
+}
+
+func TestMemoryChatStateTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		expires  bool
+		lifetime time.Duration
+	}{
+		{name: "longer than user states", ttl: 6 * time.Hour, expires: true, lifetime: 6 * time.Hour},
+		{name: "short", ttl: time.Minute, expires: true, lifetime: time.Minute},
+		{name: "no ttl", ttl: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := newTestMemoryStorage()
+			before := time.Now()
+			if err := m.SetChatState(ctx, 42, "key", "value", tt.ttl); err != nil {
+				t.Fatalf("SetChatState: %v", err)
+			}
+			
+			value, err := m.GetChatState(ctx, 42, "key")
+			if err != nil || value != "value" {
+				t.Fatalf("GetChatState = %q, %v, want value", value, err)
+			}
+			
+			item := m.chatStates.Items()["chat_state:42:key"]
+			if !tt.expires {
+				if item.Expiration != 0 {
+					t.Errorf("state expires at %v, want no expiry", time.Unix(0, item.Expiration))
+				}
+				return
+			}
+			expiresIn := time.Unix(0, item.Expiration).Sub(before)
+			if expiresIn < tt.lifetime || expiresIn > tt.lifetime+time.Minute {
+				t.Errorf("state expires in %v, want %v", expiresIn, tt.lifetime)
+			}
+		})
+	}
+}
+
+func TestMemoryChatStateSeparateFromUserState(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemoryStorage()
+	m.SetUserState(ctx, 42, "key", "user")
+	m.SetChatState(ctx, 42, "key", "chat", 0)
+	
+	if value, _ := m.GetUserState(ctx, 42, "key"); value != "user" {
+		t.Errorf("user state = %q, want user", value)
+	}
+	if value, _ := m.GetChatState(ctx, 42, "key"); value != "chat" {
+		t.Errorf("chat state = %q, want chat", value)
+	}
+	
+	m.DeleteChatState(ctx, 42, "key")
+	if value, _ := m.GetChatState(ctx, 42, "key"); value != "" {
+		t.Errorf("deleted chat state = %q, want empty", value)
+	}
+	if value, _ := m.GetUserState(ctx, 42, "key"); value != "user" {
+		t.Errorf("user state = %q after deleting the chat state, want user", value)
+	}
+}
+
+func TestMemoryRateLimitOverrides(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemoryStorage()
+	
+	m.SaveRateLimitOverride(ctx, 7, 30)
+	m.SaveRateLimitOverride(ctx, 8, 5)
+	m.SaveRateLimitOverride(ctx, 8, 0)
+	m.SaveRateLimitOverride(ctx, 9, 10)
+	m.SaveRateLimitOverride(ctx, 9, 120)
+	
+	overrides, err := m.GetRateLimitOverrides(ctx)
+	if err != nil {
+		t.Fatalf("GetRateLimitOverrides: %v", err)
+	}
+	if want := map[int64]int{7: 30, 9: 120}; !reflect.DeepEqual(overrides, want) {
+		t.Errorf("overrides = %v, want %v", overrides, want)
+	}
+}
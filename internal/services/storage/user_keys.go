@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUserKeysDisabled is returned when saving a user API key without
+// models.user_key_secret configured
+var ErrUserKeysDisabled = errors.New("user API keys are disabled")
+
+// keyCipher encrypts user API keys at rest with AES-GCM, keyed by a hash of
+// the configured secret
+type keyCipher struct {
+	aead cipher.AEAD
+}
+
+// newKeyCipher creates a cipher for the given secret
+func newKeyCipher(secret string) (*keyCipher, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keyCipher{aead: aead}, nil
+}
+
+// keyBinding ties a ciphertext to its user and endpoint, so a stored key
+// copied to another user or endpoint fails to decrypt
+func keyBinding(userID int64, endpoint string) []byte {
+	return []byte(fmt.Sprintf("%d:%s", userID, endpoint))
+}
+
+// encrypt seals apiKey for the user and endpoint
+func (c *keyCipher) encrypt(userID int64, endpoint string, apiKey string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(apiKey), keyBinding(userID, endpoint))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a key sealed by encrypt for the same user and endpoint
+func (c *keyCipher) decrypt(userID int64, endpoint string, encrypted string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode key: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("failed to decrypt key: too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	apiKey, err := c.aead.Open(nil, nonce, ciphertext, keyBinding(userID, endpoint))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key: %w", err)
+	}
+	return string(apiKey), nil
+}
+
+// UserKeysEnabled reports whether users may register their own API keys
+func (m *Manager) UserKeysEnabled() bool {
+	return m.keyCipher != nil
+}
+
+// UserAPIKey returns the user's own API key for the endpoint, or "" when the
+// user has none or per-user keys are disabled
+func (m *Manager) UserAPIKey(ctx context.Context, userID int64, endpoint string) (string, error) {
+	if m.keyCipher == nil || userID == 0 {
+		return "", nil
+	}
+	keys, err := m.storage.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	encrypted, ok := keys[endpoint]
+	if !ok {
+		return "", nil
+	}
+	return m.keyCipher.decrypt(userID, endpoint, encrypted)
+}
+
+// SetUserAPIKey stores the user's own API key for the endpoint, replacing an
+// earlier one
+func (m *Manager) SetUserAPIKey(ctx context.Context, userID int64, endpoint string, apiKey string) error {
+	if m.keyCipher == nil {
+		return ErrUserKeysDisabled
+	}
+	encrypted, err := m.keyCipher.encrypt(userID, endpoint, apiKey)
+	if err != nil {
+		return err
+	}
+	keys, err := m.storage.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		keys = make(map[string]string)
+	}
+	keys[endpoint] = encrypted
+	return m.storage.SaveAPIKeys(ctx, userID, keys)
+}
+
+// DeleteUserAPIKey removes the user's own API key for the endpoint, reporting
+// whether there was one
+func (m *Manager) DeleteUserAPIKey(ctx context.Context, userID int64, endpoint string) (bool, error) {
+	keys, err := m.storage.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := keys[endpoint]; !ok {
+		return false, nil
+	}
+	delete(keys, endpoint)
+	return true, m.storage.SaveAPIKeys(ctx, userID, keys)
+}
+
+// UserAPIKeyEndpoints returns the endpoints the user registered a key for, sorted
+func (m *Manager) UserAPIKeyEndpoints(ctx context.Context, userID int64) ([]string, error) {
+	keys, err := m.storage.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]string, 0, len(keys))
+	for endpoint := range keys {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints, nil
+}
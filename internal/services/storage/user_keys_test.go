@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-ai-tgbot-go/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+const testSecret = "a-secret-of-sixteen-plus"
+
+func TestKeyCipherRoundTrip(t *testing.T) {
+	c, err := newKeyCipher(testSecret)
+	if err != nil {
+		t.Fatalf("newKeyCipher: %v", err)
+	}
+	other, _ := newKeyCipher("another-secret-entirely")
+	sealed, err := c.encrypt(7, "openai", "sk-user-7")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if strings.Contains(sealed, "sk-user-7") {
+		t.Fatalf("ciphertext %q holds the plain key", sealed)
+	}
+	if again, _ := c.encrypt(7, "openai", "sk-user-7"); again == sealed {
+		t.Error("encrypting twice gave the same ciphertext, the nonce is reused")
+	}
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name      string
+		cipher    *keyCipher
+		userID    int64
+		endpoint  string
+		encrypted string
+		wantErr   bool
+	}{
+		{name: "same user and endpoint", cipher: c, userID: 7, endpoint: "openai", encrypted: sealed},
+		{name: "other user", cipher: c, userID: 8, endpoint: "openai", encrypted: sealed, wantErr: true},
+		{name: "other endpoint", cipher: c, userID: 7, endpoint: "deepseek", encrypted: sealed, wantErr: true},
+		{name: "other secret", cipher: other, userID: 7, endpoint: "openai", encrypted: sealed, wantErr: true},
+		{name: "tampered", cipher: c, userID: 7, endpoint: "openai", encrypted: tampered, wantErr: true},
+		{name: "not base64", cipher: c, userID: 7, endpoint: "openai", encrypted: "!!!", wantErr: true},
+		{name: "too short", cipher: c, userID: 7, endpoint: "openai", encrypted: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKey, err := tt.cipher.decrypt(tt.userID, tt.endpoint, tt.encrypted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decrypt = %q, %v, want error %v", apiKey, err, tt.wantErr)
+			}
+			if !tt.wantErr && apiKey != "sk-user-7" {
+				t.Errorf("decrypt = %q, want the original key", apiKey)
+			}
+		})
+	}
+}
+
+func newTestManager(t *testing.T, secret string) *Manager {
+	cfg := &config.Config{}
+	cfg.Storage.Type = "memory"
+	cfg.Storage.Memory.DefaultExpiration = time.Hour
+	cfg.Storage.Memory.CleanupInterval = time.Hour
+	cfg.Models.UserKeySecret = secret
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	manager, err := NewManager(cfg, logger)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return manager
+}
+
+func TestUserAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, testSecret)
+	if err := m.SetUserAPIKey(ctx, 7, "openai", "sk-user-7"); err != nil {
+		t.Fatalf("SetUserAPIKey: %v", err)
+	}
+
+	stored, _ := m.storage.GetAPIKeys(ctx, 7)
+	if stored["openai"] == "" || stored["openai"] == "sk-user-7" {
+		t.Errorf("stored %q, want the key encrypted", stored["openai"])
+	}
+
+	tests := []struct {
+		name     string
+		userID   int64
+		endpoint string
+		want     string
+	}{
+		{name: "own key", userID: 7, endpoint: "openai", want: "sk-user-7"},
+		{name: "other endpoint", userID: 7, endpoint: "deepseek"},
+		{name: "other user", userID: 8, endpoint: "openai"},
+		{name: "no user", userID: 0, endpoint: "openai"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKey, err := m.UserAPIKey(ctx, tt.userID, tt.endpoint)
+			if err != nil || apiKey != tt.want {
+				t.Errorf("UserAPIKey = %q, %v, want %q", apiKey, err, tt.want)
+			}
+		})
+	}
+
+	if deleted, err := m.DeleteUserAPIKey(ctx, 7, "openai"); err != nil || !deleted {
+		t.Fatalf("DeleteUserAPIKey = %v, %v, want deleted", deleted, err)
+	}
+	if apiKey, _ := m.UserAPIKey(ctx, 7, "openai"); apiKey != "" {
+		t.Errorf("UserAPIKey = %q after deleting it", apiKey)
+	}
+}
+
+func TestUserAPIKeysDisabled(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, "")
+	if m.UserKeysEnabled() {
+		t.Error("user keys enabled without a secret")
+	}
+	if err := m.SetUserAPIKey(ctx, 7, "openai", "sk-user-7"); !errors.Is(err, ErrUserKeysDisabled) {
+		t.Errorf("SetUserAPIKey = %v, want ErrUserKeysDisabled", err)
+	}
+	if apiKey, err := m.UserAPIKey(ctx, 7, "openai"); err != nil || apiKey != "" {
+		t.Errorf("UserAPIKey = %q, %v, want none", apiKey, err)
+	}
+}
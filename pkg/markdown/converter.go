@@ -7,23 +7,122 @@ import (
 	"github.com/russross/blackfriday/v2"
 )
 
+// Blockquote rendering styles
+const (
+	// QuoteNative renders blockquotes as Telegram quotes
+	QuoteNative = "native"
+	// QuotePrefix renders blockquotes as "> "-prefixed lines
+	QuotePrefix = "prefix"
+)
+
+// Image rendering styles
+const (
+	// ImageLink renders images as a link to the image, labelled with the alt text
+	ImageLink = "link"
+	// ImageDrop drops images from the text
+	ImageDrop = "drop"
+)
+
+// Options controls how elements Telegram can't show as such are converted.
+// The zero value uses QuoteNative and ImageLink.
+type Options struct {
+	Quotes string
+	Images string
+}
+
+var (
+	// imagePattern matches ![alt](url "title") images
+	imagePattern = regexp.MustCompile(`!\[([^\]\n]*)\]\(([^)\s]+)(?:\s+"[^"\n]*")?\)`)
+	// quoteLinePattern matches a blockquote line, capturing the quoted text
+	quoteLinePattern = regexp.MustCompile(`^ {0,3}>\s?(.*)$`)
+)
+
+// Placeholders for native blockquote tags while unsupported tags are stripped
+const (
+	quoteOpenMarker  = "\x00quote\x00"
+	quoteCloseMarker = "\x00/quote\x00"
+)
+
 // ToTelegramHTML converts markdown to Telegram-compatible HTML
-func ToTelegramHTML(markdown string) string {
+func ToTelegramHTML(markdown string, opts Options) string {
 	if markdown == "" {
 		return ""
 	}
 
 	// Convert markdown to HTML using blackfriday
+	markdown = rewriteImages(markdown, opts)
 	html := string(blackfriday.Run([]byte(markdown), blackfriday.WithExtensions(blackfriday.CommonExtensions)))
 
 	// Clean up the HTML for Telegram
-	html = cleanHTMLForTelegram(html)
+	html = cleanHTMLForTelegram(html, opts)
 
 	return html
 }
 
+// rewriteImages turns images outside fenced code into links, or drops them,
+// before conversion; Telegram can't show images inside a message
+func rewriteImages(markdown string, opts Options) string {
+	replace := func(text string) string {
+		return imagePattern.ReplaceAllStringFunc(text, func(match string) string {
+			if opts.Images == ImageDrop {
+				return ""
+			}
+			m := imagePattern.FindStringSubmatch(match)
+			alt := strings.TrimSpace(m[1])
+			if alt == "" {
+				alt = m[2]
+			}
+			return "🖼 [" + alt + "](" + m[2] + ")"
+		})
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, loc := range fencedCodePattern.FindAllStringIndex(markdown, -1) {
+		result.WriteString(replace(markdown[last:loc[0]]))
+		result.WriteString(markdown[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	result.WriteString(replace(markdown[last:]))
+	return result.String()
+}
+
+// convertBlockquotes rewrites <blockquote> elements, innermost first. Native
+// quotes become placeholders so the tag survives stripping of unsupported
+// tags; Telegram can't nest them, so nested quotes are flattened into the
+// outer one. Prefixed quotes nest as "> > ".
+func convertBlockquotes(html string, opts Options) string {
+	const openTag, closeTag = "<blockquote>", "</blockquote>"
+	for {
+		start := strings.LastIndex(html, openTag)
+		if start == -1 {
+			return html
+		}
+		end := strings.Index(html[start:], closeTag)
+		if end == -1 {
+			html = html[:start] + html[start+len(openTag):]
+			continue
+		}
+		end += start
+
+		inner := strings.TrimSpace(html[start+len(openTag) : end])
+		var quote string
+		if opts.Quotes == QuotePrefix {
+			lines := strings.Split(inner, "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRight("&gt; "+line, " ")
+			}
+			quote = strings.Join(lines, "\n")
+		} else {
+			inner = strings.NewReplacer(quoteOpenMarker, "", quoteCloseMarker, "").Replace(inner)
+			quote = quoteOpenMarker + inner + quoteCloseMarker
+		}
+		html = html[:start] + quote + "\n" + html[end+len(closeTag):]
+	}
+}
+
 // cleanHTMLForTelegram cleans HTML to be compatible with Telegram
-func cleanHTMLForTelegram(html string) string {
+func cleanHTMLForTelegram(html string, opts Options) string {
 	// Remove wrapping <p> tags
 	html = regexp.MustCompile(`<p>(.*?)</p>`).ReplaceAllString(html, "$1\n")
 
@@ -49,10 +148,13 @@ func cleanHTMLForTelegram(html string) string {
 	html = strings.ReplaceAll(html, "<li>", "• ")
 	html = strings.ReplaceAll(html, "</li>", "\n")
 
+	// Keep quotes as Telegram quotes or prefixed lines
+	html = convertBlockquotes(html, opts)
+
 	// Remove any other HTML tags that Telegram doesn't support
 	supportedTags := []string{"b", "i", "u", "s", "code", "pre", "a", "br"}
-	tagPattern := `</?([a-zA-Z]+)(?:\s[^>]*)?>` 
-	
+	tagPattern := `</?([a-zA-Z]+)(?:\s[^>]*)?>`
+
 	html = regexp.MustCompile(tagPattern).ReplaceAllStringFunc(html, func(match string) string {
 		// Extract tag name
 		tagMatch := regexp.MustCompile(`</?([a-zA-Z]+)`).FindStringSubmatch(match)
@@ -66,9 +168,123 @@ func cleanHTMLForTelegram(html string) string {
 		}
 		return ""
 	})
+	html = strings.ReplaceAll(html, quoteOpenMarker, "<blockquote>")
+	html = strings.ReplaceAll(html, quoteCloseMarker, "</blockquote>")
 
 	// Clean up extra newlines
 	html = regexp.MustCompile(`\n{3,}`).ReplaceAllString(html, "\n\n")
-	
+
 	return strings.TrimSpace(html)
-}
\ No newline at end of file
+}
+
+// markdownV2Special lists the characters MarkdownV2 requires to be escaped in text
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+var (
+	// fencedCodePattern matches ``` fenced code blocks, with an optional language
+	fencedCodePattern = regexp.MustCompile("(?s)```[a-zA-Z0-9_+-]*\n?(.*?)```")
+	// inlinePattern matches inline code, links, bold and italic spans
+	inlinePattern = regexp.MustCompile("`([^`\n]+)`|\\[([^\\]\n]+)\\]\\(([^)\\s]+)\\)|\\*\\*([^*\n]+)\\*\\*|__([^_\n]+)__|\\*([^*\n]+)\\*|\\b_([^_\n]+)_\\b")
+	// headerPattern matches markdown headers
+	headerPattern = regexp.MustCompile(`(?m)^#{1,6}\s+(.+?)\s*#*\s*$`)
+)
+
+// ToMarkdownV2 converts markdown to Telegram MarkdownV2, escaping everything
+// that isn't a supported entity
+func ToMarkdownV2(markdown string, opts Options) string {
+	markdown = rewriteImages(markdown, opts)
+
+	var result strings.Builder
+	last := 0
+	for _, loc := range fencedCodePattern.FindAllStringSubmatchIndex(markdown, -1) {
+		result.WriteString(blocksToMarkdownV2(markdown[last:loc[0]], opts))
+		result.WriteString("```\n" + escapeMarkdownV2Code(markdown[loc[2]:loc[3]]) + "```")
+		last = loc[1]
+	}
+	result.WriteString(blocksToMarkdownV2(markdown[last:], opts))
+	return strings.TrimSpace(result.String())
+}
+
+// blocksToMarkdownV2 converts text outside code blocks to MarkdownV2 line by
+// line, keeping quote lines as MarkdownV2 quotes unless opts asks for plain
+// "> " prefixes
+func blocksToMarkdownV2(text string, opts Options) string {
+	// Telegram has no headers, render them bold
+	text = headerPattern.ReplaceAllString(text, "**$1**")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if m := quoteLinePattern.FindStringSubmatch(line); m != nil && opts.Quotes != QuotePrefix {
+			lines[i] = ">" + inlineToMarkdownV2(m[1])
+			continue
+		}
+		lines[i] = inlineToMarkdownV2(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inlineToMarkdownV2 converts the inline spans of a line to MarkdownV2
+func inlineToMarkdownV2(text string) string {
+	var result strings.Builder
+	last := 0
+	for _, m := range inlinePattern.FindAllStringSubmatchIndex(text, -1) {
+		result.WriteString(escapeMarkdownV2(text[last:m[0]]))
+		group := func(i int) string { return text[m[2*i]:m[2*i+1]] }
+		switch {
+		case m[2] >= 0:
+			result.WriteString("`" + escapeMarkdownV2Code(group(1)) + "`")
+		case m[4] >= 0:
+			url := strings.NewReplacer(`\`, `\\`, ")", `\)`).Replace(group(3))
+			result.WriteString("[" + escapeMarkdownV2(group(2)) + "](" + url + ")")
+		case m[8] >= 0:
+			result.WriteString("*" + escapeMarkdownV2(group(4)) + "*")
+		case m[10] >= 0:
+			result.WriteString("*" + escapeMarkdownV2(group(5)) + "*")
+		case m[12] >= 0:
+			result.WriteString("_" + escapeMarkdownV2(group(6)) + "_")
+		default:
+			result.WriteString("_" + escapeMarkdownV2(group(7)) + "_")
+		}
+		last = m[1]
+	}
+	result.WriteString(escapeMarkdownV2(text[last:]))
+	return result.String()
+}
+
+// escapeMarkdownV2 escapes all MarkdownV2 special characters
+func escapeMarkdownV2(text string) string {
+	var result strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(markdownV2Special, r) {
+			result.WriteRune('\\')
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
+
+// escapeMarkdownV2Code escapes the characters MarkdownV2 requires inside code
+func escapeMarkdownV2Code(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(text)
+}
+
+// ToPlainText strips markdown syntax, keeping the readable text
+func ToPlainText(markdown string, opts Options) string {
+	text := fencedCodePattern.ReplaceAllString(rewriteImages(markdown, opts), "$1")
+	text = headerPattern.ReplaceAllString(text, "$1")
+	text = inlinePattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := inlinePattern.FindStringSubmatch(match)
+		switch {
+		case m[2] != "":
+			return m[2] + " (" + m[3] + ")"
+		default:
+			for _, g := range m[1:] {
+				if g != "" {
+					return g
+				}
+			}
+			return match
+		}
+	})
+	return strings.TrimSpace(text)
+}
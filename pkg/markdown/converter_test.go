@@ -0,0 +1,136 @@
+package markdown
+
+import "testing"
+
+func TestBlockquotes(t *testing.T) {
+	native := Options{}
+	prefix := Options{Quotes: QuotePrefix}
+
+	tests := []struct {
+		name    string
+		convert func(string, Options) string
+		input   string
+		opts    Options
+		want    string
+	}{
+		{
+			name:    "html native quote",
+			convert: ToTelegramHTML,
+			input:   "> quoted *text*\n\nafter",
+			opts:    native,
+			want:    "<blockquote>quoted <i>text</i></blockquote>\n\nafter",
+		},
+		{
+			name:    "html nested quotes flattened",
+			convert: ToTelegramHTML,
+			input:   "> outer\n>\n> > inner",
+			opts:    native,
+			want:    "<blockquote>outer\n\ninner</blockquote>",
+		},
+		{
+			name:    "html prefixed quote",
+			convert: ToTelegramHTML,
+			input:   "> quoted *text*\n\nafter",
+			opts:    prefix,
+			want:    "&gt; quoted <i>text</i>\n\nafter",
+		},
+		{
+			name:    "html nested prefixed quotes",
+			convert: ToTelegramHTML,
+			input:   "> outer\n>\n> > inner",
+			opts:    prefix,
+			want:    "&gt; outer\n&gt;\n&gt;\n&gt; &gt; inner",
+		},
+		{
+			name:    "markdownv2 native quote",
+			convert: ToMarkdownV2,
+			input:   "> quoted *text*.\nafter",
+			opts:    native,
+			want:    ">quoted _text_\\.\nafter",
+		},
+		{
+			name:    "markdownv2 prefixed quote",
+			convert: ToMarkdownV2,
+			input:   "> quoted *text*.\nafter",
+			opts:    prefix,
+			want:    "\\> quoted _text_\\.\nafter",
+		},
+		{
+			name:    "plain text keeps the prefix",
+			convert: ToPlainText,
+			input:   "> quoted *text*\nafter",
+			opts:    native,
+			want:    "> quoted text\nafter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.convert(tt.input, tt.opts); got != tt.want {
+				t.Errorf("converted %q to\n%q\nwant\n%q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	link := Options{}
+	drop := Options{Images: ImageDrop}
+
+	tests := []struct {
+		name    string
+		convert func(string, Options) string
+		input   string
+		opts    Options
+		want    string
+	}{
+		{
+			name:    "html link with the alt text",
+			convert: ToTelegramHTML,
+			input:   `See ![a cat](https://example.com/cat.png "Cat") here`,
+			opts:    link,
+			want:    `See 🖼 <a href="https://example.com/cat.png">a cat</a> here`,
+		},
+		{
+			name:    "html link without alt text",
+			convert: ToTelegramHTML,
+			input:   "![](https://example.com/cat.png)",
+			opts:    link,
+			want:    `🖼 <a href="https://example.com/cat.png">https://example.com/cat.png</a>`,
+		},
+		{
+			name:    "html image dropped",
+			convert: ToTelegramHTML,
+			input:   "See ![a cat](https://example.com/cat.png) here",
+			opts:    drop,
+			want:    "See  here",
+		},
+		{
+			name:    "markdownv2 link",
+			convert: ToMarkdownV2,
+			input:   "![a cat](https://example.com/cat.png)",
+			opts:    link,
+			want:    "🖼 [a cat](https://example.com/cat.png)",
+		},
+		{
+			name:    "plain text shows the url",
+			convert: ToPlainText,
+			input:   "![a cat](https://example.com/cat.png)",
+			opts:    link,
+			want:    "🖼 a cat (https://example.com/cat.png)",
+		},
+		{
+			name:    "images in code are left alone",
+			convert: ToMarkdownV2,
+			input:   "```\n![a cat](cat.png)\n```",
+			opts:    link,
+			want:    "```\n![a cat](cat.png)\n```",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.convert(tt.input, tt.opts); got != tt.want {
+				t.Errorf("converted %q to\n%q\nwant\n%q", tt.input, got, tt.want)
+			}
+		})
+	}
+}